@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/excel"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+)
+
+// agreementOptions holds the flags of the agreement subcommand
+type agreementOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	codedFile  *string
+	outputPath *string
+	noColor    *bool
+}
+
+// newAgreementFlags defines the flags of the agreement subcommand
+func newAgreementFlags() (*flag.FlagSet, *agreementOptions) {
+	flags := flag.NewFlagSet("agreement", flag.ExitOnError)
+	options := &agreementOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		codedFile:  flags.String("coded-file", "", "CSV or Excel file with the theme assignments of human coders (defaults to the review sample)"),
+		outputPath: flags.String("output-path", "", "Agreement file to write (defaults to coding_agreement.yaml next to the state file)"),
+		noColor:    flags.Bool("no-color", false, "Disable colored output"),
+	}
+	flags.Usage = commandUsage("agreement", flags)
+	return flags, options
+}
+
+// runAgreement runs the agreement subcommand, which compares the theme
+// assignments of external coders with those in the state file
+func runAgreement(args []string) {
+	flags, options := newAgreementFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+
+	if *options.configPath == "" {
+		fmt.Println("Please provide a configuration file using the -config flag and a coded file using the -coded-file flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+	codedFile := *options.codedFile
+	if codedFile == "" && cfg.ReviewSampleSize > 0 {
+		codedFile = reviewSamplePath(cfg)
+	}
+	if codedFile == "" {
+		fmt.Println("Please provide a coded file using the -coded-file flag or configure review_sample_size")
+		flags.Usage()
+		os.Exit(1)
+	}
+	outputPath := *options.outputPath
+	if outputPath == "" {
+		outputPath = filepath.Join(filepath.Dir(cfg.StateFilePath), "coding_agreement.yaml")
+	}
+
+	writer := output.NewWriter(logger)
+	result, err := writer.LoadState(cfg.StateFilePath)
+	if err != nil {
+		logger.Error("Failed to load state", "error", err)
+		fmt.Printf("Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+
+	codings, err := excel.NewExcelReader(logger).ReadCodings(codedFile)
+	if err != nil {
+		logger.Error("Failed to read coded file", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	agreement, err := analysis.CompareCodings(result, codings, codedFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writer.SaveCodingAgreement(agreement, outputPath); err != nil {
+		logger.Error("Failed to save coding agreement", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	printCodingAgreement(con, agreement, outputPath)
+}
+
+// printCodingAgreement presents the agreement per theme, the most frequent
+// confusions and the overall agreement
+func printCodingAgreement(con *console.Console, agreement *analysis.CodingAgreement, outputPath string) {
+	con.Heading("Agreement per theme (coders as reference)")
+	rows := [][]string{}
+	for _, theme := range agreement.Themes {
+		rows = append(rows, []string{
+			theme.Theme,
+			fmt.Sprintf("%d", theme.Coded),
+			fmt.Sprintf("%d", theme.Matched),
+			fmt.Sprintf("%.2f", theme.Precision),
+			fmt.Sprintf("%.2f", theme.Recall),
+			fmt.Sprintf("%.2f", theme.F1),
+			fmt.Sprintf("%.2f", theme.Kappa),
+		})
+	}
+	con.Table([]console.Column{{Title: "Theme", Max: 50}, {Title: "Coders", Right: true}, {Title: "Model", Right: true}, {Title: "Precision", Right: true}, {Title: "Recall", Right: true}, {Title: "F1", Right: true}, {Title: "Kappa", Right: true}}, rows)
+
+	if len(agreement.Confusions) > 0 {
+		con.Heading("Most frequent confusions")
+		rows = [][]string{}
+		for _, pair := range agreement.Confusions {
+			rows = append(rows, []string{pair.CodedTheme, pair.MatchedTheme, fmt.Sprintf("%d", pair.Count)})
+		}
+		con.Table([]console.Column{{Title: "Coders assigned", Max: 40}, {Title: "Model assigned instead", Max: 40}, {Title: "Responses", Right: true}}, rows)
+	}
+	if len(agreement.UnknownThemes) > 0 {
+		con.Note(fmt.Sprintf("Coded themes not in the theme list were ignored: %v", agreement.UnknownThemes))
+	}
+
+	items := []console.Item{
+		{Label: "Compared", Value: fmt.Sprintf("%d responses", agreement.Compared)},
+		{Label: "Identical themes", Value: fmt.Sprintf("%.1f%%", agreement.ExactAgreement*100)},
+		{Label: "Mean overlap", Value: fmt.Sprintf("%.2f (Jaccard)", agreement.MeanJaccard)},
+		{Label: "Mean kappa", Value: fmt.Sprintf("%.2f", agreement.MeanKappa)},
+		{Label: "Macro F1", Value: fmt.Sprintf("%.2f", agreement.MacroF1)},
+	}
+	if len(agreement.NotFound) > 0 {
+		items = append(items, console.Item{Label: "Not in state", Value: fmt.Sprintf("%d coded responses", len(agreement.NotFound))})
+	}
+	items = append(items, console.Item{Label: "Agreement", Value: outputPath})
+	con.Panel("Coding agreement", items)
+}
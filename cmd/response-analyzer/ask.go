@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/embedding"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+)
+
+// askOptions holds the flags of the ask subcommand
+type askOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	limit      *int
+	noColor    *bool
+}
+
+// newAskFlags defines the flags of the ask subcommand
+func newAskFlags() (*flag.FlagSet, *askOptions) {
+	flags := flag.NewFlagSet("ask", flag.ExitOnError)
+	options := &askOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		limit:      flags.Int("limit", 20, "Number of responses retrieved to answer from"),
+		noColor:    flags.Bool("no-color", false, "Disable colored output"),
+	}
+	flags.Usage = commandUsage("ask", flags)
+	return flags, options
+}
+
+// runAsk runs the ask subcommand, which answers a question about the analyzed
+// responses, grounded in the responses most similar to the question
+func runAsk(args []string) {
+	flags, options := newAskFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+	logger.SetRunID(analysis.NewRunID())
+
+	question := strings.TrimSpace(strings.Join(flags.Args(), " "))
+	if *options.configPath == "" || question == "" {
+		fmt.Println("Please provide a configuration file using the -config flag and a question")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+
+	writer := output.NewWriter(logger)
+	result, err := writer.LoadState(cfg.StateFilePath)
+	if err != nil {
+		logger.Error("Failed to load state", "error", err)
+		fmt.Printf("Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+
+	embedder, err := embedding.NewEmbedder(logger, cfg.EmbeddingProvider, cfg.EmbeddingModel, cfg.EmbeddingAPIKey)
+	if err != nil {
+		logger.Error("Failed to initialize embedder", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := newClaudeClient(logger, cfg)
+	if err != nil {
+		logger.Error("Failed to initialize Claude client", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	client.SetSurveyQuestion(result.ColumnTitle)
+
+	analyzer := analysis.NewAnalyzer(logger, client)
+	answer, err := analyzer.Ask(result, embedder, question, *options.limit, cfg.ContextPrompt)
+	if err != nil {
+		logger.Error("Failed to answer question", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	printAnswer(con, result, answer)
+	con.Note(fmt.Sprintf("Answered from %d of %d responses retrieved as most similar to the question, cost $%.4f.", len(answer.Retrieved), len(result.ResponseAnalyses), client.GetTotalCost()))
+}
+
+// printAnswer prints the answer and the responses it cites
+func printAnswer(con *console.Console, result *analysis.AnalysisResult, answer *analysis.Answer) {
+	con.Heading(answer.Question)
+	con.Println(answer.Text)
+
+	rows := [][]string{}
+	for _, citation := range answer.Citations {
+		if citation.ResponseID == "" {
+			continue
+		}
+		responseAnalysis := result.ResponseAnalyses[citation.ResponseID]
+		themes := "none"
+		if len(responseAnalysis.Themes) > 0 {
+			themes = strings.Join(responseAnalysis.Themes, ", ")
+		}
+		rows = append(rows, []string{
+			citation.Marker,
+			fmt.Sprintf("%d", responseAnalysis.Response.RowIndex),
+			themes,
+			strings.Join(strings.Fields(responseAnalysis.Response.Text), " "),
+		})
+	}
+	if len(rows) > 0 {
+		con.Heading("Cited responses")
+		con.Table([]console.Column{{Title: "ID"}, {Title: "Row", Right: true}, {Title: "Themes", Max: 30}, {Title: "Response", Max: 70}}, rows)
+	}
+}
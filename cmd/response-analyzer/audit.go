@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+)
+
+// applyAuditOptions holds the flags of the apply-audit subcommand
+type applyAuditOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	auditPath  *string
+	exportPath *string
+	dryRun     *bool
+}
+
+// newApplyAuditFlags defines the flags of the apply-audit subcommand
+func newApplyAuditFlags() (*flag.FlagSet, *applyAuditOptions) {
+	flags := flag.NewFlagSet("apply-audit", flag.ExitOnError)
+	options := &applyAuditOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		auditPath:  flags.String("audit", "", "Edited audit log, .yaml or an .xlsx or .csv file with id and themes columns (default: the audit log of the last run)"),
+		exportPath: flags.String("export", "", "Write the audit log to this .xlsx file for editing instead of applying it"),
+		dryRun:     flags.Bool("dry-run", false, "List the corrections without saving them"),
+	}
+	flags.Usage = commandUsage("apply-audit", flags)
+	return flags, options
+}
+
+// runApplyAudit runs the apply-audit subcommand, which takes the themes
+// corrected by hand in the audit log into the state, so the next run keeps
+// them and regenerates the summaries of the affected themes
+func runApplyAudit(args []string) {
+	flags, options := newApplyAuditFlags()
+	flags.Parse(args)
+
+	logger := logging.NewLogger(*options.verbose)
+
+	if *options.configPath == "" {
+		fmt.Println("Please provide a configuration file using the -config flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+	writer := output.NewWriter(logger)
+	writer.SetOmitResponseText(cfg.OmitResponseText)
+	writer.SetStateEncoding(cfg.StateEncoding)
+	result, err := writer.LoadState(cfg.StateFilePath)
+	if err != nil {
+		logger.Error("Failed to load state", "error", err)
+		fmt.Printf("Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *options.exportPath != "" {
+		if err := writer.ExportAuditWorkbook(result, *options.exportPath); err != nil {
+			logger.Error("Failed to export audit log", "error", err)
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote the audit log of %d responses to %s\n", len(result.ResponseAnalyses), *options.exportPath)
+		fmt.Printf("Edit the themes column and apply it with: apply-audit -config %s -audit %s\n", *options.configPath, *options.exportPath)
+		return
+	}
+
+	auditPath := *options.auditPath
+	if auditPath == "" {
+		auditPath = artifactPath(cfg, result.Run.RunID, "audit.yaml")
+	}
+	codings, err := writer.LoadAuditLog(auditPath)
+	if err != nil {
+		logger.Error("Failed to read audit log", "path", auditPath, "error", err)
+		fmt.Printf("Error reading audit log: %v\n", err)
+		os.Exit(1)
+	}
+	corrections, err := analysis.ApplyCorrections(result, codings)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(corrections.NotFound) > 0 {
+		fmt.Printf("Skipped %d responses of the audit log that are not in the state: %s\n", len(corrections.NotFound), strings.Join(corrections.NotFound, ", "))
+	}
+	if len(corrections.Corrected) == 0 && len(corrections.Locked) == 0 && len(corrections.Unlocked) == 0 {
+		fmt.Printf("The themes in %s match the state, nothing to apply\n", auditPath)
+		return
+	}
+	for _, id := range corrections.Corrected {
+		themes := result.ResponseAnalyses[id].Themes
+		if len(themes) == 0 {
+			themes = []string{"(none)"}
+		}
+		fmt.Printf("%s: %s\n", id, strings.Join(themes, ", "))
+	}
+	if len(corrections.Locked) > 0 {
+		fmt.Printf("Locked: %s\n", strings.Join(corrections.Locked, ", "))
+	}
+	if len(corrections.Unlocked) > 0 {
+		fmt.Printf("Unlocked: %s\n", strings.Join(corrections.Unlocked, ", "))
+	}
+	if *options.dryRun {
+		fmt.Printf("Would correct the themes of %d responses, lock %d and unlock %d (dry run, nothing saved)\n", len(corrections.Corrected), len(corrections.Locked), len(corrections.Unlocked))
+		return
+	}
+
+	if err := writer.SaveState(result, cfg.StateFilePath); err != nil {
+		logger.Error("Failed to save state", "error", err)
+		fmt.Printf("Error saving state: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Corrected the themes of %d responses, locked %d and unlocked %d in %s\n", len(corrections.Corrected), len(corrections.Locked), len(corrections.Unlocked), cfg.StateFilePath)
+	if len(corrections.Corrected) > 0 {
+		fmt.Printf("Marked summaries as stale: %s\n", strings.Join(append(result.StaleThemeSummaries, "global summary"), ", "))
+		fmt.Println("They will be regenerated on the next run, which keeps the corrected themes.")
+	}
+}
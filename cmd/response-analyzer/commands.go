@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// command describes the analysis run or one of the subcommands. The
+// definitions drive the usage messages, shell completions and man page.
+type command struct {
+	name     string // Empty for the analysis run itself
+	synopsis string // Arguments shown in usage messages
+	summary  string
+	flags    func() *flag.FlagSet
+	run      func(args []string)
+}
+
+// commands returns the definitions of all commands
+func commands() []command {
+	return []command{
+		{
+			name:     "",
+			synopsis: "-config config.yaml [-identify-themes-only]",
+			summary:  "Identify themes in the survey responses, match the responses to them and summarize the results",
+			flags: func() *flag.FlagSet {
+				flags := flag.NewFlagSet(binaryName, flag.ExitOnError)
+				newRootFlags(flags)
+				return flags
+			},
+		},
+		{
+			name:     "import",
+			synopsis: "-config config.yaml",
+			summary:  "Add the ticket comments of the configured helpdesk to the Excel file",
+			flags:    func() *flag.FlagSet { flags, _ := newImportFlags(); return flags },
+			run:      runImport,
+		},
+		{
+			name:     "search",
+			synopsis: "-config config.yaml [-limit n] \"query text\"",
+			summary:  "List the analyzed responses most similar to a query text",
+			flags:    func() *flag.FlagSet { flags, _ := newSearchFlags(); return flags },
+			run:      runSearch,
+		},
+		{
+			name:     "ask",
+			synopsis: "-config config.yaml [-limit n] \"question\"",
+			summary:  "Answer a question about the analyzed responses, citing the responses it is based on",
+			flags:    func() *flag.FlagSet { flags, _ := newAskFlags(); return flags },
+			run:      runAsk,
+		},
+		{
+			name:     "report",
+			synopsis: "-config config.yaml [-template-path report.tmpl] [-output-path report.md]",
+			summary:  "Render the report and appendix from the state file without any API calls",
+			flags:    func() *flag.FlagSet { flags, _ := newReportFlags(); return flags },
+			run:      runReport,
+		},
+		{
+			name:     "show",
+			synopsis: "-config config.yaml | -state-file state.yaml [-theme name] [-quotes n]",
+			summary:  "Print the themes, summaries, quotes and run metadata of a state file in the terminal",
+			flags:    func() *flag.FlagSet { flags, _ := newShowFlags(); return flags },
+			run:      runShow,
+		},
+		{
+			name:     "summarize",
+			synopsis: "-config config.yaml -language fr [-template-path report-fr.tmpl]",
+			summary:  "Generate the summaries of the state file in an additional language, keeping the classification",
+			flags:    func() *flag.FlagSet { flags, _ := newSummarizeFlags(); return flags },
+			run:      runSummarize,
+		},
+		{
+			name:     "state",
+			synopsis: "check|compact|export -config config.yaml [-strip-text] [-dry-run] [-anonymized] [-text remove|paraphrase] [-output path]",
+			summary:  "Check the state file for damaged sections, compact it by removing responses no longer in the input, or export an anonymized copy",
+			flags:    newStateFlags,
+			run:      runState,
+		},
+		{
+			name:     "queue",
+			synopsis: "build|drain|status|clear -config config.yaml",
+			summary:  "Queue the theme matching requests of the next run locally and send them separately, resuming after failures",
+			flags:    func() *flag.FlagSet { flags, _ := newQueueFlags(); return flags },
+			run:      runQueue,
+		},
+		{
+			name:     "forget",
+			synopsis: "-config config.yaml -response-id R123 [-row 45]",
+			summary:  "Remove responses from state, cache, audit logs and artifacts on a deletion request",
+			flags:    func() *flag.FlagSet { flags, _ := newForgetFlags(); return flags },
+			run:      runForget,
+		},
+		{
+			name:     "apply-audit",
+			synopsis: "-config config.yaml [-audit audit.yaml] [-export audit.xlsx]",
+			summary:  "Take themes corrected by hand in the audit log into the state",
+			flags:    func() *flag.FlagSet { flags, _ := newApplyAuditFlags(); return flags },
+			run:      runApplyAudit,
+		},
+		{
+			name:     "models",
+			synopsis: "-config config.yaml",
+			summary:  "List the models available to the API key and check the configured models",
+			flags:    func() *flag.FlagSet { flags, _ := newModelsFlags(); return flags },
+			run:      runModels,
+		},
+		{
+			name:     "compare",
+			synopsis: "-config config.yaml [-models model-a,model-b] [-sample n]",
+			summary:  "Match a sample of the responses with two models and compare their agreement, cost and latency",
+			flags:    func() *flag.FlagSet { flags, _ := newCompareFlags(); return flags },
+			run:      runCompare,
+		},
+		{
+			name:     "model-diff",
+			synopsis: "-config config.yaml -model new-model [-sample n]",
+			summary:  "Match a sample of the analyzed responses with a new model and report which theme assignments would change",
+			flags:    func() *flag.FlagSet { flags, _ := newModelDiffFlags(); return flags },
+			run:      runModelDiff,
+		},
+		{
+			name:     "agreement",
+			synopsis: "-config config.yaml -coded-file coded.csv",
+			summary:  "Compare the theme assignments of human coders with those in the state file",
+			flags:    func() *flag.FlagSet { flags, _ := newAgreementFlags(); return flags },
+			run:      runAgreement,
+		},
+		{
+			name:     "glossary",
+			synopsis: "-dir projects [-threshold 0.5] [-config config.yaml] [-output-path glossary.yaml]",
+			summary:  "Compare the themes of the projects in a directory, report near-duplicates and suggest a harmonized glossary",
+			flags:    func() *flag.FlagSet { flags, _ := newGlossaryFlags(); return flags },
+			run:      runGlossary,
+		},
+		{
+			name:     "experiment",
+			synopsis: "-config config.yaml -matrix-file experiment.yaml",
+			summary:  "Match a labelled sample with several models and prompt variants and compare their accuracy and cost",
+			flags:    func() *flag.FlagSet { flags, _ := newExperimentFlags(); return flags },
+			run:      runExperiment,
+		},
+		{
+			name:     "demo",
+			synopsis: "[-output-dir demo] [-responses n] [-seed n]",
+			summary:  "Analyze a synthetic survey with an offline mock provider to try the tool and its templates without data or an API key",
+			flags:    func() *flag.FlagSet { flags, _ := newDemoFlags(); return flags },
+			run:      runDemo,
+		},
+		{
+			name:     "self-update",
+			synopsis: "[-check] [-force]",
+			summary:  "Replace the binary by the latest GitHub release",
+			flags:    func() *flag.FlagSet { flags, _ := newSelfUpdateFlags(); return flags },
+			run:      runSelfUpdate,
+		},
+		{
+			name:     "completion",
+			synopsis: "bash|zsh|fish|powershell|man",
+			summary:  "Generate shell completions or the man page",
+			flags:    func() *flag.FlagSet { return flag.NewFlagSet("completion", flag.ExitOnError) },
+			run:      runCompletion,
+		},
+	}
+}
+
+// binaryName is the name of the command in usage messages, completions and
+// the man page
+const binaryName = "response-analyzer"
+
+// commandUsage returns a usage function for the named command
+func commandUsage(name string, flags *flag.FlagSet) func() {
+	return func() {
+		out := flags.Output()
+		for _, cmd := range commands() {
+			if cmd.name != name {
+				continue
+			}
+			invocation := os.Args[0]
+			if name != "" {
+				invocation += " " + name
+			}
+			fmt.Fprintf(out, "Usage: %s %s\n\n%s\n\n", invocation, cmd.synopsis, cmd.summary)
+		}
+		flags.PrintDefaults()
+
+		// List the subcommands in the usage of the analysis run
+		if name == "" {
+			fmt.Fprintf(out, "\nCommands:\n")
+			for _, cmd := range commands() {
+				if cmd.name != "" {
+					fmt.Fprintf(out, "  %-12s %s\n", cmd.name, cmd.summary)
+				}
+			}
+		}
+	}
+}
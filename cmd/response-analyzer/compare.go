@@ -0,0 +1,244 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/excel"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+	"github.com/oetiker/response-analyzer/pkg/validation"
+)
+
+// comparisonSeed makes repeated comparisons use the same sample
+const comparisonSeed = 1
+
+// compareOptions holds the flags of the compare subcommand
+type compareOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	models     *string
+	sample     *int
+	outputPath *string
+	noColor    *bool
+}
+
+// newCompareFlags defines the flags of the compare subcommand
+func newCompareFlags() (*flag.FlagSet, *compareOptions) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	options := &compareOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		models:     flags.String("models", "", "Two comma-separated models to compare instead of comparison_models"),
+		sample:     flags.Int("sample", 0, "Number of responses to match instead of comparison_sample_size"),
+		outputPath: flags.String("output-path", "", "Comparison file to write (defaults to comparison.yaml next to the state file)"),
+		noColor:    flags.Bool("no-color", false, "Disable colored output"),
+	}
+	flags.Usage = commandUsage("compare", flags)
+	return flags, options
+}
+
+// runCompare runs the compare subcommand, which matches a sample of the
+// responses with two models at the same time and compares their agreement,
+// cost and latency
+func runCompare(args []string) {
+	flags, options := newCompareFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+	logger.SetRunID(analysis.NewRunID())
+
+	if *options.configPath == "" {
+		fmt.Println("Please provide a configuration file using the -config flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+	if *options.models != "" {
+		cfg.ComparisonModels = strings.Split(*options.models, ",")
+		for i, model := range cfg.ComparisonModels {
+			cfg.ComparisonModels[i] = strings.TrimSpace(model)
+		}
+	}
+	if *options.sample != 0 {
+		cfg.ComparisonSampleSize = *options.sample
+	}
+	if len(cfg.ComparisonModels) == 0 {
+		fmt.Println("Please configure comparison_models or pass two models using the -models flag")
+		os.Exit(1)
+	}
+	outputPath := *options.outputPath
+	if outputPath == "" {
+		outputPath = filepath.Join(filepath.Dir(cfg.StateFilePath), "comparison.yaml")
+	}
+
+	comparison, err := compareModels(logger, cfg)
+	if err != nil {
+		logger.Error("Model comparison failed", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	writer := output.NewWriter(logger)
+	if err := writer.SaveModelComparison(comparison, outputPath); err != nil {
+		logger.Error("Failed to save model comparison", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	printComparison(con, comparison, outputPath)
+}
+
+// compareModels matches the same sample of responses with both comparison
+// models concurrently
+func compareModels(logger *logging.Logger, cfg *config.Config) (*analysis.ModelComparison, error) {
+	validator := validation.NewValidator(logger)
+	if err := validator.ValidateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	excelReader, err := newExcelReader(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	excelData, err := excelReader.ReadResponses(cfg.ExcelFilePath, cfg.ResponseColumn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read responses: %w", err)
+	}
+	responses := excelData.Responses
+	if cfg.Pseudonymize {
+		responses = analysis.PseudonymizeResponses(responses, cfg.PseudonymizationSalt)
+	}
+
+	themes, descriptions, err := matchingThemes(logger, cfg, validator)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := analysis.SampleResponses(responses, cfg.ComparisonSampleSize, comparisonSeed)
+	logger.Info("Comparing models", "models", strings.Join(cfg.ComparisonModels, ","), "responses", len(sample), "themes", len(themes))
+
+	runs := make([]analysis.ModelRun, len(cfg.ComparisonModels))
+	var wg sync.WaitGroup
+	for i, model := range cfg.ComparisonModels {
+		modelCfg := *cfg
+		modelCfg.ClaudeModel = model
+		modelCfg.CacheEnabled = false // Cached answers would hide cost and latency
+		if !claude.SupportsThinking(model) {
+			modelCfg.ThinkingBudgetTokens = 0
+		}
+		// The models run concurrently, tag their log lines
+		modelLogger := logger.Worker(model)
+		client, err := newClaudeClient(modelLogger, &modelCfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkModel(client, model); err != nil {
+			return nil, err
+		}
+		client.SetThemeDescriptions(descriptions)
+		client.SetSurveyQuestion(surveyQuestion(cfg, excelData))
+
+		wg.Add(1)
+		go func(i int, client *claude.Client) {
+			defer wg.Done()
+			runs[i] = runModelMatching(modelLogger, cfg, client, sample, themes)
+		}(i, client)
+	}
+	wg.Wait()
+
+	comparison := analysis.CompareModelRuns(sample, themes, runs[0], runs[1])
+	return &comparison, nil
+}
+
+// matchingThemes returns the configured themes or, failing that, those of
+// the state, for matching samples outside of a full run
+func matchingThemes(logger *logging.Logger, cfg *config.Config, validator *validation.Validator) ([]string, map[string]claude.ThemeDescription, error) {
+	themes := cfg.Themes
+	descriptions := cfg.ThemeDescriptions
+	if len(themes) == 0 {
+		if stateExists, _ := validator.ValidateStateFile(cfg.StateFilePath); stateExists {
+			writer := output.NewWriter(logger)
+			if previous, err := writer.LoadState(cfg.StateFilePath); err == nil {
+				themes = previous.Themes
+				if len(descriptions) == 0 {
+					descriptions = previous.ThemeDescriptions
+				}
+			}
+		}
+	}
+	if len(themes) == 0 {
+		return nil, nil, fmt.Errorf("no themes to match, configure themes or run an analysis first")
+	}
+	return themes, descriptions, nil
+}
+
+// runModelMatching matches the sampled responses with one model, recording
+// its cost and wall-clock time
+func runModelMatching(logger *logging.Logger, cfg *config.Config, client *claude.Client, sample []excel.Response, themes []string) analysis.ModelRun {
+	run := analysis.ModelRun{Model: client.Model(), Responses: len(sample)}
+	analyzer := analysis.NewAnalyzer(logger, client)
+	analyzer.SetAutoParallel(cfg.AutoParallelWorkers)
+
+	startedAt := time.Now()
+	analyses, err := analyzer.MatchResponsesToThemesParallel(sample, themes, cfg.ContextPrompt, nil, cfg.BatchSize, cfg.ParallelWorkers)
+	run.Seconds = time.Since(startedAt).Seconds()
+	if err != nil {
+		logger.Warn("Theme matching failed", "model", run.Model, "error", err)
+		run.Error = err.Error()
+	}
+
+	run.Analyses = analyses
+	run.Failed = len(sample) - len(analyses)
+	run.Cost = client.GetTotalCost()
+	run.Tokens = client.GetTotalTokens()
+	return run
+}
+
+// printComparison prints the comparison of two models
+func printComparison(con *console.Console, comparison *analysis.ModelComparison, outputPath string) {
+	con.Heading("Models")
+	rows := [][]string{}
+	for _, run := range comparison.Runs {
+		rows = append(rows, []string{
+			run.Model,
+			fmt.Sprintf("%d", run.Failed),
+			fmt.Sprintf("$%.4f", run.Cost),
+			fmt.Sprintf("%d", run.Tokens),
+			fmt.Sprintf("%.1fs", run.Seconds),
+			fmt.Sprintf("%.2fs", run.SecondsPerResponse()),
+		})
+	}
+	con.Table([]console.Column{{Title: "Model"}, {Title: "Failed", Right: true}, {Title: "Cost", Right: true}, {Title: "Tokens", Right: true}, {Title: "Duration", Right: true}, {Title: "Per response", Right: true}}, rows)
+
+	con.Heading("Agreement per theme")
+	rows = [][]string{}
+	for _, theme := range comparison.Themes {
+		rows = append(rows, []string{theme.Theme, fmt.Sprintf("%d", theme.FirstCount), fmt.Sprintf("%d", theme.SecondCount), fmt.Sprintf("%.2f", theme.Kappa)})
+	}
+	con.Table([]console.Column{{Title: "Theme"}, {Title: comparison.Runs[0].Model, Right: true}, {Title: comparison.Runs[1].Model, Right: true}, {Title: "Kappa", Right: true}}, rows)
+	con.Note("Kappa is Cohen's kappa of the theme assignment; above 0.8 is commonly read as strong agreement.")
+
+	con.Panel("Model comparison", []console.Item{
+		{Label: "Sample", Value: fmt.Sprintf("%d responses, %d compared", comparison.SampleSize, comparison.Compared)},
+		{Label: "Identical themes", Value: fmt.Sprintf("%.1f%%", comparison.ExactAgreement*100)},
+		{Label: "Mean overlap", Value: fmt.Sprintf("%.2f (Jaccard)", comparison.MeanJaccard)},
+		{Label: "Mean kappa", Value: fmt.Sprintf("%.2f", comparison.MeanKappa)},
+		{Label: "Comparison", Value: outputPath},
+	})
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// runCompletion runs the completion subcommand, which prints a completion
+// script for a shell or the man page
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s completion bash|zsh|fish|powershell|man\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(out)
+	case "zsh":
+		writeZshCompletion(out)
+	case "fish":
+		writeFishCompletion(out)
+	case "powershell":
+		writePowerShellCompletion(out)
+	case "man":
+		writeManPage(out)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown shell: %s (valid options: bash, zsh, fish, powershell, man)\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// flagInfo describes a flag for completions and the man page
+type flagInfo struct {
+	name      string
+	valueName string // Empty for boolean flags
+	usage     string
+	file      bool // The flag takes a file path
+}
+
+// commandFlags returns the flags of a command in definition order
+func commandFlags(cmd command) []flagInfo {
+	var infos []flagInfo
+	cmd.flags().VisitAll(func(f *flag.Flag) {
+		valueName, usage := flag.UnquoteUsage(f)
+		if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && boolFlag.IsBoolFlag() {
+			valueName = ""
+		}
+		infos = append(infos, flagInfo{
+			name:      f.Name,
+			valueName: valueName,
+			usage:     usage,
+			file:      f.Name == "config" || strings.HasSuffix(f.Name, "-file") || strings.HasSuffix(f.Name, "-path"),
+		})
+	})
+	return infos
+}
+
+// subcommands returns the commands other than the analysis run
+func subcommands() []command {
+	var cmds []command
+	for _, cmd := range commands() {
+		if cmd.name != "" {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// rootCommand returns the definition of the analysis run
+func rootCommand() command {
+	for _, cmd := range commands() {
+		if cmd.name == "" {
+			return cmd
+		}
+	}
+	return command{}
+}
+
+// flagNames returns the flags of a command as a space separated list
+func flagNames(cmd command) string {
+	var names []string
+	for _, f := range commandFlags(cmd) {
+		names = append(names, "-"+f.name)
+	}
+	return strings.Join(names, " ")
+}
+
+// writeBashCompletion writes the bash completion script
+func writeBashCompletion(out io.Writer) {
+	var fileFlags []string
+	seen := make(map[string]bool)
+	for _, cmd := range commands() {
+		for _, f := range commandFlags(cmd) {
+			if f.file && !seen[f.name] {
+				seen[f.name] = true
+				fileFlags = append(fileFlags, "-"+f.name, "--"+f.name)
+			}
+		}
+	}
+	var names []string
+	for _, cmd := range subcommands() {
+		names = append(names, cmd.name)
+	}
+
+	fmt.Fprintf(out, "# bash completion for %s\n", binaryName)
+	fmt.Fprintf(out, "_response_analyzer() {\n")
+	fmt.Fprintf(out, "    local cur prev cmd opts\n")
+	fmt.Fprintf(out, "    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(out, "    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(out, "    cmd=\"\"\n")
+	fmt.Fprintf(out, "    if [[ ${COMP_CWORD} -gt 1 ]]; then\n        cmd=\"${COMP_WORDS[1]}\"\n    fi\n")
+	fmt.Fprintf(out, "    case \"${prev}\" in\n")
+	if len(fileFlags) > 0 {
+		fmt.Fprintf(out, "        %s)\n            COMPREPLY=( $(compgen -f -- \"${cur}\") )\n            return 0\n            ;;\n", strings.Join(fileFlags, "|"))
+	}
+	fmt.Fprintf(out, "    esac\n")
+	fmt.Fprintf(out, "    case \"${cmd}\" in\n")
+	for _, cmd := range subcommands() {
+		opts := flagNames(cmd)
+		switch cmd.name {
+		case "completion":
+			opts = "bash zsh fish powershell man"
+		case "state":
+			opts = "check compact export " + opts
+		case "queue":
+			opts = "build drain status clear " + opts
+		}
+		fmt.Fprintf(out, "        %s)\n            opts=\"%s\"\n            ;;\n", cmd.name, opts)
+	}
+	fmt.Fprintf(out, "        *)\n            opts=\"%s %s\"\n            ;;\n", strings.Join(names, " "), flagNames(rootCommand()))
+	fmt.Fprintf(out, "    esac\n")
+	fmt.Fprintf(out, "    COMPREPLY=( $(compgen -W \"${opts}\" -- \"${cur}\") )\n")
+	fmt.Fprintf(out, "}\n")
+	fmt.Fprintf(out, "complete -o default -F _response_analyzer %s\n", binaryName)
+}
+
+// zshArguments returns the _arguments specifications of the flags of a command
+func zshArguments(cmd command) []string {
+	var specs []string
+	for _, f := range commandFlags(cmd) {
+		spec := fmt.Sprintf("'-%s[%s]", f.name, zshEscape(f.usage))
+		switch {
+		case f.file:
+			spec += ":" + f.valueName + ":_files"
+		case f.valueName != "":
+			spec += ":" + f.valueName + ":"
+		}
+		specs = append(specs, spec+"'")
+	}
+	return specs
+}
+
+// zshEscape escapes text for use in zsh completion specifications
+func zshEscape(text string) string {
+	replacer := strings.NewReplacer("'", "'\\''", "[", "\\[", "]", "\\]", ":", "\\:")
+	return replacer.Replace(text)
+}
+
+// writeZshCompletion writes the zsh completion script
+func writeZshCompletion(out io.Writer) {
+	fmt.Fprintf(out, "#compdef %s\n\n", binaryName)
+	fmt.Fprintf(out, "_response_analyzer() {\n")
+	fmt.Fprintf(out, "    local -a commands\n")
+	fmt.Fprintf(out, "    commands=(\n")
+	for _, cmd := range subcommands() {
+		fmt.Fprintf(out, "        '%s:%s'\n", cmd.name, zshEscape(cmd.summary))
+	}
+	fmt.Fprintf(out, "    )\n\n")
+	fmt.Fprintf(out, "    if (( CURRENT == 2 )) && [[ ${words[2]} != -* ]]; then\n")
+	fmt.Fprintf(out, "        _describe -t commands 'command' commands\n")
+	fmt.Fprintf(out, "    fi\n\n")
+	fmt.Fprintf(out, "    case ${words[2]} in\n")
+	for _, cmd := range subcommands() {
+		specs := zshArguments(cmd)
+		switch cmd.name {
+		case "completion":
+			specs = append(specs, "'1:target:(bash zsh fish powershell man)'")
+		case "search":
+			specs = append(specs, "'*:query:'")
+		case "state":
+			specs = append(specs, "'1:action:(check compact export)'")
+		case "queue":
+			specs = append(specs, "'1:action:(build drain status clear)'")
+		}
+		fmt.Fprintf(out, "        %s)\n            words=(${words[1]} ${words[3,-1]})\n            (( CURRENT-- ))\n", cmd.name)
+		fmt.Fprintf(out, "            _arguments %s\n            ;;\n", strings.Join(specs, " \\\n                "))
+	}
+	fmt.Fprintf(out, "        *)\n            _arguments %s\n            ;;\n", strings.Join(zshArguments(rootCommand()), " \\\n                "))
+	fmt.Fprintf(out, "    esac\n")
+	fmt.Fprintf(out, "}\n\n")
+	fmt.Fprintf(out, "_response_analyzer \"$@\"\n")
+}
+
+// fishEscape escapes text for use in single quotes in fish
+func fishEscape(text string) string {
+	return strings.NewReplacer("\\", "\\\\", "'", "\\'").Replace(text)
+}
+
+// writeFishFlags writes the fish completions of the flags of a command
+func writeFishFlags(out io.Writer, cmd command, condition string) {
+	for _, f := range commandFlags(cmd) {
+		line := fmt.Sprintf("complete -c %s -n '%s' -o %s", binaryName, condition, f.name)
+		if f.valueName != "" {
+			line += " -r"
+		}
+		if f.file {
+			line += " -F"
+		}
+		fmt.Fprintf(out, "%s -d '%s'\n", line, fishEscape(f.usage))
+	}
+}
+
+// writeFishCompletion writes the fish completion script
+func writeFishCompletion(out io.Writer) {
+	fmt.Fprintf(out, "# fish completion for %s\n", binaryName)
+	fmt.Fprintf(out, "complete -c %s -f\n", binaryName)
+	for _, cmd := range subcommands() {
+		fmt.Fprintf(out, "complete -c %s -n '__fish_use_subcommand' -a %s -d '%s'\n", binaryName, cmd.name, fishEscape(cmd.summary))
+	}
+	writeFishFlags(out, rootCommand(), "__fish_use_subcommand")
+	for _, cmd := range subcommands() {
+		condition := "__fish_seen_subcommand_from " + cmd.name
+		writeFishFlags(out, cmd, condition)
+		switch cmd.name {
+		case "completion":
+			fmt.Fprintf(out, "complete -c %s -n '%s' -a 'bash zsh fish powershell man'\n", binaryName, condition)
+		case "state":
+			fmt.Fprintf(out, "complete -c %s -n '%s' -a 'check compact export'\n", binaryName, condition)
+		case "queue":
+			fmt.Fprintf(out, "complete -c %s -n '%s' -a 'build drain status clear'\n", binaryName, condition)
+		}
+	}
+}
+
+// powerShellList formats values as a PowerShell array
+func powerShellList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return "@(" + strings.Join(quoted, ", ") + ")"
+}
+
+// writePowerShellCompletion writes the PowerShell completion script
+func writePowerShellCompletion(out io.Writer) {
+	var names []string
+	for _, cmd := range subcommands() {
+		names = append(names, cmd.name)
+	}
+	rootOptions := append(names, strings.Fields(flagNames(rootCommand()))...)
+
+	fmt.Fprintf(out, "# PowerShell completion for %s\n", binaryName)
+	fmt.Fprintf(out, "Register-ArgumentCompleter -Native -CommandName '%s', '%s.exe' -ScriptBlock {\n", binaryName, binaryName)
+	fmt.Fprintf(out, "    param($wordToComplete, $commandAst, $cursorPosition)\n")
+	fmt.Fprintf(out, "    $elements = $commandAst.CommandElements\n")
+	fmt.Fprintf(out, "    $command = ''\n")
+	fmt.Fprintf(out, "    if ($elements.Count -gt 2 -or ($elements.Count -eq 2 -and $wordToComplete -eq '')) {\n")
+	fmt.Fprintf(out, "        $command = $elements[1].ToString()\n")
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "    $options = switch ($command) {\n")
+	for _, cmd := range subcommands() {
+		options := strings.Fields(flagNames(cmd))
+		switch cmd.name {
+		case "completion":
+			options = []string{"bash", "zsh", "fish", "powershell", "man"}
+		case "state":
+			options = append([]string{"check", "compact", "export"}, options...)
+		case "queue":
+			options = append([]string{"build", "drain", "status", "clear"}, options...)
+		}
+		fmt.Fprintf(out, "        '%s' { %s }\n", cmd.name, powerShellList(options))
+	}
+	fmt.Fprintf(out, "        default { %s }\n", powerShellList(rootOptions))
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "    $options | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {\n")
+	fmt.Fprintf(out, "        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)\n")
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "}\n")
+}
+
+// roffEscape escapes text for use in a man page
+func roffEscape(text string) string {
+	text = strings.NewReplacer("\\", "\\e", "-", "\\-").Replace(text)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = "\\&" + text
+	}
+	return text
+}
+
+// writeManFlags writes the flags of a command as man page paragraphs
+func writeManFlags(out io.Writer, cmd command) {
+	for _, f := range commandFlags(cmd) {
+		fmt.Fprintf(out, ".TP\n\\fB\\-%s\\fR", roffEscape(f.name))
+		if f.valueName != "" {
+			fmt.Fprintf(out, " \\fI%s\\fR", roffEscape(f.valueName))
+		}
+		fmt.Fprintf(out, "\n%s\n", roffEscape(f.usage))
+	}
+}
+
+// writeManPage writes the man page in roff format
+func writeManPage(out io.Writer) {
+	root := rootCommand()
+	fmt.Fprintf(out, ".TH RESPONSE\\-ANALYZER 1 \"%s\" \"%s %s\" \"User Commands\"\n", time.Now().Format("2006-01-02"), binaryName, roffEscape(currentVersion()))
+	fmt.Fprintf(out, ".SH NAME\n%s \\- analyze free\\-text survey responses with Claude\n", roffEscape(binaryName))
+	fmt.Fprintf(out, ".SH SYNOPSIS\n.B %s\n%s\n", roffEscape(binaryName), roffEscape(root.synopsis))
+	for _, cmd := range subcommands() {
+		fmt.Fprintf(out, ".br\n.B %s %s\n%s\n", roffEscape(binaryName), roffEscape(cmd.name), roffEscape(cmd.synopsis))
+	}
+	fmt.Fprintf(out, ".SH DESCRIPTION\n%s.\n", roffEscape(root.summary))
+	fmt.Fprintf(out, "The analysis is configured in a YAML file; see config\\-sample.yaml for all options.\n")
+	fmt.Fprintf(out, ".SH OPTIONS\n")
+	writeManFlags(out, root)
+	fmt.Fprintf(out, ".SH COMMANDS\n")
+	for _, cmd := range subcommands() {
+		fmt.Fprintf(out, ".SS %s\n%s.\n", roffEscape(cmd.name), roffEscape(cmd.summary))
+		fmt.Fprintf(out, ".PP\n\\fB%s %s\\fR %s\n", roffEscape(binaryName), roffEscape(cmd.name), roffEscape(cmd.synopsis))
+		writeManFlags(out, cmd)
+	}
+	fmt.Fprintf(out, ".SH ENVIRONMENT\n.TP\n.B NO_COLOR\nDisable colored output.\n")
+	fmt.Fprintf(out, ".SH SEE ALSO\nhttps://github.com/oetiker/response\\-analyzer\n")
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/xuri/excelize/v2"
+)
+
+// demoTheme is a theme of the synthetic survey with the statements the
+// responses are built from
+type demoTheme struct {
+	name       string
+	weight     int // Relative frequency of the theme
+	rating     int // Typical rating of respondents mentioning the theme
+	statements []string
+}
+
+// demoThemes are the themes of the synthetic survey. The statements name
+// their theme, so the mock provider matches them.
+var demoThemes = []demoTheme{
+	{name: "Delivery speed", weight: 30, rating: 4, statements: []string{
+		"Delivery took almost two weeks.",
+		"My parcel was delivered late again.",
+		"Faster delivery would make a big difference.",
+		"The delivery was delayed twice without notice.",
+		"Delivery times are much longer than promised.",
+	}},
+	{name: "Pricing", weight: 20, rating: 6, statements: []string{
+		"The prices went up a lot this year.",
+		"Pricing is too high compared to other shops.",
+		"I would order more often with lower prices.",
+		"Shipping fees make the price hard to accept.",
+	}},
+	{name: "Customer support", weight: 20, rating: 3, statements: []string{
+		"Customer support never answered my email.",
+		"The support hotline kept me waiting for an hour.",
+		"Support staff were friendly but could not solve my problem.",
+		"It is impossible to reach a customer service agent by phone.",
+	}},
+	{name: "Product quality", weight: 15, rating: 7, statements: []string{
+		"The quality of the products has dropped.",
+		"Two items broke after a week, quality control needs work.",
+		"I love the quality, the items last for years.",
+		"The quality does not match the pictures in the shop.",
+	}},
+	{name: "Mobile app", weight: 10, rating: 6, statements: []string{
+		"The mobile app crashes when I open my orders.",
+		"Please add order tracking to the mobile app.",
+		"The mobile app is slow and logs me out all the time.",
+	}},
+	{name: "Checkout and payment", weight: 10, rating: 5, statements: []string{
+		"Checkout fails whenever I use a voucher.",
+		"Please offer more payment options such as invoices.",
+		"The checkout asks for my address three times.",
+		"My payment was charged twice.",
+	}},
+}
+
+// demoOthers are responses matching none of the themes
+var demoOthers = []string{
+	"All fine, keep up the good work!",
+	"Nothing to add.",
+	"I am happy with everything.",
+	"No complaints so far.",
+}
+
+// demoOpeners and demoClosers vary the wording of the responses
+var (
+	demoOpeners = []string{"", "", "", "Honestly, ", "To be frank, ", "Overall okay, but "}
+	demoClosers = []string{"", "", "", " Thanks for asking.", " Otherwise I am happy.", " Please fix this."}
+)
+
+// demoOptions holds the flags of the demo subcommand
+type demoOptions struct {
+	outputDir    *string
+	responses    *int
+	seed         *int64
+	templatePath *string
+	verbose      *bool
+	noColor      *bool
+}
+
+// newDemoFlags defines the flags of the demo subcommand
+func newDemoFlags() (*flag.FlagSet, *demoOptions) {
+	flags := flag.NewFlagSet("demo", flag.ExitOnError)
+	options := &demoOptions{
+		outputDir:    flags.String("output-dir", "demo", "Directory for the synthetic survey, its configuration and the results"),
+		responses:    flags.Int("responses", 150, "Number of synthetic responses"),
+		seed:         flags.Int64("seed", 1, "Seed of the synthetic responses, the same seed gives the same results"),
+		templatePath: flags.String("template-path", "report-template-en.tmpl", "Report template to render, skipped if it does not exist"),
+		verbose:      flags.Bool("verbose", false, "Enable verbose logging"),
+		noColor:      flags.Bool("no-color", false, "Disable colored output"),
+	}
+	flags.Usage = commandUsage("demo", flags)
+	return flags, options
+}
+
+// runDemo runs the demo subcommand, which generates a synthetic survey and
+// analyzes it with the offline mock provider, so the tool and its templates
+// can be evaluated without data or an API key
+func runDemo(args []string) {
+	flags, options := newDemoFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+	runID := analysis.NewRunID()
+	logger.SetRunID(runID)
+	logger.CollectWarnings()
+
+	if *options.responses < 1 {
+		fmt.Println("Error: -responses must be at least 1")
+		os.Exit(1)
+	}
+	dir := *options.outputDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("Error creating output directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	surveyPath := filepath.Join(dir, "survey.xlsx")
+	if err := writeDemoSurvey(surveyPath, *options.responses, *options.seed); err != nil {
+		fmt.Printf("Error writing synthetic survey: %v\n", err)
+		os.Exit(1)
+	}
+	logger.Info("Generated synthetic survey", "path", surveyPath, "responses", *options.responses)
+
+	templatePath := *options.templatePath
+	if _, err := os.Stat(templatePath); err != nil {
+		logger.Info("Report template not found, skipping the report", "path", templatePath)
+		templatePath = ""
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(demoConfig(dir, templatePath)), 0644); err != nil {
+		fmt.Printf("Error writing configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Start from scratch, so the same seed always gives the same results
+	cfg := loadConfig(logger, configPath, "")
+	if err := os.Remove(cfg.StateFilePath); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Error removing previous state: %v\n", err)
+		os.Exit(1)
+	}
+
+	summary, err := runWorkflow(context.Background(), logger, con, cfg, false, true, true, analysis.PilotSampling{})
+	if err != nil {
+		logger.Error("Workflow failed", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	summary.addArtifact("Survey", surveyPath)
+	summary.addArtifact("Configuration", configPath)
+	printRunSummary(logger, con, runID, summary)
+	con.Note("The themes, matches and summaries come from the offline mock provider and are synthetic; " +
+		"the cost is what the API would have charged. Copy " + configPath + ", remove mock_provider, " +
+		"set claude_api_key and point excel_file_path to your survey to analyze real responses.")
+}
+
+// writeDemoSurvey writes a synthetic survey with a submission time, a
+// rating from 0 to 10 and a free-text response per row. Delivery complaints
+// become more frequent over the three months of the survey, so the time
+// series shows a trend.
+func writeDemoSurvey(path string, count int, seed int64) error {
+	random := rand.New(rand.NewSource(seed))
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := f.GetSheetName(0)
+
+	rows := [][]interface{}{{"Submitted", "Rating (0-10)", "What should we improve?"}}
+	start := time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC)
+	span := 84 * 24 * time.Hour // Until the end of March
+	for i := 0; i < count; i++ {
+		progress := float64(i) / float64(count)
+		submitted := start.Add(time.Duration(progress * float64(span))).Add(time.Duration(random.Intn(600)) * time.Minute)
+
+		var text string
+		rating := 9 + random.Intn(2)
+		if random.Intn(10) == 0 {
+			text = demoOthers[random.Intn(len(demoOthers))]
+		} else {
+			themes := []demoTheme{pickDemoTheme(random, progress)}
+			if random.Intn(3) == 0 {
+				if second := pickDemoTheme(random, progress); second.name != themes[0].name {
+					themes = append(themes, second)
+				}
+			}
+			var statements []string
+			rating = 10
+			for _, theme := range themes {
+				statements = append(statements, theme.statements[random.Intn(len(theme.statements))])
+				rating = min(rating, theme.rating)
+			}
+			rating = max(0, min(10, rating+random.Intn(3)-1))
+			text = demoOpeners[random.Intn(len(demoOpeners))]
+			if text != "" {
+				text += strings.ToLower(statements[0][:1]) + statements[0][1:]
+			} else {
+				text = statements[0]
+			}
+			if len(statements) > 1 {
+				text += " " + statements[1]
+			}
+			text += demoClosers[random.Intn(len(demoClosers))]
+		}
+		rows = append(rows, []interface{}{submitted.Format("2006-01-02 15:04"), rating, text})
+	}
+
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", i+1, err)
+		}
+	}
+	if err := f.SetColWidth(sheet, "A", "B", 16); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(sheet, "C", "C", 80); err != nil {
+		return err
+	}
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("failed to save survey: %w", err)
+	}
+	return nil
+}
+
+// pickDemoTheme picks a theme by weight, with delivery speed gaining weight
+// as the survey progresses
+func pickDemoTheme(random *rand.Rand, progress float64) demoTheme {
+	weights := make([]int, len(demoThemes))
+	total := 0
+	for i, theme := range demoThemes {
+		weights[i] = theme.weight
+		if i == 0 {
+			weights[i] = int(float64(theme.weight) * (0.5 + progress))
+		}
+		total += weights[i]
+	}
+	pick := random.Intn(total)
+	for i, weight := range weights {
+		if pick < weight {
+			return demoThemes[i]
+		}
+		pick -= weight
+	}
+	return demoThemes[len(demoThemes)-1]
+}
+
+// demoConfig returns the configuration of the demo, with all files in dir
+func demoConfig(dir, templatePath string) string {
+	var b strings.Builder
+	b.WriteString("# Configuration written by the demo subcommand. The offline mock provider\n")
+	b.WriteString("# answers all requests, so no API key is needed and the results are synthetic.\n")
+	b.WriteString("mock_provider: true\n\n")
+	fmt.Fprintf(&b, "excel_file_path: %q\n", filepath.Join(dir, "survey.xlsx"))
+	b.WriteString("response_column: \"C\"\n")
+	b.WriteString("timestamp_column: \"A\"\n")
+	b.WriteString("rating_column: \"B\"\n")
+	b.WriteString("rating_bands:\n")
+	b.WriteString("  - {name: \"Detractors\", min: 0, max: 6}\n")
+	b.WriteString("  - {name: \"Passives\", min: 7, max: 8}\n")
+	b.WriteString("  - {name: \"Promoters\", min: 9, max: 10}\n\n")
+	b.WriteString("claude_model: \"claude-sonnet-4-5-20250929\"\n")
+	b.WriteString("context_prompt: \"Analyze these responses of online shop customers to the question what we should improve.\"\n")
+	b.WriteString("theme_summary_prompt: \"Summarize what the customers say about this theme.\"\n")
+	b.WriteString("global_summary_prompt: \"Summarize the main improvement requests of the customers.\"\n")
+	b.WriteString("global_summary_length: 600\n")
+	b.WriteString("output_language: \"en\"\n")
+	b.WriteString("cache_enabled: false\n\n")
+	b.WriteString("themes:\n")
+	for _, theme := range demoThemes {
+		fmt.Fprintf(&b, "  - %q\n", theme.name)
+	}
+	b.WriteString("generate_theme_descriptions: true\n")
+	b.WriteString("summary_citations: true\n")
+	b.WriteString("verify_summaries: true\n")
+	b.WriteString("topic_model_topics: 4\n\n")
+	fmt.Fprintf(&b, "state_file_path: %q\n", filepath.Join(dir, "state.yaml"))
+	if templatePath != "" {
+		fmt.Fprintf(&b, "report_template_path: %q\n", templatePath)
+		fmt.Fprintf(&b, "report_output_path: %q\n", filepath.Join(dir, "report.md"))
+	}
+	b.WriteString("appendix_format: \"markdown\"\n")
+	fmt.Fprintf(&b, "appendix_output_path: %q\n", filepath.Join(dir, "appendix.md"))
+	fmt.Fprintf(&b, "wide_export_path: %q\n", filepath.Join(dir, "responses_wide.csv"))
+	fmt.Fprintf(&b, "excel_output_path: %q\n", filepath.Join(dir, "results.xlsx"))
+	return b.String()
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/excel"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+	"github.com/oetiker/response-analyzer/pkg/validation"
+)
+
+// experimentOptions holds the flags of the experiment subcommand
+type experimentOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	matrixFile *string
+	outputPath *string
+	noColor    *bool
+}
+
+// newExperimentFlags defines the flags of the experiment subcommand
+func newExperimentFlags() (*flag.FlagSet, *experimentOptions) {
+	flags := flag.NewFlagSet("experiment", flag.ExitOnError)
+	options := &experimentOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		matrixFile: flags.String("matrix-file", "", "YAML file with the labelled sample and the models and prompt variants to try"),
+		outputPath: flags.String("output-path", "", "Experiment file to write (defaults to experiment.yaml next to the state file)"),
+		noColor:    flags.Bool("no-color", false, "Disable colored output"),
+	}
+	flags.Usage = commandUsage("experiment", flags)
+	return flags, options
+}
+
+// runExperiment runs the experiment subcommand, which matches a labelled
+// sample with every model and prompt variant of a matrix and scores them
+// against the coded themes
+func runExperiment(args []string) {
+	flags, options := newExperimentFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+	logger.SetRunID(analysis.NewRunID())
+
+	if *options.configPath == "" || *options.matrixFile == "" {
+		fmt.Println("Please provide a configuration file using the -config flag and an experiment matrix using the -matrix-file flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+	matrix, err := config.LoadExperimentMatrix(*options.matrixFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// The coded file is relative to the matrix file
+	if !filepath.IsAbs(matrix.CodedFile) {
+		matrix.CodedFile = filepath.Join(filepath.Dir(*options.matrixFile), matrix.CodedFile)
+	}
+	outputPath := *options.outputPath
+	if outputPath == "" {
+		outputPath = filepath.Join(filepath.Dir(cfg.StateFilePath), "experiment.yaml")
+	}
+
+	experiment, err := runExperimentMatrix(logger, cfg, matrix)
+	if err != nil {
+		logger.Error("Experiment failed", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	writer := output.NewWriter(logger)
+	if err := writer.SaveExperiment(experiment, outputPath); err != nil {
+		logger.Error("Failed to save experiment", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	printExperiment(con, experiment, outputPath)
+}
+
+// runExperimentMatrix matches the labelled sample with each combination of
+// model and prompt variant in turn, so the cells do not compete for the
+// rate limits
+func runExperimentMatrix(logger *logging.Logger, cfg *config.Config, matrix *config.ExperimentMatrix) (*analysis.Experiment, error) {
+	validator := validation.NewValidator(logger)
+	if err := validator.ValidateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	reader, err := newExcelReader(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	codings, err := reader.ReadCodings(matrix.CodedFile)
+	if err != nil {
+		return nil, err
+	}
+	excelData, err := reader.ReadResponses(cfg.ExcelFilePath, cfg.ResponseColumn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read responses: %w", err)
+	}
+	responses := excelData.Responses
+	if cfg.Pseudonymize {
+		responses = analysis.PseudonymizeResponses(responses, cfg.PseudonymizationSalt)
+	}
+	themes, descriptions, err := matchingThemes(logger, cfg, validator)
+	if err != nil {
+		return nil, err
+	}
+
+	// The sample are the coded responses, identified by ID or row
+	var sample []excel.Response
+	for _, response := range responses {
+		if slices.ContainsFunc(codings, func(coding excel.Coding) bool {
+			return coding.ID == response.ID || (coding.ID == "" && coding.Row == response.RowIndex)
+		}) {
+			sample = append(sample, response)
+		}
+	}
+	if len(sample) == 0 {
+		return nil, fmt.Errorf("none of the %d coded responses is in the Excel file, check the id or row column", len(codings))
+	}
+	if matrix.SampleSize > 0 {
+		sample = analysis.SampleResponses(sample, matrix.SampleSize, comparisonSeed)
+	}
+
+	models := matrix.Models
+	if len(models) == 0 {
+		models = []string{cfg.ClaudeModel}
+	}
+	experiment := &analysis.Experiment{GeneratedAt: time.Now(), CodedFile: matrix.CodedFile, SampleSize: len(sample)}
+	for _, model := range models {
+		for _, prompt := range matrix.Prompts {
+			cellCfg := prompt.Apply(cfg)
+			cellCfg.ClaudeModel = model
+			cellCfg.CacheEnabled = false // Cached answers would hide cost and latency
+			if !claude.SupportsThinking(model) {
+				cellCfg.ThinkingBudgetTokens = 0
+			}
+			client, err := newClaudeClient(logger, cellCfg)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkModel(client, model); err != nil {
+				return nil, err
+			}
+			client.SetThemeDescriptions(descriptions)
+			client.SetSurveyQuestion(surveyQuestion(cfg, excelData))
+
+			logger.Info("Running experiment cell", "model", model, "prompt", prompt.Name, "responses", len(sample))
+			run := runModelMatching(logger, cellCfg, client, sample, themes)
+			result := &analysis.AnalysisResult{Themes: themes, ResponseAnalyses: run.Analyses}
+			agreement, err := analysis.CompareCodings(result, codings, matrix.CodedFile)
+			if err != nil && run.Error == "" {
+				run.Error = err.Error()
+			}
+			experiment.Cells = append(experiment.Cells, analysis.NewExperimentCell(run, prompt.Name, agreement))
+		}
+	}
+	return experiment, nil
+}
+
+// printExperiment prints the accuracy and cost of each cell of an experiment
+func printExperiment(con *console.Console, experiment *analysis.Experiment, outputPath string) {
+	con.Heading(fmt.Sprintf("Experiment on %d coded responses", experiment.SampleSize))
+	best := experiment.Best()
+	rows := [][]string{}
+	for i, cell := range experiment.Cells {
+		marker := ""
+		if i == best {
+			marker = "*"
+		}
+		f1 := fmt.Sprintf("%.2f", cell.MacroF1)
+		if cell.Error != "" {
+			f1 = "failed"
+		}
+		rows = append(rows, []string{
+			marker,
+			cell.Model,
+			cell.Prompt,
+			f1,
+			fmt.Sprintf("%.2f", cell.MeanKappa),
+			fmt.Sprintf("%.1f%%", cell.ExactAgreement*100),
+			fmt.Sprintf("$%.4f", cell.Cost),
+			fmt.Sprintf("%.1fs", cell.Seconds),
+		})
+	}
+	con.Table([]console.Column{{Title: ""}, {Title: "Model"}, {Title: "Prompt", Max: 30}, {Title: "Macro F1", Right: true}, {Title: "Kappa", Right: true}, {Title: "Identical", Right: true}, {Title: "Cost", Right: true}, {Title: "Duration", Right: true}}, rows)
+	con.Note("Scores treat the coders as reference; * marks the highest macro F1.")
+
+	con.Panel("Experiment", []console.Item{
+		{Label: "Cells", Value: fmt.Sprintf("%d", len(experiment.Cells))},
+		{Label: "Coded file", Value: experiment.CodedFile},
+		{Label: "Experiment", Value: outputPath},
+	})
+}
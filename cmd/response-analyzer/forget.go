@@ -0,0 +1,317 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/cache"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+)
+
+// removedPlaceholder replaces the text of forgotten responses in artifacts
+const removedPlaceholder = "[removed on request]"
+
+// stringList is a flag that can be given multiple times
+type stringList []string
+
+// String returns the flag values
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds a flag value
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// forgetOptions holds the flags of the forget subcommand
+type forgetOptions struct {
+	configPath  *string
+	profile     *string
+	verbose     *bool
+	responseIDs stringList
+	rows        stringList
+}
+
+// newForgetFlags defines the flags of the forget subcommand
+func newForgetFlags() (*flag.FlagSet, *forgetOptions) {
+	flags := flag.NewFlagSet("forget", flag.ExitOnError)
+	options := &forgetOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+	}
+	flags.Var(&options.responseIDs, "response-id", "ID of a response to forget, e.g. R123 (can be repeated)")
+	flags.Var(&options.rows, "row", "Excel row of a respondent whose response to forget (can be repeated)")
+	flags.Usage = commandUsage("forget", flags)
+	return flags, options
+}
+
+// runForget runs the forget subcommand, which removes responses from the
+// state, cache, audit logs and derived artifacts on a data subject's request
+func runForget(args []string) {
+	flags, options := newForgetFlags()
+	flags.Parse(args)
+	configPath, verbose := options.configPath, options.verbose
+	responseIDs, rows := options.responseIDs, options.rows
+
+	logger := logging.NewLogger(*verbose)
+
+	if *configPath == "" || len(responseIDs)+len(rows) == 0 {
+		fmt.Println("Please provide a configuration file using the -config flag and at least one -response-id or -row")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *configPath, *options.profile)
+
+	ids := []string(responseIDs)
+	for _, row := range rows {
+		rowIndex, err := strconv.Atoi(row)
+		if err != nil {
+			fmt.Printf("Invalid row: %s\n", row)
+			os.Exit(1)
+		}
+		ids = append(ids, fmt.Sprintf("R%d", rowIndex))
+	}
+
+	// Map row based IDs to the pseudonyms stored in the state
+	if cfg.Pseudonymize {
+		for i, id := range ids {
+			if strings.HasPrefix(id, "R") {
+				ids[i] = analysis.PseudonymousID(cfg.PseudonymizationSalt, id)
+			}
+		}
+	}
+
+	// Remove the responses from the state
+	writer := output.NewWriter(logger)
+	writer.SetOmitResponseText(cfg.OmitResponseText)
+	writer.SetStateEncoding(cfg.StateEncoding)
+	result, err := writer.LoadState(cfg.StateFilePath)
+	if err != nil {
+		logger.Error("Failed to load state", "error", err)
+		fmt.Printf("Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+	removed := analysis.ForgetResponses(result, ids)
+	if len(removed) == 0 {
+		fmt.Printf("None of the responses %s were found in the state file %s\n", strings.Join(ids, ", "), cfg.StateFilePath)
+		os.Exit(1)
+	}
+	if err := writer.SaveState(result, cfg.StateFilePath); err != nil {
+		logger.Error("Failed to save state", "error", err)
+		fmt.Printf("Error saving state: %v\n", err)
+		os.Exit(1)
+	}
+	// The previous state kept as backup still holds the responses
+	if err := os.Remove(output.StateBackupPath(cfg.StateFilePath)); err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to remove the previous state", "error", err)
+		fmt.Printf("Warning: %s still contains the responses, please remove it manually\n", output.StateBackupPath(cfg.StateFilePath))
+	}
+	removedIDs := make([]string, len(removed))
+	for i, response := range removed {
+		removedIDs[i] = response.ID
+	}
+	fmt.Printf("Removed %d responses from the state: %s\n", len(removed), strings.Join(removedIDs, ", "))
+
+	// Texts are needed to find the responses in the cache and artifacts
+	var texts []string
+	for _, response := range removed {
+		if response.Text != "" {
+			texts = append(texts, response.Text)
+		}
+	}
+	if len(texts) < len(removed) {
+		fmt.Println("Warning: the state does not contain the text of all responses, the cache and artifacts may still contain it")
+	}
+
+	// Remove cached prompts and completions containing the responses
+	if cfg.CacheEnabled && len(texts) > 0 {
+		cacheInstance, err := cache.NewCache(logger, cfg.CacheDir, 24*time.Hour, true)
+		if err != nil {
+			logger.Warn("Failed to open cache", "error", err)
+		} else {
+			count, err := cacheInstance.RemoveMatching(func(key, value string) bool {
+				for _, text := range texts {
+					probe := textProbe(text)
+					if strings.Contains(key, probe) || strings.Contains(value, probe) {
+						return true
+					}
+				}
+				return false
+			})
+			if err != nil {
+				logger.Warn("Failed to remove cache entries", "error", err)
+			}
+			fmt.Printf("Removed %d cache entries\n", count)
+		}
+	}
+
+	// Remove the responses from all audit logs, including those of earlier runs
+	dir := filepath.Dir(cfg.StateFilePath)
+	auditLogs, _ := filepath.Glob(filepath.Join(dir, "audit*.yaml"))
+	for _, path := range auditLogs {
+		count, err := writer.RemoveFromAuditLog(path, removedIDs)
+		if err != nil {
+			logger.Warn("Failed to clean audit log", "path", path, "error", err)
+		} else if count > 0 {
+			fmt.Printf("Removed %d responses from audit log %s\n", count, path)
+		}
+	}
+
+	// Scrub the verbatim text from other artifacts and report what remains
+	var extraPaths []string
+	for _, path := range []string{cfg.ReportOutputPath, cfg.AppendixOutputPath} {
+		extraPaths = append(extraPaths, runIDVariants(path)...)
+	}
+	for _, path := range runIDVariants(cfg.WideExportPath) {
+		extraPaths = append(extraPaths, path, output.CodebookPath(path))
+	}
+	scrub := func(content string) string {
+		for _, text := range texts {
+			content = strings.ReplaceAll(content, text, removedPlaceholder)
+			content = strings.ReplaceAll(content, html.EscapeString(text), removedPlaceholder)
+			if strings.Contains(text, `"`) {
+				// Quotes are doubled in CSV fields
+				content = strings.ReplaceAll(content, strings.ReplaceAll(text, `"`, `""`), removedPlaceholder)
+			}
+		}
+		return content
+	}
+	warnRemaining := func(path, content string) {
+		for _, response := range removed {
+			if response.Text != "" && strings.Contains(content, textProbe(response.Text)) {
+				fmt.Printf("Warning: %s still contains text of %s, please review it manually\n", path, response.ID)
+			}
+		}
+	}
+	for _, path := range artifactFiles(cfg.StateFilePath, extraPaths...) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		content := string(data)
+		scrubbed := scrub(content)
+		if scrubbed != content {
+			if err := os.WriteFile(path, []byte(scrubbed), 0644); err != nil {
+				logger.Warn("Failed to scrub artifact", "path", path, "error", err)
+			} else {
+				fmt.Printf("Removed response text from %s\n", path)
+			}
+		}
+		warnRemaining(path, scrubbed)
+	}
+
+	// Scrub the cells of the exported workbooks, but never the input
+	for _, path := range workbookFiles(cfg) {
+		count, content, err := writer.ScrubWorkbook(path, scrub)
+		if err != nil {
+			logger.Warn("Failed to scrub workbook", "path", path, "error", err)
+			fmt.Printf("Warning: %s could not be checked for text of the responses, please review it manually\n", path)
+			continue
+		}
+		if count > 0 {
+			fmt.Printf("Removed response text from %d cells of %s\n", count, path)
+		}
+		warnRemaining(path, content)
+	}
+
+	if len(result.StaleThemeSummaries) > 0 || result.StaleGlobalSummary {
+		fmt.Printf("Marked summaries as stale: %s\n", strings.Join(append(result.StaleThemeSummaries, "global summary"), ", "))
+		fmt.Println("They will be regenerated on the next run.")
+	}
+	fmt.Printf("Remember to also remove the responses from %s. Until then they are skipped in later runs.\n", cfg.ExcelFilePath)
+}
+
+// textProbe returns a prefix of a response text long enough to identify it
+// in prompts, which may truncate long responses
+func textProbe(text string) string {
+	const maxProbe = 200
+	if len(text) <= maxProbe {
+		return text
+	}
+	probe := text[:maxProbe]
+	for !utf8.ValidString(probe) {
+		probe = probe[:len(probe)-1]
+	}
+	return probe
+}
+
+// artifactFiles lists the text artifacts next to the state file and at the
+// configured report, appendix and export paths
+func artifactFiles(statePath string, extraPaths ...string) []string {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if path == "" || seen[path] || path == statePath {
+			return
+		}
+		seen[path] = true
+		files = append(files, path)
+	}
+
+	dir := filepath.Dir(statePath)
+	for _, pattern := range []string{"*.yaml", "*.md", "*.html", "*.txt", "*.csv"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, path := range matches {
+			add(path)
+		}
+	}
+	for _, path := range extraPaths {
+		add(path)
+	}
+	return files
+}
+
+// workbookFiles lists the workbooks next to the state file and at the
+// configured Excel output, annotated Excel and review sample paths, without
+// the Excel file the responses are read from
+func workbookFiles(cfg *config.Config) []string {
+	input, _ := filepath.Abs(cfg.ExcelFilePath)
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		absolute, _ := filepath.Abs(path)
+		if seen[absolute] || absolute == input {
+			return
+		}
+		seen[absolute] = true
+		files = append(files, path)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(cfg.StateFilePath), "*.xlsx"))
+	for _, path := range matches {
+		add(path)
+	}
+	for _, path := range []string{cfg.ExcelOutputPath, cfg.AnnotatedExcelPath, reviewSamplePath(cfg)} {
+		for _, variant := range runIDVariants(path) {
+			if _, err := os.Stat(variant); err == nil {
+				add(variant)
+			}
+		}
+	}
+	return files
+}
+
+// runIDVariants returns a configured output path and the existing copies
+// of it written with run_id_in_filenames
+func runIDVariants(path string) []string {
+	if path == "" {
+		return nil
+	}
+	ext := filepath.Ext(path)
+	matches, _ := filepath.Glob(path[:len(path)-len(ext)] + "-*" + ext)
+	return append([]string{path}, matches...)
+}
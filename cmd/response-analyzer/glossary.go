@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/embedding"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+)
+
+// glossaryOptions holds the flags of the glossary subcommand
+type glossaryOptions struct {
+	dir        *string
+	configPath *string
+	threshold  *float64
+	outputPath *string
+	verbose    *bool
+	noColor    *bool
+}
+
+// newGlossaryFlags defines the flags of the glossary subcommand
+func newGlossaryFlags() (*flag.FlagSet, *glossaryOptions) {
+	flags := flag.NewFlagSet("glossary", flag.ExitOnError)
+	options := &glossaryOptions{
+		dir:        flags.String("dir", "", "Directory with the configurations of the projects to compare"),
+		configPath: flags.String("config", "", "Configuration whose embedding settings are used (defaults to local embeddings)"),
+		threshold:  flags.Float64("threshold", analysis.DefaultGlossaryThreshold, "Similarity above which themes are taken for the same category"),
+		outputPath: flags.String("output-path", "", "Glossary file to write (defaults to glossary.yaml in the directory)"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		noColor:    flags.Bool("no-color", false, "Disable colored output"),
+	}
+	flags.Usage = commandUsage("glossary", flags)
+	return flags, options
+}
+
+// runGlossary runs the glossary subcommand, which compares the themes of the
+// projects in a directory, reports themes naming the same category
+// differently and suggests a harmonized glossary
+func runGlossary(args []string) {
+	flags, options := newGlossaryFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+
+	if *options.dir == "" {
+		fmt.Println("Please provide the directory of the projects using the -dir flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+	if *options.threshold <= 0 || *options.threshold > 1 {
+		fmt.Printf("Invalid -threshold %g, use a similarity above 0 and up to 1\n", *options.threshold)
+		os.Exit(1)
+	}
+	outputPath := *options.outputPath
+	if outputPath == "" {
+		outputPath = filepath.Join(*options.dir, "glossary.yaml")
+	}
+
+	embedder := embedding.Embedder(&embedding.LocalEmbedder{})
+	if *options.configPath != "" {
+		cfg := loadConfig(logger, *options.configPath, "")
+		var err error
+		embedder, err = embedding.NewEmbedder(logger, cfg.EmbeddingProvider, cfg.EmbeddingModel, cfg.EmbeddingAPIKey)
+		if err != nil {
+			logger.Error("Failed to initialize embedder", "error", err)
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	projects, err := glossaryProjects(logger, *options.dir, outputPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(projects) < 2 {
+		fmt.Printf("Found %d projects with themes in %s, at least two are needed for a comparison\n", len(projects), *options.dir)
+		os.Exit(1)
+	}
+
+	glossary, err := analysis.BuildGlossary(projects, embedder, *options.threshold)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := output.NewWriter(logger).SaveGlossary(glossary, outputPath); err != nil {
+		logger.Error("Failed to save glossary", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	printGlossary(con, glossary, outputPath)
+}
+
+// glossaryProjects reads the themes of the configurations in a directory and
+// its subdirectories. The themes of the state file are preferred over those
+// of the configuration, as they include identified themes. Every question of
+// a configuration with response_columns is a project of its own. YAML files
+// that are no configurations, such as state files, are skipped.
+func glossaryProjects(logger *logging.Logger, dir, outputPath string) ([]analysis.ProjectThemes, error) {
+	var projects []analysis.ProjectThemes
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if entry.IsDir() || ext != ".yaml" && ext != ".yml" || filepath.Clean(path) == filepath.Clean(outputPath) {
+			return nil
+		}
+		cfg, err := config.LoadConfig(path)
+		if err != nil {
+			logger.Debug("Skipping file that is no configuration", "path", path, "error", err)
+			return nil
+		}
+		if cfg.StateFilePath == "" {
+			cfg.StateFilePath = defaultStateFilePath(cfg, path)
+		}
+
+		questions := map[string]*config.Config{path: cfg}
+		if len(cfg.ResponseColumns) > 0 {
+			questions = make(map[string]*config.Config)
+			for _, column := range cfg.ResponseColumns {
+				questionCfg, err := questionConfig(cfg, column)
+				if err != nil {
+					return err
+				}
+				questions[path+"#"+strings.ToUpper(column)] = questionCfg
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(questions)) {
+			project := projectThemes(logger, name, questions[name], filepath.Dir(path))
+			if len(project.Themes) == 0 {
+				logger.Warn("Skipping project without themes", "project", name)
+				continue
+			}
+			projects = append(projects, project)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the projects in %s: %w", dir, err)
+	}
+	return projects, nil
+}
+
+// projectThemes returns the themes of a project, from its state file if it
+// has one. A relative state file path that does not exist from the working
+// directory is looked up next to the configuration.
+func projectThemes(logger *logging.Logger, name string, cfg *config.Config, configDir string) analysis.ProjectThemes {
+	project := analysis.ProjectThemes{Project: name, Themes: cfg.Themes, Descriptions: maps.Clone(cfg.ThemeDescriptions)}
+
+	statePath := cfg.StateFilePath
+	if _, err := os.Stat(statePath); err != nil && !filepath.IsAbs(statePath) {
+		statePath = filepath.Join(configDir, statePath)
+	}
+	if _, err := os.Stat(statePath); err != nil {
+		return project
+	}
+	result, err := output.NewWriter(logger).LoadState(statePath)
+	if err != nil {
+		logger.Warn("Failed to load state, using the themes of the configuration", "project", name, "error", err)
+		return project
+	}
+	if len(result.Themes) > 0 {
+		project.Themes = result.Themes
+	}
+	if project.Descriptions == nil {
+		project.Descriptions = maps.Clone(result.ThemeDescriptions)
+	} else {
+		// Descriptions in the configuration take precedence
+		for theme, description := range result.ThemeDescriptions {
+			if _, ok := project.Descriptions[theme]; !ok {
+				project.Descriptions[theme] = description
+			}
+		}
+	}
+	return project
+}
+
+// printGlossary presents the themes the projects name differently and the
+// suggested glossary
+func printGlossary(con *console.Console, glossary *analysis.Glossary, outputPath string) {
+	if len(glossary.NearDuplicates) > 0 {
+		con.Heading("Themes naming the same category differently")
+		rows := [][]string{}
+		for _, group := range glossary.NearDuplicates {
+			for i, entry := range group.Entries {
+				suggested, similarity := "", ""
+				if i == 0 {
+					suggested, similarity = group.Theme, fmt.Sprintf("%.2f", group.Similarity)
+				}
+				rows = append(rows, []string{suggested, entry.Theme, entry.Project, similarity})
+			}
+		}
+		con.Table([]console.Column{{Title: "Suggested", Max: 40}, {Title: "Theme", Max: 40}, {Title: "Project", Max: 50}, {Title: "Similarity", Right: true}}, rows)
+	} else {
+		con.Note("No themes name the same category differently.")
+	}
+
+	con.Panel("Theme glossary", []console.Item{
+		{Label: "Projects", Value: fmt.Sprintf("%d", len(glossary.Projects))},
+		{Label: "Near-duplicates", Value: fmt.Sprintf("%d categories named differently", len(glossary.NearDuplicates))},
+		{Label: "Shared", Value: fmt.Sprintf("%d categories named alike", len(glossary.Shared))},
+		{Label: "Glossary", Value: fmt.Sprintf("%d themes", len(glossary.Themes))},
+		{Label: "Output", Value: outputPath + ", usable as themes_file"},
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"path/filepath"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/helpdesk"
+	"github.com/oetiker/response-analyzer/pkg/input"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+	"github.com/oetiker/response-analyzer/pkg/transcript"
+)
+
+// importOptions holds the flags of the import subcommand
+type importOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+}
+
+// newImportFlags defines the flags of the import subcommand
+func newImportFlags() (*flag.FlagSet, *importOptions) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	options := &importOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+	}
+	flags.Usage = commandUsage("import", flags)
+	return flags, options
+}
+
+// runImport runs the import subcommand, which adds the ticket comments of the
+// configured helpdesk or the segments of the configured transcripts to the
+// Excel file, so they are analyzed like survey responses by the next run
+func runImport(args []string) {
+	flags, options := newImportFlags()
+	flags.Parse(args)
+
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetRunID(analysis.NewRunID())
+
+	if *options.configPath == "" {
+		fmt.Println("Please provide a configuration file using the -config flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+	if (cfg.HelpdeskSource == nil) == (cfg.TranscriptSource == nil) {
+		fmt.Println("The configuration needs either a helpdesk_source or a transcript_source to import from")
+		os.Exit(1)
+	}
+	if input.DetectFormat(cfg.ExcelFilePath, cfg.InputFormat) != input.FormatXLSX {
+		fmt.Printf("The import adds rows to an Excel file, %s is read as %s\n", cfg.ExcelFilePath, input.DetectFormat(cfg.ExcelFilePath, cfg.InputFormat))
+		os.Exit(1)
+	}
+	if cfg.ResponseColumn != output.ImportTextColumn {
+		logger.Warn("The import writes the texts to column F, set response_column accordingly", "response_column", cfg.ResponseColumn)
+	}
+	if cfg.TranscriptSource != nil {
+		if err := importTranscripts(logger, cfg); err != nil {
+			logger.Error("Failed to import transcripts", "error", err)
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.TimestampColumn != output.HelpdeskCreatedColumn {
+		logger.Warn("The import writes the time of the comments to column C, set timestamp_column accordingly", "timestamp_column", cfg.TimestampColumn)
+	}
+
+	source, err := helpdesk.New(logger, *cfg.HelpdeskSource)
+	if err != nil {
+		logger.Error("Invalid helpdesk source", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	comments, err := source.Fetch()
+	if err != nil {
+		logger.Error("Failed to fetch ticket comments", "helpdesk", source.Name(), "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	writer := output.NewWriter(logger)
+	added, err := writer.SaveHelpdeskComments(comments, cfg.ExcelFilePath)
+	if err != nil {
+		logger.Error("Failed to save ticket comments", "path", cfg.ExcelFilePath, "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Imported %d new of %d comments from %s into %s\n", added, len(comments), source.Name(), cfg.ExcelFilePath)
+}
+
+// importTranscripts segments the configured transcripts and adds the new
+// segments to the Excel file
+func importTranscripts(logger *logging.Logger, cfg *config.Config) error {
+	source := cfg.TranscriptSource
+	mode := source.Segmentation
+	if mode == "" {
+		mode = transcript.SegmentTurn
+	}
+	if mode != transcript.SegmentTurn && mode != transcript.SegmentQuestion {
+		return fmt.Errorf("invalid transcript segmentation: %s (valid options: turn, question)", mode)
+	}
+
+	var paths []string
+	for _, pattern := range source.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid transcript pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			logger.Warn("No transcript matches", "pattern", pattern)
+		}
+		paths = append(paths, matches...)
+	}
+
+	writer := output.NewWriter(logger)
+	total, added := 0, 0
+	for _, path := range paths {
+		turns, err := transcript.Parse(path)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		segments, err := transcript.Segments(turns, mode, source.Interviewers)
+		if err != nil {
+			return err
+		}
+		n, err := writer.SaveTranscriptSegments(path, segments, cfg.ExcelFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to save the segments of %s: %w", path, err)
+		}
+		logger.Info("Imported transcript", "path", path, "turns", len(turns), "segments", len(segments), "new", n)
+		total += len(segments)
+		added += n
+	}
+	fmt.Printf("Imported %d new of %d segments from %d transcripts into %s\n", added, total, len(paths), cfg.ExcelFilePath)
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// interruptExitCode is the exit code of a run stopped by Ctrl+C, as of a
+// shell for a process ended by SIGINT
+const interruptExitCode = 130
+
+// budgetExitCode is the exit code of a run stopped by the cost cap
+const budgetExitCode = 3
+
+// notifyInterrupt returns a context that is cancelled on Ctrl+C or SIGTERM,
+// so the run aborts the requests in flight and saves a checkpoint. A second
+// Ctrl+C ends the process at once.
+func notifyInterrupt(logger *logging.Logger) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		received := <-signals
+		signal.Stop(signals)
+		logger.Warn("Interrupted, saving the responses analyzed so far; interrupt again to quit at once", "signal", received.String())
+		cancel()
+	}()
+	return ctx
+}
+
+// printInterrupted presents the usage of a run interrupted or stopped by the
+// cost cap and where it resumes from, and returns the exit code of the run
+func printInterrupted(logger *logging.Logger, con *console.Console, summary *runSummary, statePath string) int {
+	totalCost := summary.client.GetTotalCost()
+	totalTokens := summary.client.GetTotalTokens()
+	title, exitCode := "Run interrupted", interruptExitCode
+	items := []console.Item{
+		{Label: "Tokens used", Value: fmt.Sprintf("%d", totalTokens)},
+		{Label: "Total cost", Value: fmt.Sprintf("$%.4f", totalCost)},
+	}
+	if summary.client.BudgetExceeded() {
+		title, exitCode = "Run stopped at the cost cap", budgetExitCode
+		items = append(items, console.Item{Label: "Cost cap", Value: "max_cost_usd reached, raise it to continue"})
+	}
+	logger.Info("Response analysis interrupted",
+		"total_tokens", totalTokens,
+		"total_cost", fmt.Sprintf("$%.4f", totalCost),
+		"cost_cap_reached", summary.client.BudgetExceeded())
+	con.Panel(title, append(items,
+		console.Item{Label: "Duration", Value: time.Since(summary.startedAt).Round(time.Second).String()},
+		console.Item{Label: "State", Value: statePath + ", the next run resumes from the responses analyzed so far"},
+	))
+	return exitCode
+}
@@ -1,32 +1,64 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/oetiker/response-analyzer/pkg/analysis"
 	"github.com/oetiker/response-analyzer/pkg/cache"
 	"github.com/oetiker/response-analyzer/pkg/claude"
 	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/embedding"
 	"github.com/oetiker/response-analyzer/pkg/excel"
+	"github.com/oetiker/response-analyzer/pkg/llm"
 	"github.com/oetiker/response-analyzer/pkg/logging"
 	"github.com/oetiker/response-analyzer/pkg/output"
+	"github.com/oetiker/response-analyzer/pkg/sink"
 	"github.com/oetiker/response-analyzer/pkg/validation"
 )
 
 func main() {
+	// Dispatch subcommands
+	if len(os.Args) > 1 {
+		for _, cmd := range commands() {
+			if cmd.name != "" && cmd.name == os.Args[1] {
+				cmd.run(os.Args[2:])
+				return
+			}
+		}
+	}
+
 	// Parse command line flags
-	configPath := flag.String("config", "", "Path to the configuration file")
-	verbose := flag.Bool("verbose", false, "Enable verbose logging")
-	identifyThemesOnly := flag.Bool("identify-themes-only", false, "Only identify themes without performing full analysis")
+	options := newRootFlags(flag.CommandLine)
+	flag.Usage = commandUsage("", flag.CommandLine)
 	flag.Parse()
+	configPath, verbose, identifyThemesOnly := options.configPath, options.verbose, options.identifyThemesOnly
+	assumeYes := options.assumeYes
+	noColor, showVersion := options.noColor, options.showVersion
 
-	// Initialize logger
+	if *showVersion {
+		fmt.Println(versionInfo())
+		return
+	}
+
+	// Initialize logger and terminal output
+	color := !*noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
 	logger := logging.NewLogger(*verbose)
-	logger.Info("Starting response analyzer")
+	logger.SetColor(color)
+	runID := analysis.NewRunID()
+	logger.SetRunID(runID)
+	logger.CollectWarnings()
+	logger.Info("Starting response analyzer", "run_id", runID)
 
 	// Check if config file is provided
 	if *configPath == "" {
@@ -36,51 +68,436 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Profile large runs
+	if *options.pprofAddr != "" {
+		if err := startPprof(logger, *options.pprofAddr); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *options.memoryInterval > 0 {
+		stop := logMemoryUsage(logger, *options.memoryInterval)
+		defer stop()
+	}
+
 	// Load configuration
-	cfg, err := config.LoadConfig(*configPath)
-	if err != nil {
-		logger.Error("Failed to load configuration", "error", err)
-		fmt.Printf("Error loading configuration: %v\n", err)
+	cfg := loadConfig(logger, *configPath, *options.profile)
+
+	// Keep the state and outputs of a pilot run apart from the full run
+	pilot := options.pilotSampling()
+	if err := pilot.Validate(); err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Create state file path if not specified in config
-	if cfg.StateFilePath == "" {
-		dir := filepath.Dir(*configPath)
-		base := filepath.Base(*configPath)
-		ext := filepath.Ext(base)
-		name := base[:len(base)-len(ext)]
-		cfg.StateFilePath = filepath.Join(dir, name+".state.yaml")
+	if pilot.Enabled() {
+		if err := usePilotDirectory(cfg); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		logger.Info("Pilot run on a sample of the responses", "sample", pilot.Size, "strategy", pilot.Strategy, "seed", pilot.Seed, "state_file", cfg.StateFilePath)
 	}
 
-	logger.Info("Configuration loaded", "excel_file", cfg.ExcelFilePath, "state_file", cfg.StateFilePath)
+	// Stop the run gracefully on Ctrl+C
+	ctx := notifyInterrupt(logger)
+
+	// Analyze several questions one after the other
+	if len(cfg.ResponseColumns) > 0 {
+		runQuestions(ctx, logger, con, cfg, options, pilot, runID)
+		return
+	}
 
 	// Run the main workflow
-	claudeClient, err := runWorkflow(logger, cfg, *identifyThemesOnly)
+	summary, err := runWorkflow(ctx, logger, con, cfg, *identifyThemesOnly, *assumeYes, *options.force, pilot)
+	if (errors.Is(err, context.Canceled) || errors.Is(err, claude.ErrBudgetExceeded)) && summary != nil {
+		os.Exit(printInterrupted(logger, con, summary, cfg.StateFilePath))
+	}
 	if err != nil {
 		logger.Error("Workflow failed", "error", err)
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+	summary.bundle(logger, *options.bundlePath)
+	summary.deliver(logger, cfg)
+	summary.notify(logger, cfg)
+	printRunSummary(logger, con, runID, summary)
+}
 
+// printRunSummary logs the usage of the run and presents the end-of-run
+// summary
+func printRunSummary(logger *logging.Logger, con *console.Console, runID string, summary *runSummary) {
 	// Get total cost from Claude client
-	totalCost := claudeClient.GetTotalCost()
-	totalTokens := claudeClient.GetTotalTokens()
+	totalCost := summary.client.GetTotalCost()
+	totalTokens := summary.client.GetTotalTokens()
+	savings := summary.savings
+	savings.AddCache(summary.client.CacheSavings())
 	logger.Info("Response analysis completed",
 		"total_tokens", totalTokens,
-		"total_cost", fmt.Sprintf("$%.4f", totalCost))
-	fmt.Printf("\nTotal tokens used: %d\n", totalTokens)
-	fmt.Printf("Total cost: $%.4f\n", totalCost)
+		"total_cost", fmt.Sprintf("$%.4f", totalCost),
+		"saved_tokens", savings.Tokens(),
+		"saved_cost", fmt.Sprintf("$%.4f", savings.Cost()))
+
+	// Present the end-of-run summary
+	items := []console.Item{{Label: "Run ID", Value: runID}}
+	if summary.question != "" {
+		question := "column " + summary.question
+		if summary.result != nil && summary.result.ColumnTitle != "" {
+			question += ": " + summary.result.ColumnTitle
+		}
+		items = append(items, console.Item{Label: "Question", Value: question})
+	}
+	if summary.result != nil {
+		items = append(items,
+			console.Item{Label: "Responses", Value: fmt.Sprintf("%d", len(summary.result.ResponseAnalyses))},
+			console.Item{Label: "Themes", Value: fmt.Sprintf("%d", len(summary.result.Themes))})
+		if failed := len(summary.result.FailedResponses); failed > 0 {
+			items = append(items, console.Item{Label: "Failed responses", Value: fmt.Sprintf("%d, listed under failed_responses in the state and matched again by the next run", failed)})
+		}
+	}
+	items = append(items,
+		console.Item{Label: "Tokens used", Value: fmt.Sprintf("%d", totalTokens)},
+		console.Item{Label: "Total cost", Value: fmt.Sprintf("$%.4f", totalCost)})
+	if savings.CachedRequests > 0 || savings.ReusedResponses > 0 || savings.Cost() > 0 {
+		items = append(items, console.Item{Label: "Saved (estimate)", Value: fmt.Sprintf("$%.4f, %d tokens: %d cached answers ($%.4f), previous result reused for %d responses ($%.4f)",
+			savings.Cost(), savings.Tokens(), savings.CachedRequests, savings.CacheCost, savings.ReusedResponses, savings.ReuseCost)})
+	}
+	items = append(items,
+		console.Item{Label: "Duration", Value: time.Since(summary.startedAt).Round(time.Second).String()})
+	items = append(items, summary.artifacts...)
+	con.Panel("Run summary", items)
+	printKeyUsage(con, summary.client)
+	printTelemetry(con, summary.client.Telemetry())
+	printPilotEstimate(con, summary)
 }
 
-// runWorkflow runs the main workflow
-func runWorkflow(logger *logging.Logger, cfg *config.Config, identifyThemesOnly bool) (*claude.Client, error) {
-	// Validate configuration
-	validator := validation.NewValidator(logger)
-	if err := validator.ValidateConfig(cfg); err != nil {
-		return nil, fmt.Errorf("configuration validation failed: %w", err)
+// printPilotEstimate extrapolates the cost of a pilot run to all responses
+func printPilotEstimate(con *console.Console, summary *runSummary) {
+	if summary.sampled == 0 || summary.sampled >= summary.total {
+		return
+	}
+	client := summary.client
+	estimate := analysis.EstimateFullRun(client.Telemetry(), client.Model(), client.GetTotalCost(), client.GetTotalTokens(), summary.sampled, summary.total)
+	con.Heading(fmt.Sprintf("Pilot run on %d of %d responses", estimate.Sampled, estimate.Total))
+	if estimate.Tokens == 0 && estimate.CacheHits == 0 {
+		con.Note("Nothing was sent to the API, run the pilot with -force to estimate the cost of the full run.")
+		return
+	}
+	con.Table([]console.Column{{Title: "Run"}, {Title: "Responses", Right: true}, {Title: "Tokens", Right: true}, {Title: "Cost", Right: true}}, [][]string{
+		{"Pilot", fmt.Sprintf("%d", estimate.Sampled), fmt.Sprintf("%d", estimate.Tokens), fmt.Sprintf("$%.4f", estimate.Cost)},
+		{"Full run (estimate)", fmt.Sprintf("%d", estimate.Total), fmt.Sprintf("%d", estimate.EstimatedTokens), fmt.Sprintf("$%.2f", estimate.EstimatedCost)},
+	})
+	if estimate.CacheHits > 0 {
+		con.Note(fmt.Sprintf("%d answers of the pilot came from the cache and cost nothing, so the estimate is too low; disable the cache for a reliable estimate.", estimate.CacheHits))
+	}
+}
+
+// printTelemetry prints the requests and time spent per task, to show
+// whether a run waited for the API, for rate limits or for parsing
+func printTelemetry(con *console.Console, telemetry []claude.TaskTelemetry) {
+	if len(telemetry) == 0 {
+		return
+	}
+	seconds := func(value float64) string {
+		return fmt.Sprintf("%.1fs", value)
+	}
+	rows := make([][]string, len(telemetry))
+	for i, task := range telemetry {
+		rows[i] = []string{
+			task.Task,
+			fmt.Sprintf("%d", task.Requests),
+			fmt.Sprintf("%d", task.CacheHits),
+			fmt.Sprintf("%d", task.Retries),
+			fmt.Sprintf("%d", task.InputTokens+task.OutputTokens),
+			seconds(task.APISeconds),
+			seconds(task.WaitSeconds),
+			fmt.Sprintf("%.3fs", task.ParseSeconds),
+			seconds(task.WallSeconds),
+		}
+	}
+	con.Heading("Time per stage")
+	con.Table([]console.Column{
+		{Title: "Stage"},
+		{Title: "Requests", Right: true},
+		{Title: "Cached", Right: true},
+		{Title: "Retries", Right: true},
+		{Title: "Tokens", Right: true},
+		{Title: "API", Right: true},
+		{Title: "Rate limit", Right: true},
+		{Title: "Parsing", Right: true},
+		{Title: "Elapsed", Right: true},
+	}, rows)
+	con.Note("API and rate limit times are summed over parallel requests and can exceed the elapsed time.")
+}
+
+// printKeyUsage prints the usage per API key if the requests were spread
+// over several keys
+func printKeyUsage(con *console.Console, client *claude.Client) {
+	usage := client.KeyUsage()
+	if len(usage) < 2 {
+		return
+	}
+	rows := make([][]string, len(usage))
+	for i, key := range usage {
+		rows[i] = []string{key.Name, fmt.Sprintf("%d", key.Requests), fmt.Sprintf("%d", key.Tokens), fmt.Sprintf("$%.4f", key.Cost)}
+	}
+	con.Heading("Usage per API key")
+	con.Table([]console.Column{{Title: "Key"}, {Title: "Requests", Right: true}, {Title: "Tokens", Right: true}, {Title: "Cost", Right: true}}, rows)
+}
+
+// rootOptions holds the flags of the analysis run
+type rootOptions struct {
+	configPath         *string
+	profile            *string
+	verbose            *bool
+	identifyThemesOnly *bool
+	assumeYes          *bool
+	noColor            *bool
+	showVersion        *bool
+	bundlePath         *string
+	force              *bool
+	sample             *int
+	sampleStrategy     *string
+	sampleSeed         *int64
+	pprofAddr          *string
+	memoryInterval     *time.Duration
+}
+
+// newRootFlags defines the flags of the analysis run on the given flag set
+func newRootFlags(flags *flag.FlagSet) *rootOptions {
+	return &rootOptions{
+		configPath:         flags.String("config", "", "Path to the configuration file"),
+		profile:            flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:            flags.Bool("verbose", false, "Enable verbose logging"),
+		identifyThemesOnly: flags.Bool("identify-themes-only", false, "Only identify themes without performing full analysis"),
+		assumeYes:          flags.Bool("yes", false, "Do not ask for confirmation before large runs"),
+		noColor:            flags.Bool("no-color", false, "Disable colored output"),
+		showVersion:        flags.Bool("version", false, "Show version and build information"),
+		bundlePath:         flags.String("bundle", "", "Package the files of the run with a manifest into this zip archive"),
+		force:              flags.Bool("force", false, "Analyze again even if nothing changed since the previous run"),
+		sample:             flags.Int("sample", 0, "Pilot run analyzing only this many responses, with the state and outputs in a pilot directory"),
+		sampleStrategy:     flags.String("sample-strategy", analysis.SamplingRandom, "How the responses of a pilot run are sampled: random or stratified by rating"),
+		sampleSeed:         flags.Int64("sample-seed", 1, "Seed of the sample of a pilot run"),
+		pprofAddr:          flags.String("pprof", "", "Serve runtime profiles with net/http/pprof at this address during the run, e.g. :6060"),
+		memoryInterval:     flags.Duration("memory-interval", 0, "Log the memory usage at this interval during the run, e.g. 30s"),
+	}
+}
+
+// pilotSampling returns the sampling of a pilot run selected by the flags
+func (o *rootOptions) pilotSampling() analysis.PilotSampling {
+	return analysis.PilotSampling{Size: *o.sample, Strategy: *o.sampleStrategy, Seed: *o.sampleSeed}
+}
+
+// loadConfig loads the configuration file with the given profile, or the
+// one selected in the file, and derives the state file path if it is not
+// configured. It exits the program on failure.
+func loadConfig(logger *logging.Logger, configPath, profile string) *config.Config {
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		fmt.Printf("Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Create state file path if not specified in config
+	if cfg.StateFilePath == "" {
+		cfg.StateFilePath = defaultStateFilePath(cfg, configPath)
+	}
+
+	logger.Info("Configuration loaded", "excel_file", cfg.ExcelFilePath, "state_file", cfg.StateFilePath, "profile", cfg.Profile)
+	if cfg.ThemeLibrary != "" {
+		logger.Info("Themes extend the theme library", "library", cfg.ThemeLibrary, "version", cfg.ThemeLibraryVersion, "themes", len(cfg.Themes))
+	}
+	return cfg
+}
+
+// defaultStateFilePath returns the path of the state file of a configuration
+// that does not set one, next to the configuration file
+func defaultStateFilePath(cfg *config.Config, configPath string) string {
+	dir := filepath.Dir(configPath)
+	base := filepath.Base(configPath)
+	ext := filepath.Ext(base)
+	name := base[:len(base)-len(ext)]
+	if cfg.Profile != "" {
+		name += "." + cfg.Profile // Keep a draft run from reusing the matches of a final run
+	}
+	return filepath.Join(dir, name+".state.yaml")
+}
+
+// usePilotDirectory moves the state file and the configured outputs of a
+// pilot run into a pilot directory next to the state file, so the pilot
+// neither overwrites nor reuses the results of the full run
+func usePilotDirectory(cfg *config.Config) error {
+	return moveOutputs(cfg, filepath.Join(filepath.Dir(cfg.StateFilePath), "pilot"))
+}
+
+// moveOutputs moves the state file and the configured outputs into a
+// directory, keeping their file names
+func moveOutputs(cfg *config.Config, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	move := func(path *string) {
+		if *path != "" {
+			*path = filepath.Join(dir, filepath.Base(*path))
+		}
+	}
+	for _, path := range []*string{&cfg.StateFilePath, &cfg.ReportOutputPath, &cfg.AppendixOutputPath, &cfg.HTMLReportPath, &cfg.WideExportPath, &cfg.ExcelOutputPath, &cfg.AnnotatedExcelPath, &cfg.ReviewSamplePath} {
+		move(path)
+	}
+	for i := range cfg.SummaryAudiences {
+		move(&cfg.SummaryAudiences[i].OutputPath)
+	}
+	for i := range cfg.ReportOutputs {
+		move(&cfg.ReportOutputs[i].Path)
+	}
+	return nil
+}
+
+// themesFilePath returns where identified themes are saved
+func themesFilePath(cfg *config.Config) string {
+	if cfg.ThemesFile != "" {
+		return cfg.ThemesFile
+	}
+	return filepath.Join(filepath.Dir(cfg.StateFilePath), "themes.yaml")
+}
+
+// pauseFilePath returns the file whose presence pauses a run
+func pauseFilePath(cfg *config.Config) string {
+	if cfg.PauseFile != "" {
+		return cfg.PauseFile
+	}
+	return filepath.Join(filepath.Dir(cfg.StateFilePath), "pause")
+}
+
+// queueDirPath returns the directory of the offline request queue
+func queueDirPath(cfg *config.Config) string {
+	if cfg.QueueDir != "" {
+		return cfg.QueueDir
+	}
+	return filepath.Join(filepath.Dir(cfg.StateFilePath), "queue")
+}
+
+// confirmPlan prints the plan of a run exceeding the confirmation thresholds
+// and asks whether to proceed
+func confirmPlan(con *console.Console, cfg *config.Config, plan analysis.RunPlan, assumeYes bool) error {
+	tooMany := cfg.ConfirmAboveResponses >= 0 && plan.NewResponses > cfg.ConfirmAboveResponses
+	tooExpensive := cfg.ConfirmAboveCost >= 0 && plan.EstimatedCost > cfg.ConfirmAboveCost
+	if !tooMany && !tooExpensive {
+		return nil
+	}
+
+	con.Heading("Run plan")
+	con.Table([]console.Column{{Title: "Item"}, {Title: "Estimate", Right: true}}, [][]string{
+		{"Responses", fmt.Sprintf("%d", plan.Responses)},
+		{"New or changed responses", fmt.Sprintf("%d", plan.NewResponses)},
+		{"Matching batches", fmt.Sprintf("%d", plan.Batches)},
+		{"API calls", fmt.Sprintf("%d", plan.Calls)},
+		{"Input tokens", fmt.Sprintf("%d", plan.InputTokens)},
+		{"Output tokens", fmt.Sprintf("%d", plan.OutputTokens)},
+		{"Estimated cost", fmt.Sprintf("$%.2f", plan.EstimatedCost)},
+	})
+	if assumeYes {
+		return nil
+	}
+
+	if !console.IsTerminal(os.Stdin) {
+		return fmt.Errorf("run exceeds the confirmation thresholds, pass -yes to run it non-interactively")
+	}
+	ok, err := con.Confirm(os.Stdin, "Proceed with this run?")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("run cancelled")
 	}
+	return nil
+}
+
+// runSummary collects what a run produced for the end-of-run summary
+type runSummary struct {
+	client    *claude.Client
+	result    *analysis.AnalysisResult
+	startedAt time.Time
+	artifacts []console.Item       // Files written by the run
+	files     []sink.Artifact      // Paths of the files for the output sinks
+	sampled   int                  // Responses analyzed by a pilot run
+	savings   analysis.CostSavings // Saved by reusing the previous result
+	total     int                  // Responses a pilot run was sampled from
+	question  string               // Column of the question, with response_columns
+}
+
+// addArtifact records a file written by the run
+func (s *runSummary) addArtifact(label, path string) {
+	s.addArtifactNote(label, path, "")
+}
+
+// addArtifactNote records a file written by the run with a note shown next
+// to its path
+func (s *runSummary) addArtifactNote(label, path, note string) {
+	value := path
+	if note != "" {
+		value = fmt.Sprintf("%s (%s)", path, note)
+	}
+	s.artifacts = append(s.artifacts, console.Item{Label: label, Value: value})
+	s.files = append(s.files, sink.Artifact{Label: label, Path: path})
+}
+
+// bundle packages the files written by the run into a zip archive, which is
+// then recorded as a file of the run itself so output sinks deliver it
+func (s *runSummary) bundle(logger *logging.Logger, path string) {
+	if path == "" {
+		return
+	}
+	files := make([]output.BundleFile, len(s.files))
+	for i, file := range s.files {
+		files[i] = output.BundleFile{Label: file.Label, Path: file.Path}
+	}
+	run := analysis.RunMetadata{RunID: logger.RunID()}
+	if s.result != nil {
+		run = s.result.Run
+	}
+	count, err := output.NewWriter(logger).WriteBundle(path, files, run, "response-analyzer "+currentVersion())
+	if err != nil {
+		logger.Warn("Failed to write results bundle", "error", err)
+		return
+	}
+	s.addArtifactNote("Bundle", path, fmt.Sprintf("%d files", count))
+}
+
+// deliver uploads the files written by the run to the configured output
+// sinks and records the number of delivered files
+func (s *runSummary) deliver(logger *logging.Logger, cfg *config.Config) {
+	if len(cfg.OutputSinks) == 0 || len(s.files) == 0 {
+		return
+	}
+	delivered := sink.Deliver(logger, cfg.OutputSinks, s.files)
+	s.artifacts = append(s.artifacts, console.Item{Label: "Delivered", Value: fmt.Sprintf("%d files to %d output sinks", delivered, len(cfg.OutputSinks))})
+}
+
+// notify posts the result of the run to the configured webhook. A failed
+// delivery is logged as a warning, as the result is kept in the state file.
+func (s *runSummary) notify(logger *logging.Logger, cfg *config.Config) {
+	if cfg.Webhook == nil || s.result == nil {
+		return
+	}
+	writer := output.NewWriter(logger)
+	writer.SetOmitResponseText(cfg.OmitResponseText)
+	usage := output.WebhookUsage{Tokens: s.client.GetTotalTokens(), Cost: s.client.GetTotalCost()}
+	payload, err := writer.WebhookPayload(s.result, cfg.Webhook.Payload == "full", usage)
+	if err != nil {
+		logger.Warn("Failed to create webhook payload", "error", err)
+		return
+	}
+	if err := sink.PostWebhook(logger, *cfg.Webhook, s.result.Run.RunID, payload); err != nil {
+		logger.Warn("Failed to post result to webhook", "error", err)
+		s.artifacts = append(s.artifacts, console.Item{Label: "Webhook", Value: "failed, see the log"})
+		return
+	}
+	logger.Info("Posted result to webhook", "payload", cfg.Webhook.Payload, "bytes", len(payload))
+	s.artifacts = append(s.artifacts, console.Item{Label: "Webhook", Value: "posted " + cfg.Webhook.Payload + " payload"})
+}
 
+// newClaudeClient creates the Claude API client with its cache as configured
+func newClaudeClient(logger *logging.Logger, cfg *config.Config) (*claude.Client, error) {
 	// Initialize cache
 	cacheDir := cfg.CacheDir
 	if cacheDir == "" {
@@ -92,7 +509,45 @@ func runWorkflow(logger *logging.Logger, cfg *config.Config, identifyThemesOnly
 	}
 
 	// Initialize Claude API client
-	claudeClient := claude.NewClient(cfg.ClaudeAPIKey, logger, cacheInstance, cfg.OutputLanguage, cfg.ClaudeModel)
+	keys := cfg.APIKeys()
+	claudeClient := claude.NewClient(keys[0].Key, logger, cacheInstance, cfg.OutputLanguage, cfg.ClaudeModel)
+	if cfg.LLMProvider == llm.ProviderOpenAI {
+		claudeClient.SetProvider(llm.NewOpenAI(cfg.LLMBaseURL))
+		logger.Info("Sending requests to OpenAI", "model", claudeClient.Model(), "base_url", cfg.LLMBaseURL)
+	}
+	if len(keys) > 1 {
+		claudeClient.SetAPIKeys(keys, cfg.APIKeyRotation)
+		logger.Info("Spreading requests over API keys", "keys", len(keys), "rotation", cfg.APIKeyRotation)
+	}
+	claudeClient.SetRunID(logger.RunID())
+	if cfg.UsageTag != "" {
+		claudeClient.SetUsageTag(cfg.UsageTag)
+		logger.Info("Usage tag set", "usage_tag", cfg.UsageTag)
+	}
+	if cfg.MaxCostUSD > 0 {
+		claudeClient.SetMaxCost(cfg.MaxCostUSD)
+		logger.Info("Cost cap set", "max_cost", fmt.Sprintf("$%.2f", cfg.MaxCostUSD))
+	}
+
+	claudeClient.SetProposeNewThemes(cfg.ProposeNewThemes)
+	claudeClient.SetMatchReasons(cfg.MatchReasons)
+	claudeClient.SetStrictThemes(cfg.StrictThemes)
+	claudeClient.SetStructuredOutput(cfg.StructuredOutput)
+	if cfg.PromptInjectionGuard != nil {
+		claudeClient.SetPromptInjectionGuard(*cfg.PromptInjectionGuard)
+	}
+	claudeClient.SetConsensusRuns(cfg.ConsensusRuns)
+	if cfg.MatchingTemperature != nil {
+		claudeClient.SetMatchingTemperature(*cfg.MatchingTemperature)
+	}
+	if cfg.ConsensusRuns > 1 {
+		logger.Info("Using consensus voting for theme matching", "runs", cfg.ConsensusRuns)
+	}
+
+	if cfg.ThinkingBudgetTokens > 0 {
+		claudeClient.SetThinking(cfg.ThinkingBudgetTokens, cfg.ThinkingStages)
+		logger.Info("Extended thinking enabled", "budget_tokens", cfg.ThinkingBudgetTokens, "stages", strings.Join(cfg.ThinkingStages, ","))
+	}
 
 	// Set rate limit delay if configured
 	if cfg.RateLimitDelay > 0 {
@@ -100,16 +555,101 @@ func runWorkflow(logger *logging.Logger, cfg *config.Config, identifyThemesOnly
 		logger.Info("Rate limit delay set", "delay_ms", cfg.RateLimitDelay)
 	}
 
+	// Answer offline, after the settings above so no delay applies
+	if cfg.MockProvider {
+		claudeClient.UseMockProvider()
+		logger.Warn("Answering requests with the offline mock provider, the results are synthetic")
+	}
+
+	return claudeClient, nil
+}
+
+// runWorkflow runs the main workflow. Once the context is cancelled, the
+// requests in flight are aborted and the responses analyzed so far saved to
+// the state file; the summary is returned along with the error, for the cost
+// of the interrupted run.
+func runWorkflow(ctx context.Context, logger *logging.Logger, con *console.Console, cfg *config.Config, identifyThemesOnly, assumeYes, force bool, pilot analysis.PilotSampling) (*runSummary, error) {
+	summary := &runSummary{startedAt: time.Now()}
+	startedAt := summary.startedAt
+
+	// Validate configuration
+	validator := validation.NewValidator(logger)
+	if err := validator.ValidateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	// Initialize Claude API client
+	claudeClient, err := newClaudeClient(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Stop the run like on Ctrl+C once the cost cap is reached
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	claudeClient.SetContext(ctx)
+	claudeClient.SetOnBudgetExceeded(stop)
+	summary.client = claudeClient
+
+	// Answer requests from the offline queue if one was drained, matching
+	// with the batch API collects its requests in the queue
+	if _, err := os.Stat(queueDirPath(cfg)); err == nil || cfg.BatchAPI {
+		queue, err := claude.OpenQueue(logger, queueDirPath(cfg))
+		if err != nil {
+			return nil, err
+		}
+		if cfg.BatchAPI {
+			claudeClient.SetBatchAPI(queue, time.Duration(cfg.BatchPollInterval)*time.Second)
+		} else {
+			claudeClient.SetQueue(queue, false)
+		}
+		total, answered := queue.Counts()
+		logger.Info("Using answers of the request queue", "answered", answered, "queued", total)
+		if answered < total && !cfg.BatchAPI {
+			logger.Warn("The request queue has unanswered requests, they are sent now", "pending", total-answered)
+		}
+	}
+
+	// Check the configured models before spending anything on them
+	for _, model := range configuredModels(cfg, claudeClient.Model()) {
+		if err := checkModel(claudeClient, model); errors.Is(err, claude.ErrUnknownModel) {
+			return nil, err
+		} else if err != nil {
+			logger.Warn("Failed to check model", "model", model, "error", err)
+		}
+	}
+
 	// Initialize Excel reader
-	excelReader := excel.NewExcelReader(logger)
+	excelReader, err := newExcelReader(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize analyzer
 	analyzer := analysis.NewAnalyzer(logger, claudeClient)
+	analyzer.SetContext(ctx)
+
+	// Initialize embedder if embeddings are enabled
+	if cfg.EmbeddingsEnabled {
+		embedder, err := embedding.NewEmbedder(logger, cfg.EmbeddingProvider, cfg.EmbeddingModel, cfg.EmbeddingAPIKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize embedder: %w", err)
+		}
+		analyzer.SetEmbedder(embedder)
+		logger.Info("Embeddings enabled", "provider", cfg.EmbeddingProvider, "model", embedder.Model())
+	}
 
 	// Log performance optimization settings
+	if limits := cfg.RateLimits(); limits != (claude.RateLimits{}) {
+		logger.Info("Throughput settings derived from rate limits unless set explicitly",
+			"requests_per_minute", limits.RequestsPerMinute,
+			"input_tokens_per_minute", limits.InputTokensPerMinute,
+			"output_tokens_per_minute", limits.OutputTokensPerMinute,
+			"rate_limit_delay_ms", cfg.RateLimitDelay)
+	}
 	if cfg.UseParallel {
 		logger.Info("Using parallel processing",
 			"workers", cfg.ParallelWorkers,
+			"auto", cfg.AutoParallelWorkers,
 			"batch_size", cfg.BatchSize)
 	} else {
 		logger.Info("Using batch processing",
@@ -119,17 +659,6 @@ func runWorkflow(logger *logging.Logger, cfg *config.Config, identifyThemesOnly
 	// Initialize output writer
 	writer := output.NewWriter(logger)
 
-	// Read responses from Excel file
-	excelData, err := excelReader.ReadResponses(cfg.ExcelFilePath, cfg.ResponseColumn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read responses: %w", err)
-	}
-
-	responses := excelData.Responses
-	columnTitle := excelData.ColumnTitle
-
-	logger.Info("Read responses from Excel file", "count", len(responses), "column_title", columnTitle)
-
 	// Check if state file exists
 	var previousResult *analysis.AnalysisResult
 	stateExists, err := validator.ValidateStateFile(cfg.StateFilePath)
@@ -147,6 +676,84 @@ func runWorkflow(logger *logging.Logger, cfg *config.Config, identifyThemesOnly
 		}
 	}
 
+	// In append mode only the rows after those analyzed before are read
+	appending := cfg.AppendMode && previousResult != nil
+	if appending {
+		excelReader.SetFirstRow(analysis.AppendStartRow(previousResult))
+	}
+
+	// Read responses from Excel file
+	excelData, err := excelReader.ReadResponses(cfg.ExcelFilePath, cfg.ResponseColumn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read responses: %w", err)
+	}
+
+	responses := excelData.Responses
+	columnTitle := surveyQuestion(cfg, excelData)
+	claudeClient.SetSurveyQuestion(columnTitle)
+
+	// Replace response IDs and hashes by pseudonyms
+	if cfg.Pseudonymize {
+		responses = analysis.PseudonymizeResponses(responses, cfg.PseudonymizationSalt)
+		logger.Info("Pseudonymized response IDs")
+	}
+	writer.SetOmitResponseText(cfg.OmitResponseText)
+	writer.SetStateEncoding(cfg.StateEncoding)
+
+	logger.Info("Read responses from Excel file", "count", len(responses), "column_title", columnTitle)
+
+	// Merge the appended rows into the responses analyzed before
+	if appending {
+		read := len(responses)
+		var added int
+		responses, added = analysis.AppendResponses(previousResult, responses)
+		logger.Info("Appending new rows to the analyzed responses",
+			"from_row", analysis.AppendStartRow(previousResult),
+			"read", read,
+			"new", added,
+			"total", len(responses))
+	}
+
+	// Analyze only a sample of the responses in a pilot run
+	if pilot.Enabled() {
+		summary.total = len(responses)
+		responses = pilot.Sample(responses, cfg.RatingBands)
+		summary.sampled = len(responses)
+		logger.Info("Sampled responses for the pilot run", "sampled", summary.sampled, "responses", summary.total, "strategy", pilot.Strategy)
+	}
+
+	// Re-render the outputs if nothing that determines the result changed
+	// since the previous run, and otherwise tell what changed
+	fingerprint, err := analysis.NewFingerprint(cfg, responses, columnTitle)
+	if err != nil {
+		return nil, err
+	}
+	if previousResult != nil && !identifyThemesOnly && len(previousResult.Themes) > 0 {
+		if changes := recomputeReasons(fingerprint, previousResult); len(changes) == 0 && !force {
+			summary.savings = analyzer.ReuseSavings(responses, cfg, previousResult, true)
+			return rerenderUnchanged(logger, con, cfg, writer, previousResult, summary)
+		} else if len(changes) > 0 {
+			added, changed, removed := analysis.ResponseChanges(responses, previousResult)
+			logger.Info("Recomputing, the inputs changed since the previous run",
+				"previous_run", previousResult.Run.RunID,
+				"changed", strings.Join(changes, ","),
+				"new_responses", added,
+				"changed_responses", changed,
+				"removed_responses", removed)
+			con.Note(fmt.Sprintf("Recomputing, changed since the previous run: %s (%d new, %d changed, %d removed responses)",
+				strings.Join(changes, ", "), added, changed, removed))
+		}
+	}
+
+	// Ask before runs that are larger or more expensive than expected
+	plan := analyzer.PlanRun(responses, cfg, previousResult, identifyThemesOnly)
+	if err := confirmPlan(con, cfg, plan, assumeYes); err != nil {
+		return nil, err
+	}
+	if !identifyThemesOnly {
+		summary.savings = analyzer.ReuseSavings(responses, cfg, previousResult, false)
+	}
+
 	// Check if we're in identify-themes-only mode or if no themes are provided
 	if identifyThemesOnly || (len(cfg.Themes) == 0 && (previousResult == nil || len(previousResult.Themes) == 0)) {
 		// Only identify themes without performing full analysis
@@ -158,36 +765,64 @@ func runWorkflow(logger *logging.Logger, cfg *config.Config, identifyThemesOnly
 			return nil, fmt.Errorf("failed to identify themes: %w", err)
 		}
 
+		// Describe the themes if requested
+		var descriptions map[string]claude.ThemeDescription
+		if cfg.GenerateThemeDescriptions {
+			descriptions, err = analyzer.GenerateThemeDescriptions(responses, themes, cfg.ContextPrompt, cfg.ThemeDescriptions)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		// Output identified themes
-		fmt.Println("\nIdentified themes:")
+		con.Heading("Identified themes")
+		rows := make([][]string, len(themes))
 		for i, theme := range themes {
-			fmt.Printf("%d. %s\n", i+1, theme)
+			rows[i] = []string{fmt.Sprintf("%d", i+1), theme, descriptions[theme].Description}
 		}
+		con.Table([]console.Column{{Title: "#", Right: true}, {Title: "Theme"}, {Title: "Description", Max: 70}}, rows)
 
 		// Save themes to a file
-		themesPath := filepath.Join(filepath.Dir(cfg.StateFilePath), "themes.yaml")
-		if err := writer.SaveThemes(themes, themesPath); err != nil {
+		themesPath := themesFilePath(cfg)
+		if err := writer.SaveThemes(themes, descriptions, themesPath); err != nil {
 			logger.Warn("Failed to save themes", "error", err)
 		} else {
 			logger.Info("Saved themes to file", "path", themesPath)
-			fmt.Printf("\nThemes saved to: %s\n", themesPath)
+			summary.addArtifact("Themes", themesPath)
 		}
 
-		fmt.Println("\n==========================================================")
-		fmt.Println("THEMES IDENTIFICATION COMPLETED")
-		fmt.Println("==========================================================")
-		fmt.Println("To perform the full analysis:")
-		fmt.Println("1. Add these themes to your config file under the 'themes:' section")
-		fmt.Println("2. Run the program again without the -identify-themes-only flag")
-		fmt.Println("==========================================================")
+		con.Heading("Theme identification completed")
+		con.Println("To perform the full analysis:")
+		if cfg.ThemesFile != "" {
+			con.Println("1. Review the themes in " + cfg.ThemesFile)
+		} else {
+			con.Println("1. Add these themes to your config file under the 'themes:' section, or reference the file with 'themes_file:'")
+		}
+		con.Println("2. Run the program again without the -identify-themes-only flag")
 
-		return claudeClient, nil
+		return summary, nil
 	}
 
 	// Update analyzer to use configuration settings
 	analyzer.SetBatchSize(cfg.BatchSize)
 	analyzer.SetParallelWorkers(cfg.ParallelWorkers)
 	analyzer.SetUseParallel(cfg.UseParallel)
+	analyzer.SetAutoParallel(cfg.AutoParallelWorkers)
+	analyzer.SetMaxFailedResponsesPct(cfg.MaxFailedResponsesPct)
+
+	// Save the responses analyzed so far to the state file in the background
+	// after completed batches and when pausing on request, so an interrupted
+	// run resumes from them
+	snapshots := writer.NewStateSnapshots(cfg.StateFilePath)
+	defer snapshots.Flush()
+	pause := analysis.NewPauseControl(logger, pauseFilePath(cfg))
+	notifyPause(pause)
+	analyzer.SetCheckpointBatches(cfg.CheckpointBatches)
+	analyzer.SetPauseControl(pause, func(checkpoint *analysis.AnalysisResult) {
+		checkpoint.Run = analysis.RunMetadata{RunID: logger.RunID(), StartedAt: startedAt, Model: claudeClient.Model(), UsageTag: cfg.UsageTag, Profile: cfg.Profile, ThemeLibrary: cfg.ThemeLibraryRef(), PromptVersions: claudeClient.PromptVersions()}
+		checkpoint.Warnings, checkpoint.DroppedWarnings = logger.Warnings()
+		snapshots.Save(checkpoint)
+	})
 
 	// Perform full analysis
 	var result *analysis.AnalysisResult
@@ -226,60 +861,455 @@ func runWorkflow(logger *logging.Logger, cfg *config.Config, identifyThemesOnly
 			columnTitle,
 		)
 
-		// Output identified themes
-		fmt.Println("\nIdentified themes:")
-		for i, theme := range result.Themes {
-			fmt.Printf("%d. %s\n", i+1, theme)
-		}
-		fmt.Println("\nAdd these themes to your config file to use them in subsequent runs.")
-
 		// Save themes to a file
-		themesPath := filepath.Join(filepath.Dir(cfg.StateFilePath), "themes.yaml")
-		if err := writer.SaveThemes(result.Themes, themesPath); err != nil {
-			logger.Warn("Failed to save themes", "error", err)
-		} else {
-			logger.Info("Saved themes to file", "path", themesPath)
-			fmt.Printf("\nThemes saved to: %s\n", themesPath)
+		if err == nil {
+			if cfg.ThemesFile == "" {
+				con.Note("Add the identified themes to your config file or reference the themes file with 'themes_file:' to use them in subsequent runs.")
+			}
+			themesPath := themesFilePath(cfg)
+			if err := writer.SaveThemes(result.Themes, result.ThemeDescriptions, themesPath); err != nil {
+				logger.Warn("Failed to save themes", "error", err)
+			} else {
+				logger.Info("Saved themes to file", "path", themesPath)
+				summary.addArtifact("Themes", themesPath)
+			}
 		}
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to analyze responses: %w", err)
+		return summary, fmt.Errorf("failed to analyze responses: %w", err)
+	}
+
+	// Summarize in the languages of the additional reports
+	if err := summarizeReportLanguages(logger, cfg, analyzer, claudeClient, result); err != nil {
+		return summary, err
+	}
+
+	// Record run metadata
+	result.Run.RunID = logger.RunID()
+	result.Run.StartedAt = startedAt
+	result.Run.FinishedAt = time.Now()
+	result.Run.Model = cfg.ClaudeModel
+	if result.Run.Model == "" {
+		result.Run.Model = claude.DefaultModel
+	}
+	result.Run.UsageTag = cfg.UsageTag
+	result.Run.Profile = cfg.Profile
+	result.Run.ThemeLibrary = cfg.ThemeLibraryRef()
+	result.Run.PromptVersions = claudeClient.PromptVersions()
+	result.Run.Telemetry = claudeClient.Telemetry()
+	result.Run.Tokens = claudeClient.GetTotalTokens()
+	result.Run.Cost = claudeClient.GetTotalCost()
+	savings := summary.savings
+	savings.AddCache(claudeClient.CacheSavings())
+	if savings != (analysis.CostSavings{}) {
+		result.Run.Savings = &savings
 	}
+	result.Run.Fingerprint = fingerprint
+	result.Warnings, result.DroppedWarnings = logger.Warnings()
 
-	// Save state
+	// Save state, after the checkpoints still being written
+	if err := snapshots.Flush(); err != nil {
+		logger.Error("Failed to save checkpoint", "error", err)
+	}
 	if err := writer.SaveState(result, cfg.StateFilePath); err != nil {
 		return nil, fmt.Errorf("failed to save state: %w", err)
 	}
+	summary.result = result
+	summary.addArtifact("State", cfg.StateFilePath)
 
 	// Save audit log
-	auditPath := filepath.Join(filepath.Dir(cfg.StateFilePath), "audit.yaml")
+	auditPath := artifactPath(cfg, logger.RunID(), "audit.yaml")
 	if err := writer.SaveAuditLog(result, auditPath); err != nil {
 		logger.Warn("Failed to save audit log", "error", err)
 	} else {
 		logger.Info("Saved audit log", "path", auditPath)
-		fmt.Printf("\nAudit log saved to: %s\n", auditPath)
+		summary.addArtifact("Audit log", auditPath)
 	}
 
 	// Save theme statistics
-	statsPath := filepath.Join(filepath.Dir(cfg.StateFilePath), "theme_stats.yaml")
+	statsPath := artifactPath(cfg, logger.RunID(), "theme_stats.yaml")
 	if err := writer.SaveThemeStats(result, statsPath); err != nil {
 		logger.Warn("Failed to save theme statistics", "error", err)
 	} else {
 		logger.Info("Saved theme statistics", "path", statsPath)
-		fmt.Printf("Theme statistics saved to: %s\n", statsPath)
+		summary.addArtifact("Theme statistics", statsPath)
+	}
+
+	// Save the languages and lengths of the responses
+	if result.Corpus != nil {
+		corpusPath := artifactPath(cfg, logger.RunID(), "corpus_stats.yaml")
+		if err := writer.SaveCorpusStats(result.Corpus, corpusPath); err != nil {
+			logger.Warn("Failed to save corpus statistics", "error", err)
+		} else {
+			logger.Info("Saved corpus statistics", "path", corpusPath)
+			summary.addArtifact("Corpus statistics", corpusPath)
+		}
+	}
+
+	// Save the theme frequencies per period
+	if result.TimeSeries != nil {
+		seriesPath := artifactPath(cfg, logger.RunID(), "theme_time_series.yaml")
+		if err := writer.SaveTimeSeries(result.TimeSeries, seriesPath); err != nil {
+			logger.Warn("Failed to save theme time series", "error", err)
+		} else {
+			logger.Info("Saved theme time series", "path", seriesPath)
+			summary.addArtifact("Theme time series", seriesPath)
+		}
+	}
+
+	// Save the theme frequencies of the rolling windows
+	if len(result.RollingWindows) > 0 {
+		windowsPath := artifactPath(cfg, logger.RunID(), "rolling_windows.yaml")
+		if err := writer.SaveRollingWindows(result.RollingWindows, windowsPath); err != nil {
+			logger.Warn("Failed to save rolling windows", "error", err)
+		} else {
+			logger.Info("Saved rolling windows", "path", windowsPath)
+			summary.addArtifact("Rolling windows", windowsPath)
+		}
+	}
+
+	// Save the ratings per theme
+	if result.Ratings != nil {
+		ratingsPath := artifactPath(cfg, logger.RunID(), "theme_ratings.yaml")
+		if err := writer.SaveRatingStats(result.Ratings, ratingsPath); err != nil {
+			logger.Warn("Failed to save rating statistics", "error", err)
+		} else {
+			logger.Info("Saved rating statistics", "path", ratingsPath)
+			summary.addArtifact("Rating statistics", ratingsPath)
+		}
 	}
 
 	// Save summary if available
 	if result.Summary != "" {
-		summaryPath := filepath.Join(filepath.Dir(cfg.StateFilePath), "summary.txt")
+		summaryPath := artifactPath(cfg, logger.RunID(), "summary.txt")
 		if err := writer.SaveSummary(result.Summary, summaryPath); err != nil {
 			logger.Warn("Failed to save summary", "error", err)
 		} else {
 			logger.Info("Saved summary", "path", summaryPath)
-			fmt.Printf("Summary saved to: %s\n", summaryPath)
+			summary.addArtifact("Summary", summaryPath)
+		}
+	}
+
+	// Save verification report if summaries were verified
+	if result.Verification != nil {
+		verificationPath := artifactPath(cfg, logger.RunID(), "verification.yaml")
+		if err := writer.SaveVerificationReport(result.Verification, verificationPath); err != nil {
+			logger.Warn("Failed to save verification report", "error", err)
+		} else {
+			logger.Info("Saved verification report", "path", verificationPath)
+			summary.addArtifactNote("Verification", verificationPath, fmt.Sprintf("%d unsupported claims", result.Verification.Unsupported))
+		}
+	}
+
+	// Present the themes by number of responses
+	con.Heading("Themes")
+	con.Table([]console.Column{{Title: "Theme"}, {Title: "Responses", Right: true}, {Title: "Share", Right: true}}, themeRows(result))
+
+	// Present themes proposed for unmatched responses
+	if len(result.ProposedThemes) > 0 {
+		con.Heading("Proposed new themes for responses matching none of the themes")
+		rows := make([][]string, len(result.ProposedThemes))
+		for i, proposal := range result.ProposedThemes {
+			rows[i] = []string{proposal.Theme, fmt.Sprintf("%d", proposal.Count)}
+		}
+		con.Table([]console.Column{{Title: "Proposed theme"}, {Title: "Responses", Right: true}}, rows)
+		proposedPath := artifactPath(cfg, logger.RunID(), "proposed_themes.yaml")
+		if err := writer.SaveProposedThemes(result.ProposedThemes, proposedPath); err != nil {
+			logger.Warn("Failed to save proposed themes", "error", err)
+		} else {
+			logger.Info("Saved proposed themes", "path", proposedPath)
+			summary.addArtifact("Proposed themes", proposedPath)
+			con.Note("Add the ones you want to keep to the 'themes:' section and run again, which matches all responses against the new themes.")
+		}
+	}
+
+	// Present responses that fit none of the themes
+	if len(result.Outliers) > 0 {
+		con.Heading(fmt.Sprintf("%d responses are far from all themes (possible new topics)", len(result.Outliers)))
+		rows := make([][]string, len(result.Outliers))
+		for i, outlier := range result.Outliers {
+			responseAnalysis := result.ResponseAnalyses[outlier.ResponseID]
+			rows[i] = []string{fmt.Sprintf("%d", responseAnalysis.Response.RowIndex), fmt.Sprintf("%.2f", outlier.Similarity), outlier.NearestTheme, responseAnalysis.Response.Text}
+		}
+		con.Table([]console.Column{{Title: "Row", Right: true}, {Title: "Similarity", Right: true}, {Title: "Nearest theme", Max: 30}, {Title: "Response", Max: 60}}, rows)
+	}
+
+	// Present the clusters next to the themes they overlap with
+	if len(result.Clusters) > 0 {
+		con.Heading("Response clusters (independent of themes)")
+		rows := make([][]string, len(result.Clusters))
+		for i, item := range result.Clusters {
+			rows[i] = []string{item.Label, fmt.Sprintf("%d", item.Size), item.TopTheme, overlap(item.TopTheme, item.TopThemeShare)}
+		}
+		con.Table([]console.Column{{Title: "Cluster", Max: 40}, {Title: "Responses", Right: true}, {Title: "Top theme", Max: 40}, {Title: "Overlap", Right: true}}, rows)
+	}
+
+	// Present the topic model baseline next to the themes
+	if len(result.Topics) > 0 {
+		con.Heading("Topic model baseline (NMF)")
+		rows := make([][]string, len(result.Topics))
+		for i, topic := range result.Topics {
+			rows[i] = []string{strings.Join(topic.Words, ", "), fmt.Sprintf("%d", topic.Size), topic.TopTheme, overlap(topic.TopTheme, topic.TopThemeShare)}
+		}
+		con.Table([]console.Column{{Title: "Topic words", Max: 50}, {Title: "Responses", Right: true}, {Title: "Top theme", Max: 40}, {Title: "Overlap", Right: true}}, rows)
+	}
+
+	// Present the languages and lengths of the responses
+	if corpus := result.Corpus; corpus != nil && len(corpus.Languages) > 0 {
+		con.Heading(fmt.Sprintf("Responses by language (mean length %.0f characters, %.0f words)", corpus.Length.MeanChars, corpus.Length.MeanWords))
+		rows := make([][]string, len(corpus.Languages))
+		for i, language := range corpus.Languages {
+			rows[i] = []string{language.Language, fmt.Sprintf("%d", language.Count), fmt.Sprintf("%.1f%%", language.Percentage)}
+		}
+		con.Table([]console.Column{{Title: "Language"}, {Title: "Responses", Right: true}, {Title: "Share", Right: true}}, rows)
+	}
+
+	// Present the most frequent themes per period
+	if result.TimeSeries != nil {
+		con.Heading(fmt.Sprintf("Themes per %s", result.TimeSeries.Interval))
+		rows := make([][]string, len(result.TimeSeries.Periods))
+		for i, period := range result.TimeSeries.Periods {
+			rows[i] = []string{period.Label, fmt.Sprintf("%d", period.Responses), topThemes(period.ThemeCounts, 3)}
+		}
+		con.Table([]console.Column{{Title: "Period"}, {Title: "Responses", Right: true}, {Title: "Most frequent themes", Max: 80}}, rows)
+	}
+
+	// Present the themes of the rolling windows and those gaining ground
+	if len(result.RollingWindows) > 0 {
+		con.Heading(fmt.Sprintf("Rolling windows up to %s", result.RollingWindows[0].End.Format("2006-01-02")))
+		rows := make([][]string, len(result.RollingWindows))
+		for i, window := range result.RollingWindows {
+			rows[i] = []string{
+				fmt.Sprintf("%d days", window.Days),
+				fmt.Sprintf("%d", window.Responses),
+				fmt.Sprintf("%d", window.PreviousResponses),
+				topThemes(window.ThemeCounts, 3),
+				risingThemes(window, 3),
+			}
+		}
+		con.Table([]console.Column{{Title: "Window"}, {Title: "Responses", Right: true}, {Title: "Before", Right: true}, {Title: "Most frequent themes", Max: 50}, {Title: "Rising", Max: 40}}, rows)
+	}
+
+	// Present the ratings per theme and the themes per rating band
+	if ratings := result.Ratings; ratings != nil {
+		con.Heading(fmt.Sprintf("Mean rating per theme (all rated responses: %.2f)", ratings.MeanRating))
+		rows := make([][]string, 0, len(ratings.Themes))
+		for _, theme := range ratings.Themes {
+			if theme.Rated > 0 {
+				rows = append(rows, []string{theme.Theme, fmt.Sprintf("%d", theme.Rated), fmt.Sprintf("%.2f", theme.MeanRating)})
+			}
+		}
+		con.Table([]console.Column{{Title: "Theme", Max: 50}, {Title: "Rated", Right: true}, {Title: "Mean rating", Right: true}}, rows)
+
+		rows = make([][]string, len(ratings.Bands))
+		for i, band := range ratings.Bands {
+			rows[i] = []string{band.Name, fmt.Sprintf("%d", band.Responses), topThemes(band.ThemeCounts, 3)}
+		}
+		con.Table([]console.Column{{Title: "Rating band"}, {Title: "Responses", Right: true}, {Title: "Most frequent themes", Max: 80}}, rows)
+
+		if len(ratings.Drivers) > 0 {
+			con.Heading("Key drivers (themes by effect size on the rating)")
+			rows = make([][]string, len(ratings.Drivers))
+			for i, driver := range ratings.Drivers {
+				rows[i] = []string{driver.Theme, fmt.Sprintf("%d", driver.With), fmt.Sprintf("%.2f", driver.MeanWith), fmt.Sprintf("%.2f", driver.MeanWithout), fmt.Sprintf("%+.2f", driver.EffectSize)}
+			}
+			con.Table([]console.Column{{Title: "Theme", Max: 50}, {Title: "Responses", Right: true}, {Title: "Mean with", Right: true}, {Title: "Mean without", Right: true}, {Title: "Effect size", Right: true}}, rows)
+		}
+	}
+
+	// Present refined sub-structures for over-broad themes
+	for _, split := range result.ThemeSplits {
+		if split.Applied {
+			con.Heading(fmt.Sprintf("Theme '%s' (%.1f%% of responses) was split into", split.Theme, split.Share*100))
+		} else {
+			con.Heading(fmt.Sprintf("Theme '%s' covers %.1f%% of responses. Suggested sub-themes", split.Theme, split.Share*100))
+		}
+		rows := make([][]string, len(split.SubThemes))
+		for i, subTheme := range split.SubThemes {
+			rows[i] = []string{subTheme, fmt.Sprintf("%d", len(split.Responses[subTheme]))}
+		}
+		con.Table([]console.Column{{Title: "Sub-theme"}, {Title: "Responses", Right: true}}, rows)
+	}
+
+	// Present the non-fatal issues of the run, which are easily missed in the log
+	if warningCounts := result.WarningCounts(); len(warningCounts) > 0 {
+		con.Heading(fmt.Sprintf("%d warnings during the run", len(result.Warnings)+result.DroppedWarnings))
+		rows := make([][]string, len(warningCounts))
+		for i, warningCount := range warningCounts {
+			rows[i] = []string{warningCount.Message, fmt.Sprintf("%d", warningCount.Count)}
+		}
+		con.Table([]console.Column{{Title: "Warning", Max: 80}, {Title: "Count", Right: true}}, rows)
+		con.Note("The details of each warning are in the 'warnings:' section of the state file.")
+	}
+
+	renderReports(logger, cfg, writer, result, logger.RunID(), summary)
+	checkRateLimits(logger, cfg, claudeClient)
+
+	return summary, nil
+}
+
+// recomputeReasons returns what changed since the previous run: the keys of
+// the fingerprint that differ, failed_responses if the previous run left
+// responses to retry and stale_summaries if summaries were marked stale since.
+// A previous run without a fingerprint always counts as changed.
+func recomputeReasons(fingerprint analysis.Fingerprint, previous *analysis.AnalysisResult) []string {
+	if len(previous.Run.Fingerprint) == 0 {
+		return []string{"fingerprint"}
+	}
+	changes := fingerprint.Changes(previous.Run.Fingerprint)
+	if len(previous.FailedResponses) > 0 {
+		changes = append(changes, "failed_responses")
+	}
+	if len(previous.StaleThemeSummaries) > 0 || previous.StaleGlobalSummary {
+		changes = append(changes, "stale_summaries")
+	}
+	return changes
+}
+
+// rerenderUnchanged renders the outputs of the previous run again, for runs
+// in which nothing that determines the result changed
+func rerenderUnchanged(logger *logging.Logger, con *console.Console, cfg *config.Config, writer *output.Writer, previous *analysis.AnalysisResult, summary *runSummary) (*runSummary, error) {
+	logger.Info("Nothing changed since the previous run, re-rendering its outputs", "previous_run", previous.Run.RunID)
+	summary.result = previous
+	summary.addArtifact("State", cfg.StateFilePath)
+
+	con.Heading("Themes")
+	con.Table([]console.Column{{Title: "Theme"}, {Title: "Responses", Right: true}, {Title: "Share", Right: true}}, themeRows(previous))
+
+	runID := previous.Run.RunID
+	if runID == "" {
+		runID = logger.RunID()
+	}
+	renderReports(logger, cfg, writer, previous, runID, summary)
+	con.Note(fmt.Sprintf("Nothing that determines the result changed since run %s, so the outputs were rendered from the state without calling the API. Pass -force to analyze again.", previous.Run.RunID))
+	return summary, nil
+}
+
+// topThemes lists the most frequent themes with their counts
+func topThemes(counts map[string]int, limit int) string {
+	themes := make([]string, 0, len(counts))
+	for theme := range counts {
+		themes = append(themes, theme)
+	}
+	sort.Slice(themes, func(i, j int) bool {
+		if counts[themes[i]] != counts[themes[j]] {
+			return counts[themes[i]] > counts[themes[j]]
+		}
+		return themes[i] < themes[j]
+	})
+	parts := make([]string, 0, limit)
+	for _, theme := range themes[:min(limit, len(themes))] {
+		parts = append(parts, fmt.Sprintf("%s (%d)", theme, counts[theme]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// risingThemes lists the themes whose share of the responses grew most in the
+// window compared to the window before it, in percentage points
+func risingThemes(window analysis.RollingWindow, limit int) string {
+	if window.Responses == 0 || window.PreviousResponses == 0 {
+		return ""
+	}
+	changes := make(map[string]float64)
+	for theme, count := range window.ThemeCounts {
+		change := 100 * (float64(count)/float64(window.Responses) - float64(window.PreviousCounts[theme])/float64(window.PreviousResponses))
+		if change > 0 {
+			changes[theme] = change
+		}
+	}
+	themes := make([]string, 0, len(changes))
+	for theme := range changes {
+		themes = append(themes, theme)
+	}
+	sort.Slice(themes, func(i, j int) bool {
+		if changes[themes[i]] != changes[themes[j]] {
+			return changes[themes[i]] > changes[themes[j]]
+		}
+		return themes[i] < themes[j]
+	})
+	parts := make([]string, 0, limit)
+	for _, theme := range themes[:min(limit, len(themes))] {
+		parts = append(parts, fmt.Sprintf("%s (+%.0f pts)", theme, changes[theme]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// newExcelReader creates an Excel reader for the columns, the preprocessing
+// and the segmentation of the configuration
+func newExcelReader(logger *logging.Logger, cfg *config.Config) (*excel.ExcelReader, error) {
+	reader := excel.NewExcelReader(logger)
+	reader.SetInputFormat(cfg.InputFormat)
+	if cfg.TimestampColumn != "" {
+		reader.SetTimestampColumn(cfg.TimestampColumn)
+	}
+	if cfg.RatingColumn != "" {
+		reader.SetRatingColumn(cfg.RatingColumn)
+	}
+	if len(cfg.Preprocessing) > 0 {
+		preprocessor, err := excel.NewPreprocessor(cfg.Preprocessing, cfg.SpellingCorrections, cfg.BoilerplatePatterns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preprocessing: %w", err)
+		}
+		reader.SetPreprocessor(preprocessor)
+	}
+	if cfg.Segmentation != "" {
+		segmenter := excel.NewSegmenter(cfg.Segmentation, cfg.SegmentationMinLength)
+		if cfg.Segmentation == excel.SegmentSemantic {
+			embedder, err := embedding.NewEmbedder(logger, cfg.EmbeddingProvider, cfg.EmbeddingModel, cfg.EmbeddingAPIKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize embedder for segmentation: %w", err)
+			}
+			segmenter.SetEmbedder(embedder.Embed, cfg.SegmentationThreshold)
 		}
+		reader.SetSegmenter(segmenter)
+	}
+	return reader, nil
+}
+
+// surveyQuestion returns the question the responses answer: the configured
+// column title or the header cell of the response column
+func surveyQuestion(cfg *config.Config, excelData excel.ExcelData) string {
+	if cfg.ColumnTitle != "" {
+		return cfg.ColumnTitle
+	}
+	return excelData.ColumnTitle
+}
+
+// checkRateLimits compares the configured rate limits with those reported by
+// the API, so they can be configured instead of guessed
+func checkRateLimits(logger *logging.Logger, cfg *config.Config, client *claude.Client) {
+	observed, ok := client.ObservedRateLimits()
+	if !ok {
+		return
+	}
+	configured := cfg.RateLimits()
+	if configured == (claude.RateLimits{}) {
+		logger.Info("Rate limits reported by the API, set them in the configuration to derive throughput settings",
+			"requests_per_minute", observed.RequestsPerMinute,
+			"input_tokens_per_minute", observed.InputTokensPerMinute,
+			"output_tokens_per_minute", observed.OutputTokensPerMinute)
+		return
+	}
+	if configured.RequestsPerMinute > observed.RequestsPerMinute ||
+		configured.InputTokensPerMinute > observed.InputTokensPerMinute ||
+		configured.OutputTokensPerMinute > observed.OutputTokensPerMinute {
+		logger.Warn("Configured rate limits exceed those reported by the API",
+			"requests_per_minute", observed.RequestsPerMinute,
+			"input_tokens_per_minute", observed.InputTokensPerMinute,
+			"output_tokens_per_minute", observed.OutputTokensPerMinute)
 	}
+}
+
+// renderReports renders the report template, the appendix and the wide
+// export of a result
+func renderReports(logger *logging.Logger, cfg *config.Config, writer *output.Writer, result *analysis.AnalysisResult, runID string, summary *runSummary) {
+	// Present the survey as currently configured, as it does not change
+	// the result and may be edited after the analysis
+	if cfg.Survey != nil {
+		result.Survey = cfg.Survey
+	}
+	writer.SetHTMLTheme(cfg.HTMLTheme)
 
 	// Generate report if template is provided
 	if cfg.ReportTemplatePath != "" {
@@ -287,13 +1317,205 @@ func runWorkflow(logger *logging.Logger, cfg *config.Config, identifyThemesOnly
 		if reportPath == "" {
 			reportPath = filepath.Join(filepath.Dir(cfg.StateFilePath), "report.txt")
 		}
+		reportPath = withRunID(cfg, runID, reportPath)
 		if err := writer.GenerateReport(result, cfg.ReportTemplatePath, reportPath); err != nil {
 			logger.Warn("Failed to generate report", "error", err)
 		} else {
 			logger.Info("Generated report", "path", reportPath)
-			fmt.Printf("Report generated at: %s\n", reportPath)
+			summary.addArtifact("Report", reportPath)
 		}
 	}
 
-	return claudeClient, nil
+	// Generate the additional reports, each with the summaries in its language
+	for _, report := range cfg.ReportOutputs {
+		localized := result
+		if report.Language != "" && report.Language != cfg.OutputLanguage {
+			var err error
+			if localized, err = result.InLanguage(report.Language); err != nil {
+				logger.Warn("No summaries for report, run an analysis first", "language", report.Language, "path", report.Path)
+				continue
+			}
+		}
+		templatePath := report.Template
+		if templatePath == "" {
+			templatePath = cfg.ReportTemplatePath
+		}
+		path := withRunID(cfg, runID, report.Path)
+		if err := writer.GenerateReport(localized, templatePath, path); err != nil {
+			logger.Warn("Failed to generate report", "path", path, "error", err)
+			continue
+		}
+		logger.Info("Generated report", "path", path, "language", report.Language)
+		label := "Report"
+		if report.Language != "" {
+			label = fmt.Sprintf("Report (%s)", report.Language)
+		}
+		summary.addArtifact(label, path)
+	}
+
+	// Write the summary of each audience, as a report if it has a template
+	for _, audience := range cfg.SummaryAudiences {
+		tailored, err := result.ForAudience(audience.Name)
+		if err != nil {
+			logger.Warn("No summary for audience, run an analysis first", "audience", audience.Name)
+			continue
+		}
+		path := audiencePath(cfg, runID, audience)
+		if audience.TemplatePath != "" {
+			err = writer.GenerateReport(tailored, audience.TemplatePath, path)
+		} else {
+			err = writer.SaveSummary(tailored.GlobalSummary, path)
+		}
+		if err != nil {
+			logger.Warn("Failed to write audience summary", "audience", audience.Name, "error", err)
+		} else {
+			summary.addArtifact(fmt.Sprintf("Summary for %s", audience.Name), path)
+		}
+	}
+
+	// Generate appendix if requested
+	if cfg.AppendixFormat != "" {
+		path := appendixPath(cfg, runID)
+		if err := writer.GenerateAppendix(result, cfg.AppendixFormat, path); err != nil {
+			logger.Warn("Failed to generate appendix", "error", err)
+		} else {
+			logger.Info("Generated appendix", "path", path)
+			summary.addArtifact("Appendix", path)
+		}
+	}
+
+	// Generate the HTML report with charts if requested
+	if cfg.HTMLReportPath != "" {
+		path := withRunID(cfg, runID, cfg.HTMLReportPath)
+		if err := writer.GenerateHTMLReport(result, path); err != nil {
+			logger.Warn("Failed to generate HTML report", "error", err)
+		} else {
+			summary.addArtifact("HTML report", path)
+		}
+	}
+
+	// Export the responses for statistics software if requested
+	if cfg.WideExportPath != "" {
+		path := withRunID(cfg, runID, cfg.WideExportPath)
+		codebookPath, err := writer.ExportWide(result, path)
+		if err != nil {
+			logger.Warn("Failed to export responses in wide format", "error", err)
+		} else {
+			logger.Info("Exported responses in wide format", "path", path, "codebook", codebookPath)
+			summary.addArtifact("Wide export", path)
+			summary.addArtifact("Codebook", codebookPath)
+		}
+	}
+
+	// Write the Excel workbook with the theme matrix if requested
+	if cfg.ExcelOutputPath != "" {
+		path := withRunID(cfg, runID, cfg.ExcelOutputPath)
+		if err := writer.ExportWorkbook(result, path); err != nil {
+			logger.Warn("Failed to export Excel workbook", "error", err)
+		} else {
+			logger.Info("Exported Excel workbook", "path", path)
+			summary.addArtifact("Excel workbook", path)
+		}
+	}
+
+	// Annotate a copy of the input workbook if requested
+	if cfg.AnnotatedExcelPath != "" {
+		path := withRunID(cfg, runID, cfg.AnnotatedExcelPath)
+		if err := writer.AnnotateWorkbook(result, cfg.ExcelFilePath, cfg.ResponseColumn, path); err != nil {
+			logger.Warn("Failed to annotate a copy of the Excel file", "error", err)
+		} else {
+			summary.addArtifact("Annotated Excel file", path)
+		}
+	}
+
+	// Draw the review sample if requested. An existing sample may be under
+	// review and is kept.
+	if cfg.ReviewSampleSize > 0 {
+		path := reviewSamplePath(cfg)
+		if _, err := os.Stat(path); err == nil {
+			logger.Info("Keeping the existing review sample, delete it to draw a new one", "path", path)
+		} else {
+			sample := analysis.ReviewSample(result, cfg.ReviewSampleSize, cfg.ReviewSampleSeed)
+			if err := writer.SaveReviewSample(result, sample, path); err != nil {
+				logger.Warn("Failed to save review sample", "error", err)
+			} else {
+				summary.addArtifact("Review sample", path)
+			}
+		}
+	}
+}
+
+// themeRows returns the rows of the theme table, ordered by number of responses
+func themeRows(result *analysis.AnalysisResult) [][]string {
+	total := len(result.ResponseAnalyses)
+	themes := append([]string(nil), result.Themes...)
+	sort.SliceStable(themes, func(i, j int) bool {
+		return len(result.ThemeAnalyses[themes[i]].Responses) > len(result.ThemeAnalyses[themes[j]].Responses)
+	})
+	rows := make([][]string, len(themes))
+	for i, theme := range themes {
+		count := len(result.ThemeAnalyses[theme].Responses)
+		share := 0.0
+		if total > 0 {
+			share = float64(count) / float64(total) * 100.0
+		}
+		rows[i] = []string{theme, fmt.Sprintf("%d", count), fmt.Sprintf("%.1f%%", share)}
+	}
+	return rows
+}
+
+// overlap formats the share of a cluster or topic assigned to its top theme
+func overlap(topTheme string, share float64) string {
+	if topTheme == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", share*100)
+}
+
+// artifactPath returns the path of an output file stored next to the state file
+func artifactPath(cfg *config.Config, runID, name string) string {
+	return withRunID(cfg, runID, filepath.Join(filepath.Dir(cfg.StateFilePath), name))
+}
+
+// audienceFileName matches the characters replaced in the default file name
+// of an audience summary
+var audienceFileName = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// audiencePath returns the path the summary of an audience is written to,
+// summary-<name>.txt next to the state file unless configured
+func audiencePath(cfg *config.Config, runID string, audience config.SummaryAudience) string {
+	if audience.OutputPath != "" {
+		return withRunID(cfg, runID, audience.OutputPath)
+	}
+	name := strings.Trim(audienceFileName.ReplaceAllString(strings.ToLower(audience.Name), "-"), "-")
+	return artifactPath(cfg, runID, "summary-"+name+".txt")
+}
+
+// reviewSamplePath returns the path of the review sample. It does not carry
+// the run ID, as the sample is kept across runs until it is reviewed.
+func reviewSamplePath(cfg *config.Config) string {
+	if cfg.ReviewSamplePath != "" {
+		return cfg.ReviewSamplePath
+	}
+	return filepath.Join(filepath.Dir(cfg.StateFilePath), "review_sample.xlsx")
+}
+
+// withRunID inserts the run ID before the file extension if configured
+func withRunID(cfg *config.Config, runID, path string) string {
+	if !cfg.RunIDInFilenames || runID == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return path[:len(path)-len(ext)] + "-" + runID + ext
+}
+
+// appendixPath returns the output path of the appendix for the configured format
+func appendixPath(cfg *config.Config, runID string) string {
+	if cfg.AppendixOutputPath != "" {
+		return withRunID(cfg, runID, cfg.AppendixOutputPath)
+	}
+	if cfg.AppendixFormat == "html" {
+		return artifactPath(cfg, runID, "appendix.html")
+	}
+	return artifactPath(cfg, runID, "appendix.md")
 }
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+	"github.com/oetiker/response-analyzer/pkg/validation"
+)
+
+// maxPrintedChanges limits the changed responses printed by model-diff
+const maxPrintedChanges = 15
+
+// modelDiffOptions holds the flags of the model-diff subcommand
+type modelDiffOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	model      *string
+	sample     *int
+	outputPath *string
+	noColor    *bool
+}
+
+// newModelDiffFlags defines the flags of the model-diff subcommand
+func newModelDiffFlags() (*flag.FlagSet, *modelDiffOptions) {
+	flags := flag.NewFlagSet("model-diff", flag.ExitOnError)
+	options := &modelDiffOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		model:      flags.String("model", "", "New model to match the sample with"),
+		sample:     flags.Int("sample", 0, "Number of responses to match instead of comparison_sample_size"),
+		outputPath: flags.String("output-path", "", "Migration report to write (defaults to model_diff.yaml next to the state file)"),
+		noColor:    flags.Bool("no-color", false, "Disable colored output"),
+	}
+	flags.Usage = commandUsage("model-diff", flags)
+	return flags, options
+}
+
+// runModelDiff runs the model-diff subcommand, which matches a sample of the
+// analyzed responses with a new model and reports which theme assignments of
+// the state would change
+func runModelDiff(args []string) {
+	flags, options := newModelDiffFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+	logger.SetRunID(analysis.NewRunID())
+
+	if *options.configPath == "" || *options.model == "" {
+		fmt.Println("Please provide a configuration file using the -config flag and the new model using the -model flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+	if *options.sample != 0 {
+		cfg.ComparisonSampleSize = *options.sample
+	}
+	outputPath := *options.outputPath
+	if outputPath == "" {
+		outputPath = filepath.Join(filepath.Dir(cfg.StateFilePath), "model_diff.yaml")
+	}
+
+	migration, err := diffModel(logger, cfg, strings.TrimSpace(*options.model))
+	if err != nil {
+		logger.Error("Model diff failed", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	writer := output.NewWriter(logger)
+	if err := writer.SaveModelMigration(migration, outputPath); err != nil {
+		logger.Error("Failed to save model diff", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	printModelMigration(con, migration, outputPath)
+}
+
+// diffModel matches a sample of the responses analyzed in the state with the
+// new model, without the cache, and compares the assignments with the state
+func diffModel(logger *logging.Logger, cfg *config.Config, model string) (*analysis.ModelMigration, error) {
+	validator := validation.NewValidator(logger)
+	if err := validator.ValidateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	stateExists, err := validator.ValidateStateFile(cfg.StateFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if !stateExists {
+		return nil, fmt.Errorf("no state file at %s, run an analysis first", cfg.StateFilePath)
+	}
+	writer := output.NewWriter(logger)
+	result, err := writer.LoadState(cfg.StateFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	if len(result.Themes) == 0 || len(result.ResponseAnalyses) == 0 {
+		return nil, fmt.Errorf("the state holds no theme assignments, run an analysis first")
+	}
+	if result.Run.Model == "" {
+		result.Run.Model = cfg.ClaudeModel
+	}
+
+	// The texts may be stripped from the state, read them from the input
+	excelReader, err := newExcelReader(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	excelData, err := excelReader.ReadResponses(cfg.ExcelFilePath, cfg.ResponseColumn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read responses: %w", err)
+	}
+	responses := excelData.Responses
+	if cfg.Pseudonymize {
+		responses = analysis.PseudonymizeResponses(responses, cfg.PseudonymizationSalt)
+	}
+	candidates := analysis.MigrationCandidates(result, responses)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("none of the responses in %s is analyzed in the state with the same text", cfg.ExcelFilePath)
+	}
+	sample := analysis.SampleResponses(candidates, cfg.ComparisonSampleSize, comparisonSeed)
+
+	modelCfg := *cfg
+	modelCfg.ClaudeModel = model
+	modelCfg.CacheEnabled = false // Cached answers would repeat the old model
+	if !claude.SupportsThinking(model) {
+		modelCfg.ThinkingBudgetTokens = 0
+	}
+	client, err := newClaudeClient(logger, &modelCfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkModel(client, model); err != nil {
+		return nil, err
+	}
+	descriptions := result.ThemeDescriptions
+	if len(descriptions) == 0 {
+		descriptions = cfg.ThemeDescriptions
+	}
+	client.SetThemeDescriptions(descriptions)
+	client.SetSurveyQuestion(surveyQuestion(cfg, excelData))
+
+	logger.Info("Matching sample with new model", "from", result.Run.Model, "to", model, "responses", len(sample), "themes", len(result.Themes))
+	run := runModelMatching(logger, cfg, client, sample, result.Themes)
+	if run.Error != "" && len(run.Analyses) == 0 {
+		return nil, fmt.Errorf("matching with %s failed: %s", model, run.Error)
+	}
+
+	migration := analysis.CompareMigration(result, sample, run)
+	return &migration, nil
+}
+
+// printModelMigration prints how the theme assignments would change with the
+// new model
+func printModelMigration(con *console.Console, migration *analysis.ModelMigration, outputPath string) {
+	con.Heading("Assignments per theme")
+	rows := [][]string{}
+	for _, theme := range migration.Themes {
+		rows = append(rows, []string{
+			theme.Theme,
+			fmt.Sprintf("%d", theme.Before),
+			fmt.Sprintf("%d", theme.After),
+			fmt.Sprintf("+%d", theme.Gained),
+			fmt.Sprintf("-%d", theme.Lost),
+			fmt.Sprintf("%.2f", theme.Kappa),
+		})
+	}
+	con.Table([]console.Column{{Title: "Theme", Max: 50}, {Title: "Before", Right: true}, {Title: "After", Right: true}, {Title: "Gained", Right: true}, {Title: "Lost", Right: true}, {Title: "Kappa", Right: true}}, rows)
+
+	if len(migration.Moves) > 0 {
+		con.Heading("Most frequent moves")
+		rows = [][]string{}
+		for _, move := range migration.Moves {
+			rows = append(rows, []string{move.From, move.To, fmt.Sprintf("%d", move.Count)})
+		}
+		con.Table([]console.Column{{Title: "From", Max: 40}, {Title: "To", Max: 40}, {Title: "Responses", Right: true}}, rows)
+	}
+
+	if len(migration.Changes) > 0 {
+		con.Heading("Changed responses")
+		rows = [][]string{}
+		for _, change := range migration.Changes[:min(len(migration.Changes), maxPrintedChanges)] {
+			rows = append(rows, []string{
+				change.ResponseID,
+				fmt.Sprintf("%d", change.Row),
+				strings.Join(change.Removed, ", "),
+				strings.Join(change.Added, ", "),
+			})
+		}
+		con.Table([]console.Column{{Title: "Response"}, {Title: "Row", Right: true}, {Title: "Removed", Max: 40}, {Title: "Added", Max: 40}}, rows)
+		if len(migration.Changes) > maxPrintedChanges {
+			con.Note(fmt.Sprintf("%d more changed responses are listed in %s", len(migration.Changes)-maxPrintedChanges, outputPath))
+		}
+	}
+
+	items := []console.Item{
+		{Label: "Models", Value: fmt.Sprintf("%s → %s", migration.FromModel, migration.ToModel)},
+		{Label: "Sample", Value: fmt.Sprintf("%d responses, %d compared", migration.SampleSize, migration.Compared)},
+		{Label: "Changed", Value: fmt.Sprintf("%d responses (%.1f%%)", migration.ChangedResponses, migration.ChangedShare*100)},
+		{Label: "Assignments", Value: fmt.Sprintf("+%d / -%d", migration.AddedAssignments, migration.RemovedAssignments)},
+		{Label: "Estimated", Value: fmt.Sprintf("%d of %d analyzed responses would change", migration.EstimatedChanged, migration.AnalyzedResponses)},
+		{Label: "Mean overlap", Value: fmt.Sprintf("%.2f (Jaccard)", migration.MeanJaccard)},
+		{Label: "Cost", Value: fmt.Sprintf("$%.4f", migration.Run.Cost)},
+	}
+	if migration.Run.Failed > 0 {
+		items = append(items, console.Item{Label: "Failed", Value: fmt.Sprintf("%d responses not classified by %s", migration.Run.Failed, migration.ToModel)})
+	}
+	items = append(items, console.Item{Label: "Model diff", Value: outputPath})
+	con.Panel("Model migration", items)
+}
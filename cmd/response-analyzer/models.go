@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// modelsOptions holds the flags of the models subcommand
+type modelsOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	noColor    *bool
+}
+
+// newModelsFlags defines the flags of the models subcommand
+func newModelsFlags() (*flag.FlagSet, *modelsOptions) {
+	flags := flag.NewFlagSet("models", flag.ExitOnError)
+	options := &modelsOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		noColor:    flags.Bool("no-color", false, "Disable colored output"),
+	}
+	flags.Usage = commandUsage("models", flags)
+	return flags, options
+}
+
+// runModels runs the models subcommand, which lists the models available to
+// the API key and checks the configured models against them
+func runModels(args []string) {
+	flags, options := newModelsFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+
+	if *options.configPath == "" {
+		fmt.Println("Please provide a configuration file using the -config flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+	client := claude.NewClient(cfg.APIKeys()[0].Key, logger, nil, cfg.OutputLanguage, cfg.ClaudeModel)
+
+	models, err := client.ListModels()
+	if err != nil {
+		logger.Error("Failed to list models", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	configured := configuredModels(cfg, client.Model())
+	rows := make([][]string, len(models))
+	for i, model := range models {
+		inputCost, outputCost := claude.ModelCostPerMillionTokens(model.ID)
+		thinking := ""
+		if claude.SupportsThinking(model.ID) {
+			thinking = "yes"
+		}
+		marker := ""
+		for _, name := range configured {
+			if name == model.ID {
+				marker = "*"
+			}
+		}
+		rows[i] = []string{marker, model.ID, model.DisplayName, model.CreatedAt.Format("2006-01-02"), fmt.Sprintf("$%.2f / $%.2f", inputCost, outputCost), thinking}
+	}
+	con.Heading("Available models")
+	con.Table([]console.Column{{Title: ""}, {Title: "Model"}, {Title: "Name"}, {Title: "Released"}, {Title: "Price per 1M in / out", Right: true}, {Title: "Thinking"}}, rows)
+	con.Note("Prices are those used for cost reporting; unknown models are priced like claude-3-opus.")
+
+	failed := false
+	for _, name := range configured {
+		if err := checkModel(client, name); err != nil {
+			con.Warn("%v", err)
+			failed = true
+		} else {
+			con.Success("Configured model %s is available", name)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// configuredModels returns the models used by the configuration
+func configuredModels(cfg *config.Config, model string) []string {
+	models := []string{model}
+	if cfg.VerifySummaries && cfg.VerificationModel != model {
+		models = append(models, cfg.VerificationModel)
+	}
+	return models
+}
+
+// checkModel checks that the API knows a model, suggesting similar model
+// names for typos
+func checkModel(client *claude.Client, model string) error {
+	_, err := client.GetModel(model)
+	if err == nil || !errors.Is(err, claude.ErrUnknownModel) {
+		return err
+	}
+
+	hint := ""
+	if models, listErr := client.ListModels(); listErr == nil {
+		if suggestions := claude.SuggestModels(model, models); len(suggestions) > 0 {
+			hint = fmt.Sprintf(", did you mean %s?", strings.Join(suggestions, " or "))
+		} else {
+			hint = ", run the models subcommand to list the available models"
+		}
+	}
+	return fmt.Errorf("%w: %s%s", claude.ErrUnknownModel, model, hint)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+)
+
+// notifyPause toggles the pause of the run on SIGUSR1
+func notifyPause(pause *analysis.PauseControl) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for range signals {
+			pause.Toggle()
+		}
+	}()
+}
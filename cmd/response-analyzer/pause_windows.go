@@ -0,0 +1,7 @@
+package main
+
+import "github.com/oetiker/response-analyzer/pkg/analysis"
+
+// notifyPause does nothing, as Windows has no SIGUSR1; runs are paused with
+// the pause file instead
+func notifyPause(pause *analysis.PauseControl) {}
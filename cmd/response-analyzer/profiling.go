@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// startPprof serves the runtime profiles of net/http/pprof at the given
+// address, e.g. :6060, for the rest of the run
+func startPprof(logger *logging.Logger, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for pprof: %w", err)
+	}
+
+	// Only the profiles are served, on a mux of their own
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			logger.Warn("Stopped serving pprof", "error", err)
+		}
+	}()
+	logger.Info("Serving pprof profiles", "url", fmt.Sprintf("http://%s/debug/pprof/", listener.Addr()))
+	return nil
+}
+
+// logMemoryUsage logs the memory usage of the process at the given interval
+// until stop is called, and once more then
+func logMemoryUsage(logger *logging.Logger, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				logMemoryStats(logger, "Memory usage")
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		logMemoryStats(logger, "Memory usage at the end of the run")
+	}
+}
+
+// logMemoryStats logs the heap in use, the memory obtained from the system,
+// the heap reserved and the garbage collections so far
+func logMemoryStats(logger *logging.Logger, message string) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	logger.Info(message,
+		"heap", mebibytes(stats.HeapAlloc),
+		"heap_objects", stats.HeapObjects,
+		"system", mebibytes(stats.Sys),
+		"heap_reserved", mebibytes(stats.HeapSys),
+		"gc_runs", stats.NumGC,
+		"goroutines", runtime.NumGoroutine())
+}
+
+// mebibytes formats a number of bytes in MiB
+func mebibytes(bytes uint64) string {
+	return fmt.Sprintf("%.1f MiB", float64(bytes)/(1<<20))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+	"github.com/oetiker/response-analyzer/pkg/template"
+	"github.com/oetiker/response-analyzer/pkg/validation"
+)
+
+// questionConfig returns the configuration of the question in a column of a
+// survey with several response columns. The state and outputs of the
+// question go to a directory named after the column next to the state file.
+func questionConfig(cfg *config.Config, column string) (*config.Config, error) {
+	questionCfg := *cfg
+	questionCfg.ResponseColumn = column
+	questionCfg.ResponseColumns = nil
+	questionCfg.ColumnTitle = "" // Every question has a header of its own
+	questionCfg.SummaryAudiences = slices.Clone(cfg.SummaryAudiences)
+	questionCfg.ReportOutputs = slices.Clone(cfg.ReportOutputs)
+	dir := filepath.Join(filepath.Dir(cfg.StateFilePath), strings.ToUpper(column))
+	if err := moveOutputs(&questionCfg, dir); err != nil {
+		return nil, err
+	}
+
+	// Without configured themes every question gets a theme set of its own
+	if len(cfg.Themes) == 0 && cfg.ThemesFile == "" {
+		questionCfg.ThemesFile = filepath.Join(dir, "themes.yaml")
+		questionCfg.ThemeDescriptions = maps.Clone(cfg.ThemeDescriptions)
+		if err := questionCfg.LoadThemesFile(); err != nil {
+			return nil, err
+		}
+	}
+	return &questionCfg, nil
+}
+
+// combinedReportPath returns the output path of the combined report
+func combinedReportPath(cfg *config.Config, runID string) string {
+	if cfg.CombinedReportOutputPath != "" {
+		return withRunID(cfg, runID, cfg.CombinedReportOutputPath)
+	}
+	return artifactPath(cfg, runID, "report-combined.md")
+}
+
+// runQuestions runs the workflow for every question of a survey with
+// several response columns, one after the other, and renders the combined
+// report of all questions
+func runQuestions(ctx context.Context, logger *logging.Logger, con *console.Console, cfg *config.Config, options *rootOptions, pilot analysis.PilotSampling, runID string) {
+	validator := validation.NewValidator(logger)
+	if err := validator.ValidateConfig(cfg); err != nil {
+		logger.Error("Workflow failed", "error", err)
+		fmt.Printf("Error: configuration validation failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	var questions []template.QuestionResult
+	spent := 0.0
+	for _, column := range cfg.ResponseColumns {
+		questionCfg, err := questionConfig(cfg, column)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		// The cost cap covers all questions of the run
+		if cfg.MaxCostUSD > 0 {
+			questionCfg.MaxCostUSD = max(cfg.MaxCostUSD-spent, 1e-9)
+		}
+		con.Heading(fmt.Sprintf("Question in column %s", strings.ToUpper(column)))
+		logger.Info("Analyzing question", "column", column, "state_file", questionCfg.StateFilePath)
+
+		summary, err := runWorkflow(ctx, logger, con, questionCfg, *options.identifyThemesOnly, *options.assumeYes, *options.force, pilot)
+		if (errors.Is(err, context.Canceled) || errors.Is(err, claude.ErrBudgetExceeded)) && summary != nil {
+			os.Exit(printInterrupted(logger, con, summary, questionCfg.StateFilePath))
+		}
+		if err != nil {
+			logger.Error("Workflow failed", "column", column, "error", err)
+			fmt.Printf("Error in column %s: %v\n", column, err)
+			os.Exit(1)
+		}
+		spent += summary.client.GetTotalCost()
+		summary.question = strings.ToUpper(column)
+		if *options.bundlePath != "" {
+			summary.bundle(logger, withSuffix(*options.bundlePath, strings.ToUpper(column)))
+		}
+		summary.deliver(logger, questionCfg)
+		summary.notify(logger, questionCfg)
+		printRunSummary(logger, con, runID, summary)
+		if summary.result != nil {
+			questions = append(questions, template.QuestionResult{Column: strings.ToUpper(column), Result: summary.result})
+		}
+	}
+	if *options.identifyThemesOnly || len(questions) == 0 {
+		return
+	}
+
+	path := combinedReportPath(cfg, runID)
+	writer := output.NewWriter(logger)
+	writer.SetHTMLTheme(cfg.HTMLTheme)
+	if err := writer.GenerateCombinedReport(questions, cfg.CombinedReportTemplatePath, path); err != nil {
+		logger.Error("Failed to generate combined report", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	con.Panel("Combined report", []console.Item{
+		{Label: "Questions", Value: fmt.Sprintf("%d", len(questions))},
+		{Label: "Report", Value: path},
+	})
+}
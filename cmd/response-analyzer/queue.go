@@ -0,0 +1,243 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/llm"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+	"github.com/oetiker/response-analyzer/pkg/validation"
+)
+
+// queueOptions holds the flags of the queue subcommand
+type queueOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	noColor    *bool
+	batch      *bool
+}
+
+// newQueueFlags defines the flags of the queue subcommand
+func newQueueFlags() (*flag.FlagSet, *queueOptions) {
+	flags := flag.NewFlagSet("queue", flag.ExitOnError)
+	options := &queueOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		noColor:    flags.Bool("no-color", false, "Disable colored output"),
+		batch:      flags.Bool("batch", false, "Drain the queue as a message batch at half the price, waiting for it to end (default with batch_api)"),
+	}
+	flags.Usage = commandUsage("queue", flags)
+	return flags, options
+}
+
+// runQueue runs the queue subcommand, which collects the theme matching
+// requests of the next run in a local queue and sends them separately
+func runQueue(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s queue build|drain|status|clear -config config.yaml\n", os.Args[0])
+		os.Exit(1)
+	}
+	action := args[0]
+
+	flags, options := newQueueFlags()
+	flags.Parse(args[1:])
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+	logger.SetRunID(analysis.NewRunID())
+
+	if *options.configPath == "" {
+		fmt.Println("Please provide a configuration file using the -config flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+
+	var err error
+	switch action {
+	case "build":
+		err = buildQueue(logger, con, cfg)
+	case "drain":
+		err = drainQueue(logger, con, cfg, *options.batch || cfg.BatchAPI)
+	case "status":
+		err = queueStatus(logger, con, cfg)
+	case "clear":
+		err = clearQueue(logger, con, cfg)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown queue action: %s (valid options: build, drain, status, clear)\n", action)
+		os.Exit(1)
+	}
+	if err != nil {
+		logger.Error("Queue "+action+" failed", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// buildQueue queues the theme matching requests the next run would send,
+// without sending any of them
+func buildQueue(logger *logging.Logger, con *console.Console, cfg *config.Config) error {
+	validator := validation.NewValidator(logger)
+	if err := validator.ValidateConfig(cfg); err != nil {
+		return fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	excelReader, err := newExcelReader(logger, cfg)
+	if err != nil {
+		return err
+	}
+	excelData, err := excelReader.ReadResponses(cfg.ExcelFilePath, cfg.ResponseColumn)
+	if err != nil {
+		return fmt.Errorf("failed to read responses: %w", err)
+	}
+	responses := excelData.Responses
+	if cfg.Pseudonymize {
+		responses = analysis.PseudonymizeResponses(responses, cfg.PseudonymizationSalt)
+	}
+
+	var previousResult *analysis.AnalysisResult
+	if stateExists, _ := validator.ValidateStateFile(cfg.StateFilePath); stateExists {
+		writer := output.NewWriter(logger)
+		if previousResult, err = writer.LoadState(cfg.StateFilePath); err != nil {
+			return fmt.Errorf("failed to load state: %w", err)
+		}
+	}
+
+	// The requests must be those of the next run, so the themes and their
+	// descriptions have to be known already
+	themes := cfg.Themes
+	previousAnalyses := map[string]analysis.ResponseAnalysis{}
+	if previousResult != nil {
+		if len(themes) == 0 {
+			themes = previousResult.Themes
+		}
+		previousAnalyses = previousResult.ResponseAnalyses
+		responses = analysis.SkipForgottenResponses(responses, previousResult.ForgottenResponses)
+	}
+	if len(themes) == 0 {
+		return fmt.Errorf("no themes to match, configure themes or identify them with a regular run first")
+	}
+	known := analysis.KnownThemeDescriptions(cfg, previousResult)
+	descriptions := make(map[string]claude.ThemeDescription)
+	for _, theme := range themes {
+		if description, ok := known[theme]; ok {
+			descriptions[theme] = description
+		} else if cfg.GenerateThemeDescriptions {
+			return fmt.Errorf("theme %q has no description yet, generate the descriptions with a regular run first", theme)
+		}
+	}
+
+	claudeClient, err := newClaudeClient(logger, cfg)
+	if err != nil {
+		return err
+	}
+	queue, err := claude.OpenQueue(logger, queueDirPath(cfg))
+	if err != nil {
+		return err
+	}
+	before, _ := queue.Counts()
+	claudeClient.SetQueue(queue, true)
+	claudeClient.SetThemeDescriptions(descriptions)
+	claudeClient.SetSurveyQuestion(surveyQuestion(cfg, excelData))
+
+	analyzer := analysis.NewAnalyzer(logger, claudeClient)
+	if _, err := analyzer.MatchResponsesToThemesParallel(responses, themes, cfg.ContextPrompt, previousAnalyses, cfg.BatchSize, cfg.ParallelWorkers); err != nil {
+		return fmt.Errorf("failed to queue requests: %w", err)
+	}
+
+	total, answered := queue.Counts()
+	con.Panel("Request queue built", []console.Item{
+		{Label: "Newly queued", Value: fmt.Sprintf("%d requests", total-before)},
+		{Label: "Pending", Value: fmt.Sprintf("%d requests", total-answered)},
+		{Label: "Queue", Value: queue.Dir()},
+	})
+	con.Note("Send the requests with: queue drain -config <config>")
+	return nil
+}
+
+// drainQueue sends the pending requests of the queue, keeping the answers
+// received before a failure, or sends them as one message batch
+func drainQueue(logger *logging.Logger, con *console.Console, cfg *config.Config, batch bool) error {
+	if _, err := os.Stat(queueDirPath(cfg)); err != nil {
+		return fmt.Errorf("no request queue at %s, build it first", queueDirPath(cfg))
+	}
+	queue, err := claude.OpenQueue(logger, queueDirPath(cfg))
+	if err != nil {
+		return err
+	}
+	claudeClient, err := newClaudeClient(logger, cfg)
+	if err != nil {
+		return err
+	}
+
+	var drained int
+	var drainErr error
+	if batch {
+		if cfg.LLMProvider == llm.ProviderOpenAI {
+			return fmt.Errorf("message batches require llm_provider: anthropic")
+		}
+		drained, drainErr = claudeClient.DrainQueueBatch(queue, time.Duration(cfg.BatchPollInterval)*time.Second)
+	} else {
+		drained, drainErr = claudeClient.DrainQueue(queue, cfg.ParallelWorkers)
+	}
+	total, answered := queue.Counts()
+	con.Panel("Request queue drained", []console.Item{
+		{Label: "Answered now", Value: fmt.Sprintf("%d requests", drained)},
+		{Label: "Answered", Value: fmt.Sprintf("%d of %d requests", answered, total)},
+		{Label: "Total cost", Value: fmt.Sprintf("$%.4f", claudeClient.GetTotalCost())},
+	})
+	printKeyUsage(con, claudeClient)
+	if drainErr != nil {
+		con.Warn("Run queue drain again to send the remaining requests")
+		return drainErr
+	}
+	con.Note("Run the analysis as usual, it uses the answers of the queue.")
+	return nil
+}
+
+// queueStatus prints the progress of the queue
+func queueStatus(logger *logging.Logger, con *console.Console, cfg *config.Config) error {
+	if _, err := os.Stat(queueDirPath(cfg)); err != nil {
+		con.Note("No request queue at " + queueDirPath(cfg))
+		return nil
+	}
+	queue, err := claude.OpenQueue(logger, queueDirPath(cfg))
+	if err != nil {
+		return err
+	}
+	total, answered := queue.Counts()
+	con.Panel("Request queue", []console.Item{
+		{Label: "Answered", Value: fmt.Sprintf("%d of %d requests", answered, total)},
+		{Label: "Pending", Value: fmt.Sprintf("%d requests", total-answered)},
+		{Label: "Queue", Value: queue.Dir()},
+	})
+	return nil
+}
+
+// clearQueue removes the queue once its answers are no longer needed
+func clearQueue(logger *logging.Logger, con *console.Console, cfg *config.Config) error {
+	if _, err := os.Stat(queueDirPath(cfg)); err != nil {
+		con.Note("No request queue at " + queueDirPath(cfg))
+		return nil
+	}
+	queue, err := claude.OpenQueue(logger, queueDirPath(cfg))
+	if err != nil {
+		return err
+	}
+	if err := queue.Clear(); err != nil {
+		return err
+	}
+	con.Success("Removed the request queue %s", queueDirPath(cfg))
+	return nil
+}
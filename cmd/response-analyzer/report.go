@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+)
+
+// reportOptions holds the flags of the report subcommand
+type reportOptions struct {
+	configPath   *string
+	profile      *string
+	verbose      *bool
+	templatePath *string
+	outputPath   *string
+	language     *string
+	noColor      *bool
+	bundlePath   *string
+}
+
+// newReportFlags defines the flags of the report subcommand
+func newReportFlags() (*flag.FlagSet, *reportOptions) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	options := &reportOptions{
+		configPath:   flags.String("config", "", "Path to the configuration file"),
+		profile:      flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:      flags.Bool("verbose", false, "Enable verbose logging"),
+		templatePath: flags.String("template-path", "", "Report template to use instead of report_template_path"),
+		outputPath:   flags.String("output-path", "", "Report file to write instead of report_output_path"),
+		language:     flags.String("language", "", "Render the summaries generated in this language by the summarize subcommand"),
+		noColor:      flags.Bool("no-color", false, "Disable colored output"),
+		bundlePath:   flags.String("bundle", "", "Package the state and the rendered files with a manifest into this zip archive"),
+	}
+	flags.Usage = commandUsage("report", flags)
+	return flags, options
+}
+
+// runReport runs the report subcommand, which renders the report and the
+// appendix from the state file without reading the Excel file or calling the API
+func runReport(args []string) {
+	flags, options := newReportFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+
+	if *options.configPath == "" {
+		fmt.Println("Please provide a configuration file using the -config flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+	if *options.templatePath != "" {
+		cfg.ReportTemplatePath = *options.templatePath
+	}
+	if *options.outputPath != "" {
+		cfg.ReportOutputPath = *options.outputPath
+	}
+	if cfg.ReportTemplatePath == "" && cfg.AppendixFormat == "" && cfg.HTMLReportPath == "" && cfg.WideExportPath == "" && cfg.ExcelOutputPath == "" && cfg.AnnotatedExcelPath == "" &&
+		cfg.ReviewSampleSize == 0 && len(cfg.SummaryAudiences) == 0 {
+		fmt.Println("Nothing to render, configure report_template_path, appendix_format, html_report_path, wide_export_path, excel_output_path, annotated_excel_path, review_sample_size or summary_audiences, or pass -template-path")
+		os.Exit(1)
+	}
+
+	writer := output.NewWriter(logger)
+	result, err := writer.LoadState(cfg.StateFilePath)
+	if err != nil {
+		logger.Error("Failed to load state", "error", err)
+		fmt.Printf("Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Use the summaries of an additional language
+	if *options.language != "" && *options.language != cfg.OutputLanguage {
+		result, err = result.InLanguage(*options.language)
+		if err != nil {
+			fmt.Printf("Error: %v, generate them with the summarize subcommand\n", err)
+			os.Exit(1)
+		}
+		cfg = languageConfig(cfg, *options.language)
+		if *options.outputPath != "" {
+			cfg.ReportOutputPath = *options.outputPath
+		}
+	}
+
+	// Name the files after the run that produced the state
+	summary := &runSummary{startedAt: time.Now(), result: result}
+	renderReports(logger, cfg, writer, result, result.Run.RunID, summary)
+	if len(summary.artifacts) == 0 {
+		fmt.Println("Error: rendering failed, see the log messages above")
+		os.Exit(1)
+	}
+	summary.addArtifact("State", cfg.StateFilePath)
+	summary.bundle(logger, *options.bundlePath)
+	summary.deliver(logger, cfg)
+
+	var items []console.Item
+	if result.Run.RunID != "" {
+		items = append(items, console.Item{Label: "Run ID", Value: result.Run.RunID})
+	}
+	items = append(items, summary.artifacts...)
+	con.Panel("Rendered from state", items)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/embedding"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+)
+
+// searchOptions holds the flags of the search subcommand
+type searchOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	limit      *int
+}
+
+// newSearchFlags defines the flags of the search subcommand
+func newSearchFlags() (*flag.FlagSet, *searchOptions) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	options := &searchOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		limit:      flags.Int("limit", 10, "Maximum number of responses to show"),
+	}
+	flags.Usage = commandUsage("search", flags)
+	return flags, options
+}
+
+// runSearch runs the search subcommand, which lists the analyzed responses
+// most similar to a query text
+func runSearch(args []string) {
+	flags, options := newSearchFlags()
+	flags.Parse(args)
+	configPath, verbose, limit := options.configPath, options.verbose, options.limit
+
+	logger := logging.NewLogger(*verbose)
+
+	query := strings.TrimSpace(strings.Join(flags.Args(), " "))
+	if *configPath == "" || query == "" {
+		fmt.Println("Please provide a configuration file using the -config flag and a query text")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *configPath, *options.profile)
+
+	// Load the analyzed responses and their embeddings
+	writer := output.NewWriter(logger)
+	result, err := writer.LoadState(cfg.StateFilePath)
+	if err != nil {
+		logger.Error("Failed to load state", "error", err)
+		fmt.Printf("Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+
+	embedder, err := embedding.NewEmbedder(logger, cfg.EmbeddingProvider, cfg.EmbeddingModel, cfg.EmbeddingAPIKey)
+	if err != nil {
+		logger.Error("Failed to initialize embedder", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	neighbors, err := analysis.SearchResponses(result, embedder, query, *limit)
+	if err != nil {
+		logger.Error("Search failed", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Responses most similar to %q:\n\n", query)
+	for i, neighbor := range neighbors {
+		responseAnalysis, ok := result.ResponseAnalyses[neighbor.ID]
+		if !ok {
+			continue
+		}
+		themes := "none"
+		if len(responseAnalysis.Themes) > 0 {
+			themes = strings.Join(responseAnalysis.Themes, ", ")
+		}
+		fmt.Printf("%d. [%.3f] Row %d (%s)\n", i+1, neighbor.Similarity, responseAnalysis.Response.RowIndex, neighbor.ID)
+		fmt.Printf("   Themes: %s\n", themes)
+		fmt.Printf("   %s\n\n", responseAnalysis.Response.Text)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/update"
+)
+
+// selfUpdateOptions holds the flags of the self-update subcommand
+type selfUpdateOptions struct {
+	verbose *bool
+	check   *bool
+	force   *bool
+}
+
+// newSelfUpdateFlags defines the flags of the self-update subcommand
+func newSelfUpdateFlags() (*flag.FlagSet, *selfUpdateOptions) {
+	flags := flag.NewFlagSet("self-update", flag.ExitOnError)
+	options := &selfUpdateOptions{
+		verbose: flags.Bool("verbose", false, "Enable verbose logging"),
+		check:   flags.Bool("check", false, "Only check whether a newer release is available"),
+		force:   flags.Bool("force", false, "Install the latest release even if it is not newer"),
+	}
+	flags.Usage = commandUsage("self-update", flags)
+	return flags, options
+}
+
+// runSelfUpdate runs the self-update subcommand, which replaces the binary by
+// the latest GitHub release
+func runSelfUpdate(args []string) {
+	flags, options := newSelfUpdateFlags()
+	flags.Parse(args)
+	verbose, check, force := options.verbose, options.check, options.force
+
+	logger := logging.NewLogger(*verbose)
+	updater := update.NewUpdater(logger)
+
+	current := currentVersion()
+	release, err := updater.LatestRelease()
+	if err != nil {
+		logger.Error("Failed to check for updates", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Installed version: %s\n", current)
+	fmt.Printf("Latest release:    %s (%s)\n", release.TagName, release.HTMLURL)
+
+	newer := update.IsNewer(release.TagName, current)
+	if !newer && !*force {
+		fmt.Println("You are running the latest version.")
+		return
+	}
+	if *check {
+		fmt.Println("A newer release is available. Run 'self-update' to install it.")
+		return
+	}
+	if current == "dev" && !*force {
+		fmt.Println("This is a development build. Use -force to replace it by the latest release.")
+		os.Exit(1)
+	}
+
+	binary, err := updater.DownloadBinary(release)
+	if err != nil {
+		logger.Error("Failed to download update", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	path, err := update.ReplaceExecutable(binary)
+	if err != nil {
+		logger.Error("Failed to install update", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Updated %s to %s\n", path, release.TagName)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+)
+
+// showOptions holds the flags of the show subcommand
+type showOptions struct {
+	configPath *string
+	profile    *string
+	statePath  *string
+	verbose    *bool
+	theme      *string
+	quotes     *int
+	noColor    *bool
+}
+
+// newShowFlags defines the flags of the show subcommand
+func newShowFlags() (*flag.FlagSet, *showOptions) {
+	flags := flag.NewFlagSet("show", flag.ExitOnError)
+	options := &showOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		statePath:  flags.String("state-file", "", "State file to show instead of the one of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		theme:      flags.String("theme", "", "Only show this theme, with all of its responses"),
+		quotes:     flags.Int("quotes", 3, "Number of responses quoted per theme"),
+		noColor:    flags.Bool("no-color", false, "Disable colored output"),
+	}
+	flags.Usage = commandUsage("show", flags)
+	return flags, options
+}
+
+// runShow runs the show subcommand, which prints the results of a state
+// file in the terminal without any API calls
+func runShow(args []string) {
+	flags, options := newShowFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+
+	statePath := *options.statePath
+	if statePath == "" {
+		if *options.configPath == "" {
+			fmt.Println("Please provide a configuration file using the -config flag or a state file using the -state-file flag")
+			flags.Usage()
+			os.Exit(1)
+		}
+		statePath = loadConfig(logger, *options.configPath, *options.profile).StateFilePath
+	}
+
+	result, err := output.NewWriter(logger).LoadState(statePath)
+	if err != nil {
+		logger.Error("Failed to load state", "error", err)
+		fmt.Printf("Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *options.theme != "" {
+		theme, ok := findTheme(result.Themes, *options.theme)
+		if !ok {
+			fmt.Printf("Error: theme %q is not in the state file, it has: %s\n", *options.theme, strings.Join(result.Themes, ", "))
+			os.Exit(1)
+		}
+		showTheme(con, result, theme, -1)
+		return
+	}
+
+	con.Panel("State", showRunItems(result, statePath))
+
+	con.Heading("Themes")
+	con.Table([]console.Column{{Title: "Theme"}, {Title: "Responses", Right: true}, {Title: "Share", Right: true}}, themeRows(result))
+
+	if result.GlobalSummary != "" || result.Summary != "" {
+		con.Heading("Summary")
+		summary := result.GlobalSummary
+		if summary == "" {
+			summary = result.Summary
+		}
+		con.Println(summary)
+	}
+	if variants := result.SummaryVariantsToChoose(); len(variants) > 0 {
+		for i, variant := range variants[1:] {
+			con.Heading(fmt.Sprintf("Summary variant %d of %d", i+2, len(variants)))
+			con.Println(variant.Summary)
+		}
+	} else {
+		for i, variant := range result.SummaryVariants {
+			if variant.Selected {
+				con.Note(fmt.Sprintf("Variant %d of %d summaries, picked by the judge: %s", i+1, len(result.SummaryVariants), variant.Reason))
+			}
+		}
+	}
+
+	for _, theme := range themesByCount(result) {
+		showTheme(con, result, theme, *options.quotes)
+	}
+
+	if len(result.Warnings) > 0 {
+		con.Heading(fmt.Sprintf("%d warnings during the run", len(result.Warnings)+result.DroppedWarnings))
+		rows := [][]string{}
+		for _, warningCount := range result.WarningCounts() {
+			rows = append(rows, []string{warningCount.Message, fmt.Sprintf("%d", warningCount.Count)})
+		}
+		con.Table([]console.Column{{Title: "Warning", Max: 80}, {Title: "Count", Right: true}}, rows)
+	}
+	printTelemetry(con, result.Run.Telemetry)
+}
+
+// showRunItems lists the metadata of the run that produced a result
+func showRunItems(result *analysis.AnalysisResult, statePath string) []console.Item {
+	items := []console.Item{
+		{Label: "State", Value: statePath},
+		{Label: "Responses", Value: fmt.Sprintf("%d", len(result.ResponseAnalyses))},
+		{Label: "Themes", Value: fmt.Sprintf("%d", len(result.Themes))},
+	}
+	if result.ColumnTitle != "" {
+		items = append(items, console.Item{Label: "Column", Value: result.ColumnTitle})
+	}
+	run := result.Run
+	if run.RunID != "" {
+		items = append(items, console.Item{Label: "Run ID", Value: run.RunID})
+	}
+	if !run.StartedAt.IsZero() {
+		started := run.StartedAt.Format(time.DateTime)
+		if !run.FinishedAt.IsZero() {
+			started += fmt.Sprintf(" (%s)", run.FinishedAt.Sub(run.StartedAt).Round(time.Second))
+		}
+		items = append(items, console.Item{Label: "Started", Value: started})
+	} else if !result.AnalysisTimestamp.IsZero() {
+		items = append(items, console.Item{Label: "Analyzed", Value: result.AnalysisTimestamp.Format(time.DateTime)})
+	}
+	if run.Model != "" {
+		items = append(items, console.Item{Label: "Model", Value: run.Model})
+	}
+	if run.Profile != "" {
+		items = append(items, console.Item{Label: "Profile", Value: run.Profile})
+	}
+	if run.ThemeLibrary != "" {
+		items = append(items, console.Item{Label: "Theme library", Value: run.ThemeLibrary})
+	}
+	if run.UsageTag != "" {
+		items = append(items, console.Item{Label: "Usage tag", Value: run.UsageTag})
+	}
+	if len(run.PromptVersions) > 0 {
+		tasks := make([]string, 0, len(run.PromptVersions))
+		for task, version := range run.PromptVersions {
+			tasks = append(tasks, task+" "+version)
+		}
+		sort.Strings(tasks)
+		items = append(items, console.Item{Label: "Prompts", Value: strings.Join(tasks, ", ")})
+	}
+	return items
+}
+
+// showTheme prints the summary of a theme and quotes its responses. A
+// negative limit quotes all of them.
+func showTheme(con *console.Console, result *analysis.AnalysisResult, theme string, limit int) {
+	ids := topQuotes(result, theme)
+	con.Heading(fmt.Sprintf("%s (%d responses)", theme, len(result.ThemeAnalyses[theme].Responses)))
+	if description, ok := result.ThemeDescriptions[theme]; ok && description.Description != "" {
+		con.Note(description.Description)
+	}
+	if summary, ok := result.ThemeSummaries[theme]; ok {
+		con.Println(summary.Summary)
+		for _, idea := range summary.UniqueIdeas {
+			con.Println("  • " + idea)
+		}
+	}
+
+	if limit >= 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	if len(ids) == 0 {
+		return
+	}
+	rows := make([][]string, len(ids))
+	for i, id := range ids {
+		response := result.ResponseAnalyses[id].Response
+		rows[i] = []string{fmt.Sprintf("%d", response.RowIndex), response.Text}
+	}
+	con.Table([]console.Column{{Title: "Row", Right: true}, {Title: "Response", Max: 100}}, rows)
+}
+
+// topQuotes returns the responses of a theme in the order they are quoted:
+// responses assigned to fewer themes first, as they are most specific to
+// the theme, then those the consensus runs agreed on most
+func topQuotes(result *analysis.AnalysisResult, theme string) []string {
+	var ids []string
+	for _, id := range result.ThemeAnalyses[theme].Responses {
+		if responseAnalysis, ok := result.ResponseAnalyses[id]; ok && strings.TrimSpace(responseAnalysis.Response.Text) != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		a, b := result.ResponseAnalyses[ids[i]], result.ResponseAnalyses[ids[j]]
+		if len(a.Themes) != len(b.Themes) {
+			return len(a.Themes) < len(b.Themes)
+		}
+		if a.Agreement != b.Agreement {
+			return a.Agreement > b.Agreement
+		}
+		return a.Response.Before(b.Response)
+	})
+	return ids
+}
+
+// themesByCount returns the themes with responses, most frequent first
+func themesByCount(result *analysis.AnalysisResult) []string {
+	var themes []string
+	for _, theme := range result.Themes {
+		if len(result.ThemeAnalyses[theme].Responses) > 0 {
+			themes = append(themes, theme)
+		}
+	}
+	sort.SliceStable(themes, func(i, j int) bool {
+		return len(result.ThemeAnalyses[themes[i]].Responses) > len(result.ThemeAnalyses[themes[j]].Responses)
+	})
+	return themes
+}
+
+// findTheme returns the theme of the list matching a name, ignoring case
+func findTheme(themes []string, name string) (string, bool) {
+	index := slices.IndexFunc(themes, func(theme string) bool {
+		return strings.EqualFold(strings.TrimSpace(theme), strings.TrimSpace(name))
+	})
+	if index < 0 {
+		return "", false
+	}
+	return themes[index], true
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+)
+
+// stateCompactOptions holds the flags of the state compact subcommand
+type stateCompactOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	stripText  *bool
+	dryRun     *bool
+}
+
+// newStateCompactFlags defines the flags of the state compact subcommand
+func newStateCompactFlags() (*flag.FlagSet, *stateCompactOptions) {
+	flags := flag.NewFlagSet("state compact", flag.ExitOnError)
+	options := &stateCompactOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		stripText:  flags.Bool("strip-text", false, "Remove the response texts from the state"),
+		dryRun:     flags.Bool("dry-run", false, "Only report what would be removed"),
+	}
+	flags.Usage = commandUsage("state", flags)
+	return flags, options
+}
+
+// stateCheckOptions holds the flags of the state check subcommand
+type stateCheckOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+}
+
+// newStateCheckFlags defines the flags of the state check subcommand
+func newStateCheckFlags() (*flag.FlagSet, *stateCheckOptions) {
+	flags := flag.NewFlagSet("state check", flag.ExitOnError)
+	options := &stateCheckOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+	}
+	flags.Usage = commandUsage("state", flags)
+	return flags, options
+}
+
+// stateExportOptions holds the flags of the state export subcommand
+type stateExportOptions struct {
+	configPath *string
+	profile    *string
+	verbose    *bool
+	anonymized *bool
+	text       *string
+	outputPath *string
+}
+
+// newStateExportFlags defines the flags of the state export subcommand
+func newStateExportFlags() (*flag.FlagSet, *stateExportOptions) {
+	flags := flag.NewFlagSet("state export", flag.ExitOnError)
+	options := &stateExportOptions{
+		configPath: flags.String("config", "", "Path to the configuration file"),
+		profile:    flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:    flags.Bool("verbose", false, "Enable verbose logging"),
+		anonymized: flags.Bool("anonymized", false, "Renumber the responses and leave out what can identify the respondents"),
+		text:       flags.String("text", analysis.AnonymizedTextRemove, "Response texts of an anonymized state: "+strings.Join(analysis.AnonymizedTexts, " or ")),
+		outputPath: flags.String("output", "", "Path of the exported state file (default: next to the state file)"),
+	}
+	flags.Usage = commandUsage("state", flags)
+	return flags, options
+}
+
+// newStateFlags returns the flags of all state subcommands, for the
+// completions and the man page
+func newStateFlags() *flag.FlagSet {
+	flags, _ := newStateCompactFlags()
+	exportFlags, _ := newStateExportFlags()
+	exportFlags.VisitAll(func(f *flag.Flag) {
+		if flags.Lookup(f.Name) == nil {
+			flags.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	return flags
+}
+
+// runState runs the state subcommand, which maintains the state file
+func runState(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "check":
+			runStateCheck(args[1:])
+			return
+		case "compact":
+			runStateCompact(args[1:])
+			return
+		case "export":
+			runStateExport(args[1:])
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Usage: %s state check|compact|export -config config.yaml\n", os.Args[0])
+	os.Exit(1)
+}
+
+// runStateCheck reports the problems of the state file and what of it can
+// be recovered
+func runStateCheck(args []string) {
+	flags, options := newStateCheckFlags()
+	flags.Parse(args)
+
+	logger := logging.NewLogger(*options.verbose)
+
+	if *options.configPath == "" {
+		fmt.Println("Please provide a configuration file using the -config flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+
+	result, problems, err := output.NewWriter(logger).ReadState(cfg.StateFilePath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("State file %s: %d responses, %d themes, %d theme summaries\n",
+		cfg.StateFilePath, len(result.ResponseAnalyses), len(result.Themes), len(result.ThemeSummaries))
+	if len(problems) == 0 {
+		fmt.Println("No problems found")
+		return
+	}
+	fmt.Printf("%d problems found, the affected parts are skipped when the state is loaded:\n", len(problems))
+	for _, problem := range problems {
+		fmt.Printf("  %s\n", problem)
+	}
+	os.Exit(1)
+}
+
+// runStateCompact removes responses that are no longer in the input from
+// the state, stores repeated texts only once and optionally strips the texts
+func runStateCompact(args []string) {
+	flags, options := newStateCompactFlags()
+	flags.Parse(args)
+
+	logger := logging.NewLogger(*options.verbose)
+
+	if *options.configPath == "" {
+		fmt.Println("Please provide a configuration file using the -config flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+
+	writer := output.NewWriter(logger)
+	result, err := writer.LoadState(cfg.StateFilePath)
+	if err != nil {
+		logger.Error("Failed to load state", "error", err)
+		fmt.Printf("Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+	before, err := os.Stat(cfg.StateFilePath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Find the responses that are no longer in the input
+	excelReader, err := newExcelReader(logger, cfg)
+	if err != nil {
+		logger.Error("Failed to read responses", "error", err)
+		fmt.Printf("Error reading responses: %v\n", err)
+		os.Exit(1)
+	}
+	excelData, err := excelReader.ReadResponses(cfg.ExcelFilePath, cfg.ResponseColumn)
+	if err != nil {
+		logger.Error("Failed to read responses", "error", err)
+		fmt.Printf("Error reading responses: %v\n", err)
+		os.Exit(1)
+	}
+	responses := excelData.Responses
+	if cfg.Pseudonymize {
+		responses = analysis.PseudonymizeResponses(responses, cfg.PseudonymizationSalt)
+	}
+	orphaned := analysis.OrphanedResponses(result, responses)
+
+	if *options.dryRun {
+		fmt.Printf("%d of %d responses in the state are no longer in %s\n", len(orphaned), len(result.ResponseAnalyses), cfg.ExcelFilePath)
+		if len(orphaned) > 0 {
+			fmt.Printf("Would remove: %s\n", strings.Join(orphaned, ", "))
+		}
+		return
+	}
+
+	removed := analysis.RemoveResponses(result, orphaned)
+	writer.SetOmitResponseText(cfg.OmitResponseText || *options.stripText)
+	writer.SetStateEncoding(cfg.StateEncoding)
+	deduplicated, err := writer.SaveCompactState(result, cfg.StateFilePath)
+	if err != nil {
+		logger.Error("Failed to save state", "error", err)
+		fmt.Printf("Error saving state: %v\n", err)
+		os.Exit(1)
+	}
+	after, err := os.Stat(cfg.StateFilePath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Removed %d responses no longer in %s\n", len(removed), cfg.ExcelFilePath)
+	fmt.Printf("Stored %d repeated response texts only once\n", deduplicated)
+	if *options.stripText {
+		fmt.Println("Removed the response texts, later runs read them from the Excel file")
+	}
+	fmt.Printf("State file %s: %d -> %d bytes\n", cfg.StateFilePath, before.Size(), after.Size())
+	if !cfg.OmitResponseText && *options.stripText {
+		fmt.Println("Set omit_response_text in the configuration to keep the texts out of later runs")
+	}
+}
+
+// runStateExport writes a copy of the state for sharing, with -anonymized
+// one that keeps the themes, statistics and summaries but not the responses
+// as written
+func runStateExport(args []string) {
+	flags, options := newStateExportFlags()
+	flags.Parse(args)
+
+	logger := logging.NewLogger(*options.verbose)
+
+	if *options.configPath == "" {
+		fmt.Println("Please provide a configuration file using the -config flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+	if !slices.Contains(analysis.AnonymizedTexts, *options.text) {
+		fmt.Printf("Invalid -text %q, use %s\n", *options.text, strings.Join(analysis.AnonymizedTexts, " or "))
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+
+	outputPath := *options.outputPath
+	if outputPath == "" {
+		base := strings.TrimSuffix(cfg.StateFilePath, filepath.Ext(cfg.StateFilePath))
+		if *options.anonymized {
+			outputPath = base + "-anonymized.yaml"
+		} else {
+			outputPath = base + "-export.yaml"
+		}
+	}
+	if outputPath == cfg.StateFilePath {
+		fmt.Println("Error: the export would overwrite the state file, choose another -output")
+		os.Exit(1)
+	}
+
+	result, err := output.NewWriter(logger).LoadState(cfg.StateFilePath)
+	if err != nil {
+		logger.Error("Failed to load state", "error", err)
+		fmt.Printf("Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *options.anonymized {
+		var paraphrases map[string]string
+		if *options.text == analysis.AnonymizedTextParaphrase {
+			claudeClient, err := newClaudeClient(logger, cfg)
+			if err != nil {
+				logger.Error("Failed to create Claude client", "error", err)
+				fmt.Printf("Error creating Claude client: %v\n", err)
+				os.Exit(1)
+			}
+			analyzer := analysis.NewAnalyzer(logger, claudeClient)
+			analyzer.SetBatchSize(cfg.BatchSize)
+			paraphrases, err = analyzer.ParaphraseResponses(result)
+			if err != nil {
+				logger.Error("Failed to paraphrase responses", "error", err)
+				fmt.Printf("Error paraphrasing responses: %v\n", err)
+				os.Exit(1)
+			}
+			if len(paraphrases) < len(result.ResponseAnalyses) {
+				fmt.Printf("%d responses have no text in the state and are exported without one\n", len(result.ResponseAnalyses)-len(paraphrases))
+			}
+		}
+		result = analysis.Anonymize(result, paraphrases)
+	}
+
+	if err := output.NewWriter(logger).SaveState(result, outputPath); err != nil {
+		logger.Error("Failed to save state", "error", err)
+		fmt.Printf("Error saving state: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Exported %d responses and %d themes to %s\n", len(result.ResponseAnalyses), len(result.Themes), outputPath)
+	if *options.anonymized {
+		fmt.Printf("Response texts: %s\n", *options.text)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/console"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/output"
+	"github.com/oetiker/response-analyzer/pkg/validation"
+)
+
+// summarizeOptions holds the flags of the summarize subcommand
+type summarizeOptions struct {
+	configPath   *string
+	profile      *string
+	verbose      *bool
+	language     *string
+	templatePath *string
+	noColor      *bool
+}
+
+// newSummarizeFlags defines the flags of the summarize subcommand
+func newSummarizeFlags() (*flag.FlagSet, *summarizeOptions) {
+	flags := flag.NewFlagSet("summarize", flag.ExitOnError)
+	options := &summarizeOptions{
+		configPath:   flags.String("config", "", "Path to the configuration file"),
+		profile:      flags.String("profile", "", "Apply the settings of this profile of the configuration"),
+		verbose:      flags.Bool("verbose", false, "Enable verbose logging"),
+		language:     flags.String("language", "", "Output language of the summaries (en, de, de-ch, fr, it)"),
+		templatePath: flags.String("template-path", "", "Report template to use instead of report_template_path"),
+		noColor:      flags.Bool("no-color", false, "Disable colored output"),
+	}
+	flags.Usage = commandUsage("summarize", flags)
+	return flags, options
+}
+
+// runSummarize runs the summarize subcommand, which generates the summaries
+// of the state file in an additional language, keeping the classification
+func runSummarize(args []string) {
+	flags, options := newSummarizeFlags()
+	flags.Parse(args)
+
+	color := !*options.noColor && console.ColorSupported(os.Stdout)
+	con := console.New(os.Stdout, color)
+	logger := logging.NewLogger(*options.verbose)
+	logger.SetColor(color)
+	runID := analysis.NewRunID()
+	logger.SetRunID(runID)
+
+	if *options.configPath == "" || *options.language == "" {
+		fmt.Println("Please provide a configuration file using the -config flag and a language using the -language flag")
+		flags.Usage()
+		os.Exit(1)
+	}
+	language := *options.language
+	if !validation.ValidLanguage(language) {
+		fmt.Printf("Invalid language: %s (valid options: en, de, de-ch, fr, it)\n", language)
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(logger, *options.configPath, *options.profile)
+	if language == cfg.OutputLanguage {
+		fmt.Printf("%s is the output_language of the analysis, use skip_matching to regenerate its summaries\n", language)
+		os.Exit(1)
+	}
+	if *options.templatePath != "" {
+		cfg.ReportTemplatePath = *options.templatePath
+	}
+
+	writer := output.NewWriter(logger)
+	writer.SetOmitResponseText(cfg.OmitResponseText)
+	writer.SetStateEncoding(cfg.StateEncoding)
+	result, err := writer.LoadState(cfg.StateFilePath)
+	if err != nil {
+		logger.Error("Failed to load state", "error", err)
+		fmt.Printf("Error loading state: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The summaries are generated from the texts, which the state may omit
+	if err := restoreResponseTexts(logger, cfg, result); err != nil {
+		logger.Error("Failed to restore response texts", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	languageCfg := *cfg
+	languageCfg.OutputLanguage = language
+	claudeClient, err := newClaudeClient(logger, &languageCfg)
+	if err != nil {
+		logger.Error("Failed to initialize Claude client", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	claudeClient.SetThemeDescriptions(result.ThemeDescriptions)
+	claudeClient.SetSurveyQuestion(result.ColumnTitle)
+	analyzer := analysis.NewAnalyzer(logger, claudeClient)
+
+	summaries, err := analyzer.GenerateLanguageSummaries(result, cfg)
+	if err != nil {
+		logger.Error("Failed to generate summaries", "error", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if result.LanguageSummaries == nil {
+		result.LanguageSummaries = make(map[string]analysis.LanguageSummaries)
+	}
+	result.LanguageSummaries[language] = summaries
+
+	summary := &runSummary{client: claudeClient, result: result, startedAt: time.Now()}
+	if err := writer.SaveState(result, cfg.StateFilePath); err != nil {
+		logger.Error("Failed to save state", "error", err)
+		fmt.Printf("Error saving state: %v\n", err)
+		os.Exit(1)
+	}
+	summary.addArtifact("State", cfg.StateFilePath)
+
+	// Render the report and appendix with the new summaries
+	localized, _ := result.InLanguage(language)
+	renderReports(logger, languageConfig(cfg, language), writer, localized, result.Run.RunID, summary)
+	summary.deliver(logger, cfg)
+
+	items := []console.Item{
+		{Label: "Language", Value: language},
+		{Label: "Theme summaries", Value: fmt.Sprintf("%d", len(summaries.ThemeSummaries))},
+		{Label: "Total cost", Value: fmt.Sprintf("$%.4f", claudeClient.GetTotalCost())},
+	}
+	items = append(items, summary.artifacts...)
+	con.Panel("Summaries in "+language, items)
+}
+
+// summarizeReportLanguages generates the summaries in the languages of the
+// configured report outputs that differ from the output language, sharing
+// the classification of the result. Summaries kept from the previous run are
+// not generated again.
+func summarizeReportLanguages(logger *logging.Logger, cfg *config.Config, analyzer *analysis.Analyzer, client *claude.Client, result *analysis.AnalysisResult) error {
+	outputLanguage := client.OutputLanguage()
+	defer client.SetOutputLanguage(outputLanguage)
+	for _, report := range cfg.ReportOutputs {
+		language := report.Language
+		if language == "" || language == cfg.OutputLanguage {
+			continue
+		}
+		if _, ok := result.LanguageSummaries[language]; ok {
+			continue
+		}
+		logger.Info("Generating summaries for report", "language", language, "path", report.Path)
+		client.SetOutputLanguage(language)
+		summaries, err := analyzer.GenerateLanguageSummaries(result, cfg)
+		if err != nil {
+			return fmt.Errorf("failed to generate summaries in %s: %w", language, err)
+		}
+		if result.LanguageSummaries == nil {
+			result.LanguageSummaries = make(map[string]analysis.LanguageSummaries)
+		}
+		result.LanguageSummaries[language] = summaries
+	}
+	return nil
+}
+
+// restoreResponseTexts fills in response texts missing from the state from
+// the Excel file, matching the responses by ID and hash
+func restoreResponseTexts(logger *logging.Logger, cfg *config.Config, result *analysis.AnalysisResult) error {
+	missing := 0
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		if responseAnalysis.Response.Text == "" {
+			missing++
+		}
+	}
+	if missing == 0 {
+		return nil
+	}
+
+	logger.Info("Reading response texts missing from the state", "count", missing)
+	excelReader, err := newExcelReader(logger, cfg)
+	if err != nil {
+		return err
+	}
+	excelData, err := excelReader.ReadResponses(cfg.ExcelFilePath, cfg.ResponseColumn)
+	if err != nil {
+		return fmt.Errorf("failed to read responses: %w", err)
+	}
+	responses := excelData.Responses
+	if cfg.Pseudonymize {
+		responses = analysis.PseudonymizeResponses(responses, cfg.PseudonymizationSalt)
+	}
+	for _, response := range responses {
+		responseAnalysis, ok := result.ResponseAnalyses[response.ID]
+		if !ok || responseAnalysis.Response.Text != "" || responseAnalysis.Response.Hash != response.Hash {
+			continue
+		}
+		responseAnalysis.Response = response
+		result.ResponseAnalyses[response.ID] = responseAnalysis
+		missing--
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d responses of the state are missing or changed in %s", missing, cfg.ExcelFilePath)
+	}
+	return nil
+}
+
+// languageConfig returns a copy of the configuration writing the report and
+// appendix of an additional language next to those of the output language.
+// The wide export does not depend on the language and is left out.
+func languageConfig(cfg *config.Config, language string) *config.Config {
+	languageCfg := *cfg
+	languageCfg.OutputLanguage = language
+
+	reportPath := cfg.ReportOutputPath
+	if reportPath == "" {
+		reportPath = filepath.Join(filepath.Dir(cfg.StateFilePath), "report.txt")
+	}
+	languageCfg.ReportOutputPath = withSuffix(reportPath, language)
+	if cfg.AppendixFormat != "" {
+		languageCfg.AppendixOutputPath = withSuffix(appendixPath(cfg, ""), language)
+	}
+	if cfg.HTMLReportPath != "" {
+		languageCfg.HTMLReportPath = withSuffix(cfg.HTMLReportPath, language)
+	}
+	languageCfg.WideExportPath = ""
+	languageCfg.ReportOutputs = nil
+	if cfg.ExcelOutputPath != "" {
+		languageCfg.ExcelOutputPath = withSuffix(cfg.ExcelOutputPath, language)
+	}
+	return &languageCfg
+}
+
+// withSuffix inserts a suffix before the file extension
+func withSuffix(path, suffix string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + suffix + ext
+}
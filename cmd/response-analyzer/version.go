@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// pseudoVersionPattern matches the timestamp and commit of Go pseudo-versions
+// such as v0.0.0-20240101120000-abcdef123456
+var pseudoVersionPattern = regexp.MustCompile(`\d{14}-[0-9a-f]{12}`)
+
+// currentVersion returns the version of the binary, falling back to the
+// module version for binaries built with go install. Builds from a checkout
+// report "dev".
+func currentVersion() string {
+	if version == "dev" {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			moduleVersion := info.Main.Version
+			if moduleVersion != "" && moduleVersion != "(devel)" &&
+				!strings.Contains(moduleVersion, "+dirty") && !pseudoVersionPattern.MatchString(moduleVersion) {
+				return moduleVersion
+			}
+		}
+	}
+	return version
+}
+
+// versionInfo returns the version together with build information
+func versionInfo() string {
+	details := ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		settings := make(map[string]string)
+		for _, setting := range info.Settings {
+			settings[setting.Key] = setting.Value
+		}
+		if revision := settings["vcs.revision"]; revision != "" {
+			if len(revision) > 12 {
+				revision = revision[:12]
+			}
+			if settings["vcs.modified"] == "true" {
+				revision += "-dirty"
+			}
+			details += "commit " + revision + ", "
+		}
+		if built := settings["vcs.time"]; built != "" {
+			details += "built " + built + ", "
+		}
+	}
+	return fmt.Sprintf("response-analyzer %s (%s%s %s/%s)", currentVersion(), details, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
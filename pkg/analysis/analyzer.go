@@ -1,41 +1,231 @@
 package analysis
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/cluster"
 	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/embedding"
 	"github.com/oetiker/response-analyzer/pkg/excel"
 	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/topics"
 )
 
 // ResponseAnalysis represents the analysis of a response
 type ResponseAnalysis struct {
-	Response excel.Response `yaml:"response"`
-	Themes   []string       `yaml:"themes,omitempty"`
-	Analyzed time.Time      `yaml:"analyzed"`
+	Response      excel.Response      `yaml:"response"`
+	Themes        []string            `yaml:"themes,omitempty"`
+	ProposedTheme string              `yaml:"proposed_theme,omitempty"` // New theme proposed when no theme matched
+	Reason        string              `yaml:"reason,omitempty"`         // Justification of the themes by the model, with match_reasons
+	Agreement     float64             `yaml:"agreement,omitempty"`      // Share of consensus runs agreeing with the themes
+	Truncations   []claude.Truncation `yaml:"truncations,omitempty"`    // Tasks whose prompts contained only the start of the response
+	Corrected     bool                `yaml:"corrected,omitempty"`      // Themes corrected by hand with apply-audit
+	Locked        bool                `yaml:"locked,omitempty"`         // Themes confirmed by a reviewer, kept even if the response changes
+	Sentiment     *claude.Sentiment   `yaml:"sentiment,omitempty"`      // Sentiment of the response, with sentiment_enabled
+	Analyzed      time.Time           `yaml:"analyzed"`
+}
+
+// KeptFor reports whether the analysis is kept for the response as read now,
+// without matching it again: its text is unchanged or its themes are locked
+func (r ResponseAnalysis) KeptFor(response excel.Response) bool {
+	return r.Locked || r.Response.Hash == response.Hash
+}
+
+// reusedFor returns the kept analysis for the response as read now, as the
+// state may omit the response text. The sentiment of a changed response is
+// dropped, so it is classified again.
+func (r ResponseAnalysis) reusedFor(response excel.Response) ResponseAnalysis {
+	if r.Response.Hash != response.Hash {
+		r.Sentiment = nil
+	}
+	r.Response = response
+	return r
 }
 
 // ThemeAnalysis represents the analysis of a theme
 type ThemeAnalysis struct {
+	Theme     string                   `yaml:"theme"`
+	Responses []string                 `yaml:"response_ids,omitempty"`
+	Sentiment *claude.SentimentBalance `yaml:"sentiment,omitempty"` // Responses of the theme by sentiment, with sentiment_enabled
+}
+
+// ProposedTheme represents a new theme proposed during matching for responses
+// that did not fit any of the existing themes
+type ProposedTheme struct {
 	Theme     string   `yaml:"theme"`
+	Count     int      `yaml:"count"`
 	Responses []string `yaml:"response_ids,omitempty"`
 }
 
+// ThemeSplit represents a refined sub-structure for a theme that absorbed
+// too large a share of the responses
+type ThemeSplit struct {
+	Theme     string              `yaml:"theme"`
+	Share     float64             `yaml:"share"`
+	SubThemes []string            `yaml:"sub_themes"`
+	Responses map[string][]string `yaml:"response_ids,omitempty"` // Response IDs per sub-theme
+	Applied   bool                `yaml:"applied"`
+}
+
+// Citation represents a citation marker used in the global summary
+type Citation struct {
+	Marker     string `yaml:"marker"`                // Marker as it appears in the summary, e.g. T2 or R15
+	Theme      string `yaml:"theme,omitempty"`       // Cited theme
+	ResponseID string `yaml:"response_id,omitempty"` // Cited response
+}
+
+// VerificationReport represents the result of checking summary claims
+// against the underlying responses
+type VerificationReport struct {
+	Model       string                         `yaml:"model"`
+	Themes      map[string][]claude.ClaimCheck `yaml:"themes,omitempty"`
+	Global      []claude.ClaimCheck            `yaml:"global,omitempty"`
+	Unsupported int                            `yaml:"unsupported"`
+	Removed     bool                           `yaml:"removed"` // Whether unsupported claims were removed from the summaries
+}
+
+// ConsensusStats summarizes the disagreement between consensus runs
+type ConsensusStats struct {
+	Runs                  int                `yaml:"runs"`
+	DisagreementRate      float64            `yaml:"disagreement_rate"`       // Average share of runs disagreeing with the consensus
+	DisputedResponses     int                `yaml:"disputed_responses"`      // Responses where at least one run disagreed
+	ThemeDisagreementRate map[string]float64 `yaml:"theme_disagreement_rate"` // Disagreement rate of the responses assigned to each theme
+}
+
+// EmbeddingStore holds the embeddings computed for responses and themes, so
+// reruns and similarity searches don't require recomputation
+type EmbeddingStore struct {
+	Model      string                       `yaml:"model"`
+	Dimensions int                          `yaml:"dimensions"`
+	Responses  map[string]ResponseEmbedding `yaml:"responses,omitempty"`
+	Themes     map[string][]float32         `yaml:"themes,omitempty"`
+}
+
+// ResponseEmbedding represents the embedding of a response text
+type ResponseEmbedding struct {
+	Hash   string    `yaml:"hash"` // Hash of the embedded response text
+	Vector []float32 `yaml:"vector,flow"`
+}
+
+// Outlier represents a response that is semantically far from all themes
+type Outlier struct {
+	ResponseID   string  `yaml:"response_id"`
+	Similarity   float64 `yaml:"similarity"`    // Similarity to the closest theme
+	NearestTheme string  `yaml:"nearest_theme"` // Theme closest to the response
+}
+
+// Cluster represents a group of similar responses found by clustering their
+// embeddings, independent of the themes
+type Cluster struct {
+	Label         string   `yaml:"label"`
+	Size          int      `yaml:"size"`
+	Responses     []string `yaml:"responses"`       // IDs of the responses in the cluster
+	TopTheme      string   `yaml:"top_theme"`       // Theme most responses of the cluster are assigned to
+	TopThemeShare float64  `yaml:"top_theme_share"` // Share of the cluster assigned to the top theme
+}
+
+// Topic represents a topic of the classical (non-LLM) topic model baseline
+type Topic struct {
+	Words         []string `yaml:"words,flow"` // Most characteristic words of the topic
+	Size          int      `yaml:"size"`
+	Responses     []string `yaml:"responses"`       // IDs of the responses dominated by the topic
+	TopTheme      string   `yaml:"top_theme"`       // Theme most responses of the topic are assigned to
+	TopThemeShare float64  `yaml:"top_theme_share"` // Share of the topic assigned to the top theme
+}
+
+// RunMetadata describes the run that produced an analysis result
+type RunMetadata struct {
+	RunID      string    `yaml:"run_id"`
+	StartedAt  time.Time `yaml:"started_at"`
+	FinishedAt time.Time `yaml:"finished_at,omitempty"`
+	Model      string    `yaml:"model,omitempty"`
+	UsageTag   string    `yaml:"usage_tag,omitempty"`
+	Profile    string    `yaml:"profile,omitempty"` // Profile of the configuration applied
+
+	// Shared theme library the themes extend, as <path or URL>@<version>
+	ThemeLibrary string `yaml:"theme_library,omitempty"`
+
+	// Prompt version per task of the requests of the run, see claude.PromptVersion
+	PromptVersions map[string]string `yaml:"prompt_versions,omitempty"`
+
+	// Requests and time spent per task
+	Telemetry []claude.TaskTelemetry `yaml:"telemetry,omitempty"`
+
+	// Tokens and cost spent on the API, and what the cache and reusing the
+	// previous result saved
+	Tokens  int          `yaml:"tokens,omitempty"`
+	Cost    float64      `yaml:"cost,omitempty"`
+	Savings *CostSavings `yaml:"savings,omitempty"`
+
+	// Hashes of the settings and inputs the result was computed from
+	Fingerprint Fingerprint `yaml:"fingerprint,omitempty"`
+}
+
 // AnalysisResult represents the result of the analysis
 type AnalysisResult struct {
-	Themes            []string                       `yaml:"themes"`
-	ResponseAnalyses  map[string]ResponseAnalysis    `yaml:"response_analyses"`
-	ThemeAnalyses     map[string]ThemeAnalysis       `yaml:"theme_analyses"`
-	ThemeSummaries    map[string]claude.ThemeSummary `yaml:"theme_summaries,omitempty"`
-	Summary           string                         `yaml:"summary,omitempty"`        // Global summary (for backward compatibility)
-	GlobalSummary     string                         `yaml:"global_summary,omitempty"` // Same as Summary, new name for clarity
-	UniqueIdeas       []string                       `yaml:"unique_ideas,omitempty"`   // Kept for backward compatibility
-	AnalysisTimestamp time.Time                      `yaml:"analysis_timestamp"`
-	ColumnTitle       string                         `yaml:"column_title,omitempty"` // Title of the column containing responses
+	Themes            []string                           `yaml:"themes"`
+	ResponseAnalyses  map[string]ResponseAnalysis        `yaml:"response_analyses"`
+	ThemeAnalyses     map[string]ThemeAnalysis           `yaml:"theme_analyses"`
+	ThemeSummaries    map[string]claude.ThemeSummary     `yaml:"theme_summaries,omitempty"`
+	Summary           string                             `yaml:"summary,omitempty"`        // Global summary (for backward compatibility)
+	GlobalSummary     string                             `yaml:"global_summary,omitempty"` // Same as Summary, new name for clarity
+	UniqueIdeas       []string                           `yaml:"unique_ideas,omitempty"`   // Kept for backward compatibility
+	AnalysisTimestamp time.Time                          `yaml:"analysis_timestamp"`
+	ColumnTitle       string                             `yaml:"column_title,omitempty"`       // Title of the column containing responses
+	Survey            *config.Survey                     `yaml:"survey,omitempty"`             // Description of the survey for reports
+	Run               RunMetadata                        `yaml:"run,omitempty"`                // Metadata of the run that produced this result
+	ProposedThemes    []ProposedTheme                    `yaml:"proposed_themes,omitempty"`    // New themes proposed for unmatched responses
+	ThemeSplits       []ThemeSplit                       `yaml:"theme_splits,omitempty"`       // Sub-structures for over-broad themes
+	ThemeDescriptions map[string]claude.ThemeDescription `yaml:"theme_descriptions,omitempty"` // Description and inclusion criteria per theme
+	SummaryCitations  []Citation                         `yaml:"summary_citations,omitempty"`  // Citations used in the global summary
+	SummaryVariants   []SummaryVariant                   `yaml:"summary_variants,omitempty"`   // Variants of the global summary, with summary_variants
+	AudienceSummaries []AudienceSummary                  `yaml:"audience_summaries,omitempty"` // Global summaries for the configured audiences
+	Verification      *VerificationReport                `yaml:"verification,omitempty"`       // Claim verification of the summaries
+	Consensus         *ConsensusStats                    `yaml:"consensus,omitempty"`          // Disagreement between consensus runs
+	Embeddings        *EmbeddingStore                    `yaml:"embeddings,omitempty"`         // Embeddings of responses and themes
+	Outliers          []Outlier                          `yaml:"outliers,omitempty"`           // Responses far from all themes
+	Clusters          []Cluster                          `yaml:"clusters,omitempty"`           // Unsupervised clusters of the responses
+	Topics            []Topic                            `yaml:"topics,omitempty"`             // Classical topic model baseline
+	TimeSeries        *TimeSeries                        `yaml:"time_series,omitempty"`        // Theme frequencies per week or month
+	Ratings           *RatingStats                       `yaml:"ratings,omitempty"`            // Ratings per theme and themes per rating band
+	Corpus            *CorpusStats                       `yaml:"corpus,omitempty"`             // Languages and lengths of the responses
+	FailedResponses   []FailedResponse                   `yaml:"failed_responses,omitempty"`   // Responses of failed batches, matched again by the next run
+	RollingWindows    []RollingWindow                    `yaml:"rolling_windows,omitempty"`    // Theme frequencies of the last days of the feedback
+	SummaryBaseline   *SummaryBaseline                   `yaml:"summary_baseline,omitempty"`   // Theme frequencies the summaries were generated from
+
+	// Non-fatal issues logged during the run, such as skipped rows or
+	// responses a batch answer left out
+	Warnings        []logging.Warning `yaml:"warnings,omitempty"`
+	DroppedWarnings int               `yaml:"dropped_warnings,omitempty"` // Warnings left out because there were too many
+
+	// Summaries in additional output languages, keyed by language
+	LanguageSummaries map[string]LanguageSummaries `yaml:"language_summaries,omitempty"`
+
+	// Data subject deletion
+	ForgottenResponses  []ForgottenResponse `yaml:"forgotten_responses,omitempty"`   // Responses removed on request, skipped in later runs
+	StaleThemeSummaries []string            `yaml:"stale_theme_summaries,omitempty"` // Themes whose summary was built from forgotten or corrected responses
+	StaleGlobalSummary  bool                `yaml:"stale_global_summary,omitempty"`  // The global summary was built from forgotten or corrected responses
+}
+
+// NewRunID generates an identifier for a single run of the analyzer.
+// It combines a timestamp with a random suffix so IDs sort chronologically.
+func NewRunID() string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return time.Now().Format("20060102-150405")
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
 }
 
 // Analyzer handles the analysis of responses
@@ -45,6 +235,22 @@ type Analyzer struct {
 	batchSize       int
 	parallelWorkers int
 	useParallel     bool
+	autoParallel    bool // Tune the number of workers up to parallelWorkers
+
+	maxFailedResponsesPct float64          // Share of responses in percent whose batches may fail
+	failedResponses       []FailedResponse // Responses of failed batches, left for the next run
+	embedder              embedding.Embedder
+
+	pause              *PauseControl         // Pauses the run between API requests
+	onCheckpoint       func(*AnalysisResult) // Saves the progress when the run pauses or batches complete
+	checkpointBase     *AnalysisResult       // Result in progress, providing themes and descriptions
+	collectingBatch    bool                  // Matching only collects the requests of a message batch, its results are placeholders
+	checkpointPrevious *AnalysisResult       // Result of the previous run
+	checkpointBatches  int                   // Completed batches between checkpoints, negative disables them
+	checkpointMutex    sync.Mutex            // Keeps checkpoints of concurrent batches in order
+	completedBatches   int                   // Batches completed since the last checkpoint
+
+	ctx context.Context // Stops the run between batches once cancelled, e.g. on Ctrl+C
 }
 
 // NewAnalyzer creates a new Analyzer instance
@@ -55,9 +261,16 @@ func NewAnalyzer(logger *logging.Logger, claudeClient *claude.Client) *Analyzer
 		batchSize:       10,   // Default batch size
 		parallelWorkers: 4,    // Default number of workers
 		useParallel:     true, // Default to using parallel processing
+		ctx:             context.Background(),
 	}
 }
 
+// SetContext sets the context of the run. Once it is cancelled, no further
+// batches are started and the responses analyzed so far are checkpointed.
+func (a *Analyzer) SetContext(ctx context.Context) {
+	a.ctx = ctx
+}
+
 // SetBatchSize sets the batch size for processing responses
 func (a *Analyzer) SetBatchSize(batchSize int) {
 	if batchSize > 0 {
@@ -77,6 +290,18 @@ func (a *Analyzer) SetUseParallel(useParallel bool) {
 	a.useParallel = useParallel
 }
 
+// SetAutoParallel sets whether the number of parallel workers is tuned
+// during the run, starting low and growing up to the configured number as
+// long as the API keeps up
+func (a *Analyzer) SetAutoParallel(autoParallel bool) {
+	a.autoParallel = autoParallel
+}
+
+// SetEmbedder sets the embedder used to compute response and theme embeddings
+func (a *Analyzer) SetEmbedder(embedder embedding.Embedder) {
+	a.embedder = embedder
+}
+
 // IdentifyThemes identifies themes in responses
 func (a *Analyzer) IdentifyThemes(responses []excel.Response, contextPrompt string) ([]string, error) {
 	a.logger.Info("Identifying themes in responses", "count", len(responses))
@@ -97,6 +322,102 @@ func (a *Analyzer) IdentifyThemes(responses []excel.Response, contextPrompt stri
 	return themes, nil
 }
 
+// matchingChanges returns what the matching of the previous run depended on
+// and changed since: the themes, their descriptions or the matching settings.
+// Sub-themes of splits applied by the previous run count as the theme they
+// were split from.
+func matchingChanges(cfg *config.Config, themes []string, descriptions map[string]claude.ThemeDescription, previous *AnalysisResult) []string {
+	if previous == nil || len(previous.ResponseAnalyses) == 0 {
+		return nil
+	}
+	var changes []string
+	var applied []ThemeSplit
+	for _, split := range previous.ThemeSplits {
+		if split.Applied {
+			applied = append(applied, split)
+		}
+	}
+	parents := splitParents(applied)
+	if !slices.Equal(slices.Sorted(slices.Values(mergeThemes(themes, parents))), slices.Sorted(slices.Values(mergeThemes(previous.Themes, parents)))) {
+		changes = append(changes, "themes")
+	}
+	for _, theme := range themes {
+		if slices.Contains(previous.Themes, theme) && descriptions[theme] != previous.ThemeDescriptions[theme] {
+			changes = append(changes, "theme_descriptions")
+			break
+		}
+	}
+	return append(changes, changedSettings(cfg, previous, matchingSettings)...)
+}
+
+// GenerateThemeDescriptions generates a description and inclusion criteria for
+// each theme. Descriptions already known for a theme are reused.
+func (a *Analyzer) GenerateThemeDescriptions(responses []excel.Response, themes []string, contextPrompt string, known map[string]claude.ThemeDescription) (map[string]claude.ThemeDescription, error) {
+	result := make(map[string]claude.ThemeDescription)
+	var missing []string
+	for _, theme := range themes {
+		if description, ok := known[theme]; ok && description.Description != "" {
+			result[theme] = description
+		} else {
+			missing = append(missing, theme)
+		}
+	}
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	a.logger.Info("Generating theme descriptions", "themes", len(missing))
+
+	// Extract response texts
+	responseTexts := make([]string, len(responses))
+	for i, response := range responses {
+		responseTexts[i] = response.Text
+	}
+
+	descriptions, err := a.claudeClient.GenerateThemeDescriptions(missing, responseTexts, contextPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate theme descriptions: %w", err)
+	}
+	for theme, description := range descriptions {
+		result[theme] = description
+	}
+
+	a.logger.Info("Generated theme descriptions", "count", len(descriptions))
+	return result, nil
+}
+
+// ReusePreviousAnalyses keeps the previous analyses of unchanged responses
+// without matching anything. New and changed responses are left out.
+func (a *Analyzer) ReusePreviousAnalyses(responses []excel.Response, previousAnalyses map[string]ResponseAnalysis) map[string]ResponseAnalysis {
+	result := make(map[string]ResponseAnalysis)
+	skipped := 0
+	for _, response := range responses {
+		previousAnalysis, ok := previousAnalyses[response.ID]
+		if !ok || !previousAnalysis.KeptFor(response) {
+			skipped++
+			continue
+		}
+		result[response.ID] = previousAnalysis.reusedFor(response)
+	}
+	if skipped > 0 {
+		a.logger.Warn("Leaving out new or changed responses as matching is skipped", "count", skipped)
+	}
+	return result
+}
+
+// changedLockedResponses returns the IDs of the responses whose themes are
+// locked although their text changed, in input order
+func changedLockedResponses(responses []excel.Response, previousAnalyses map[string]ResponseAnalysis) []string {
+	var changed []string
+	for _, response := range responses {
+		if previousAnalysis, ok := previousAnalyses[response.ID]; ok && previousAnalysis.Locked && previousAnalysis.Response.Hash != response.Hash {
+			changed = append(changed, response.ID)
+		}
+	}
+	return changed
+}
+
 // MatchResponsesToThemes matches responses to themes
 func (a *Analyzer) MatchResponsesToThemes(responses []excel.Response, themes []string, contextPrompt string, previousAnalyses map[string]ResponseAnalysis) (map[string]ResponseAnalysis, error) {
 	a.logger.Info("Matching responses to themes", "responses", len(responses), "themes", len(themes))
@@ -107,10 +428,11 @@ func (a *Analyzer) MatchResponsesToThemes(responses []excel.Response, themes []s
 	// Reuse previous analyses for unchanged responses
 	newResponses := []excel.Response{}
 	for _, response := range responses {
-		if previousAnalysis, ok := previousAnalyses[response.ID]; ok && previousAnalysis.Response.Hash == response.Hash {
-			// Response hasn't changed, reuse previous analysis
+		if previousAnalysis, ok := previousAnalyses[response.ID]; ok && previousAnalysis.KeptFor(response) {
+			// Response hasn't changed, reuse previous analysis with the
+			// current response, as the state may omit the response text
 			a.logger.Debug("Reusing previous analysis", "response_id", response.ID)
-			result[response.ID] = previousAnalysis
+			result[response.ID] = previousAnalysis.reusedFor(response)
 		} else {
 			// Response is new or has changed, analyze it
 			newResponses = append(newResponses, response)
@@ -141,26 +463,61 @@ func (a *Analyzer) MatchResponsesToThemes(responses []excel.Response, themes []s
 		}
 	}
 
-	// Match responses to themes in batches
-	matchedThemesBatch, err := a.claudeClient.MatchResponsesToThemesBatch(responseTexts, themes, contextPrompt, batchSize)
-	if err != nil {
-		return nil, fmt.Errorf("failed to match responses to themes in batch: %w", err)
+	// Match responses to themes in batches, pausing between them if requested.
+	// Failed batches get empty placeholders, their responses are left out.
+	failures := a.newMatchFailures(len(newResponses))
+	var matchedThemesBatch []claude.MatchResult
+	matchedSoFar := func() map[string]ResponseAnalysis {
+		matched := newResponses[:len(matchedThemesBatch)]
+		analyses := a.matchedSoFar(result, matched, matchedThemesBatch)
+		for _, response := range matched {
+			if failures.failed(response.ID) {
+				delete(analyses, response.ID)
+			}
+		}
+		return analyses
 	}
+	for i := 0; i < len(responseTexts); i += batchSize {
+		a.waitIfPaused(matchedSoFar)
+		if err := a.interrupted("matching", matchedSoFar); err != nil {
+			return nil, err
+		}
+		end := min(i+batchSize, len(responseTexts))
+		batchResults, err := a.claudeClient.MatchResponsesToThemesBatch(responseTexts[i:end], themes, contextPrompt, batchSize)
+		if err != nil {
+			if err := a.interrupted("matching", matchedSoFar); err != nil {
+				return nil, err
+			}
+			if err := failures.add(a.logger, newResponses[i:end], err); err != nil {
+				return nil, fmt.Errorf("failed to match responses to themes in batch: %w", err)
+			}
+			batchResults = make([]claude.MatchResult, end-i)
+		}
+		matchedThemesBatch = append(matchedThemesBatch, batchResults...)
+		a.checkpointBatch(matchedSoFar)
+	}
+	failures.keep(a)
 
 	// Create response analyses from batch results
 	for i, response := range newResponses {
-		var matchedThemes []string
+		if failures.failed(response.ID) {
+			continue
+		}
+		var match claude.MatchResult
 		if i < len(matchedThemesBatch) {
-			matchedThemes = matchedThemesBatch[i]
+			match = matchedThemesBatch[i]
 		} else {
-			matchedThemes = []string{}
+			match.Themes = []string{}
 		}
 
 		// Create response analysis
 		analysis := ResponseAnalysis{
-			Response: response,
-			Themes:   matchedThemes,
-			Analyzed: time.Now(),
+			Response:      response,
+			Themes:        match.Themes,
+			ProposedTheme: match.ProposedTheme,
+			Reason:        match.Reason,
+			Agreement:     match.Agreement,
+			Analyzed:      time.Now(),
 		}
 
 		// Add to result
@@ -182,10 +539,11 @@ func (a *Analyzer) MatchResponsesToThemesParallel(responses []excel.Response, th
 	// Reuse previous analyses for unchanged responses
 	newResponses := []excel.Response{}
 	for _, response := range responses {
-		if previousAnalysis, ok := previousAnalyses[response.ID]; ok && previousAnalysis.Response.Hash == response.Hash {
-			// Response hasn't changed, reuse previous analysis
+		if previousAnalysis, ok := previousAnalyses[response.ID]; ok && previousAnalysis.KeptFor(response) {
+			// Response hasn't changed, reuse previous analysis with the
+			// current response, as the state may omit the response text
 			a.logger.Debug("Reusing previous analysis", "response_id", response.ID)
-			result[response.ID] = previousAnalysis
+			result[response.ID] = previousAnalysis.reusedFor(response)
 		} else {
 			// Response is new or has changed, analyze it
 			newResponses = append(newResponses, response)
@@ -230,7 +588,8 @@ func (a *Analyzer) MatchResponsesToThemesParallel(responses []excel.Response, th
 	// Process batches in parallel
 	var wg sync.WaitGroup
 	errorsChan := make(chan error, len(batches))
-	semaphore := make(chan struct{}, numWorkers) // Limit concurrent workers
+	pool := newWorkerPool(a.logger, numWorkers, a.autoParallel) // Limit concurrent workers, tagging their log lines
+	failures := a.newMatchFailures(len(newResponses))
 
 	for batchIndex, batch := range batches {
 		wg.Add(1)
@@ -238,11 +597,23 @@ func (a *Analyzer) MatchResponsesToThemesParallel(responses []excel.Response, th
 		go func(index int, batchResponses []excel.Response) {
 			defer wg.Done()
 
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+			// Acquire a worker, handing back the requests it made for tuning
+			var stats claude.RequestStats
+			workerID, started := pool.acquire()
+			defer func() { pool.release(workerID, started, stats) }()
+			logger := a.logger.Worker(fmt.Sprintf("worker %d", workerID))
+			if failures.stopped() || a.ctx.Err() != nil {
+				return // Too many responses failed or the run was cancelled
+			}
+
+			matchedSoFar := func() map[string]ResponseAnalysis {
+				resultMutex.Lock()
+				defer resultMutex.Unlock()
+				return maps.Clone(result)
+			}
+			a.waitIfPaused(matchedSoFar)
 
-			a.logger.Debug("Processing batch", "batch", index, "size", len(batchResponses))
+			logger.Debug("Processing batch", "batch", index, "size", len(batchResponses))
 
 			// Extract response texts
 			responseTexts := make([]string, len(batchResponses))
@@ -251,27 +622,35 @@ func (a *Analyzer) MatchResponsesToThemesParallel(responses []excel.Response, th
 			}
 
 			// Match batch to themes
-			matchedThemesBatch, err := a.claudeClient.MatchResponsesToThemesBatch(responseTexts, themes, contextPrompt, len(batchResponses))
+			matchedThemesBatch, err := a.claudeClient.MatchResponsesToThemesBatchWithStats(responseTexts, themes, contextPrompt, len(batchResponses), &stats)
 			if err != nil {
-				errorsChan <- fmt.Errorf("failed to process batch %d: %w", index, err)
+				if a.ctx.Err() != nil {
+					return // Cancelled, the batch is matched again by the next run
+				}
+				if err := failures.add(logger, batchResponses, err); err != nil {
+					errorsChan <- fmt.Errorf("failed to process batch %d: %w", index, err)
+				}
 				return
 			}
 
 			// Create response analyses from batch results
 			batchResults := make(map[string]ResponseAnalysis)
 			for i, response := range batchResponses {
-				var matchedThemes []string
+				var match claude.MatchResult
 				if i < len(matchedThemesBatch) {
-					matchedThemes = matchedThemesBatch[i]
+					match = matchedThemesBatch[i]
 				} else {
-					matchedThemes = []string{}
+					match.Themes = []string{}
 				}
 
 				// Create response analysis
 				analysis := ResponseAnalysis{
-					Response: response,
-					Themes:   matchedThemes,
-					Analyzed: time.Now(),
+					Response:      response,
+					Themes:        match.Themes,
+					ProposedTheme: match.ProposedTheme,
+					Reason:        match.Reason,
+					Agreement:     match.Agreement,
+					Analyzed:      time.Now(),
 				}
 
 				batchResults[response.ID] = analysis
@@ -283,14 +662,29 @@ func (a *Analyzer) MatchResponsesToThemesParallel(responses []excel.Response, th
 				result[id] = analysis
 			}
 			resultMutex.Unlock()
-
-			a.logger.Debug("Batch processed", "batch", index, "size", len(batchResponses))
+			a.checkpointBatch(matchedSoFar)
+
+			logger.Debug("Batch processed",
+				"batch", index,
+				"size", len(batchResponses),
+				"latency", time.Since(started).Round(time.Millisecond),
+				"api", stats.Latency.Round(time.Millisecond),
+				"wait", stats.Wait.Round(time.Millisecond),
+				"parse", stats.Parse.Round(time.Microsecond),
+				"requests", stats.Requests,
+				"retries", stats.Retries,
+				"cache_hits", stats.CacheHits,
+				"tokens", stats.Tokens())
 		}(batchIndex, batch)
 	}
 
 	// Wait for all batches to complete
 	wg.Wait()
 	close(errorsChan)
+	pool.report()
+	if err := a.interrupted("matching", func() map[string]ResponseAnalysis { return result }); err != nil {
+		return nil, err
+	}
 
 	// Check for errors
 	if len(errorsChan) > 0 {
@@ -300,6 +694,7 @@ func (a *Analyzer) MatchResponsesToThemesParallel(responses []excel.Response, th
 		}
 		return nil, fmt.Errorf("errors occurred during parallel processing: %s", strings.Join(errMsgs, "; "))
 	}
+	failures.keep(a)
 
 	a.logger.Info("Matched responses to themes in parallel", "count", len(result))
 	return result, nil
@@ -320,11 +715,17 @@ func (a *Analyzer) BuildThemeAnalyses(responseAnalyses map[string]ResponseAnalys
 		}
 	}
 
-	// Add responses to themes
+	// Add responses to themes, counting their sentiments
 	for responseID, analysis := range responseAnalyses {
 		for _, theme := range analysis.Themes {
 			if themeAnalysis, ok := result[theme]; ok {
 				themeAnalysis.Responses = append(themeAnalysis.Responses, responseID)
+				if analysis.Sentiment != nil {
+					if themeAnalysis.Sentiment == nil {
+						themeAnalysis.Sentiment = &claude.SentimentBalance{}
+					}
+					themeAnalysis.Sentiment.Add(*analysis.Sentiment)
+				}
 				result[theme] = themeAnalysis
 			}
 		}
@@ -334,12 +735,645 @@ func (a *Analyzer) BuildThemeAnalyses(responseAnalyses map[string]ResponseAnalys
 	return result
 }
 
-// GenerateThemeSummaries generates summaries for each theme and extracts unique ideas
-func (a *Analyzer) GenerateThemeSummaries(responseAnalyses map[string]ResponseAnalysis, themeAnalyses map[string]ThemeAnalysis, themeSummaryPrompt string) (map[string]claude.ThemeSummary, error) {
-	a.logger.Info("Generating theme summaries")
+// CollectProposedThemes collects the new themes proposed for unmatched responses.
+// Proposals differing only in case are merged; the result is ordered by count.
+func (a *Analyzer) CollectProposedThemes(responseAnalyses map[string]ResponseAnalysis) []ProposedTheme {
+	byName := make(map[string]*ProposedTheme)
+	var order []string
+	for responseID, analysis := range responseAnalyses {
+		if analysis.ProposedTheme == "" || len(analysis.Themes) > 0 {
+			continue
+		}
+		key := strings.ToLower(analysis.ProposedTheme)
+		proposal, ok := byName[key]
+		if !ok {
+			proposal = &ProposedTheme{Theme: analysis.ProposedTheme}
+			byName[key] = proposal
+			order = append(order, key)
+		}
+		proposal.Count++
+		proposal.Responses = append(proposal.Responses, responseID)
+	}
 
-	// Initialize result
-	result := make(map[string]claude.ThemeSummary)
+	proposals := make([]ProposedTheme, 0, len(order))
+	for _, key := range order {
+		sort.Strings(byName[key].Responses)
+		proposals = append(proposals, *byName[key])
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		if proposals[i].Count != proposals[j].Count {
+			return proposals[i].Count > proposals[j].Count
+		}
+		return proposals[i].Theme < proposals[j].Theme
+	})
+
+	if len(proposals) > 0 {
+		a.logger.Info("Collected proposed themes", "count", len(proposals))
+	}
+	return proposals
+}
+
+// SplitBroadThemes runs a sub-theme identification pass over the responses of
+// every theme holding more than the given share of all responses. If apply is
+// set, the theme is replaced by its sub-themes (named "Theme / Sub-theme") and
+// the theme analyses are rebuilt.
+func (a *Analyzer) SplitBroadThemes(result *AnalysisResult, threshold float64, contextPrompt string, apply bool) error {
+	totalResponses := len(result.ResponseAnalyses)
+	if threshold <= 0 || totalResponses == 0 {
+		return nil
+	}
+
+	var splits []ThemeSplit
+	for _, theme := range result.Themes {
+		themeAnalysis, ok := result.ThemeAnalyses[theme]
+		if !ok {
+			continue
+		}
+		share := float64(len(themeAnalysis.Responses)) / float64(totalResponses)
+		if share <= threshold || len(themeAnalysis.Responses) < 2 {
+			continue
+		}
+
+		a.logger.Info("Theme exceeds share threshold, identifying sub-themes",
+			"theme", theme,
+			"share", fmt.Sprintf("%.1f%%", share*100),
+			"threshold", fmt.Sprintf("%.1f%%", threshold*100))
+
+		// Collect the responses of this theme in a stable order
+		responseIDs := append([]string(nil), themeAnalysis.Responses...)
+		sort.Strings(responseIDs)
+		responseTexts := make([]string, 0, len(responseIDs))
+		for _, id := range responseIDs {
+			responseTexts = append(responseTexts, result.ResponseAnalyses[id].Response.Text)
+		}
+
+		subThemes, err := a.claudeClient.IdentifySubThemes(theme, responseTexts, contextPrompt)
+		if err != nil {
+			return fmt.Errorf("failed to identify sub-themes for theme %s: %w", theme, err)
+		}
+		if len(subThemes) < 2 {
+			a.logger.Info("No useful sub-structure found", "theme", theme)
+			continue
+		}
+
+		matches, err := a.claudeClient.MatchResponsesToThemesBatch(responseTexts, subThemes, contextPrompt, a.batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to match responses to sub-themes of %s: %w", theme, err)
+		}
+
+		split := ThemeSplit{
+			Theme:     theme,
+			Share:     share,
+			SubThemes: subThemes,
+			Responses: make(map[string][]string),
+		}
+		for i, id := range responseIDs {
+			if i >= len(matches) {
+				break
+			}
+			for _, subTheme := range matches[i].Themes {
+				split.Responses[subTheme] = append(split.Responses[subTheme], id)
+			}
+		}
+		splits = append(splits, split)
+	}
+
+	if apply {
+		for i := range splits {
+			a.applyThemeSplit(result, splits[i])
+			splits[i].Applied = true
+		}
+		if len(splits) > 0 {
+			result.ThemeAnalyses = a.BuildThemeAnalyses(result.ResponseAnalyses, result.Themes)
+		}
+	}
+
+	result.ThemeSplits = splits
+	return nil
+}
+
+// applyThemeSplit replaces a theme by its sub-themes in the theme list and in
+// the response analyses
+func (a *Analyzer) applyThemeSplit(result *AnalysisResult, split ThemeSplit) {
+	qualified := func(subTheme string) string {
+		return qualifiedSubTheme(split.Theme, subTheme)
+	}
+
+	// Replace the theme in the theme list, keeping its position
+	result.Themes = expandThemeSplits(result.Themes, []ThemeSplit{split})
+
+	// Map each response to its sub-themes
+	subThemesByResponse := make(map[string][]string)
+	for subTheme, ids := range split.Responses {
+		for _, id := range ids {
+			subThemesByResponse[id] = append(subThemesByResponse[id], qualified(subTheme))
+		}
+	}
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		var themes []string
+		changed := false
+		for _, theme := range responseAnalysis.Themes {
+			if theme == split.Theme {
+				sort.Strings(subThemesByResponse[id])
+				themes = append(themes, subThemesByResponse[id]...)
+				changed = true
+				continue
+			}
+			themes = append(themes, theme)
+		}
+		if changed {
+			responseAnalysis.Themes = themes
+			result.ResponseAnalyses[id] = responseAnalysis
+		}
+	}
+
+	a.logger.Info("Applied theme split", "theme", split.Theme, "sub_themes", len(split.SubThemes))
+}
+
+// qualifiedSubTheme returns the name of a sub-theme of an applied split
+func qualifiedSubTheme(theme, subTheme string) string {
+	return theme + " / " + subTheme
+}
+
+// expandThemeSplits replaces the split themes in a theme list by their
+// sub-themes, keeping their position. The splits are applied in order, so
+// sub-themes can be split further.
+func expandThemeSplits(themes []string, splits []ThemeSplit) []string {
+	for _, split := range splits {
+		expanded := make([]string, 0, len(themes)+len(split.SubThemes))
+		for _, theme := range themes {
+			if theme != split.Theme {
+				expanded = append(expanded, theme)
+				continue
+			}
+			for _, subTheme := range split.SubThemes {
+				expanded = append(expanded, qualifiedSubTheme(theme, subTheme))
+			}
+		}
+		themes = expanded
+	}
+	return themes
+}
+
+// hasSubThemes reports whether a theme list holds sub-themes of a split
+func hasSubThemes(themes []string, split ThemeSplit) bool {
+	return slices.ContainsFunc(split.SubThemes, func(subTheme string) bool {
+		return slices.Contains(themes, qualifiedSubTheme(split.Theme, subTheme))
+	})
+}
+
+// previousThemeSplits returns the splits applied by earlier runs to themes
+// of the theme list, before or after the split
+func previousThemeSplits(previousResult *AnalysisResult, themes []string) []ThemeSplit {
+	if previousResult == nil {
+		return nil
+	}
+	var splits []ThemeSplit
+	for _, split := range previousResult.ThemeSplits {
+		if !split.Applied {
+			continue
+		}
+		if slices.Contains(themes, split.Theme) || hasSubThemes(themes, split) {
+			splits = append(splits, split)
+			themes = expandThemeSplits(themes, []ThemeSplit{split})
+		}
+	}
+	return splits
+}
+
+// splitParents maps the sub-themes of the splits to the theme they were
+// split from
+func splitParents(splits []ThemeSplit) map[string]string {
+	parents := make(map[string]string)
+	for _, split := range splits {
+		for _, subTheme := range split.SubThemes {
+			parents[qualifiedSubTheme(split.Theme, subTheme)] = split.Theme
+		}
+	}
+	return parents
+}
+
+// mergeThemes replaces sub-themes by the theme they were split from, keeping
+// the order of the first occurrences
+func mergeThemes(themes []string, parents map[string]string) []string {
+	merged := make([]string, 0, len(themes))
+	for _, theme := range themes {
+		for parents[theme] != "" {
+			theme = parents[theme]
+		}
+		if !slices.Contains(merged, theme) {
+			merged = append(merged, theme)
+		}
+	}
+	return merged
+}
+
+// mergeSubThemes returns the analyses with the sub-themes of the splits
+// replaced by the theme they were split from
+func mergeSubThemes(analyses map[string]ResponseAnalysis, splits []ThemeSplit) map[string]ResponseAnalysis {
+	parents := splitParents(splits)
+	merged := make(map[string]ResponseAnalysis, len(analyses))
+	for id, responseAnalysis := range analyses {
+		if slices.ContainsFunc(responseAnalysis.Themes, func(theme string) bool { return parents[theme] != "" }) {
+			responseAnalysis.Themes = mergeThemes(responseAnalysis.Themes, parents)
+		}
+		merged[id] = responseAnalysis
+	}
+	return merged
+}
+
+// BuildConsensusStats computes the disagreement rates of consensus voting
+func (a *Analyzer) BuildConsensusStats(responseAnalyses map[string]ResponseAnalysis, themeAnalyses map[string]ThemeAnalysis, runs int) *ConsensusStats {
+	stats := &ConsensusStats{
+		Runs:                  runs,
+		ThemeDisagreementRate: make(map[string]float64),
+	}
+	if len(responseAnalyses) == 0 {
+		return stats
+	}
+
+	disagreement := func(analysis ResponseAnalysis) float64 {
+		if analysis.Agreement == 0 {
+			return 0 // Analyzed without consensus voting
+		}
+		return 1 - analysis.Agreement
+	}
+
+	total := 0.0
+	for _, analysis := range responseAnalyses {
+		d := disagreement(analysis)
+		total += d
+		if d > 0 {
+			stats.DisputedResponses++
+		}
+	}
+	stats.DisagreementRate = total / float64(len(responseAnalyses))
+
+	for theme, themeAnalysis := range themeAnalyses {
+		if len(themeAnalysis.Responses) == 0 {
+			continue
+		}
+		sum := 0.0
+		for _, id := range themeAnalysis.Responses {
+			sum += disagreement(responseAnalyses[id])
+		}
+		stats.ThemeDisagreementRate[theme] = sum / float64(len(themeAnalysis.Responses))
+	}
+
+	a.logger.Info("Consensus voting",
+		"runs", runs,
+		"disagreement_rate", fmt.Sprintf("%.1f%%", stats.DisagreementRate*100),
+		"disputed_responses", stats.DisputedResponses)
+	return stats
+}
+
+// UpdateEmbeddings computes the embeddings of the responses and themes of the
+// result. Embeddings of the previous store are reused for unchanged texts if
+// they were computed with the same model.
+func (a *Analyzer) UpdateEmbeddings(result *AnalysisResult, previous *EmbeddingStore) error {
+	if a.embedder == nil {
+		return nil
+	}
+
+	store := &EmbeddingStore{
+		Model:     a.embedder.Model(),
+		Responses: make(map[string]ResponseEmbedding),
+		Themes:    make(map[string][]float32),
+	}
+	if previous != nil && previous.Model != store.Model {
+		a.logger.Info("Embedding model changed, recomputing embeddings", "previous", previous.Model, "current", store.Model)
+		previous = nil
+	}
+
+	// Reuse embeddings of unchanged responses
+	var ids []string
+	var texts []string
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		if previous != nil {
+			if known, ok := previous.Responses[id]; ok && known.Hash == responseAnalysis.Response.Hash {
+				store.Responses[id] = known
+				continue
+			}
+		}
+		ids = append(ids, id)
+		texts = append(texts, responseAnalysis.Response.Text)
+	}
+
+	// Reuse embeddings of known themes
+	var themes []string
+	for _, theme := range result.Themes {
+		if previous != nil {
+			if vector, ok := previous.Themes[theme]; ok {
+				store.Themes[theme] = vector
+				continue
+			}
+		}
+		themes = append(themes, theme)
+	}
+
+	a.logger.Info("Computing embeddings",
+		"model", store.Model,
+		"responses", len(ids),
+		"themes", len(themes),
+		"reused", len(store.Responses)+len(store.Themes))
+
+	// Describe themes by name and description for a richer embedding
+	themeTexts := make([]string, len(themes))
+	for i, theme := range themes {
+		themeTexts[i] = theme
+		if description, ok := result.ThemeDescriptions[theme]; ok && description.Description != "" {
+			themeTexts[i] += ": " + description.Description
+		}
+	}
+
+	if len(texts)+len(themeTexts) > 0 {
+		vectors, err := a.embedder.Embed(append(texts, themeTexts...))
+		if err != nil {
+			return fmt.Errorf("failed to compute embeddings: %w", err)
+		}
+		for i, id := range ids {
+			store.Responses[id] = ResponseEmbedding{
+				Hash:   result.ResponseAnalyses[id].Response.Hash,
+				Vector: vectors[i],
+			}
+		}
+		for i, theme := range themes {
+			store.Themes[theme] = vectors[len(ids)+i]
+		}
+	}
+
+	for _, responseEmbedding := range store.Responses {
+		store.Dimensions = len(responseEmbedding.Vector)
+		break
+	}
+
+	result.Embeddings = store
+	return nil
+}
+
+// DetectOutliers flags responses whose similarity to every theme is below the
+// threshold. A theme is represented by the centroid of its assigned responses
+// (leaving out the response under test) or, for themes without responses, by
+// the theme embedding. Outliers are returned from least to most similar.
+func (a *Analyzer) DetectOutliers(result *AnalysisResult, threshold float64) []Outlier {
+	if result.Embeddings == nil || len(result.Embeddings.Responses) == 0 {
+		a.logger.Warn("Outlier detection requires embeddings, skipping")
+		return nil
+	}
+	store := result.Embeddings
+
+	// Sum the vectors of the responses assigned to each theme
+	sums := make(map[string][]float32)
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		responseEmbedding, ok := store.Responses[id]
+		if !ok {
+			continue
+		}
+		for _, theme := range responseAnalysis.Themes {
+			if sums[theme] == nil {
+				sums[theme] = make([]float32, len(responseEmbedding.Vector))
+			}
+			for i, v := range responseEmbedding.Vector {
+				sums[theme][i] += v
+			}
+		}
+	}
+
+	var outliers []Outlier
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		responseEmbedding, ok := store.Responses[id]
+		if !ok {
+			continue
+		}
+		assigned := make(map[string]bool)
+		for _, theme := range responseAnalysis.Themes {
+			assigned[theme] = true
+		}
+
+		best := Outlier{ResponseID: id, Similarity: -1}
+		for _, theme := range result.Themes {
+			reference := sums[theme]
+			if reference != nil && assigned[theme] {
+				// Leave the response itself out of its theme's centroid
+				reference = make([]float32, len(sums[theme]))
+				for i := range reference {
+					reference[i] = sums[theme][i] - responseEmbedding.Vector[i]
+				}
+			}
+			if isZeroVector(reference) {
+				reference = store.Themes[theme]
+			}
+			if reference == nil {
+				continue
+			}
+			if similarity := embedding.CosineSimilarity(responseEmbedding.Vector, reference); similarity > best.Similarity {
+				best.Similarity = similarity
+				best.NearestTheme = theme
+			}
+		}
+		if best.NearestTheme != "" && best.Similarity < threshold {
+			outliers = append(outliers, best)
+		}
+	}
+
+	sort.Slice(outliers, func(i, j int) bool {
+		if outliers[i].Similarity != outliers[j].Similarity {
+			return outliers[i].Similarity < outliers[j].Similarity
+		}
+		return outliers[i].ResponseID < outliers[j].ResponseID
+	})
+
+	a.logger.Info("Detected outlier responses", "count", len(outliers), "threshold", threshold)
+	return outliers
+}
+
+// ClusterResponses groups the responses into k clusters by their embeddings
+// and lets the model label each cluster. The clusters are compared with the
+// themes by recording the theme most of their responses are assigned to.
+func (a *Analyzer) ClusterResponses(result *AnalysisResult, k int, contextPrompt string) error {
+	if result.Embeddings == nil || len(result.Embeddings.Responses) == 0 {
+		a.logger.Warn("Clustering requires embeddings, skipping")
+		return nil
+	}
+
+	// Cluster in a stable order so reruns produce the same clusters
+	ids := make([]string, 0, len(result.Embeddings.Responses))
+	for id := range result.Embeddings.Responses {
+		if _, ok := result.ResponseAnalyses[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	vectors := make([][]float32, len(ids))
+	for i, id := range ids {
+		vectors[i] = result.Embeddings.Responses[id].Vector
+	}
+
+	a.logger.Info("Clustering responses", "responses", len(ids), "clusters", k)
+	assignments := cluster.KMeans(vectors, k, cluster.DefaultIterations)
+
+	// Collect the members of each non-empty cluster
+	members := make(map[int][]string)
+	for i, c := range assignments {
+		members[c] = append(members[c], ids[i])
+	}
+	var clusterIDs []int
+	for c := range members {
+		clusterIDs = append(clusterIDs, c)
+	}
+	sort.Slice(clusterIDs, func(i, j int) bool {
+		if len(members[clusterIDs[i]]) != len(members[clusterIDs[j]]) {
+			return len(members[clusterIDs[i]]) > len(members[clusterIDs[j]])
+		}
+		return clusterIDs[i] < clusterIDs[j]
+	})
+
+	clusters := make([]Cluster, 0, len(clusterIDs))
+	texts := make([][]string, 0, len(clusterIDs))
+	for _, c := range clusterIDs {
+		responseIDs := members[c]
+		var clusterTexts []string
+		themeCounts := make(map[string]int)
+		for _, id := range responseIDs {
+			clusterTexts = append(clusterTexts, result.ResponseAnalyses[id].Response.Text)
+			for _, theme := range result.ResponseAnalyses[id].Themes {
+				themeCounts[theme]++
+			}
+		}
+
+		item := Cluster{Size: len(responseIDs), Responses: responseIDs}
+		for _, theme := range result.Themes {
+			if themeCounts[theme] > themeCounts[item.TopTheme] || (item.TopTheme == "" && themeCounts[theme] > 0) {
+				item.TopTheme = theme
+			}
+		}
+		if item.TopTheme != "" {
+			item.TopThemeShare = float64(themeCounts[item.TopTheme]) / float64(len(responseIDs))
+		}
+		clusters = append(clusters, item)
+		texts = append(texts, clusterTexts)
+	}
+
+	labels, err := a.claudeClient.LabelClusters(texts, contextPrompt)
+	if err != nil {
+		return fmt.Errorf("failed to label clusters: %w", err)
+	}
+	for i := range clusters {
+		clusters[i].Label = labels[i]
+	}
+
+	result.Clusters = clusters
+	a.logger.Info("Clustered responses", "clusters", len(clusters))
+	return nil
+}
+
+// BuildTopicModel fits a classical NMF topic model over the responses as a
+// non-LLM baseline and compares its topics with the themes
+func (a *Analyzer) BuildTopicModel(result *AnalysisResult, topicCount int) []Topic {
+	ids := make([]string, 0, len(result.ResponseAnalyses))
+	for id := range result.ResponseAnalyses {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	documents := make([]string, len(ids))
+	for i, id := range ids {
+		documents[i] = result.ResponseAnalyses[id].Response.Text
+	}
+
+	a.logger.Info("Fitting topic model", "responses", len(documents), "topics", topicCount)
+
+	var baseline []Topic
+	for _, fitted := range topics.NewModel(topicCount).Fit(documents) {
+		if len(fitted.Documents) == 0 {
+			continue
+		}
+		topic := Topic{Words: fitted.Words, Size: len(fitted.Documents)}
+		themeCounts := make(map[string]int)
+		for _, d := range fitted.Documents {
+			topic.Responses = append(topic.Responses, ids[d])
+			for _, theme := range result.ResponseAnalyses[ids[d]].Themes {
+				themeCounts[theme]++
+			}
+		}
+		for _, theme := range result.Themes {
+			if themeCounts[theme] > themeCounts[topic.TopTheme] {
+				topic.TopTheme = theme
+			}
+		}
+		if topic.TopTheme != "" {
+			topic.TopThemeShare = float64(themeCounts[topic.TopTheme]) / float64(topic.Size)
+		}
+		baseline = append(baseline, topic)
+	}
+
+	sort.SliceStable(baseline, func(i, j int) bool {
+		return baseline[i].Size > baseline[j].Size
+	})
+
+	a.logger.Info("Fitted topic model", "topics", len(baseline))
+	return baseline
+}
+
+// isZeroVector reports whether a vector is missing or has only zero components
+func isZeroVector(vector []float32) bool {
+	for _, v := range vector {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SimilarResponses returns the k responses most similar to the given response
+// based on the stored embeddings
+func SimilarResponses(result *AnalysisResult, responseID string, k int) ([]embedding.Neighbor, error) {
+	if result.Embeddings == nil {
+		return nil, fmt.Errorf("no embeddings stored in the result")
+	}
+	query, ok := result.Embeddings.Responses[responseID]
+	if !ok {
+		return nil, fmt.Errorf("no embedding stored for response %s", responseID)
+	}
+
+	candidates := make(map[string][]float32, len(result.Embeddings.Responses))
+	for id, responseEmbedding := range result.Embeddings.Responses {
+		if id != responseID {
+			candidates[id] = responseEmbedding.Vector
+		}
+	}
+	return embedding.Nearest(query.Vector, candidates, k), nil
+}
+
+// SearchResponses embeds the query and returns the k stored responses most
+// similar to it. The embedder must use the model the store was built with.
+func SearchResponses(result *AnalysisResult, embedder embedding.Embedder, query string, k int) ([]embedding.Neighbor, error) {
+	if result.Embeddings == nil || len(result.Embeddings.Responses) == 0 {
+		return nil, fmt.Errorf("no embeddings stored in the result, enable embeddings_enabled and rerun the analysis")
+	}
+	if embedder.Model() != result.Embeddings.Model {
+		return nil, fmt.Errorf("embedding model %s does not match the stored embeddings (%s)", embedder.Model(), result.Embeddings.Model)
+	}
+
+	vectors, err := embedder.Embed([]string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	candidates := make(map[string][]float32, len(result.Embeddings.Responses))
+	for id, responseEmbedding := range result.Embeddings.Responses {
+		candidates[id] = responseEmbedding.Vector
+	}
+	return embedding.Nearest(vectors[0], candidates, k), nil
+}
+
+// GenerateThemeSummaries generates summaries for each theme of the result
+// and extracts unique ideas. Each summary is generated from a sample of the
+// responses of its theme, selected by the summary sampling settings.
+func (a *Analyzer) GenerateThemeSummaries(result *AnalysisResult, cfg *config.Config) (map[string]claude.ThemeSummary, error) {
+	a.logger.Info("Generating theme summaries", "sampling", cfg.SummarySampling, "sample_size", cfg.SummarySampleSize)
+	responseAnalyses, themeAnalyses := result.ResponseAnalyses, result.ThemeAnalyses
+
+	summaries := make(map[string]claude.ThemeSummary)
 
 	// Process each theme
 	for theme, analysis := range themeAnalyses {
@@ -347,19 +1381,26 @@ func (a *Analyzer) GenerateThemeSummaries(responseAnalyses map[string]ResponseAn
 		if len(analysis.Responses) == 0 {
 			continue
 		}
+		a.waitIfPaused(func() map[string]ResponseAnalysis { return responseAnalyses })
+		if err := a.interrupted("theme summaries", func() map[string]ResponseAnalysis { return responseAnalyses }); err != nil {
+			return nil, err
+		}
 
-		// Get response texts for this theme
+		// Get the texts of the sampled responses of this theme
 		var responses []string
-		for _, responseID := range analysis.Responses {
-			if responseAnalysis, ok := responseAnalyses[responseID]; ok {
-				responses = append(responses, responseAnalysis.Response.Text)
-			}
+		for _, responseAnalysis := range a.summarySample(theme, analysis.Responses, result, cfg) {
+			responses = append(responses, responseAnalysis.Response.Text)
 		}
 
 		// Generate theme summary using Claude API
-		a.logger.Debug("Generating summary for theme", "theme", theme, "responses", len(responses))
-		themeSummaryResponse, err := a.claudeClient.GenerateThemeSummary(theme, responses, themeSummaryPrompt)
+		a.logger.Debug("Generating summary for theme", "theme", theme, "responses", len(analysis.Responses), "sample", len(responses))
+		// Without the sentiment stage the summaries carry no sentiment balance
+		sentiment := analysis.Sentiment
+		themeSummaryResponse, err := a.claudeClient.GenerateThemeSummary(theme, responses, len(analysis.Responses), cfg.ThemeSummaryPromptFor(theme), sentiment)
 		if err != nil {
+			if err := a.interrupted("theme summaries", func() map[string]ResponseAnalysis { return responseAnalyses }); err != nil {
+				return nil, err
+			}
 			return nil, fmt.Errorf("failed to generate summary for theme %s: %w", theme, err)
 		}
 
@@ -370,14 +1411,14 @@ func (a *Analyzer) GenerateThemeSummaries(responseAnalyses map[string]ResponseAn
 		themeSummary := claude.ThemeSummary{
 			Summary:     summary,
 			UniqueIdeas: uniqueIdeas,
+			Sentiment:   sentiment,
 		}
 
-		// Add to result
-		result[theme] = themeSummary
+		summaries[theme] = themeSummary
 	}
 
-	a.logger.Info("Generated theme summaries", "count", len(result))
-	return result, nil
+	a.logger.Info("Generated theme summaries", "count", len(summaries))
+	return summaries, nil
 }
 
 // GenerateGlobalSummary generates a global summary based on theme summaries
@@ -394,6 +1435,237 @@ func (a *Analyzer) GenerateGlobalSummary(themeSummaries map[string]claude.ThemeS
 	return summary, nil
 }
 
+// carryOverVerification keeps the verification of summaries that were not
+// regenerated in this run
+func carryOverVerification(result *AnalysisResult, previous *VerificationReport, themes, global bool) {
+	if previous == nil {
+		return
+	}
+	if result.Verification == nil {
+		result.Verification = &VerificationReport{Model: previous.Model, Removed: previous.Removed}
+	}
+
+	var checks []claude.ClaimCheck
+	if themes {
+		result.Verification.Themes = previous.Themes
+		for _, themeChecks := range previous.Themes {
+			checks = append(checks, themeChecks...)
+		}
+	}
+	if global {
+		result.Verification.Global = previous.Global
+		checks = append(checks, previous.Global...)
+	}
+	for _, check := range checks {
+		if !check.Supported {
+			result.Verification.Unsupported++
+		}
+	}
+}
+
+// VerifyThemeSummaries checks every claim of the theme summaries against the
+// responses of the theme. Unsupported claims are removed if remove is set.
+func (a *Analyzer) VerifyThemeSummaries(result *AnalysisResult, model string, remove bool) error {
+	a.logger.Info("Verifying theme summaries", "model", model)
+
+	if result.Verification == nil {
+		result.Verification = &VerificationReport{Model: model, Removed: remove}
+	}
+	result.Verification.Themes = make(map[string][]claude.ClaimCheck)
+
+	for _, theme := range result.Themes {
+		summary, ok := result.ThemeSummaries[theme]
+		if !ok || summary.Summary == "" {
+			continue
+		}
+
+		var sources []string
+		for _, id := range result.ThemeAnalyses[theme].Responses {
+			sources = append(sources, result.ResponseAnalyses[id].Response.Text)
+		}
+		sort.Strings(sources)
+
+		checks, err := a.claudeClient.VerifySummary(model, summary.Summary, sources)
+		if err != nil {
+			return fmt.Errorf("failed to verify summary of theme %s: %w", theme, err)
+		}
+		result.Verification.Themes[theme] = checks
+
+		cleaned, unsupported := applyClaimChecks(summary.Summary, checks, remove)
+		result.Verification.Unsupported += unsupported
+		if unsupported > 0 {
+			a.logger.Warn("Theme summary contains unsupported claims", "theme", theme, "unsupported", unsupported)
+		}
+		summary.Summary = cleaned
+		result.ThemeSummaries[theme] = summary
+	}
+
+	return nil
+}
+
+// VerifyGlobalSummary checks every claim of the global summary against the
+// theme summaries it was generated from
+func (a *Analyzer) VerifyGlobalSummary(result *AnalysisResult, model string, remove bool) error {
+	a.logger.Info("Verifying global summary", "model", model)
+
+	if result.Verification == nil {
+		result.Verification = &VerificationReport{Model: model, Removed: remove}
+	}
+
+	var sources []string
+	for _, theme := range result.Themes {
+		if summary, ok := result.ThemeSummaries[theme]; ok {
+			sources = append(sources, theme+": "+summary.Summary)
+		}
+	}
+
+	checks, err := a.claudeClient.VerifySummary(model, result.GlobalSummary, sources)
+	if err != nil {
+		return fmt.Errorf("failed to verify global summary: %w", err)
+	}
+	result.Verification.Global = checks
+
+	cleaned, unsupported := applyClaimChecks(result.GlobalSummary, checks, remove)
+	result.Verification.Unsupported += unsupported
+	if unsupported > 0 {
+		a.logger.Warn("Global summary contains unsupported claims", "unsupported", unsupported)
+	}
+	if remove && unsupported > 0 {
+		// Citations of the removed claims are gone from the summary
+		cleaned, result.SummaryCitations = a.ValidateCitations(cleaned, result)
+	}
+	result.GlobalSummary = cleaned
+	result.Summary = cleaned
+
+	return nil
+}
+
+// applyClaimChecks counts the unsupported claims and, if remove is set,
+// removes the claims that can be found verbatim in the summary
+func applyClaimChecks(summary string, checks []claude.ClaimCheck, remove bool) (string, int) {
+	unsupported := 0
+	for _, check := range checks {
+		if check.Supported {
+			continue
+		}
+		unsupported++
+		if remove && check.Claim != "" {
+			summary = strings.Replace(summary, check.Claim, "", 1)
+		}
+	}
+	if remove && unsupported > 0 {
+		summary = tidyRemovedClaims(summary)
+	}
+	return summary, unsupported
+}
+
+// Leftovers of claims removed from a summary
+var (
+	repeatedSpacePattern          = regexp.MustCompile(`[ \t]{2,}`)
+	orphanedMarkerPattern         = regexp.MustCompile(`([.!?])[ \t]*(?:\[[^\]\s]+\][ \t]*)+[.!?]`)
+	strayPunctuationPattern       = regexp.MustCompile(`([.!?])[ \t]+[.,;:]+`)
+	spaceBeforePunctuationPattern = regexp.MustCompile(`[ \t]+([.,])`) // Other marks follow a space in French
+	repeatedSeparatorPattern      = regexp.MustCompile(`([,;:])[ \t]*[,;:]+`)
+	leadingPunctuationPattern     = regexp.MustCompile(`(?m)^[ \t]*[.,;:][ \t]*`)
+)
+
+// tidyRemovedClaims removes the spaces, punctuation and citation markers
+// that claims removed from a summary left behind
+func tidyRemovedClaims(summary string) string {
+	summary = repeatedSpacePattern.ReplaceAllString(summary, " ")
+	summary = orphanedMarkerPattern.ReplaceAllString(summary, "$1")
+	summary = strayPunctuationPattern.ReplaceAllString(summary, "$1")
+	summary = spaceBeforePunctuationPattern.ReplaceAllString(summary, "$1")
+	summary = repeatedSeparatorPattern.ReplaceAllString(summary, "$1")
+	summary = leadingPunctuationPattern.ReplaceAllString(summary, "")
+	return strings.TrimSpace(summary)
+}
+
+// citationPattern matches citation markers such as [T2], [R15] or [R15.2]
+var citationPattern = regexp.MustCompile(`\[(T\d+|` + ResponseIDPattern + `)\]`)
+
+// maxCitedResponsesPerTheme limits the example responses offered for citation per theme
+const maxCitedResponsesPerTheme = 5
+
+// GenerateCitedGlobalSummary generates a global summary with citation markers
+// referencing themes ([T1]) and responses ([R12]). Citations are validated
+// against the result; markers that do not exist are removed from the summary.
+func (a *Analyzer) GenerateCitedGlobalSummary(result *AnalysisResult, globalSummaryPrompt string, summaryLength int) (string, []Citation, error) {
+	a.logger.Info("Generating global summary with citations")
+
+	summary, err := a.claudeClient.GenerateCitedGlobalSummary(result.Themes, result.ThemeSummaries, a.citationEvidence(result), globalSummaryPrompt, summaryLength)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate global summary: %w", err)
+	}
+
+	summary, citations := a.ValidateCitations(summary, result)
+	a.logger.Info("Generated global summary with citations", "length", len(summary), "citations", len(citations))
+	return summary, citations, nil
+}
+
+// citationEvidence returns a few example responses per theme the global
+// summary may cite
+func (a *Analyzer) citationEvidence(result *AnalysisResult) map[string][]claude.CitedResponse {
+	evidence := make(map[string][]claude.CitedResponse)
+	for _, theme := range result.Themes {
+		ids := append([]string(nil), result.ThemeAnalyses[theme].Responses...)
+		sort.Slice(ids, func(i, j int) bool {
+			return result.ResponseAnalyses[ids[i]].Response.Before(result.ResponseAnalyses[ids[j]].Response)
+		})
+		for i, id := range ids {
+			if i >= maxCitedResponsesPerTheme {
+				break
+			}
+			evidence[theme] = append(evidence[theme], claude.CitedResponse{
+				ID:   id,
+				Text: result.ResponseAnalyses[id].Response.Text,
+			})
+		}
+	}
+	return evidence
+}
+
+// ValidateCitations checks the citation markers in a summary against the
+// themes and responses of the result. Unknown markers are removed from the
+// summary and logged; the valid citations are returned in order of appearance.
+func (a *Analyzer) ValidateCitations(summary string, result *AnalysisResult) (string, []Citation) {
+	var citations []Citation
+	seen := make(map[string]bool)
+	invalid := 0
+
+	cleaned := citationPattern.ReplaceAllStringFunc(summary, func(match string) string {
+		marker := match[1 : len(match)-1]
+		citation := Citation{Marker: marker}
+		if marker[0] == 'T' {
+			var index int
+			fmt.Sscanf(marker, "T%d", &index)
+			if index < 1 || index > len(result.Themes) {
+				invalid++
+				a.logger.Warn("Removing citation of unknown theme", "marker", marker)
+				return ""
+			}
+			citation.Theme = result.Themes[index-1]
+		} else {
+			if _, ok := result.ResponseAnalyses[marker]; !ok {
+				invalid++
+				a.logger.Warn("Removing citation of unknown response", "marker", marker)
+				return ""
+			}
+			citation.ResponseID = marker
+		}
+		if !seen[marker] {
+			seen[marker] = true
+			citations = append(citations, citation)
+		}
+		return match
+	})
+
+	if invalid > 0 {
+		a.logger.Warn("Global summary contained invalid citations", "removed", invalid)
+	}
+	return cleaned, citations
+}
+
 // GenerateSummary generates a summary of the analysis (for backward compatibility)
 func (a *Analyzer) GenerateSummary(responseAnalyses map[string]ResponseAnalysis, themeAnalyses map[string]ThemeAnalysis, summaryPrompt string, summaryLength int) (string, error) {
 	a.logger.Info("Generating summary")
@@ -478,6 +1750,47 @@ func (a *Analyzer) IdentifyThemesOnly(responses []excel.Response, contextPrompt
 	return a.IdentifyThemes(responses, contextPrompt)
 }
 
+// recordTruncations adds the truncations of the responses in the prompts of
+// this run to their analyses. Those of earlier runs are kept for the tasks
+// that did not run again.
+func (a *Analyzer) recordTruncations(result *AnalysisResult) {
+	truncated := 0
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		truncations := a.claudeClient.Truncations(responseAnalysis.Response.Text)
+		if len(truncations) == 0 {
+			continue
+		}
+		merged := truncations
+		for _, previous := range responseAnalysis.Truncations {
+			if !slices.ContainsFunc(truncations, func(t claude.Truncation) bool { return t.Task == previous.Task }) {
+				merged = append(merged, previous)
+			}
+		}
+		responseAnalysis.Truncations = merged
+		result.ResponseAnalyses[id] = responseAnalysis
+		truncated++
+	}
+	if truncated > 0 {
+		a.logger.Info("Responses were truncated in prompts, see the audit log", "count", truncated)
+	}
+}
+
+// KnownThemeDescriptions returns the theme descriptions of the previous
+// result, overridden by those of the configuration
+func KnownThemeDescriptions(cfg *config.Config, previousResult *AnalysisResult) map[string]claude.ThemeDescription {
+	if previousResult == nil || len(previousResult.ThemeDescriptions) == 0 {
+		return cfg.ThemeDescriptions
+	}
+	known := make(map[string]claude.ThemeDescription)
+	for theme, description := range previousResult.ThemeDescriptions {
+		known[theme] = description
+	}
+	for theme, description := range cfg.ThemeDescriptions {
+		known[theme] = description
+	}
+	return known
+}
+
 // AnalyzeResponses analyzes responses using the provided configuration
 func (a *Analyzer) AnalyzeResponses(responses []excel.Response, cfg *config.Config, previousResult *AnalysisResult, columnTitle string) (*AnalysisResult, error) {
 	a.logger.Info("Analyzing responses", "count", len(responses))
@@ -489,6 +1802,17 @@ func (a *Analyzer) AnalyzeResponses(responses []excel.Response, cfg *config.Conf
 		ThemeAnalyses:     make(map[string]ThemeAnalysis),
 		AnalysisTimestamp: time.Now(),
 		ColumnTitle:       columnTitle,
+		Survey:            cfg.Survey,
+	}
+
+	// Never analyze responses that were forgotten on request again
+	if previousResult != nil && len(previousResult.ForgottenResponses) > 0 {
+		result.ForgottenResponses = previousResult.ForgottenResponses
+		kept := SkipForgottenResponses(responses, result.ForgottenResponses)
+		if skipped := len(responses) - len(kept); skipped > 0 {
+			a.logger.Info("Skipping forgotten responses still present in the input", "count", skipped)
+		}
+		responses = kept
 	}
 
 	// If no themes provided, identify them
@@ -506,9 +1830,115 @@ func (a *Analyzer) AnalyzeResponses(responses []excel.Response, cfg *config.Conf
 		previousAnalyses = previousResult.ResponseAnalyses
 	}
 
+	// Keep the theme splits applied by earlier runs: with
+	// auto_apply_theme_splits the theme list takes their sub-themes again,
+	// otherwise the sub-themes of the reused responses go back to their theme
+	appliedSplits := previousThemeSplits(previousResult, result.Themes)
+	if len(appliedSplits) > 0 {
+		if cfg.AutoApplyThemeSplits {
+			result.Themes = expandThemeSplits(result.Themes, appliedSplits)
+		} else {
+			var merged, kept []ThemeSplit
+			for _, split := range appliedSplits {
+				if hasSubThemes(result.Themes, split) {
+					kept = append(kept, split)
+				} else {
+					merged = append(merged, split)
+				}
+			}
+			previousAnalyses = mergeSubThemes(previousAnalyses, merged)
+			appliedSplits = kept
+		}
+	}
+
+	// Locked themes are kept, but a reviewer should check those of responses
+	// that changed since
+	if changed := changedLockedResponses(responses, previousAnalyses); len(changed) > 0 {
+		a.logger.Warn("Keeping the locked themes of changed responses, review them", "count", len(changed), "responses", strings.Join(changed, ", "))
+	}
+
+	// Match responses again that were matched without a justification
+	if cfg.MatchReasons && !cfg.SkipMatching {
+		withReasons := make(map[string]ResponseAnalysis, len(previousAnalyses))
+		for id, analysis := range previousAnalyses {
+			if analysis.Reason != "" || analysis.Locked {
+				withReasons[id] = analysis
+			}
+		}
+		if dropped := len(previousAnalyses) - len(withReasons); dropped > 0 {
+			a.logger.Info("Matching responses again to justify their themes", "count", dropped)
+			previousAnalyses = withReasons
+		}
+	}
+
+	// Describe the themes so matching can use their inclusion criteria
+	knownDescriptions := KnownThemeDescriptions(cfg, previousResult)
+	if cfg.GenerateThemeDescriptions {
+		descriptions, err := a.GenerateThemeDescriptions(responses, result.Themes, cfg.ContextPrompt, knownDescriptions)
+		if err != nil {
+			return nil, err
+		}
+		result.ThemeDescriptions = descriptions
+	} else if len(knownDescriptions) > 0 {
+		result.ThemeDescriptions = make(map[string]claude.ThemeDescription)
+		for _, theme := range result.Themes {
+			if description, ok := knownDescriptions[theme]; ok {
+				result.ThemeDescriptions[theme] = description
+			}
+		}
+	}
+	a.claudeClient.SetThemeDescriptions(result.ThemeDescriptions)
+
+	// Match all responses again if the themes or the matching changed since
+	// the previous run, keeping only the locked themes
+	rematched := false
+	if !cfg.SkipMatching {
+		if changes := matchingChanges(cfg, result.Themes, result.ThemeDescriptions, previousResult); len(changes) > 0 {
+			rematched = true
+			locked := make(map[string]ResponseAnalysis)
+			for id, analysis := range previousAnalyses {
+				if analysis.Locked {
+					locked[id] = analysis
+				}
+			}
+			a.logger.Info("Matching all responses again, the themes or their matching changed", "changed", strings.Join(changes, ","), "locked", len(locked))
+			previousAnalyses = locked
+		}
+	}
+
+	// Let a paused run checkpoint the responses matched so far
+	a.checkpointBase = result
+	a.checkpointPrevious = previousResult
+
+	// Send the matching requests as a message batch first, so the matching
+	// below takes their answers from the queue
+	if a.claudeClient.BatchAPI() && !cfg.SkipMatching {
+		a.logger.Info("Collecting the theme matching requests for a message batch")
+		// The collection pass gets no answers, so its results are neither
+		// kept nor checkpointed
+		a.collectingBatch = true
+		answered, err := a.claudeClient.SendAsBatch(func() error {
+			_, err := a.MatchResponsesToThemes(responses, result.Themes, cfg.ContextPrompt, previousAnalyses)
+			return err
+		})
+		a.collectingBatch = false
+		a.failedResponses = nil
+		if err != nil {
+			return nil, fmt.Errorf("failed to match responses through the batch API: %w", err)
+		}
+		a.logger.Info("Matching with the answers of the message batch", "answered", answered)
+	}
+
 	// Match responses to themes
 	var err error
-	if a.useParallel {
+	if cfg.SkipMatching {
+		// Keep the classification of the previous run
+		if previousResult == nil {
+			return nil, fmt.Errorf("skip_matching requires the state of a previous run")
+		}
+		a.logger.Info("Skipping theme matching, reusing previous assignments")
+		result.ResponseAnalyses = a.ReusePreviousAnalyses(responses, previousAnalyses)
+	} else if a.useParallel {
 		// Use parallel processing
 		result.ResponseAnalyses, err = a.MatchResponsesToThemesParallel(responses, result.Themes, cfg.ContextPrompt, previousAnalyses, a.batchSize, a.parallelWorkers)
 		if err != nil {
@@ -522,9 +1952,85 @@ func (a *Analyzer) AnalyzeResponses(responses []excel.Response, cfg *config.Conf
 		}
 	}
 
+	if !cfg.SkipMatching {
+		result.FailedResponses = a.failedResponses
+	}
+
+	// Classify the sentiment of the responses without one
+	if cfg.SentimentEnabled {
+		if err := a.ScoreSentiment(result.ResponseAnalyses); err != nil {
+			return nil, err
+		}
+	} else {
+		dropSentiments(result.ResponseAnalyses)
+	}
+
 	// Build theme analyses
 	result.ThemeAnalyses = a.BuildThemeAnalyses(result.ResponseAnalyses, result.Themes)
 
+	// Record the disagreement between consensus runs
+	if cfg.ConsensusRuns > 1 {
+		result.Consensus = a.BuildConsensusStats(result.ResponseAnalyses, result.ThemeAnalyses, cfg.ConsensusRuns)
+	}
+
+	// Collect new themes proposed for responses that matched no theme
+	result.ProposedThemes = a.CollectProposedThemes(result.ResponseAnalyses)
+
+	// Look for themes that absorbed too many responses, which reclassifies
+	// their responses and is therefore skipped along with the matching
+	if cfg.SkipMatching {
+		if previousResult != nil {
+			result.ThemeSplits = previousResult.ThemeSplits
+		}
+	} else if err := a.SplitBroadThemes(result, cfg.SplitThemeThreshold, cfg.ContextPrompt, cfg.AutoApplyThemeSplits); err != nil {
+		return nil, fmt.Errorf("failed to split broad themes: %w", err)
+	}
+	if !cfg.SkipMatching && len(appliedSplits) > 0 {
+		result.ThemeSplits = append(appliedSplits, result.ThemeSplits...)
+	}
+
+	// Count the themes per week or month of the survey
+	if cfg.TimestampColumn != "" {
+		result.TimeSeries = BuildTimeSeries(result.ResponseAnalyses, result.Themes, cfg.TimeSeriesInterval)
+		if len(cfg.RollingWindows) > 0 {
+			result.RollingWindows = BuildRollingWindows(result.ResponseAnalyses, result.Themes, cfg.RollingWindows)
+		}
+	}
+
+	// Connect the themes to the numeric ratings
+	if cfg.RatingColumn != "" {
+		result.Ratings = BuildRatingStats(result.ResponseAnalyses, result.Themes, cfg.RatingBands)
+	}
+
+	// Describe the languages and lengths of the responses
+	result.Corpus = BuildCorpusStats(result.ResponseAnalyses, result.Themes)
+
+	// Compute embeddings, reusing those of unchanged responses
+	var previousEmbeddings *EmbeddingStore
+	if previousResult != nil {
+		previousEmbeddings = previousResult.Embeddings
+	}
+	if err := a.UpdateEmbeddings(result, previousEmbeddings); err != nil {
+		return nil, err
+	}
+
+	// Flag responses that are far from all themes
+	if cfg.OutlierThreshold > 0 {
+		result.Outliers = a.DetectOutliers(result, cfg.OutlierThreshold)
+	}
+
+	// Fit the classical topic model baseline
+	if cfg.TopicModelTopics > 0 {
+		result.Topics = a.BuildTopicModel(result, cfg.TopicModelTopics)
+	}
+
+	// Cluster the responses as a cross-check of the themes
+	if cfg.ClusterCount > 0 {
+		if err := a.ClusterResponses(result, cfg.ClusterCount, cfg.ContextPrompt); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check if any responses have changed
 	responsesChanged := len(previousAnalyses) != len(result.ResponseAnalyses)
 	if !responsesChanged {
@@ -536,41 +2042,108 @@ func (a *Analyzer) AnalyzeResponses(responses []excel.Response, cfg *config.Conf
 		}
 	}
 
-	// If no responses have changed and previous result has theme summaries, reuse them
-	if !responsesChanged && previousResult != nil && len(previousResult.ThemeSummaries) > 0 {
+	// If no responses have changed, or the themes drifted less than the
+	// threshold since the summaries were generated, and previous result has
+	// theme summaries that are not stale, reuse them. Skipping the matching is
+	// meant to regenerate the summaries, so they are not reused then, nor
+	// after matching all responses again or for other themes.
+	reuseSummaries := !cfg.SkipMatching && !rematched && previousResult != nil && len(previousResult.ThemeSummaries) > 0 &&
+		len(previousResult.StaleThemeSummaries) == 0 && !previousResult.StaleGlobalSummary &&
+		slices.Equal(slices.Sorted(slices.Values(result.Themes)), slices.Sorted(slices.Values(previousResult.Themes))) &&
+		!a.summarySettingsChanged(cfg, previousResult)
+	if reuseSummaries && responsesChanged {
+		reuseSummaries = a.summaryDriftBelowThreshold(result, previousResult, cfg.SummaryDriftThreshold)
+	}
+	if reuseSummaries {
 		a.logger.Info("Reusing theme summaries from previous result", "count", len(previousResult.ThemeSummaries))
+		result.SummaryBaseline = previousResult.SummaryBaseline
 		result.ThemeSummaries = previousResult.ThemeSummaries
 		result.GlobalSummary = previousResult.GlobalSummary
 		result.Summary = previousResult.Summary
+		result.SummaryCitations = previousResult.SummaryCitations
+		result.SummaryVariants = previousResult.SummaryVariants
+		result.Verification = previousResult.Verification
+		result.LanguageSummaries = previousResult.LanguageSummaries
+		if err := a.GenerateAudienceSummaries(result, cfg, previousResult.AudienceSummaries); err != nil {
+			return nil, err
+		}
 	} else {
+		if previousResult != nil && len(previousResult.LanguageSummaries) > 0 {
+			// The languages of the report outputs are summarized again after the run
+			dropped := 0
+			for language := range previousResult.LanguageSummaries {
+				if !slices.ContainsFunc(cfg.ReportOutputs, func(report config.ReportOutput) bool { return report.Language == language }) {
+					dropped++
+				}
+			}
+			if dropped > 0 {
+				a.logger.Warn("Dropping summaries in additional languages, regenerate them with the summarize subcommand", "languages", dropped)
+			}
+		}
 		// Generate theme summaries if themes are provided and theme summary prompt is provided
-		if len(result.Themes) > 0 && cfg.ThemeSummaryPrompt != "" {
-			result.ThemeSummaries, err = a.GenerateThemeSummaries(result.ResponseAnalyses, result.ThemeAnalyses, cfg.ThemeSummaryPrompt)
+		if cfg.SkipThemeSummaries {
+			a.logger.Info("Skipping theme summaries")
+			if previousResult != nil {
+				result.ThemeSummaries = previousResult.ThemeSummaries
+				result.StaleThemeSummaries = previousResult.StaleThemeSummaries
+				carryOverVerification(result, previousResult.Verification, true, false)
+			}
+		} else if len(result.Themes) > 0 && (cfg.ThemeSummaryPrompt != "" || len(cfg.ThemeOverrides) > 0) {
+			result.ThemeSummaries, err = a.GenerateThemeSummaries(result, cfg)
 			if err != nil {
 				return nil, fmt.Errorf("failed to generate theme summaries: %w", err)
 			}
+			if cfg.VerifySummaries {
+				if err := a.VerifyThemeSummaries(result, cfg.VerificationModel, cfg.RemoveUnsupportedClaims); err != nil {
+					return nil, err
+				}
+			}
 		}
 
-		// Generate global summary if themes are provided and global summary prompt is provided
-		if len(result.Themes) > 0 && cfg.GlobalSummaryPrompt != "" && cfg.SummaryLength > 0 {
-			result.GlobalSummary, err = a.GenerateGlobalSummary(result.ThemeSummaries, cfg.GlobalSummaryPrompt, cfg.SummaryLength)
-			if err != nil {
-				return nil, fmt.Errorf("failed to generate global summary: %w", err)
+		// Generate global summary if themes are provided, using a default
+		// global summary prompt if none is provided
+		if cfg.SkipGlobalSummary {
+			a.logger.Info("Skipping global summary")
+			if previousResult != nil {
+				result.GlobalSummary = previousResult.GlobalSummary
+				result.Summary = previousResult.Summary
+				result.SummaryCitations = previousResult.SummaryCitations
+				result.SummaryVariants = previousResult.SummaryVariants
+				result.AudienceSummaries = previousResult.AudienceSummaries
+				result.StaleGlobalSummary = previousResult.StaleGlobalSummary
+				carryOverVerification(result, previousResult.Verification, false, true)
 			}
-			// Set Summary to the same value for backward compatibility
-			result.Summary = result.GlobalSummary
 		} else if len(result.Themes) > 0 && cfg.SummaryLength > 0 {
-			// Use a default global summary prompt if none is provided
-			defaultGlobalPrompt := "Summarize the main points made in each theme and highlight any unique ideas or problems mentioned."
-			result.GlobalSummary, err = a.GenerateGlobalSummary(result.ThemeSummaries, defaultGlobalPrompt, cfg.SummaryLength)
-			if err != nil {
+			globalPrompt := cfg.GlobalSummaryPrompt
+			if globalPrompt == "" {
+				globalPrompt = "Summarize the main points made in each theme and highlight any unique ideas or problems mentioned."
+			}
+			if err := a.GenerateGlobalSummaries(result, cfg, globalPrompt); err != nil {
 				return nil, fmt.Errorf("failed to generate global summary: %w", err)
 			}
 			// Set Summary to the same value for backward compatibility
 			result.Summary = result.GlobalSummary
+			if cfg.VerifySummaries {
+				if err := a.VerifyGlobalSummary(result, cfg.VerificationModel, cfg.RemoveUnsupportedClaims); err != nil {
+					return nil, err
+				}
+			}
+			if err := a.GenerateAudienceSummaries(result, cfg, nil); err != nil {
+				return nil, err
+			}
+		}
+
+		// Remember the themes the summaries were generated from, to measure
+		// their drift in later runs
+		if len(result.ThemeSummaries) > 0 && !cfg.SkipThemeSummaries {
+			result.SummaryBaseline = NewSummaryBaseline(result.ResponseAnalyses, result.Themes)
+		} else if previousResult != nil {
+			result.SummaryBaseline = previousResult.SummaryBaseline
 		}
 	}
 
+	a.recordTruncations(result)
+
 	a.logger.Info("Analysis completed",
 		"themes", len(result.Themes),
 		"responses", len(result.ResponseAnalyses),
@@ -0,0 +1,93 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+func TestApplyClaimChecks(t *testing.T) {
+	unsupported := func(claim string) claude.ClaimCheck {
+		return claude.ClaimCheck{Claim: claim, Supported: false}
+	}
+	tests := []struct {
+		name            string
+		summary         string
+		checks          []claude.ClaimCheck
+		remove          bool
+		want            string
+		wantUnsupported int
+	}{
+		{
+			name:            "claim with citation marker",
+			summary:         "Many like the app [T1]. Delivery is slow [R3]. Prices are fair [T2].",
+			checks:          []claude.ClaimCheck{{Claim: "Many like the app", Supported: true}, unsupported("Delivery is slow")},
+			remove:          true,
+			want:            "Many like the app [T1]. Prices are fair [T2].",
+			wantUnsupported: 1,
+		},
+		{
+			name:            "whole sentence",
+			summary:         "Many like the app [T1]. Delivery is slow [R3]. Prices are fair [T2].",
+			checks:          []claude.ClaimCheck{unsupported("Delivery is slow [R3].")},
+			remove:          true,
+			want:            "Many like the app [T1]. Prices are fair [T2].",
+			wantUnsupported: 1,
+		},
+		{
+			name:            "list item",
+			summary:         "Customers mention delays, broken boxes, and lost parcels.",
+			checks:          []claude.ClaimCheck{unsupported("broken boxes")},
+			remove:          true,
+			want:            "Customers mention delays, and lost parcels.",
+			wantUnsupported: 1,
+		},
+		{
+			name:            "first sentence",
+			summary:         "Delivery is slow. Prices are fair.\nSupport helps quickly.",
+			checks:          []claude.ClaimCheck{unsupported("Delivery is slow"), unsupported("Support helps quickly")},
+			remove:          true,
+			want:            "Prices are fair.",
+			wantUnsupported: 2,
+		},
+		{
+			name:            "claims are only counted",
+			summary:         "Delivery is slow [R3]. Prices are fair.",
+			checks:          []claude.ClaimCheck{unsupported("Delivery is slow")},
+			remove:          false,
+			want:            "Delivery is slow [R3]. Prices are fair.",
+			wantUnsupported: 1,
+		},
+		{
+			name:            "all claims supported",
+			summary:         "Delivery is slow  [R3].",
+			checks:          []claude.ClaimCheck{{Claim: "Delivery is slow", Supported: true}},
+			remove:          true,
+			want:            "Delivery is slow  [R3].",
+			wantUnsupported: 0,
+		},
+	}
+	for _, test := range tests {
+		got, unsupported := applyClaimChecks(test.summary, test.checks, test.remove)
+		if got != test.want || unsupported != test.wantUnsupported {
+			t.Errorf("%s: got %q, %d unsupported, want %q, %d", test.name, got, unsupported, test.want, test.wantUnsupported)
+		}
+	}
+}
+
+func TestValidateCitationsAfterClaimRemoval(t *testing.T) {
+	result := &AnalysisResult{
+		Themes:           []string{"App", "Prices"},
+		ResponseAnalyses: map[string]ResponseAnalysis{"R3": {}},
+	}
+	analyzer := NewAnalyzer(logging.NewLogger(false), nil)
+
+	summary, _ := applyClaimChecks("Many like the app [T1]. Delivery is slow [R3]. Prices are fair [T2].", []claude.ClaimCheck{{Claim: "Delivery is slow"}}, true)
+	_, citations := analyzer.ValidateCitations(summary, result)
+	want := []Citation{{Marker: "T1", Theme: "App"}, {Marker: "T2", Theme: "Prices"}}
+	if !reflect.DeepEqual(citations, want) {
+		t.Errorf("got citations %+v, want %+v", citations, want)
+	}
+}
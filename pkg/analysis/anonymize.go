@@ -0,0 +1,242 @@
+package analysis
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Ways of treating the response texts of an anonymized state
+const (
+	AnonymizedTextRemove     = "remove"     // Leave the texts out
+	AnonymizedTextParaphrase = "paraphrase" // Replace the texts by paraphrases
+)
+
+// AnonymizedTexts lists the valid ways of treating the response texts
+var AnonymizedTexts = []string{AnonymizedTextRemove, AnonymizedTextParaphrase}
+
+// Anonymize returns a copy of the result for sharing with third parties.
+// Themes, statistics and summaries are kept, while the response texts are
+// replaced by the given paraphrases or left out. The rows are numbered anew
+// in their order, so the responses get the IDs R1, R2, ... (and R1.1, R1.2,
+// ... for segments) whatever their IDs were, and citations in the summaries
+// are renumbered with them. Hashes, submission times, model justifications,
+// embeddings, warnings and the forgotten responses are left out, as they
+// can reveal the texts or the respondents. The same result always gives the
+// same anonymized result.
+func Anonymize(result *AnalysisResult, paraphrases map[string]string) *AnalysisResult {
+	ids := anonymousIDs(result)
+	anonymized := *result
+
+	anonymized.ResponseAnalyses = make(map[string]ResponseAnalysis, len(result.ResponseAnalyses))
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		response := responseAnalysis.Response
+		response.ID = ids.response[id]
+		response.RowIndex = ids.rows[response.RowIndex]
+		if response.Document != "" {
+			response.Document = fmt.Sprintf("R%d", response.RowIndex)
+		}
+		response.Text = paraphrases[id]
+		response.Original = ""
+		response.Hash = ""
+		response.Timestamp = time.Time{}
+		responseAnalysis.Response = response
+		responseAnalysis.Reason = ""
+		responseAnalysis.Truncations = nil
+		anonymized.ResponseAnalyses[response.ID] = responseAnalysis
+	}
+
+	anonymized.ThemeAnalyses = make(map[string]ThemeAnalysis, len(result.ThemeAnalyses))
+	for theme, themeAnalysis := range result.ThemeAnalyses {
+		themeAnalysis.Responses = ids.list(themeAnalysis.Responses)
+		anonymized.ThemeAnalyses[theme] = themeAnalysis
+	}
+	anonymized.ProposedThemes = slices.Clone(result.ProposedThemes)
+	for i, proposed := range result.ProposedThemes {
+		proposed.Responses = ids.list(proposed.Responses)
+		anonymized.ProposedThemes[i] = proposed
+	}
+	anonymized.ThemeSplits = make([]ThemeSplit, len(result.ThemeSplits))
+	for i, split := range result.ThemeSplits {
+		responses := make(map[string][]string, len(split.Responses))
+		for subTheme, subThemeIDs := range split.Responses {
+			responses[subTheme] = ids.list(subThemeIDs)
+		}
+		split.Responses = responses
+		anonymized.ThemeSplits[i] = split
+	}
+	anonymized.Outliers = make([]Outlier, len(result.Outliers))
+	for i, outlier := range result.Outliers {
+		outlier.ResponseID = ids.response[outlier.ResponseID]
+		anonymized.Outliers[i] = outlier
+	}
+	anonymized.Clusters = make([]Cluster, len(result.Clusters))
+	for i, cluster := range result.Clusters {
+		cluster.Responses = ids.list(cluster.Responses)
+		anonymized.Clusters[i] = cluster
+	}
+	anonymized.Topics = make([]Topic, len(result.Topics))
+	for i, topic := range result.Topics {
+		topic.Responses = ids.list(topic.Responses)
+		anonymized.Topics[i] = topic
+	}
+	anonymized.FailedResponses = nil
+	for _, failed := range result.FailedResponses {
+		if id, ok := ids.response[failed.ID]; ok {
+			anonymized.FailedResponses = append(anonymized.FailedResponses, FailedResponse{ID: id, Row: ids.rows[failed.Row], Error: failed.Error})
+		}
+	}
+
+	// Renumber the citations of the summaries
+	anonymized.Summary = ids.citations(result.Summary)
+	anonymized.GlobalSummary = ids.citations(result.GlobalSummary)
+	anonymized.SummaryCitations = ids.citationList(result.SummaryCitations)
+	anonymized.SummaryVariants = make([]SummaryVariant, len(result.SummaryVariants))
+	for i, variant := range result.SummaryVariants {
+		variant.Summary = ids.citations(variant.Summary)
+		variant.Citations = ids.citationList(variant.Citations)
+		anonymized.SummaryVariants[i] = variant
+	}
+	anonymized.AudienceSummaries = make([]AudienceSummary, len(result.AudienceSummaries))
+	for i, audience := range result.AudienceSummaries {
+		audience.Summary = ids.citations(audience.Summary)
+		anonymized.AudienceSummaries[i] = audience
+	}
+	anonymized.LanguageSummaries = make(map[string]LanguageSummaries, len(result.LanguageSummaries))
+	for language, summaries := range result.LanguageSummaries {
+		summaries.GlobalSummary = ids.citations(summaries.GlobalSummary)
+		summaries.ThemeSummaries = maps.Clone(summaries.ThemeSummaries)
+		anonymized.LanguageSummaries[language] = summaries
+	}
+
+	anonymized.Run.RunID = ""
+	anonymized.Run.UsageTag = ""
+	anonymized.Embeddings = nil
+	anonymized.Warnings = nil
+	anonymized.DroppedWarnings = 0
+	anonymized.ForgottenResponses = nil
+	return &anonymized
+}
+
+// anonymizedIDs maps the IDs and rows of the responses of a result to those
+// of the anonymized result
+type anonymizedIDs struct {
+	response map[string]string
+	rows     map[int]int
+}
+
+// anonymousIDs numbers the rows of the responses in their order and derives
+// the IDs of the responses from the numbers
+func anonymousIDs(result *AnalysisResult) anonymizedIDs {
+	var rows []int
+	seen := make(map[int]bool)
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		if row := responseAnalysis.Response.RowIndex; !seen[row] {
+			seen[row] = true
+			rows = append(rows, row)
+		}
+	}
+	sort.Ints(rows)
+
+	ids := anonymizedIDs{response: make(map[string]string, len(result.ResponseAnalyses)), rows: make(map[int]int, len(rows))}
+	for i, row := range rows {
+		ids.rows[row] = i + 1
+	}
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		response := responseAnalysis.Response
+		anonymous := fmt.Sprintf("R%d", ids.rows[response.RowIndex])
+		if response.Segment > 0 {
+			anonymous += fmt.Sprintf(".%d", response.Segment)
+		}
+		ids.response[id] = anonymous
+	}
+	return ids
+}
+
+// list maps a list of response IDs, leaving out those of unknown responses
+func (ids anonymizedIDs) list(responseIDs []string) []string {
+	if responseIDs == nil {
+		return nil
+	}
+	mapped := make([]string, 0, len(responseIDs))
+	for _, id := range responseIDs {
+		if anonymous, ok := ids.response[id]; ok {
+			mapped = append(mapped, anonymous)
+		}
+	}
+	return mapped
+}
+
+// responseMarkerPattern matches the citation of a response in a summary,
+// e.g. [R15], [R15.2] or [P0123456789ab]
+var responseMarkerPattern = regexp.MustCompile(`\[(` + ResponseIDPattern + `)\]`)
+
+// citations renumbers the citations of responses in a summary, removing
+// those of unknown responses
+func (ids anonymizedIDs) citations(summary string) string {
+	return responseMarkerPattern.ReplaceAllStringFunc(summary, func(match string) string {
+		if anonymous, ok := ids.response[match[1:len(match)-1]]; ok {
+			return "[" + anonymous + "]"
+		}
+		return ""
+	})
+}
+
+// citationList renumbers the citations of responses
+func (ids anonymizedIDs) citationList(citations []Citation) []Citation {
+	var mapped []Citation
+	for _, citation := range citations {
+		if citation.ResponseID != "" {
+			anonymous, ok := ids.response[citation.ResponseID]
+			if !ok {
+				continue
+			}
+			citation.Marker, citation.ResponseID = anonymous, anonymous
+		}
+		mapped = append(mapped, citation)
+	}
+	return mapped
+}
+
+// ParaphraseResponses paraphrases the texts of the responses for an
+// anonymized result, in batches of batch_size and in the order of the
+// responses, so the same result gives the same batches. The paraphrases are
+// returned by response ID.
+func (a *Analyzer) ParaphraseResponses(result *AnalysisResult) (map[string]string, error) {
+	responses := make([]ResponseAnalysis, 0, len(result.ResponseAnalyses))
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		if responseAnalysis.Response.Text != "" {
+			responses = append(responses, responseAnalysis)
+		}
+	}
+	sort.Slice(responses, func(i, j int) bool { return responses[i].Response.Before(responses[j].Response) })
+
+	batchSize := a.batchSize
+	if batchSize <= 0 {
+		batchSize = 10
+	}
+	a.logger.Info("Paraphrasing the responses", "responses", len(responses), "batch_size", batchSize)
+
+	paraphrases := make(map[string]string, len(responses))
+	for start := 0; start < len(responses); start += batchSize {
+		batch := responses[start:min(start+batchSize, len(responses))]
+		texts := make([]string, len(batch))
+		for i, responseAnalysis := range batch {
+			texts[i] = responseAnalysis.Response.Text
+		}
+		batchParaphrases, err := a.claudeClient.ParaphraseResponses(texts)
+		if err != nil {
+			return nil, err
+		}
+		for i, responseAnalysis := range batch {
+			if batchParaphrases[i] == "" {
+				return nil, fmt.Errorf("no paraphrase of response %s in the answer", responseAnalysis.Response.ID)
+			}
+			paraphrases[responseAnalysis.Response.ID] = batchParaphrases[i]
+		}
+	}
+	return paraphrases, nil
+}
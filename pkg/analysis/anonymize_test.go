@@ -0,0 +1,96 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// anonymizeFixture returns a result with a plain response, a response split
+// into two segments and a pseudonymized response
+func anonymizeFixture() *AnalysisResult {
+	submitted := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	response := func(id string, row int, text, document string, segment int) ResponseAnalysis {
+		return ResponseAnalysis{
+			Response: excel.Response{ID: id, Text: text, RowIndex: row, Hash: "hash-" + id, Timestamp: submitted, Original: text + "!", Document: document, Segment: segment},
+			Themes:   []string{"Delivery"},
+			Reason:   "Mentions " + text,
+		}
+	}
+	return &AnalysisResult{
+		Themes: []string{"Delivery"},
+		ResponseAnalyses: map[string]ResponseAnalysis{
+			"R7":            response("R7", 7, "Slow delivery", "", 0),
+			"R12.1":         response("R12.1", 12, "Late parcel", "R12", 1),
+			"R12.2":         response("R12.2", 12, "Broken box", "R12", 2),
+			"Pabcdef012345": response("Pabcdef012345", 30, "Never arrived", "", 0),
+		},
+		ThemeAnalyses: map[string]ThemeAnalysis{
+			"Delivery": {Theme: "Delivery", Responses: []string{"R7", "R12.1", "R12.2", "Pabcdef012345"}},
+		},
+		GlobalSummary:    "Parcels arrive late [R12.1] or broken [R12.2], slowly [R7], or never [Pabcdef012345], see [T1] and [R99].",
+		SummaryCitations: []Citation{{Marker: "T1", Theme: "Delivery"}, {Marker: "R12.1", ResponseID: "R12.1"}, {Marker: "R99", ResponseID: "R99"}},
+	}
+}
+
+func TestAnonymizeRenumbersResponses(t *testing.T) {
+	result := anonymizeFixture()
+	anonymized := Anonymize(result, map[string]string{"R12.1": "A parcel came late"})
+
+	want := map[string]struct {
+		row      int
+		text     string
+		document string
+	}{
+		"R1":   {1, "", ""},
+		"R2.1": {2, "A parcel came late", "R2"},
+		"R2.2": {2, "", "R2"},
+		"R3":   {3, "", ""},
+	}
+	if len(anonymized.ResponseAnalyses) != len(want) {
+		t.Fatalf("got %d responses, want %d", len(anonymized.ResponseAnalyses), len(want))
+	}
+	for id, expected := range want {
+		responseAnalysis, ok := anonymized.ResponseAnalyses[id]
+		if !ok {
+			t.Fatalf("response %s is missing", id)
+		}
+		response := responseAnalysis.Response
+		if response.ID != id || response.RowIndex != expected.row || response.Text != expected.text || response.Document != expected.document {
+			t.Errorf("response %s = %+v, want row %d, text %q, document %q", id, response, expected.row, expected.text, expected.document)
+		}
+		if response.Hash != "" || response.Original != "" || !response.Timestamp.IsZero() || responseAnalysis.Reason != "" {
+			t.Errorf("response %s keeps identifying data: %+v, reason %q", id, response, responseAnalysis.Reason)
+		}
+	}
+
+	if got := anonymized.ThemeAnalyses["Delivery"].Responses; !reflect.DeepEqual(got, []string{"R1", "R2.1", "R2.2", "R3"}) {
+		t.Errorf("theme responses = %v", got)
+	}
+	if _, ok := result.ResponseAnalyses["R7"]; !ok || result.ResponseAnalyses["R12.1"].Response.Text != "Late parcel" {
+		t.Error("the original result was modified")
+	}
+}
+
+func TestAnonymizeRenumbersCitations(t *testing.T) {
+	anonymized := Anonymize(anonymizeFixture(), nil)
+
+	want := "Parcels arrive late [R2.1] or broken [R2.2], slowly [R1], or never [R3], see [T1] and ."
+	if anonymized.GlobalSummary != want {
+		t.Errorf("global summary = %q, want %q", anonymized.GlobalSummary, want)
+	}
+	wantCitations := []Citation{{Marker: "T1", Theme: "Delivery"}, {Marker: "R2.1", ResponseID: "R2.1"}}
+	if !reflect.DeepEqual(anonymized.SummaryCitations, wantCitations) {
+		t.Errorf("citations = %+v, want %+v", anonymized.SummaryCitations, wantCitations)
+	}
+}
+
+func TestAnonymizeIsStable(t *testing.T) {
+	first := Anonymize(anonymizeFixture(), nil)
+	second := Anonymize(anonymizeFixture(), nil)
+	if !reflect.DeepEqual(first, second) {
+		t.Error("anonymizing the same result twice gives different results")
+	}
+}
@@ -0,0 +1,48 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// AppendStartRow returns the first row of the input to read in append mode:
+// the row after the last analyzed row, or an earlier row whose batch failed
+// in the previous run and needs to be matched again
+func AppendStartRow(previous *AnalysisResult) int {
+	last := 0
+	for _, responseAnalysis := range previous.ResponseAnalyses {
+		last = max(last, responseAnalysis.Response.RowIndex)
+	}
+	start := last + 1
+	for _, failed := range previous.FailedResponses {
+		if failed.Row > 0 {
+			start = min(start, failed.Row)
+		}
+	}
+	return start
+}
+
+// AppendResponses merges the responses read in append mode into those of the
+// previous result. The analyzed responses are taken from the previous result
+// even if their rows changed or are gone, the read ones are added unless
+// already analyzed. It returns the merged responses in row order and the
+// number of added responses.
+func AppendResponses(previous *AnalysisResult, read []excel.Response) ([]excel.Response, int) {
+	merged := make([]excel.Response, 0, len(previous.ResponseAnalyses)+len(read))
+	for _, responseAnalysis := range previous.ResponseAnalyses {
+		merged = append(merged, responseAnalysis.Response)
+	}
+	added := 0
+	for _, response := range read {
+		if _, ok := previous.ResponseAnalyses[response.ID]; ok {
+			continue
+		}
+		merged = append(merged, response)
+		added++
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Before(merged[j])
+	})
+	return merged, added
+}
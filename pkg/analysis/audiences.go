@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+)
+
+// AudienceSummary is the global summary written for a stakeholder group
+type AudienceSummary struct {
+	Audience    string    `yaml:"audience"`
+	Summary     string    `yaml:"summary"`
+	Settings    string    `yaml:"settings"` // Hash of the prompt and length the summary was generated with
+	GeneratedAt time.Time `yaml:"generated_at"`
+}
+
+// audienceSettings returns the hash of the settings of an audience summary
+func audienceSettings(audience config.SummaryAudience, length int) string {
+	return shortHash(fmt.Sprintf("%s\n%d", audience.Prompt, length))
+}
+
+// GenerateAudienceSummaries generates the global summaries of the configured
+// audiences from the theme summaries of the result, concurrently. The
+// summaries of previous are reused for audiences whose prompt and length did
+// not change; pass nil when the theme summaries were regenerated.
+func (a *Analyzer) GenerateAudienceSummaries(result *AnalysisResult, cfg *config.Config, previous []AudienceSummary) error {
+	result.AudienceSummaries = nil
+	if len(cfg.SummaryAudiences) == 0 || len(result.Themes) == 0 {
+		return nil
+	}
+
+	summaries := make([]AudienceSummary, len(cfg.SummaryAudiences))
+	errs := make([]error, len(cfg.SummaryAudiences))
+	var wg sync.WaitGroup
+	for i, audience := range cfg.SummaryAudiences {
+		length := audience.Length
+		if length == 0 {
+			length = cfg.SummaryLength
+		}
+		settings := audienceSettings(audience, length)
+		if reused, ok := findAudienceSummary(previous, audience.Name); ok && reused.Settings == settings {
+			a.logger.Info("Reusing audience summary from previous result", "audience", audience.Name)
+			summaries[i] = reused
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, audience config.SummaryAudience) {
+			defer wg.Done()
+			a.logger.Info("Generating audience summary", "audience", audience.Name, "length", length)
+			summary, err := a.claudeClient.GenerateGlobalSummary(result.ThemeSummaries, audience.Prompt, length)
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to generate the summary of audience %s: %w", audience.Name, err)
+				return
+			}
+			summaries[i] = AudienceSummary{Audience: audience.Name, Summary: summary, Settings: settings, GeneratedAt: time.Now()}
+		}(i, audience)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	result.AudienceSummaries = summaries
+	return nil
+}
+
+// findAudienceSummary returns the summary of the named audience
+func findAudienceSummary(summaries []AudienceSummary, audience string) (AudienceSummary, bool) {
+	for _, summary := range summaries {
+		if summary.Audience == audience {
+			return summary, true
+		}
+	}
+	return AudienceSummary{}, false
+}
+
+// ForAudience returns a copy of the result with the global summary replaced
+// by the summary of the audience, for rendering its report. Citations and
+// the verification report refer to the global summary and are left out.
+func (r *AnalysisResult) ForAudience(audience string) (*AnalysisResult, error) {
+	summary, ok := findAudienceSummary(r.AudienceSummaries, audience)
+	if !ok {
+		return nil, fmt.Errorf("the state has no summary for audience %s", audience)
+	}
+
+	tailored := *r
+	tailored.GlobalSummary = summary.Summary
+	tailored.Summary = summary.Summary
+	tailored.SummaryCitations = nil
+	tailored.SummaryVariants = nil
+	tailored.Verification = nil
+	return &tailored, nil
+}
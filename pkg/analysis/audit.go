@@ -0,0 +1,164 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// AuditCorrections is the outcome of applying an edited audit log
+type AuditCorrections struct {
+	Corrected []string // Responses whose themes changed, in row order
+	Locked    []string // Responses whose themes were locked, in row order
+	Unlocked  []string // Responses whose themes were unlocked, in row order
+	NotFound  []string // Responses of the audit log missing in the state
+}
+
+// ApplyCorrections takes the themes of the responses in an edited audit log
+// into the result. Responses whose themes differ are marked as corrected,
+// and the summaries of the themes they left or joined are marked as stale,
+// so they are regenerated on the next run. Theme names are matched to the
+// theme list ignoring case; unknown names are an error, so no edit is lost.
+// Entries that say whether the themes are locked lock or unlock them.
+func ApplyCorrections(result *AnalysisResult, codings []excel.Coding) (*AuditCorrections, error) {
+	byRow := make(map[int]string, len(result.ResponseAnalyses))
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		byRow[responseAnalysis.Response.RowIndex] = id
+	}
+
+	// Resolve all entries first, so an error leaves the result unchanged
+	corrections := &AuditCorrections{}
+	edited := make(map[string][]string)
+	locks := make(map[string]bool)
+	unknown := make(map[string]bool)
+	for _, coding := range codings {
+		if coding.ID == "" {
+			coding.ID = byRow[coding.Row]
+		}
+		if _, ok := result.ResponseAnalyses[coding.ID]; !ok {
+			if coding.ID == "" {
+				coding.ID = fmt.Sprintf("row %d", coding.Row)
+			}
+			corrections.NotFound = append(corrections.NotFound, coding.ID)
+			continue
+		}
+		themes := []string{}
+		for _, name := range coding.Themes {
+			theme, ok := resolveCodedTheme(name, result.Themes)
+			if !ok {
+				unknown[name] = true
+				continue
+			}
+			if !slices.Contains(themes, theme) {
+				themes = append(themes, theme)
+			}
+		}
+		edited[coding.ID] = themes
+		if coding.Locked != nil {
+			locks[coding.ID] = *coding.Locked
+		}
+	}
+	if len(unknown) > 0 {
+		names := make([]string, 0, len(unknown))
+		for name := range unknown {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown themes in the audit log: %s (valid themes: %s)", strings.Join(names, ", "), strings.Join(result.Themes, ", "))
+	}
+
+	stale := make(map[string]bool)
+	for _, theme := range result.StaleThemeSummaries {
+		stale[theme] = true
+	}
+	for id, themes := range edited {
+		responseAnalysis := result.ResponseAnalyses[id]
+		if sameThemes(responseAnalysis.Themes, themes) {
+			continue
+		}
+		for _, theme := range responseAnalysis.Themes {
+			stale[theme] = true
+			if themeAnalysis, ok := result.ThemeAnalyses[theme]; ok {
+				themeAnalysis.Responses = slices.DeleteFunc(themeAnalysis.Responses, func(other string) bool { return other == id })
+				result.ThemeAnalyses[theme] = themeAnalysis
+			}
+		}
+		for _, theme := range themes {
+			stale[theme] = true
+			if themeAnalysis, ok := result.ThemeAnalyses[theme]; ok {
+				themeAnalysis.Responses = append(themeAnalysis.Responses, id)
+				result.ThemeAnalyses[theme] = themeAnalysis
+			}
+		}
+
+		// The justification and agreement were those of the model's themes
+		responseAnalysis.Themes = themes
+		responseAnalysis.Reason = ""
+		responseAnalysis.Agreement = 0
+		responseAnalysis.Corrected = true
+		result.ResponseAnalyses[id] = responseAnalysis
+		corrections.Corrected = append(corrections.Corrected, id)
+	}
+
+	// Locking changes no themes, so it leaves the summaries as they are
+	for id, locked := range locks {
+		responseAnalysis := result.ResponseAnalyses[id]
+		if responseAnalysis.Locked == locked {
+			continue
+		}
+		responseAnalysis.Locked = locked
+		result.ResponseAnalyses[id] = responseAnalysis
+		if locked {
+			corrections.Locked = append(corrections.Locked, id)
+		} else {
+			corrections.Unlocked = append(corrections.Unlocked, id)
+		}
+	}
+	for _, ids := range [][]string{corrections.Corrected, corrections.Locked, corrections.Unlocked} {
+		sort.Slice(ids, func(i, j int) bool {
+			return result.ResponseAnalyses[ids[i]].Response.Before(result.ResponseAnalyses[ids[j]].Response)
+		})
+	}
+	if len(corrections.Corrected) == 0 {
+		return corrections, nil
+	}
+
+	// Proposals only stand for responses that are left without a theme
+	for i := range result.ProposedThemes {
+		result.ProposedThemes[i].Responses = slices.DeleteFunc(result.ProposedThemes[i].Responses, func(id string) bool {
+			return len(result.ResponseAnalyses[id].Themes) > 0
+		})
+		result.ProposedThemes[i].Count = len(result.ProposedThemes[i].Responses)
+	}
+	refreshStatistics(result)
+
+	// Mark the summaries of the affected themes as stale
+	result.StaleThemeSummaries = nil
+	for _, theme := range result.Themes {
+		if stale[theme] {
+			result.StaleThemeSummaries = append(result.StaleThemeSummaries, theme)
+		}
+	}
+	result.StaleGlobalSummary = true
+
+	// Summaries in additional languages cannot be marked stale, drop them
+	result.LanguageSummaries = nil
+
+	return corrections, nil
+}
+
+// sameThemes reports whether two theme lists hold the same themes
+func sameThemes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, theme := range a {
+		if !slices.Contains(b, theme) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,215 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// maxConfusionPairs limits the confusion pairs listed in a coding agreement
+const maxConfusionPairs = 20
+
+// CodedThemeAgreement compares the assignments of a theme by the coders and
+// the model, treating the coders as the reference
+type CodedThemeAgreement struct {
+	Theme     string  `yaml:"theme"`
+	Coded     int     `yaml:"coded"`     // Responses the coders assigned the theme to
+	Matched   int     `yaml:"matched"`   // Responses the model assigned the theme to
+	Both      int     `yaml:"both"`      // Responses both assigned the theme to
+	Precision float64 `yaml:"precision"` // Share of the model's assignments the coders agree with
+	Recall    float64 `yaml:"recall"`    // Share of the coders' assignments the model found
+	F1        float64 `yaml:"f1"`
+	Kappa     float64 `yaml:"kappa"`
+}
+
+// ConfusionPair counts responses where the coders assigned one theme and
+// the model another one instead
+type ConfusionPair struct {
+	CodedTheme   string `yaml:"coded_theme"`
+	MatchedTheme string `yaml:"matched_theme"`
+	Count        int    `yaml:"count"`
+}
+
+// CodingAgreement compares externally coded theme assignments with those of
+// the model, for validating the analysis against human coders
+type CodingAgreement struct {
+	GeneratedAt    time.Time             `yaml:"generated_at"`
+	CodedFile      string                `yaml:"coded_file"`
+	Compared       int                   `yaml:"compared"`                 // Coded responses found in the result
+	NotFound       []string              `yaml:"not_found,omitempty"`      // Coded response IDs missing from the result
+	UnknownThemes  []string              `yaml:"unknown_themes,omitempty"` // Coded themes that are not in the theme list
+	ExactAgreement float64               `yaml:"exact_agreement"`          // Share of responses with identical themes
+	MeanJaccard    float64               `yaml:"mean_jaccard"`             // Mean overlap of the theme sets
+	MeanKappa      float64               `yaml:"mean_kappa"`               // Mean Cohen's kappa over the themes
+	MacroF1        float64               `yaml:"macro_f1"`                 // Mean F1 over the themes
+	Themes         []CodedThemeAgreement `yaml:"themes"`
+	Confusions     []ConfusionPair       `yaml:"confusions,omitempty"` // Most frequent confusions first
+}
+
+// themeVariable matches the theme variables of the wide export
+var themeVariable = regexp.MustCompile(`^theme_(\d+)$`)
+
+// CompareCodings compares the theme assignments of external coders with the
+// response analyses of a result. Coded theme names are matched to the theme
+// list ignoring case, and the theme_NN variables of the wide export are
+// resolved to the theme at that position.
+func CompareCodings(result *AnalysisResult, codings []excel.Coding, codedFile string) (*CodingAgreement, error) {
+	agreement := &CodingAgreement{GeneratedAt: time.Now(), CodedFile: codedFile}
+	unknown := make(map[string]bool)
+
+	byRow := make(map[int]string, len(result.ResponseAnalyses))
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		byRow[responseAnalysis.Response.RowIndex] = id
+	}
+
+	coded := make(map[string][]string)
+	var ids []string
+	for _, coding := range codings {
+		if coding.ID == "" {
+			coding.ID = byRow[coding.Row]
+		}
+		if _, ok := result.ResponseAnalyses[coding.ID]; !ok {
+			if coding.ID == "" {
+				coding.ID = fmt.Sprintf("row %d", coding.Row)
+			}
+			agreement.NotFound = append(agreement.NotFound, coding.ID)
+			continue
+		}
+		var themes []string
+		for _, name := range coding.Themes {
+			theme, ok := resolveCodedTheme(name, result.Themes)
+			if !ok {
+				unknown[name] = true
+				continue
+			}
+			if !slices.Contains(themes, theme) {
+				themes = append(themes, theme)
+			}
+		}
+		if _, ok := coded[coding.ID]; !ok {
+			ids = append(ids, coding.ID)
+		}
+		coded[coding.ID] = themes
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("none of the %d coded responses is in the result, check the id or row column", len(codings))
+	}
+	agreement.Compared = len(ids)
+	for name := range unknown {
+		agreement.UnknownThemes = append(agreement.UnknownThemes, name)
+	}
+	sort.Strings(agreement.UnknownThemes)
+
+	// Only themes of the current list count, split themes may be gone
+	matched := make(map[string][]string, len(ids))
+	for _, id := range ids {
+		for _, theme := range result.ResponseAnalyses[id].Themes {
+			if slices.Contains(result.Themes, theme) {
+				matched[id] = append(matched[id], theme)
+			}
+		}
+	}
+
+	exact := 0
+	jaccardSum := 0.0
+	confusions := make(map[[2]string]int)
+	for _, id := range ids {
+		jaccard := jaccardIndex(coded[id], matched[id])
+		jaccardSum += jaccard
+		if jaccard == 1 {
+			exact++
+			continue
+		}
+		for _, codedTheme := range coded[id] {
+			if slices.Contains(matched[id], codedTheme) {
+				continue
+			}
+			for _, matchedTheme := range matched[id] {
+				if !slices.Contains(coded[id], matchedTheme) {
+					confusions[[2]string{codedTheme, matchedTheme}]++
+				}
+			}
+		}
+	}
+	agreement.ExactAgreement = float64(exact) / float64(len(ids))
+	agreement.MeanJaccard = jaccardSum / float64(len(ids))
+
+	kappaSum, f1Sum := 0.0, 0.0
+	for _, theme := range result.Themes {
+		themeAgreement := CodedThemeAgreement{Theme: theme}
+		bothNo := 0
+		for _, id := range ids {
+			inCoded := slices.Contains(coded[id], theme)
+			inMatched := slices.Contains(matched[id], theme)
+			if inCoded {
+				themeAgreement.Coded++
+			}
+			if inMatched {
+				themeAgreement.Matched++
+			}
+			switch {
+			case inCoded && inMatched:
+				themeAgreement.Both++
+			case !inCoded && !inMatched:
+				bothNo++
+			}
+		}
+		if themeAgreement.Matched > 0 {
+			themeAgreement.Precision = float64(themeAgreement.Both) / float64(themeAgreement.Matched)
+		}
+		if themeAgreement.Coded > 0 {
+			themeAgreement.Recall = float64(themeAgreement.Both) / float64(themeAgreement.Coded)
+		}
+		if themeAgreement.Precision+themeAgreement.Recall > 0 {
+			themeAgreement.F1 = 2 * themeAgreement.Precision * themeAgreement.Recall / (themeAgreement.Precision + themeAgreement.Recall)
+		}
+		themeAgreement.Kappa = cohensKappa(len(ids), themeAgreement.Both, bothNo, themeAgreement.Coded, themeAgreement.Matched)
+		kappaSum += themeAgreement.Kappa
+		f1Sum += themeAgreement.F1
+		agreement.Themes = append(agreement.Themes, themeAgreement)
+	}
+	if len(result.Themes) > 0 {
+		agreement.MeanKappa = kappaSum / float64(len(result.Themes))
+		agreement.MacroF1 = f1Sum / float64(len(result.Themes))
+	}
+
+	for pair, count := range confusions {
+		agreement.Confusions = append(agreement.Confusions, ConfusionPair{CodedTheme: pair[0], MatchedTheme: pair[1], Count: count})
+	}
+	sort.Slice(agreement.Confusions, func(i, j int) bool {
+		a, b := agreement.Confusions[i], agreement.Confusions[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.CodedTheme != b.CodedTheme {
+			return a.CodedTheme < b.CodedTheme
+		}
+		return a.MatchedTheme < b.MatchedTheme
+	})
+	if len(agreement.Confusions) > maxConfusionPairs {
+		agreement.Confusions = agreement.Confusions[:maxConfusionPairs]
+	}
+
+	return agreement, nil
+}
+
+// resolveCodedTheme returns the theme of the list a coded theme name refers to
+func resolveCodedTheme(name string, themes []string) (string, bool) {
+	for _, theme := range themes {
+		if strings.EqualFold(strings.TrimSpace(theme), name) {
+			return theme, true
+		}
+	}
+	if match := themeVariable.FindStringSubmatch(name); match != nil {
+		if index, err := strconv.Atoi(match[1]); err == nil && index >= 1 && index <= len(themes) {
+			return themes[index-1], true
+		}
+	}
+	return "", false
+}
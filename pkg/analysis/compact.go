@@ -0,0 +1,25 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// OrphanedResponses returns the IDs of the analyzed responses that are no
+// longer part of the input, sorted by ID
+func OrphanedResponses(result *AnalysisResult, responses []excel.Response) []string {
+	present := make(map[string]bool, len(responses))
+	for _, response := range responses {
+		present[response.ID] = true
+	}
+
+	var orphaned []string
+	for id := range result.ResponseAnalyses {
+		if !present[id] {
+			orphaned = append(orphaned, id)
+		}
+	}
+	sort.Strings(orphaned)
+	return orphaned
+}
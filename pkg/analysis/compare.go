@@ -0,0 +1,182 @@
+package analysis
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// maxListedDisagreements limits the disagreements listed in a comparison
+const maxListedDisagreements = 20
+
+// ModelRun is the theme matching of a sample by one model
+type ModelRun struct {
+	Model     string                      `yaml:"model"`
+	Seconds   float64                     `yaml:"seconds"` // Wall-clock time of the matching
+	Cost      float64                     `yaml:"cost"`
+	Tokens    int                         `yaml:"tokens"`
+	Failed    int                         `yaml:"failed_responses,omitempty"` // Sampled responses the model did not classify
+	Error     string                      `yaml:"error,omitempty"`
+	Analyses  map[string]ResponseAnalysis `yaml:"-"`
+	Responses int                         `yaml:"-"`
+}
+
+// SecondsPerResponse returns the mean wall-clock time per sampled response
+func (r ModelRun) SecondsPerResponse() float64 {
+	if r.Responses == 0 {
+		return 0
+	}
+	return r.Seconds / float64(r.Responses)
+}
+
+// ThemeAgreement is the agreement of two models on one theme
+type ThemeAgreement struct {
+	Theme       string  `yaml:"theme"`
+	FirstCount  int     `yaml:"first_count"`
+	SecondCount int     `yaml:"second_count"`
+	Kappa       float64 `yaml:"kappa"` // Cohen's kappa of the binary assignment
+}
+
+// Disagreement is a response the models assigned different themes to
+type Disagreement struct {
+	ResponseID   string   `yaml:"response_id"`
+	FirstThemes  []string `yaml:"first_themes"`
+	SecondThemes []string `yaml:"second_themes"`
+}
+
+// ModelComparison compares the theme matching of two models on a sample
+type ModelComparison struct {
+	GeneratedAt    time.Time        `yaml:"generated_at"`
+	SampleSize     int              `yaml:"sample_size"`
+	Compared       int              `yaml:"compared_responses"` // Responses classified by both models
+	Runs           []ModelRun       `yaml:"runs"`
+	ExactAgreement float64          `yaml:"exact_agreement"` // Share of responses with identical theme sets
+	MeanJaccard    float64          `yaml:"mean_jaccard"`    // Mean overlap of the theme sets
+	MeanKappa      float64          `yaml:"mean_kappa"`      // Mean of the per-theme kappas
+	Themes         []ThemeAgreement `yaml:"themes"`
+	Disagreements  []Disagreement   `yaml:"disagreements,omitempty"`
+}
+
+// SampleResponses returns a reproducible random sample of the responses in
+// their original order
+func SampleResponses(responses []excel.Response, size int, seed int64) []excel.Response {
+	if size <= 0 || size >= len(responses) {
+		return responses
+	}
+	picked := rand.New(rand.NewSource(seed)).Perm(len(responses))[:size]
+	sort.Ints(picked)
+	sample := make([]excel.Response, size)
+	for i, index := range picked {
+		sample[i] = responses[index]
+	}
+	return sample
+}
+
+// CompareModelRuns computes the agreement of two model runs on the sampled
+// responses
+func CompareModelRuns(sample []excel.Response, themes []string, first, second ModelRun) ModelComparison {
+	comparison := ModelComparison{
+		GeneratedAt: time.Now(),
+		SampleSize:  len(sample),
+		Runs:        []ModelRun{first, second},
+	}
+
+	// Only responses classified by both models are compared
+	ids := []string{}
+	for _, response := range sample {
+		_, inFirst := first.Analyses[response.ID]
+		_, inSecond := second.Analyses[response.ID]
+		if inFirst && inSecond {
+			ids = append(ids, response.ID)
+		}
+	}
+	comparison.Compared = len(ids)
+	if len(ids) == 0 {
+		return comparison
+	}
+
+	exact := 0
+	jaccardSum := 0.0
+	for _, id := range ids {
+		firstThemes := first.Analyses[id].Themes
+		secondThemes := second.Analyses[id].Themes
+		jaccard := jaccardIndex(firstThemes, secondThemes)
+		jaccardSum += jaccard
+		if jaccard == 1 {
+			exact++
+		} else if len(comparison.Disagreements) < maxListedDisagreements {
+			comparison.Disagreements = append(comparison.Disagreements, Disagreement{
+				ResponseID:   id,
+				FirstThemes:  firstThemes,
+				SecondThemes: secondThemes,
+			})
+		}
+	}
+	comparison.ExactAgreement = float64(exact) / float64(len(ids))
+	comparison.MeanJaccard = jaccardSum / float64(len(ids))
+
+	kappaSum := 0.0
+	for _, theme := range themes {
+		agreement := ThemeAgreement{Theme: theme}
+		bothYes, bothNo := 0, 0
+		for _, id := range ids {
+			inFirst := slices.Contains(first.Analyses[id].Themes, theme)
+			inSecond := slices.Contains(second.Analyses[id].Themes, theme)
+			if inFirst {
+				agreement.FirstCount++
+			}
+			if inSecond {
+				agreement.SecondCount++
+			}
+			switch {
+			case inFirst && inSecond:
+				bothYes++
+			case !inFirst && !inSecond:
+				bothNo++
+			}
+		}
+		agreement.Kappa = cohensKappa(len(ids), bothYes, bothNo, agreement.FirstCount, agreement.SecondCount)
+		kappaSum += agreement.Kappa
+		comparison.Themes = append(comparison.Themes, agreement)
+	}
+	if len(themes) > 0 {
+		comparison.MeanKappa = kappaSum / float64(len(themes))
+	}
+
+	return comparison
+}
+
+// jaccardIndex returns the overlap of two theme sets; two empty sets agree
+func jaccardIndex(a, b []string) float64 {
+	union := make(map[string]bool)
+	for _, theme := range a {
+		union[theme] = true
+	}
+	shared := 0
+	for _, theme := range b {
+		if union[theme] {
+			shared++
+		}
+		union[theme] = true
+	}
+	if len(union) == 0 {
+		return 1
+	}
+	return float64(shared) / float64(len(union))
+}
+
+// cohensKappa returns Cohen's kappa of two binary ratings of n items. When
+// the expected agreement is perfect, e.g. both never assign the theme, the
+// observed agreement is returned.
+func cohensKappa(n, bothYes, bothNo, firstYes, secondYes int) float64 {
+	total := float64(n)
+	observed := float64(bothYes+bothNo) / total
+	expected := (float64(firstYes)*float64(secondYes) + float64(n-firstYes)*float64(n-secondYes)) / (total * total)
+	if expected == 1 {
+		return observed
+	}
+	return (observed - expected) / (1 - expected)
+}
@@ -0,0 +1,51 @@
+package analysis
+
+// CoOccurrence counts how often two themes are assigned to the same response
+type CoOccurrence struct {
+	Themes []string // In the order of the theme list
+	Counts [][]int  // Counts[i][j] responses with the themes i and j, Counts[i][i] all responses with theme i
+}
+
+// Count returns the number of responses with both themes, 0 for unknown themes
+func (c *CoOccurrence) Count(a, b string) int {
+	i, j := -1, -1
+	for k, theme := range c.Themes {
+		if theme == a {
+			i = k
+		}
+		if theme == b {
+			j = k
+		}
+	}
+	if i < 0 || j < 0 {
+		return 0
+	}
+	return c.Counts[i][j]
+}
+
+// ThemeCoOccurrence counts the responses assigned to each pair of themes
+func ThemeCoOccurrence(result *AnalysisResult) *CoOccurrence {
+	index := make(map[string]int, len(result.Themes))
+	for i, theme := range result.Themes {
+		index[theme] = i
+	}
+	counts := make([][]int, len(result.Themes))
+	for i := range counts {
+		counts[i] = make([]int, len(result.Themes))
+	}
+
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		var assigned []int
+		for _, theme := range responseAnalysis.Themes {
+			if i, ok := index[theme]; ok {
+				assigned = append(assigned, i)
+			}
+		}
+		for _, i := range assigned {
+			for _, j := range assigned {
+				counts[i][j]++
+			}
+		}
+	}
+	return &CoOccurrence{Themes: result.Themes, Counts: counts}
+}
@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LanguageUnknown is the language of responses too short or too unusual to
+// detect
+const LanguageUnknown = "unknown"
+
+// languageWords lists frequent function words of the languages the analyzer
+// supports, which are enough to tell the language of a survey response
+var languageWords = map[string]map[string]bool{
+	"en": wordSet("the", "and", "is", "are", "was", "to", "of", "in", "it", "that", "for", "with", "not", "this", "be", "have", "you", "on", "but", "they", "would", "very", "more", "should", "there", "what", "we", "my"),
+	"de": wordSet("der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "mit", "den", "sich", "auf", "für", "von", "auch", "es", "ich", "wir", "sehr", "sind", "wird", "dass", "mehr", "oder", "aber", "noch", "man", "bei", "im"),
+	"fr": wordSet("le", "la", "les", "et", "est", "pas", "un", "une", "des", "du", "de", "que", "qui", "pour", "dans", "avec", "sur", "ce", "il", "je", "nous", "sont", "plus", "très", "mais", "ou", "au", "aux", "être", "on"),
+	"it": wordSet("il", "lo", "la", "gli", "le", "e", "è", "non", "un", "una", "di", "che", "per", "con", "del", "della", "sono", "più", "molto", "ma", "anche", "si", "questo", "nel", "alla", "come", "io", "noi", "essere", "ci"),
+}
+
+// wordSet converts a list of words into a set
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// responseWords splits a response into its words
+func responseWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// DetectLanguage guesses the language of a response from its function words.
+// It returns one of en, de, fr and it, or LanguageUnknown if no language
+// has at least two hits or two languages are tied.
+func DetectLanguage(text string) string {
+	scores := make(map[string]int, len(languageWords))
+	for _, word := range responseWords(strings.ToLower(text)) {
+		for language, words := range languageWords {
+			if words[word] {
+				scores[language]++
+			}
+		}
+	}
+	best, bestScore, tied := LanguageUnknown, 0, false
+	for _, language := range []string{"en", "de", "fr", "it"} {
+		switch score := scores[language]; {
+		case score > bestScore:
+			best, bestScore, tied = language, score, false
+		case score == bestScore && score > 0:
+			tied = true
+		}
+	}
+	if bestScore < 2 || tied {
+		return LanguageUnknown
+	}
+	return best
+}
+
+// LanguageCount is the number of responses in a language
+type LanguageCount struct {
+	Language   string  `yaml:"language"`
+	Count      int     `yaml:"count"`
+	Percentage float64 `yaml:"percentage"`
+}
+
+// LengthStats describes the length of a set of responses
+type LengthStats struct {
+	Responses   int     `yaml:"responses"`
+	MeanChars   float64 `yaml:"mean_chars"`
+	MedianChars float64 `yaml:"median_chars"`
+	MeanWords   float64 `yaml:"mean_words"`
+	MedianWords float64 `yaml:"median_words"`
+}
+
+// ThemeLength is the length of the responses of a theme
+type ThemeLength struct {
+	Theme       string `yaml:"theme"`
+	LengthStats `yaml:",inline"`
+}
+
+// CorpusStats holds descriptive statistics of the responses, such as for the
+// methods section of a report
+type CorpusStats struct {
+	Languages []LanguageCount `yaml:"languages"` // Most frequent language first
+	Length    LengthStats     `yaml:"length"`
+	Themes    []ThemeLength   `yaml:"themes"` // In the order of the theme list
+}
+
+// BuildCorpusStats detects the language of every response and computes the
+// length of the responses overall and per theme
+func BuildCorpusStats(analyses map[string]ResponseAnalysis, themes []string) *CorpusStats {
+	stats := &CorpusStats{}
+	languages := make(map[string]int)
+	var chars, words []int
+	themeChars := make(map[string][]int)
+	themeWords := make(map[string][]int)
+	for _, responseAnalysis := range analyses {
+		text := responseAnalysis.Response.Text
+		languages[DetectLanguage(text)]++
+		charCount := utf8.RuneCountInString(strings.TrimSpace(text))
+		wordCount := len(responseWords(text))
+		chars = append(chars, charCount)
+		words = append(words, wordCount)
+		for _, theme := range responseAnalysis.Themes {
+			themeChars[theme] = append(themeChars[theme], charCount)
+			themeWords[theme] = append(themeWords[theme], wordCount)
+		}
+	}
+
+	for language, count := range languages {
+		stats.Languages = append(stats.Languages, LanguageCount{
+			Language:   language,
+			Count:      count,
+			Percentage: float64(count) / float64(len(analyses)) * 100.0,
+		})
+	}
+	sort.Slice(stats.Languages, func(i, j int) bool {
+		if stats.Languages[i].Count != stats.Languages[j].Count {
+			return stats.Languages[i].Count > stats.Languages[j].Count
+		}
+		return stats.Languages[i].Language < stats.Languages[j].Language
+	})
+
+	stats.Length = lengthStats(chars, words)
+	for _, theme := range themes {
+		stats.Themes = append(stats.Themes, ThemeLength{Theme: theme, LengthStats: lengthStats(themeChars[theme], themeWords[theme])})
+	}
+	return stats
+}
+
+// ThemeLength returns the length of the responses of a theme
+func (s *CorpusStats) ThemeLength(theme string) (LengthStats, bool) {
+	for _, themeLength := range s.Themes {
+		if themeLength.Theme == theme {
+			return themeLength.LengthStats, true
+		}
+	}
+	return LengthStats{}, false
+}
+
+// lengthStats computes the mean and median of the character and word counts
+func lengthStats(chars, words []int) LengthStats {
+	stats := LengthStats{Responses: len(chars)}
+	if len(chars) == 0 {
+		return stats
+	}
+	stats.MeanChars, stats.MedianChars = meanMedian(chars)
+	stats.MeanWords, stats.MedianWords = meanMedian(words)
+	return stats
+}
+
+// meanMedian returns the mean and the median of the values
+func meanMedian(values []int) (float64, float64) {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	sum := 0
+	for _, value := range sorted {
+		sum += value
+	}
+	mean := float64(sum) / float64(len(sorted))
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return mean, float64(sorted[middle-1]+sorted[middle]) / 2
+	}
+	return mean, float64(sorted[middle])
+}
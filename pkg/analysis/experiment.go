@@ -0,0 +1,63 @@
+package analysis
+
+import "time"
+
+// ExperimentCell is the result of one model and prompt variant of an
+// experiment on a labelled sample
+type ExperimentCell struct {
+	Model          string  `yaml:"model"`
+	Prompt         string  `yaml:"prompt"`
+	ExactAgreement float64 `yaml:"exact_agreement"` // Share of responses with the coded themes
+	MeanJaccard    float64 `yaml:"mean_jaccard"`
+	MeanKappa      float64 `yaml:"mean_kappa"`
+	MacroF1        float64 `yaml:"macro_f1"` // Mean F1 over the themes
+	Cost           float64 `yaml:"cost"`
+	Tokens         int     `yaml:"tokens"`
+	Seconds        float64 `yaml:"seconds"`
+	Failed         int     `yaml:"failed_responses,omitempty"`
+	Error          string  `yaml:"error,omitempty"`
+}
+
+// Experiment compares the theme matching of several models and prompt
+// variants against the themes assigned by coders
+type Experiment struct {
+	GeneratedAt time.Time        `yaml:"generated_at"`
+	CodedFile   string           `yaml:"coded_file"`
+	SampleSize  int              `yaml:"sample_size"`
+	Cells       []ExperimentCell `yaml:"cells"`
+}
+
+// NewExperimentCell scores a matching run against the coded themes
+func NewExperimentCell(run ModelRun, prompt string, agreement *CodingAgreement) ExperimentCell {
+	cell := ExperimentCell{
+		Model:   run.Model,
+		Prompt:  prompt,
+		Cost:    run.Cost,
+		Tokens:  run.Tokens,
+		Seconds: run.Seconds,
+		Failed:  run.Failed,
+		Error:   run.Error,
+	}
+	if agreement != nil {
+		cell.ExactAgreement = agreement.ExactAgreement
+		cell.MeanJaccard = agreement.MeanJaccard
+		cell.MeanKappa = agreement.MeanKappa
+		cell.MacroF1 = agreement.MacroF1
+	}
+	return cell
+}
+
+// Best returns the index of the cell with the highest macro F1, -1 if no
+// cell succeeded
+func (e *Experiment) Best() int {
+	best := -1
+	for i, cell := range e.Cells {
+		if cell.Error != "" {
+			continue
+		}
+		if best < 0 || cell.MacroF1 > e.Cells[best].MacroF1 {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/oetiker/response-analyzer/pkg/excel"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// FailedResponse is a response whose batch could not be matched to the
+// themes. It is left out of the response analyses, so the next run matches
+// it again.
+type FailedResponse struct {
+	ID    string `yaml:"id"`
+	Row   int    `yaml:"row"`
+	Error string `yaml:"error"`
+}
+
+// matchFailures collects the responses of failed batches when the run
+// tolerates failures
+type matchFailures struct {
+	mutex     sync.Mutex
+	maxPct    float64 // Share of the responses in percent that may fail, 0 tolerates none
+	total     int     // Responses to match
+	responses []FailedResponse
+	ids       map[string]bool
+}
+
+// newMatchFailures creates the failure collection for matching the given
+// number of responses
+func (a *Analyzer) newMatchFailures(total int) *matchFailures {
+	return &matchFailures{maxPct: a.maxFailedResponsesPct, total: total, ids: make(map[string]bool)}
+}
+
+// add records the responses of a failed batch. It returns nil as long as
+// the failed responses stay below the tolerated share, and otherwise the
+// error that aborts the run.
+func (f *matchFailures) add(logger *logging.Logger, batch []excel.Response, err error) error {
+	if f.maxPct <= 0 {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, response := range batch {
+		f.responses = append(f.responses, FailedResponse{ID: response.ID, Row: response.RowIndex, Error: err.Error()})
+		f.ids[response.ID] = true
+	}
+	logger.Warn("Failed to match a batch, leaving its responses for the next run",
+		"responses", len(batch),
+		"first_row", batch[0].RowIndex,
+		"failed", len(f.responses),
+		"error", err)
+	if f.exceeded() {
+		return fmt.Errorf("%d of %d responses failed, reaching max_failed_responses_pct %g%%: %w", len(f.responses), f.total, f.maxPct, err)
+	}
+	return nil
+}
+
+// exceeded returns whether the failed responses reached the tolerated share.
+// The caller holds the mutex.
+func (f *matchFailures) exceeded() bool {
+	return f.total > 0 && float64(len(f.responses))*100 >= f.maxPct*float64(f.total)
+}
+
+// stopped returns whether too many responses failed already, so the
+// remaining batches are not worth sending
+func (f *matchFailures) stopped() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.maxPct > 0 && f.exceeded()
+}
+
+// failed returns whether a response is in a failed batch
+func (f *matchFailures) failed(id string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.ids[id]
+}
+
+// keep hands the failed responses to the analyzer for the result of the run
+func (f *matchFailures) keep(a *Analyzer) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	a.failedResponses = f.responses
+}
+
+// SetMaxFailedResponsesPct sets the share of responses in percent whose
+// batches may fail without aborting the run; 0 aborts on the first failure
+func (a *Analyzer) SetMaxFailedResponsesPct(pct float64) {
+	a.maxFailedResponsesPct = pct
+}
+
+// FailedResponses returns the responses whose batches failed during the
+// matching of this analyzer
+func (a *Analyzer) FailedResponses() []FailedResponse {
+	return a.failedResponses
+}
@@ -0,0 +1,195 @@
+package analysis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/excel"
+	"gopkg.in/yaml.v3"
+)
+
+// Keys of the fingerprint that are not configuration settings
+const (
+	FingerprintResponses       = "responses"        // IDs, texts, timestamps and ratings of the responses
+	FingerprintPromptRevisions = "prompt_revisions" // Revisions of the built-in instructions
+)
+
+// fingerprintIgnored lists the settings that do not change the result of a
+// run: credentials, pacing, confirmation and the files the result is
+// rendered to. The Excel file is covered by the hash of its responses, the
+// themes and codebook files by the themes read from them.
+var fingerprintIgnored = map[string]bool{
+	"excel_file_path": true, "input_format": true, "themes_file": true, "codebook_file": true, "theme_library": true, "theme_library_version": true, "state_file_path": true, "state_encoding": true,
+	"claude_api_key": true, "claude_api_keys": true, "api_key_rotation": true, "embedding_api_key": true, "usage_tag": true,
+	"cache_enabled": true, "cache_dir": true, "pause_file": true, "checkpoint_batches": true, "queue_dir": true, "batch_api": true, "batch_poll_interval": true,
+	"rate_limit_delay": true, "rate_limit_tier": true, "requests_per_minute": true,
+	"input_tokens_per_minute": true, "output_tokens_per_minute": true,
+	"parallel_workers": true, "use_parallel": true, "auto_parallel_workers": true, "max_failed_responses_pct": true,
+	"confirm_above_responses": true, "confirm_above_cost": true, "max_cost_usd": true, "comparison_models": true, "comparison_sample_size": true,
+	"report_template_path": true, "report_output_path": true, "combined_report_template_path": true, "combined_report_output_path": true,
+	"appendix_format": true, "appendix_output_path": true, "html_theme": true, "html_report_path": true,
+	"wide_export_path": true, "excel_output_path": true, "annotated_excel_path": true,
+	"review_sample_size": true, "review_sample_path": true, "review_sample_seed": true,
+	"output_sinks": true, "webhook": true, "run_id_in_filenames": true, "survey": true,
+	"profile": true, "profiles": true, // The settings of the applied profile count
+}
+
+// matchingSettings are the settings the theme matching depends on besides
+// the themes and their descriptions, so a change matches all responses again
+var matchingSettings = []string{
+	"claude_model", "llm_provider", "llm_base_url", "mock_provider", "context_prompt", "thinking_budget_tokens", "thinking_stages",
+	"consensus_runs", "matching_temperature", "prompt_injection_guard", "propose_new_themes", "strict_themes", "structured_output",
+}
+
+// Fingerprint holds a short hash of everything that determines the result of
+// a run: each relevant configuration setting, the revisions of the built-in
+// prompts and the responses. Comparing it with the fingerprint of the
+// previous run shows whether anything needs to be recomputed, and what.
+type Fingerprint map[string]string
+
+// NewFingerprint computes the fingerprint of a run with the configuration,
+// the responses read from the input and the title of their column
+func NewFingerprint(cfg *config.Config, responses []excel.Response, columnTitle string) (Fingerprint, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal configuration: %w", err)
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %w", err)
+	}
+	if model, _ := settings["claude_model"].(string); model == "" {
+		settings["claude_model"] = claude.DefaultModel
+	}
+	settings["column_title"] = columnTitle // Read from the header cell unless configured
+
+	fingerprint := make(Fingerprint)
+	for key, value := range settings {
+		if fingerprintIgnored[key] || isEmptySetting(value) {
+			continue
+		}
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", key, err)
+		}
+		fingerprint[key] = shortHash(string(data))
+	}
+
+	tasks := []string{
+		claude.TaskThemeIdentification, claude.TaskMatching, claude.TaskThemeSummary, claude.TaskGlobalSummary,
+		claude.TaskThemeSplit, claude.TaskThemeDescriptions, claude.TaskVerification, claude.TaskClusterLabels, claude.TaskSummary,
+	}
+	if cfg.SentimentEnabled {
+		tasks = append(tasks, claude.TaskSentiment)
+	}
+	revisions := make([]string, len(tasks))
+	for i, task := range tasks {
+		revisions[i] = task + "=" + claude.PromptVersion(task, "")
+	}
+	fingerprint[FingerprintPromptRevisions] = shortHash(strings.Join(revisions, "\n"))
+
+	entries := make([]string, len(responses))
+	for i, response := range responses {
+		entry := response.ID + ":" + response.Hash
+		if !response.Timestamp.IsZero() {
+			entry += ":" + response.Timestamp.UTC().Format("2006-01-02T15:04:05")
+		}
+		if response.Rating != nil {
+			entry += fmt.Sprintf(":%g", *response.Rating)
+		}
+		entries[i] = entry
+	}
+	sort.Strings(entries)
+	fingerprint[FingerprintResponses] = shortHash(strings.Join(entries, "\n"))
+	return fingerprint, nil
+}
+
+// Changes returns the keys whose hashes differ from the previous
+// fingerprint, including keys that were added or removed, in sorted order
+func (f Fingerprint) Changes(previous Fingerprint) []string {
+	var changes []string
+	for key, hash := range f {
+		if previous[key] != hash {
+			changes = append(changes, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := f[key]; !ok {
+			changes = append(changes, key)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// changedSettings returns the settings among keys that changed since the
+// previous run, none if it was written before fingerprints
+func changedSettings(cfg *config.Config, previous *AnalysisResult, keys []string) []string {
+	if previous.Run.Fingerprint == nil {
+		return nil // Written before fingerprints, assume the defaults
+	}
+	fingerprint, err := NewFingerprint(cfg, nil, previous.ColumnTitle)
+	if err != nil {
+		return keys
+	}
+	var changes []string
+	for _, key := range keys {
+		if fingerprint[key] != previous.Run.Fingerprint[key] {
+			changes = append(changes, key)
+		}
+	}
+	return changes
+}
+
+// ResponseChanges counts the responses that are new, changed or removed
+// compared to the previous result
+func ResponseChanges(responses []excel.Response, previous *AnalysisResult) (added, changed, removed int) {
+	seen := make(map[string]bool, len(responses))
+	for _, response := range responses {
+		seen[response.ID] = true
+		if analysis, ok := previous.ResponseAnalyses[response.ID]; !ok {
+			added++
+		} else if analysis.Response.Hash != response.Hash {
+			changed++
+		}
+	}
+	for id := range previous.ResponseAnalyses {
+		if !seen[id] {
+			removed++
+		}
+	}
+	return added, changed, removed
+}
+
+// isEmptySetting returns whether a setting is unset, so adding a setting
+// with its zero value does not count as a change
+func isEmptySetting(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int:
+		return v == 0
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// shortHash returns the first 16 hex digits of the SHA-256 hash of a text
+func shortHash(text string) string {
+	hash := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(hash[:8])
+}
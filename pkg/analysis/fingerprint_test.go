@@ -0,0 +1,162 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+func fingerprintConfig() *config.Config {
+	return &config.Config{
+		ClaudeAPIKey:  "key",
+		ContextPrompt: "Customer feedback",
+		Themes:        []string{"Price", "Delivery"},
+		CacheDir:      ".cache",
+	}
+}
+
+func TestFingerprintSettings(t *testing.T) {
+	base, err := NewFingerprint(fingerprintConfig(), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		change func(cfg *config.Config)
+		want   []string
+	}{
+		{name: "API key", change: func(cfg *config.Config) { cfg.ClaudeAPIKey = "other" }},
+		{name: "cache directory", change: func(cfg *config.Config) { cfg.CacheDir = "/tmp/cache" }},
+		{name: "rate limit delay", change: func(cfg *config.Config) { cfg.RateLimitDelay = 500 }},
+		{name: "parallel workers", change: func(cfg *config.Config) { cfg.ParallelWorkers = 8 }},
+		{name: "report output", change: func(cfg *config.Config) { cfg.ReportOutputPath = "report.md" }},
+		{name: "zero value added", change: func(cfg *config.Config) { cfg.ConsensusRuns = 0 }},
+		{name: "context prompt", change: func(cfg *config.Config) { cfg.ContextPrompt = "Employee feedback" }, want: []string{"context_prompt"}},
+		{name: "themes", change: func(cfg *config.Config) { cfg.Themes = append(cfg.Themes, "Support") }, want: []string{"themes"}},
+		{name: "model", change: func(cfg *config.Config) { cfg.ClaudeModel = "claude-3-5-haiku-20241022" }, want: []string{"claude_model"}},
+		{name: "setting added", change: func(cfg *config.Config) { cfg.ConsensusRuns = 3 }, want: []string{"consensus_runs"}},
+	}
+	for _, test := range tests {
+		cfg := fingerprintConfig()
+		test.change(cfg)
+		fingerprint, err := NewFingerprint(cfg, nil, "")
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := fingerprint.Changes(base); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got changes %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestChangedSettings(t *testing.T) {
+	fingerprint, err := NewFingerprint(fingerprintConfig(), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := &AnalysisResult{Run: RunMetadata{Fingerprint: fingerprint}}
+	temperature := 0.2
+	tests := []struct {
+		name     string
+		change   func(cfg *config.Config)
+		previous *AnalysisResult
+		want     []string
+	}{
+		{name: "unchanged", change: func(cfg *config.Config) {}, previous: previous},
+		{name: "ignored setting", change: func(cfg *config.Config) { cfg.ParallelWorkers = 8 }, previous: previous},
+		{name: "other setting", change: func(cfg *config.Config) { cfg.ThemeSummaryPrompt = "Be brief" }, previous: previous},
+		{name: "context prompt", change: func(cfg *config.Config) { cfg.ContextPrompt = "Employee feedback" }, previous: previous, want: []string{"context_prompt"}},
+		{
+			name: "model and temperature",
+			change: func(cfg *config.Config) {
+				cfg.ClaudeModel = "claude-3-5-haiku-20241022"
+				cfg.MatchingTemperature = &temperature
+			},
+			previous: previous,
+			want:     []string{"claude_model", "matching_temperature"},
+		},
+		{name: "written before fingerprints", change: func(cfg *config.Config) { cfg.ContextPrompt = "Employee feedback" }, previous: &AnalysisResult{}},
+	}
+	for _, test := range tests {
+		cfg := fingerprintConfig()
+		test.change(cfg)
+		if got := changedSettings(cfg, test.previous, matchingSettings); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFingerprintResponses(t *testing.T) {
+	rating := 4.0
+	responses := func() []excel.Response {
+		return []excel.Response{
+			{ID: "R2", Text: "Too expensive", Hash: "a1"},
+			{ID: "R3", Text: "Late delivery", Hash: "b2"},
+		}
+	}
+	base, err := NewFingerprint(fingerprintConfig(), responses(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		change func(responses []excel.Response) []excel.Response
+		want   []string
+	}{
+		{name: "unchanged", change: func(responses []excel.Response) []excel.Response { return responses }},
+		{
+			name: "reordered",
+			change: func(responses []excel.Response) []excel.Response {
+				return []excel.Response{responses[1], responses[0]}
+			},
+		},
+		{
+			name: "text edited",
+			change: func(responses []excel.Response) []excel.Response {
+				responses[0].Hash = "c3"
+				return responses
+			},
+			want: []string{FingerprintResponses},
+		},
+		{
+			name: "response added",
+			change: func(responses []excel.Response) []excel.Response {
+				return append(responses, excel.Response{ID: "R4", Text: "Fine", Hash: "d4"})
+			},
+			want: []string{FingerprintResponses},
+		},
+		{
+			name:   "response removed",
+			change: func(responses []excel.Response) []excel.Response { return responses[:1] },
+			want:   []string{FingerprintResponses},
+		},
+		{
+			name: "timestamp set",
+			change: func(responses []excel.Response) []excel.Response {
+				responses[1].Timestamp = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+				return responses
+			},
+			want: []string{FingerprintResponses},
+		},
+		{
+			name: "rating set",
+			change: func(responses []excel.Response) []excel.Response {
+				responses[1].Rating = &rating
+				return responses
+			},
+			want: []string{FingerprintResponses},
+		},
+	}
+	for _, test := range tests {
+		fingerprint, err := NewFingerprint(fingerprintConfig(), test.change(responses()), "")
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := fingerprint.Changes(base); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got changes %v, want %v", test.name, got, test.want)
+		}
+	}
+}
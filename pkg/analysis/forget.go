@@ -0,0 +1,200 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// ForgottenResponse records a response removed on a data subject's request.
+// Only the hash of the text is kept, so the response is skipped if it is
+// still present in the input file.
+type ForgottenResponse struct {
+	Hash        string    `yaml:"hash"`
+	ForgottenAt time.Time `yaml:"forgotten_at"`
+}
+
+// ForgetResponses removes the given responses and everything derived from
+// them from the result. Summaries that were built from the responses are
+// marked as stale, so they are regenerated on the next run. The segments of
+// a segmented response are removed with it. It returns the removed responses.
+func ForgetResponses(result *AnalysisResult, ids []string) []excel.Response {
+	ids = withSegments(result, ids)
+	staleThemes := make(map[string]bool)
+	for _, theme := range result.StaleThemeSummaries {
+		staleThemes[theme] = true
+	}
+	for _, id := range ids {
+		if responseAnalysis, ok := result.ResponseAnalyses[id]; ok {
+			for _, theme := range responseAnalysis.Themes {
+				staleThemes[theme] = true
+			}
+		}
+	}
+
+	removed := RemoveResponses(result, ids)
+	if len(removed) == 0 {
+		return nil
+	}
+	for _, response := range removed {
+		result.ForgottenResponses = append(result.ForgottenResponses, ForgottenResponse{
+			Hash:        response.Hash,
+			ForgottenAt: time.Now(),
+		})
+	}
+
+	// Mark the summaries built from the responses as stale
+	result.StaleThemeSummaries = nil
+	for _, theme := range result.Themes {
+		if staleThemes[theme] {
+			result.StaleThemeSummaries = append(result.StaleThemeSummaries, theme)
+		}
+	}
+	result.StaleGlobalSummary = true
+
+	// Summaries in additional languages cannot be marked stale, drop them
+	result.LanguageSummaries = nil
+
+	return removed
+}
+
+// RemoveResponses removes the given responses and everything derived from
+// them from the result, leaving the summaries as they are. It returns the
+// removed responses.
+func RemoveResponses(result *AnalysisResult, ids []string) []excel.Response {
+	forget := make(map[string]bool)
+	var removed []excel.Response
+	for _, id := range ids {
+		responseAnalysis, ok := result.ResponseAnalyses[id]
+		if !ok || forget[id] {
+			continue
+		}
+		forget[id] = true
+		removed = append(removed, responseAnalysis.Response)
+		delete(result.ResponseAnalyses, id)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	keep := func(ids []string) []string {
+		kept := ids[:0]
+		for _, id := range ids {
+			if !forget[id] {
+				kept = append(kept, id)
+			}
+		}
+		return kept
+	}
+
+	// Remove the responses from all derived structures
+	for theme, themeAnalysis := range result.ThemeAnalyses {
+		themeAnalysis.Responses = keep(themeAnalysis.Responses)
+		result.ThemeAnalyses[theme] = themeAnalysis
+	}
+	for i := range result.ProposedThemes {
+		result.ProposedThemes[i].Responses = keep(result.ProposedThemes[i].Responses)
+		result.ProposedThemes[i].Count = len(result.ProposedThemes[i].Responses)
+	}
+	for i := range result.ThemeSplits {
+		for subTheme, responses := range result.ThemeSplits[i].Responses {
+			result.ThemeSplits[i].Responses[subTheme] = keep(responses)
+		}
+	}
+	var citations []Citation
+	for _, citation := range result.SummaryCitations {
+		if !forget[citation.ResponseID] {
+			citations = append(citations, citation)
+		}
+	}
+	result.SummaryCitations = citations
+	for i, variant := range result.SummaryVariants {
+		var kept []Citation
+		for _, citation := range variant.Citations {
+			if !forget[citation.ResponseID] {
+				kept = append(kept, citation)
+			}
+		}
+		result.SummaryVariants[i].Citations = kept
+	}
+	if result.Embeddings != nil {
+		for id := range forget {
+			delete(result.Embeddings.Responses, id)
+		}
+	}
+	var outliers []Outlier
+	for _, outlier := range result.Outliers {
+		if !forget[outlier.ResponseID] {
+			outliers = append(outliers, outlier)
+		}
+	}
+	result.Outliers = outliers
+	for i := range result.Clusters {
+		result.Clusters[i].Responses = keep(result.Clusters[i].Responses)
+		result.Clusters[i].Size = len(result.Clusters[i].Responses)
+	}
+	for i := range result.Topics {
+		result.Topics[i].Responses = keep(result.Topics[i].Responses)
+		result.Topics[i].Size = len(result.Topics[i].Responses)
+	}
+	refreshStatistics(result)
+
+	return removed
+}
+
+// refreshStatistics rebuilds the statistics of the themes after responses
+// were removed or their themes changed
+func refreshStatistics(result *AnalysisResult) {
+	if result.TimeSeries != nil {
+		result.TimeSeries = BuildTimeSeries(result.ResponseAnalyses, result.Themes, result.TimeSeries.Interval)
+	}
+	if len(result.RollingWindows) > 0 {
+		days := make([]int, len(result.RollingWindows))
+		for i, window := range result.RollingWindows {
+			days[i] = window.Days
+		}
+		result.RollingWindows = BuildRollingWindows(result.ResponseAnalyses, result.Themes, days)
+	}
+	if result.Ratings != nil {
+		result.Ratings = BuildRatingStats(result.ResponseAnalyses, result.Themes, result.Ratings.BandRanges())
+	}
+	if result.Corpus != nil {
+		result.Corpus = BuildCorpusStats(result.ResponseAnalyses, result.Themes)
+	}
+}
+
+// SkipForgottenResponses drops the responses whose text was forgotten earlier
+func SkipForgottenResponses(responses []excel.Response, forgotten []ForgottenResponse) []excel.Response {
+	if len(forgotten) == 0 {
+		return responses
+	}
+	hashes := make(map[string]bool, len(forgotten))
+	for _, item := range forgotten {
+		hashes[item.Hash] = true
+	}
+	kept := make([]excel.Response, 0, len(responses))
+	for _, response := range responses {
+		if !hashes[response.Hash] {
+			kept = append(kept, response)
+		}
+	}
+	return kept
+}
+
+// withSegments adds the segments of the responses split from the documents
+// with the given IDs, so forgetting a row forgets all of its segments
+func withSegments(result *AnalysisResult, ids []string) []string {
+	documents := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		documents[id] = true
+	}
+	expanded := append([]string(nil), ids...)
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		if documents[responseAnalysis.Response.Document] && !documents[id] {
+			expanded = append(expanded, id)
+		}
+	}
+	sort.Strings(expanded[len(ids):])
+	return expanded
+}
@@ -0,0 +1,252 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/embedding"
+)
+
+// DefaultGlossaryThreshold is the similarity above which themes of different
+// projects are taken for the same category
+const DefaultGlossaryThreshold = 0.5
+
+// ProjectThemes are the themes of one project compared by the glossary
+type ProjectThemes struct {
+	Project      string                             `yaml:"project"` // Path of the configuration
+	Themes       []string                           `yaml:"themes"`
+	Descriptions map[string]claude.ThemeDescription `yaml:"-"`
+}
+
+// GlossaryEntry is a theme of a project
+type GlossaryEntry struct {
+	Project     string `yaml:"project"`
+	Theme       string `yaml:"theme"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// GlossaryGroup is a category that the themes of several projects cover
+type GlossaryGroup struct {
+	Theme      string          `yaml:"theme"`      // Suggested name of the category
+	Similarity float64         `yaml:"similarity"` // Lowest similarity of two of its themes
+	Entries    []GlossaryEntry `yaml:"entries"`
+}
+
+// Glossary compares the themes of several projects. Themes and
+// theme_descriptions form the harmonized glossary, so the file can be used
+// as a themes file.
+type Glossary struct {
+	GeneratedAt       time.Time                          `yaml:"generated_at"`
+	EmbeddingModel    string                             `yaml:"embedding_model"`
+	Threshold         float64                            `yaml:"threshold"`
+	Projects          []ProjectThemes                    `yaml:"projects"`
+	NearDuplicates    []GlossaryGroup                    `yaml:"near_duplicates,omitempty"` // Categories the projects name differently
+	Shared            []GlossaryGroup                    `yaml:"shared,omitempty"`          // Categories the projects name alike
+	Themes            []string                           `yaml:"themes"`
+	ThemeDescriptions map[string]claude.ThemeDescription `yaml:"theme_descriptions,omitempty"`
+}
+
+// glossaryTheme is a theme of a project with its embeddings
+type glossaryTheme struct {
+	project     int
+	theme       string
+	description claude.ThemeDescription
+	name        []float32
+	text        []float32 // Embedding of the description, nil without one
+}
+
+// BuildGlossary groups the themes of the projects that name the same
+// category, at most one theme per project, and suggests a glossary with one
+// theme per group. Themes are similar by the embeddings of their names, or
+// of their names and descriptions if that is higher. Groups only merge if
+// all their themes are similar above the threshold, so chains of loosely
+// related themes stay apart. The name used by most projects is suggested,
+// the shortest on a tie.
+func BuildGlossary(projects []ProjectThemes, embedder embedding.Embedder, threshold float64) (*Glossary, error) {
+	var themes []*glossaryTheme
+	var names, descriptions []string
+	for i, project := range projects {
+		for _, theme := range project.Themes {
+			entry := &glossaryTheme{project: i, theme: theme, description: project.Descriptions[theme]}
+			themes = append(themes, entry)
+			names = append(names, theme)
+			descriptions = append(descriptions, entry.description.Description)
+		}
+	}
+	if len(themes) == 0 {
+		return nil, fmt.Errorf("the projects have no themes")
+	}
+	nameVectors, err := embedder.Embed(names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed theme names: %w", err)
+	}
+	var described []string
+	for _, description := range descriptions {
+		if description != "" {
+			described = append(described, description)
+		}
+	}
+	var descriptionVectors [][]float32
+	if len(described) > 0 {
+		descriptionVectors, err = embedder.Embed(described)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed theme descriptions: %w", err)
+		}
+	}
+	next := 0
+	for i, theme := range themes {
+		theme.name = nameVectors[i]
+		if descriptions[i] != "" {
+			theme.text = descriptionVectors[next]
+			next++
+		}
+	}
+
+	// Merge the most similar groups first, each pair of themes once
+	similarity := make([][]float64, len(themes))
+	type pair struct {
+		first, second int
+	}
+	var pairs []pair
+	for i := range themes {
+		similarity[i] = make([]float64, len(themes))
+		for j := range i {
+			similarity[i][j] = themeSimilarity(themes[i], themes[j])
+			similarity[j][i] = similarity[i][j]
+			if themes[i].project != themes[j].project && similarity[i][j] >= threshold {
+				pairs = append(pairs, pair{j, i})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool {
+		return similarity[pairs[a].first][pairs[a].second] > similarity[pairs[b].first][pairs[b].second]
+	})
+
+	groupOf := make([]int, len(themes))
+	members := make([][]int, len(themes))
+	for i := range themes {
+		groupOf[i] = i
+		members[i] = []int{i}
+	}
+	for _, p := range pairs {
+		first, second := groupOf[p.first], groupOf[p.second]
+		if first == second || !mergeable(themes, similarity, members[first], members[second], threshold) {
+			continue
+		}
+		for _, member := range members[second] {
+			groupOf[member] = first
+		}
+		members[first] = append(members[first], members[second]...)
+		members[second] = nil
+	}
+
+	glossary := &Glossary{
+		GeneratedAt:       time.Now(),
+		EmbeddingModel:    embedder.Model(),
+		Threshold:         threshold,
+		Projects:          projects,
+		ThemeDescriptions: make(map[string]claude.ThemeDescription),
+	}
+	var groups []GlossaryGroup
+	for _, group := range members {
+		if len(group) == 0 {
+			continue
+		}
+		sort.Slice(group, func(a, b int) bool { return group[a] < group[b] })
+		name := suggestedTheme(themes, group)
+		glossaryGroup := GlossaryGroup{Theme: name, Similarity: 1}
+		for i, member := range group {
+			theme := themes[member]
+			glossaryGroup.Entries = append(glossaryGroup.Entries, GlossaryEntry{
+				Project:     projects[theme.project].Project,
+				Theme:       theme.theme,
+				Description: theme.description.Description,
+			})
+			for _, other := range group[:i] {
+				glossaryGroup.Similarity = min(glossaryGroup.Similarity, similarity[member][other])
+			}
+			if _, ok := glossary.ThemeDescriptions[name]; !ok && theme.theme == name && theme.description.Description != "" {
+				glossary.ThemeDescriptions[name] = theme.description
+			}
+		}
+		groups = append(groups, glossaryGroup)
+	}
+
+	// Categories of most projects first
+	sort.SliceStable(groups, func(a, b int) bool {
+		if len(groups[a].Entries) != len(groups[b].Entries) {
+			return len(groups[a].Entries) > len(groups[b].Entries)
+		}
+		return groups[a].Theme < groups[b].Theme
+	})
+	for _, group := range groups {
+		if slices.Contains(glossary.Themes, group.Theme) {
+			// Two categories suggest the same name, keep them apart
+			group.Theme = fmt.Sprintf("%s (%s)", group.Theme, group.Entries[0].Project)
+		}
+		glossary.Themes = append(glossary.Themes, group.Theme)
+		if len(group.Entries) < 2 {
+			continue
+		}
+		if distinctThemes(group.Entries) > 1 {
+			glossary.NearDuplicates = append(glossary.NearDuplicates, group)
+		} else {
+			glossary.Shared = append(glossary.Shared, group)
+		}
+	}
+	return glossary, nil
+}
+
+// themeSimilarity returns the similarity of two themes by their names, or by
+// their names and descriptions if that is higher
+func themeSimilarity(a, b *glossaryTheme) float64 {
+	similarity := embedding.CosineSimilarity(a.name, b.name)
+	if a.text != nil && b.text != nil {
+		similarity = max(similarity, (similarity+embedding.CosineSimilarity(a.text, b.text))/2)
+	}
+	return similarity
+}
+
+// mergeable reports whether two groups hold themes of different projects
+// only and all their themes are similar above the threshold
+func mergeable(themes []*glossaryTheme, similarity [][]float64, first, second []int, threshold float64) bool {
+	for _, a := range first {
+		for _, b := range second {
+			if themes[a].project == themes[b].project || similarity[a][b] < threshold {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// suggestedTheme returns the name most themes of a group share, the shortest
+// and then the first alphabetically on a tie
+func suggestedTheme(themes []*glossaryTheme, group []int) string {
+	counts := make(map[string]int)
+	for _, member := range group {
+		counts[themes[member].theme]++
+	}
+	best := ""
+	for name, count := range counts {
+		switch {
+		case best == "", count > counts[best]:
+			best = name
+		case count == counts[best] && (len(name) < len(best) || len(name) == len(best) && name < best):
+			best = name
+		}
+	}
+	return best
+}
+
+// distinctThemes counts the different names in a group
+func distinctThemes(entries []GlossaryEntry) int {
+	names := make(map[string]bool)
+	for _, entry := range entries {
+		names[entry.Theme] = true
+	}
+	return len(names)
+}
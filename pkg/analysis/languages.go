@@ -0,0 +1,63 @@
+package analysis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+)
+
+// LanguageSummaries holds the summaries of a result in an additional output
+// language, generated from the same classification
+type LanguageSummaries struct {
+	ThemeSummaries map[string]claude.ThemeSummary `yaml:"theme_summaries,omitempty"`
+	GlobalSummary  string                         `yaml:"global_summary,omitempty"`
+	GeneratedAt    time.Time                      `yaml:"generated_at"`
+}
+
+// GenerateLanguageSummaries generates the theme and global summaries of a
+// result in the output language of the analyzer's client, keeping the
+// classification of the responses
+func (a *Analyzer) GenerateLanguageSummaries(result *AnalysisResult, cfg *config.Config) (LanguageSummaries, error) {
+	summaries := LanguageSummaries{GeneratedAt: time.Now()}
+
+	var err error
+	if len(result.Themes) > 0 && (cfg.ThemeSummaryPrompt != "" || len(cfg.ThemeOverrides) > 0) {
+		summaries.ThemeSummaries, err = a.GenerateThemeSummaries(result, cfg)
+		if err != nil {
+			return LanguageSummaries{}, fmt.Errorf("failed to generate theme summaries: %w", err)
+		}
+	}
+
+	if len(result.Themes) > 0 && cfg.SummaryLength > 0 {
+		globalPrompt := cfg.GlobalSummaryPrompt
+		if globalPrompt == "" {
+			globalPrompt = "Summarize the main points made in each theme and highlight any unique ideas or problems mentioned."
+		}
+		summaries.GlobalSummary, err = a.GenerateGlobalSummary(summaries.ThemeSummaries, globalPrompt, cfg.SummaryLength)
+		if err != nil {
+			return LanguageSummaries{}, fmt.Errorf("failed to generate global summary: %w", err)
+		}
+	}
+
+	return summaries, nil
+}
+
+// InLanguage returns a copy of the result with the summaries replaced by
+// those generated in the given language. Citations and the verification
+// report refer to the original summaries and are left out.
+func (r *AnalysisResult) InLanguage(language string) (*AnalysisResult, error) {
+	summaries, ok := r.LanguageSummaries[language]
+	if !ok {
+		return nil, fmt.Errorf("the state has no summaries in language %s", language)
+	}
+
+	localized := *r
+	localized.ThemeSummaries = summaries.ThemeSummaries
+	localized.GlobalSummary = summaries.GlobalSummary
+	localized.Summary = summaries.GlobalSummary
+	localized.SummaryCitations = nil
+	localized.Verification = nil
+	return &localized, nil
+}
@@ -0,0 +1,188 @@
+package analysis
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// maxListedMoves limits the theme moves listed in a migration report
+const maxListedMoves = 10
+
+// ThemeMigration is the change of the assignments of one theme when
+// switching models
+type ThemeMigration struct {
+	Theme  string  `yaml:"theme"`
+	Before int     `yaml:"before"` // Sampled responses with the theme in the state
+	After  int     `yaml:"after"`  // Sampled responses with the theme by the new model
+	Gained int     `yaml:"gained"` // Responses the new model adds the theme to
+	Lost   int     `yaml:"lost"`   // Responses the new model removes the theme from
+	Kappa  float64 `yaml:"kappa"`  // Cohen's kappa of the state and the new model
+}
+
+// ThemeMove counts the responses that lose one theme and gain another one
+// instead when switching models
+type ThemeMove struct {
+	From  string `yaml:"from"`
+	To    string `yaml:"to"`
+	Count int    `yaml:"count"`
+}
+
+// AssignmentChange is a sampled response the new model assigns other themes
+// to than the state
+type AssignmentChange struct {
+	ResponseID string   `yaml:"response_id"`
+	Row        int      `yaml:"row"`
+	Before     []string `yaml:"before"`
+	After      []string `yaml:"after"`
+	Added      []string `yaml:"added,omitempty"`
+	Removed    []string `yaml:"removed,omitempty"`
+}
+
+// ModelMigration reports how the theme assignments of the state would change
+// when matching with a new model, estimated on a sample of the responses
+type ModelMigration struct {
+	GeneratedAt        time.Time          `yaml:"generated_at"`
+	FromModel          string             `yaml:"from_model"`
+	ToModel            string             `yaml:"to_model"`
+	AnalyzedResponses  int                `yaml:"analyzed_responses"` // Responses in the state
+	SampleSize         int                `yaml:"sample_size"`
+	Compared           int                `yaml:"compared_responses"` // Sampled responses the new model classified
+	Run                ModelRun           `yaml:"run"`
+	ChangedResponses   int                `yaml:"changed_responses"`   // Compared responses with other themes
+	ChangedShare       float64            `yaml:"changed_share"`       // Share of the compared responses with other themes
+	EstimatedChanged   int                `yaml:"estimated_changed"`   // Changed responses expected among all analyzed responses
+	AddedAssignments   int                `yaml:"added_assignments"`   // Response-theme assignments the new model adds
+	RemovedAssignments int                `yaml:"removed_assignments"` // Response-theme assignments the new model removes
+	MeanJaccard        float64            `yaml:"mean_jaccard"`
+	Themes             []ThemeMigration   `yaml:"themes"`
+	Moves              []ThemeMove        `yaml:"moves,omitempty"` // Most frequent replacements of one theme by another
+	Changes            []AssignmentChange `yaml:"changes,omitempty"`
+}
+
+// MigrationCandidates returns the responses of the input whose analysis in
+// the state was made from the same text, so the assignments of a new model
+// can be compared with those of the state
+func MigrationCandidates(result *AnalysisResult, responses []excel.Response) []excel.Response {
+	var candidates []excel.Response
+	for _, response := range responses {
+		if previous, ok := result.ResponseAnalyses[response.ID]; ok && previous.Response.Hash == response.Hash {
+			candidates = append(candidates, response)
+		}
+	}
+	return candidates
+}
+
+// CompareMigration compares the theme assignments of the state with those of
+// the new model on the sampled responses
+func CompareMigration(result *AnalysisResult, sample []excel.Response, run ModelRun) ModelMigration {
+	migration := ModelMigration{
+		GeneratedAt:       time.Now(),
+		FromModel:         result.Run.Model,
+		ToModel:           run.Model,
+		AnalyzedResponses: len(result.ResponseAnalyses),
+		SampleSize:        len(sample),
+		Run:               run,
+	}
+
+	var compared []excel.Response
+	for _, response := range sample {
+		if _, ok := run.Analyses[response.ID]; ok {
+			compared = append(compared, response)
+		}
+	}
+	migration.Compared = len(compared)
+	if len(compared) == 0 {
+		return migration
+	}
+
+	moves := make(map[[2]string]int)
+	jaccardSum := 0.0
+	for _, response := range compared {
+		before := result.ResponseAnalyses[response.ID].Themes
+		after := run.Analyses[response.ID].Themes
+		jaccardSum += jaccardIndex(before, after)
+
+		var added, removed []string
+		for _, theme := range after {
+			if !slices.Contains(before, theme) {
+				added = append(added, theme)
+			}
+		}
+		for _, theme := range before {
+			if !slices.Contains(after, theme) {
+				removed = append(removed, theme)
+			}
+		}
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		migration.ChangedResponses++
+		migration.AddedAssignments += len(added)
+		migration.RemovedAssignments += len(removed)
+		for _, from := range removed {
+			for _, to := range added {
+				moves[[2]string{from, to}]++
+			}
+		}
+		migration.Changes = append(migration.Changes, AssignmentChange{
+			ResponseID: response.ID,
+			Row:        response.RowIndex,
+			Before:     before,
+			After:      after,
+			Added:      added,
+			Removed:    removed,
+		})
+	}
+	migration.ChangedShare = float64(migration.ChangedResponses) / float64(len(compared))
+	migration.EstimatedChanged = int(migration.ChangedShare*float64(migration.AnalyzedResponses) + 0.5)
+	migration.MeanJaccard = jaccardSum / float64(len(compared))
+
+	for _, theme := range result.Themes {
+		themeMigration := ThemeMigration{Theme: theme}
+		bothYes, bothNo := 0, 0
+		for _, response := range compared {
+			before := slices.Contains(result.ResponseAnalyses[response.ID].Themes, theme)
+			after := slices.Contains(run.Analyses[response.ID].Themes, theme)
+			if before {
+				themeMigration.Before++
+			}
+			if after {
+				themeMigration.After++
+			}
+			switch {
+			case before && after:
+				bothYes++
+			case !before && !after:
+				bothNo++
+			case after:
+				themeMigration.Gained++
+			default:
+				themeMigration.Lost++
+			}
+		}
+		themeMigration.Kappa = cohensKappa(len(compared), bothYes, bothNo, themeMigration.Before, themeMigration.After)
+		migration.Themes = append(migration.Themes, themeMigration)
+	}
+
+	for pair, count := range moves {
+		migration.Moves = append(migration.Moves, ThemeMove{From: pair[0], To: pair[1], Count: count})
+	}
+	sort.Slice(migration.Moves, func(i, j int) bool {
+		a, b := migration.Moves[i], migration.Moves[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	if len(migration.Moves) > maxListedMoves {
+		migration.Moves = migration.Moves[:maxListedMoves]
+	}
+
+	return migration
+}
@@ -0,0 +1,134 @@
+package analysis
+
+import (
+	"sync"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// Tuning of the number of parallel workers in auto mode
+const (
+	autoParallelStart = 2               // Workers at the start of a run
+	slowLatencyFactor = 3               // Answers this much slower than the fastest are a sign of overload
+	minSlowLatency    = 5 * time.Second // Answers faster than this are never considered slow
+)
+
+// workerPool limits the number of batches matched at the same time and hands
+// out worker IDs to tag their log lines. In auto mode the limit starts low
+// and follows the API: it grows by one after a round of batches without rate
+// limit errors or slow answers, halves on rate limit errors and shrinks by
+// one on slow answers.
+type workerPool struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	logger *logging.Logger
+	auto   bool
+
+	limit  int    // Workers allowed at the same time
+	max    int    // Upper bound of the limit
+	busy   []bool // Worker IDs in use, worker 1 first
+	active int
+
+	successes  int           // Batches without trouble since the limit last changed
+	reduced    time.Time     // Time of the last reduction of the limit
+	baseline   time.Duration // Fastest latency of a request so far
+	peak       int
+	reductions int
+}
+
+// newWorkerPool creates a pool of up to the given number of workers,
+// starting with fewer of them in auto mode
+func newWorkerPool(logger *logging.Logger, workers int, auto bool) *workerPool {
+	workers = max(1, workers)
+	limit := workers
+	if auto {
+		limit = min(autoParallelStart, workers)
+	}
+	pool := &workerPool{logger: logger, auto: auto, limit: limit, max: workers, busy: make([]bool, workers), peak: limit}
+	pool.cond = sync.NewCond(&pool.mutex)
+	return pool
+}
+
+// acquire waits for a free worker and returns its ID and the time the
+// worker started
+func (p *workerPool) acquire() (int, time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for p.active >= p.limit {
+		p.cond.Wait()
+	}
+	p.active++
+	for i, busy := range p.busy {
+		if !busy {
+			p.busy[i] = true
+			return i + 1, time.Now()
+		}
+	}
+	panic("worker pool has no free worker") // Unreachable, as active < limit <= max
+}
+
+// release returns a worker to the pool, tuning the limit in auto mode by the
+// requests the worker made
+func (p *workerPool) release(id int, started time.Time, stats claude.RequestStats) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.busy[id-1] = false
+	p.active--
+	if p.auto {
+		p.tune(started, stats)
+	}
+	p.cond.Broadcast()
+}
+
+// tune adjusts the limit by the requests of a worker that started at the
+// given time. Workers that started before the last reduction do not reduce
+// the limit again, as they ran with the previous one.
+func (p *workerPool) tune(started time.Time, stats claude.RequestStats) {
+	if stats.Requests == 0 {
+		return // Answered from the cache, says nothing about the API
+	}
+	latency := stats.Latency / time.Duration(stats.Requests)
+	if p.baseline == 0 || latency < p.baseline {
+		p.baseline = latency
+	}
+
+	switch {
+	case stats.Retries > 0:
+		if started.After(p.reduced) && p.limit > 1 {
+			p.reduce(p.limit/2, "Rate limited, reducing parallel workers", "retries", stats.Retries)
+		}
+	case latency > minSlowLatency && latency > slowLatencyFactor*p.baseline:
+		if started.After(p.reduced) && p.limit > 1 {
+			p.reduce(p.limit-1, "Slow answers, reducing parallel workers", "latency", latency.Round(time.Millisecond))
+		}
+	default:
+		p.successes++
+		if p.successes >= p.limit && p.limit < p.max {
+			p.limit++
+			p.successes = 0
+			p.peak = max(p.peak, p.limit)
+			p.logger.Debug("Increasing parallel workers", "workers", p.limit)
+		}
+	}
+}
+
+// reduce lowers the limit, logging the reason
+func (p *workerPool) reduce(limit int, msg string, keyvals ...interface{}) {
+	p.limit = max(1, limit)
+	p.successes = 0
+	p.reduced = time.Now()
+	p.reductions++
+	p.logger.Info(msg, append([]interface{}{"workers", p.limit}, keyvals...)...)
+}
+
+// report logs the outcome of the tuning in auto mode
+func (p *workerPool) report() {
+	if !p.auto {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.logger.Info("Tuned parallel workers", "final", p.limit, "peak", p.peak, "max", p.max, "reductions", p.reductions)
+}
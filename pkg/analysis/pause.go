@@ -0,0 +1,223 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/excel"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// pausePollInterval is how often a paused run checks whether to resume
+const pausePollInterval = 5 * time.Second
+
+// PauseControl pauses a run between API requests while a pause file exists
+// or after Toggle was called, e.g. on SIGUSR1, until it is called again
+type PauseControl struct {
+	logger *logging.Logger
+	file   string
+
+	mu       sync.Mutex
+	toggled  bool
+	waitLock sync.Mutex // Lets one worker checkpoint while the others wait
+}
+
+// NewPauseControl creates a pause control watching the given pause file; an
+// empty path only pauses on Toggle
+func NewPauseControl(logger *logging.Logger, file string) *PauseControl {
+	return &PauseControl{logger: logger, file: file}
+}
+
+// Toggle pauses a running or resumes a paused run
+func (p *PauseControl) Toggle() {
+	p.mu.Lock()
+	p.toggled = !p.toggled
+	toggled := p.toggled
+	p.mu.Unlock()
+	if toggled {
+		p.logger.Info("Pause requested, waiting for running requests to finish")
+	} else {
+		p.logger.Info("Resume requested")
+	}
+}
+
+// Paused returns whether the run should pause
+func (p *PauseControl) Paused() bool {
+	p.mu.Lock()
+	toggled := p.toggled
+	p.mu.Unlock()
+	if toggled {
+		return true
+	}
+	if p.file == "" {
+		return false
+	}
+	_, err := os.Stat(p.file)
+	return err == nil
+}
+
+// Wait blocks while the run is paused or until the context is cancelled.
+// The first caller to notice the pause runs the checkpoint before idling.
+func (p *PauseControl) Wait(ctx context.Context, checkpoint func()) {
+	if p == nil || !p.Paused() {
+		return
+	}
+
+	p.waitLock.Lock()
+	defer p.waitLock.Unlock()
+	if !p.Paused() {
+		return
+	}
+
+	if checkpoint != nil {
+		checkpoint()
+	}
+	p.logger.Info("Run paused, remove the pause file or send SIGUSR1 again to resume", "pause_file", p.file)
+	startedAt := time.Now()
+	for p.Paused() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pausePollInterval):
+		}
+	}
+	p.logger.Info("Run resumed", "paused_for", time.Since(startedAt).Round(time.Second))
+}
+
+// SetPauseControl lets the analyzer pause between API requests. On pausing
+// and after completed batches, the responses matched so far are passed to
+// checkpoint as a result that a later run can resume from.
+func (a *Analyzer) SetPauseControl(pause *PauseControl, checkpoint func(*AnalysisResult)) {
+	a.pause = pause
+	a.onCheckpoint = checkpoint
+}
+
+// waitIfPaused blocks while the run is paused, checkpointing the given
+// response analyses first
+func (a *Analyzer) waitIfPaused(analyses func() map[string]ResponseAnalysis) {
+	a.pause.Wait(a.ctx, func() {
+		if !a.checkpointing() {
+			return
+		}
+		a.onCheckpoint(a.checkpointResult(analyses()))
+	})
+}
+
+// SetCheckpointBatches sets after how many completed batches the responses
+// analyzed so far are checkpointed, so a run that crashes or is killed
+// resumes from them. Zero checkpoints after every batch, a negative number
+// only when the run pauses.
+func (a *Analyzer) SetCheckpointBatches(batches int) {
+	a.checkpointBatches = batches
+}
+
+// checkpointBatch counts a completed batch and checkpoints the given
+// response analyses if a checkpoint is due. The analyses are taken and handed
+// over under a lock, so a checkpoint never replaces a later one.
+func (a *Analyzer) checkpointBatch(analyses func() map[string]ResponseAnalysis) {
+	if !a.checkpointing() || a.checkpointBatches < 0 {
+		return
+	}
+	a.checkpointMutex.Lock()
+	defer a.checkpointMutex.Unlock()
+	a.completedBatches++
+	if a.completedBatches < max(a.checkpointBatches, 1) {
+		return
+	}
+	a.completedBatches = 0
+	a.onCheckpoint(a.checkpointResult(analyses()))
+}
+
+// interrupted returns an error if the run was cancelled, after
+// checkpointing the given response analyses whatever checkpoint_batches is,
+// so the next run resumes from them
+func (a *Analyzer) interrupted(stage string, analyses func() map[string]ResponseAnalysis) error {
+	err := a.ctx.Err()
+	if err == nil {
+		return nil
+	}
+	if a.checkpointing() {
+		a.checkpointMutex.Lock()
+		defer a.checkpointMutex.Unlock()
+		a.onCheckpoint(a.checkpointResult(analyses()))
+	}
+	return fmt.Errorf("%s interrupted: %w", stage, err)
+}
+
+// checkpointing reports whether the progress of the run is checkpointed.
+// The matching that collects the requests of a message batch is not, as its
+// results are placeholders that would pass for responses without themes.
+func (a *Analyzer) checkpointing() bool {
+	return a.onCheckpoint != nil && a.checkpointBase != nil && !a.collectingBatch
+}
+
+// checkpointResult returns a result holding the given response analyses
+// that the next run resumes from. The summaries of the previous run are kept
+// as stale, so they are regenerated unless the run skips them.
+func (a *Analyzer) checkpointResult(analyses map[string]ResponseAnalysis) *AnalysisResult {
+	base := a.checkpointBase
+	checkpoint := &AnalysisResult{
+		Themes:             base.Themes,
+		ResponseAnalyses:   analyses,
+		ThemeAnalyses:      a.BuildThemeAnalyses(analyses, base.Themes),
+		AnalysisTimestamp:  time.Now(),
+		ColumnTitle:        base.ColumnTitle,
+		ThemeDescriptions:  base.ThemeDescriptions,
+		ForgottenResponses: base.ForgottenResponses,
+	}
+
+	if previous := a.checkpointPrevious; previous != nil {
+		checkpoint.ThemeSummaries = previous.ThemeSummaries
+		checkpoint.GlobalSummary = previous.GlobalSummary
+		checkpoint.Summary = previous.Summary
+		checkpoint.SummaryCitations = previous.SummaryCitations
+		checkpoint.SummaryVariants = previous.SummaryVariants
+		checkpoint.AudienceSummaries = previous.AudienceSummaries
+		checkpoint.Embeddings = previous.Embeddings
+		for _, theme := range previous.Themes {
+			if _, ok := previous.ThemeSummaries[theme]; ok {
+				checkpoint.StaleThemeSummaries = append(checkpoint.StaleThemeSummaries, theme)
+			}
+		}
+		checkpoint.StaleGlobalSummary = previous.GlobalSummary != ""
+	}
+	return checkpoint
+}
+
+// Snapshot returns a copy of the result whose maps can be read while the run
+// goes on changing those of the result, for saving it in the background.
+// The values in the maps are shared, they are replaced rather than changed.
+func (r *AnalysisResult) Snapshot() *AnalysisResult {
+	snapshot := *r
+	snapshot.ResponseAnalyses = maps.Clone(r.ResponseAnalyses)
+	snapshot.ThemeAnalyses = maps.Clone(r.ThemeAnalyses)
+	snapshot.ThemeSummaries = maps.Clone(r.ThemeSummaries)
+	snapshot.ThemeDescriptions = maps.Clone(r.ThemeDescriptions)
+	snapshot.LanguageSummaries = maps.Clone(r.LanguageSummaries)
+	return &snapshot
+}
+
+// matchedSoFar returns the reused analyses together with those of the
+// responses matched so far
+func (a *Analyzer) matchedSoFar(reused map[string]ResponseAnalysis, responses []excel.Response, matches []claude.MatchResult) map[string]ResponseAnalysis {
+	analyses := maps.Clone(reused)
+	for i, response := range responses {
+		if i >= len(matches) {
+			break
+		}
+		analyses[response.ID] = ResponseAnalysis{
+			Response:      response,
+			Themes:        matches[i].Themes,
+			ProposedTheme: matches[i].ProposedTheme,
+			Reason:        matches[i].Reason,
+			Agreement:     matches[i].Agreement,
+			Analyzed:      time.Now(),
+		}
+	}
+	return analyses
+}
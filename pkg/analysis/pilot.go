@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// PilotSampling selects the responses of a pilot run, which analyzes a
+// subset end-to-end to try prompts and themes before the full run
+type PilotSampling struct {
+	Size     int    // Responses to analyze, 0 analyzes all
+	Strategy string // SamplingRandom or SamplingStratified
+	Seed     int64
+}
+
+// Enabled returns whether only a sample of the responses is analyzed
+func (p PilotSampling) Enabled() bool {
+	return p.Size > 0
+}
+
+// Validate checks the strategy of the sampling
+func (p PilotSampling) Validate() error {
+	if p.Size < 0 {
+		return fmt.Errorf("sample size must not be negative")
+	}
+	switch p.Strategy {
+	case SamplingRandom, SamplingStratified:
+		return nil
+	default:
+		return fmt.Errorf("invalid sample strategy: %s (valid options: random, stratified)", p.Strategy)
+	}
+}
+
+// Sample returns the responses of the pilot run in row order: a seeded
+// random sample, or one stratified by the rating bands. All responses are
+// returned if there are not more than the sample size.
+func (p PilotSampling) Sample(responses []excel.Response, bands []config.RatingBand) []excel.Response {
+	if !p.Enabled() || len(responses) <= p.Size {
+		return responses
+	}
+	if p.Strategy != SamplingStratified {
+		return SampleResponses(responses, p.Size, p.Seed)
+	}
+	candidates := make([]ResponseAnalysis, len(responses))
+	for i, response := range responses {
+		candidates[i] = ResponseAnalysis{Response: response}
+	}
+	picked := sortByRow(stratifiedSample(rand.New(rand.NewSource(p.Seed)), candidates, bands, p.Size))
+	sample := make([]excel.Response, len(picked))
+	for i, responseAnalysis := range picked {
+		sample[i] = responseAnalysis.Response
+	}
+	return sample
+}
+
+// PilotEstimate is the cost of a pilot run extrapolated to all responses
+type PilotEstimate struct {
+	Sampled         int
+	Total           int
+	Cost            float64 // Cost of the pilot run
+	Tokens          int     // Tokens of the pilot run
+	EstimatedCost   float64 // Estimated cost of analyzing all responses
+	EstimatedTokens int
+	CacheHits       int // Answers taken from the cache, which the estimate lacks
+}
+
+// EstimateFullRun extrapolates the usage of a pilot run to all responses.
+// Matching scales with the number of responses; the other tasks work on
+// the themes or on samples of the responses and are taken as they are.
+func EstimateFullRun(telemetry []claude.TaskTelemetry, model string, cost float64, tokens, sampled, total int) PilotEstimate {
+	estimate := PilotEstimate{Sampled: sampled, Total: total, Cost: cost, Tokens: tokens, EstimatedCost: cost, EstimatedTokens: tokens}
+	if sampled <= 0 || total <= sampled {
+		return estimate
+	}
+	scale := float64(total) / float64(sampled)
+
+	// Weigh the tasks by their list price, so the measured cost, which
+	// includes the stage models, is split between them
+	var all, matching float64
+	matchingTokens := 0
+	for _, task := range telemetry {
+		estimate.CacheHits += task.CacheHits
+		weight := claude.CalculateCost(model, task.InputTokens, task.OutputTokens).Cost
+		all += weight
+		if task.Task == claude.TaskMatching {
+			matching += weight
+			matchingTokens += task.InputTokens + task.OutputTokens
+		}
+	}
+	if all > 0 {
+		estimate.EstimatedCost = cost * (all + matching*(scale-1)) / all
+	}
+	estimate.EstimatedTokens = tokens + int(float64(matchingTokens)*(scale-1))
+	return estimate
+}
@@ -0,0 +1,172 @@
+package analysis
+
+import (
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+const (
+	// charsPerToken is the rough number of characters per token used for estimates
+	charsPerToken = 4
+	// promptOverheadTokens approximates the instructions and theme list of a prompt
+	promptOverheadTokens = 600
+	// matchOutputTokens approximates the output per matched response
+	matchOutputTokens = 40
+	// summaryOutputTokens approximates the output of a theme summary
+	summaryOutputTokens = 600
+	// sentimentOutputTokens approximates the output per classified sentiment
+	sentimentOutputTokens = 10
+)
+
+// RunPlan describes the API calls a run is expected to make
+type RunPlan struct {
+	Responses     int     // Responses in the input
+	NewResponses  int     // Responses that are new or changed since the previous run
+	Batches       int     // Matching batches
+	Calls         int     // Estimated number of API calls
+	InputTokens   int     // Estimated input tokens
+	OutputTokens  int     // Estimated output tokens
+	EstimatedCost float64 // Estimated cost in USD
+}
+
+// estimateTokens estimates the number of tokens of a text
+func estimateTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// PlanRun estimates the API calls, tokens and cost of analyzing the responses.
+// The estimate is rough and meant to catch runs that are larger than expected.
+func (a *Analyzer) PlanRun(responses []excel.Response, cfg *config.Config, previousResult *AnalysisResult, identifyThemesOnly bool) RunPlan {
+	plan := RunPlan{Responses: len(responses)}
+	model := a.claudeClient.Model()
+	thinking := make(map[string]bool)
+	if cfg.ThinkingBudgetTokens > 0 {
+		for _, stage := range cfg.ThinkingStages {
+			thinking[stage] = true
+		}
+	}
+	// thinkingTokens returns the thinking budget of the calls of a stage,
+	// which is billed as output and assumed to be used up
+	thinkingTokens := func(stage string, calls int) int {
+		if thinking[stage] {
+			return calls * cfg.ThinkingBudgetTokens
+		}
+		return 0
+	}
+	addCalls := func(model string, calls, inputTokens, outputTokens int) {
+		plan.Calls += calls
+		plan.InputTokens += inputTokens
+		plan.OutputTokens += outputTokens
+		plan.EstimatedCost += claude.CalculateCost(model, inputTokens, outputTokens).Cost
+	}
+
+	allTokens := 0
+	for _, response := range responses {
+		allTokens += estimateTokens(response.Text)
+	}
+
+	themes := cfg.Themes
+	if len(themes) == 0 && previousResult != nil {
+		themes = previousResult.Themes
+	}
+	identify := identifyThemesOnly || len(themes) == 0
+	if identify {
+		addCalls(model, 1, allTokens+promptOverheadTokens, summaryOutputTokens+thinkingTokens(claude.StageThemeIdentification, 1))
+	}
+	themeCount := len(themes)
+	if themeCount == 0 {
+		themeCount = 10 // Typical number of identified themes
+	}
+	if cfg.GenerateThemeDescriptions {
+		addCalls(model, themeCount, themeCount*(allTokens/4+promptOverheadTokens), themeCount*summaryOutputTokens/2)
+	}
+	if identifyThemesOnly {
+		return plan
+	}
+
+	// Matching covers only new and changed responses, and all unless locked
+	// if the themes or the matching changed
+	rematch := len(matchingChanges(cfg, themes, KnownThemeDescriptions(cfg, previousResult), previousResult)) > 0
+	newTokens := 0
+	for _, response := range responses {
+		if cfg.SkipMatching {
+			break
+		}
+		if previousResult != nil {
+			if previous, ok := previousResult.ResponseAnalyses[response.ID]; ok && previous.KeptFor(response) && (previous.Locked || !rematch) {
+				continue
+			}
+		}
+		plan.NewResponses++
+		newTokens += estimateTokens(response.Text)
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 10
+	}
+	plan.Batches = (plan.NewResponses + batchSize - 1) / batchSize
+	runs := cfg.ConsensusRuns
+	if runs < 1 {
+		runs = 1
+	}
+	costBefore := plan.EstimatedCost
+	addCalls(model, plan.Batches*runs, runs*(newTokens+plan.Batches*promptOverheadTokens), runs*plan.NewResponses*matchOutputTokens+thinkingTokens(claude.StageMatching, plan.Batches*runs))
+	if cfg.BatchAPI {
+		plan.EstimatedCost = costBefore + (plan.EstimatedCost-costBefore)*claude.BatchDiscount
+	}
+
+	// Sentiment is classified for the responses without one, in the batches
+	// of the matching
+	if cfg.SentimentEnabled {
+		unscored, unscoredTokens := 0, 0
+		for _, response := range responses {
+			if previousResult != nil {
+				if previous, ok := previousResult.ResponseAnalyses[response.ID]; ok && previous.Sentiment != nil && previous.Response.Hash == response.Hash {
+					continue
+				}
+			}
+			unscored++
+			unscoredTokens += estimateTokens(response.Text)
+		}
+		batches := (unscored + batchSize - 1) / batchSize
+		addCalls(model, batches, unscoredTokens+batches*promptOverheadTokens, unscored*sentimentOutputTokens)
+	}
+
+	// Summaries are regenerated when responses changed or their settings
+	// changed. Whether the themes drifted less than summary_drift_threshold
+	// is only known after the matching, so the plan assumes they are
+	// regenerated.
+	if plan.NewResponses > 0 || cfg.SkipMatching || previousResult == nil || len(previousResult.ThemeSummaries) == 0 ||
+		len(changedSettings(cfg, previousResult, summarySettings)) > 0 {
+		summaryCalls, summaryThinking, verifiedCalls := 0, 0, 0
+		if !cfg.SkipGlobalSummary {
+			variants := max(cfg.SummaryVariants, 1)
+			summaryCalls += variants
+			summaryThinking += thinkingTokens(claude.StageGlobalSummary, variants)
+			verifiedCalls++
+			summaryCalls += len(cfg.SummaryAudiences)
+			if variants > 1 && cfg.SummaryVariantSelection != SummarySelectionAll {
+				// The judge reads all variants
+				addCalls(model, 1, variants*summaryOutputTokens+promptOverheadTokens, summaryOutputTokens)
+			}
+		}
+		if !cfg.SkipThemeSummaries && (cfg.ThemeSummaryPrompt != "" || len(cfg.ThemeOverrides) > 0) {
+			summaryCalls += themeCount
+			summaryThinking += thinkingTokens(claude.StageThemeSummaries, themeCount)
+			verifiedCalls += themeCount
+		}
+		if summaryCalls > 0 {
+			addCalls(model, summaryCalls, allTokens+summaryCalls*promptOverheadTokens, summaryCalls*summaryOutputTokens+summaryThinking)
+		}
+		if cfg.VerifySummaries && verifiedCalls > 0 {
+			addCalls(cfg.VerificationModel, verifiedCalls, allTokens+verifiedCalls*promptOverheadTokens, verifiedCalls*summaryOutputTokens)
+		}
+	}
+
+	if cfg.ClusterCount > 0 {
+		addCalls(model, 1, cfg.ClusterCount*promptOverheadTokens, cfg.ClusterCount*20)
+	}
+
+	return plan
+}
@@ -0,0 +1,56 @@
+package analysis
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// ResponseIDPattern matches the IDs of responses: R and the row (R12), with
+// the segment number for segmented responses (R12.3), or the pseudonymous ID
+// of PseudonymousID
+const ResponseIDPattern = `R\d+(?:\.\d+)?|P[0-9a-f]{12}`
+
+// PseudonymousID derives a stable pseudonymous ID from a response ID. Without
+// the salt the pseudonym cannot be linked back to the row of the input file.
+func PseudonymousID(salt, id string) string {
+	return "P" + keyedHash(salt, "id:"+id)[:12]
+}
+
+// PseudonymizeResponses replaces the IDs of the responses by pseudonymous IDs
+// and their hashes by salted hashes, so neither can be linked to the input
+// without the salt
+func PseudonymizeResponses(responses []excel.Response, salt string) []excel.Response {
+	pseudonymized := make([]excel.Response, len(responses))
+	for i, response := range responses {
+		response.ID = PseudonymousID(salt, response.ID)
+		if response.Document != "" {
+			response.Document = PseudonymousID(salt, response.Document)
+		}
+		response.Hash = keyedHash(salt, "text:"+response.Text)
+		pseudonymized[i] = response
+	}
+	return pseudonymized
+}
+
+// WithoutResponseText returns a copy of the result in which the verbatim
+// response texts are omitted, for artifacts that are shared
+func WithoutResponseText(result *AnalysisResult) *AnalysisResult {
+	stripped := *result
+	stripped.ResponseAnalyses = make(map[string]ResponseAnalysis, len(result.ResponseAnalyses))
+	for id, responseAnalysis := range result.ResponseAnalyses {
+		responseAnalysis.Response.Text = ""
+		responseAnalysis.Response.Original = ""
+		stripped.ResponseAnalyses[id] = responseAnalysis
+	}
+	return &stripped
+}
+
+// keyedHash returns the hex encoded HMAC-SHA256 of the value
+func keyedHash(salt, value string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,61 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/embedding"
+)
+
+// Answer is the answer to an ad-hoc question about the analyzed responses
+type Answer struct {
+	Question  string
+	Text      string
+	Citations []Citation // Themes and responses cited in the answer, in order of appearance
+	Retrieved []string   // IDs of the responses retrieved for the question, most similar first
+}
+
+// Ask answers a question grounded in the themes of the result and the k
+// stored responses most similar to the question. Citations of themes or
+// responses that do not exist are removed from the answer.
+func (a *Analyzer) Ask(result *AnalysisResult, embedder embedding.Embedder, question string, k int, contextPrompt string) (*Answer, error) {
+	neighbors, err := SearchResponses(result, embedder, question, k)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := &Answer{Question: question}
+	var evidence []claude.QuestionEvidence
+	for _, neighbor := range neighbors {
+		responseAnalysis, ok := result.ResponseAnalyses[neighbor.ID]
+		if !ok || responseAnalysis.Response.Text == "" {
+			continue
+		}
+		evidence = append(evidence, claude.QuestionEvidence{
+			ID:     neighbor.ID,
+			Text:   responseAnalysis.Response.Text,
+			Themes: responseAnalysis.Themes,
+		})
+		answer.Retrieved = append(answer.Retrieved, neighbor.ID)
+	}
+	if len(evidence) == 0 {
+		return nil, fmt.Errorf("the state holds no response texts to answer from, it was written with omit_response_text or compacted with -strip-text")
+	}
+
+	themes := make([]claude.QuestionTheme, len(result.Themes))
+	for i, theme := range result.Themes {
+		themes[i] = claude.QuestionTheme{
+			Name:      theme,
+			Responses: len(result.ThemeAnalyses[theme].Responses),
+			Summary:   result.ThemeSummaries[theme].Summary,
+		}
+	}
+
+	a.logger.Info("Answering question", "retrieved", len(evidence), "themes", len(themes))
+	text, err := a.claudeClient.AnswerQuestion(question, themes, evidence, contextPrompt)
+	if err != nil {
+		return nil, err
+	}
+	answer.Text, answer.Citations = a.ValidateCitations(text, result)
+	return answer, nil
+}
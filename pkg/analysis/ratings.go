@@ -0,0 +1,223 @@
+package analysis
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+)
+
+// ThemeRating holds the ratings of the responses of a theme
+type ThemeRating struct {
+	Theme      string  `yaml:"theme"`
+	Rated      int     `yaml:"rated"` // Responses of the theme with a rating
+	MeanRating float64 `yaml:"mean_rating"`
+}
+
+// RatingBandStats holds the prevalence of the themes in a band of ratings
+type RatingBandStats struct {
+	Name        string         `yaml:"name"`
+	Min         float64        `yaml:"min"`
+	Max         float64        `yaml:"max"`
+	Responses   int            `yaml:"responses"`
+	ThemeCounts map[string]int `yaml:"theme_counts,omitempty"`
+}
+
+// minDriverResponses is the number of rated responses a theme needs with
+// and without it to be considered as a driver
+const minDriverResponses = 5
+
+// Driver holds how strongly a theme is associated with low or high ratings
+type Driver struct {
+	Theme       string  `yaml:"theme"`
+	With        int     `yaml:"with"`    // Rated responses with the theme
+	Without     int     `yaml:"without"` // Rated responses without the theme
+	MeanWith    float64 `yaml:"mean_with"`
+	MeanWithout float64 `yaml:"mean_without"`
+	Difference  float64 `yaml:"difference"`  // MeanWith minus MeanWithout
+	EffectSize  float64 `yaml:"effect_size"` // Cohen's d of the difference
+}
+
+// RatingStats connects the themes to the numeric rating of the responses
+type RatingStats struct {
+	Rated      int               `yaml:"rated"`
+	Unrated    int               `yaml:"unrated,omitempty"` // Responses without a readable rating
+	MeanRating float64           `yaml:"mean_rating"`
+	Themes     []ThemeRating     `yaml:"themes"`
+	Bands      []RatingBandStats `yaml:"bands"`
+	Drivers    []Driver          `yaml:"drivers,omitempty"` // Themes by effect size, lowest ratings first
+}
+
+// BuildRatingStats computes the mean rating per theme and the themes per
+// band of ratings. Without configured bands every rating value is its own
+// band. It returns nil if no response is rated.
+func BuildRatingStats(analyses map[string]ResponseAnalysis, themes []string, bands []config.RatingBand) *RatingStats {
+	stats := &RatingStats{}
+	var total float64
+	sums := make(map[string]float64)
+	rated := make(map[string]int)
+	var ratings []float64
+	for _, responseAnalysis := range analyses {
+		rating := responseAnalysis.Response.Rating
+		if rating == nil {
+			stats.Unrated++
+			continue
+		}
+		stats.Rated++
+		total += *rating
+		ratings = append(ratings, *rating)
+		for _, theme := range responseAnalysis.Themes {
+			sums[theme] += *rating
+			rated[theme]++
+		}
+	}
+	if stats.Rated == 0 {
+		return nil
+	}
+	stats.MeanRating = total / float64(stats.Rated)
+
+	for _, theme := range themes {
+		themeRating := ThemeRating{Theme: theme, Rated: rated[theme]}
+		if rated[theme] > 0 {
+			themeRating.MeanRating = sums[theme] / float64(rated[theme])
+		}
+		stats.Themes = append(stats.Themes, themeRating)
+	}
+
+	if len(bands) == 0 {
+		bands = valueBands(ratings)
+	}
+	for _, band := range bands {
+		stats.Bands = append(stats.Bands, RatingBandStats{Name: band.Name, Min: band.Min, Max: band.Max, ThemeCounts: make(map[string]int)})
+	}
+	for _, responseAnalysis := range analyses {
+		rating := responseAnalysis.Response.Rating
+		if rating == nil {
+			continue
+		}
+		// The first matching band wins if the configured bands overlap
+		for i := range stats.Bands {
+			band := &stats.Bands[i]
+			if *rating < band.Min || *rating > band.Max {
+				continue
+			}
+			band.Responses++
+			for _, theme := range responseAnalysis.Themes {
+				if slices.Contains(themes, theme) {
+					band.ThemeCounts[theme]++
+				}
+			}
+			break
+		}
+	}
+
+	stats.Drivers = buildDrivers(analyses, themes)
+	return stats
+}
+
+// LowDrivers returns the drivers associated with lower ratings, strongest first
+func (s *RatingStats) LowDrivers() []Driver {
+	var drivers []Driver
+	for _, driver := range s.Drivers {
+		if driver.EffectSize < 0 {
+			drivers = append(drivers, driver)
+		}
+	}
+	return drivers
+}
+
+// HighDrivers returns the drivers associated with higher ratings, strongest
+// first
+func (s *RatingStats) HighDrivers() []Driver {
+	var drivers []Driver
+	for i := len(s.Drivers) - 1; i >= 0; i-- {
+		if s.Drivers[i].EffectSize > 0 {
+			drivers = append(drivers, s.Drivers[i])
+		}
+	}
+	return drivers
+}
+
+// buildDrivers compares the ratings of the responses with and without each
+// theme. Themes with too few responses on either side are left out, as their
+// effect sizes are mostly noise.
+func buildDrivers(analyses map[string]ResponseAnalysis, themes []string) []Driver {
+	var drivers []Driver
+	for _, theme := range themes {
+		var with, without []float64
+		for _, responseAnalysis := range analyses {
+			rating := responseAnalysis.Response.Rating
+			if rating == nil {
+				continue
+			}
+			if slices.Contains(responseAnalysis.Themes, theme) {
+				with = append(with, *rating)
+			} else {
+				without = append(without, *rating)
+			}
+		}
+		if len(with) < minDriverResponses || len(without) < minDriverResponses {
+			continue
+		}
+
+		meanWith, varianceWith := meanVariance(with)
+		meanWithout, varianceWithout := meanVariance(without)
+		driver := Driver{
+			Theme:       theme,
+			With:        len(with),
+			Without:     len(without),
+			MeanWith:    meanWith,
+			MeanWithout: meanWithout,
+			Difference:  meanWith - meanWithout,
+		}
+		pooled := math.Sqrt(((float64(len(with))-1)*varianceWith + (float64(len(without))-1)*varianceWithout) / float64(len(with)+len(without)-2))
+		if pooled > 0 {
+			driver.EffectSize = driver.Difference / pooled
+		}
+		drivers = append(drivers, driver)
+	}
+	sort.SliceStable(drivers, func(i, j int) bool {
+		return drivers[i].EffectSize < drivers[j].EffectSize
+	})
+	return drivers
+}
+
+// meanVariance returns the mean and the sample variance of the values
+func meanVariance(values []float64) (float64, float64) {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+	return mean, squares / float64(len(values)-1)
+}
+
+// BandRanges returns the bands of the statistics, to recompute them later
+func (s *RatingStats) BandRanges() []config.RatingBand {
+	bands := make([]config.RatingBand, len(s.Bands))
+	for i, band := range s.Bands {
+		bands[i] = config.RatingBand{Name: band.Name, Min: band.Min, Max: band.Max}
+	}
+	return bands
+}
+
+// valueBands returns a band for each distinct rating, in ascending order
+func valueBands(ratings []float64) []config.RatingBand {
+	values := slices.Clone(ratings)
+	slices.Sort(values)
+	values = slices.Compact(values)
+	bands := make([]config.RatingBand, len(values))
+	for i, value := range values {
+		bands[i] = config.RatingBand{Name: strconv.FormatFloat(value, 'f', -1, 64), Min: value, Max: value}
+	}
+	return bands
+}
@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"math/rand"
+	"slices"
+)
+
+// NoThemeStratum is the stratum of the review sample holding the responses
+// without a theme
+const NoThemeStratum = "(no theme)"
+
+// ReviewItem is a response drawn into the review sample, with the stratum it
+// was drawn from
+type ReviewItem struct {
+	Analysis ResponseAnalysis
+	Stratum  string
+}
+
+// ReviewSample draws a stratified random sample of size theme assignments
+// for quality control. A response belongs to the stratum of its first theme
+// in theme order, or to the stratum of responses without a theme. Each
+// stratum gets at least one response if the size allows, the rest is spread
+// in proportion to the size of the strata. The seed makes the sample
+// reproducible.
+func ReviewSample(result *AnalysisResult, size int, seed int64) []ReviewItem {
+	strata := make(map[string][]ResponseAnalysis)
+	total := 0
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		stratum := NoThemeStratum
+		for _, theme := range result.Themes {
+			if slices.Contains(responseAnalysis.Themes, theme) {
+				stratum = theme
+				break
+			}
+		}
+		strata[stratum] = append(strata[stratum], responseAnalysis)
+		total++
+	}
+	var order []string
+	for _, stratum := range append(slices.Clone(result.Themes), NoThemeStratum) {
+		if len(strata[stratum]) > 0 {
+			order = append(order, stratum)
+			sortByRow(strata[stratum])
+		}
+	}
+	size = min(size, total)
+
+	// Allocate one response per stratum, then the rest by the largest
+	// shortfall against a proportional allocation
+	allocation := make(map[string]int, len(order))
+	allocated := 0
+	if size >= len(order) {
+		for _, stratum := range order {
+			allocation[stratum] = 1
+		}
+		allocated = len(order)
+	}
+	for ; allocated < size; allocated++ {
+		best, bestDeficit := "", 0.0
+		for _, stratum := range order {
+			count := len(strata[stratum])
+			if allocation[stratum] >= count {
+				continue
+			}
+			deficit := float64(count*size)/float64(total) - float64(allocation[stratum])
+			if best == "" || deficit > bestDeficit {
+				best, bestDeficit = stratum, deficit
+			}
+		}
+		allocation[best]++
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	var sample []ReviewItem
+	for _, stratum := range order {
+		if allocation[stratum] == 0 {
+			continue
+		}
+		for _, responseAnalysis := range sortByRow(randomSample(random, strata[stratum], allocation[stratum])) {
+			sample = append(sample, ReviewItem{Analysis: responseAnalysis, Stratum: stratum})
+		}
+	}
+	return sample
+}
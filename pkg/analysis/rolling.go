@@ -0,0 +1,133 @@
+package analysis
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// RollingWindow holds the theme frequencies of the responses submitted in the
+// last days of an ongoing feedback channel, and of the window of the same
+// length before it for comparison
+type RollingWindow struct {
+	Days              int            `yaml:"days"`
+	Start             time.Time      `yaml:"start"`
+	End               time.Time      `yaml:"end"` // Submission time of the latest response
+	Responses         int            `yaml:"responses"`
+	ThemeCounts       map[string]int `yaml:"theme_counts,omitempty"`
+	PreviousResponses int            `yaml:"previous_responses"`
+	PreviousCounts    map[string]int `yaml:"previous_counts,omitempty"`
+}
+
+// BuildRollingWindows counts the responses and their themes in each window of
+// the given number of days, ending at the latest submission time. It returns
+// nil if no response is dated.
+func BuildRollingWindows(analyses map[string]ResponseAnalysis, themes []string, days []int) []RollingWindow {
+	var end time.Time
+	for _, responseAnalysis := range analyses {
+		if timestamp := responseAnalysis.Response.Timestamp; timestamp.After(end) {
+			end = timestamp
+		}
+	}
+	if end.IsZero() {
+		return nil
+	}
+
+	windows := make([]RollingWindow, len(days))
+	for i, length := range days {
+		start := end.AddDate(0, 0, -length)
+		before := start.AddDate(0, 0, -length)
+		window := RollingWindow{Days: length, Start: start, End: end, ThemeCounts: make(map[string]int), PreviousCounts: make(map[string]int)}
+		for _, responseAnalysis := range analyses {
+			timestamp := responseAnalysis.Response.Timestamp
+			switch {
+			case timestamp.IsZero() || !timestamp.After(before):
+				continue
+			case timestamp.After(start):
+				window.Responses++
+				countThemes(window.ThemeCounts, responseAnalysis.Themes, themes)
+			default:
+				window.PreviousResponses++
+				countThemes(window.PreviousCounts, responseAnalysis.Themes, themes)
+			}
+		}
+		windows[i] = window
+	}
+	return windows
+}
+
+// countThemes counts the themes of a response that are in the current list
+func countThemes(counts map[string]int, assigned []string, themes []string) {
+	for _, theme := range assigned {
+		if slices.Contains(themes, theme) {
+			counts[theme]++
+		}
+	}
+}
+
+// SummaryBaseline holds the theme frequencies the summaries were generated
+// from, to tell how far the themes drifted since
+type SummaryBaseline struct {
+	Responses   int            `yaml:"responses"`
+	ThemeCounts map[string]int `yaml:"theme_counts,omitempty"`
+	GeneratedAt time.Time      `yaml:"generated_at"`
+}
+
+// NewSummaryBaseline records the theme frequencies of the analyzed responses
+func NewSummaryBaseline(analyses map[string]ResponseAnalysis, themes []string) *SummaryBaseline {
+	baseline := &SummaryBaseline{Responses: len(analyses), ThemeCounts: make(map[string]int), GeneratedAt: time.Now()}
+	for _, responseAnalysis := range analyses {
+		countThemes(baseline.ThemeCounts, responseAnalysis.Themes, themes)
+	}
+	return baseline
+}
+
+// Drift returns how far the share of the themes among all theme assignments
+// moved between the baseline and the current one, as the total variation
+// distance: 0 for the same shares, 1 for disjoint themes
+func (b *SummaryBaseline) Drift(current *SummaryBaseline) float64 {
+	shares := func(counts map[string]int) map[string]float64 {
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+		result := make(map[string]float64, len(counts))
+		for theme, count := range counts {
+			if total > 0 {
+				result[theme] = float64(count) / float64(total)
+			}
+		}
+		return result
+	}
+	before, after := shares(b.ThemeCounts), shares(current.ThemeCounts)
+	distance := 0.0
+	for theme, share := range before {
+		distance += math.Abs(share - after[theme])
+	}
+	for theme, share := range after {
+		if _, ok := before[theme]; !ok {
+			distance += share
+		}
+	}
+	return distance / 2
+}
+
+// summaryDriftBelowThreshold returns whether the themes of the result drifted
+// so little from those the previous summaries were generated from that the
+// summaries are kept for the changed responses
+func (a *Analyzer) summaryDriftBelowThreshold(result *AnalysisResult, previous *AnalysisResult, threshold float64) bool {
+	if threshold <= 0 || previous.SummaryBaseline == nil || !slices.Equal(result.Themes, previous.Themes) {
+		return false
+	}
+	drift := previous.SummaryBaseline.Drift(NewSummaryBaseline(result.ResponseAnalyses, result.Themes))
+	if drift >= threshold {
+		a.logger.Info("Regenerating the summaries, the themes drifted", "drift", math.Round(drift*1000)/1000, "threshold", threshold)
+		return false
+	}
+	a.logger.Info("Keeping the summaries, the themes drifted less than the threshold",
+		"drift", math.Round(drift*1000)/1000,
+		"threshold", threshold,
+		"summarized_responses", previous.SummaryBaseline.Responses,
+		"responses", len(result.ResponseAnalyses))
+	return true
+}
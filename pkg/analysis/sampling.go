@@ -0,0 +1,211 @@
+package analysis
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/embedding"
+)
+
+// Strategies selecting the responses a theme summary is generated from
+const (
+	SamplingShortest       = "shortest"       // Shortest responses first
+	SamplingLongest        = "longest"        // Longest responses first
+	SamplingRandom         = "random"         // Seeded random sample
+	SamplingRepresentative = "representative" // Closest to the centroid of the theme's embeddings
+	SamplingStratified     = "stratified"     // Random sample per rating band, in proportion to the bands
+)
+
+// summarySample returns the responses of a theme to generate its summary
+// from, at most the configured sample size of the theme, selected by the
+// configured strategy. All responses are returned if they fit.
+func (a *Analyzer) summarySample(theme string, ids []string, result *AnalysisResult, cfg *config.Config) []ResponseAnalysis {
+	responses := make([]ResponseAnalysis, 0, len(ids))
+	for _, id := range ids {
+		if responseAnalysis, ok := result.ResponseAnalyses[id]; ok {
+			responses = append(responses, responseAnalysis)
+		}
+	}
+	// Start from a stable order, so the same responses give the same prompt
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Response.Before(responses[j].Response)
+	})
+	size := cfg.SummarySampleSizeFor(theme)
+	if len(responses) <= size {
+		return responses
+	}
+
+	strategy := cfg.SummarySampling
+	if strategy == SamplingRepresentative && (result.Embeddings == nil || len(result.Embeddings.Responses) == 0) {
+		a.logger.Warn("No embeddings for representative summary sampling, sampling randomly", "theme", theme)
+		strategy = SamplingRandom
+	}
+	a.logger.Debug("Sampling responses for theme summary", "theme", theme, "strategy", strategy, "sample", size, "responses", len(responses))
+
+	random := rand.New(rand.NewSource(cfg.SummarySamplingSeed + themeSeed(theme)))
+	switch strategy {
+	case SamplingLongest:
+		sort.SliceStable(responses, func(i, j int) bool {
+			return len(responses[i].Response.Text) > len(responses[j].Response.Text)
+		})
+		return responses[:size]
+	case SamplingRandom:
+		return sortByRow(randomSample(random, responses, size))
+	case SamplingRepresentative:
+		return representativeSample(responses, result.Embeddings, size)
+	case SamplingStratified:
+		return sortByRow(stratifiedSample(random, responses, cfg.RatingBands, size))
+	default:
+		sort.SliceStable(responses, func(i, j int) bool {
+			return len(responses[i].Response.Text) < len(responses[j].Response.Text)
+		})
+		return responses[:size]
+	}
+}
+
+// summarySettings are the settings selecting the responses of the theme
+// summaries and the variants of the global summary, and the prompts and
+// models generating them
+var summarySettings = []string{
+	"summary_sample_size", "summary_sampling", "summary_sampling_seed", "theme_overrides", "summary_variants", "summary_variant_selection", "sentiment_enabled",
+	"claude_model", "llm_provider", "llm_base_url", "mock_provider", "context_prompt", "theme_summary_prompt", "global_summary_prompt", "global_summary_length",
+	"output_language", "summary_citations", "thinking_budget_tokens", "thinking_stages", "verify_summaries", "verification_model", "remove_unsupported_claims",
+	FingerprintPromptRevisions,
+}
+
+// summarySettingsChanged returns whether the settings of the summaries
+// changed since the previous run, so its summaries cannot be reused
+func (a *Analyzer) summarySettingsChanged(cfg *config.Config, previous *AnalysisResult) bool {
+	changes := changedSettings(cfg, previous, summarySettings)
+	if len(changes) > 0 {
+		a.logger.Info("Regenerating the summaries, their settings changed", "setting", changes[0])
+		return true
+	}
+	return false
+}
+
+// themeSeed derives a seed from the theme name, so the themes of a run get
+// different random samples
+func themeSeed(theme string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(theme))
+	return int64(hash.Sum64() >> 1)
+}
+
+// randomSample picks size responses at random
+func randomSample(random *rand.Rand, responses []ResponseAnalysis, size int) []ResponseAnalysis {
+	sample := make([]ResponseAnalysis, 0, size)
+	for _, index := range random.Perm(len(responses))[:size] {
+		sample = append(sample, responses[index])
+	}
+	return sample
+}
+
+// sortByRow orders responses by their row in the Excel file
+func sortByRow(responses []ResponseAnalysis) []ResponseAnalysis {
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Response.Before(responses[j].Response)
+	})
+	return responses
+}
+
+// representativeSample picks the responses closest to the centroid of the
+// embeddings of all responses, most typical first. Responses without an
+// embedding are not picked.
+func representativeSample(responses []ResponseAnalysis, store *EmbeddingStore, size int) []ResponseAnalysis {
+	byID := make(map[string]ResponseAnalysis, len(responses))
+	candidates := make(map[string][]float32, len(responses))
+	var centroid []float32
+	for _, responseAnalysis := range responses {
+		responseEmbedding, ok := store.Responses[responseAnalysis.Response.ID]
+		if !ok {
+			continue
+		}
+		if centroid == nil {
+			centroid = make([]float32, len(responseEmbedding.Vector))
+		}
+		for i, value := range responseEmbedding.Vector {
+			if i < len(centroid) {
+				centroid[i] += value
+			}
+		}
+		byID[responseAnalysis.Response.ID] = responseAnalysis
+		candidates[responseAnalysis.Response.ID] = responseEmbedding.Vector
+	}
+	sample := make([]ResponseAnalysis, 0, size)
+	for _, neighbor := range embedding.Nearest(centroid, candidates, size) {
+		sample = append(sample, byID[neighbor.ID])
+	}
+	return sample
+}
+
+// stratifiedSample picks responses at random from each band of ratings, in
+// proportion to the size of the bands, and at least one from each band if
+// the sample is large enough. Unrated responses form a band of their own.
+// Without configured bands every rating value is a band.
+func stratifiedSample(random *rand.Rand, responses []ResponseAnalysis, bands []config.RatingBand, size int) []ResponseAnalysis {
+	if len(bands) == 0 {
+		var ratings []float64
+		for _, responseAnalysis := range responses {
+			if rating := responseAnalysis.Response.Rating; rating != nil {
+				ratings = append(ratings, *rating)
+			}
+		}
+		bands = valueBands(ratings)
+	}
+	strata := make([][]ResponseAnalysis, len(bands)+1) // The last holds the unrated responses
+	for _, responseAnalysis := range responses {
+		stratum := len(bands)
+		if rating := responseAnalysis.Response.Rating; rating != nil {
+			// The first matching band wins if the configured bands overlap
+			for i, band := range bands {
+				if *rating >= band.Min && *rating <= band.Max {
+					stratum = i
+					break
+				}
+			}
+		}
+		strata[stratum] = append(strata[stratum], responseAnalysis)
+	}
+
+	// Give each band one response, then the rest to the band furthest below
+	// its proportional share
+	quotas := make([]int, len(strata))
+	assigned, nonEmpty := 0, 0
+	for _, stratum := range strata {
+		if len(stratum) > 0 {
+			nonEmpty++
+		}
+	}
+	if size >= nonEmpty {
+		for i, stratum := range strata {
+			if len(stratum) > 0 {
+				quotas[i] = 1
+				assigned++
+			}
+		}
+	}
+	for ; assigned < size; assigned++ {
+		best, bestDeficit := -1, 0.0
+		for i, stratum := range strata {
+			if quotas[i] >= len(stratum) {
+				continue
+			}
+			deficit := float64(len(stratum))*float64(size)/float64(len(responses)) - float64(quotas[i])
+			if best < 0 || deficit > bestDeficit {
+				best, bestDeficit = i, deficit
+			}
+		}
+		quotas[best]++
+	}
+
+	var sample []ResponseAnalysis
+	for i, stratum := range strata {
+		if quotas[i] > 0 {
+			sample = append(sample, randomSample(random, stratum, quotas[i])...)
+		}
+	}
+	return sample
+}
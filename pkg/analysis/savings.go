@@ -0,0 +1,58 @@
+package analysis
+
+import (
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/excel"
+)
+
+// CostSavings are the tokens and cost a run avoided, estimated for answers
+// taken from the cache and for the matches and summaries of the previous run
+// that were reused instead of computed again
+type CostSavings struct {
+	CachedRequests  int     `yaml:"cached_requests,omitempty"`
+	CacheTokens     int     `yaml:"cache_tokens,omitempty"`
+	CacheCost       float64 `yaml:"cache_cost,omitempty"`
+	ReusedResponses int     `yaml:"reused_responses,omitempty"` // Responses whose previous matches were kept
+	ReuseTokens     int     `yaml:"reuse_tokens,omitempty"`
+	ReuseCost       float64 `yaml:"reuse_cost,omitempty"`
+}
+
+// Tokens returns the tokens saved by the cache and by reuse
+func (s CostSavings) Tokens() int {
+	return s.CacheTokens + s.ReuseTokens
+}
+
+// Cost returns the cost saved by the cache and by reuse
+func (s CostSavings) Cost() float64 {
+	return s.CacheCost + s.ReuseCost
+}
+
+// AddCache adds the answers taken from the cache
+func (s *CostSavings) AddCache(cache claude.CacheSavings) {
+	s.CachedRequests += cache.Requests
+	s.CacheTokens += cache.InputTokens + cache.OutputTokens
+	s.CacheCost += cache.Cost
+}
+
+// ReuseSavings estimates what reusing the previous result saves: the
+// difference between the plan of analyzing all responses from scratch with
+// the themes of the previous result and the plan of the incremental run.
+// Without a previous result nothing is reused. If unchanged is set, the run
+// reuses the previous result as a whole without calling the API.
+func (a *Analyzer) ReuseSavings(responses []excel.Response, cfg *config.Config, previous *AnalysisResult, unchanged bool) CostSavings {
+	if previous == nil {
+		return CostSavings{}
+	}
+	full := a.PlanRun(responses, cfg, &AnalysisResult{Themes: previous.Themes}, false)
+	incremental := RunPlan{}
+	if !unchanged {
+		incremental = a.PlanRun(responses, cfg, previous, false)
+	}
+	savings := CostSavings{ReusedResponses: full.NewResponses - incremental.NewResponses}
+	if full.EstimatedCost > incremental.EstimatedCost {
+		savings.ReuseTokens = full.InputTokens + full.OutputTokens - incremental.InputTokens - incremental.OutputTokens
+		savings.ReuseCost = full.EstimatedCost - incremental.EstimatedCost
+	}
+	return savings
+}
@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+)
+
+// ScoreSentiment classifies the sentiment of the responses that have none
+// yet, in batches of batch_size. The responses of a failed batch are left
+// without a sentiment and classified by the next run. An error is only
+// returned if the run is cancelled.
+func (a *Analyzer) ScoreSentiment(responseAnalyses map[string]ResponseAnalysis) error {
+	var pending []ResponseAnalysis
+	for _, responseAnalysis := range responseAnalyses {
+		if responseAnalysis.Sentiment == nil {
+			pending = append(pending, responseAnalysis)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Response.Before(pending[j].Response) })
+
+	batchSize := a.batchSize
+	if batchSize <= 0 {
+		batchSize = 10
+	}
+	a.logger.Info("Classifying the sentiment of the responses", "responses", len(pending), "batch_size", batchSize)
+
+	scored, failed := 0, 0
+	for start := 0; start < len(pending); start += batchSize {
+		a.waitIfPaused(func() map[string]ResponseAnalysis { return responseAnalyses })
+		if err := a.interrupted("sentiment", func() map[string]ResponseAnalysis { return responseAnalyses }); err != nil {
+			return err
+		}
+		batch := pending[start:min(start+batchSize, len(pending))]
+		texts := make([]string, len(batch))
+		for i, responseAnalysis := range batch {
+			texts[i] = responseAnalysis.Response.Text
+		}
+		sentiments, err := a.claudeClient.ClassifySentiments(texts)
+		if err != nil {
+			if err := a.interrupted("sentiment", func() map[string]ResponseAnalysis { return responseAnalyses }); err != nil {
+				return err
+			}
+			a.logger.Warn("Failed to classify the sentiment of a batch, the next run classifies it again", "responses", len(batch), "error", err)
+			failed += len(batch)
+			continue
+		}
+		for i, responseAnalysis := range batch {
+			if sentiments[i] == nil {
+				continue
+			}
+			responseAnalysis.Sentiment = sentiments[i]
+			responseAnalyses[responseAnalysis.Response.ID] = responseAnalysis
+			scored++
+		}
+		a.checkpointBatch(func() map[string]ResponseAnalysis { return responseAnalyses })
+	}
+	a.logger.Info("Classified the sentiment of the responses", "classified", scored, "failed", failed)
+	return nil
+}
+
+// dropSentiments removes the sentiments of the responses, so a run without
+// the sentiment stage does not report those of earlier runs
+func dropSentiments(responseAnalyses map[string]ResponseAnalysis) {
+	for id, responseAnalysis := range responseAnalyses {
+		if responseAnalysis.Sentiment != nil {
+			responseAnalysis.Sentiment = nil
+			responseAnalyses[id] = responseAnalysis
+		}
+	}
+}
+
+// OverallSentiment counts all responses by sentiment, nil if none has one
+func OverallSentiment(responseAnalyses map[string]ResponseAnalysis) *claude.SentimentBalance {
+	var balance *claude.SentimentBalance
+	for _, responseAnalysis := range responseAnalyses {
+		if responseAnalysis.Sentiment == nil {
+			continue
+		}
+		if balance == nil {
+			balance = &claude.SentimentBalance{}
+		}
+		balance.Add(*responseAnalysis.Sentiment)
+	}
+	return balance
+}
@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Intervals of the time series
+const (
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// TimePeriod holds the theme frequencies of one week or month of the survey
+type TimePeriod struct {
+	Start       time.Time      `yaml:"start"`
+	Label       string         `yaml:"label"`     // 2024-03 for months, 2024-W11 for ISO weeks
+	Responses   int            `yaml:"responses"` // Responses submitted in the period
+	ThemeCounts map[string]int `yaml:"theme_counts,omitempty"`
+}
+
+// TimeSeries holds the theme frequencies per period of the survey, so changes
+// like a spike after a policy change become visible
+type TimeSeries struct {
+	Interval string       `yaml:"interval"`
+	Periods  []TimePeriod `yaml:"periods"`
+	Undated  int          `yaml:"undated,omitempty"` // Responses without a readable timestamp
+}
+
+// BuildTimeSeries counts the responses and their themes per week or month.
+// Periods without responses between the first and the last one are included,
+// so the periods are evenly spaced. It returns nil if no response is dated.
+func BuildTimeSeries(analyses map[string]ResponseAnalysis, themes []string, interval string) *TimeSeries {
+	series := &TimeSeries{Interval: interval}
+	counts := make(map[time.Time]*TimePeriod)
+	var first, last time.Time
+	for _, responseAnalysis := range analyses {
+		timestamp := responseAnalysis.Response.Timestamp
+		if timestamp.IsZero() {
+			series.Undated++
+			continue
+		}
+		start := periodStart(timestamp, interval)
+		period, ok := counts[start]
+		if !ok {
+			period = &TimePeriod{Start: start, ThemeCounts: make(map[string]int)}
+			counts[start] = period
+		}
+		period.Responses++
+		for _, theme := range responseAnalysis.Themes {
+			period.ThemeCounts[theme]++
+		}
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if start.After(last) {
+			last = start
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	for start := first; !start.After(last); start = nextPeriod(start, interval) {
+		period := TimePeriod{Start: start, ThemeCounts: make(map[string]int)}
+		if counted, ok := counts[start]; ok {
+			period = *counted
+		}
+		period.Label = periodLabel(start, interval)
+		// Only keep themes of the current list, split themes may be gone
+		for theme := range period.ThemeCounts {
+			if !slices.Contains(themes, theme) {
+				delete(period.ThemeCounts, theme)
+			}
+		}
+		series.Periods = append(series.Periods, period)
+	}
+	return series
+}
+
+// periodStart returns the start of the month or ISO week of a timestamp
+func periodStart(timestamp time.Time, interval string) time.Time {
+	year, month, day := timestamp.Date()
+	if interval == IntervalWeek {
+		monday := day - (int(timestamp.Weekday())+6)%7
+		return time.Date(year, month, monday, 0, 0, 0, 0, timestamp.Location())
+	}
+	return time.Date(year, month, 1, 0, 0, 0, 0, timestamp.Location())
+}
+
+// nextPeriod returns the start of the period after the given one
+func nextPeriod(start time.Time, interval string) time.Time {
+	if interval == IntervalWeek {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// periodLabel names a period by its month or ISO week
+func periodLabel(start time.Time, interval string) string {
+	if interval == IntervalWeek {
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return start.Format("2006-01")
+}
@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+)
+
+// Selections of the global summary among its variants
+const (
+	SummarySelectionJudge = "judge" // A judge pass picks the best variant
+	SummarySelectionAll   = "all"   // All variants are presented for the analyst to choose
+)
+
+// SummaryVariant is one of several global summaries generated for a run
+type SummaryVariant struct {
+	Summary   string     `yaml:"summary"`
+	Citations []Citation `yaml:"citations,omitempty"`
+	Selected  bool       `yaml:"selected,omitempty"` // Picked by the judge as the global summary
+	Reason    string     `yaml:"reason,omitempty"`   // Why the judge picked the variant
+}
+
+// GenerateGlobalSummaries generates the global summary of the result. With
+// more than one summary variant configured, the variants are generated
+// concurrently and either the judge picks the global summary among them, or
+// all are kept for the analyst to choose and the first one is the global
+// summary.
+func (a *Analyzer) GenerateGlobalSummaries(result *AnalysisResult, cfg *config.Config, globalSummaryPrompt string) error {
+	var err error
+	if cfg.SummaryVariants <= 1 {
+		result.SummaryVariants = nil
+		if cfg.SummaryCitations {
+			result.GlobalSummary, result.SummaryCitations, err = a.GenerateCitedGlobalSummary(result, globalSummaryPrompt, cfg.SummaryLength)
+		} else {
+			result.GlobalSummary, err = a.GenerateGlobalSummary(result.ThemeSummaries, globalSummaryPrompt, cfg.SummaryLength)
+		}
+		return err
+	}
+
+	a.logger.Info("Generating global summary variants", "variants", cfg.SummaryVariants)
+	variants := make([]SummaryVariant, cfg.SummaryVariants)
+	errs := make([]error, cfg.SummaryVariants)
+	var wg sync.WaitGroup
+	for i := range variants {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			variants[i], errs[i] = a.generateSummaryVariant(result, cfg, globalSummaryPrompt, i)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed to generate global summary variant %d: %w", i+1, err)
+		}
+	}
+
+	chosen := 0
+	if cfg.SummaryVariantSelection != SummarySelectionAll {
+		summaries := make([]string, len(variants))
+		for i, variant := range variants {
+			summaries[i] = variant.Summary
+		}
+		judgement, err := a.claudeClient.JudgeSummaries(result.Themes, result.ThemeSummaries, summaries)
+		if err != nil {
+			a.logger.Warn("Failed to judge the global summary variants, using the first one", "error", err)
+		} else {
+			chosen = judgement.Best
+			variants[chosen].Selected = true
+			variants[chosen].Reason = judgement.Reason
+			a.logger.Info("Judge picked a global summary variant", "variant", chosen+1, "reason", judgement.Reason)
+		}
+	}
+
+	result.SummaryVariants = variants
+	result.GlobalSummary = variants[chosen].Summary
+	result.SummaryCitations = variants[chosen].Citations
+	return nil
+}
+
+// generateSummaryVariant generates one variant of the global summary
+func (a *Analyzer) generateSummaryVariant(result *AnalysisResult, cfg *config.Config, globalSummaryPrompt string, variant int) (SummaryVariant, error) {
+	if !cfg.SummaryCitations {
+		summary, err := a.claudeClient.GenerateGlobalSummaryVariant(result.ThemeSummaries, globalSummaryPrompt, cfg.SummaryLength, variant)
+		return SummaryVariant{Summary: summary}, err
+	}
+	summary, err := a.claudeClient.GenerateCitedGlobalSummaryVariant(result.Themes, result.ThemeSummaries, a.citationEvidence(result), globalSummaryPrompt, cfg.SummaryLength, variant)
+	if err != nil {
+		return SummaryVariant{}, err
+	}
+	summary, citations := a.ValidateCitations(summary, result)
+	return SummaryVariant{Summary: summary, Citations: citations}, nil
+}
+
+// SummaryVariantsToChoose returns the global summary variants the analyst
+// is to choose from, none if the judge picked one
+func (r *AnalysisResult) SummaryVariantsToChoose() []SummaryVariant {
+	for _, variant := range r.SummaryVariants {
+		if variant.Selected {
+			return nil
+		}
+	}
+	return r.SummaryVariants
+}
@@ -0,0 +1,29 @@
+package analysis
+
+import "sort"
+
+// WarningCount is the number of times a warning was logged during a run
+type WarningCount struct {
+	Message string
+	Count   int
+}
+
+// WarningCounts groups the warnings of the result by message, most frequent
+// first
+func (r *AnalysisResult) WarningCounts() []WarningCount {
+	counts := make(map[string]int)
+	for _, warning := range r.Warnings {
+		counts[warning.Message]++
+	}
+	warningCounts := make([]WarningCount, 0, len(counts))
+	for message, count := range counts {
+		warningCounts = append(warningCounts, WarningCount{Message: message, Count: count})
+	}
+	sort.Slice(warningCounts, func(i, j int) bool {
+		if warningCounts[i].Count != warningCounts[j].Count {
+			return warningCounts[i].Count > warningCounts[j].Count
+		}
+		return warningCounts[i].Message < warningCounts[j].Message
+	})
+	return warningCounts
+}
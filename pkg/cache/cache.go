@@ -149,6 +149,31 @@ func (c *Cache) Clear() error {
 	return nil
 }
 
+// RemoveMatching removes all entries whose key or value matches the given
+// function and returns the number of removed entries
+func (c *Cache) RemoveMatching(match func(key, value string) bool) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	removed := 0
+	for hashedKey, entry := range c.entries {
+		if !match(entry.Key, entry.Value) {
+			continue
+		}
+		delete(c.entries, hashedKey)
+		removed++
+		if c.persisted {
+			filePath := filepath.Join(c.cacheDir, hashedKey+".json")
+			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+				return removed, fmt.Errorf("failed to remove cache file: %w", err)
+			}
+		}
+	}
+
+	c.logger.Info("Removed matching cache entries", "count", removed)
+	return removed, nil
+}
+
 // persistEntry saves a cache entry to disk
 func (c *Cache) persistEntry(hashedKey string, entry *CacheEntry) error {
 	// Marshal entry to JSON
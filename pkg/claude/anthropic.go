@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/oetiker/response-analyzer/pkg/llm"
+)
+
+// anthropic encodes requests for the messages API of Anthropic
+type anthropic struct{}
+
+// Name identifies the provider
+func (anthropic) Name() string {
+	return llm.ProviderAnthropic
+}
+
+// CompletionURL returns the URL of the messages API
+func (anthropic) CompletionURL() string {
+	return ClaudeAPIURL
+}
+
+// ModelsURL returns the URL of the model list
+func (anthropic) ModelsURL() string {
+	return ModelsAPIURL
+}
+
+// Authorize sets the API key and version headers
+func (anthropic) Authorize(req *http.Request, apiKey string) {
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+}
+
+// EncodeRequest returns the body of a messages request
+func (anthropic) EncodeRequest(req llm.Request) ([]byte, error) {
+	return json.Marshal(anthropicBody(req))
+}
+
+// anthropicBody converts a request into the body of a messages request
+func anthropicBody(req llm.Request) RequestBody {
+	body := RequestBody{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Messages:    []Message{{Role: "user", Content: req.Prompt}},
+		Temperature: req.Temperature,
+		System:      req.System,
+	}
+	if req.ThinkingBudget > 0 {
+		body.Thinking = &ThinkingConfig{Type: "enabled", BudgetTokens: req.ThinkingBudget}
+	}
+	if req.Tool != nil {
+		body.Tools = []Tool{{Name: req.Tool.Name, Description: req.Tool.Description, InputSchema: req.Tool.InputSchema}}
+		body.ToolChoice = &ToolChoice{Type: "tool", Name: req.Tool.Name}
+	}
+	if req.User != "" {
+		body.Metadata = &RequestMetadata{UserID: req.User}
+	}
+	return body
+}
+
+// neutralRequest converts the body of a messages request, as built for the
+// cache key and the request queue, into a provider-neutral request
+func neutralRequest(body RequestBody) llm.Request {
+	req := llm.Request{
+		Model:       body.Model,
+		System:      body.System,
+		MaxTokens:   body.MaxTokens,
+		Temperature: body.Temperature,
+	}
+	if len(body.Messages) > 0 {
+		req.Prompt = body.Messages[0].Content
+	}
+	if body.Thinking != nil {
+		req.ThinkingBudget = body.Thinking.BudgetTokens
+	}
+	if len(body.Tools) > 0 {
+		req.Tool = &llm.Tool{Name: body.Tools[0].Name, Description: body.Tools[0].Description, InputSchema: body.Tools[0].InputSchema}
+	}
+	if body.Metadata != nil {
+		req.User = body.Metadata.UserID
+	}
+	return req
+}
+
+// DecodeResponse decodes a message, leaving out thinking blocks; the answer
+// of a tool call is its input
+func (anthropic) DecodeResponse(data []byte) (llm.Response, error) {
+	var body ResponseBody
+	if err := json.Unmarshal(data, &body); err != nil {
+		return llm.Response{}, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	response := llm.Response{
+		InputTokens:  body.Usage.InputTokens,
+		OutputTokens: body.Usage.OutputTokens,
+		StopReason:   body.StopReason,
+	}
+	for _, block := range body.Content {
+		switch block.Type {
+		case "text":
+			response.Text += block.Text
+		case "tool_use":
+			response.Text += string(block.Input)
+		case "thinking":
+			response.ThinkingLength += len(block.Thinking)
+		}
+	}
+	return response, nil
+}
+
+// ErrorMessage extracts the message of an Anthropic error
+func (anthropic) ErrorMessage(data []byte) string {
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && body.Error.Message != "" {
+		return body.Error.Message
+	}
+	return string(data)
+}
@@ -0,0 +1,341 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// MessageBatchesURL is the URL of the Message Batches API
+	MessageBatchesURL = "https://api.anthropic.com/v1/messages/batches"
+	// BatchDiscount is the share of the regular price charged for requests
+	// of a message batch
+	BatchDiscount = 0.5
+	// DefaultBatchPollInterval is the default interval between checks of a
+	// message batch
+	DefaultBatchPollInterval = time.Minute
+	// MaxBatchRequests is the largest number of requests in a message batch
+	MaxBatchRequests = 100000
+)
+
+// errBatchNotFound is returned for a message batch the API does not know
+var errBatchNotFound = errors.New("message batch not found")
+
+// messageBatch is the status of a message batch
+type messageBatch struct {
+	ID               string `json:"id"`
+	ProcessingStatus string `json:"processing_status"` // in_progress, canceling or ended
+	RequestCounts    struct {
+		Processing int `json:"processing"`
+		Succeeded  int `json:"succeeded"`
+		Errored    int `json:"errored"`
+		Canceled   int `json:"canceled"`
+		Expired    int `json:"expired"`
+	} `json:"request_counts"`
+	ResultsURL string `json:"results_url"`
+}
+
+// batchRequest is a request of a message batch
+type batchRequest struct {
+	CustomID string      `json:"custom_id"`
+	Params   RequestBody `json:"params"`
+}
+
+// batchResult is the result of a request of a message batch, a line of the
+// results file
+type batchResult struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string          `json:"type"` // succeeded, errored, canceled or expired
+		Message json.RawMessage `json:"message,omitempty"`
+		Error   json.RawMessage `json:"error,omitempty"`
+	} `json:"result"`
+}
+
+// SetBatchAPI makes the theme matching of runs collect its requests in the
+// queue and send them as a message batch, at half the price, polling for
+// the answers at the given interval
+func (c *Client) SetBatchAPI(queue *Queue, pollInterval time.Duration) {
+	c.SetQueue(queue, false)
+	if pollInterval <= 0 {
+		pollInterval = DefaultBatchPollInterval
+	}
+	c.batchPollInterval = pollInterval
+}
+
+// BatchAPI reports whether theme matching is sent as a message batch
+func (c *Client) BatchAPI() bool {
+	return c.batchPollInterval > 0 && c.queue != nil
+}
+
+// SendAsBatch queues the requests made by collect instead of sending them,
+// then sends the pending requests of the queue as a message batch and waits
+// for their answers. Requests made again afterwards are answered from the
+// queue; those the batch failed to answer are sent directly.
+func (c *Client) SendAsBatch(collect func() error) (int, error) {
+	c.recordQueue = true
+	err := collect()
+	c.recordQueue = false
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect requests: %w", err)
+	}
+	return c.DrainQueueBatch(c.queue, c.batchPollInterval)
+}
+
+// DrainQueueBatch sends the pending requests of the queue as one message
+// batch, waits until it has ended and stores the answers. The ID of the
+// batch is kept in the queue until then, so after an interruption the batch
+// is polled again rather than sent twice. It returns the number of answered
+// requests; requests the batch did not answer stay pending.
+func (c *Client) DrainQueueBatch(queue *Queue, pollInterval time.Duration) (int, error) {
+	if pollInterval <= 0 {
+		pollInterval = DefaultBatchPollInterval
+	}
+
+	id := queue.batchID()
+	if id != "" {
+		c.logger.Info("Waiting for the message batch sent before", "batch", id)
+		if _, err := c.batchStatus(id); errors.Is(err, errBatchNotFound) {
+			c.logger.Warn("The message batch sent before is unknown to the API, sending the requests again", "batch", id)
+			if err := queue.setBatchID(""); err != nil {
+				return 0, err
+			}
+			id = ""
+		}
+	}
+	if id == "" {
+		pending := queue.Pending()
+		if len(pending) == 0 {
+			return 0, nil
+		}
+		if len(pending) > MaxBatchRequests {
+			c.logger.Warn("Too many requests for one message batch, drain the queue again for the rest", "pending", len(pending), "max", MaxBatchRequests)
+			pending = pending[:MaxBatchRequests]
+		}
+		estimate := 0.0
+		for _, entry := range pending {
+			estimate += estimateRequestCost(entry.Request) * BatchDiscount
+		}
+		if err := c.reserveBudget(estimate); err != nil {
+			return 0, err
+		}
+		defer c.releaseBudget(estimate)
+		batch, err := c.createBatch(pending)
+		if err != nil {
+			return 0, err
+		}
+		if err := queue.setBatchID(batch.ID); err != nil {
+			return 0, err
+		}
+		id = batch.ID
+		c.logger.Info("Sent message batch", "batch", id, "requests", len(pending))
+	}
+
+	batch, err := c.waitForBatch(id, pollInterval)
+	if err != nil {
+		return 0, err
+	}
+	answered, err := c.storeBatchResults(queue, batch)
+	if err != nil {
+		return answered, err
+	}
+	if err := queue.setBatchID(""); err != nil {
+		return answered, err
+	}
+	return answered, nil
+}
+
+// createBatch sends the requests as a message batch, identified by the names
+// of their queue files
+func (c *Client) createBatch(pending []*QueuedRequest) (*messageBatch, error) {
+	requests := make([]batchRequest, len(pending))
+	for i, entry := range pending {
+		requests[i] = batchRequest{CustomID: batchCustomID(entry.Key), Params: entry.Request}
+	}
+	data, err := json.Marshal(map[string][]batchRequest{"requests": requests})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message batch: %w", err)
+	}
+	var batch messageBatch
+	if err := c.batchCall(http.MethodPost, MessageBatchesURL, data, &batch); err != nil {
+		return nil, fmt.Errorf("failed to send message batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// batchStatus returns the status of a message batch
+func (c *Client) batchStatus(id string) (*messageBatch, error) {
+	var batch messageBatch
+	if err := c.batchCall(http.MethodGet, MessageBatchesURL+"/"+id, nil, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// waitForBatch polls a message batch until it has ended
+func (c *Client) waitForBatch(id string, pollInterval time.Duration) (*messageBatch, error) {
+	for {
+		batch, err := c.batchStatus(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check message batch: %w", err)
+		}
+		counts := batch.RequestCounts
+		if batch.ProcessingStatus == "ended" {
+			c.logger.Info("Message batch ended", "batch", id, "succeeded", counts.Succeeded, "errored", counts.Errored, "canceled", counts.Canceled, "expired", counts.Expired)
+			return batch, nil
+		}
+		c.logger.Info("Waiting for the message batch", "batch", id, "status", batch.ProcessingStatus,
+			"processing", counts.Processing, "done", counts.Succeeded+counts.Errored+counts.Canceled+counts.Expired, "next_check", pollInterval)
+		if err := c.wait(pollInterval); err != nil {
+			return nil, fmt.Errorf("stopped waiting for message batch %s, the next run picks it up: %w", id, err)
+		}
+	}
+}
+
+// storeBatchResults stores the answers of an ended message batch in the
+// queue and the cache, adding their discounted cost to the totals
+func (c *Client) storeBatchResults(queue *Queue, batch *messageBatch) (int, error) {
+	if batch.ResultsURL == "" {
+		return 0, fmt.Errorf("message batch %s has no results", batch.ID)
+	}
+	byID := make(map[string]*QueuedRequest)
+	for _, entry := range queue.Pending() {
+		byID[batchCustomID(entry.Key)] = entry
+	}
+
+	var results []byte
+	if err := c.batchCall(http.MethodGet, batch.ResultsURL, nil, &results); err != nil {
+		return 0, fmt.Errorf("failed to fetch message batch results: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(results))
+	answered, failed := 0, 0
+	firstError := ""
+	for decoder.More() {
+		var result batchResult
+		if err := decoder.Decode(&result); err != nil {
+			return answered, fmt.Errorf("failed to parse message batch results: %w", err)
+		}
+		entry, ok := byID[result.CustomID]
+		if !ok {
+			continue // Answered already
+		}
+		if result.Result.Type != "succeeded" {
+			failed++
+			if firstError == "" {
+				firstError = result.Result.Type
+				if len(result.Result.Error) > 0 {
+					firstError += ": " + c.provider.ErrorMessage(result.Result.Error)
+				}
+			}
+			continue
+		}
+
+		started := time.Now()
+		answer, err := c.provider.DecodeResponse(result.Result.Message)
+		if err != nil {
+			return answered, err
+		}
+		if err := queue.SetAnswer(entry, answer.Text); err != nil {
+			return answered, err
+		}
+		if c.cache != nil && cacheableStop(answer.StopReason) {
+			if err := c.cache.Set(entry.Key, answer.Text); err != nil {
+				c.logger.Warn("Failed to cache response", "error", err)
+			}
+		}
+		cost := CalculateCost(entry.Request.Model, answer.InputTokens, answer.OutputTokens)
+		cost.Cost *= BatchDiscount
+		c.addUsage(cost)
+		c.keys.record(0, cost)
+		c.telemetry.record(cacheKeyTask(entry.Key), started, RequestStats{Requests: 1, InputTokens: answer.InputTokens, OutputTokens: answer.OutputTokens}, nil)
+		answered++
+	}
+	if failed > 0 {
+		c.logger.Warn("Requests of the message batch were not answered, they stay pending", "count", failed, "first_error", firstError)
+	}
+	c.logger.Info("Stored the answers of the message batch", "batch", batch.ID, "answered", answered, "total_cost", fmt.Sprintf("$%.4f", c.GetTotalCost()))
+	return answered, nil
+}
+
+// batchCall calls the Message Batches API with the first API key, as a batch
+// can only be read with a key of the workspace it was created in. The answer
+// is decoded into result, or stored as is if result is a *[]byte.
+func (c *Client) batchCall(method, url string, body []byte, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(c.ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req, c.keys.keys[0].Key)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return errBatchNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s API request failed with status %d: %s", c.provider.Name(), resp.StatusCode, c.provider.ErrorMessage(data))
+	}
+	if raw, ok := result.(*[]byte); ok {
+		*raw = data
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// batchCustomID returns the ID of a queued request in a message batch, the
+// name of its queue file without extension
+func batchCustomID(key string) string {
+	return strings.TrimSuffix(queueFileName(key), ".json")
+}
+
+// batchIDFile is the file of the queue holding the ID of the message batch
+// being processed
+const batchIDFile = "batch.id"
+
+// batchID returns the ID of the message batch sent for the queue and not yet
+// stored, or an empty string
+func (q *Queue) batchID() string {
+	data, err := os.ReadFile(filepath.Join(q.dir, batchIDFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// setBatchID records the ID of the message batch sent for the queue, an
+// empty ID removes it
+func (q *Queue) setBatchID(id string) error {
+	path := filepath.Join(q.dir, batchIDFile)
+	if id == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove message batch ID: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to save message batch ID: %w", err)
+	}
+	return nil
+}
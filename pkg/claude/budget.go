@@ -0,0 +1,86 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrBudgetExceeded is returned for requests that are not sent because they
+// could take the cost of the run beyond max_cost_usd
+var ErrBudgetExceeded = errors.New("cost cap reached")
+
+// SetMaxCost caps the cost of the requests in USD, 0 disables the cap. A
+// request that could take the cost beyond the cap is not sent and fails with
+// ErrBudgetExceeded.
+func (c *Client) SetMaxCost(maxCost float64) {
+	c.maxCost = maxCost
+}
+
+// SetOnBudgetExceeded sets the function called once when the cost cap stops
+// the first request, e.g. to stop the run like on Ctrl+C
+func (c *Client) SetOnBudgetExceeded(exceeded func()) {
+	c.onBudgetExceeded = exceeded
+}
+
+// BudgetExceeded reports whether the cost cap has stopped a request
+func (c *Client) BudgetExceeded() bool {
+	c.budgetMutex.Lock()
+	defer c.budgetMutex.Unlock()
+	return c.budgetExceeded
+}
+
+// estimateRequestCost estimates the most a request can cost: the input from
+// the length of the request and as many output tokens as it allows
+func estimateRequestCost(reqBody RequestBody) float64 {
+	data, _ := json.Marshal(reqBody)
+	return CalculateCost(reqBody.Model, estimateTokens(string(data)), reqBody.MaxTokens).Cost
+}
+
+// reserveBudget sets the estimated cost of requests about to be sent aside
+// from the remaining budget, so requests sent in parallel cannot exceed it
+// together. It fails with ErrBudgetExceeded if the budget does not cover
+// them; otherwise the reservation is released with releaseBudget once their
+// cost is known.
+func (c *Client) reserveBudget(estimate float64) error {
+	if c.maxCost <= 0 {
+		return nil
+	}
+	c.budgetMutex.Lock()
+	defer c.budgetMutex.Unlock()
+	if c.totalCost+c.reservedCost+estimate <= c.maxCost {
+		c.reservedCost += estimate
+		return nil
+	}
+	if !c.budgetExceeded {
+		c.budgetExceeded = true
+		c.logger.Warn("Stopping the run, the next request could exceed the cost cap",
+			"max_cost", fmt.Sprintf("$%.2f", c.maxCost),
+			"total_cost", fmt.Sprintf("$%.4f", c.totalCost),
+			"request_estimate", fmt.Sprintf("$%.4f", estimate))
+		if c.onBudgetExceeded != nil {
+			c.onBudgetExceeded()
+		}
+	}
+	return fmt.Errorf("%w: $%.4f of $%.2f spent", ErrBudgetExceeded, c.totalCost, c.maxCost)
+}
+
+// addUsage adds the cost and tokens of an answered request to the totals
+// and returns the total cost
+func (c *Client) addUsage(cost Cost) float64 {
+	c.budgetMutex.Lock()
+	defer c.budgetMutex.Unlock()
+	c.totalCost += cost.Cost
+	c.totalTokens += cost.TotalTokens
+	return c.totalCost
+}
+
+// releaseBudget returns a reservation of reserveBudget
+func (c *Client) releaseBudget(estimate float64) {
+	if c.maxCost <= 0 {
+		return
+	}
+	c.budgetMutex.Lock()
+	defer c.budgetMutex.Unlock()
+	c.reservedCost -= estimate
+}
@@ -0,0 +1,170 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// budgetStep reserves, releases or spends an amount of the budget
+type budgetStep struct {
+	op      string // reserve, release or spend
+	amount  float64
+	wantErr bool
+}
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxCost      float64
+		steps        []budgetStep
+		wantReserved float64
+		wantExceeded bool
+		wantCalls    int
+	}{
+		{
+			name:    "no cap",
+			maxCost: 0,
+			steps:   []budgetStep{{op: "reserve", amount: 100}, {op: "spend", amount: 100}, {op: "reserve", amount: 100}},
+		},
+		{
+			name:         "reservations within the cap",
+			maxCost:      1,
+			steps:        []budgetStep{{op: "reserve", amount: 0.25}, {op: "reserve", amount: 0.75}},
+			wantReserved: 1,
+		},
+		{
+			name:         "release frees the reservation",
+			maxCost:      1,
+			steps:        []budgetStep{{op: "reserve", amount: 0.75}, {op: "release", amount: 0.75}, {op: "reserve", amount: 0.75}},
+			wantReserved: 0.75,
+		},
+		{
+			name:    "spent cost stays taken",
+			maxCost: 1,
+			steps: []budgetStep{
+				{op: "reserve", amount: 0.5}, {op: "spend", amount: 0.5}, {op: "release", amount: 0.5},
+				{op: "reserve", amount: 0.75, wantErr: true},
+			},
+			wantExceeded: true,
+			wantCalls:    1,
+		},
+		{
+			name:    "exceeded once",
+			maxCost: 1,
+			steps: []budgetStep{
+				{op: "reserve", amount: 0.75}, {op: "reserve", amount: 0.5, wantErr: true}, {op: "reserve", amount: 0.5, wantErr: true},
+				{op: "release", amount: 0.75}, {op: "reserve", amount: 0.25},
+			},
+			wantReserved: 0.25,
+			wantExceeded: true,
+			wantCalls:    1,
+		},
+	}
+	for _, test := range tests {
+		client := NewClient("test-key", logging.NewLogger(false), nil, "", "")
+		client.SetMaxCost(test.maxCost)
+		calls := 0
+		client.SetOnBudgetExceeded(func() { calls++ })
+
+		for i, step := range test.steps {
+			var err error
+			switch step.op {
+			case "reserve":
+				err = client.reserveBudget(step.amount)
+			case "release":
+				client.releaseBudget(step.amount)
+			case "spend":
+				client.addUsage(Cost{Cost: step.amount})
+			}
+			if step.wantErr != (err != nil) || (err != nil && !errors.Is(err, ErrBudgetExceeded)) {
+				t.Errorf("%s: step %d (%s %g): got error %v, want error %v", test.name, i+1, step.op, step.amount, err, step.wantErr)
+			}
+		}
+		if client.reservedCost != test.wantReserved {
+			t.Errorf("%s: got $%g reserved, want $%g", test.name, client.reservedCost, test.wantReserved)
+		}
+		if client.BudgetExceeded() != test.wantExceeded {
+			t.Errorf("%s: got exceeded %v, want %v", test.name, client.BudgetExceeded(), test.wantExceeded)
+		}
+		if calls != test.wantCalls {
+			t.Errorf("%s: got %d calls of the callback, want %d", test.name, calls, test.wantCalls)
+		}
+	}
+}
+
+func TestDrainQueueBatchBudget(t *testing.T) {
+	request := RequestBody{
+		Model:     "claude-sonnet-4-5-20250929",
+		MaxTokens: 1000,
+		Messages:  []Message{{Role: "user", Content: "Match the responses to the themes"}},
+	}
+	estimate := estimateRequestCost(request) * BatchDiscount
+
+	tests := []struct {
+		name         string
+		maxCost      float64
+		wantAnswered int
+		wantErr      bool
+		wantCalls    int
+		wantRequests int
+	}{
+		{name: "covered", maxCost: estimate * 2, wantAnswered: 1, wantRequests: 3},
+		{name: "exceeded", maxCost: estimate / 2, wantErr: true, wantCalls: 1},
+	}
+	for _, test := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Content-Type", "application/json")
+			if strings.HasSuffix(r.URL.Path, "/results") {
+				fmt.Fprintf(w, `{"custom_id":%q,"result":{"type":"succeeded","message":{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":100,"output_tokens":10}}}}`+"\n", batchCustomID("match"))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"id": "msgbatch_1", "processing_status": "ended", "results_url": MessageBatchesURL + "/msgbatch_1/results"})
+		}))
+		serverURL, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logger := logging.NewLogger(false)
+		queue, err := OpenQueue(logger, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := queue.Add("match", request); err != nil {
+			t.Fatal(err)
+		}
+		client := NewClient("test-key", logger, nil, "", "")
+		client.httpClient = &http.Client{Transport: serverTransport{server: serverURL}}
+		client.SetMaxCost(test.maxCost)
+		calls := 0
+		client.SetOnBudgetExceeded(func() { calls++ })
+
+		answered, err := client.DrainQueueBatch(queue, 1)
+		server.Close()
+		if test.wantErr != (err != nil) || (err != nil && !errors.Is(err, ErrBudgetExceeded)) {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.wantErr)
+		}
+		if answered != test.wantAnswered {
+			t.Errorf("%s: got %d answered, want %d", test.name, answered, test.wantAnswered)
+		}
+		if client.reservedCost != 0 {
+			t.Errorf("%s: got $%g still reserved", test.name, client.reservedCost)
+		}
+		if calls != test.wantCalls {
+			t.Errorf("%s: got %d calls of the callback, want %d", test.name, calls, test.wantCalls)
+		}
+		if requests != test.wantRequests {
+			t.Errorf("%s: got %d API requests, want %d", test.name, requests, test.wantRequests)
+		}
+	}
+}
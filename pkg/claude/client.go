@@ -2,22 +2,67 @@ package claude
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/oetiker/response-analyzer/pkg/cache"
+	"github.com/oetiker/response-analyzer/pkg/llm"
 	"github.com/oetiker/response-analyzer/pkg/logging"
 )
 
 // ThemeSummary represents a summary of a theme
 type ThemeSummary struct {
-	Summary     string   `json:"summary"`
-	UniqueIdeas []string `json:"unique_ideas,omitempty"`
+	Summary     string            `json:"summary"`
+	UniqueIdeas []string          `json:"unique_ideas,omitempty"`
+	Sentiment   *SentimentBalance `yaml:"sentiment,omitempty" json:"sentiment,omitempty"` // Sentiment of the responses the summary reflects
+}
+
+// SentimentBalance counts the responses of a theme by sentiment
+type SentimentBalance struct {
+	Positive int `yaml:"positive" json:"positive"`
+	Neutral  int `yaml:"neutral" json:"neutral"`
+	Negative int `yaml:"negative" json:"negative"`
+}
+
+// Total returns the number of responses with a sentiment
+func (b SentimentBalance) Total() int {
+	return b.Positive + b.Neutral + b.Negative
+}
+
+// Share returns the share of a sentiment in percent
+func (b SentimentBalance) Share(count int) float64 {
+	if b.Total() == 0 {
+		return 0
+	}
+	return float64(count) / float64(b.Total()) * 100.0
+}
+
+// Describe summarizes the balance in words, such as "mostly negative (72%)"
+func (b SentimentBalance) Describe() string {
+	name, count := "neutral", b.Neutral
+	if b.Negative > count {
+		name, count = "negative", b.Negative
+	}
+	if b.Positive > count {
+		name, count = "positive", b.Positive
+	}
+	if share := b.Share(count); share > 50 {
+		return fmt.Sprintf("mostly %s (%.0f%%)", name, share)
+	}
+	return fmt.Sprintf("mixed, %.0f%% positive, %.0f%% neutral, %.0f%% negative", b.Share(b.Positive), b.Share(b.Neutral), b.Share(b.Negative))
+}
+
+// ThemeDescription represents the description and inclusion criteria of a theme
+type ThemeDescription struct {
+	Description       string `yaml:"description" json:"description"`
+	InclusionCriteria string `yaml:"inclusion_criteria,omitempty" json:"inclusion_criteria,omitempty"`
 }
 
 const (
@@ -31,21 +76,53 @@ const (
 	DefaultMaxTokens = 4096
 	// DefaultRateLimitDelay is the default delay between API calls to avoid rate limiting
 	DefaultRateLimitDelay = 1 * time.Second
+	// DefaultTemperature is the default sampling temperature
+	DefaultTemperature = 0.7
+	// MinThinkingBudget is the smallest token budget accepted for extended thinking
+	MinThinkingBudget = 1024
+	// ThinkingTimeout is the request timeout used when extended thinking is enabled
+	ThinkingTimeout = 5 * time.Minute
+)
+
+// Stages of the analysis for which extended thinking can be enabled
+const (
+	StageThemeIdentification = "theme_identification"
+	StageMatching            = "matching"
+	StageThemeSummaries      = "theme_summaries"
+	StageGlobalSummary       = "global_summary"
 )
 
+// ThinkingStages lists the stages for which extended thinking can be enabled
+var ThinkingStages = []string{StageThemeIdentification, StageMatching, StageThemeSummaries, StageGlobalSummary}
+
 // Message represents a message in the Claude API
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
 }
 
+// RequestMetadata represents the metadata attached to a Claude API request
+type RequestMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+// ThinkingConfig enables extended thinking for a Claude API request
+type ThinkingConfig struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
 // RequestBody represents the request body for the Claude API
 type RequestBody struct {
-	Model       string    `json:"model"`
-	MaxTokens   int       `json:"max_tokens"`
-	Messages    []Message `json:"messages"`
-	Temperature float64   `json:"temperature,omitempty"`
-	System      string    `json:"system,omitempty"`
+	Model       string           `json:"model"`
+	MaxTokens   int              `json:"max_tokens"`
+	Messages    []Message        `json:"messages"`
+	Temperature *float64         `json:"temperature,omitempty"`
+	System      string           `json:"system,omitempty"`
+	Metadata    *RequestMetadata `json:"metadata,omitempty"`
+	Thinking    *ThinkingConfig  `json:"thinking,omitempty"`
+	Tools       []Tool           `json:"tools,omitempty"`
+	ToolChoice  *ToolChoice      `json:"tool_choice,omitempty"`
 }
 
 // ResponseBody represents the response body from the Claude API
@@ -65,8 +142,12 @@ type ResponseBody struct {
 
 // ContentBlock represents a block of content in the Claude API response
 type ContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Thinking string `json:"thinking,omitempty"` // Reasoning of thinking blocks
+
+	Name  string          `json:"name,omitempty"`  // Tool called by tool_use blocks
+	Input json.RawMessage `json:"input,omitempty"` // Input of the tool call
 }
 
 // Cost represents the cost of a Claude API call
@@ -79,19 +160,66 @@ type Cost struct {
 
 // Client is a client for the Claude API
 type Client struct {
-	apiKey         string
+	keys           *apiKeys     // API keys the requests are spread over
+	provider       llm.Provider // API the requests are sent to
 	model          string
 	httpClient     *http.Client
 	logger         *logging.Logger
 	cache          *cache.Cache
 	outputLanguage string
-	totalCost      float64
-	totalTokens    int
+	totalCost      float64       // Guarded by budgetMutex
+	totalTokens    int           // Guarded by budgetMutex
 	rateLimitDelay time.Duration // Delay between API calls to avoid rate limiting
+	runID          string        // Run ID sent along with every request
+	usageTag       string        // Tag sent as metadata.user_id for usage attribution
+	surveyQuestion string        // Question the responses answer, from the title of the response column
+
+	injectionGuard bool                    // Whether instruction-like text is removed from responses
+	injectionSeen  map[string]bool         // Responses whose instruction-like text was reported
+	truncations    map[string][]Truncation // Responses shortened for prompts, for the audit log
+
+	proposeNewThemes  bool                        // Whether the model may propose new themes for unmatched responses
+	matchReasons      bool                        // Whether the model justifies each theme assignment
+	strictThemes      bool                        // Whether batches are classified with a tool restricted to the theme numbers
+	structuredOutput  bool                        // Whether batches are classified and themes summarized by calling tools
+	themeDescriptions map[string]ThemeDescription // Descriptions added to the theme list in matching prompts
+
+	consensusRuns       int     // Number of times each batch is classified for majority voting
+	matchingTemperature float64 // Sampling temperature for theme matching
+
+	thinkingBudget int             // Token budget for extended thinking, 0 disables it
+	thinkingStages map[string]bool // Stages using extended thinking
+
+	limitsMutex    sync.Mutex
+	observedLimits RateLimits // Rate limits reported by the last API response, guarded by limitsMutex
+
+	queue       *Queue // Requests answered offline or to be queued
+	recordQueue bool   // Queue requests missing from the queue instead of sending them
+
+	batchPollInterval time.Duration // Interval of checking message batches, 0 unless matching uses the batch API
+
+	promptMutex    sync.Mutex
+	promptVersions map[string]string // Prompt version used per task
+
+	telemetry *telemetry // Requests and timing per task
+
+	ctx context.Context // Cancels requests and waits, e.g. on Ctrl+C
+
+	budgetMutex      sync.Mutex
+	maxCost          float64 // Cost cap of the requests in USD, 0 without a cap
+	reservedCost     float64 // Estimated cost of the requests in flight
+	budgetExceeded   bool    // Whether the cost cap has stopped a request
+	onBudgetExceeded func()  // Called when the cost cap stops the first request
+
+	savingsMutex sync.Mutex
+	cacheSavings CacheSavings // Requests answered from the cache
 }
 
 // ModelCostPerMillionTokens returns the cost per million tokens for a given model
 func ModelCostPerMillionTokens(model string) (inputCost, outputCost float64) {
+	if inputCost, outputCost, ok := llm.OpenAICostPerMillionTokens(model); ok {
+		return inputCost, outputCost
+	}
 	switch model {
 	case "claude-3-opus-20240229":
 		return 15.0, 75.0
@@ -101,6 +229,16 @@ func ModelCostPerMillionTokens(model string) (inputCost, outputCost float64) {
 		return 0.25, 1.25
 	case "claude-3-7-sonnet-20250219":
 		return 3.0, 15.0
+	case "claude-3-5-sonnet-20240620", "claude-3-5-sonnet-20241022":
+		return 3.0, 15.0
+	case "claude-3-5-haiku-20241022":
+		return 0.8, 4.0
+	case "claude-sonnet-4-20250514", "claude-sonnet-4-5-20250929":
+		return 3.0, 15.0
+	case "claude-opus-4-20250514", "claude-opus-4-1-20250805":
+		return 15.0, 75.0
+	case "claude-haiku-4-5-20251001":
+		return 1.0, 5.0
 	case "claude-2.1":
 		return 8.0, 24.0
 	case "claude-2.0":
@@ -111,7 +249,18 @@ func ModelCostPerMillionTokens(model string) (inputCost, outputCost float64) {
 	}
 }
 
-// CalculateCost calculates the cost of a Claude API call
+// SupportsThinking reports whether a model supports extended thinking
+func SupportsThinking(model string) bool {
+	for _, prefix := range []string{"claude-3-7-sonnet", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4"} {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CalculateCost calculates the cost of a Claude API call. Thinking tokens are
+// part of the output tokens reported by the API and billed as such.
 func CalculateCost(model string, inputTokens, outputTokens int) Cost {
 	inputCostPerMillion, outputCostPerMillion := ModelCostPerMillionTokens(model)
 
@@ -128,13 +277,22 @@ func CalculateCost(model string, inputTokens, outputTokens int) Cost {
 	}
 }
 
+// Model returns the model used for completions
+func (c *Client) Model() string {
+	return c.model
+}
+
 // GetTotalCost returns the total cost of all Claude API calls
 func (c *Client) GetTotalCost() float64 {
+	c.budgetMutex.Lock()
+	defer c.budgetMutex.Unlock()
 	return c.totalCost
 }
 
 // GetTotalTokens returns the total number of tokens used
 func (c *Client) GetTotalTokens() int {
+	c.budgetMutex.Lock()
+	defer c.budgetMutex.Unlock()
 	return c.totalTokens
 }
 
@@ -146,8 +304,9 @@ func NewClient(apiKey string, logger *logging.Logger, cache *cache.Cache, output
 	}
 
 	return &Client{
-		apiKey: apiKey,
-		model:  model,
+		keys:     newAPIKeys([]APIKey{{Name: "default", Key: apiKey}}, RotateRoundRobin),
+		provider: anthropic{},
+		model:    model,
 		httpClient: &http.Client{
 			Timeout: DefaultTimeout,
 		},
@@ -157,6 +316,34 @@ func NewClient(apiKey string, logger *logging.Logger, cache *cache.Cache, output
 		totalCost:      0.0,
 		totalTokens:    0,
 		rateLimitDelay: DefaultRateLimitDelay,
+
+		injectionGuard: true,
+
+		consensusRuns:       1,
+		matchingTemperature: DefaultTemperature,
+
+		telemetry: newTelemetry(),
+
+		ctx: context.Background(),
+	}
+}
+
+// SetContext sets the context of the requests. Once it is cancelled,
+// requests in flight are aborted and further requests fail with its error.
+func (c *Client) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// wait sleeps for the given duration, returning early with the error of the
+// context if it is cancelled
+func (c *Client) wait(delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	case <-timer.C:
+		return nil
 	}
 }
 
@@ -165,38 +352,194 @@ func (c *Client) SetRateLimitDelay(delay time.Duration) {
 	c.rateLimitDelay = delay
 }
 
+// SetProvider sets the API the requests are sent to; the default is the
+// Claude API of Anthropic
+func (c *Client) SetProvider(provider llm.Provider) {
+	c.provider = provider
+}
+
+// ProviderName returns the name of the API the requests are sent to
+func (c *Client) ProviderName() string {
+	return c.provider.Name()
+}
+
+// SetRunID sets the run ID that is attached to every API request
+func (c *Client) SetRunID(runID string) {
+	c.runID = runID
+}
+
+// SetUsageTag sets the tag sent as metadata.user_id so usage can be
+// attributed per survey or project in the Anthropic console
+func (c *Client) SetUsageTag(tag string) {
+	c.usageTag = tag
+}
+
+// SetOutputLanguage sets the language of the answers, e.g. to summarize a
+// classification in an additional language
+func (c *Client) SetOutputLanguage(language string) {
+	c.outputLanguage = language
+}
+
+// OutputLanguage returns the language of the answers
+func (c *Client) OutputLanguage() string {
+	return c.outputLanguage
+}
+
+// SetSurveyQuestion sets the question the responses answer, usually the
+// title of the response column, so the prompts can give it as context
+func (c *Client) SetSurveyQuestion(question string) {
+	c.surveyQuestion = strings.Join(strings.Fields(question), " ")
+}
+
+// SetProposeNewThemes sets whether the model may propose a new theme for
+// responses that match none of the given themes
+func (c *Client) SetProposeNewThemes(propose bool) {
+	c.proposeNewThemes = propose
+}
+
+// SetMatchReasons sets whether the model adds a short justification to the
+// themes it assigns to each response
+func (c *Client) SetMatchReasons(reasons bool) {
+	c.matchReasons = reasons
+}
+
+// SetThemeDescriptions sets the theme descriptions used in matching prompts
+func (c *Client) SetThemeDescriptions(descriptions map[string]ThemeDescription) {
+	c.themeDescriptions = descriptions
+}
+
+// SetConsensusRuns sets how often each batch is classified; only theme
+// assignments made by a majority of the runs are kept
+func (c *Client) SetConsensusRuns(runs int) {
+	if runs > 0 {
+		c.consensusRuns = runs
+	}
+}
+
+// SetMatchingTemperature sets the sampling temperature used for theme matching
+func (c *Client) SetMatchingTemperature(temperature float64) {
+	c.matchingTemperature = temperature
+}
+
 // SetModel sets the model to use for API requests
 func (c *Client) SetModel(model string) {
 	c.model = model
 }
 
+// SetThinking enables extended thinking with the given token budget for the
+// given stages. A budget of 0 disables it.
+func (c *Client) SetThinking(budget int, stages []string) {
+	c.thinkingBudget = budget
+	c.thinkingStages = make(map[string]bool)
+	for _, stage := range stages {
+		c.thinkingStages[stage] = true
+	}
+	if budget > 0 && c.httpClient.Timeout < ThinkingTimeout {
+		c.httpClient.Timeout = ThinkingTimeout
+	}
+}
+
+// stageThinking returns the thinking budget for a stage, 0 if thinking is
+// disabled for it
+func (c *Client) stageThinking(stage string) int {
+	if c.thinkingStages[stage] {
+		return c.thinkingBudget
+	}
+	return 0
+}
+
+// completeStage gets a completion for a stage of the analysis, using
+// extended thinking if it is enabled for the stage
+func (c *Client) completeStage(stage string, prompt string, systemPrompt string, maxTokens int) (string, error) {
+	return c.complete(stage, c.model, prompt, systemPrompt, maxTokens, DefaultTemperature, "", c.stageThinking(stage), nil, nil)
+}
+
+// completeVariant gets a completion for one of several variants of the
+// answer of a stage, each cached separately. Variant 0 is the completion of
+// completeStage.
+func (c *Client) completeVariant(stage string, prompt string, systemPrompt string, maxTokens int, variant int) (string, error) {
+	cacheVariant := ""
+	if variant > 0 {
+		cacheVariant = fmt.Sprintf("variant-%d", variant)
+	}
+	return c.complete(stage, c.model, prompt, systemPrompt, maxTokens, DefaultTemperature, cacheVariant, c.stageThinking(stage), nil, nil)
+}
+
+// completeTask gets a completion for a task without extended thinking
+func (c *Client) completeTask(task string, model string, prompt string, systemPrompt string, maxTokens int) (string, error) {
+	return c.complete(task, model, prompt, systemPrompt, maxTokens, DefaultTemperature, "", 0, nil, nil)
+}
+
 // GetCompletion gets a completion from the Claude API
 func (c *Client) GetCompletion(prompt string, systemPrompt string, maxTokens int) (string, error) {
+	return c.GetCompletionWithModel(c.model, prompt, systemPrompt, maxTokens)
+}
+
+// GetCompletionWithModel gets a completion from the Claude API using the given
+// model instead of the client's default model
+func (c *Client) GetCompletionWithModel(model string, prompt string, systemPrompt string, maxTokens int) (string, error) {
+	return c.completeTask(TaskCompletion, model, prompt, systemPrompt, maxTokens)
+}
+
+// complete sends a completion request for a task. The cache is partitioned by
+// task and prompt version, and by the provider and the URL the request is
+// sent to, so servers with the same model names do not share answers.
+// Requests with a non-default temperature or a cache variant are cached
+// separately, so repeated runs of the same prompt (e.g. for consensus voting)
+// get their own answers. A thinking budget above 0 enables extended
+// thinking; the budget is added to maxTokens, which then only limits the
+// answer. With a tool, the model is made to call it and the answer is the
+// JSON input of the call. The requests are added to stats, if it is not nil.
+func (c *Client) complete(task string, model string, prompt string, systemPrompt string, maxTokens int, temperature float64, cacheVariant string, thinkingBudget int, tool *Tool, stats *RequestStats) (string, error) {
+	started := time.Now()
+
 	// Check cache first
-	cacheKey := fmt.Sprintf("%s:%s:%d:%s", c.model, systemPrompt, maxTokens, prompt)
+	version := c.usePrompt(task, systemPrompt)
+	cacheKey := fmt.Sprintf("%s@%s:%s:%s:%s:%s:%d:%s", task, version, c.provider.Name(), c.provider.CompletionURL(), model, systemPrompt, maxTokens, prompt)
+	if temperature != DefaultTemperature || cacheVariant != "" {
+		cacheKey += fmt.Sprintf(":%g:%s", temperature, cacheVariant)
+	}
+	if thinkingBudget > 0 {
+		cacheKey += fmt.Sprintf(":thinking=%d", thinkingBudget)
+	}
+	if tool != nil {
+		cacheKey += ":tool=" + tool.Name
+	}
 	if c.cache != nil {
 		if cachedResponse, found := c.cache.Get(cacheKey); found {
 			c.logger.Info("Using cached response")
+			c.telemetry.record(task, started, RequestStats{CacheHits: 1}, stats)
+			c.recordCacheHit(model, prompt, systemPrompt, cachedResponse)
 			return cachedResponse, nil
 		}
 	}
 
+	// Use the answer drained from the request queue, or queue the request
+	if c.queue != nil {
+		if answer, found := c.queue.Answer(cacheKey); found {
+			c.logger.Debug("Using queued answer")
+			c.telemetry.record(task, started, RequestStats{CacheHits: 1}, stats)
+			return answer, nil
+		}
+		if c.recordQueue {
+			return "", c.queue.Add(cacheKey, c.requestBody(model, prompt, systemPrompt, maxTokens, temperature, thinkingBudget, tool))
+		}
+	}
+
 	// Log the request details
 	c.logger.Info("Sending request to Claude API",
-		"model", c.model,
+		"model", model,
 		"prompt_length", len(prompt),
 		"system_prompt_length", len(systemPrompt),
 		"max_tokens", maxTokens)
 
-	// Apply rate limiting delay if set
-	if c.rateLimitDelay > 0 {
-		c.logger.Debug("Applying rate limit delay", "delay", c.rateLimitDelay)
-		time.Sleep(c.rateLimitDelay)
-	}
+	return c.send(task, cacheKey, c.requestBody(model, prompt, systemPrompt, maxTokens, temperature, thinkingBudget, tool), stats)
+}
 
-	// Create request body
+// requestBody creates the body of a completion request
+func (c *Client) requestBody(model string, prompt string, systemPrompt string, maxTokens int, temperature float64, thinkingBudget int, tool *Tool) RequestBody {
 	reqBody := RequestBody{
-		Model:     c.model,
+		Model:     model,
 		MaxTokens: maxTokens,
 		Messages: []Message{
 			{
@@ -204,7 +547,7 @@ func (c *Client) GetCompletion(prompt string, systemPrompt string, maxTokens int
 				Content: prompt,
 			},
 		},
-		Temperature: 0.7,
+		Temperature: &temperature,
 	}
 
 	// Add system prompt if provided
@@ -212,22 +555,76 @@ func (c *Client) GetCompletion(prompt string, systemPrompt string, maxTokens int
 		reqBody.System = systemPrompt
 	}
 
-	// Marshal request body
-	reqData, err := json.Marshal(reqBody)
+	// Enable extended thinking, which does not allow setting the temperature
+	if thinkingBudget > 0 {
+		reqBody.Thinking = &ThinkingConfig{Type: "enabled", BudgetTokens: thinkingBudget}
+		reqBody.MaxTokens = maxTokens + thinkingBudget
+		reqBody.Temperature = nil
+	}
+
+	// Make the model answer by calling the tool
+	if tool != nil {
+		reqBody.Tools = []Tool{*tool}
+		reqBody.ToolChoice = &ToolChoice{Type: "tool", Name: tool.Name}
+	}
+
+	// Add usage metadata if provided
+	if c.usageTag != "" {
+		reqBody.Metadata = &RequestMetadata{UserID: c.usageTag}
+	}
+	return reqBody
+}
+
+// cacheableStop reports whether an answer that stopped for the reason is
+// complete enough to be cached
+func cacheableStop(reason string) bool {
+	return reason != llm.StopRefusal && reason != llm.StopMaxTokens
+}
+
+// send sends a completion request through the provider, retrying on rate
+// limit errors, and caches the answer under the given key. The time spent is
+// recorded for the task and added to stats, if it is not nil.
+func (c *Client) send(task string, cacheKey string, reqBody RequestBody, stats *RequestStats) (string, error) {
+	started := time.Now()
+	sample := RequestStats{Requests: 1}
+	defer func() { c.telemetry.record(task, started, sample, stats) }()
+
+	// Do not start requests once the run is cancelled
+	if err := c.ctx.Err(); err != nil {
+		return "", err
+	}
+
+	// Do not send requests the cost cap does not cover
+	estimate := estimateRequestCost(reqBody)
+	if err := c.reserveBudget(estimate); err != nil {
+		return "", err
+	}
+	defer c.releaseBudget(estimate)
+
+	// Apply rate limiting delay if set
+	if c.rateLimitDelay > 0 {
+		c.logger.Debug("Applying rate limit delay", "delay", c.rateLimitDelay)
+		if err := c.wait(c.rateLimitDelay); err != nil {
+			return "", err
+		}
+		sample.Wait += c.rateLimitDelay
+	}
+
+	// Encode the request for the provider
+	reqData, err := c.provider.EncodeRequest(neutralRequest(reqBody))
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
 	// Create request
-	req, err := http.NewRequest("POST", ClaudeAPIURL, bytes.NewBuffer(reqData))
+	req, err := http.NewRequestWithContext(c.ctx, "POST", c.provider.CompletionURL(), bytes.NewBuffer(reqData))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", c.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	keyIndex := c.keys.next()
+	c.setHeaders(req, c.keys.keys[keyIndex].Key)
 
 	// Maximum number of retries for rate limit errors
 	maxRetries := 3
@@ -235,121 +632,130 @@ func (c *Client) GetCompletion(prompt string, systemPrompt string, maxTokens int
 
 	// Retry loop with exponential backoff
 	for retry := 0; retry <= maxRetries; retry++ {
+		sample.Retries = retry
+
 		// Send request
+		requestStarted := time.Now()
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
+			sample.Latency += time.Since(requestStarted)
 			return "", fmt.Errorf("failed to send request: %w", err)
 		}
 
 		// Read response body
 		respData, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		sample.Latency += time.Since(requestStarted)
 
 		if err != nil {
 			return "", fmt.Errorf("failed to read response body: %w", err)
 		}
+		if limits, ok := parseRateLimits(resp.Header); ok {
+			c.limitsMutex.Lock()
+			c.observedLimits = limits
+			c.limitsMutex.Unlock()
+		}
 
 		// Check response status
 		if resp.StatusCode == http.StatusOK {
 			// Success, process the response
-			var respBody ResponseBody
-			if err := json.Unmarshal(respData, &respBody); err != nil {
-				return "", fmt.Errorf("failed to unmarshal response body: %w", err)
+			parseStarted := time.Now()
+			answer, err := c.provider.DecodeResponse(respData)
+			if err != nil {
+				return "", err
 			}
-
-			// Extract text from response
-			var responseText string
-			for _, block := range respBody.Content {
-				if block.Type == "text" {
-					responseText += block.Text
-				}
+			responseText := answer.Text
+			sample.Parse += time.Since(parseStarted)
+			sample.InputTokens = answer.InputTokens
+			sample.OutputTokens = answer.OutputTokens
+			switch answer.StopReason {
+			case llm.StopRefusal:
+				c.logger.Warn("The model refused to answer the request", "model", reqBody.Model)
+			case llm.StopMaxTokens:
+				c.logger.Warn("Answer was cut off at the token limit", "model", reqBody.Model, "max_tokens", reqBody.MaxTokens)
 			}
 
-			// Cache response
-			if c.cache != nil {
+			// Cache response, unless it is a refusal or cut off, which the
+			// next run should ask for again
+			if c.cache != nil && cacheableStop(answer.StopReason) {
 				if err := c.cache.Set(cacheKey, responseText); err != nil {
 					c.logger.Warn("Failed to cache response", "error", err)
 				}
 			}
 
 			// Calculate cost
-			cost := CalculateCost(c.model, respBody.Usage.InputTokens, respBody.Usage.OutputTokens)
+			cost := CalculateCost(reqBody.Model, answer.InputTokens, answer.OutputTokens)
 
 			// Update total cost and tokens
-			c.totalCost += cost.Cost
-			c.totalTokens += cost.TotalTokens
+			totalCost := c.addUsage(cost)
+			c.keys.record(keyIndex, cost)
 
 			// Log response details with cost information
-			c.logger.Info("Received response from Claude API",
-				"input_tokens", respBody.Usage.InputTokens,
-				"output_tokens", respBody.Usage.OutputTokens,
+			c.logger.Info("Received response from the API",
+				"provider", c.provider.Name(),
+				"input_tokens", answer.InputTokens,
+				"output_tokens", answer.OutputTokens,
 				"total_tokens", cost.TotalTokens,
 				"cost", fmt.Sprintf("$%.4f", cost.Cost),
-				"total_cost", fmt.Sprintf("$%.4f", c.totalCost),
-				"response_length", len(responseText))
+				"total_cost", fmt.Sprintf("$%.4f", totalCost),
+				"response_length", len(responseText),
+				"thinking_length", answer.ThinkingLength)
 
 			return responseText, nil
 		} else if resp.StatusCode == http.StatusTooManyRequests && retry < maxRetries {
 			// Rate limit error, extract message and retry with backoff
-			var errorMsg string
-			var errorResp map[string]interface{}
-
-			if err := json.Unmarshal(respData, &errorResp); err == nil {
-				if errObj, ok := errorResp["error"].(map[string]interface{}); ok {
-					if msg, ok := errObj["message"].(string); ok {
-						errorMsg = msg
-					}
+			errorMsg := c.provider.ErrorMessage(respData)
+
+			// With several keys, retry right away with another one,
+			// otherwise back off with exponentially increasing delays
+			if len(c.keys.keys) > 1 {
+				c.logger.Warn("Rate limit exceeded, retrying with the next API key",
+					"retry", retry+1,
+					"max_retries", maxRetries,
+					"key", c.keys.keys[keyIndex].Name,
+					"error", errorMsg)
+				keyIndex = c.keys.rateLimited(keyIndex)
+			} else {
+				delay := baseDelay * time.Duration(1<<retry)
+				c.logger.Warn("Rate limit exceeded, retrying after backoff",
+					"retry", retry+1,
+					"max_retries", maxRetries,
+					"delay", delay,
+					"error", errorMsg)
+
+				// Wait before retrying
+				if err := c.wait(delay); err != nil {
+					return "", err
 				}
+				sample.Wait += delay
 			}
 
-			if errorMsg == "" {
-				errorMsg = string(respData)
-			}
-
-			// Calculate backoff delay with exponential increase
-			delay := baseDelay * time.Duration(1<<retry)
-			c.logger.Warn("Rate limit exceeded, retrying after backoff",
-				"retry", retry+1,
-				"max_retries", maxRetries,
-				"delay", delay,
-				"error", errorMsg)
-
-			// Wait before retrying
-			time.Sleep(delay)
-
 			// Create a new request for the retry
-			req, err = http.NewRequest("POST", ClaudeAPIURL, bytes.NewBuffer(reqData))
+			req, err = http.NewRequestWithContext(c.ctx, "POST", c.provider.CompletionURL(), bytes.NewBuffer(reqData))
 			if err != nil {
 				return "", fmt.Errorf("failed to create retry request: %w", err)
 			}
 
 			// Set headers again
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("x-api-key", c.apiKey)
-			req.Header.Set("anthropic-version", "2023-06-01")
+			c.setHeaders(req, c.keys.keys[keyIndex].Key)
 		} else {
 			// Other error, extract message and return
-			var errorMsg string
-			var errorResp map[string]interface{}
-
-			if err := json.Unmarshal(respData, &errorResp); err == nil {
-				if errObj, ok := errorResp["error"].(map[string]interface{}); ok {
-					if msg, ok := errObj["message"].(string); ok {
-						errorMsg = msg
-					}
-				}
-			}
-
-			if errorMsg == "" {
-				errorMsg = string(respData)
-			}
-
-			return "", fmt.Errorf("Claude API request failed with status %d: %s", resp.StatusCode, errorMsg)
+			return "", fmt.Errorf("%s API request failed with status %d: %s", c.provider.Name(), resp.StatusCode, c.provider.ErrorMessage(respData))
 		}
 	}
 
 	// If we get here, we've exhausted all retries
-	return "", fmt.Errorf("Claude API request failed after %d retries: rate limit exceeded", maxRetries)
+	return "", fmt.Errorf("%s API request failed after %d retries: rate limit exceeded", c.provider.Name(), maxRetries)
+}
+
+// setHeaders sets the headers required for an API request with the given
+// API key
+func (c *Client) setHeaders(req *http.Request, apiKey string) {
+	req.Header.Set("Content-Type", "application/json")
+	c.provider.Authorize(req, apiKey)
+	if c.runID != "" {
+		req.Header.Set("X-Run-Id", c.runID)
+	}
 }
 
 // getLanguageInstructions returns language-specific instructions based on the output language
@@ -375,14 +781,75 @@ func (c *Client) IdentifyThemes(responses []string, contextPrompt string) ([]str
 	maxResponsesToInclude := 50
 	responseCount := len(responses)
 	samplesToUse := min(responseCount, maxResponsesToInclude)
+	combinedResponses := c.numberedSample(TaskThemeIdentification, responses, maxResponsesToInclude)
 
-	// If we have more responses than our limit, select a representative sample
-	// Use a deterministic sampling approach
+	// Get language instructions
+	langInstructions := c.getLanguageInstructions()
+
+	// Create a more concise prompt with stable format
+	prompt := fmt.Sprintf("Identify main themes in these %d survey responses (sample of %d total):\n\n%s\n\nReturn themes as a YAML list with each theme on a new line starting with a dash. %s",
+		samplesToUse, responseCount, combinedResponses, c.dataNotice())
+
+	// Add language instructions if needed
+	if langInstructions != "" {
+		prompt += " " + langInstructions
+	}
+
+	// Get completion
+	completion, err := c.completeStage(StageThemeIdentification, prompt, contextPrompt, DefaultMaxTokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to identify themes: %w", err)
+	}
+
+	// Extract themes from completion
+	themes := extractThemesFromYAML(completion)
+
+	// Ensure we don't return nil
+	if themes == nil {
+		themes = []string{}
+	}
+
+	c.logger.Info("Identified themes", "count", len(themes))
+	return themes, nil
+}
+
+// IdentifySubThemes identifies sub-themes within the responses of a single theme
+func (c *Client) IdentifySubThemes(theme string, responses []string, contextPrompt string) ([]string, error) {
+	maxResponsesToInclude := 50
+	responseCount := len(responses)
+	samplesToUse := min(responseCount, maxResponsesToInclude)
+	combinedResponses := c.numberedSample(TaskThemeSplit, responses, maxResponsesToInclude)
+
+	// Get language instructions
+	langInstructions := c.getLanguageInstructions()
+
+	prompt := fmt.Sprintf("All of these %d survey responses (sample of %d total) were assigned to the theme \"%s\":\n\n%s\n\nThe theme is too broad. Identify 2 to 6 distinct sub-themes that together cover these responses. Return the sub-themes as a YAML list with each sub-theme on a new line starting with a dash. %s",
+		samplesToUse, responseCount, theme, combinedResponses, c.dataNotice())
+
+	// Add language instructions if needed
+	if langInstructions != "" {
+		prompt += " " + langInstructions
+	}
+
+	// Get completion
+	completion, err := c.completeTask(TaskThemeSplit, c.model, prompt, contextPrompt, DefaultMaxTokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to identify sub-themes: %w", err)
+	}
+
+	subThemes := extractThemesFromYAML(completion)
+	c.logger.Info("Identified sub-themes", "theme", theme, "count", len(subThemes))
+	return subThemes, nil
+}
+
+// numberedSample builds a numbered list of at most maxResponses responses for
+// the prompt of a task. Larger sets are sampled deterministically by taking
+// evenly distributed responses.
+func (c *Client) numberedSample(task string, responses []string, maxResponses int) string {
 	var selectedResponses []string
-	if responseCount > maxResponsesToInclude {
-		// Deterministic sampling - take evenly distributed responses
-		step := responseCount / maxResponsesToInclude
-		for i := 0; i < responseCount && len(selectedResponses) < maxResponsesToInclude; i += step {
+	if len(responses) > maxResponses {
+		step := len(responses) / maxResponses
+		for i := 0; i < len(responses) && len(selectedResponses) < maxResponses; i += step {
 			selectedResponses = append(selectedResponses, responses[i])
 		}
 	} else {
@@ -393,62 +860,112 @@ func (c *Client) IdentifyThemes(responses []string, contextPrompt string) ([]str
 	combinedResponses := ""
 	for i, response := range selectedResponses {
 		// Truncate very long responses to save tokens
-		truncatedResponse := response
-		if len(response) > 500 {
-			truncatedResponse = response[:497] + "..."
-		}
-		combinedResponses += fmt.Sprintf("%d: %s\n", i+1, truncatedResponse)
+		truncatedResponse := c.truncateResponse(task, response, 500)
+		combinedResponses += fmt.Sprintf("%d: %s\n", i+1, c.quoteResponse(truncatedResponse))
+	}
+	return combinedResponses
+}
+
+// GenerateThemeDescriptions generates a description and inclusion criteria for each theme
+func (c *Client) GenerateThemeDescriptions(themes []string, responses []string, contextPrompt string) (map[string]ThemeDescription, error) {
+	themesText := ""
+	for i, theme := range themes {
+		themesText += fmt.Sprintf("%d. %s\n", i+1, theme)
 	}
 
 	// Get language instructions
 	langInstructions := c.getLanguageInstructions()
 
-	// Create a more concise prompt with stable format
-	prompt := fmt.Sprintf("Identify main themes in these %d survey responses (sample of %d total):\n\n%s\n\nReturn themes as a YAML list with each theme on a new line starting with a dash.",
-		samplesToUse, responseCount, combinedResponses)
+	prompt := "These themes were identified in a set of survey responses:\n\n" + themesText
+	prompt += "\nSample of the responses:\n\n" + c.numberedSample(TaskThemeDescriptions, responses, 30)
+	prompt += "\nFor each theme, write a one-paragraph description and the inclusion criteria a response must meet to belong to the theme. Format your answer as:\n"
+	prompt += "THEME 1:\nDESCRIPTION: [description]\nINCLUDE: [inclusion criteria]\n\nTHEME 2:\n...\n\nDo not include any # symbols in your response."
+	prompt += "\n" + c.dataNotice()
 
 	// Add language instructions if needed
 	if langInstructions != "" {
-		prompt += " " + langInstructions
+		prompt += "\n" + langInstructions
 	}
 
 	// Get completion
-	completion, err := c.GetCompletion(prompt, contextPrompt, DefaultMaxTokens)
+	completion, err := c.completeTask(TaskThemeDescriptions, c.model, prompt, contextPrompt, DefaultMaxTokens)
 	if err != nil {
-		return nil, fmt.Errorf("failed to identify themes: %w", err)
+		return nil, fmt.Errorf("failed to generate theme descriptions: %w", err)
 	}
 
-	// Extract themes from completion
-	themes := extractThemesFromYAML(completion)
+	parseStarted := time.Now()
+	descriptions := parseThemeDescriptions(completion, themes)
+	c.recordParse(TaskThemeDescriptions, parseStarted, nil)
+	c.logger.Info("Generated theme descriptions", "count", len(descriptions))
+	return descriptions, nil
+}
 
-	// Ensure we don't return nil
-	if themes == nil {
-		themes = []string{}
+// parseThemeDescriptions parses the descriptions returned by GenerateThemeDescriptions
+func parseThemeDescriptions(completion string, themes []string) map[string]ThemeDescription {
+	descriptions := make(map[string]ThemeDescription)
+	current := -1
+	for _, line := range strings.Split(completion, "\n") {
+		line = strings.TrimSpace(line)
+		var num int
+		if _, err := fmt.Sscanf(line, "THEME %d:", &num); err == nil {
+			current = -1
+			if num > 0 && num <= len(themes) {
+				current = num - 1
+			}
+			continue
+		}
+		if current < 0 {
+			continue
+		}
+		theme := themes[current]
+		description := descriptions[theme]
+		switch {
+		case strings.HasPrefix(line, "DESCRIPTION:"):
+			description.Description = strings.TrimSpace(strings.TrimPrefix(line, "DESCRIPTION:"))
+		case strings.HasPrefix(line, "INCLUDE:"):
+			description.InclusionCriteria = strings.TrimSpace(strings.TrimPrefix(line, "INCLUDE:"))
+		default:
+			continue
+		}
+		descriptions[theme] = description
 	}
-
-	c.logger.Info("Identified themes", "count", len(themes))
-	return themes, nil
+	return descriptions
 }
 
-// MatchResponsesToThemes matches responses to themes
-func (c *Client) MatchResponsesToThemes(response string, themes []string, contextPrompt string) ([]string, error) {
-	// Create prompt with consistent theme ordering
+// formatThemeList builds the numbered theme list used in matching prompts,
+// including the theme descriptions where available
+func (c *Client) formatThemeList(themes []string) string {
 	themesText := ""
 	for i, theme := range themes {
 		themesText += fmt.Sprintf("%d. %s\n", i+1, theme)
+		if description, ok := c.themeDescriptions[theme]; ok {
+			if description.Description != "" {
+				themesText += "   Description: " + description.Description + "\n"
+			}
+			if description.InclusionCriteria != "" {
+				themesText += "   Include if: " + description.InclusionCriteria + "\n"
+			}
+		}
 	}
+	return themesText
+}
+
+// MatchResponsesToThemes matches responses to themes
+func (c *Client) MatchResponsesToThemes(response string, themes []string, contextPrompt string) ([]string, error) {
+	// Create prompt with consistent theme ordering
+	themesText := c.formatThemeList(themes)
 
 	// Get language instructions
 	langInstructions := c.getLanguageInstructions()
 
 	// Truncate very long responses to save tokens and ensure consistency
-	truncatedResponse := response
-	if len(response) > 500 {
-		truncatedResponse = response[:497] + "..."
+	truncatedResponse := c.truncateResponse(TaskMatching, response, 500)
+	if truncatedResponse != response {
+		c.logger.Warn("Truncated long response for matching", "length", len(response), "kept", len(truncatedResponse))
 	}
 
 	// Create a stable prompt format
-	prompt := fmt.Sprintf("Here is a survey response:\n\n%s\n\nHere are the themes:\n%s\n\nWhich themes does this response relate to? Return the theme numbers as a YAML list with each number on a new line starting with a dash.", truncatedResponse, themesText)
+	prompt := fmt.Sprintf("Here is a survey response:\n\n%s\n\nHere are the themes:\n%s\n\nWhich themes does this response relate to? Return the theme numbers as a YAML list with each number on a new line starting with a dash. %s", c.quoteResponse(truncatedResponse), themesText, c.dataNotice())
 
 	// Add language instructions if needed
 	if langInstructions != "" {
@@ -456,7 +973,7 @@ func (c *Client) MatchResponsesToThemes(response string, themes []string, contex
 	}
 
 	// Get completion
-	completion, err := c.GetCompletion(prompt, contextPrompt, DefaultMaxTokens)
+	completion, err := c.completeStage(StageMatching, prompt, contextPrompt, DefaultMaxTokens)
 	if err != nil {
 		return nil, fmt.Errorf("failed to match response to themes: %w", err)
 	}
@@ -481,15 +998,29 @@ func (c *Client) MatchResponsesToThemes(response string, themes []string, contex
 	return matchedThemes, nil
 }
 
+// MatchResult represents the themes matched to a single response in a batch
+type MatchResult struct {
+	Themes        []string // Names of the matched themes (empty if none applies)
+	ProposedTheme string   // Name of a new theme proposed for a response matching none of the themes
+	Reason        string   // Justification of the assignment, if asked for
+	Agreement     float64  // Share of consensus runs agreeing with the result (1 without consensus voting)
+}
+
 // MatchResponsesToThemesBatch matches multiple responses to themes in a single API call
-func (c *Client) MatchResponsesToThemesBatch(responses []string, themes []string, contextPrompt string, batchSize int) ([][]string, error) {
+func (c *Client) MatchResponsesToThemesBatch(responses []string, themes []string, contextPrompt string, batchSize int) ([]MatchResult, error) {
+	return c.MatchResponsesToThemesBatchWithStats(responses, themes, contextPrompt, batchSize, nil)
+}
+
+// MatchResponsesToThemesBatchWithStats matches multiple responses to themes
+// like MatchResponsesToThemesBatch, adding its requests to stats
+func (c *Client) MatchResponsesToThemesBatchWithStats(responses []string, themes []string, contextPrompt string, batchSize int, stats *RequestStats) ([]MatchResult, error) {
 	// Default batch size if not specified
 	if batchSize <= 0 {
 		batchSize = 10
 	}
 
 	// Process responses in batches
-	var allResults [][]string
+	var allResults []MatchResult
 
 	for i := 0; i < len(responses); i += batchSize {
 		end := i + batchSize
@@ -498,7 +1029,7 @@ func (c *Client) MatchResponsesToThemesBatch(responses []string, themes []string
 		}
 
 		batch := responses[i:end]
-		batchResults, err := c.processBatch(batch, themes, contextPrompt)
+		batchResults, err := c.processBatch(batch, themes, contextPrompt, stats)
 		if err != nil {
 			return nil, fmt.Errorf("failed to process batch %d-%d: %w", i, end, err)
 		}
@@ -510,27 +1041,48 @@ func (c *Client) MatchResponsesToThemesBatch(responses []string, themes []string
 }
 
 // processBatch processes a batch of responses in a single API call
-func (c *Client) processBatch(responses []string, themes []string, contextPrompt string) ([][]string, error) {
+func (c *Client) processBatch(responses []string, themes []string, contextPrompt string, stats *RequestStats) ([]MatchResult, error) {
 	// Create theme list once - sort by index to ensure consistent order
-	themesText := ""
-	for i, theme := range themes {
-		themesText += fmt.Sprintf("%d. %s\n", i+1, theme)
-	}
+	themesText := c.formatThemeList(themes)
 
 	// Build the prompt with all responses in the batch - use a stable format
 	prompt := "Analyze multiple survey responses and match each to relevant themes.\n\n"
 	prompt += "Themes:\n" + themesText + "\n"
-	prompt += "For each response, identify which themes apply. Format your answer as:\n"
-	prompt += "RESPONSE 1: [comma-separated theme numbers]\nRESPONSE 2: [comma-separated theme numbers]\n...\n\n"
+	if c.classifiesWithTool() {
+		prompt += "For each response, identify which themes apply. Record your answer by calling the " + classificationToolName + " tool once with a classification for every response, using only the theme numbers listed above.\n"
+		prompt += "Only assign a theme if the response actually relates to it. If none of the themes applies, give an empty list of themes.\n"
+		if c.proposeNewThemes {
+			prompt += "For a response without themes, you may give a short name for a new theme that would fit it as new_theme.\n"
+		}
+		if c.matchReasons {
+			prompt += "Give a one-sentence justification of each classification as why, referring to what the response says.\n"
+		}
+	} else {
+		prompt += "For each response, identify which themes apply. Format your answer as:\n"
+		prompt += "RESPONSE 1: [comma-separated theme numbers]\nRESPONSE 2: [comma-separated theme numbers]\n...\n\n"
+		prompt += "Only assign a theme if the response actually relates to it. If none of the themes applies, answer NONE (e.g. \"RESPONSE 3: NONE\").\n"
+		if c.proposeNewThemes {
+			prompt += "If you answer NONE, you may add a short name for a new theme that would fit the response (e.g. \"RESPONSE 3: NONE NEW: Parking Situation\").\n"
+		}
+		if c.matchReasons {
+			prompt += "End each line with a one-sentence justification of your answer, referring to what the response says (e.g. \"RESPONSE 1: 2, 4 WHY: complains about slow support and the price\").\n"
+		}
+	}
+	prompt += "\n"
 
 	// Add all responses in a stable order
+	truncated := 0
 	for i, response := range responses {
 		// Truncate very long responses to save tokens
-		truncatedResponse := response
-		if len(response) > 300 {
-			truncatedResponse = response[:297] + "..."
+		truncatedResponse := c.truncateResponse(TaskMatching, response, 300)
+		if truncatedResponse != response {
+			truncated++
 		}
-		prompt += fmt.Sprintf("RESPONSE %d: %s\n\n", i+1, truncatedResponse)
+		prompt += fmt.Sprintf("RESPONSE %d: %s\n\n", i+1, c.quoteResponse(truncatedResponse))
+	}
+	prompt += c.dataNotice() + "\n"
+	if truncated > 0 {
+		c.logger.Warn("Truncated long responses for batch matching", "count", truncated, "batch_size", len(responses), "kept", 297)
 	}
 
 	// Get language instructions
@@ -539,23 +1091,126 @@ func (c *Client) processBatch(responses []string, themes []string, contextPrompt
 		prompt += langInstructions + "\n"
 	}
 
-	// Get completion
-	completion, err := c.GetCompletion(prompt, contextPrompt, DefaultMaxTokens)
-	if err != nil {
-		return nil, fmt.Errorf("failed to match responses to themes in batch: %w", err)
+	// Classify the batch once per consensus run
+	runs := make([][]MatchResult, 0, c.consensusRuns)
+	for run := 0; run < c.consensusRuns; run++ {
+		cacheVariant := ""
+		if run > 0 {
+			cacheVariant = fmt.Sprintf("consensus-%d", run)
+		}
+		if c.classifiesWithTool() {
+			results, err := c.classifyStrict(prompt, contextPrompt, cacheVariant, len(responses), themes, stats)
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, results)
+			continue
+		}
+		completion, err := c.complete(TaskMatching, c.model, prompt, contextPrompt, DefaultMaxTokens, c.matchingTemperature, cacheVariant, c.stageThinking(StageMatching), nil, stats)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match responses to themes in batch: %w", err)
+		}
+		if c.recordQueue {
+			// The request was queued, there is no answer to parse yet
+			runs = append(runs, make([]MatchResult, len(responses)))
+			continue
+		}
+		parseStarted := time.Now()
+		runs = append(runs, c.parseBatchResults(completion, len(responses), themes))
+		c.recordParse(TaskMatching, parseStarted, stats)
+	}
+
+	if len(runs) == 1 {
+		return runs[0], nil
+	}
+	return majorityVote(runs, themes), nil
+}
+
+// majorityVote combines the results of several classification runs, keeping
+// only the themes assigned by more than half of the runs. The agreement of a
+// response is the share of runs whose assignment equals the consensus.
+func majorityVote(runs [][]MatchResult, themes []string) []MatchResult {
+	results := make([]MatchResult, len(runs[0]))
+	for i := range results {
+		votes := make(map[string]int)
+		proposals := make(map[string]int)
+		for _, run := range runs {
+			for _, theme := range run[i].Themes {
+				votes[theme]++
+			}
+			if run[i].ProposedTheme != "" {
+				proposals[run[i].ProposedTheme]++
+			}
+		}
+
+		// Keep the majority themes in theme order
+		consensus := []string{}
+		for _, theme := range themes {
+			if votes[theme]*2 > len(runs) {
+				consensus = append(consensus, theme)
+			}
+		}
+
+		// Count the runs agreeing with the consensus, keeping the
+		// justification of the first of them
+		agreeing := 0
+		reason := ""
+		for _, run := range runs {
+			if sameThemes(run[i].Themes, consensus) {
+				agreeing++
+				if reason == "" {
+					reason = run[i].Reason
+				}
+			}
+		}
+
+		// Keep the most frequent proposal for unmatched responses
+		proposedTheme := ""
+		if len(consensus) == 0 {
+			best := 0
+			for proposal, count := range proposals {
+				if count > best || (count == best && proposal < proposedTheme) {
+					proposedTheme, best = proposal, count
+				}
+			}
+		}
+
+		results[i] = MatchResult{
+			Themes:        consensus,
+			ProposedTheme: proposedTheme,
+			Reason:        reason,
+			Agreement:     float64(agreeing) / float64(len(runs)),
+		}
 	}
+	return results
+}
 
-	// Parse the results
-	return c.parseBatchResults(completion, len(responses), themes), nil
+// sameThemes reports whether two theme lists contain the same themes
+func sameThemes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, theme := range a {
+		set[theme] = true
+	}
+	for _, theme := range b {
+		if !set[theme] {
+			return false
+		}
+	}
+	return true
 }
 
 // parseBatchResults parses the batch results from the API response
-func (c *Client) parseBatchResults(completion string, responseCount int, themes []string) [][]string {
-	results := make([][]string, responseCount)
+func (c *Client) parseBatchResults(completion string, responseCount int, themes []string) []MatchResult {
+	results := make([]MatchResult, responseCount)
+	answered := make([]bool, responseCount)
 
 	// Initialize with empty slices
 	for i := range results {
-		results[i] = []string{}
+		results[i].Themes = []string{}
+		results[i].Agreement = 1
 	}
 
 	// Split by lines
@@ -565,7 +1220,7 @@ func (c *Client) parseBatchResults(completion string, responseCount int, themes
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 
-		// Look for lines like "RESPONSE 1: 2, 4, 7"
+		// Look for lines like "RESPONSE 1: 2, 4, 7" or "RESPONSE 2: NONE NEW: Parking WHY: ..."
 		if strings.HasPrefix(line, "RESPONSE ") {
 			parts := strings.SplitN(line, ":", 2)
 			if len(parts) != 2 {
@@ -579,12 +1234,24 @@ func (c *Client) parseBatchResults(completion string, responseCount int, themes
 				continue
 			}
 
-			// Extract theme numbers
+			// Split off the justification and a proposed new theme
 			themeNumsStr := strings.TrimSpace(parts[1])
+			reason := ""
+			if idx := strings.Index(themeNumsStr, "WHY:"); idx >= 0 {
+				reason = strings.TrimSpace(themeNumsStr[idx+4:])
+				themeNumsStr = strings.TrimSpace(themeNumsStr[:idx])
+			}
+			proposedTheme := ""
+			if idx := strings.Index(themeNumsStr, "NEW:"); idx >= 0 {
+				proposedTheme = strings.Trim(strings.TrimSpace(themeNumsStr[idx+4:]), "\"")
+				themeNumsStr = strings.TrimSpace(themeNumsStr[:idx])
+			}
+
+			// Extract theme numbers
 			themeNumsStr = strings.ReplaceAll(themeNumsStr, " ", "")
 			themeNumStrs := strings.Split(themeNumsStr, ",")
 
-			var matchedThemes []string
+			matchedThemes := []string{}
 			for _, numStr := range themeNumStrs {
 				var num int
 				if _, err := fmt.Sscanf(numStr, "%d", &num); err == nil {
@@ -594,66 +1261,92 @@ func (c *Client) parseBatchResults(completion string, responseCount int, themes
 				}
 			}
 
+			// Only keep proposals for responses without a matching theme
+			if len(matchedThemes) > 0 || !c.proposeNewThemes {
+				proposedTheme = ""
+			}
+
 			// Store matched themes
-			results[responseNum-1] = matchedThemes
+			results[responseNum-1] = MatchResult{
+				Themes:        matchedThemes,
+				ProposedTheme: proposedTheme,
+				Reason:        reason,
+				Agreement:     1,
+			}
+			answered[responseNum-1] = true
+		}
+	}
+
+	var missing []string
+	for i, ok := range answered {
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%d", i+1))
 		}
 	}
+	if len(missing) > 0 {
+		c.logger.Warn("Batch answer did not classify all responses, leaving them without themes", "missing", strings.Join(missing, ","), "batch_size", responseCount)
+	}
 
 	return results
 }
 
-// GenerateThemeSummary generates a summary for a specific theme and extracts unique ideas
-func (c *Client) GenerateThemeSummary(theme string, responses []string, themeSummaryPrompt string) (string, error) {
-	// Limit the number of responses to include
-	maxResponses := 15
-
+// GenerateThemeSummary generates a summary for a specific theme from a
+// sample of the total responses of the theme and extracts unique ideas. With
+// a sentiment balance, the summary is asked to state it explicitly.
+func (c *Client) GenerateThemeSummary(theme string, responses []string, total int, themeSummaryPrompt string, sentiment *SentimentBalance) (string, error) {
 	// Create prompt with consistent format
 	prompt := fmt.Sprintf("Theme: %s\n\nResponses:", theme)
 
-	// Sort responses by length to ensure consistent selection if truncated
-	// This helps create more stable cache keys
-	if len(responses) > maxResponses {
-		// Create a copy to avoid modifying the original
-		responsesCopy := make([]string, len(responses))
-		copy(responsesCopy, responses)
-
-		// Sort by length (shorter responses first)
-		sort.Slice(responsesCopy, func(i, j int) bool {
-			return len(responsesCopy[i]) < len(responsesCopy[j])
-		})
-
-		// Take the first maxResponses
-		responses = responsesCopy[:maxResponses]
+	for _, response := range responses {
+		// Truncate very long responses
+		truncatedResponse := c.truncateResponse(TaskThemeSummary, response, 300)
+		prompt += fmt.Sprintf("\n- %s", c.quoteResponse(truncatedResponse))
 	}
 
-	// Add responses (limited)
-	responsesToInclude := min(len(responses), maxResponses)
-	for i := 0; i < responsesToInclude; i++ {
-		// Truncate very long responses
-		truncatedResponse := responses[i]
-		if len(responses[i]) > 300 {
-			truncatedResponse = responses[i][:297] + "..."
-		}
-		prompt += fmt.Sprintf("\n- %s", truncatedResponse)
+	if total > len(responses) {
+		prompt += fmt.Sprintf("\n\n(Showing %d of %d responses)", len(responses), total)
 	}
 
-	if len(responses) > maxResponses {
-		prompt += fmt.Sprintf("\n\n(Showing %d of %d responses)", maxResponses, len(responses))
+	// State the sentiment of all responses, not only the ones shown
+	if sentiment != nil && sentiment.Total() > 0 {
+		prompt += fmt.Sprintf("\n\nSentiment of all %d responses: %d positive, %d neutral, %d negative, %s. State this sentiment balance explicitly in the summary, with the share, e.g. \"mostly negative (72%%)\".",
+			sentiment.Total(), sentiment.Positive, sentiment.Neutral, sentiment.Negative, sentiment.Describe())
 	}
 
 	// Get language instructions
 	langInstructions := c.getLanguageInstructions()
 
 	// Add concise instructions for structured output (without # symbols)
-	prompt += "\n\nProvide:\nSUMMARY:\n[summary]\n\nUNIQUE IDEAS:\nIDEA: [idea 1]\nIDEA: [idea 2]\n...\n\nDo not include any # symbols in your response."
+	if c.structuredOutput {
+		prompt += "\n\nRecord the summary and the unique ideas of the responses by calling the " + themeSummaryToolName + " tool. Do not include any # symbols."
+	} else {
+		prompt += "\n\nProvide:\nSUMMARY:\n[summary]\n\nUNIQUE IDEAS:\nIDEA: [idea 1]\nIDEA: [idea 2]\n...\n\nDo not include any # symbols in your response."
+	}
+	prompt += "\n" + c.dataNotice()
 
 	// Add language instructions if needed
 	if langInstructions != "" {
 		prompt += "\n" + langInstructions
 	}
 
+	// Record the summary with the tool, asking again for a malformed answer
+	if c.structuredOutput {
+		var summary string
+		err := c.completeStructured(StageThemeSummaries, prompt, themeSummaryPrompt, DefaultTemperature, "", themeSummaryTool(), structuredRetries, nil, func(answer string) error {
+			parsed, err := parseToolThemeSummary(answer)
+			if err == nil {
+				summary = parsed
+			}
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to generate theme summary: %w", err)
+		}
+		return summary, nil
+	}
+
 	// Get completion
-	completion, err := c.GetCompletion(prompt, themeSummaryPrompt, DefaultMaxTokens)
+	completion, err := c.completeStage(StageThemeSummaries, prompt, themeSummaryPrompt, DefaultMaxTokens)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate theme summary: %w", err)
 	}
@@ -663,6 +1356,13 @@ func (c *Client) GenerateThemeSummary(theme string, responses []string, themeSum
 
 // GenerateGlobalSummary generates a global summary based on theme summaries
 func (c *Client) GenerateGlobalSummary(themeSummaries map[string]ThemeSummary, globalSummaryPrompt string, summaryLength int) (string, error) {
+	return c.GenerateGlobalSummaryVariant(themeSummaries, globalSummaryPrompt, summaryLength, 0)
+}
+
+// GenerateGlobalSummaryVariant generates one of several variants of the
+// global summary. Each variant is cached separately; variant 0 is the
+// summary of GenerateGlobalSummary.
+func (c *Client) GenerateGlobalSummaryVariant(themeSummaries map[string]ThemeSummary, globalSummaryPrompt string, summaryLength int, variant int) (string, error) {
 	// Create a more concise prompt
 	prompt := "Theme summaries from survey responses:\n\n"
 
@@ -696,7 +1396,7 @@ func (c *Client) GenerateGlobalSummary(themeSummaries map[string]ThemeSummary, g
 	}
 
 	// Get completion
-	completion, err := c.GetCompletion(prompt, globalSummaryPrompt, DefaultMaxTokens)
+	completion, err := c.completeVariant(StageGlobalSummary, prompt, globalSummaryPrompt, DefaultMaxTokens, variant)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate global summary: %w", err)
 	}
@@ -707,6 +1407,222 @@ func (c *Client) GenerateGlobalSummary(themeSummaries map[string]ThemeSummary, g
 	return processedSummary, nil
 }
 
+// CitedResponse represents a response that may be cited in a grounded summary
+type CitedResponse struct {
+	ID   string
+	Text string
+}
+
+// GenerateCitedGlobalSummary generates a global summary in which claims are
+// backed by citation markers. Themes are referenced as [T1], [T2], ... in the
+// order given; responses are referenced by their ID, e.g. [R12].
+func (c *Client) GenerateCitedGlobalSummary(themes []string, themeSummaries map[string]ThemeSummary, evidence map[string][]CitedResponse, globalSummaryPrompt string, summaryLength int) (string, error) {
+	return c.GenerateCitedGlobalSummaryVariant(themes, themeSummaries, evidence, globalSummaryPrompt, summaryLength, 0)
+}
+
+// GenerateCitedGlobalSummaryVariant generates one of several variants of the
+// global summary with citation markers, see GenerateGlobalSummaryVariant
+func (c *Client) GenerateCitedGlobalSummaryVariant(themes []string, themeSummaries map[string]ThemeSummary, evidence map[string][]CitedResponse, globalSummaryPrompt string, summaryLength int, variant int) (string, error) {
+	prompt := "Theme summaries from survey responses, each with a citation marker and example responses:\n\n"
+
+	for i, theme := range themes {
+		summary, ok := themeSummaries[theme]
+		if !ok {
+			continue
+		}
+		prompt += fmt.Sprintf("## [T%d] %s\n%s\n", i+1, theme, summary.Summary)
+		if len(evidence[theme]) > 0 {
+			prompt += "Example responses:\n"
+			for _, response := range evidence[theme] {
+				text := response.Text
+				if len(text) > 200 {
+					text = text[:197] + "..."
+				}
+				prompt += fmt.Sprintf("- [%s] %s\n", response.ID, c.quoteResponse(text))
+			}
+		}
+		prompt += "\n"
+	}
+
+	// Get language instructions
+	langInstructions := c.getLanguageInstructions()
+
+	prompt += fmt.Sprintf("Create a comprehensive global summary highlighting the most important findings. Length: ~%d characters. DO NOT include a title or heading in your response. ", summaryLength)
+	prompt += "Support every claim with one or more citation markers in square brackets, using only the theme markers (e.g. [T2]) and response IDs (e.g. [R15]) listed above. Do not make claims that are not supported by the material above. "
+	prompt += c.dataNotice()
+
+	// Add language instructions if needed
+	if langInstructions != "" {
+		prompt += " " + langInstructions
+	}
+
+	// Get completion
+	completion, err := c.completeVariant(StageGlobalSummary, prompt, globalSummaryPrompt, DefaultMaxTokens, variant)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate global summary: %w", err)
+	}
+
+	return removeTitle(completion), nil
+}
+
+// ClaimCheck represents the verification of a single claim of a summary
+type ClaimCheck struct {
+	Claim     string `yaml:"claim"`
+	Supported bool   `yaml:"supported"`
+	Reason    string `yaml:"reason,omitempty"`
+}
+
+// VerifySummary checks each claim of a summary against the source material
+// using the given model, which is typically a cheaper one
+func (c *Client) VerifySummary(model string, summary string, sources []string) ([]ClaimCheck, error) {
+	prompt := "Here is a summary:\n\n" + summary + "\n\nHere is the source material the summary is based on:\n\n"
+	for i, source := range sources {
+		text := c.truncateResponse(TaskVerification, source, 300)
+		prompt += fmt.Sprintf("%d: %s\n", i+1, c.quoteResponse(text))
+	}
+	prompt += "\nSplit the summary into its individual factual claims. Check each claim against the source material only. Quote each claim exactly as it appears in the summary. Format your answer as:\n"
+	prompt += "CLAIM: [claim quoted from the summary]\nVERDICT: SUPPORTED or UNSUPPORTED\nREASON: [short reason]\n\n"
+	prompt += "Answer in the language of the summary.\n" + c.dataNotice()
+
+	completion, err := c.completeTask(TaskVerification, model, prompt, "You are a careful fact checker verifying summaries of survey responses.", DefaultMaxTokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify summary: %w", err)
+	}
+
+	parseStarted := time.Now()
+	defer c.recordParse(TaskVerification, parseStarted, nil)
+	return parseClaimChecks(completion), nil
+}
+
+// parseClaimChecks parses the claim verification returned by VerifySummary
+func parseClaimChecks(completion string) []ClaimCheck {
+	var checks []ClaimCheck
+	for _, line := range strings.Split(completion, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "CLAIM:"):
+			checks = append(checks, ClaimCheck{
+				Claim:     strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "CLAIM:")), "\""),
+				Supported: true,
+			})
+		case strings.HasPrefix(line, "VERDICT:") && len(checks) > 0:
+			verdict := strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(line, "VERDICT:")))
+			checks[len(checks)-1].Supported = !strings.HasPrefix(verdict, "UNSUPPORTED")
+		case strings.HasPrefix(line, "REASON:") && len(checks) > 0:
+			checks[len(checks)-1].Reason = strings.TrimSpace(strings.TrimPrefix(line, "REASON:"))
+		}
+	}
+	return checks
+}
+
+// SummaryJudgement is the choice of the best of several summary variants
+type SummaryJudgement struct {
+	Best   int    // Index of the best variant
+	Reason string // Why the variant was chosen
+}
+
+// JudgeSummaries asks which of several variants of the global summary best
+// reflects the theme summaries it is based on
+func (c *Client) JudgeSummaries(themes []string, themeSummaries map[string]ThemeSummary, summaries []string) (SummaryJudgement, error) {
+	prompt := fmt.Sprintf("Here are %d candidate summaries of the same survey analysis:\n\n", len(summaries))
+	for i, summary := range summaries {
+		prompt += fmt.Sprintf("SUMMARY %d:\n%s\n\n", i+1, summary)
+	}
+	prompt += "They are based on these theme summaries:\n\n"
+	for _, theme := range themes {
+		if summary, ok := themeSummaries[theme]; ok {
+			prompt += fmt.Sprintf("## %s\n%s\n\n", theme, summary.Summary)
+		}
+	}
+	prompt += "Choose the summary that reflects the theme summaries best: it states the most important findings accurately, covers the relevant themes, makes no claims beyond the theme summaries and reads clearly. Format your answer as:\n"
+	prompt += "BEST: [number of the summary]\nREASON: [short reason]\n\nAnswer in the language of the summaries."
+
+	completion, err := c.completeTask(TaskSummaryJudge, c.model, prompt, "You are a careful editor judging summaries of survey responses.", DefaultMaxTokens)
+	if err != nil {
+		return SummaryJudgement{}, fmt.Errorf("failed to judge summaries: %w", err)
+	}
+
+	parseStarted := time.Now()
+	defer c.recordParse(TaskSummaryJudge, parseStarted, nil)
+	return parseSummaryJudgement(completion, len(summaries))
+}
+
+// parseSummaryJudgement parses the answer of JudgeSummaries
+func parseSummaryJudgement(completion string, count int) (SummaryJudgement, error) {
+	judgement := SummaryJudgement{Best: -1}
+	for _, line := range strings.Split(completion, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "BEST:"):
+			number, err := strconv.Atoi(strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "BEST:")), "[]."))
+			if err == nil && number >= 1 && number <= count {
+				judgement.Best = number - 1
+			}
+		case strings.HasPrefix(line, "REASON:"):
+			judgement.Reason = strings.TrimSpace(strings.TrimPrefix(line, "REASON:"))
+		}
+	}
+	if judgement.Best < 0 {
+		return judgement, fmt.Errorf("the judgement names none of the %d summaries", count)
+	}
+	return judgement, nil
+}
+
+// LabelClusters asks for a short theme-like label for each cluster of responses
+func (c *Client) LabelClusters(clusters [][]string, contextPrompt string) ([]string, error) {
+	// Get language instructions
+	langInstructions := c.getLanguageInstructions()
+
+	prompt := "Survey responses were grouped into clusters by similarity. Below is a sample of each cluster.\n\n"
+	for i, responses := range clusters {
+		prompt += fmt.Sprintf("CLUSTER %d (%d responses):\n%s\n", i+1, len(responses), c.numberedSample(TaskClusterLabels, responses, 15))
+	}
+	prompt += "Give each cluster a short label (a few words) describing what its responses have in common. Format your answer with one line per cluster:\n"
+	prompt += "CLUSTER 1: [label]\nCLUSTER 2: [label]\n...\n" + c.dataNotice()
+
+	// Add language instructions if needed
+	if langInstructions != "" {
+		prompt += "\n" + langInstructions
+	}
+
+	// Get completion
+	completion, err := c.completeTask(TaskClusterLabels, c.model, prompt, contextPrompt, DefaultMaxTokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to label clusters: %w", err)
+	}
+
+	parseStarted := time.Now()
+	defer c.recordParse(TaskClusterLabels, parseStarted, nil)
+	return parseClusterLabels(completion, len(clusters)), nil
+}
+
+// parseClusterLabels parses the labels returned by LabelClusters. Clusters
+// without a label are named by their number.
+func parseClusterLabels(completion string, count int) []string {
+	labels := make([]string, count)
+	for _, line := range strings.Split(completion, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(strings.ToUpper(line), "CLUSTER ") {
+			continue
+		}
+		parts := strings.SplitN(line[len("CLUSTER "):], ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || number < 1 || number > count {
+			continue
+		}
+		labels[number-1] = strings.Trim(strings.TrimSpace(parts[1]), "\"*")
+	}
+	for i, label := range labels {
+		if label == "" {
+			labels[i] = fmt.Sprintf("Cluster %d", i+1)
+		}
+	}
+	return labels
+}
+
 // removeTitle removes titles from summaries
 func removeTitle(text string) string {
 	lines := strings.Split(text, "\n")
@@ -771,7 +1687,7 @@ func (c *Client) GenerateSummary(themeResponses map[string][]string, summaryProm
 		prompt += fmt.Sprintf("Theme: %s\n", theme)
 		for i, response := range responses {
 			if i < 10 { // Limit to 10 responses per theme to avoid token limits
-				prompt += fmt.Sprintf("- %s\n", response)
+				prompt += fmt.Sprintf("- %s\n", c.quoteResponse(response))
 			}
 		}
 		prompt += "\n"
@@ -780,7 +1696,7 @@ func (c *Client) GenerateSummary(themeResponses map[string][]string, summaryProm
 	// Get language instructions
 	langInstructions := c.getLanguageInstructions()
 
-	prompt += fmt.Sprintf("\nBased on the above, provide a summary of the main points made in each theme and highlight any unique ideas or problems mentioned. The summary should be approximately %d characters long.", summaryLength)
+	prompt += fmt.Sprintf("\nBased on the above, provide a summary of the main points made in each theme and highlight any unique ideas or problems mentioned. The summary should be approximately %d characters long. %s", summaryLength, c.dataNotice())
 
 	// Add language instructions if needed
 	if langInstructions != "" {
@@ -788,7 +1704,7 @@ func (c *Client) GenerateSummary(themeResponses map[string][]string, summaryProm
 	}
 
 	// Get completion
-	completion, err := c.GetCompletion(prompt, summaryPrompt, DefaultMaxTokens)
+	completion, err := c.completeTask(TaskSummary, c.model, prompt, summaryPrompt, DefaultMaxTokens)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate summary: %w", err)
 	}
@@ -0,0 +1,61 @@
+package claude
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMajorityVote(t *testing.T) {
+	themes := []string{"Delivery", "Prices", "Support"}
+	tests := []struct {
+		name string
+		runs []MatchResult // Result of the response in each run
+		want MatchResult
+	}{
+		{
+			name: "unanimous",
+			runs: []MatchResult{{Themes: []string{"Prices"}}, {Themes: []string{"Prices"}}, {Themes: []string{"Prices"}}},
+			want: MatchResult{Themes: []string{"Prices"}, Agreement: 1},
+		},
+		{
+			name: "majority of the runs",
+			runs: []MatchResult{{Themes: []string{"Delivery", "Prices"}}, {Themes: []string{"Delivery"}}, {Themes: []string{"Delivery", "Prices"}}},
+			want: MatchResult{Themes: []string{"Delivery", "Prices"}, Agreement: 2.0 / 3},
+		},
+		{
+			name: "themes in theme order",
+			runs: []MatchResult{{Themes: []string{"Support", "Delivery"}}, {Themes: []string{"Delivery", "Support"}}},
+			want: MatchResult{Themes: []string{"Delivery", "Support"}, Agreement: 1},
+		},
+		{
+			name: "half of the runs is no majority",
+			runs: []MatchResult{{Themes: []string{"Delivery"}}, {Themes: []string{}}},
+			want: MatchResult{Themes: []string{}, Agreement: 0.5},
+		},
+		{
+			name: "most frequent proposal",
+			runs: []MatchResult{{Themes: []string{"Delivery"}}, {Themes: []string{}, ProposedTheme: "Packaging"}, {Themes: []string{}, ProposedTheme: "Packaging"}},
+			want: MatchResult{Themes: []string{}, ProposedTheme: "Packaging", Agreement: 2.0 / 3},
+		},
+		{
+			name: "tied proposals",
+			runs: []MatchResult{{Themes: []string{}, ProposedTheme: "Returns"}, {Themes: []string{}, ProposedTheme: "Packaging"}},
+			want: MatchResult{Themes: []string{}, ProposedTheme: "Packaging", Agreement: 1},
+		},
+		{
+			name: "no proposal with a consensus",
+			runs: []MatchResult{{Themes: []string{"Prices"}}, {Themes: []string{"Prices"}}, {Themes: []string{}, ProposedTheme: "Packaging"}},
+			want: MatchResult{Themes: []string{"Prices"}, Agreement: 2.0 / 3},
+		},
+	}
+	for _, test := range tests {
+		runs := make([][]MatchResult, len(test.runs))
+		for i, result := range test.runs {
+			runs[i] = []MatchResult{result}
+		}
+		got := majorityVote(runs, themes)
+		if len(got) != 1 || !reflect.DeepEqual(got[0], test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
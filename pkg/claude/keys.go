@@ -0,0 +1,93 @@
+package claude
+
+import "sync"
+
+// Rotation strategies for several API keys
+const (
+	RotateRoundRobin  = "round_robin"   // Use the keys in turn for every request
+	RotateOnRateLimit = "on_rate_limit" // Use a key until it hits the rate limit
+)
+
+// APIKey is a named API key
+type APIKey struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+// KeyUsage is the usage of one API key
+type KeyUsage struct {
+	Name     string
+	Requests int
+	Tokens   int
+	Cost     float64
+}
+
+// apiKeys rotates requests over several API keys and accounts for their usage
+type apiKeys struct {
+	mutex    sync.Mutex
+	keys     []APIKey
+	usage    []KeyUsage
+	rotation string
+	current  int
+}
+
+// newAPIKeys creates the rotation of the given keys
+func newAPIKeys(keys []APIKey, rotation string) *apiKeys {
+	usage := make([]KeyUsage, len(keys))
+	for i, key := range keys {
+		usage[i].Name = key.Name
+	}
+	return &apiKeys{keys: keys, usage: usage, rotation: rotation}
+}
+
+// next returns the index of the key for the next request
+func (k *apiKeys) next() int {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	index := k.current
+	if k.rotation != RotateOnRateLimit {
+		k.current = (k.current + 1) % len(k.keys)
+	}
+	return index
+}
+
+// rateLimited moves on from a key that hit the rate limit and returns the
+// key to retry with
+func (k *apiKeys) rateLimited(index int) int {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.rotation == RotateOnRateLimit && k.current == index {
+		k.current = (k.current + 1) % len(k.keys)
+	}
+	return (index + 1) % len(k.keys)
+}
+
+// record adds a request to the usage of a key
+func (k *apiKeys) record(index int, cost Cost) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.usage[index].Requests++
+	k.usage[index].Tokens += cost.TotalTokens
+	k.usage[index].Cost += cost.Cost
+}
+
+// SetAPIKeys spreads the requests over several API keys with the given
+// rotation strategy, replacing the key of NewClient
+func (c *Client) SetAPIKeys(keys []APIKey, rotation string) {
+	if len(keys) > 0 {
+		c.keys = newAPIKeys(keys, rotation)
+	}
+}
+
+// KeyUsage returns the requests, tokens and cost per API key
+func (c *Client) KeyUsage() []KeyUsage {
+	c.keys.mutex.Lock()
+	defer c.keys.mutex.Unlock()
+
+	usage := make([]KeyUsage, len(c.keys.usage))
+	copy(usage, c.keys.usage)
+	return usage
+}
@@ -0,0 +1,665 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// mockModels are the models listed by the mock provider
+var mockModels = []ModelInfo{
+	{ID: "claude-sonnet-4-5-20250929", DisplayName: "Claude Sonnet 4.5", CreatedAt: time.Date(2025, 9, 29, 0, 0, 0, 0, time.UTC)},
+	{ID: "claude-haiku-4-5-20251001", DisplayName: "Claude Haiku 4.5", CreatedAt: time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)},
+	{ID: "claude-3-haiku-20240307", DisplayName: "Claude Haiku 3", CreatedAt: time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)},
+}
+
+var (
+	mockResponsePattern  = regexp.MustCompile(`(?s)<response>(.*?)</response>`)
+	mockThemePattern     = regexp.MustCompile(`(?m)^(\d+)\. (.+)$`)
+	mockHeadingPattern   = regexp.MustCompile(`(?m)^## (\[T\d+\] )?(.+)$`)
+	mockCitationPattern  = regexp.MustCompile(`(?m)^- \[(R[^\]]+)\]`)
+	mockSentimentPattern = regexp.MustCompile(`negative, (.+?)\. State this`)
+	mockClusterPattern   = regexp.MustCompile(`(?m)^CLUSTER (\d+) \(`)
+)
+
+// mockStopwords are words left out of the themes and labels of the mock
+// provider
+var mockStopwords = map[string]bool{
+	"about": true, "after": true, "again": true, "also": true, "always": true, "been": true,
+	"better": true, "could": true, "does": true, "even": true, "every": true, "from": true,
+	"have": true, "just": true, "like": true, "more": true, "much": true, "never": true,
+	"only": true, "really": true, "should": true, "some": true, "than": true, "that": true,
+	"their": true, "them": true, "then": true, "there": true, "they": true, "this": true,
+	"time": true, "very": true, "were": true, "what": true, "when": true, "which": true,
+	"will": true, "with": true, "would": true, "your": true, "please": true, "great": true,
+	"good": true, "still": true, "thing": true, "things": true, "overall": true, "other": true,
+	"aber": true, "auch": true, "dass": true, "eine": true, "einen": true, "immer": true,
+	"nicht": true, "noch": true, "oder": true, "sehr": true, "sind": true, "und": true,
+	"wenn": true, "wird": true, "wäre": true,
+}
+
+// mockTransport answers Claude API requests offline. The answers are derived
+// deterministically from the prompts: themes are the most frequent words of
+// the responses, a response matches a theme if it contains a word of the
+// theme name, and summaries quote the responses. It lets demos and template
+// work run the whole pipeline without an API key. Message batches end as
+// soon as they are sent.
+type mockTransport struct {
+	mutex   sync.Mutex
+	batches map[string][]byte // Results of the message batches by ID
+}
+
+// UseMockProvider answers all requests with the offline mock provider
+// instead of the Claude API. Token counts and costs are estimated from the
+// length of the prompts and answers.
+func (c *Client) UseMockProvider() {
+	c.provider = anthropic{}
+	c.httpClient = &http.Client{Transport: &mockTransport{batches: make(map[string][]byte)}}
+	c.rateLimitDelay = 0
+}
+
+// RoundTrip answers a request to the messages, message batches or models
+// endpoint
+func (t *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch {
+	case req.Method == http.MethodGet && req.URL.Path == "/v1/models":
+		return mockJSON(http.StatusOK, modelList{Data: mockModels})
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/v1/models/"):
+		// Every model is known, so configured models can be tried out
+		id := strings.TrimPrefix(req.URL.Path, "/v1/models/")
+		return mockJSON(http.StatusOK, ModelInfo{ID: id, DisplayName: id})
+	case req.Method == http.MethodPost && req.URL.Path == "/v1/messages":
+		var body RequestBody
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("mock provider: invalid request: %w", err)
+		}
+		return mockJSON(http.StatusOK, mockMessage(body))
+	case req.Method == http.MethodPost && req.URL.Path == "/v1/messages/batches":
+		var body struct {
+			Requests []batchRequest `json:"requests"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("mock provider: invalid request: %w", err)
+		}
+		var results bytes.Buffer
+		for _, request := range body.Requests {
+			message, err := json.Marshal(mockMessage(request.Params))
+			if err != nil {
+				return nil, err
+			}
+			var result batchResult
+			result.CustomID = request.CustomID
+			result.Result.Type = "succeeded"
+			result.Result.Message = message
+			if err := json.NewEncoder(&results).Encode(result); err != nil {
+				return nil, err
+			}
+		}
+		t.mutex.Lock()
+		id := fmt.Sprintf("msgbatch_mock_%d", len(t.batches)+1)
+		t.batches[id] = results.Bytes()
+		t.mutex.Unlock()
+		batch := messageBatch{ID: id, ProcessingStatus: "in_progress"}
+		batch.RequestCounts.Processing = len(body.Requests)
+		return mockJSON(http.StatusOK, batch)
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/v1/messages/batches/"):
+		id, results := strings.CutSuffix(strings.TrimPrefix(req.URL.Path, "/v1/messages/batches/"), "/results")
+		t.mutex.Lock()
+		data, ok := t.batches[id]
+		t.mutex.Unlock()
+		if !ok {
+			break
+		}
+		if results {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Status:     "200 OK",
+				Header:     http.Header{"Content-Type": {"application/x-jsonl"}},
+				Body:       io.NopCloser(bytes.NewReader(data)),
+			}, nil
+		}
+		batch := messageBatch{ID: id, ProcessingStatus: "ended", ResultsURL: MessageBatchesURL + "/" + id + "/results"}
+		batch.RequestCounts.Succeeded = bytes.Count(data, []byte("\n"))
+		return mockJSON(http.StatusOK, batch)
+	}
+	return mockJSON(http.StatusNotFound, map[string]interface{}{
+		"type":  "error",
+		"error": map[string]string{"type": "not_found_error", "message": "not supported by the mock provider"},
+	})
+}
+
+// mockMessage answers a messages request
+func mockMessage(body RequestBody) ResponseBody {
+	prompt := ""
+	if len(body.Messages) > 0 {
+		prompt = body.Messages[0].Content
+	}
+	answer := mockAnswer(prompt)
+	content := []ContentBlock{{Type: "text", Text: answer}}
+	stopReason := "end_turn"
+	if len(body.Tools) > 0 {
+		switch body.Tools[0].Name {
+		case classificationToolName:
+			answer = mockToolClassifications(mockThemes(before(prompt, "For each response")), mockResponses(prompt), body.Tools[0])
+		case themeSummaryToolName:
+			answer = mockToolThemeSummary(prompt, mockResponses(prompt))
+		case sentimentToolName:
+			answer = mockToolSentiments(mockResponses(prompt))
+		}
+		content = []ContentBlock{{Type: "tool_use", Name: body.Tools[0].Name, Input: json.RawMessage(answer)}}
+		stopReason = "tool_use"
+	}
+	response := ResponseBody{
+		ID:         "msg_mock",
+		Type:       "message",
+		Role:       "assistant",
+		Content:    content,
+		Model:      body.Model,
+		StopReason: stopReason,
+	}
+	response.Usage.InputTokens = (len(body.System)+len(prompt))/4 + 1
+	response.Usage.OutputTokens = len(answer)/4 + 1
+	return response
+}
+
+// mockJSON creates a response with a JSON body
+func mockJSON(status int, value interface{}) (*http.Response, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
+
+// mockAnswer answers a prompt, recognizing the task by the wording of the
+// prompts built by the client
+func mockAnswer(prompt string) string {
+	responses := mockResponses(prompt)
+	switch {
+	case strings.HasPrefix(prompt, "Identify main themes"):
+		return mockList(mockFrequentWords(responses, nil, 6))
+	case strings.HasPrefix(prompt, "All of these"):
+		theme := between(prompt, "assigned to the theme \"", "\"")
+		return mockList(mockFrequentWords(responses, mockKeywordSet(theme), 3))
+	case strings.HasPrefix(prompt, "These themes were identified"):
+		return mockDescriptions(mockThemes(before(prompt, "Sample of the responses")))
+	case strings.HasPrefix(prompt, "Analyze multiple survey responses"):
+		return mockBatchMatches(mockThemes(before(prompt, "For each response")), responses, strings.Contains(prompt, "WHY:"))
+	case strings.HasPrefix(prompt, "Here is a survey response"):
+		var lines []string
+		for _, number := range mockMatch(mockThemes(after(prompt, "Here are the themes:")), responses) {
+			lines = append(lines, fmt.Sprintf("- %d", number))
+		}
+		return strings.Join(lines, "\n")
+	case strings.HasPrefix(prompt, "Theme: "):
+		return mockThemeSummary(prompt, responses)
+	case strings.HasPrefix(prompt, "Classify the sentiment"):
+		var lines []string
+		for i, sentiment := range mockSentiments(responses) {
+			lines = append(lines, fmt.Sprintf("RESPONSE %d: %s %d", i+1, strings.ToUpper(sentiment.Label), sentiment.Intensity))
+		}
+		return strings.Join(lines, "\n")
+	case strings.HasPrefix(prompt, "Paraphrase each survey response"):
+		var lines []string
+		for i, response := range responses {
+			lines = append(lines, fmt.Sprintf("RESPONSE %d: The respondent mentions %s.", i+1, joinWords(mockFrequentWords([]string{response}, nil, 3))))
+		}
+		return strings.Join(lines, "\n")
+	case strings.HasPrefix(prompt, "Theme summaries from survey responses"):
+		return mockGlobalSummary(prompt)
+	case strings.HasPrefix(prompt, "Here are ") && strings.Contains(prompt, "candidate summaries"):
+		return mockJudgement(prompt)
+	case strings.HasPrefix(prompt, "Answer the question about the survey responses"):
+		return mockAnswerQuestion(prompt)
+	case strings.HasPrefix(prompt, "Here is a summary:"):
+		return mockVerification(between(prompt, "Here is a summary:\n\n", "\n\nHere is the source material"), responses)
+	case strings.HasPrefix(prompt, "Survey responses were grouped into clusters"):
+		return mockClusterLabels(prompt)
+	case strings.HasPrefix(prompt, "Here are the themes and their associated responses"):
+		return fmt.Sprintf("The responses discuss %s.", joinWords(mockFrequentWords(responses, nil, 4)))
+	default:
+		return "This answer was generated by the offline mock provider."
+	}
+}
+
+// mockResponses returns the quoted responses of a prompt
+func mockResponses(prompt string) []string {
+	var responses []string
+	for _, match := range mockResponsePattern.FindAllStringSubmatch(prompt, -1) {
+		responses = append(responses, strings.TrimSpace(match[1]))
+	}
+	return responses
+}
+
+// mockThemes returns the numbered themes of a theme list
+func mockThemes(text string) []string {
+	var themes []string
+	for _, match := range mockThemePattern.FindAllStringSubmatch(text, -1) {
+		themes = append(themes, strings.TrimSpace(match[2]))
+	}
+	return themes
+}
+
+// mockWords splits a text into lower case words
+func mockWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '-'
+	})
+}
+
+// mockStem shortens a word to the prefix its inflected forms share, e.g.
+// deliv for delivery
+func mockStem(word string) string {
+	runes := []rune(word)
+	length := min(max(4, len(runes)-3), len(runes))
+	if length > 6 {
+		length = 6
+	}
+	return string(runes[:length])
+}
+
+// mockKeywordSet returns the stems of the significant words of a theme name
+func mockKeywordSet(theme string) map[string]bool {
+	keywords := make(map[string]bool)
+	for _, word := range mockWords(theme) {
+		if len([]rune(word)) >= 4 && !mockStopwords[word] {
+			keywords[mockStem(word)] = true
+		}
+	}
+	return keywords
+}
+
+// mockMatch returns the numbers of the themes with a keyword that starts a
+// word of the responses
+func mockMatch(themes []string, responses []string) []int {
+	var words []string
+	for _, response := range responses {
+		words = append(words, mockWords(response)...)
+	}
+	var numbers []int
+	for i, theme := range themes {
+		if mockMentions(words, mockKeywordSet(theme)) {
+			numbers = append(numbers, i+1)
+		}
+	}
+	return numbers
+}
+
+// mockMentions reports whether one of the words starts with one of the
+// keyword stems
+func mockMentions(words []string, keywords map[string]bool) bool {
+	for _, word := range words {
+		for keyword := range keywords {
+			if strings.HasPrefix(word, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mockBatchMatches answers a batch matching request, justifying the
+// answers if asked to
+func mockBatchMatches(themes []string, responses []string, reasons bool) string {
+	var b strings.Builder
+	for i, response := range responses {
+		var numbers, names []string
+		for _, number := range mockMatch(themes, []string{response}) {
+			numbers = append(numbers, fmt.Sprintf("%d", number))
+			names = append(names, strings.ToLower(themes[number-1]))
+		}
+		reason := "WHY: The response mentions " + strings.Join(names, " and ") + "."
+		if len(numbers) == 0 {
+			numbers = []string{"NONE"}
+			reason = "WHY: The response mentions none of the themes."
+		}
+		fmt.Fprintf(&b, "RESPONSE %d: %s", i+1, strings.Join(numbers, ", "))
+		if reasons {
+			fmt.Fprintf(&b, " %s", reason)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// mockToolClassifications answers a call of the classification tool, giving
+// justifications if the tool asks for them
+func mockToolClassifications(themes []string, responses []string, tool Tool) string {
+	properties := tool.InputSchema["properties"].(map[string]interface{})["classifications"].(map[string]interface{})["items"].(map[string]interface{})["properties"].(map[string]interface{})
+	_, reasons := properties["why"]
+
+	type classification struct {
+		Response int    `json:"response"`
+		Themes   []int  `json:"themes"`
+		Why      string `json:"why,omitempty"`
+	}
+	input := struct {
+		Classifications []classification `json:"classifications"`
+	}{Classifications: []classification{}}
+	for i, response := range responses {
+		numbers := mockMatch(themes, []string{response})
+		entry := classification{Response: i + 1, Themes: append([]int{}, numbers...)}
+		if reasons {
+			var names []string
+			for _, number := range numbers {
+				names = append(names, strings.ToLower(themes[number-1]))
+			}
+			entry.Why = "The response mentions none of the themes."
+			if len(names) > 0 {
+				entry.Why = "The response mentions " + strings.Join(names, " and ") + "."
+			}
+		}
+		input.Classifications = append(input.Classifications, entry)
+	}
+	data, _ := json.Marshal(input)
+	return string(data)
+}
+
+// mockPositiveWords and mockNegativeWords are the stems of the words the
+// mock provider takes as positive and negative
+var (
+	mockPositiveWords = mockStems("happy", "thanks", "great", "good", "love", "friendly", "fast", "easy")
+	mockNegativeWords = mockStems("late", "slow", "broke", "impossible", "waiting", "delayed", "charged", "problem", "expensive")
+)
+
+// mockStems returns the set of the stems of the words
+func mockStems(words ...string) map[string]bool {
+	stems := make(map[string]bool, len(words))
+	for _, word := range words {
+		stems[mockStem(word)] = true
+	}
+	return stems
+}
+
+// mockSentiments classifies the sentiment of the responses by counting
+// positive and negative words, the difference giving the intensity
+func mockSentiments(responses []string) []Sentiment {
+	sentiments := make([]Sentiment, len(responses))
+	for i, response := range responses {
+		score := 0
+		for _, word := range mockWords(response) {
+			stem := mockStem(word)
+			if mockPositiveWords[stem] {
+				score++
+			}
+			if mockNegativeWords[stem] {
+				score--
+			}
+		}
+		intensity := min(max(score, -score, MinSentimentIntensity), MaxSentimentIntensity)
+		switch {
+		case score > 0:
+			sentiments[i] = Sentiment{Label: SentimentPositive, Intensity: intensity}
+		case score < 0:
+			sentiments[i] = Sentiment{Label: SentimentNegative, Intensity: intensity}
+		default:
+			sentiments[i] = Sentiment{Label: SentimentNeutral, Intensity: MinSentimentIntensity}
+		}
+	}
+	return sentiments
+}
+
+// mockToolSentiments answers a call of the sentiment tool
+func mockToolSentiments(responses []string) string {
+	type entry struct {
+		Response  int    `json:"response"`
+		Sentiment string `json:"sentiment"`
+		Intensity int    `json:"intensity"`
+	}
+	input := struct {
+		Sentiments []entry `json:"sentiments"`
+	}{Sentiments: []entry{}}
+	for i, sentiment := range mockSentiments(responses) {
+		input.Sentiments = append(input.Sentiments, entry{Response: i + 1, Sentiment: sentiment.Label, Intensity: sentiment.Intensity})
+	}
+	data, _ := json.Marshal(input)
+	return string(data)
+}
+
+// mockFrequentWords returns the words occurring in most responses, leaving
+// out stopwords and the excluded stems, as title case themes
+func mockFrequentWords(responses []string, exclude map[string]bool, count int) []string {
+	frequency := make(map[string]int)
+	form := make(map[string]string) // First form seen of each stem
+	for _, response := range responses {
+		seen := make(map[string]bool)
+		for _, word := range mockWords(response) {
+			stem := mockStem(word)
+			if len([]rune(word)) < 4 || mockStopwords[word] || exclude[stem] || seen[stem] {
+				continue
+			}
+			seen[stem] = true
+			frequency[stem]++
+			if _, ok := form[stem]; !ok {
+				form[stem] = word
+			}
+		}
+	}
+	stems := make([]string, 0, len(frequency))
+	for stem, n := range frequency {
+		if n >= 2 || len(responses) < 4 {
+			stems = append(stems, stem)
+		}
+	}
+	sort.Slice(stems, func(i, j int) bool {
+		if frequency[stems[i]] != frequency[stems[j]] {
+			return frequency[stems[i]] > frequency[stems[j]]
+		}
+		return stems[i] < stems[j]
+	})
+	var words []string
+	for _, stem := range stems[:min(count, len(stems))] {
+		runes := []rune(form[stem])
+		words = append(words, string(unicode.ToUpper(runes[0]))+string(runes[1:]))
+	}
+	return words
+}
+
+// mockList formats items as a YAML list
+func mockList(items []string) string {
+	var lines []string
+	for _, item := range items {
+		lines = append(lines, "- "+item)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// mockDescriptions answers a theme description request
+func mockDescriptions(themes []string) string {
+	var b strings.Builder
+	for i, theme := range themes {
+		fmt.Fprintf(&b, "THEME %d:\nDESCRIPTION: Responses about %s.\nINCLUDE: The response mentions %s.\n\n", i+1, strings.ToLower(theme), strings.ToLower(theme))
+	}
+	return b.String()
+}
+
+// mockThemeSummary answers a theme summary request by quoting the shortest
+// and the longest response
+func mockThemeSummary(prompt string, responses []string) string {
+	summary, ideas := mockSummaryAndIdeas(prompt, responses)
+	text := "SUMMARY:\n" + summary + "\n\nUNIQUE IDEAS:\n"
+	for _, idea := range ideas {
+		text += "IDEA: " + idea + "\n"
+	}
+	return text
+}
+
+// mockToolThemeSummary answers a call of the theme summary tool
+func mockToolThemeSummary(prompt string, responses []string) string {
+	summary, ideas := mockSummaryAndIdeas(prompt, responses)
+	data, _ := json.Marshal(toolThemeSummary{Summary: summary, Ideas: ideas})
+	return string(data)
+}
+
+// mockSummaryAndIdeas summarizes the responses of a theme with the shortest
+// response, taking the longest as idea
+func mockSummaryAndIdeas(prompt string, responses []string) (string, []string) {
+	theme := strings.TrimSpace(before(strings.TrimPrefix(prompt, "Theme: "), "\n"))
+	summary := fmt.Sprintf("%d of the quoted responses address %s.", len(responses), strings.ToLower(theme))
+	sorted := append([]string(nil), responses...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) < len(sorted[j]) })
+	if len(sorted) > 0 {
+		summary += fmt.Sprintf(" A typical comment is \"%s\".", sorted[0])
+	}
+	if match := mockSentimentPattern.FindStringSubmatch(prompt); match != nil {
+		summary += fmt.Sprintf(" The sentiment is %s.", match[1])
+	}
+
+	ideas := []string{}
+	if len(sorted) > 1 {
+		ideas = append(ideas, sorted[len(sorted)-1])
+	}
+	return summary, ideas
+}
+
+// mockGlobalSummary answers a global summary request with a sentence per
+// theme, citing the theme and its first example response if asked to
+func mockGlobalSummary(prompt string) string {
+	cited := strings.Contains(prompt, "citation marker")
+	headings := mockHeadingPattern.FindAllStringSubmatchIndex(prompt, -1)
+	var sentences []string
+	for i, heading := range headings {
+		theme := prompt[heading[4]:heading[5]]
+		sentence := fmt.Sprintf("Respondents commented on %s", strings.ToLower(theme))
+		if cited && heading[2] >= 0 {
+			end := len(prompt)
+			if i+1 < len(headings) {
+				end = headings[i+1][0]
+			}
+			sentence += " " + strings.TrimSpace(prompt[heading[2]:heading[3]])
+			if citation := mockCitationPattern.FindStringSubmatch(prompt[heading[1]:end]); citation != nil {
+				sentence += fmt.Sprintf("[%s]", citation[1])
+			}
+		}
+		sentences = append(sentences, sentence+".")
+	}
+	if len(sentences) == 0 {
+		return "The responses did not match any of the themes."
+	}
+	// A single line, so the first sentence is not taken for a title
+	return fmt.Sprintf("The analysis found %d themes in the responses. %s", len(sentences), strings.Join(sentences, " "))
+}
+
+// mockJudgement answers a request to judge summary variants, choosing the
+// longest summary
+func mockJudgement(prompt string) string {
+	best, longest := 1, -1
+	for number := 1; ; number++ {
+		marker := fmt.Sprintf("SUMMARY %d:\n", number)
+		if !strings.Contains(prompt, marker) {
+			break
+		}
+		if summary := before(after(prompt, marker), "\n\n"); len(summary) > longest {
+			best, longest = number, len(summary)
+		}
+	}
+	return fmt.Sprintf("BEST: %d\nREASON: Summary %d covers the most findings.", best, best)
+}
+
+// mockAnswerQuestion answers a question with the themes of the first
+// retrieved responses, citing them
+func mockAnswerQuestion(prompt string) string {
+	citations := mockCitationPattern.FindAllStringSubmatch(after(prompt, "Responses most relevant to the question"), 3)
+	if len(citations) == 0 {
+		return "The responses do not answer the question."
+	}
+	var sentences []string
+	for _, citation := range citations {
+		themes := between(after(prompt, "["+citation[1]+"] "), "(themes: ", ")")
+		sentences = append(sentences, fmt.Sprintf("One respondent raises %s [%s].", strings.ToLower(themes), citation[1]))
+	}
+	return strings.Join(sentences, " ")
+}
+
+// mockVerification answers a verification request, supporting claims that
+// share a word with the source material
+func mockVerification(summary string, sources []string) string {
+	var words []string
+	for _, source := range sources {
+		words = append(words, mockWords(source)...)
+	}
+	var b strings.Builder
+	for _, claim := range strings.SplitAfter(summary, ". ") {
+		claim = strings.TrimSpace(claim)
+		if claim == "" {
+			continue
+		}
+		if mockMentions(words, mockKeywordSet(claim)) {
+			fmt.Fprintf(&b, "CLAIM: %s\nVERDICT: SUPPORTED\nREASON: Mentioned in the source material.\n\n", claim)
+		} else {
+			fmt.Fprintf(&b, "CLAIM: %s\nVERDICT: UNSUPPORTED\nREASON: Not found in the source material.\n\n", claim)
+		}
+	}
+	return b.String()
+}
+
+// mockClusterLabels labels each cluster with its most frequent words
+func mockClusterLabels(prompt string) string {
+	clusters := mockClusterPattern.FindAllStringSubmatchIndex(prompt, -1)
+	var b strings.Builder
+	for i, cluster := range clusters {
+		end := len(prompt)
+		if i+1 < len(clusters) {
+			end = clusters[i+1][0]
+		}
+		words := mockFrequentWords(mockResponses(prompt[cluster[1]:end]), nil, 2)
+		label := "Miscellaneous"
+		if len(words) > 0 {
+			label = strings.Join(words, " and ")
+		}
+		fmt.Fprintf(&b, "CLUSTER %s: %s\n", prompt[cluster[2]:cluster[3]], label)
+	}
+	return b.String()
+}
+
+// joinWords joins words in lower case with commas and "and"
+func joinWords(words []string) string {
+	if len(words) == 0 {
+		return "various topics"
+	}
+	lower := make([]string, len(words))
+	for i, word := range words {
+		lower[i] = strings.ToLower(word)
+	}
+	if len(lower) == 1 {
+		return lower[0]
+	}
+	return strings.Join(lower[:len(lower)-1], ", ") + " and " + lower[len(lower)-1]
+}
+
+// before returns the text before the first occurrence of sep, or the whole
+// text
+func before(text, sep string) string {
+	if i := strings.Index(text, sep); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
+
+// after returns the text after the first occurrence of sep, or an empty
+// string
+func after(text, sep string) string {
+	if i := strings.Index(text, sep); i >= 0 {
+		return text[i+len(sep):]
+	}
+	return ""
+}
+
+// between returns the text between the first occurrence of start and the
+// following occurrence of end
+func between(text, start, end string) string {
+	return before(after(text, start), end)
+}
@@ -0,0 +1,162 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ModelsAPIURL is the URL of the model list of the Claude API
+const ModelsAPIURL = "https://api.anthropic.com/v1/models"
+
+// ErrUnknownModel is returned when the API does not know a model
+var ErrUnknownModel = errors.New("unknown model")
+
+// ModelInfo describes a model available through the Claude API
+type ModelInfo struct {
+	ID          string    `json:"id"`
+	DisplayName string    `json:"display_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// modelList is a page of the model list
+type modelList struct {
+	Data    []ModelInfo `json:"data"`
+	HasMore bool        `json:"has_more"`
+	LastID  string      `json:"last_id"`
+}
+
+// ListModels returns the models available to the API key, newest first
+func (c *Client) ListModels() ([]ModelInfo, error) {
+	var models []ModelInfo
+	afterID := ""
+	for {
+		query := url.Values{"limit": {"100"}}
+		if afterID != "" {
+			query.Set("after_id", afterID)
+		}
+		var page modelList
+		if err := c.getJSON(c.provider.ModelsURL()+"?"+query.Encode(), &page); err != nil {
+			return nil, fmt.Errorf("failed to list models: %w", err)
+		}
+		models = append(models, page.Data...)
+		if !page.HasMore || page.LastID == "" {
+			break
+		}
+		afterID = page.LastID
+	}
+
+	sort.SliceStable(models, func(i, j int) bool {
+		return models[i].CreatedAt.After(models[j].CreatedAt)
+	})
+	return models, nil
+}
+
+// GetModel returns the model with the given ID or alias. It returns an error
+// wrapping ErrUnknownModel if the API does not know the model.
+func (c *Client) GetModel(model string) (ModelInfo, error) {
+	var info ModelInfo
+	if err := c.getJSON(c.provider.ModelsURL()+"/"+url.PathEscape(model), &info); err != nil {
+		return ModelInfo{}, fmt.Errorf("failed to get model %s: %w", model, err)
+	}
+	return info, nil
+}
+
+// getJSON sends a GET request to the API of the provider and decodes the JSON response
+func (c *Client) getJSON(requestURL string, target interface{}) error {
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req, c.keys.keys[0].Key)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrUnknownModel
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s API request failed with status %d: %s", c.provider.Name(), resp.StatusCode, strings.TrimSpace(c.provider.ErrorMessage(data)))
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	return nil
+}
+
+// SuggestModels returns the models whose IDs are closest to a possibly
+// mistyped model name, best match first
+func SuggestModels(name string, models []ModelInfo) []string {
+	const maxSuggestions = 3
+	normalized := normalizeModelName(name)
+
+	type candidate struct {
+		id       string
+		distance int
+	}
+	var candidates []candidate
+	for _, model := range models {
+		id := normalizeModelName(model.ID)
+		distance := editDistance(normalized, id)
+		// Names given without a date match the dated model IDs
+		if strings.HasPrefix(id, normalized) {
+			distance = 0
+		}
+		if distance <= len(normalized)/3 {
+			candidates = append(candidates, candidate{model.ID, distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var suggestions []string
+	for _, item := range candidates {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, item.id)
+	}
+	return suggestions
+}
+
+// normalizeModelName folds the spelling variants of model names, such as
+// "claude-3.5-sonnet" and "Claude 3 5 Sonnet", into one form
+func normalizeModelName(name string) string {
+	return strings.NewReplacer(".", "-", "_", "-", " ", "-").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(min(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
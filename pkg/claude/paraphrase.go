@@ -0,0 +1,51 @@
+package claude
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// paraphrasePattern matches a line of a paraphrase answer, e.g.
+// "RESPONSE 3: The delivery took too long."
+var paraphrasePattern = regexp.MustCompile(`^RESPONSE\s+(\d+)\s*:\s*(.+)$`)
+
+// ParaphraseResponses rewrites a batch of responses in neutral words without
+// names, places, numbers or other details identifying the respondent, for
+// sharing results. The paraphrases are requested at temperature 0 and cached,
+// so the same responses get the same paraphrases. Responses an answer leaves
+// out get an empty paraphrase.
+func (c *Client) ParaphraseResponses(responses []string) ([]string, error) {
+	prompt := "Paraphrase each survey response in one or two neutral sentences that keep its opinion and the topics it raises. " +
+		"Leave out names of people, companies and places, contact details, dates, numbers and any other detail that could identify the respondent, " +
+		"and do not quote the response.\n"
+	prompt += "Format your answer as:\nRESPONSE 1: [paraphrase]\nRESPONSE 2: [paraphrase]\n...\n\n"
+	for i, response := range responses {
+		prompt += fmt.Sprintf("RESPONSE %d: %s\n\n", i+1, c.quoteResponse(c.truncateResponse(TaskParaphrase, response, 1000)))
+	}
+	prompt += c.dataNotice()
+
+	systemPrompt := "You are an analyst preparing survey results for sharing with third parties without revealing the respondents."
+	completion, err := c.complete(TaskParaphrase, c.model, prompt, systemPrompt, DefaultMaxTokens, 0, "", 0, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to paraphrase responses: %w", err)
+	}
+	parseStarted := time.Now()
+	defer c.recordParse(TaskParaphrase, parseStarted, nil)
+
+	paraphrases := make([]string, len(responses))
+	for _, line := range strings.Split(completion, "\n") {
+		match := paraphrasePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(match[1])
+		if number < 1 || number > len(responses) {
+			continue
+		}
+		paraphrases[number-1] = strings.TrimSpace(match[2])
+	}
+	return paraphrases, nil
+}
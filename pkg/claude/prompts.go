@@ -0,0 +1,74 @@
+package claude
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+)
+
+// Tasks of the requests, each with its own built-in instructions
+const (
+	TaskThemeIdentification = StageThemeIdentification
+	TaskMatching            = StageMatching
+	TaskThemeSummary        = StageThemeSummaries
+	TaskGlobalSummary       = StageGlobalSummary
+	TaskThemeSplit          = "theme_split"
+	TaskThemeDescriptions   = "theme_descriptions"
+	TaskVerification        = "verification"
+	TaskClusterLabels       = "cluster_labels"
+	TaskSummary             = "summary"
+	TaskSummaryJudge        = "summary_judge"
+	TaskQuestion            = "question"
+	TaskSentiment           = "sentiment"
+	TaskParaphrase          = "paraphrase"
+	TaskCompletion          = "completion" // Requests with instructions of the caller
+)
+
+// promptRevisions are the revisions of the built-in instructions of each
+// task. Bump the revision of a task when its instructions change, so only
+// its cache entries are invalidated and results show which instructions
+// produced them.
+var promptRevisions = map[string]int{
+	TaskThemeIdentification: 2,
+	TaskMatching:            2,
+	TaskThemeSummary:        2,
+	TaskGlobalSummary:       2,
+	TaskThemeSplit:          2,
+	TaskThemeDescriptions:   2,
+	TaskVerification:        2,
+	TaskClusterLabels:       2,
+	TaskSummary:             2,
+	TaskSummaryJudge:        1,
+	TaskQuestion:            1,
+	TaskSentiment:           1,
+	TaskParaphrase:          1,
+	TaskCompletion:          1,
+}
+
+// PromptVersion returns the version of the prompt of a task: the revision of
+// its built-in instructions and a short hash of the configured system prompt,
+// e.g. r1-3fa2b9c0
+func PromptVersion(task, systemPrompt string) string {
+	hash := sha256.Sum256([]byte(systemPrompt))
+	return fmt.Sprintf("r%d-%s", promptRevisions[task], hex.EncodeToString(hash[:4]))
+}
+
+// usePrompt records the prompt version of a task and returns it
+func (c *Client) usePrompt(task, systemPrompt string) string {
+	version := PromptVersion(task, systemPrompt)
+	c.promptMutex.Lock()
+	defer c.promptMutex.Unlock()
+	if c.promptVersions == nil {
+		c.promptVersions = make(map[string]string)
+	}
+	c.promptVersions[task] = version
+	return version
+}
+
+// PromptVersions returns the prompt version used for each task so far
+func (c *Client) PromptVersions() map[string]string {
+	c.promptMutex.Lock()
+	defer c.promptMutex.Unlock()
+	return maps.Clone(c.promptVersions)
+}
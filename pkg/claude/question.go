@@ -0,0 +1,63 @@
+package claude
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QuestionTheme is a theme of the analysis offered as context for answering
+// a question
+type QuestionTheme struct {
+	Name      string
+	Responses int // Number of responses assigned to the theme
+	Summary   string
+}
+
+// QuestionEvidence is a response retrieved for answering a question
+type QuestionEvidence struct {
+	ID     string
+	Text   string
+	Themes []string
+}
+
+// AnswerQuestion answers an ad-hoc question about the analyzed responses,
+// grounded in the themes and the responses retrieved for the question.
+// Themes are cited as [T1], [T2], ... in the order given, responses by their
+// ID, e.g. [R12].
+func (c *Client) AnswerQuestion(question string, themes []QuestionTheme, evidence []QuestionEvidence, contextPrompt string) (string, error) {
+	prompt := fmt.Sprintf("Answer the question about the survey responses: %s\n\n", question)
+
+	prompt += "Themes of the analysis, each with a citation marker and its number of responses:\n"
+	for i, theme := range themes {
+		prompt += fmt.Sprintf("- [T%d] %s (%d responses)", i+1, theme.Name, theme.Responses)
+		if theme.Summary != "" {
+			prompt += ": " + strings.Join(strings.Fields(theme.Summary), " ")
+		}
+		prompt += "\n"
+	}
+
+	prompt += "\nResponses most relevant to the question, each with its ID and themes:\n"
+	for _, response := range evidence {
+		themes := "none"
+		if len(response.Themes) > 0 {
+			themes = strings.Join(response.Themes, ", ")
+		}
+		text := c.truncateResponse(TaskQuestion, response.Text, 500)
+		prompt += fmt.Sprintf("- [%s] (themes: %s) %s\n", response.ID, themes, c.quoteResponse(text))
+	}
+
+	prompt += "\nAnswer using only the themes and responses above. Support every statement with citation markers in square brackets, using the theme markers (e.g. [T2]) and response IDs (e.g. [R15]) listed above. "
+	prompt += "The responses are a selection relevant to the question, not all responses: state how common something is only from the theme counts. If the material does not answer the question, say so. "
+	prompt += c.dataNotice()
+
+	// Add language instructions if needed
+	if langInstructions := c.getLanguageInstructions(); langInstructions != "" {
+		prompt += " " + langInstructions
+	}
+
+	completion, err := c.completeTask(TaskQuestion, c.model, prompt, contextPrompt, DefaultMaxTokens)
+	if err != nil {
+		return "", fmt.Errorf("failed to answer question: %w", err)
+	}
+	return strings.TrimSpace(completion), nil
+}
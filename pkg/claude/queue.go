@@ -0,0 +1,245 @@
+package claude
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// QueuedRequest is a request of the queue, with its answer once drained
+type QueuedRequest struct {
+	Key        string      `json:"key"`
+	Request    RequestBody `json:"request"`
+	QueuedAt   time.Time   `json:"queued_at"`
+	Answer     string      `json:"answer,omitempty"`
+	AnsweredAt *time.Time  `json:"answered_at,omitempty"`
+}
+
+// Queue stores requests on disk, one file per request, so they can be sent
+// later and their answers survive interrupted connections
+type Queue struct {
+	logger  *logging.Logger
+	dir     string
+	mutex   sync.Mutex
+	entries map[string]*QueuedRequest
+}
+
+// OpenQueue opens the queue in the given directory, creating it if needed
+func OpenQueue(logger *logging.Logger, dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	queue := &Queue{logger: logger, dir: dir, entries: make(map[string]*QueuedRequest)}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queue directory: %w", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queued request: %w", err)
+		}
+		var entry QueuedRequest
+		if err := json.Unmarshal(data, &entry); err != nil {
+			logger.Warn("Skipping damaged queued request", "file", file, "error", err)
+			continue
+		}
+		queue.entries[queueFileName(entry.Key)] = &entry
+	}
+	return queue, nil
+}
+
+// Dir returns the directory of the queue
+func (q *Queue) Dir() string {
+	return q.dir
+}
+
+// Add queues a request unless it is queued already
+func (q *Queue) Add(key string, request RequestBody) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	name := queueFileName(key)
+	if _, ok := q.entries[name]; ok {
+		return nil
+	}
+	entry := &QueuedRequest{Key: key, Request: request, QueuedAt: time.Now()}
+	if err := q.write(name, entry); err != nil {
+		return err
+	}
+	q.entries[name] = entry
+	return nil
+}
+
+// Answer returns the drained answer of a request
+func (q *Queue) Answer(key string) (string, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	entry, ok := q.entries[queueFileName(key)]
+	if !ok || entry.AnsweredAt == nil {
+		return "", false
+	}
+	return entry.Answer, true
+}
+
+// Counts returns the number of queued and of answered requests
+func (q *Queue) Counts() (int, int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	answered := 0
+	for _, entry := range q.entries {
+		if entry.AnsweredAt != nil {
+			answered++
+		}
+	}
+	return len(q.entries), answered
+}
+
+// Pending returns the requests without an answer in the order they were queued
+func (q *Queue) Pending() []*QueuedRequest {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var pending []*QueuedRequest
+	for _, entry := range q.entries {
+		if entry.AnsweredAt == nil {
+			pending = append(pending, entry)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if !pending[i].QueuedAt.Equal(pending[j].QueuedAt) {
+			return pending[i].QueuedAt.Before(pending[j].QueuedAt)
+		}
+		return pending[i].Key < pending[j].Key
+	})
+	return pending
+}
+
+// SetAnswer stores the answer of a queued request
+func (q *Queue) SetAnswer(entry *QueuedRequest, answer string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	answered := *entry
+	answeredAt := time.Now()
+	answered.Answer = answer
+	answered.AnsweredAt = &answeredAt
+	name := queueFileName(entry.Key)
+	if err := q.write(name, &answered); err != nil {
+		return err
+	}
+	q.entries[name] = &answered
+	return nil
+}
+
+// Clear removes the queue with all requests and answers
+func (q *Queue) Clear() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if err := os.RemoveAll(q.dir); err != nil {
+		return fmt.Errorf("failed to remove queue directory: %w", err)
+	}
+	q.entries = make(map[string]*QueuedRequest)
+	return nil
+}
+
+// write writes an entry to its file, replacing it in one step so an
+// interruption leaves either the old or the new version
+func (q *Queue) write(name string, entry *QueuedRequest) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queued request: %w", err)
+	}
+	path := filepath.Join(q.dir, name)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write queued request: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write queued request: %w", err)
+	}
+	return nil
+}
+
+// queueFileName returns the file name of a request in the queue
+func queueFileName(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:]) + ".json"
+}
+
+// SetQueue answers requests from the queue. With record set, requests that
+// are not in the queue are added to it instead of being sent and answered
+// with an empty completion, which lets a run collect its requests offline.
+func (c *Client) SetQueue(queue *Queue, record bool) {
+	c.queue = queue
+	c.recordQueue = record
+}
+
+// DrainQueue sends the pending requests of the queue with the given number
+// of workers, storing each answer as soon as it arrives. It stops at the
+// first failed request and returns the number of answered requests.
+func (c *Client) DrainQueue(queue *Queue, workers int) (int, error) {
+	pending := queue.Pending()
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		answered int
+		errs     []string
+	)
+	next := make(chan *QueuedRequest)
+	for worker := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger := c.logger.Worker(fmt.Sprintf("worker %d", worker+1))
+			for entry := range next {
+				logger.Info("Sending queued request", "model", entry.Request.Model, "queued_at", entry.QueuedAt.Format(time.DateTime))
+				answer, err := c.send(cacheKeyTask(entry.Key), entry.Key, entry.Request, nil)
+				if err == nil {
+					err = queue.SetAnswer(entry, answer)
+				}
+
+				mutex.Lock()
+				if err != nil {
+					errs = append(errs, err.Error())
+				} else {
+					answered++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, entry := range pending {
+		mutex.Lock()
+		failed := len(errs) > 0
+		mutex.Unlock()
+		if failed {
+			break
+		}
+		next <- entry
+	}
+	close(next)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return answered, fmt.Errorf("failed to drain queue: %s", strings.Join(errs, "; "))
+	}
+	return answered, nil
+}
@@ -0,0 +1,115 @@
+package claude
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Token estimates of a theme matching request, used to derive throughput
+// settings from rate limits before any response has been read
+const (
+	matchingPromptTokens        = 1500 // Instructions and theme list
+	matchingInputTokensPerItem  = 100  // Tokens of an average response
+	matchingOutputTokensPerItem = 40   // Tokens of the themes assigned to a response
+
+	rateLimitHeadroom  = 0.8 // Share of the rate limits to use
+	MaxParallelWorkers = 16  // Most workers derived from rate limits or tuned automatically
+	defaultBatchSize   = 10
+	largeBatchSize     = 20 // Used when the limits allow less than a request per second
+)
+
+// RateLimits are the per-minute limits of an API account
+type RateLimits struct {
+	RequestsPerMinute     int
+	InputTokensPerMinute  int
+	OutputTokensPerMinute int
+}
+
+// RateLimitTiers are the limits of the Anthropic usage tiers for Sonnet
+// models, which are lower than or equal to those of the Haiku models
+var RateLimitTiers = map[int]RateLimits{
+	1: {RequestsPerMinute: 50, InputTokensPerMinute: 30000, OutputTokensPerMinute: 8000},
+	2: {RequestsPerMinute: 1000, InputTokensPerMinute: 450000, OutputTokensPerMinute: 90000},
+	3: {RequestsPerMinute: 2000, InputTokensPerMinute: 800000, OutputTokensPerMinute: 160000},
+	4: {RequestsPerMinute: 4000, InputTokensPerMinute: 2000000, OutputTokensPerMinute: 400000},
+}
+
+// Throughput holds the parallelism and pacing that stay within rate limits
+type Throughput struct {
+	BatchSize         int           // Responses per matching request
+	RequestsPerMinute int           // Matching requests per minute the limits allow
+	ParallelWorkers   int           // Workers sending requests at the same time
+	RateLimitDelay    time.Duration // Delay of each worker before a request
+}
+
+// Throughput derives the number of workers and the delay between their
+// requests that keep theme matching with the given batch size within the
+// limits. A batch size of 0 picks larger batches for low limits, as they
+// spend fewer tokens on the prompt per response. The delay assumes instant
+// responses, so the limits hold however fast the API answers.
+func (l RateLimits) Throughput(batchSize int) Throughput {
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+		if l.allowedRequests(batchSize) < 60 {
+			batchSize = largeBatchSize
+		}
+	}
+
+	allowed := l.allowedRequests(batchSize)
+	if math.IsInf(allowed, 1) {
+		return Throughput{BatchSize: batchSize, ParallelWorkers: MaxParallelWorkers}
+	}
+
+	perMinute := math.Max(1, math.Floor(allowed*rateLimitHeadroom))
+	// About one worker per request per second keeps the delays near a second
+	workers := int(math.Max(1, math.Min(MaxParallelWorkers, math.Floor(perMinute/60))))
+	return Throughput{
+		BatchSize:         batchSize,
+		RequestsPerMinute: int(perMinute),
+		ParallelWorkers:   workers,
+		RateLimitDelay:    time.Duration(float64(workers) * float64(time.Minute) / perMinute).Round(time.Millisecond),
+	}
+}
+
+// allowedRequests returns the matching requests per minute allowed by the
+// limits, infinite if there are none
+func (l RateLimits) allowedRequests(batchSize int) float64 {
+	allowed := math.Inf(1)
+	if l.RequestsPerMinute > 0 {
+		allowed = float64(l.RequestsPerMinute)
+	}
+	if l.InputTokensPerMinute > 0 {
+		allowed = math.Min(allowed, float64(l.InputTokensPerMinute)/float64(matchingPromptTokens+batchSize*matchingInputTokensPerItem))
+	}
+	if l.OutputTokensPerMinute > 0 {
+		allowed = math.Min(allowed, float64(l.OutputTokensPerMinute)/float64(batchSize*matchingOutputTokensPerItem))
+	}
+	return allowed
+}
+
+// parseRateLimits reads the rate limits the API reports in response headers
+func parseRateLimits(header http.Header) (RateLimits, bool) {
+	limit := func(name string) int {
+		value, err := strconv.Atoi(header.Get("anthropic-ratelimit-" + name + "-limit"))
+		if err != nil {
+			return 0
+		}
+		return value
+	}
+	limits := RateLimits{
+		RequestsPerMinute:     limit("requests"),
+		InputTokensPerMinute:  limit("input-tokens"),
+		OutputTokensPerMinute: limit("output-tokens"),
+	}
+	return limits, limits != RateLimits{}
+}
+
+// ObservedRateLimits returns the rate limits reported by the last API
+// response, if any
+func (c *Client) ObservedRateLimits() (RateLimits, bool) {
+	c.limitsMutex.Lock()
+	defer c.limitsMutex.Unlock()
+	return c.observedLimits, c.observedLimits != RateLimits{}
+}
@@ -0,0 +1,157 @@
+package claude
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    RateLimits
+		wantOK  bool
+	}{
+		{
+			name: "all limits",
+			headers: map[string]string{
+				"anthropic-ratelimit-requests-limit":      "50",
+				"anthropic-ratelimit-input-tokens-limit":  "30000",
+				"anthropic-ratelimit-output-tokens-limit": "8000",
+			},
+			want:   RateLimits{RequestsPerMinute: 50, InputTokensPerMinute: 30000, OutputTokensPerMinute: 8000},
+			wantOK: true,
+		},
+		{
+			name:    "requests only",
+			headers: map[string]string{"anthropic-ratelimit-requests-limit": "1000"},
+			want:    RateLimits{RequestsPerMinute: 1000},
+			wantOK:  true,
+		},
+		{
+			name: "invalid values are left out",
+			headers: map[string]string{
+				"anthropic-ratelimit-requests-limit":     "many",
+				"anthropic-ratelimit-input-tokens-limit": "450000",
+			},
+			want:   RateLimits{InputTokensPerMinute: 450000},
+			wantOK: true,
+		},
+		{
+			name:    "no limits",
+			headers: map[string]string{"anthropic-ratelimit-requests-remaining": "49"},
+			want:    RateLimits{},
+			wantOK:  false,
+		},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		for name, value := range test.headers {
+			header.Set(name, value)
+		}
+		got, ok := parseRateLimits(header)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.name, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestThroughput(t *testing.T) {
+	tests := []struct {
+		name      string
+		limits    RateLimits
+		batchSize int
+		want      Throughput
+	}{
+		{
+			name:   "no limits",
+			limits: RateLimits{},
+			want:   Throughput{BatchSize: 10, ParallelWorkers: 16},
+		},
+		{
+			name:   "tier 1 picks large batches",
+			limits: RateLimitTiers[1],
+			want:   Throughput{BatchSize: 20, RequestsPerMinute: 6, ParallelWorkers: 1, RateLimitDelay: 10 * time.Second},
+		},
+		{
+			name:   "tier 4 is limited by input tokens",
+			limits: RateLimitTiers[4],
+			want:   Throughput{BatchSize: 10, RequestsPerMinute: 640, ParallelWorkers: 10, RateLimitDelay: 938 * time.Millisecond},
+		},
+		{
+			name:      "configured batch size",
+			limits:    RateLimits{RequestsPerMinute: 120},
+			batchSize: 5,
+			want:      Throughput{BatchSize: 5, RequestsPerMinute: 96, ParallelWorkers: 1, RateLimitDelay: 625 * time.Millisecond},
+		},
+		{
+			name:   "workers are capped",
+			limits: RateLimits{RequestsPerMinute: 100000},
+			want:   Throughput{BatchSize: 10, RequestsPerMinute: 80000, ParallelWorkers: 16, RateLimitDelay: 12 * time.Millisecond},
+		},
+	}
+	for _, test := range tests {
+		if got := test.limits.Throughput(test.batchSize); got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+// serverTransport sends all requests to a test server
+type serverTransport struct {
+	server *url.URL
+}
+
+func (t serverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.server.Scheme
+	req.URL.Host = t.server.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// TestObservedRateLimitsConcurrently sends requests from several workers
+// while reading the observed rate limits; run with -race
+func TestObservedRateLimitsConcurrently(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("anthropic-ratelimit-requests-limit", "1000")
+		w.Header().Set("anthropic-ratelimit-input-tokens-limit", "450000")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":2}}`)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient("test-key", logging.NewLogger(false), nil, "", "")
+	client.httpClient = &http.Client{Transport: serverTransport{server: serverURL}}
+	client.SetRateLimitDelay(0)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				if _, err := client.GetCompletion(fmt.Sprintf("prompt %d.%d", worker, i), "", 10); err != nil {
+					t.Errorf("request failed: %v", err)
+				}
+				client.ObservedRateLimits()
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	want := RateLimits{RequestsPerMinute: 1000, InputTokensPerMinute: 450000}
+	if got, ok := client.ObservedRateLimits(); got != want || !ok {
+		t.Errorf("got %+v, %v, want %+v, true", got, ok, want)
+	}
+}
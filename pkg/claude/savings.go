@@ -0,0 +1,41 @@
+package claude
+
+// CacheSavings are the requests answered from the cache and the tokens and
+// cost they would have taken, estimated from the length of the prompt and of
+// the cached answer
+type CacheSavings struct {
+	Requests     int
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+}
+
+// estimateTokens estimates the number of tokens of a text, about four
+// characters per token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// recordCacheHit adds the estimated usage of a request answered from the
+// cache to the savings
+func (c *Client) recordCacheHit(model, prompt, systemPrompt, answer string) {
+	inputTokens := estimateTokens(prompt) + estimateTokens(systemPrompt)
+	outputTokens := estimateTokens(answer)
+	cost := CalculateCost(model, inputTokens, outputTokens)
+
+	c.savingsMutex.Lock()
+	defer c.savingsMutex.Unlock()
+	c.cacheSavings.Requests++
+	c.cacheSavings.InputTokens += inputTokens
+	c.cacheSavings.OutputTokens += outputTokens
+	c.cacheSavings.Cost += cost.Cost
+}
+
+// CacheSavings returns the requests of the run answered from the cache and
+// their estimated tokens and cost. Answers of the request queue are not
+// included, their cost was spent when the queue was drained.
+func (c *Client) CacheSavings() CacheSavings {
+	c.savingsMutex.Lock()
+	defer c.savingsMutex.Unlock()
+	return c.cacheSavings
+}
@@ -0,0 +1,203 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentiment labels of a response
+const (
+	SentimentPositive = "positive"
+	SentimentNeutral  = "neutral"
+	SentimentNegative = "negative"
+)
+
+// Intensities of a sentiment
+const (
+	MinSentimentIntensity = 1 // Mild
+	MaxSentimentIntensity = 3 // Strong
+)
+
+// sentimentToolName is the tool the model calls to record the sentiments of
+// a batch with structured output
+const sentimentToolName = "record_sentiments"
+
+// Sentiment is the sentiment of a response with its intensity from 1 (mild)
+// to 3 (strong)
+type Sentiment struct {
+	Label     string `yaml:"label" json:"label"`
+	Intensity int    `yaml:"intensity" json:"intensity"`
+}
+
+// Add counts a sentiment in the balance
+func (b *SentimentBalance) Add(sentiment Sentiment) {
+	switch sentiment.Label {
+	case SentimentPositive:
+		b.Positive++
+	case SentimentNeutral:
+		b.Neutral++
+	case SentimentNegative:
+		b.Negative++
+	}
+}
+
+// sentimentPattern matches a line of a sentiment answer, e.g.
+// "RESPONSE 3: NEGATIVE 2"
+var sentimentPattern = regexp.MustCompile(`(?i)^RESPONSE\s+(\d+)\s*:\s*(POSITIVE|NEUTRAL|NEGATIVE)\b\s*(\d)?`)
+
+// ClassifySentiments classifies the sentiment of a batch of responses. The
+// responses an answer leaves out get no sentiment.
+func (c *Client) ClassifySentiments(responses []string) ([]*Sentiment, error) {
+	prompt := "Classify the sentiment of each survey response as POSITIVE, NEUTRAL or NEGATIVE, with its intensity from 1 (mild) to 3 (strong).\n"
+	if c.structuredOutput {
+		prompt += "Record your answer by calling the " + sentimentToolName + " tool once with the sentiment of every response.\n\n"
+	} else {
+		prompt += "Format your answer as:\nRESPONSE 1: [POSITIVE, NEUTRAL or NEGATIVE] [intensity]\nRESPONSE 2: [POSITIVE, NEUTRAL or NEGATIVE] [intensity]\n...\n\n"
+		prompt += "A response without an opinion is NEUTRAL 1 (e.g. \"RESPONSE 3: NEUTRAL 1\").\n\n"
+	}
+	for i, response := range responses {
+		prompt += fmt.Sprintf("RESPONSE %d: %s\n\n", i+1, c.quoteResponse(c.truncateResponse(TaskSentiment, response, 300)))
+	}
+	prompt += c.dataNotice()
+
+	systemPrompt := "You are an analyst classifying the sentiment of survey responses."
+	if c.structuredOutput {
+		var sentiments []*Sentiment
+		err := c.completeStructured(TaskSentiment, prompt, systemPrompt, DefaultTemperature, "", sentimentTool(len(responses)), structuredRetries, nil, func(answer string) error {
+			parsed, err := parseToolSentiments(answer, len(responses))
+			if err == nil {
+				sentiments = parsed
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to classify sentiments: %w", err)
+		}
+		return sentiments, nil
+	}
+
+	completion, err := c.completeTask(TaskSentiment, c.model, prompt, systemPrompt, DefaultMaxTokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify sentiments: %w", err)
+	}
+	parseStarted := time.Now()
+	defer c.recordParse(TaskSentiment, parseStarted, nil)
+	return c.parseSentiments(completion, len(responses)), nil
+}
+
+// parseSentiments parses the text answer of ClassifySentiments. Intensities
+// outside 1 to 3 are clamped, a missing one counts as mild.
+func (c *Client) parseSentiments(answer string, responseCount int) []*Sentiment {
+	sentiments := make([]*Sentiment, responseCount)
+	for _, line := range strings.Split(answer, "\n") {
+		match := sentimentPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(match[1])
+		if number < 1 || number > responseCount {
+			continue
+		}
+		intensity := MinSentimentIntensity
+		if match[3] != "" {
+			intensity, _ = strconv.Atoi(match[3])
+		}
+		intensity = min(max(intensity, MinSentimentIntensity), MaxSentimentIntensity)
+		sentiments[number-1] = &Sentiment{Label: strings.ToLower(match[2]), Intensity: intensity}
+	}
+
+	missing := 0
+	for _, sentiment := range sentiments {
+		if sentiment == nil {
+			missing++
+		}
+	}
+	if missing > 0 {
+		c.logger.Warn("Sentiment answer left out responses, leaving them without sentiment", "missing", missing, "batch_size", responseCount)
+	}
+	return sentiments
+}
+
+// sentimentTool returns the tool for recording the sentiments of a batch of
+// responses
+func sentimentTool(responseCount int) *Tool {
+	return &Tool{
+		Name:        sentimentToolName,
+		Description: "Record the sentiment of each survey response",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"sentiments": map[string]interface{}{
+					"type":     "array",
+					"minItems": responseCount,
+					"maxItems": responseCount,
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"response": map[string]interface{}{
+								"type":        "integer",
+								"minimum":     1,
+								"maximum":     responseCount,
+								"description": "Number of the response",
+							},
+							"sentiment": map[string]interface{}{
+								"type": "string",
+								"enum": []string{SentimentPositive, SentimentNeutral, SentimentNegative},
+							},
+							"intensity": map[string]interface{}{
+								"type":        "integer",
+								"minimum":     MinSentimentIntensity,
+								"maximum":     MaxSentimentIntensity,
+								"description": "Intensity of the sentiment from 1 (mild) to 3 (strong)",
+							},
+						},
+						"required": []string{"response", "sentiment", "intensity"},
+					},
+				},
+			},
+			"required": []string{"sentiments"},
+		},
+	}
+}
+
+// toolSentiments is the input of a call of the sentiment tool
+type toolSentiments struct {
+	Sentiments []struct {
+		Response  int    `json:"response"`
+		Sentiment string `json:"sentiment"`
+		Intensity int    `json:"intensity"`
+	} `json:"sentiments"`
+}
+
+// parseToolSentiments parses the sentiment tool input of an answer,
+// rejecting an answer that leaves out a response or has unknown values
+func parseToolSentiments(answer string, responseCount int) ([]*Sentiment, error) {
+	var input toolSentiments
+	if err := json.Unmarshal([]byte(answer), &input); err != nil {
+		return nil, fmt.Errorf("answer is not a call of the %s tool: %w", sentimentToolName, err)
+	}
+	sentiments := make([]*Sentiment, responseCount)
+	for _, entry := range input.Sentiments {
+		if entry.Response < 1 || entry.Response > responseCount {
+			return nil, fmt.Errorf("response number %d is out of range 1-%d", entry.Response, responseCount)
+		}
+		label := strings.ToLower(entry.Sentiment)
+		if label != SentimentPositive && label != SentimentNeutral && label != SentimentNegative {
+			return nil, fmt.Errorf("unknown sentiment %q of response %d", entry.Sentiment, entry.Response)
+		}
+		if entry.Intensity < MinSentimentIntensity || entry.Intensity > MaxSentimentIntensity {
+			return nil, fmt.Errorf("intensity %d of response %d is out of range %d-%d", entry.Intensity, entry.Response, MinSentimentIntensity, MaxSentimentIntensity)
+		}
+		sentiments[entry.Response-1] = &Sentiment{Label: label, Intensity: entry.Intensity}
+	}
+	for i, sentiment := range sentiments {
+		if sentiment == nil {
+			return nil, fmt.Errorf("response %d has no sentiment", i+1)
+		}
+	}
+	return sentiments, nil
+}
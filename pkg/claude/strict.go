@@ -0,0 +1,168 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// classificationToolName is the tool the model calls to classify a batch in
+// strict mode
+const classificationToolName = "classify_responses"
+
+// strictRetries is the number of times a batch is classified again after
+// an answer that does not map onto the theme list
+const strictRetries = 3
+
+// Tool is a tool the model can call, with the JSON schema of its input
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// ToolChoice forces the model to call a tool
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// SetStrictThemes enables or disables the strict mode, in which batches are
+// classified by calling a tool whose schema only allows the theme numbers of
+// the theme list. Answers outside the list are rejected and retried.
+func (c *Client) SetStrictThemes(strict bool) {
+	c.strictThemes = strict
+}
+
+// classificationTool returns the tool for classifying a batch of responses,
+// allowing only the numbers of the themes and responses as values
+func (c *Client) classificationTool(themeCount, responseCount int) *Tool {
+	themeNumbers := make([]int, themeCount)
+	for i := range themeNumbers {
+		themeNumbers[i] = i + 1
+	}
+	properties := map[string]interface{}{
+		"response": map[string]interface{}{
+			"type":        "integer",
+			"minimum":     1,
+			"maximum":     responseCount,
+			"description": "Number of the response",
+		},
+		"themes": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "integer", "enum": themeNumbers},
+			"uniqueItems": true,
+			"description": "Numbers of the themes the response relates to, empty if none applies",
+		},
+	}
+	if c.proposeNewThemes {
+		properties["new_theme"] = map[string]interface{}{
+			"type":        "string",
+			"description": "Short name of a new theme for a response none of the themes applies to",
+		}
+	}
+	if c.matchReasons {
+		properties["why"] = map[string]interface{}{
+			"type":        "string",
+			"description": "One-sentence justification, referring to what the response says",
+		}
+	}
+	return &Tool{
+		Name:        classificationToolName,
+		Description: "Record the themes of each survey response",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"classifications": map[string]interface{}{
+					"type":     "array",
+					"minItems": responseCount,
+					"maxItems": responseCount,
+					"items": map[string]interface{}{
+						"type":       "object",
+						"properties": properties,
+						"required":   []string{"response", "themes"},
+					},
+				},
+			},
+			"required": []string{"classifications"},
+		},
+	}
+}
+
+// toolClassifications is the input of a call of the classification tool
+type toolClassifications struct {
+	Classifications []struct {
+		Response int    `json:"response"`
+		Themes   []int  `json:"themes"`
+		NewTheme string `json:"new_theme"`
+		Why      string `json:"why"`
+	} `json:"classifications"`
+}
+
+// parseToolResults parses the classification tool input of a batch answer.
+// Unlike the text answers, an answer that does not map onto the theme list
+// or leaves out a response is rejected with an error.
+func (c *Client) parseToolResults(answer string, responseCount int, themes []string) ([]MatchResult, error) {
+	var input toolClassifications
+	if err := json.Unmarshal([]byte(answer), &input); err != nil {
+		return nil, fmt.Errorf("answer is not a call of the %s tool: %w", classificationToolName, err)
+	}
+
+	results := make([]MatchResult, responseCount)
+	answered := make([]bool, responseCount)
+	for _, classification := range input.Classifications {
+		number := classification.Response
+		if number < 1 || number > responseCount {
+			return nil, fmt.Errorf("response number %d is out of range 1-%d", number, responseCount)
+		}
+		if answered[number-1] {
+			return nil, fmt.Errorf("response %d is classified twice", number)
+		}
+		matched := []string{}
+		for _, themeNumber := range classification.Themes {
+			if themeNumber < 1 || themeNumber > len(themes) {
+				return nil, fmt.Errorf("theme number %d of response %d is out of range 1-%d", themeNumber, number, len(themes))
+			}
+			if theme := themes[themeNumber-1]; !slices.Contains(matched, theme) {
+				matched = append(matched, theme)
+			}
+		}
+		result := MatchResult{Themes: matched, Reason: classification.Why, Agreement: 1}
+		if len(matched) == 0 && c.proposeNewThemes {
+			result.ProposedTheme = classification.NewTheme
+		}
+		results[number-1] = result
+		answered[number-1] = true
+	}
+	for i, ok := range answered {
+		if !ok {
+			return nil, fmt.Errorf("response %d is not classified", i+1)
+		}
+	}
+	return results, nil
+}
+
+// classifyStrict classifies a batch with the classification tool. An answer
+// that does not map onto the theme list is rejected and the batch is
+// classified again, up to strictRetries times in strict mode and once with
+// structured output. Extended thinking cannot be combined with a forced tool
+// call and is not used.
+func (c *Client) classifyStrict(prompt, contextPrompt, cacheVariant string, responseCount int, themes []string, stats *RequestStats) ([]MatchResult, error) {
+	tool := c.classificationTool(len(themes), responseCount)
+	retries := structuredRetries
+	if c.strictThemes {
+		retries = strictRetries
+	}
+	results := make([]MatchResult, responseCount)
+	err := c.completeStructured(TaskMatching, prompt, contextPrompt, c.matchingTemperature, cacheVariant, tool, retries, stats, func(answer string) error {
+		parsed, err := c.parseToolResults(answer, responseCount, themes)
+		if err == nil {
+			results = parsed
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to match responses to themes in batch: %w", err)
+	}
+	return results, nil
+}
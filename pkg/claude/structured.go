@@ -0,0 +1,114 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// themeSummaryToolName is the tool the model calls to record a theme summary
+// with structured output
+const themeSummaryToolName = "record_theme_summary"
+
+// structuredRetries is the number of times a request is sent again after a
+// malformed answer with structured output
+const structuredRetries = 1
+
+// SetStructuredOutput enables or disables structured output, in which batches
+// are classified and themes summarized by calling a tool with a JSON schema
+// instead of answering in a text format. Malformed answers are rejected and
+// asked for once more.
+func (c *Client) SetStructuredOutput(structured bool) {
+	c.structuredOutput = structured
+}
+
+// classifiesWithTool reports whether batches are classified by calling the
+// classification tool
+func (c *Client) classifiesWithTool() bool {
+	return c.strictThemes || c.structuredOutput
+}
+
+// completeStructured gets an answer by calling the tool and hands it to
+// parse. An answer parse rejects is asked for again, each retry cached as a
+// variant of its own, up to retries times.
+func (c *Client) completeStructured(task string, prompt string, systemPrompt string, temperature float64, cacheVariant string, tool *Tool, retries int, stats *RequestStats, parse func(answer string) error) error {
+	for attempt := 0; ; attempt++ {
+		variant := cacheVariant
+		if attempt > 0 {
+			variant += fmt.Sprintf("retry-%d", attempt)
+		}
+		answer, err := c.complete(task, c.model, prompt, systemPrompt, DefaultMaxTokens, temperature, variant, 0, tool, stats)
+		if err != nil {
+			return err
+		}
+		if c.recordQueue {
+			// The request was queued, there is no answer to check yet
+			return nil
+		}
+		parseStarted := time.Now()
+		err = parse(answer)
+		c.recordParse(task, parseStarted, stats)
+		if err == nil {
+			return nil
+		}
+		if attempt == retries {
+			return fmt.Errorf("answer was rejected %d times: %w", attempt+1, err)
+		}
+		c.logger.Warn("Rejected the answer of the tool call, asking again", "tool", tool.Name, "attempt", attempt+1, "error", err)
+	}
+}
+
+// themeSummaryTool returns the tool for recording a theme summary with its
+// unique ideas
+func themeSummaryTool() *Tool {
+	return &Tool{
+		Name:        themeSummaryToolName,
+		Description: "Record the summary and the unique ideas of the responses of a theme",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"summary": map[string]interface{}{
+					"type":        "string",
+					"description": "Summary of the responses",
+				},
+				"ideas": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Unique ideas or suggestions of the responses, one sentence each",
+				},
+			},
+			"required": []string{"summary", "ideas"},
+		},
+	}
+}
+
+// toolThemeSummary is the input of a call of the theme summary tool
+type toolThemeSummary struct {
+	Summary string   `json:"summary"`
+	Ideas   []string `json:"ideas"`
+}
+
+// parseToolThemeSummary parses the theme summary tool input of an answer and
+// returns it in the text format of theme summaries, so summaries are read
+// the same way with and without structured output
+func parseToolThemeSummary(answer string) (string, error) {
+	var input toolThemeSummary
+	if err := json.Unmarshal([]byte(answer), &input); err != nil {
+		return "", fmt.Errorf("answer is not a call of the %s tool: %w", themeSummaryToolName, err)
+	}
+	summary := strings.TrimSpace(input.Summary)
+	if summary == "" {
+		return "", fmt.Errorf("summary is empty")
+	}
+
+	var b strings.Builder
+	b.WriteString("SUMMARY:\n" + summary + "\n\nUNIQUE IDEAS:\n")
+	for _, idea := range input.Ideas {
+		// An idea is a line of the text format
+		if idea = strings.Join(strings.Fields(idea), " "); idea != "" {
+			b.WriteString("IDEA: " + idea + "\n")
+		}
+	}
+	return b.String(), nil
+}
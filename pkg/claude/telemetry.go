@@ -0,0 +1,132 @@
+package claude
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TaskTelemetry is the time spent on the requests of one task of a run,
+// showing whether it went into the API, rate limiting or parsing
+type TaskTelemetry struct {
+	Task         string  `yaml:"task"`
+	Requests     int     `yaml:"requests"`             // Requests sent to the API
+	CacheHits    int     `yaml:"cache_hits,omitempty"` // Answers taken from the cache or the request queue
+	Retries      int     `yaml:"retries,omitempty"`
+	InputTokens  int     `yaml:"input_tokens"`
+	OutputTokens int     `yaml:"output_tokens"`
+	APISeconds   float64 `yaml:"api_seconds"`   // Waiting for API answers, summed over parallel requests
+	WaitSeconds  float64 `yaml:"wait_seconds"`  // Rate limit delays and backoff
+	ParseSeconds float64 `yaml:"parse_seconds"` // Decoding and parsing the answers
+	WallSeconds  float64 `yaml:"wall_seconds"`  // From the start of the first request to the end of the last
+
+	first time.Time
+	last  time.Time
+}
+
+// RequestStats accumulates the requests made for a single call, such as the
+// matching of one batch
+type RequestStats struct {
+	Requests     int
+	CacheHits    int
+	Retries      int
+	InputTokens  int
+	OutputTokens int
+	Latency      time.Duration // Waiting for API answers
+	Wait         time.Duration // Rate limit delays and backoff
+	Parse        time.Duration // Decoding and parsing the answers
+}
+
+// Tokens returns the input and output tokens of the requests
+func (s RequestStats) Tokens() int {
+	return s.InputTokens + s.OutputTokens
+}
+
+// add adds the requests of other to s
+func (s *RequestStats) add(other RequestStats) {
+	s.Requests += other.Requests
+	s.CacheHits += other.CacheHits
+	s.Retries += other.Retries
+	s.InputTokens += other.InputTokens
+	s.OutputTokens += other.OutputTokens
+	s.Latency += other.Latency
+	s.Wait += other.Wait
+	s.Parse += other.Parse
+}
+
+// telemetry accounts for the requests of a client per task
+type telemetry struct {
+	mutex sync.Mutex
+	tasks map[string]*TaskTelemetry
+	order []string // Tasks in the order of their first request
+}
+
+// newTelemetry creates an empty telemetry
+func newTelemetry() *telemetry {
+	return &telemetry{tasks: make(map[string]*TaskTelemetry)}
+}
+
+// record adds the requests of a call that started at the given time to the
+// task and to stats, if it is not nil
+func (t *telemetry) record(task string, started time.Time, sample RequestStats, stats *RequestStats) {
+	if stats != nil {
+		stats.add(sample)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	entry, ok := t.tasks[task]
+	if !ok {
+		entry = &TaskTelemetry{Task: task, first: started}
+		t.tasks[task] = entry
+		t.order = append(t.order, task)
+	}
+	entry.Requests += sample.Requests
+	entry.CacheHits += sample.CacheHits
+	entry.Retries += sample.Retries
+	entry.InputTokens += sample.InputTokens
+	entry.OutputTokens += sample.OutputTokens
+	entry.APISeconds += sample.Latency.Seconds()
+	entry.WaitSeconds += sample.Wait.Seconds()
+	entry.ParseSeconds += sample.Parse.Seconds()
+	if started.Before(entry.first) {
+		entry.first = started
+	}
+	if now := time.Now(); now.After(entry.last) {
+		entry.last = now
+	}
+	entry.WallSeconds = entry.last.Sub(entry.first).Seconds()
+}
+
+// recordParse adds the time spent parsing an answer of the task
+func (c *Client) recordParse(task string, started time.Time, stats *RequestStats) {
+	c.telemetry.record(task, started, RequestStats{Parse: time.Since(started)}, stats)
+}
+
+// Telemetry returns the requests and timing per task, in the order the
+// tasks were first used
+func (c *Client) Telemetry() []TaskTelemetry {
+	c.telemetry.mutex.Lock()
+	defer c.telemetry.mutex.Unlock()
+
+	milliseconds := func(seconds float64) float64 {
+		return math.Round(seconds*1000) / 1000
+	}
+	tasks := make([]TaskTelemetry, len(c.telemetry.order))
+	for i, task := range c.telemetry.order {
+		tasks[i] = *c.telemetry.tasks[task]
+		tasks[i].APISeconds = milliseconds(tasks[i].APISeconds)
+		tasks[i].WaitSeconds = milliseconds(tasks[i].WaitSeconds)
+		tasks[i].ParseSeconds = milliseconds(tasks[i].ParseSeconds)
+		tasks[i].WallSeconds = milliseconds(tasks[i].WallSeconds)
+	}
+	return tasks
+}
+
+// cacheKeyTask returns the task of a cache key, which starts with the task
+// and the prompt version
+func cacheKeyTask(cacheKey string) string {
+	task, _, _ := strings.Cut(cacheKey, "@")
+	return task
+}
@@ -0,0 +1,61 @@
+package claude
+
+import (
+	"unicode/utf8"
+)
+
+// truncationMarker ends a response that was shortened for a prompt
+const truncationMarker = "..."
+
+// Truncation records that a response was shortened for the prompts of a
+// task, so reviewers can tell whether its classification was based on
+// partial text. Lengths are in characters.
+type Truncation struct {
+	Task     string `yaml:"task"`
+	Original int    `yaml:"original_length"`
+	Sent     int    `yaml:"sent_length"` // Without the marker
+}
+
+// truncateResponse shortens a response longer than limit bytes for the
+// prompts of a task. The cut backs off to the start of a character, so no
+// character is split, and the marker is appended. The truncation is
+// recorded for the audit log.
+func (c *Client) truncateResponse(task string, response string, limit int) string {
+	if len(response) <= limit {
+		return response
+	}
+	cut := limit - len(truncationMarker)
+	for cut > 0 && !utf8.RuneStart(response[cut]) {
+		cut--
+	}
+	kept := response[:cut]
+	c.recordTruncation(response, Truncation{
+		Task:     task,
+		Original: utf8.RuneCountInString(response),
+		Sent:     utf8.RuneCountInString(kept),
+	})
+	return kept + truncationMarker
+}
+
+// recordTruncation remembers the truncation of a response, once per task
+func (c *Client) recordTruncation(response string, truncation Truncation) {
+	c.promptMutex.Lock()
+	defer c.promptMutex.Unlock()
+	if c.truncations == nil {
+		c.truncations = make(map[string][]Truncation)
+	}
+	for _, seen := range c.truncations[response] {
+		if seen == truncation {
+			return
+		}
+	}
+	c.truncations[response] = append(c.truncations[response], truncation)
+}
+
+// Truncations returns how the response was shortened in the prompts of this
+// client so far, in the order of the tasks
+func (c *Client) Truncations(response string) []Truncation {
+	c.promptMutex.Lock()
+	defer c.promptMutex.Unlock()
+	return append([]Truncation(nil), c.truncations[response]...)
+}
@@ -0,0 +1,117 @@
+package cluster
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/oetiker/response-analyzer/pkg/embedding"
+)
+
+// DefaultIterations is the maximum number of k-means iterations
+const DefaultIterations = 50
+
+// seed makes clustering deterministic, so reruns over the same responses
+// produce the same clusters (and hit the completion cache when labelling)
+const seed = 42
+
+// KMeans partitions the vectors into k clusters using spherical k-means
+// (cosine similarity) with k-means++ seeding. It returns the cluster index of
+// each vector. k is reduced to the number of vectors if necessary.
+func KMeans(vectors [][]float32, k int, iterations int) []int {
+	assignments := make([]int, len(vectors))
+	if len(vectors) == 0 || k <= 1 {
+		return assignments
+	}
+	if k > len(vectors) {
+		k = len(vectors)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	centroids := seedCentroids(vectors, k, rng)
+
+	for iteration := 0; iteration < iterations; iteration++ {
+		// Assign each vector to its most similar centroid
+		changed := false
+		for i, vector := range vectors {
+			best := nearestCentroid(vector, centroids)
+			if iteration == 0 || best != assignments[i] {
+				changed = true
+			}
+			assignments[i] = best
+		}
+		if !changed {
+			break
+		}
+
+		// Recompute the centroids as the normalized mean of their members
+		dimensions := len(vectors[0])
+		sums := make([][]float32, k)
+		counts := make([]int, k)
+		for c := range sums {
+			sums[c] = make([]float32, dimensions)
+		}
+		for i, vector := range vectors {
+			c := assignments[i]
+			counts[c]++
+			for d, v := range vector {
+				sums[c][d] += v
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				// Keep the previous centroid of an empty cluster
+				continue
+			}
+			embedding.Normalize(sums[c])
+			centroids[c] = sums[c]
+		}
+	}
+
+	return assignments
+}
+
+// seedCentroids picks k initial centroids using k-means++ seeding
+func seedCentroids(vectors [][]float32, k int, rng *rand.Rand) [][]float32 {
+	centroids := [][]float32{vectors[rng.Intn(len(vectors))]}
+	distances := make([]float64, len(vectors))
+
+	for len(centroids) < k {
+		total := 0.0
+		for i, vector := range vectors {
+			// Cosine distance to the closest centroid so far, squared
+			distance := 1 - embedding.CosineSimilarity(vector, centroids[nearestCentroid(vector, centroids)])
+			distances[i] = math.Max(distance, 0) * math.Max(distance, 0)
+			total += distances[i]
+		}
+		if total == 0 {
+			// All remaining vectors coincide with a centroid
+			centroids = append(centroids, vectors[rng.Intn(len(vectors))])
+			continue
+		}
+
+		target := rng.Float64() * total
+		chosen := len(vectors) - 1
+		for i, distance := range distances {
+			target -= distance
+			if target <= 0 {
+				chosen = i
+				break
+			}
+		}
+		centroids = append(centroids, vectors[chosen])
+	}
+	return centroids
+}
+
+// nearestCentroid returns the index of the centroid most similar to the vector
+func nearestCentroid(vector []float32, centroids [][]float32) int {
+	best := 0
+	bestSimilarity := math.Inf(-1)
+	for c, centroid := range centroids {
+		if similarity := embedding.CosineSimilarity(vector, centroid); similarity > bestSimilarity {
+			best = c
+			bestSimilarity = similarity
+		}
+	}
+	return best
+}
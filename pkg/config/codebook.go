@@ -0,0 +1,307 @@
+package config
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"gopkg.in/yaml.v3"
+)
+
+// CodebookEntry is a theme of a codebook with its description
+type CodebookEntry struct {
+	Theme             string `yaml:"theme"`
+	Description       string `yaml:"description,omitempty"`
+	InclusionCriteria string `yaml:"inclusion_criteria,omitempty"`
+}
+
+var (
+	// markdownHeading is a heading line of a Markdown codebook
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+	// markdownListItem is a top-level list item naming a theme, optionally
+	// followed by its description after a colon or dash
+	markdownListItem = regexp.MustCompile(`^[-*+]\s+(?:\*\*(.+?)\*\*|([^:–—]+?))\s*(?:[:–—]\s*(.*))?$`)
+	// inclusionLabel starts the inclusion criteria of a theme
+	inclusionLabel = regexp.MustCompile(`(?i)^\**(include if|include|inclusion criteria|einschlusskriterien|einschliessen wenn|einschließen wenn|critères d'inclusion|criteri di inclusione)\**\s*:\**\s*`)
+)
+
+// LoadCodebook reads the themes and their descriptions from a codebook
+// document. YAML (.yaml, .yml), Markdown (.md, .markdown, .txt) and Word
+// (.docx) files are supported.
+func LoadCodebook(path string) ([]CodebookEntry, error) {
+	var entries []CodebookEntry
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		entries, err = loadYAMLCodebook(path)
+	case ".md", ".markdown", ".txt":
+		var data []byte
+		data, err = os.ReadFile(path)
+		if err == nil {
+			entries = parseMarkdownCodebook(string(data))
+		}
+	case ".docx":
+		var text string
+		text, err = docxMarkdown(path)
+		if err == nil {
+			entries = parseMarkdownCodebook(text)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported codebook format %s, expected .yaml, .md or .docx", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read codebook %s: %w", path, err)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("codebook %s contains no themes", path)
+	}
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if seen[entry.Theme] {
+			return nil, fmt.Errorf("codebook %s contains the theme %q more than once", path, entry.Theme)
+		}
+		seen[entry.Theme] = true
+	}
+	return entries, nil
+}
+
+// loadYAMLCodebook reads a YAML codebook, either a list of entries or a
+// mapping with the entries under themes. The themes file written by the tool
+// is accepted as well.
+func loadYAMLCodebook(path string) ([]CodebookEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	root := document.Content[0]
+
+	var list *yaml.Node
+	var descriptions map[string]claude.ThemeDescription
+	switch root.Kind {
+	case yaml.SequenceNode:
+		list = root
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			switch root.Content[i].Value {
+			case "themes":
+				list = root.Content[i+1]
+			case "theme_descriptions":
+				if err := root.Content[i+1].Decode(&descriptions); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("expected a list of themes")
+	}
+
+	entries := make([]CodebookEntry, 0, len(list.Content))
+	for _, item := range list.Content {
+		var entry CodebookEntry
+		if item.Kind == yaml.ScalarNode {
+			entry.Theme = item.Value
+		} else if err := item.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", item.Line, err)
+		}
+		entry.Theme = strings.TrimSpace(entry.Theme)
+		if entry.Theme == "" {
+			return nil, fmt.Errorf("line %d: theme without a name", item.Line)
+		}
+		if description, ok := descriptions[entry.Theme]; ok && entry.Description == "" {
+			entry.Description = description.Description
+			entry.InclusionCriteria = description.InclusionCriteria
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseMarkdownCodebook reads the themes of a Markdown codebook. Headings
+// without sub-headings are themes, the text below them their description;
+// a paragraph starting with "Include:" holds the inclusion criteria. A
+// codebook without headings lists a theme per top-level list item, e.g.
+// "- **Pricing**: Prices and discounts".
+func parseMarkdownCodebook(text string) []CodebookEntry {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	// Find the headings and whether a deeper one follows each
+	type heading struct {
+		line  int
+		level int
+		title string
+	}
+	var headings []heading
+	for i, line := range lines {
+		if match := markdownHeading.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			headings = append(headings, heading{line: i, level: len(match[1]), title: strings.TrimSpace(match[2])})
+		}
+	}
+	if len(headings) == 0 {
+		return parseMarkdownList(lines)
+	}
+
+	var entries []CodebookEntry
+	for i, h := range headings {
+		end := len(lines)
+		if i+1 < len(headings) {
+			end = headings[i+1].line
+			if headings[i+1].level > h.level {
+				continue // A section grouping themes
+			}
+		}
+		entry := CodebookEntry{Theme: h.title}
+		var description []string
+		for _, line := range lines[h.line+1 : end] {
+			line = strings.TrimSpace(line)
+			if criteria := inclusionLabel.ReplaceAllString(line, ""); criteria != line {
+				entry.InclusionCriteria = strings.TrimSpace(criteria)
+				continue
+			}
+			if line != "" {
+				description = append(description, line)
+			}
+		}
+		entry.Description = strings.Join(description, " ")
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// parseMarkdownList reads a theme from each top-level list item
+func parseMarkdownList(lines []string) []CodebookEntry {
+	var entries []CodebookEntry
+	for _, line := range lines {
+		match := markdownListItem.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		theme := strings.TrimSpace(match[1] + match[2])
+		if theme == "" {
+			continue
+		}
+		entries = append(entries, CodebookEntry{Theme: theme, Description: strings.TrimSpace(match[3])})
+	}
+	return entries
+}
+
+// docxMarkdown converts the paragraphs of a Word document to Markdown lines,
+// turning headings into Markdown headings and list paragraphs into list
+// items
+func docxMarkdown(path string) (string, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	var document []byte
+	for _, file := range archive.File {
+		if file.Name != "word/document.xml" {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		document, err = io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	if document == nil {
+		return "", fmt.Errorf("not a Word document, word/document.xml is missing")
+	}
+
+	var markdown strings.Builder
+	var paragraph strings.Builder
+	prefix := ""
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "p":
+				paragraph.Reset()
+				prefix = ""
+			case "pStyle":
+				prefix = headingPrefix(attribute(element, "val"))
+			case "numPr":
+				if prefix == "" {
+					prefix = "- "
+				}
+			case "t":
+				inText = true
+			case "tab":
+				paragraph.WriteString(" ")
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "p":
+				if text := strings.TrimSpace(paragraph.String()); text != "" {
+					markdown.WriteString(prefix + text + "\n")
+				}
+				markdown.WriteString("\n")
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(element)
+			}
+		}
+	}
+	return markdown.String(), nil
+}
+
+// headingPrefix returns the Markdown prefix of a Word paragraph style,
+// "## " for the style Heading2 (Überschrift2, Titre2, ...) and the empty
+// string for other styles
+func headingPrefix(style string) string {
+	lower := strings.ToLower(style)
+	for _, name := range []string{"heading", "berschrift", "titre", "titolo"} {
+		index := strings.Index(lower, name)
+		if index < 0 {
+			continue
+		}
+		level := strings.TrimSpace(lower[index+len(name):])
+		if len(level) == 1 && level[0] >= '1' && level[0] <= '6' {
+			return strings.Repeat("#", int(level[0]-'0')) + " "
+		}
+	}
+	return ""
+}
+
+// attribute returns the value of the attribute with the given local name
+func attribute(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/llm"
 	"gopkg.in/yaml.v3"
 )
 
@@ -11,53 +14,405 @@ import (
 type Config struct {
 	// Excel file configuration
 	ExcelFilePath  string `yaml:"excel_file_path"`
+	InputFormat    string `yaml:"input_format,omitempty"` // xlsx or csv, detected from the extension of excel_file_path if empty
 	ResponseColumn string `yaml:"response_column"`
+	// Several columns with open-ended questions analyzed in one run, each
+	// with its own themes and state, instead of response_column
+	ResponseColumns []string `yaml:"response_columns,omitempty"`
+	ColumnTitle     string   `yaml:"column_title,omitempty"` // Question the responses answer, defaults to the header cell of the response column
+
+	// Helpdesk the import subcommand fetches ticket comments from into the
+	// Excel file
+	HelpdeskSource *HelpdeskSource `yaml:"helpdesk_source,omitempty"`
+
+	// Interview or focus group transcripts the import subcommand segments
+	// into the Excel file
+	TranscriptSource *TranscriptSource `yaml:"transcript_source,omitempty"`
+
+	// Cleaning of the response texts before they are hashed and analyzed
+	Preprocessing       []string          `yaml:"preprocessing,omitempty"`        // Steps in the order they are applied
+	SpellingCorrections map[string]string `yaml:"spelling_corrections,omitempty"` // Misspellings replaced by the spellfix step
+	BoilerplatePatterns []string          `yaml:"boilerplate_patterns,omitempty"` // Regular expressions removed by the strip_email step
+
+	// Splitting of long responses, such as documents, into segments analyzed
+	// as responses of their own
+	Segmentation          string  `yaml:"segmentation,omitempty"`            // paragraph, sentence or semantic
+	SegmentationMinLength int     `yaml:"segmentation_min_length,omitempty"` // Only split responses of at least this many characters
+	SegmentationThreshold float64 `yaml:"segmentation_threshold,omitempty"`  // Similarity below which a semantic segment ends
+
+	// Theme frequencies over time
+	TimestampColumn    string `yaml:"timestamp_column,omitempty"`     // Column with the submission time of the responses
+	TimeSeriesInterval string `yaml:"time_series_interval,omitempty"` // week or month
+	RollingWindows     []int  `yaml:"rolling_windows,omitempty"`      // Lengths in days of windows ending at the latest response, e.g. 30 and 90
+
+	// Numeric ratings the themes are correlated with
+	RatingColumn string       `yaml:"rating_column,omitempty"` // Column with a score such as NPS or satisfaction
+	RatingBands  []RatingBand `yaml:"rating_bands,omitempty"`  // Ranges of the score, one band per value if empty
 
 	// Claude API configuration
 	ClaudeAPIKey  string `yaml:"claude_api_key"`
 	ClaudeModel   string `yaml:"claude_model,omitempty"`
 	ContextPrompt string `yaml:"context_prompt"`
+	UsageTag      string `yaml:"usage_tag,omitempty"`   // Sent as metadata.user_id for usage attribution
 	SummaryLength int    `yaml:"global_summary_length"` // Renamed from summary_length for clarity
 
+	// Provider of the language model: anthropic (default) or openai. The API
+	// keys and models are those of the provider.
+	LLMProvider string `yaml:"llm_provider,omitempty"`
+	LLMBaseURL  string `yaml:"llm_base_url,omitempty"` // Base URL of an OpenAI-compatible API, instead of the OpenAI API
+
+	// Answer requests with the offline mock provider instead of the API, for
+	// demos and template work without an API key
+	MockProvider bool `yaml:"mock_provider,omitempty"`
+
+	// Several API keys the requests are spread over, instead of claude_api_key
+	ClaudeAPIKeys  []claude.APIKey `yaml:"claude_api_keys,omitempty"`
+	APIKeyRotation string          `yaml:"api_key_rotation,omitempty"` // round_robin or on_rate_limit
+
+	// Extended thinking for reasoning-capable models
+	ThinkingBudgetTokens int      `yaml:"thinking_budget_tokens,omitempty"` // Token budget for extended thinking (0 disables it)
+	ThinkingStages       []string `yaml:"thinking_stages,omitempty"`        // Stages using extended thinking (defaults to global_summary)
+
 	// Theme summary configuration
 	ThemeSummaryPrompt  string `yaml:"theme_summary_prompt,omitempty"`
 	GlobalSummaryPrompt string `yaml:"global_summary_prompt,omitempty"`
+	SummaryCitations    bool   `yaml:"summary_citations,omitempty"` // Ground the global summary with citation markers
+
+	// Keep the summaries of an ongoing feedback channel while the share of
+	// the themes drifted less than this since they were generated (0 to 1)
+	SummaryDriftThreshold float64 `yaml:"summary_drift_threshold,omitempty"`
+
+	// Variants of the global summary generated concurrently
+	SummaryVariants         int    `yaml:"summary_variants,omitempty"`          // Number of global summaries to generate (0 or 1: a single one)
+	SummaryVariantSelection string `yaml:"summary_variant_selection,omitempty"` // judge (default) picks the best variant, all keeps every variant for the analyst
+
+	// Global summaries for stakeholder groups, generated from the same theme
+	// summaries as the global summary
+	SummaryAudiences []SummaryAudience `yaml:"summary_audiences,omitempty"`
+
+	// Verification of summary claims against the responses
+	VerifySummaries         bool   `yaml:"verify_summaries,omitempty"`
+	VerificationModel       string `yaml:"verification_model,omitempty"`        // Model used for verification (defaults to a cheap model)
+	RemoveUnsupportedClaims bool   `yaml:"remove_unsupported_claims,omitempty"` // Remove unsupported claims instead of only flagging them
+
+	// Per-theme overrides (keyed by theme name)
+	ThemeOverrides map[string]ThemeOverride `yaml:"theme_overrides,omitempty"`
+
+	// Responses a theme summary is generated from
+	SummarySampleSize   int    `yaml:"summary_sample_size,omitempty"`   // Defaults to 15
+	SummarySampling     string `yaml:"summary_sampling,omitempty"`      // shortest (default), longest, random, representative or stratified
+	SummarySamplingSeed int64  `yaml:"summary_sampling_seed,omitempty"` // Seed of the random and stratified samples
 
 	// Output language configuration
 	OutputLanguage string `yaml:"output_language,omitempty"`
 
 	// Themes (populated after first run)
-	Themes []string `yaml:"themes,omitempty"`
+	Themes     []string `yaml:"themes,omitempty"`
+	ThemesFile string   `yaml:"themes_file,omitempty"` // Read themes and descriptions from a themes.yaml written by the tool
+	// Read themes and descriptions from a mandated codebook (.yaml, .md or .docx)
+	CodebookFile string `yaml:"codebook_file,omitempty"`
+	// Path or URL of a shared theme library the themes extend, and the
+	// version it must have
+	ThemeLibrary        string `yaml:"theme_library,omitempty"`
+	ThemeLibraryVersion string `yaml:"theme_library_version,omitempty"`
+
+	// Theme descriptions and inclusion criteria (fed into matching prompts)
+	ThemeDescriptions         map[string]claude.ThemeDescription `yaml:"theme_descriptions,omitempty"`
+	GenerateThemeDescriptions bool                               `yaml:"generate_theme_descriptions,omitempty"` // Generate descriptions for themes lacking one
+
+	// Theme matching reliability
+	ConsensusRuns       int      `yaml:"consensus_runs,omitempty"`       // Classify each batch N times and keep majority-vote assignments
+	MatchingTemperature *float64 `yaml:"matching_temperature,omitempty"` // Sampling temperature for theme matching (defaults to 0.7)
+
+	// Remove instruction-like text such as "ignore previous instructions" from
+	// responses before they are quoted in prompts (defaults to true)
+	PromptInjectionGuard *bool `yaml:"prompt_injection_guard,omitempty"`
+
+	// Let the model propose new themes for responses matching none of the themes
+	ProposeNewThemes bool `yaml:"propose_new_themes,omitempty"`
+
+	// Let the model justify the themes it assigns to each response
+	MatchReasons bool `yaml:"match_reasons,omitempty"`
+
+	// Classify batches with a tool only accepting the theme numbers, retrying
+	// answers outside the theme list
+	StrictThemes bool `yaml:"strict_themes,omitempty"`
+
+	// Classify batches and summarize themes by calling tools with a JSON
+	// schema instead of parsing text answers
+	StructuredOutput bool `yaml:"structured_output,omitempty"`
+
+	// Classify the sentiment of each response for sentiment by theme
+	SentimentEnabled bool `yaml:"sentiment_enabled,omitempty"`
+
+	// Splitting of over-broad themes
+	SplitThemeThreshold  float64 `yaml:"split_theme_threshold,omitempty"`   // Share of responses (0-1) above which a theme is split
+	AutoApplyThemeSplits bool    `yaml:"auto_apply_theme_splits,omitempty"` // Replace over-broad themes by their sub-themes
+
+	// Stages to skip, keeping their results from the previous run
+	SkipMatching       bool `yaml:"skip_matching,omitempty"`        // Reuse the theme assignments of the state file
+	SkipThemeSummaries bool `yaml:"skip_theme_summaries,omitempty"` // Keep the theme summaries of the state file
+	SkipGlobalSummary  bool `yaml:"skip_global_summary,omitempty"`  // Keep the global summary of the state file
+
+	// Comparison of two models on a sample of the responses
+	ComparisonModels     []string `yaml:"comparison_models,omitempty"`      // Models compared by the compare subcommand
+	ComparisonSampleSize int      `yaml:"comparison_sample_size,omitempty"` // Number of responses matched by each model
+
+	// Confirmation before large runs (negative values disable the check)
+	ConfirmAboveResponses int     `yaml:"confirm_above_responses,omitempty"` // Ask before analyzing more new responses than this
+	ConfirmAboveCost      float64 `yaml:"confirm_above_cost,omitempty"`      // Ask before runs estimated to cost more than this (USD)
+	MaxCostUSD            float64 `yaml:"max_cost_usd,omitempty"`            // Stop the run before its requests cost more than this (USD, 0 disables)
 
 	// State management
 	StateFilePath string `yaml:"state_file_path,omitempty"`
+	StateEncoding string `yaml:"state_encoding,omitempty"` // yaml (default) or gzip
+	PauseFile     string `yaml:"pause_file,omitempty"`     // Pause the run while this file exists (defaults to pause next to the state file)
+	QueueDir      string `yaml:"queue_dir,omitempty"`      // Directory of the offline request queue (defaults to queue next to the state file)
+	AppendMode    bool   `yaml:"append_mode,omitempty"`    // Only read the rows after those analyzed before and keep the analyzed ones
+
+	// Checkpoints of the responses analyzed so far, for resuming a crashed run
+	CheckpointBatches int `yaml:"checkpoint_batches,omitempty"` // Completed batches between checkpoints (default 1, negative only on pausing)
+
+	// Theme matching through the Message Batches API at half the price, the
+	// run waits for the batch to end
+	BatchAPI          bool `yaml:"batch_api,omitempty"`
+	BatchPollInterval int  `yaml:"batch_poll_interval,omitempty"` // Seconds between checks of the batch (default 60)
+
+	// Pseudonymization of state and audit artifacts
+	Pseudonymize         bool   `yaml:"pseudonymize,omitempty"`          // Store pseudonymous response IDs and salted hashes
+	PseudonymizationSalt string `yaml:"pseudonymization_salt,omitempty"` // Secret salt for pseudonymous IDs and hashes
+	OmitResponseText     bool   `yaml:"omit_response_text,omitempty"`    // Do not store verbatim responses in state and audit files
+
+	// Embedding configuration
+	EmbeddingsEnabled bool   `yaml:"embeddings_enabled,omitempty"` // Compute and store embeddings of responses and themes
+	EmbeddingProvider string `yaml:"embedding_provider,omitempty"` // local or voyage
+	EmbeddingModel    string `yaml:"embedding_model,omitempty"`    // Model of the embedding provider
+	EmbeddingAPIKey   string `yaml:"embedding_api_key,omitempty"`  // API key of the embedding provider
+
+	// Outlier detection (requires embeddings)
+	OutlierThreshold float64 `yaml:"outlier_threshold,omitempty"` // Flag responses less similar than this to every theme (0 disables)
+
+	// Unsupervised clustering (requires embeddings)
+	ClusterCount int `yaml:"cluster_count,omitempty"` // Number of k-means clusters labelled by the model (0 disables)
+
+	// Classical topic model baseline
+	TopicModelTopics int `yaml:"topic_model_topics,omitempty"` // Number of NMF topics reported alongside the themes (0 disables)
 
 	// Cache configuration
 	CacheEnabled bool   `yaml:"cache_enabled"`
 	CacheDir     string `yaml:"cache_dir,omitempty"`
 
 	// Rate limiting configuration
-	RateLimitDelay int `yaml:"rate_limit_delay,omitempty"`
+	RateLimitDelay        int `yaml:"rate_limit_delay,omitempty"`
+	RateLimitTier         int `yaml:"rate_limit_tier,omitempty"`          // Anthropic usage tier (1-4) to derive throughput settings from
+	RequestsPerMinute     int `yaml:"requests_per_minute,omitempty"`      // Request limit of the account, overriding the tier
+	InputTokensPerMinute  int `yaml:"input_tokens_per_minute,omitempty"`  // Input token limit of the account, overriding the tier
+	OutputTokensPerMinute int `yaml:"output_tokens_per_minute,omitempty"` // Output token limit of the account, overriding the tier
 
 	// Performance optimization configuration
-	BatchSize       int  `yaml:"batch_size,omitempty"`       // Batch size for processing responses
-	ParallelWorkers int  `yaml:"parallel_workers,omitempty"` // Number of parallel workers
-	UseParallel     bool `yaml:"use_parallel,omitempty"`     // Whether to use parallel processing
+	BatchSize           int  `yaml:"batch_size,omitempty"`            // Batch size for processing responses
+	ParallelWorkers     int  `yaml:"parallel_workers,omitempty"`      // Number of parallel workers
+	UseParallel         bool `yaml:"use_parallel,omitempty"`          // Whether to use parallel processing
+	AutoParallelWorkers bool `yaml:"auto_parallel_workers,omitempty"` // Tune the number of workers during the run, up to parallel_workers
+
+	// Share of the responses in percent whose matching batches may fail
+	// without aborting the run, 0 aborts on the first failed batch
+	MaxFailedResponsesPct float64 `yaml:"max_failed_responses_pct,omitempty"`
+
+	// Description of the survey for the header of reports
+	Survey *Survey `yaml:"survey,omitempty"`
 
 	// Report template configuration
 	ReportTemplatePath string `yaml:"report_template_path,omitempty"`
 	ReportOutputPath   string `yaml:"report_output_path,omitempty"`
+
+	// Report of all questions with response_columns, by default the
+	// built-in Markdown report written to report-combined.md
+	CombinedReportTemplatePath string `yaml:"combined_report_template_path,omitempty"`
+	CombinedReportOutputPath   string `yaml:"combined_report_output_path,omitempty"`
+
+	// Additional reports, each in a language of its own with summaries
+	// generated in that language from the same classification
+	ReportOutputs []ReportOutput `yaml:"report_outputs,omitempty"`
+
+	// Appendix listing all responses grouped by theme
+	AppendixFormat     string `yaml:"appendix_format,omitempty"`      // markdown or html (empty disables the appendix)
+	AppendixOutputPath string `yaml:"appendix_output_path,omitempty"` // Defaults to appendix.md/appendix.html next to the state file
+	HTMLTheme          string `yaml:"html_theme,omitempty"`           // default or accessible
+
+	// Self-contained HTML report with charts of the theme frequencies and
+	// co-occurrences and the summaries
+	HTMLReportPath string `yaml:"html_report_path,omitempty"` // Path of the .html file (empty disables the report)
+
+	// Wide CSV export for statistics software, with a codebook next to it
+	WideExportPath string `yaml:"wide_export_path,omitempty"` // Path of the CSV file (empty disables the export)
+
+	// Excel workbook with the themes, the responses and a theme matrix
+	ExcelOutputPath string `yaml:"excel_output_path,omitempty"` // Path of the .xlsx file (empty disables the workbook)
+
+	// Copy of the input workbook with the themes of each response as a comment
+	// on its cell
+	AnnotatedExcelPath string `yaml:"annotated_excel_path,omitempty"` // Path of the .xlsx file (empty disables the copy)
+
+	// Stratified random sample of theme assignments for a human review
+	ReviewSampleSize int    `yaml:"review_sample_size,omitempty"` // Number of responses in the sample (0 disables the sample)
+	ReviewSamplePath string `yaml:"review_sample_path,omitempty"` // Path of the .xlsx file (default: review_sample.xlsx next to the state file)
+	ReviewSampleSeed int64  `yaml:"review_sample_seed,omitempty"` // Seed of the random selection
+
+	// Destinations the written files are delivered to after a run
+	OutputSinks []OutputSink `yaml:"output_sinks,omitempty"`
+
+	// Webhook the result is posted to after a run
+	Webhook *Webhook `yaml:"webhook,omitempty"`
+
+	// Run identification
+	RunIDInFilenames bool `yaml:"run_id_in_filenames,omitempty"` // Whether to add the run ID to output file names
+
+	// Named sets of settings applied over the others, e.g. a cheap draft and a
+	// thorough final run, selected by profile or the -profile flag
+	Profile  string               `yaml:"profile,omitempty"`
+	Profiles map[string]yaml.Node `yaml:"profiles,omitempty"`
+}
+
+// ThemeOverride holds settings that replace the global ones for a single theme
+type ThemeOverride struct {
+	SummaryPrompt     string `yaml:"summary_prompt,omitempty"`      // Replaces theme_summary_prompt for this theme
+	SummarySampleSize int    `yaml:"summary_sample_size,omitempty"` // Replaces summary_sample_size for this theme
+}
+
+// SummaryAudience is a stakeholder group, e.g. the executive board or the
+// works council, a global summary of its own is written for
+type SummaryAudience struct {
+	Name         string `yaml:"name"`
+	Prompt       string `yaml:"prompt"`                  // Replaces global_summary_prompt for this audience
+	Length       int    `yaml:"length,omitempty"`        // Length in characters (defaults to global_summary_length)
+	OutputPath   string `yaml:"output_path,omitempty"`   // Defaults to summary-<name>.txt next to the state file
+	TemplatePath string `yaml:"template_path,omitempty"` // Report template rendered with the summary of the audience
+}
+
+// ReportOutput is an additional report rendered after a run
+type ReportOutput struct {
+	Template string `yaml:"template,omitempty"` // Defaults to report_template_path
+	Language string `yaml:"language,omitempty"` // Defaults to output_language
+	Path     string `yaml:"path"`
+}
+
+// Survey describes the survey the responses come from, so reports can
+// present it without editing
+type Survey struct {
+	Title        string  `yaml:"title,omitempty"`
+	Period       string  `yaml:"period,omitempty"`        // When the survey ran, e.g. "March 2025"
+	Population   string  `yaml:"population,omitempty"`    // Who was asked, e.g. "Customers with an order in 2024"
+	Invited      int     `yaml:"invited,omitempty"`       // Number of people asked
+	ResponseRate float64 `yaml:"response_rate,omitempty"` // In percent, computed from invited and the respondents if not set
+	Methodology  string  `yaml:"methodology,omitempty"`   // Notes on the method, e.g. sampling, weighting or mode
+}
+
+// RatingBand is a named range of ratings, e.g. the detractors of an NPS
+type RatingBand struct {
+	Name string  `yaml:"name"`
+	Min  float64 `yaml:"min"`
+	Max  float64 `yaml:"max"`
+}
+
+// Webhook is a URL the result of a run is posted to as JSON
+type Webhook struct {
+	URL     string            `yaml:"url"`
+	Payload string            `yaml:"payload,omitempty"` // summary (default) or full
+	Headers map[string]string `yaml:"headers,omitempty"` // Extra headers, e.g. Authorization
+	Secret  string            `yaml:"secret,omitempty"`  // Signs the body with HMAC-SHA256 in X-Signature-256
+	Retries int               `yaml:"retries,omitempty"` // Retries of failed deliveries, defaults to 3
+}
+
+// HelpdeskSource is a helpdesk the import subcommand fetches ticket comments
+// from into the Excel file, so support tickets are analyzed like survey
+// responses
+type HelpdeskSource struct {
+	Type       string `yaml:"type"` // zendesk, freshdesk or jira (Jira Service Management)
+	URL        string `yaml:"url"`  // e.g. https://example.zendesk.com
+	Username   string `yaml:"username,omitempty"`
+	APIToken   string `yaml:"api_token,omitempty"`
+	Query      string `yaml:"query,omitempty"`       // Zendesk search query, Freshdesk filter query or JQL selecting the tickets
+	Comments   string `yaml:"comments,omitempty"`    // first, requester (default) or all public comments
+	MaxTickets int    `yaml:"max_tickets,omitempty"` // Fetch at most this many tickets (0 for all)
+}
+
+// TranscriptSource are transcripts of interviews or focus groups the import
+// subcommand segments into the Excel file
+type TranscriptSource struct {
+	Files        []string `yaml:"files"`                  // Paths or glob patterns of .vtt, .srt or plain text transcripts
+	Segmentation string   `yaml:"segmentation,omitempty"` // turn (default) or question
+	Interviewers []string `yaml:"interviewers,omitempty"` // Speaker labels of the interviewers or moderators, whose turns are the questions
+}
+
+// OutputSink is a destination the files of a run are uploaded to. The
+// scheme of the URL selects the kind of sink: s3://bucket/prefix,
+// sftp://user@host:port/path, https://host/path for WebDAV, or
+// file:///path for a directory such as a mounted network share.
+type OutputSink struct {
+	Name string `yaml:"name,omitempty"` // Name in log messages, defaults to the host
+	URL  string `yaml:"url"`
+
+	// Files to deliver by their label in the run summary, e.g. Report or
+	// State; all files if empty
+	Artifacts []string `yaml:"artifacts,omitempty"`
+
+	// S3, the keys default to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	Region          string `yaml:"region,omitempty"`
+	Endpoint        string `yaml:"endpoint,omitempty"` // S3 compatible service, addressed path-style
+	AccessKeyID     string `yaml:"access_key_id,omitempty"`
+	SecretAccessKey string `yaml:"secret_access_key,omitempty"`
+
+	// SFTP, using the sftp command and the SSH agent or this key
+	IdentityFile string `yaml:"identity_file,omitempty"`
+
+	// WebDAV basic authentication
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
+// ThemeSummaryPromptFor returns the summary prompt to use for the given theme,
+// falling back to the global theme summary prompt
+func (c *Config) ThemeSummaryPromptFor(theme string) string {
+	if override, ok := c.ThemeOverrides[theme]; ok && override.SummaryPrompt != "" {
+		return override.SummaryPrompt
+	}
+	return c.ThemeSummaryPrompt
+}
+
+// SummarySampleSizeFor returns the number of responses the summary of the
+// given theme is generated from
+func (c *Config) SummarySampleSizeFor(theme string) int {
+	if override, ok := c.ThemeOverrides[theme]; ok && override.SummarySampleSize > 0 {
+		return override.SummarySampleSize
+	}
+	return c.SummarySampleSize
 }
 
-// LoadConfig loads the configuration from a YAML file
+// LoadConfig loads the configuration from a YAML file, applying the profile
+// selected by its profile setting, if any
 func LoadConfig(path string) (*Config, error) {
+	return LoadConfigProfile(path, "")
+}
+
+// LoadConfigProfile loads the configuration from a YAML file, applying the
+// settings of the named profile over the others. An empty name selects the
+// profile of the profile setting, if any.
+func LoadConfigProfile(path, profile string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := applyProfile(&document, profile); err != nil {
+		return nil, err
+	}
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := document.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
@@ -66,19 +421,67 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("excel_file_path is required")
 	}
 
-	if cfg.ResponseColumn == "" {
+	if cfg.ResponseColumn == "" && len(cfg.ResponseColumns) == 0 {
 		return nil, fmt.Errorf("response_column is required")
 	}
 
-	if cfg.ClaudeAPIKey == "" {
+	if cfg.ClaudeAPIKey == "" && len(cfg.ClaudeAPIKeys) == 0 && !cfg.MockProvider {
 		return nil, fmt.Errorf("claude_api_key is required")
 	}
+	if cfg.ClaudeAPIKey != "" && len(cfg.ClaudeAPIKeys) > 0 {
+		return nil, fmt.Errorf("claude_api_key and claude_api_keys cannot both be set")
+	}
+	for i := range cfg.ClaudeAPIKeys {
+		if cfg.ClaudeAPIKeys[i].Name == "" {
+			cfg.ClaudeAPIKeys[i].Name = fmt.Sprintf("key-%d", i+1) // Name keys by position for the usage report
+		}
+	}
+
+	// Read themes from the themes file
+	if cfg.ThemesFile != "" {
+		if len(cfg.Themes) > 0 {
+			return nil, fmt.Errorf("themes and themes_file cannot both be set")
+		}
+		if err := cfg.LoadThemesFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read themes from the codebook
+	if cfg.CodebookFile != "" {
+		if len(cfg.Themes) > 0 {
+			return nil, fmt.Errorf("codebook_file cannot be combined with themes or themes_file")
+		}
+		if err := cfg.loadCodebook(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read themes from the shared theme library, extended by those of the config
+	if cfg.ThemeLibrary != "" {
+		if cfg.ThemesFile != "" || cfg.CodebookFile != "" {
+			return nil, fmt.Errorf("theme_library cannot be combined with themes_file or codebook_file")
+		}
+		if err := cfg.loadThemeLibrary(); err != nil {
+			return nil, err
+		}
+	} else if cfg.ThemeLibraryVersion != "" {
+		return nil, fmt.Errorf("theme_library_version requires theme_library")
+	}
 
 	// Set defaults
 	if cfg.SummaryLength == 0 {
 		cfg.SummaryLength = 500 // Default global summary length
 	}
 
+	if cfg.SummarySampleSize == 0 {
+		cfg.SummarySampleSize = 15 // Responses per theme summary
+	}
+
+	if cfg.SummarySampling == "" {
+		cfg.SummarySampling = "shortest" // Shortest responses first
+	}
+
 	if cfg.CacheDir == "" && cfg.CacheEnabled {
 		cfg.CacheDir = ".cache" // Default cache directory
 	}
@@ -91,6 +494,42 @@ func LoadConfig(path string) (*Config, error) {
 		cfg.OutputLanguage = "en" // Default to English
 	}
 
+	if cfg.LLMProvider == llm.ProviderOpenAI {
+		if cfg.ClaudeModel == "" {
+			cfg.ClaudeModel = llm.OpenAIDefaultModel
+		}
+		if cfg.VerificationModel == "" {
+			cfg.VerificationModel = llm.OpenAIVerificationModel
+		}
+	}
+
+	if cfg.VerificationModel == "" {
+		cfg.VerificationModel = "claude-3-haiku-20240307" // Cheap model for claim verification
+	}
+
+	if cfg.TimeSeriesInterval == "" {
+		cfg.TimeSeriesInterval = "month" // Count the themes per month
+	}
+
+	if cfg.Segmentation != "" && cfg.SegmentationMinLength == 0 {
+		cfg.SegmentationMinLength = 500 // Only split documents, not ordinary answers
+	}
+	if cfg.Segmentation == "semantic" && cfg.SegmentationThreshold == 0 {
+		cfg.SegmentationThreshold = 0.3
+	}
+
+	if cfg.Webhook != nil {
+		if cfg.Webhook.Payload == "" {
+			cfg.Webhook.Payload = "summary"
+		}
+		if cfg.Webhook.Retries == 0 {
+			cfg.Webhook.Retries = 3
+		}
+	}
+
+	// Derive unset throughput settings from the rate limits
+	cfg.applyRateLimits()
+
 	if cfg.RateLimitDelay == 0 {
 		cfg.RateLimitDelay = 1000 // Default to 1000ms (1 second)
 	}
@@ -100,6 +539,37 @@ func LoadConfig(path string) (*Config, error) {
 		cfg.BatchSize = 10 // Default batch size
 	}
 
+	if cfg.ConfirmAboveResponses == 0 {
+		cfg.ConfirmAboveResponses = 2000 // Ask before analyzing more than 2000 new responses
+	}
+
+	if cfg.ConfirmAboveCost == 0 {
+		cfg.ConfirmAboveCost = 5.0 // Ask before runs estimated above 5 USD
+	}
+
+	if cfg.APIKeyRotation == "" {
+		cfg.APIKeyRotation = claude.RotateRoundRobin // Spread requests evenly over the keys
+	}
+
+	if cfg.ComparisonSampleSize == 0 {
+		cfg.ComparisonSampleSize = 100 // Compare models on 100 responses
+	}
+
+	if cfg.ThinkingBudgetTokens > 0 && len(cfg.ThinkingStages) == 0 {
+		cfg.ThinkingStages = []string{claude.StageGlobalSummary} // Think only where it pays off
+	}
+
+	if cfg.EmbeddingProvider == "" {
+		cfg.EmbeddingProvider = "local" // Default to local embeddings
+	}
+
+	if cfg.ConsensusRuns == 0 {
+		cfg.ConsensusRuns = 1 // Classify each batch once
+	}
+
+	if cfg.ParallelWorkers == 0 && cfg.AutoParallelWorkers {
+		cfg.ParallelWorkers = claude.MaxParallelWorkers // Let the tuning find the number
+	}
 	if cfg.ParallelWorkers == 0 {
 		cfg.ParallelWorkers = 4 // Default number of workers
 	}
@@ -111,6 +581,130 @@ func LoadConfig(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// ThemesFileContent is the content of a themes file
+type ThemesFileContent struct {
+	Themes            []string                           `yaml:"themes"`
+	ThemeDescriptions map[string]claude.ThemeDescription `yaml:"theme_descriptions,omitempty"`
+}
+
+// APIKeys returns the API keys to use, the first of which is used for
+// requests that are not rotated
+func (cfg *Config) APIKeys() []claude.APIKey {
+	if len(cfg.ClaudeAPIKeys) > 0 {
+		return cfg.ClaudeAPIKeys
+	}
+	return []claude.APIKey{{Name: "default", Key: cfg.ClaudeAPIKey}}
+}
+
+// RateLimits returns the rate limits of rate_limit_tier, overridden by the
+// explicitly configured limits
+func (cfg *Config) RateLimits() claude.RateLimits {
+	limits := claude.RateLimitTiers[cfg.RateLimitTier]
+	if cfg.RequestsPerMinute > 0 {
+		limits.RequestsPerMinute = cfg.RequestsPerMinute
+	}
+	if cfg.InputTokensPerMinute > 0 {
+		limits.InputTokensPerMinute = cfg.InputTokensPerMinute
+	}
+	if cfg.OutputTokensPerMinute > 0 {
+		limits.OutputTokensPerMinute = cfg.OutputTokensPerMinute
+	}
+	return limits
+}
+
+// applyRateLimits derives batch_size, parallel_workers and rate_limit_delay
+// from the rate limits unless they are set explicitly
+func (cfg *Config) applyRateLimits() {
+	limits := cfg.RateLimits()
+	if limits == (claude.RateLimits{}) {
+		return
+	}
+
+	throughput := limits.Throughput(cfg.BatchSize)
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = throughput.BatchSize
+	}
+	if cfg.ParallelWorkers == 0 {
+		cfg.ParallelWorkers = throughput.ParallelWorkers
+	}
+	if cfg.RateLimitDelay == 0 {
+		cfg.RateLimitDelay = max(1, int(throughput.RateLimitDelay.Milliseconds()))
+	}
+}
+
+// LoadThemesFile reads the themes and their descriptions from the themes file.
+// A missing file is not an error, the themes are then identified and written to it.
+func (cfg *Config) LoadThemesFile() error {
+	data, err := os.ReadFile(cfg.ThemesFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read themes file: %w", err)
+	}
+
+	var content ThemesFileContent
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return fmt.Errorf("failed to parse themes file %s: %w", cfg.ThemesFile, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, theme := range content.Themes {
+		if strings.TrimSpace(theme) == "" {
+			return fmt.Errorf("themes file %s contains an empty theme", cfg.ThemesFile)
+		}
+		if seen[theme] {
+			return fmt.Errorf("themes file %s contains the theme %q more than once", cfg.ThemesFile, theme)
+		}
+		seen[theme] = true
+	}
+	if len(content.Themes) == 0 {
+		return fmt.Errorf("themes file %s contains no themes", cfg.ThemesFile)
+	}
+	cfg.Themes = content.Themes
+
+	// Descriptions in the config take precedence over those in the file
+	for theme, description := range content.ThemeDescriptions {
+		if !seen[theme] {
+			return fmt.Errorf("themes file %s describes the unknown theme %q", cfg.ThemesFile, theme)
+		}
+		if _, ok := cfg.ThemeDescriptions[theme]; ok {
+			continue
+		}
+		if cfg.ThemeDescriptions == nil {
+			cfg.ThemeDescriptions = make(map[string]claude.ThemeDescription)
+		}
+		cfg.ThemeDescriptions[theme] = description
+	}
+	return nil
+}
+
+// loadCodebook takes the themes and their descriptions from the codebook.
+// Descriptions in the config take precedence over those in the codebook.
+func (cfg *Config) loadCodebook() error {
+	entries, err := LoadCodebook(cfg.CodebookFile)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		cfg.Themes = append(cfg.Themes, entry.Theme)
+		if entry.Description == "" && entry.InclusionCriteria == "" {
+			continue
+		}
+		if _, ok := cfg.ThemeDescriptions[entry.Theme]; ok {
+			continue
+		}
+		if cfg.ThemeDescriptions == nil {
+			cfg.ThemeDescriptions = make(map[string]claude.ThemeDescription)
+		}
+		cfg.ThemeDescriptions[entry.Theme] = claude.ThemeDescription{
+			Description:       entry.Description,
+			InclusionCriteria: entry.InclusionCriteria,
+		}
+	}
+	return nil
+}
+
 // SaveConfig saves the configuration to a YAML file
 func SaveConfig(cfg *Config, path string) error {
 	data, err := yaml.Marshal(cfg)
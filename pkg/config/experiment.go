@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PromptVariant is a variant of the matching settings tried in an experiment;
+// unset fields keep the values of the configuration
+type PromptVariant struct {
+	Name                string   `yaml:"name"`
+	ContextPrompt       string   `yaml:"context_prompt,omitempty"`
+	MatchingTemperature *float64 `yaml:"matching_temperature,omitempty"`
+	BatchSize           int      `yaml:"batch_size,omitempty"`
+}
+
+// ExperimentMatrix defines an experiment matching a labelled sample with
+// every combination of the models and prompt variants
+type ExperimentMatrix struct {
+	CodedFile  string          `yaml:"coded_file"`            // Labelled sample, as read by the agreement subcommand
+	SampleSize int             `yaml:"sample_size,omitempty"` // Coded responses to use (0 uses all)
+	Models     []string        `yaml:"models,omitempty"`      // Defaults to claude_model
+	Prompts    []PromptVariant `yaml:"prompts,omitempty"`     // Defaults to the configured prompt
+}
+
+// LoadExperimentMatrix loads an experiment matrix from a YAML file
+func LoadExperimentMatrix(path string) (*ExperimentMatrix, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read experiment matrix: %w", err)
+	}
+
+	var matrix ExperimentMatrix
+	if err := yaml.Unmarshal(data, &matrix); err != nil {
+		return nil, fmt.Errorf("failed to parse experiment matrix: %w", err)
+	}
+
+	if matrix.CodedFile == "" {
+		return nil, fmt.Errorf("coded_file is required in the experiment matrix")
+	}
+	if matrix.SampleSize < 0 {
+		return nil, fmt.Errorf("invalid sample_size: %d (must be 0 or greater)", matrix.SampleSize)
+	}
+	if len(matrix.Prompts) == 0 {
+		matrix.Prompts = []PromptVariant{{Name: "configured"}}
+	}
+	names := make(map[string]bool)
+	for _, prompt := range matrix.Prompts {
+		if prompt.Name == "" {
+			return nil, fmt.Errorf("prompt variant without name in the experiment matrix")
+		}
+		if names[prompt.Name] {
+			return nil, fmt.Errorf("duplicate prompt variant name: %s", prompt.Name)
+		}
+		if prompt.BatchSize < 0 {
+			return nil, fmt.Errorf("invalid batch_size of prompt variant %s: %d", prompt.Name, prompt.BatchSize)
+		}
+		names[prompt.Name] = true
+	}
+
+	return &matrix, nil
+}
+
+// Apply returns a copy of the configuration with the settings of the variant
+func (v PromptVariant) Apply(cfg *Config) *Config {
+	variantCfg := *cfg
+	if v.ContextPrompt != "" {
+		variantCfg.ContextPrompt = v.ContextPrompt
+	}
+	if v.MatchingTemperature != nil {
+		variantCfg.MatchingTemperature = v.MatchingTemperature
+	}
+	if v.BatchSize > 0 {
+		variantCfg.BatchSize = v.BatchSize
+	}
+	return &variantCfg
+}
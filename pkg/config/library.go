@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"gopkg.in/yaml.v3"
+)
+
+// themeLibraryTimeout limits the download of a theme library
+const themeLibraryTimeout = 30 * time.Second
+
+// ThemeLibraryContent is the content of a theme library: a themes file with
+// the version of the taxonomy it holds
+type ThemeLibraryContent struct {
+	Version           string `yaml:"version"`
+	ThemesFileContent `yaml:",inline"`
+}
+
+// loadThemeLibrary reads the themes and their descriptions from the theme
+// library. The themes of the config extend those of the library, and
+// descriptions in the config take precedence. With theme_library_version the
+// library must have that version; without it, it is set to the version read.
+func (cfg *Config) loadThemeLibrary() error {
+	data, err := readThemeLibrary(cfg.ThemeLibrary)
+	if err != nil {
+		return err
+	}
+	var library ThemeLibraryContent
+	if err := yaml.Unmarshal(data, &library); err != nil {
+		return fmt.Errorf("failed to parse theme library %s: %w", cfg.ThemeLibrary, err)
+	}
+	if library.Version == "" {
+		return fmt.Errorf("theme library %s has no version", cfg.ThemeLibrary)
+	}
+	if cfg.ThemeLibraryVersion != "" && cfg.ThemeLibraryVersion != library.Version {
+		return fmt.Errorf("theme library %s has version %s, the configuration requires %s", cfg.ThemeLibrary, library.Version, cfg.ThemeLibraryVersion)
+	}
+	if len(library.Themes) == 0 {
+		return fmt.Errorf("theme library %s contains no themes", cfg.ThemeLibrary)
+	}
+	for i, theme := range library.Themes {
+		if strings.TrimSpace(theme) == "" {
+			return fmt.Errorf("theme library %s contains an empty theme", cfg.ThemeLibrary)
+		}
+		if slices.Contains(library.Themes[:i], theme) {
+			return fmt.Errorf("theme library %s contains the theme %q more than once", cfg.ThemeLibrary, theme)
+		}
+	}
+	cfg.ThemeLibraryVersion = library.Version
+
+	// The themes of the config extend the library
+	themes := slices.Clone(library.Themes)
+	for _, theme := range cfg.Themes {
+		if !slices.Contains(themes, theme) {
+			themes = append(themes, theme)
+		}
+	}
+	cfg.Themes = themes
+
+	for theme, description := range library.ThemeDescriptions {
+		if !slices.Contains(library.Themes, theme) {
+			return fmt.Errorf("theme library %s describes the unknown theme %q", cfg.ThemeLibrary, theme)
+		}
+		if _, ok := cfg.ThemeDescriptions[theme]; ok {
+			continue
+		}
+		if cfg.ThemeDescriptions == nil {
+			cfg.ThemeDescriptions = make(map[string]claude.ThemeDescription)
+		}
+		cfg.ThemeDescriptions[theme] = description
+	}
+	return nil
+}
+
+// ThemeLibraryRef identifies the theme library in use as <path or URL>@<version>,
+// empty without a library
+func (cfg *Config) ThemeLibraryRef() string {
+	if cfg.ThemeLibrary == "" {
+		return ""
+	}
+	return cfg.ThemeLibrary + "@" + cfg.ThemeLibraryVersion
+}
+
+// readThemeLibrary reads a theme library from a file or an http(s) URL
+func readThemeLibrary(location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		data, err := os.ReadFile(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read theme library: %w", err)
+		}
+		return data, nil
+	}
+
+	client := &http.Client{Timeout: themeLibraryTimeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download theme library: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download theme library %s: %s", location, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download theme library: %w", err)
+	}
+	return data, nil
+}
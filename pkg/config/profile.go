@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// applyProfile replaces the settings of a parsed configuration by those of
+// the named profile, or of the profile setting if the name is empty. Settings
+// are replaced as a whole, a profile setting theme_overrides replaces all of
+// them. The profile setting is set to the applied profile.
+func applyProfile(document *yaml.Node, name string) error {
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		if name != "" {
+			return fmt.Errorf("profile %s not found, the configuration has no profiles", name)
+		}
+		return nil
+	}
+	settings := document.Content[0]
+	if name == "" {
+		if value := mappingNode(settings, "profile"); value != nil {
+			name = value.Value
+		}
+	}
+	if name == "" {
+		return nil
+	}
+
+	profiles := mappingNode(settings, "profiles")
+	if profiles == nil || profiles.Kind != yaml.MappingNode || len(profiles.Content) == 0 {
+		return fmt.Errorf("profile %s not found, the configuration has no profiles", name)
+	}
+	selected := mappingNode(profiles, name)
+	if selected == nil {
+		var names []string
+		for i := 0; i < len(profiles.Content); i += 2 {
+			names = append(names, profiles.Content[i].Value)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("profile %s not found (profiles: %s)", name, strings.Join(names, ", "))
+	}
+	if selected.Kind != yaml.MappingNode {
+		return fmt.Errorf("profile %s must be a mapping of settings", name)
+	}
+
+	for i := 0; i < len(selected.Content); i += 2 {
+		key, value := selected.Content[i], selected.Content[i+1]
+		if key.Value == "profile" || key.Value == "profiles" {
+			return fmt.Errorf("profile %s cannot set %s", name, key.Value)
+		}
+		setMappingNode(settings, key, value)
+	}
+	setMappingNode(settings, &yaml.Node{Kind: yaml.ScalarNode, Value: "profile"}, &yaml.Node{Kind: yaml.ScalarNode, Value: name})
+	return nil
+}
+
+// mappingNode returns the value of a key of a mapping node, nil if it is
+// missing
+func mappingNode(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingNode sets the value of a key of a mapping node, adding the key
+// if it is missing
+func setMappingNode(mapping *yaml.Node, key, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key.Value {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, key, value)
+}
@@ -0,0 +1,206 @@
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences used for colored output
+const (
+	Reset  = "\033[0m"
+	Bold   = "\033[1m"
+	Dim    = "\033[2m"
+	Red    = "\033[31m"
+	Green  = "\033[32m"
+	Yellow = "\033[33m"
+	Cyan   = "\033[36m"
+)
+
+// Column describes a column of a table
+type Column struct {
+	Title string
+	Right bool // Align the column to the right, e.g. for numbers
+	Max   int  // Truncate cells longer than this many characters (0 for no limit)
+}
+
+// Item is a labelled value shown in a panel
+type Item struct {
+	Label string
+	Value string
+}
+
+// Console renders human-friendly terminal output
+type Console struct {
+	out   io.Writer
+	color bool
+}
+
+// New creates a console writing to out, using colors if color is true
+func New(out io.Writer, color bool) *Console {
+	return &Console{out: out, color: color}
+}
+
+// ColorSupported reports whether colors should be used on the given file.
+// Colors are disabled if NO_COLOR is set, TERM is dumb or the file is not a
+// terminal.
+func ColorSupported(file *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return IsTerminal(file)
+}
+
+// IsTerminal reports whether the file is a terminal
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Confirm asks a yes/no question and reads the answer from in
+func (c *Console) Confirm(in io.Reader, question string) (bool, error) {
+	fmt.Fprintf(c.out, "%s [y/N] ", c.Colorize(Bold, question))
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err == io.EOF && answer == "" {
+		fmt.Fprintln(c.out)
+		return false, nil
+	}
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read answer: %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// Colorize wraps text in the given color if colors are enabled
+func (c *Console) Colorize(color, text string) string {
+	if !c.color || text == "" {
+		return text
+	}
+	return color + text + Reset
+}
+
+// Heading prints a section heading
+func (c *Console) Heading(title string) {
+	fmt.Fprintf(c.out, "\n%s\n", c.Colorize(Bold+Cyan, title))
+}
+
+// Println prints a line of text
+func (c *Console) Println(text string) {
+	fmt.Fprintln(c.out, text)
+}
+
+// Note prints a dimmed hint
+func (c *Console) Note(text string) {
+	fmt.Fprintln(c.out, c.Colorize(Dim, text))
+}
+
+// Success prints a message marking a successful step
+func (c *Console) Success(format string, args ...interface{}) {
+	fmt.Fprintln(c.out, c.Colorize(Green, "✔ ")+fmt.Sprintf(format, args...))
+}
+
+// Warn prints a warning message
+func (c *Console) Warn(format string, args ...interface{}) {
+	fmt.Fprintln(c.out, c.Colorize(Yellow, "! "+fmt.Sprintf(format, args...)))
+}
+
+// Table prints rows as a table with aligned columns
+func (c *Console) Table(columns []Column, rows [][]string) {
+	// Truncate cells and measure the column widths
+	widths := make([]int, len(columns))
+	cells := make([][]string, len(rows))
+	for i, column := range columns {
+		widths[i] = width(column.Title)
+	}
+	for r, row := range rows {
+		cells[r] = make([]string, len(columns))
+		for i := range columns {
+			if i >= len(row) {
+				continue
+			}
+			cells[r][i] = truncate(row[i], columns[i].Max)
+			if w := width(cells[r][i]); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	titles := make([]string, len(columns))
+	rules := make([]string, len(columns))
+	for i, column := range columns {
+		titles[i] = pad(column.Title, widths[i], column.Right)
+		rules[i] = strings.Repeat("─", widths[i])
+	}
+	fmt.Fprintln(c.out, c.Colorize(Bold, strings.TrimRight(strings.Join(titles, "  "), " ")))
+	fmt.Fprintln(c.out, c.Colorize(Dim, strings.Join(rules, "  ")))
+	for _, row := range cells {
+		padded := make([]string, len(columns))
+		for i, column := range columns {
+			padded[i] = pad(row[i], widths[i], column.Right)
+		}
+		fmt.Fprintln(c.out, strings.TrimRight(strings.Join(padded, "  "), " "))
+	}
+}
+
+// Panel prints labelled values in a box
+func (c *Console) Panel(title string, items []Item) {
+	labelWidth := 0
+	for _, item := range items {
+		if w := width(item.Label); w > labelWidth {
+			labelWidth = w
+		}
+	}
+	lines := make([]string, len(items))
+	contentWidth := width(title)
+	for i, item := range items {
+		lines[i] = pad(item.Label, labelWidth, false) + "  " + item.Value
+		if w := width(lines[i]); w > contentWidth {
+			contentWidth = w
+		}
+	}
+
+	border := func(left, right string) string {
+		return c.Colorize(Cyan, left+strings.Repeat("─", contentWidth+2)+right)
+	}
+	side := c.Colorize(Cyan, "│")
+	fmt.Fprintln(c.out)
+	fmt.Fprintln(c.out, border("┌", "┐"))
+	fmt.Fprintf(c.out, "%s %s %s\n", side, c.Colorize(Bold, pad(title, contentWidth, false)), side)
+	fmt.Fprintln(c.out, border("├", "┤"))
+	for i, item := range items {
+		label := c.Colorize(Dim, pad(item.Label, labelWidth, false))
+		fmt.Fprintf(c.out, "%s %s  %s%s %s\n", side, label, item.Value, strings.Repeat(" ", contentWidth-width(lines[i])), side)
+	}
+	fmt.Fprintln(c.out, border("└", "┘"))
+}
+
+// width returns the number of characters of a text
+func width(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+// pad pads a text with spaces to the given width
+func pad(text string, w int, right bool) string {
+	padding := strings.Repeat(" ", max(w-width(text), 0))
+	if right {
+		return padding + text
+	}
+	return text + padding
+}
+
+// truncate shortens a single-line version of the text to at most n characters
+func truncate(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if n <= 0 || width(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,262 @@
+package embedding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+const (
+	// ProviderLocal computes embeddings locally using feature hashing
+	ProviderLocal = "local"
+	// ProviderVoyage computes embeddings using the Voyage AI API
+	ProviderVoyage = "voyage"
+
+	// LocalModel is the model name recorded for local embeddings
+	LocalModel = "local-hashing-512"
+	// LocalDimensions is the number of dimensions of local embeddings
+	LocalDimensions = 512
+
+	// VoyageAPIURL is the URL of the Voyage AI embeddings API
+	VoyageAPIURL = "https://api.voyageai.com/v1/embeddings"
+	// DefaultVoyageModel is the default Voyage AI embedding model
+	DefaultVoyageModel = "voyage-3"
+	// voyageBatchSize is the number of texts sent in one Voyage AI request
+	voyageBatchSize = 64
+)
+
+// Embedder computes embedding vectors for texts
+type Embedder interface {
+	// Embed returns one vector per text
+	Embed(texts []string) ([][]float32, error)
+	// Model returns the name of the embedding model
+	Model() string
+}
+
+// NewEmbedder creates an embedder for the given provider
+func NewEmbedder(logger *logging.Logger, provider, model, apiKey string) (Embedder, error) {
+	switch provider {
+	case "", ProviderLocal:
+		return &LocalEmbedder{}, nil
+	case ProviderVoyage:
+		if apiKey == "" {
+			return nil, fmt.Errorf("an API key is required for the voyage embedding provider")
+		}
+		if model == "" {
+			model = DefaultVoyageModel
+		}
+		return &VoyageEmbedder{
+			logger:     logger,
+			apiKey:     apiKey,
+			model:      model,
+			httpClient: &http.Client{Timeout: 60 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider: %s (valid options: local, voyage)", provider)
+	}
+}
+
+// LocalEmbedder computes embeddings without any API by hashing words and
+// character trigrams into a fixed number of dimensions. The vectors capture
+// lexical rather than deep semantic similarity, but cost nothing.
+type LocalEmbedder struct{}
+
+// Model returns the name of the local embedding model
+func (e *LocalEmbedder) Model() string {
+	return LocalModel
+}
+
+// Embed returns the local embedding of each text
+func (e *LocalEmbedder) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = localVector(text)
+	}
+	return vectors, nil
+}
+
+// localVector computes the hashed feature vector of a text
+func localVector(text string) []float32 {
+	vector := make([]float32, LocalDimensions)
+	add := func(feature string, weight float32) {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		sum := h.Sum32()
+		// Use one bit of the hash as sign to reduce collision bias
+		if sum&0x80000000 != 0 {
+			weight = -weight
+		}
+		vector[sum%LocalDimensions] += weight
+	}
+
+	for _, word := range Tokenize(text) {
+		add("w:"+word, 1)
+		padded := " " + word + " "
+		runes := []rune(padded)
+		for i := 0; i+3 <= len(runes); i++ {
+			add("c:"+string(runes[i:i+3]), 0.5)
+		}
+	}
+
+	Normalize(vector)
+	return vector
+}
+
+// Tokenize splits a text into lower-case words
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// VoyageEmbedder computes embeddings using the Voyage AI API
+type VoyageEmbedder struct {
+	logger     *logging.Logger
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// Model returns the name of the Voyage AI model
+func (e *VoyageEmbedder) Model() string {
+	return e.model
+}
+
+// Embed returns the Voyage AI embedding of each text
+func (e *VoyageEmbedder) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for i := 0; i < len(texts); i += voyageBatchSize {
+		end := i + voyageBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := e.embedBatch(texts[i:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// embedBatch sends a single request to the Voyage AI API
+func (e *VoyageEmbedder) embedBatch(texts []string) ([][]float32, error) {
+	e.logger.Debug("Requesting embeddings", "model", e.model, "count", len(texts))
+
+	reqData, err := json.Marshal(map[string]interface{}{
+		"input": texts,
+		"model": e.model,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", VoyageAPIURL, bytes.NewBuffer(reqData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send embedding request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding request failed with status %d: %s", resp.StatusCode, string(respData))
+	}
+
+	var respBody struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respData, &respBody); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal embedding response: %w", err)
+	}
+	if len(respBody.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding response contains %d vectors for %d texts", len(respBody.Data), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range respBody.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding response contains invalid index %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// Normalize scales a vector to unit length in place
+func Normalize(vector []float32) {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// Neighbor represents a candidate found by a nearest-neighbor search
+type Neighbor struct {
+	ID         string
+	Similarity float64
+}
+
+// Nearest returns the k candidates most similar to the query vector
+func Nearest(query []float32, candidates map[string][]float32, k int) []Neighbor {
+	neighbors := make([]Neighbor, 0, len(candidates))
+	for id, vector := range candidates {
+		neighbors = append(neighbors, Neighbor{ID: id, Similarity: CosineSimilarity(query, vector)})
+	}
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].Similarity != neighbors[j].Similarity {
+			return neighbors[i].Similarity > neighbors[j].Similarity
+		}
+		return neighbors[i].ID < neighbors[j].ID
+	})
+	if k > 0 && len(neighbors) > k {
+		neighbors = neighbors[:k]
+	}
+	return neighbors
+}
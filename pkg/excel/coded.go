@@ -0,0 +1,129 @@
+package excel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/input"
+	"github.com/xuri/excelize/v2"
+)
+
+// Coding is the theme assignment of a response by external coders
+type Coding struct {
+	ID     string   // Response ID, empty if the file identifies responses by row
+	Row    int      // Row of the response in the survey file, 0 if unknown
+	Themes []string // Theme names as written in the coded file
+	Locked *bool    // Whether a reviewer locked the themes, nil if the file has no locked column
+}
+
+// exportColumns are the columns of the wide export and the review sample
+// that are not themes
+var exportColumns = map[string]bool{"timestamp": true, "rating": true, "text": true, "n_themes": true, "confidence": true, "stratum": true}
+
+// ReadCodings reads the theme assignments of external coders from a CSV or
+// Excel file with a header row. Responses are identified by an "id" column,
+// such as in the wide export, or by a "row" column with the row of the
+// response in the survey file. The themes are either listed in a "themes"
+// column separated by semicolons, or every other column is a theme named by
+// its header, with 1 marking an assigned theme. The other columns of the
+// wide export are ignored. An optional "locked" column marks themes
+// confirmed by a reviewer with yes, true, x or 1.
+func (r *ExcelReader) ReadCodings(filePath string) ([]Coding, error) {
+	r.logger.Info("Reading coded file", "path", filePath)
+
+	rows, err := readTable(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("coded file %s has no data rows", filePath)
+	}
+
+	header := make([]string, len(rows[0]))
+	idColumn, rowColumn, themesColumn, lockedColumn := -1, -1, -1, -1
+	for i, name := range rows[0] {
+		header[i] = strings.TrimSpace(name)
+		switch strings.ToLower(header[i]) {
+		case "id":
+			idColumn = i
+		case "row":
+			rowColumn = i
+		case "themes":
+			themesColumn = i
+		case "locked":
+			lockedColumn = i
+		}
+	}
+	if idColumn < 0 && rowColumn < 0 {
+		return nil, fmt.Errorf("coded file %s needs an id or a row column", filePath)
+	}
+
+	var codings []Coding
+	for rowIndex, row := range rows[1:] {
+		cell := func(column int) string {
+			if column < 0 || column >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[column])
+		}
+
+		coding := Coding{ID: cell(idColumn)}
+		coding.Row, _ = strconv.Atoi(cell(rowColumn))
+		if coding.ID == "" && coding.Row == 0 {
+			r.logger.Warn("Skipping coded row without id or row", "row", rowIndex+2)
+			continue
+		}
+		if lockedColumn >= 0 {
+			locked := false
+			switch strings.ToLower(cell(lockedColumn)) {
+			case "yes", "true", "x", "1":
+				locked = true
+			}
+			coding.Locked = &locked
+		}
+
+		if themesColumn >= 0 {
+			for _, theme := range strings.Split(cell(themesColumn), ";") {
+				if theme = strings.TrimSpace(theme); theme != "" {
+					coding.Themes = append(coding.Themes, theme)
+				}
+			}
+		} else {
+			for column, name := range header {
+				if column == idColumn || column == rowColumn || column == lockedColumn || name == "" || exportColumns[strings.ToLower(name)] {
+					continue
+				}
+				if value, err := strconv.ParseFloat(cell(column), 64); err == nil && value == 1 {
+					coding.Themes = append(coding.Themes, name)
+				}
+			}
+		}
+		codings = append(codings, coding)
+	}
+
+	r.logger.Info("Read coded responses", "count", len(codings))
+	return codings, nil
+}
+
+// readTable reads the rows of a CSV file or of the first sheet of an Excel file
+func readTable(filePath string) ([][]string, error) {
+	if input.DetectFormat(filePath, "") == input.FormatCSV {
+		return input.ReadCSV(filePath)
+	}
+
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open coded file: %w", err)
+	}
+	defer f.Close()
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("no sheets found in coded file")
+	}
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coded file: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,160 @@
+package excel
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Preprocessing steps that can be applied to the response texts
+const (
+	StepRemoveArtifacts     = "remove_artifacts"
+	StepStripHTML           = "strip_html"
+	StepStripEmail          = "strip_email"
+	StepNormalizeWhitespace = "normalize_whitespace"
+	StepRemoveEmoji         = "remove_emoji"
+	StepLowercase           = "lowercase"
+	StepSpellfix            = "spellfix"
+)
+
+// PreprocessingSteps lists the valid preprocessing steps
+var PreprocessingSteps = []string{StepRemoveArtifacts, StepStripHTML, StepStripEmail, StepNormalizeWhitespace, StepRemoveEmoji, StepLowercase, StepSpellfix}
+
+var (
+	// lineBreakTags are HTML line breaks and paragraphs survey tools leave
+	// in exported answers
+	lineBreakTags = regexp.MustCompile(`(?i)<\s*(br|/?p)\s*/?\s*>`)
+	// repeatedMarks are runs of exclamation and question marks
+	repeatedMarks = regexp.MustCompile(`([!?])[!?]+`)
+	// words are the words looked up in the spelling corrections
+	words = regexp.MustCompile(`[\pL\pN']+`)
+)
+
+// Preprocessor cleans response texts before they are hashed and sent to the
+// model
+type Preprocessor struct {
+	steps       []string
+	corrections map[string]string // Lowercase misspelling to correction
+	boilerplate []*regexp.Regexp  // Text removed by the strip_email step
+}
+
+// NewPreprocessor creates a preprocessor applying the steps in the given
+// order. The spellfix step replaces the words of corrections, ignoring case,
+// and the strip_email step removes the text matching the boilerplate
+// patterns, regular expressions for the signatures and footers of an input.
+func NewPreprocessor(steps []string, corrections map[string]string, boilerplate []string) (*Preprocessor, error) {
+	for _, step := range steps {
+		if !slices.Contains(PreprocessingSteps, step) {
+			return nil, fmt.Errorf("unknown preprocessing step %q, expected one of %s", step, strings.Join(PreprocessingSteps, ", "))
+		}
+	}
+	lowercase := make(map[string]string, len(corrections))
+	for misspelling, correction := range corrections {
+		lowercase[strings.ToLower(misspelling)] = correction
+	}
+	patterns := make([]*regexp.Regexp, len(boilerplate))
+	for i, pattern := range boilerplate {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boilerplate pattern %q: %w", pattern, err)
+		}
+		patterns[i] = compiled
+	}
+	return &Preprocessor{steps: steps, corrections: lowercase, boilerplate: patterns}, nil
+}
+
+// Apply returns the preprocessed text
+func (p *Preprocessor) Apply(text string) string {
+	for _, step := range p.steps {
+		switch step {
+		case StepRemoveArtifacts:
+			text = removeArtifacts(text)
+		case StepStripHTML:
+			text = stripHTML(text)
+		case StepStripEmail:
+			text = stripEmail(text, p.boilerplate)
+		case StepNormalizeWhitespace:
+			text = strings.Join(strings.Fields(text), " ")
+		case StepRemoveEmoji:
+			text = strings.Map(func(r rune) rune {
+				if isEmoji(r) {
+					return -1
+				}
+				return r
+			}, text)
+		case StepLowercase:
+			text = strings.ToLower(text)
+		case StepSpellfix:
+			text = p.spellfix(text)
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// removeArtifacts replaces the markup survey tools leave in exported
+// answers: line break tags, HTML entities and escaped carriage returns
+func removeArtifacts(text string) string {
+	text = lineBreakTags.ReplaceAllString(text, "\n")
+	text = strings.NewReplacer("_x000D_", "", `\r\n`, "\n", `\n`, "\n", "\r\n", "\n").Replace(text)
+	text = html.UnescapeString(text)
+	return strings.ReplaceAll(text, "\u00a0", " ")
+}
+
+// isEmoji returns whether the rune is an emoji or joins emoji into one
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // Emoticons, pictographs, flags, skin tones
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // Miscellaneous symbols and dingbats
+		return true
+	case r == 0x200D || r == 0xFE0F || r == 0x20E3: // Joiner, emoji presentation, keycap
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // Tags of subdivision flags
+		return true
+	}
+	return false
+}
+
+// spellfix shortens letters repeated for emphasis to two, repeated
+// exclamation and question marks to one and replaces the configured
+// misspellings, keeping an initial capital
+func (p *Preprocessor) spellfix(text string) string {
+	text = shortenLetterRuns(text)
+	text = repeatedMarks.ReplaceAllString(text, "$1")
+	if len(p.corrections) == 0 {
+		return text
+	}
+	return words.ReplaceAllStringFunc(text, func(word string) string {
+		correction, ok := p.corrections[strings.ToLower(word)]
+		if !ok {
+			return word
+		}
+		if first := []rune(word)[0]; unicode.IsUpper(first) && correction != "" {
+			r := []rune(correction)
+			r[0] = unicode.ToUpper(r[0])
+			return string(r)
+		}
+		return correction
+	})
+}
+
+// shortenLetterRuns shortens runs of three or more of the same letter to two
+func shortenLetterRuns(text string) string {
+	var builder strings.Builder
+	var previous rune
+	run := 0
+	for _, r := range text {
+		if r == previous && unicode.IsLetter(r) {
+			run++
+		} else {
+			previous, run = r, 1
+		}
+		if run <= 2 {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
@@ -4,8 +4,13 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/oetiker/response-analyzer/pkg/input"
 	"github.com/oetiker/response-analyzer/pkg/logging"
 	"github.com/xuri/excelize/v2"
 )
@@ -13,9 +18,29 @@ import (
 // Response represents a single response from the Excel file
 type Response struct {
 	ID       string // Unique identifier for the response
-	Text     string // The response text
+	Text     string // The response text, after preprocessing
 	RowIndex int    // The row index in the Excel file (1-based)
 	Hash     string // Hash of the response text for change detection
+
+	// Submission time from the timestamp column, zero if there is none
+	Timestamp time.Time `yaml:"timestamp,omitempty"`
+	// Score from the rating column, nil if there is none
+	Rating *float64 `yaml:"rating,omitempty"`
+	// Text as read from the Excel file, if preprocessing changed it
+	Original string `yaml:"original,omitempty"`
+	// ID of the response a segment was split from, and the number of the
+	// segment within it
+	Document string `yaml:"document,omitempty"`
+	Segment  int    `yaml:"segment,omitempty"`
+}
+
+// Before reports whether the response comes before the other in the Excel
+// file: by row, and the segments of a row in their order
+func (r Response) Before(other Response) bool {
+	if r.RowIndex != other.RowIndex {
+		return r.RowIndex < other.RowIndex
+	}
+	return r.Segment < other.Segment
 }
 
 // ExcelData represents the data read from an Excel file
@@ -26,7 +51,13 @@ type ExcelData struct {
 
 // ExcelReader handles reading responses from Excel files
 type ExcelReader struct {
-	logger *logging.Logger
+	logger          *logging.Logger
+	timestampColumn string
+	ratingColumn    string
+	preprocessor    *Preprocessor
+	segmenter       *Segmenter
+	firstRow        int
+	format          string // Format of the survey file, detected from the extension if empty
 }
 
 // NewExcelReader creates a new ExcelReader instance
@@ -36,43 +67,166 @@ func NewExcelReader(logger *logging.Logger) *ExcelReader {
 	}
 }
 
-// ReadResponses reads responses from an Excel file
-func (r *ExcelReader) ReadResponses(filePath, columnLetter string) (ExcelData, error) {
-	r.logger.Info("Reading Excel file", "path", filePath, "column", columnLetter)
+// SetTimestampColumn reads the submission time of the responses from the
+// given column
+func (r *ExcelReader) SetTimestampColumn(columnLetter string) {
+	r.timestampColumn = columnLetter
+}
+
+// SetRatingColumn reads a numeric rating of the responses from the given
+// column
+func (r *ExcelReader) SetRatingColumn(columnLetter string) {
+	r.ratingColumn = columnLetter
+}
+
+// SetFirstRow skips the data rows before the given row, for reading only
+// the rows appended since a previous run. No responses are not an error then.
+func (r *ExcelReader) SetFirstRow(row int) {
+	r.firstRow = row
+}
+
+// SetInputFormat sets the format of the survey file, xlsx or csv; empty
+// detects it from the file extension
+func (r *ExcelReader) SetInputFormat(format string) {
+	r.format = format
+}
+
+// table streams the rows of the first sheet of a workbook or of a CSV file
+type table struct {
+	name  string   // Sheet or file, for messages
+	other []string // Sheets besides the one read
+	// next returns the formatted cell values of the next row and, if asked
+	// for when opening, the unformatted ones of Excel dates and numbers;
+	// io.EOF after the last row
+	next  func() (cells, raw []string, err error)
+	close func()
+}
+
+// openTable opens the survey file for reading its rows one by one, so large
+// workbooks are not loaded into memory as a whole. With raw, the cells of
+// Excel dates and numbers are also read unformatted; those of CSV files are
+// always read as they are.
+func (r *ExcelReader) openTable(filePath string, raw bool) (*table, error) {
+	if input.DetectFormat(filePath, r.format) == input.FormatCSV {
+		rows, err := input.ReadCSV(filePath)
+		if err != nil {
+			return nil, err
+		}
+		next := func() ([]string, []string, error) {
+			if len(rows) == 0 {
+				return nil, nil, io.EOF
+			}
+			row := rows[0]
+			rows = rows[1:]
+			return row, row, nil
+		}
+		return &table{name: fmt.Sprintf("file %q", filepath.Base(filePath)), next: next, close: func() {}}, nil
+	}
 
 	// Open the Excel file
 	f, err := excelize.OpenFile(filePath)
 	if err != nil {
-		return ExcelData{}, fmt.Errorf("failed to open Excel file: %w", err)
+		return nil, fmt.Errorf("failed to open Excel file: %w", err)
 	}
-	defer f.Close()
 
 	// Get the first sheet
 	sheets := f.GetSheetList()
 	if len(sheets) == 0 {
-		return ExcelData{}, fmt.Errorf("no sheets found in Excel file")
+		f.Close()
+		return nil, fmt.Errorf("no sheets found in Excel file")
 	}
 	sheetName := sheets[0]
 
+	// Stream the rows, the unformatted values with a second iterator over
+	// the same rows
+	rows, err := f.Rows(sheetName)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	var rawRows *excelize.Rows
+	if raw {
+		if rawRows, err = f.Rows(sheetName); err != nil {
+			rows.Close()
+			f.Close()
+			return nil, fmt.Errorf("failed to read rows: %w", err)
+		}
+	}
+	next := func() ([]string, []string, error) {
+		if !rows.Next() {
+			if err := rows.Error(); err != nil {
+				return nil, nil, fmt.Errorf("failed to read rows: %w", err)
+			}
+			return nil, nil, io.EOF
+		}
+		cells, err := rows.Columns()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read rows: %w", err)
+		}
+		if rawRows == nil || !rawRows.Next() {
+			return cells, nil, nil
+		}
+		rawCells, err := rawRows.Columns(excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read rows: %w", err)
+		}
+		return cells, rawCells, nil
+	}
+	closeSheet := func() {
+		rows.Close()
+		if rawRows != nil {
+			rawRows.Close()
+		}
+		f.Close()
+	}
+	return &table{name: fmt.Sprintf("sheet %q", sheetName), other: sheets[1:], next: next, close: closeSheet}, nil
+}
+
+// SetSegmenter splits long responses into segments analyzed as responses
+// of their own
+func (r *ExcelReader) SetSegmenter(segmenter *Segmenter) {
+	r.segmenter = segmenter
+}
+
+// SetPreprocessor cleans the response texts with the given preprocessor
+// before they are hashed
+func (r *ExcelReader) SetPreprocessor(preprocessor *Preprocessor) {
+	r.preprocessor = preprocessor
+}
+
+// ReadResponses reads responses from an Excel or CSV file
+func (r *ExcelReader) ReadResponses(filePath, columnLetter string) (ExcelData, error) {
+	r.logger.Info("Reading survey file", "path", filePath, "format", input.DetectFormat(filePath, r.format), "column", columnLetter)
+
 	// Convert column letter to index
 	columnIndex, err := excelize.ColumnNameToNumber(columnLetter)
 	if err != nil {
 		return ExcelData{}, fmt.Errorf("invalid column letter: %w", err)
 	}
 
-	// Read all rows
-	rows, err := f.GetRows(sheetName)
+	sheet, err := r.openTable(filePath, r.timestampColumn != "" || r.ratingColumn != "")
 	if err != nil {
-		return ExcelData{}, fmt.Errorf("failed to read rows: %w", err)
+		return ExcelData{}, err
 	}
+	defer sheet.close()
 
 	// Initialize column title
 	columnTitle := ""
 
-	// Extract responses
+	// Extract responses row by row
 	var responses []Response
-	for i, row := range rows {
-		rowIndex := i + 1 // Excel rows are 1-based
+	preprocessed, segmented := 0, 0
+	adjacent := newColumnSamples(columnIndex)
+	var emptyRows []int               // Rows without cells, only reported if followed by others
+	for rowIndex := 1; ; rowIndex++ { // Excel rows are 1-based
+		row, raw, err := sheet.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ExcelData{}, err
+		}
+		adjacent.add(row, rowIndex)
 
 		// Get column title from header row
 		if rowIndex == 1 {
@@ -81,8 +235,20 @@ func (r *ExcelReader) ReadResponses(filePath, columnLetter string) (ExcelData, e
 			}
 			continue // Skip processing header as a response
 		}
+		if rowIndex < r.firstRow {
+			continue
+		}
 
-		// Check if column exists in this row
+		// Check if column exists in this row; like empty cells at the end of
+		// the sheet, empty rows after the last row are not reported
+		if len(row) == 0 {
+			emptyRows = append(emptyRows, rowIndex)
+			continue
+		}
+		for _, emptyRow := range emptyRows {
+			r.logger.Warn("Row does not have the specified column", "row", emptyRow, "column", columnLetter)
+		}
+		emptyRows = nil
 		if len(row) < columnIndex {
 			r.logger.Warn("Row does not have the specified column", "row", rowIndex, "column", columnLetter)
 			continue
@@ -90,6 +256,10 @@ func (r *ExcelReader) ReadResponses(filePath, columnLetter string) (ExcelData, e
 
 		// Get response text
 		text := strings.TrimSpace(row[columnIndex-1])
+		original := text
+		if r.preprocessor != nil {
+			text = r.preprocessor.Apply(text)
+		}
 		if text == "" {
 			r.logger.Debug("Empty response", "row", rowIndex)
 			continue
@@ -103,21 +273,144 @@ func (r *ExcelReader) ReadResponses(filePath, columnLetter string) (ExcelData, e
 			RowIndex: rowIndex,
 			Hash:     hash,
 		}
+		if text != original {
+			response.Original = original
+			preprocessed++
+		}
+
+		if r.timestampColumn != "" {
+			response.Timestamp = r.readTimestamp(raw, rowIndex)
+		}
+		if r.ratingColumn != "" {
+			response.Rating = r.readRating(raw, rowIndex)
+		}
 
-		responses = append(responses, response)
+		if r.segmenter == nil {
+			responses = append(responses, response)
+			continue
+		}
+		segments, err := r.segmenter.Split(text)
+		if err != nil {
+			return ExcelData{}, fmt.Errorf("failed to segment response of row %d: %w", rowIndex, err)
+		}
+		if len(segments) == 1 {
+			responses = append(responses, response)
+			continue
+		}
+		for i, segment := range segments {
+			part := response
+			part.ID = fmt.Sprintf("%s.%d", response.ID, i+1)
+			part.Text = segment
+			part.Hash = hashText(segment)
+			part.Original = ""
+			part.Document = response.ID
+			part.Segment = i + 1
+			responses = append(responses, part)
+		}
+		segmented++
 	}
 
-	r.logger.Info("Read responses from Excel file", "count", len(responses), "column_title", columnTitle)
+	// Refuse to continue without responses, most likely the column or sheet is wrong
+	if len(responses) == 0 && r.firstRow == 0 {
+		return ExcelData{}, emptyColumnError(sheet, columnLetter, adjacent)
+	}
+
+	if segmented > 0 {
+		r.logger.Info("Split long responses into segments", "documents", segmented, "responses", len(responses))
+	}
+	if preprocessed > 0 {
+		r.logger.Info("Preprocessed response texts", "changed", preprocessed)
+	}
+	r.logger.Info("Read responses from survey file", "count", len(responses), "column_title", columnTitle)
 	return ExcelData{
 		Responses:   responses,
 		ColumnTitle: columnTitle,
 	}, nil
 }
 
-// ValidateExcelFile validates that the Excel file exists and has the specified column
+// timestampLayouts are the date formats accepted in the timestamp column
+// besides Excel dates
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	"1/2/2006 15:04:05",
+	"1/2/2006 15:04",
+	"1/2/2006",
+}
+
+// readTimestamp reads the timestamp of a row, returning the zero time if the
+// cell is empty or not a date
+func (r *ExcelReader) readTimestamp(raw []string, rowIndex int) time.Time {
+	cell := fmt.Sprintf("%s%d", r.timestampColumn, rowIndex)
+	value := strings.TrimSpace(cellValue(raw, r.timestampColumn))
+	if value == "" {
+		return time.Time{}
+	}
+
+	// Dates formatted as such are stored as serial numbers
+	if serial, err := strconv.ParseFloat(value, 64); err == nil {
+		if timestamp, err := excelize.ExcelDateToTime(serial, false); err == nil {
+			return timestamp
+		}
+	}
+	for _, layout := range timestampLayouts {
+		if timestamp, err := time.Parse(layout, value); err == nil {
+			return timestamp
+		}
+	}
+	r.logger.Warn("Unreadable timestamp, response is left out of the time series", "cell", cell, "value", value)
+	return time.Time{}
+}
+
+// readRating reads the rating of a row, returning nil if the cell is empty
+// or not a number
+func (r *ExcelReader) readRating(raw []string, rowIndex int) *float64 {
+	cell := fmt.Sprintf("%s%d", r.ratingColumn, rowIndex)
+	value := strings.TrimSpace(cellValue(raw, r.ratingColumn))
+	if value == "" {
+		return nil
+	}
+
+	// Accept a decimal comma as used in German speaking countries
+	rating, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		r.logger.Warn("Unreadable rating, response is left out of the rating statistics", "cell", cell, "value", value)
+		return nil
+	}
+	return &rating
+}
+
+// cellValue returns the value of a column of a row, empty if the row is
+// shorter
+func cellValue(row []string, columnLetter string) string {
+	columnIndex, err := excelize.ColumnNameToNumber(columnLetter)
+	if err != nil || len(row) < columnIndex {
+		return ""
+	}
+	return row[columnIndex-1]
+}
+
+// ValidateExcelFile validates that the Excel or CSV file can be read and
+// the column letter is valid
 func (r *ExcelReader) ValidateExcelFile(filePath, columnLetter string) error {
 	r.logger.Info("Validating Excel file", "path", filePath, "column", columnLetter)
 
+	if input.DetectFormat(filePath, r.format) == input.FormatCSV {
+		if _, err := excelize.ColumnNameToNumber(columnLetter); err != nil {
+			return fmt.Errorf("invalid column letter: %w", err)
+		}
+		if _, err := input.ReadCSV(filePath); err != nil {
+			return err
+		}
+		r.logger.Info("CSV file validation successful")
+		return nil
+	}
+
 	// Check if file exists and can be opened
 	f, err := excelize.OpenFile(filePath)
 	if err != nil {
@@ -141,6 +434,109 @@ func (r *ExcelReader) ValidateExcelFile(filePath, columnLetter string) error {
 	return nil
 }
 
+// maxSampleLength limits the length of the cell values quoted in errors
+const maxSampleLength = 40
+
+// columnSample is the header, the first value and the number of non-empty
+// values of a column
+type columnSample struct {
+	header, value string
+	count         int
+}
+
+// columnSamples collects the samples of the columns next to the response
+// column while the rows are read, to describe a response column without
+// responses without reading the file again
+type columnSamples struct {
+	columnIndex int
+	columns     map[int]*columnSample
+	rows        int // Up to the last row with cells
+}
+
+// newColumnSamples returns the samples of the two columns on either side of
+// the given one
+func newColumnSamples(columnIndex int) *columnSamples {
+	samples := &columnSamples{columnIndex: columnIndex, columns: make(map[int]*columnSample)}
+	for index := columnIndex - 2; index <= columnIndex+2; index++ {
+		if index >= 1 && index != columnIndex {
+			samples.columns[index] = &columnSample{}
+		}
+	}
+	return samples
+}
+
+// add takes the cells of a row into the samples
+func (s *columnSamples) add(row []string, rowIndex int) {
+	if len(row) > 0 {
+		s.rows = rowIndex
+	}
+	for index, sample := range s.columns {
+		if len(row) < index {
+			continue
+		}
+		text := strings.TrimSpace(row[index-1])
+		if rowIndex == 1 {
+			sample.header = text
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		if sample.count == 0 {
+			sample.value = text
+		}
+		sample.count++
+	}
+}
+
+// emptyColumnError describes a column without responses together with a
+// sample of the adjacent columns to help spot a wrong column or sheet
+func emptyColumnError(sheet *table, columnLetter string, adjacent *columnSamples) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "column %s of %s contains no responses", columnLetter, sheet.name)
+	switch {
+	case adjacent.rows == 0:
+		b.WriteString(" (it is empty)")
+	case adjacent.rows == 1:
+		b.WriteString(" (it only has a header row)")
+	default:
+		fmt.Fprintf(&b, " in %d data rows", adjacent.rows-1)
+	}
+
+	// Sample the header and first value of the adjacent columns
+	var samples []string
+	for index := adjacent.columnIndex - 2; index <= adjacent.columnIndex+2; index++ {
+		sample, ok := adjacent.columns[index]
+		if !ok || (sample.header == "" && sample.count == 0) {
+			continue
+		}
+		name, err := excelize.ColumnNumberToName(index)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, fmt.Sprintf("column %s %q: %d values, e.g. %q", name, truncate(sample.header), sample.count, truncate(sample.value)))
+	}
+	if len(samples) > 0 {
+		b.WriteString("; adjacent columns: ")
+		b.WriteString(strings.Join(samples, ", "))
+	}
+
+	if len(sheet.other) > 0 {
+		fmt.Fprintf(&b, "; only the first sheet is read, the file also has %s", strings.Join(sheet.other, ", "))
+	}
+	b.WriteString("; check response_column and excel_file_path")
+	return fmt.Errorf("%s", b.String())
+}
+
+// truncate shortens a cell value for quoting in errors
+func truncate(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxSampleLength {
+		return text
+	}
+	return string(runes[:maxSampleLength]) + "..."
+}
+
 // hashText creates a hash of the text for change detection
 func hashText(text string) string {
 	hash := sha256.Sum256([]byte(text))
@@ -0,0 +1,81 @@
+package excel
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlComments, htmlScripts and htmlStyles are removed with their content
+	htmlComments = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlScripts  = regexp.MustCompile(`(?is)<script\b.*?</script\s*>`)
+	htmlStyles   = regexp.MustCompile(`(?is)<style\b.*?</style\s*>`)
+	// htmlBlocks are tags that start a new line of text
+	htmlBlocks = regexp.MustCompile(`(?i)</?(p|div|br|li|ul|ol|tr|table|h[1-6]|blockquote|hr|section|article|header|footer)\b[^>]*>`)
+	// htmlTags are all other tags; a tag starts with a letter, so text like
+	// "<3" or "a < b" is kept
+	htmlTags = regexp.MustCompile(`</?[a-zA-Z][^>]*>|<![^>]*>`)
+	// blankLines are runs of empty lines
+	blankLines = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+\n`)
+	// trailingSpace is the space at the end of a line
+	trailingSpace = regexp.MustCompile(`[ \t]+\n`)
+)
+
+var (
+	// emailCutoff are lines after which only quoted mail, signatures or
+	// mail client footers follow
+	emailCutoff = regexp.MustCompile(`(?i)^(-{2,}\s*$|_{5,}\s*$|-+\s*(original message|forwarded message|ursprüngliche nachricht|weitergeleitete nachricht|message d'origine|messaggio originale)\s*-+|(on|am|le|il)\s.{0,160}(wrote|schrieb|a écrit|ha scritto)\s*:|sent from my\s|von meinem\s.*gesendet|envoyé de mon\s|inviato da\s)`)
+	// emailHeaders are header lines at the start of a pasted mail
+	emailHeaders = regexp.MustCompile(`(?i)^(from|to|cc|bcc|subject|sent|date|von|an|betreff|gesendet|datum|de|à|objet|envoyé|da|a|oggetto|inviato)\s*:`)
+	// emailClosings are salutations followed by the name and signature of
+	// the sender
+	emailClosings = regexp.MustCompile(`(?i)^((best|kind|warm|many)\s+)?(regards|wishes|thanks)[,.!]?$|^(cheers|sincerely|yours sincerely|yours truly)[,.!]?$|^((mit\s+)?(freundlichen|besten|lieben|herzlichen)\s+grü(ss|ß)en|viele grü(ss|ß)e|lg|mfg|gruss|gruß)[,.!]?$|^(cordialement|bien à vous|salutations distinguées|cordiali saluti|distinti saluti|saluti)[,.!]?$`)
+	// emailDisclaimers start the legal notices appended to mails
+	emailDisclaimers = regexp.MustCompile(`(?i)(this|the information in this) (e-?mail|message).{0,100}(confidential|intended (only|solely))|(diese|der inhalt dieser) (e-?mail|nachricht).{0,100}vertraulich|ce (courriel|message).{0,100}confidentiel|questa (e-?mail|comunicazione).{0,100}riservat`)
+)
+
+// maxClosingDistance is the number of lines a closing may be from the end
+// of a mail to be treated as the start of its signature
+const maxClosingDistance = 6
+
+// stripHTML removes the markup of HTML fragments, keeping block elements as
+// line breaks
+func stripHTML(text string) string {
+	text = htmlComments.ReplaceAllString(text, "")
+	text = htmlScripts.ReplaceAllString(text, "")
+	text = htmlStyles.ReplaceAllString(text, "")
+	text = htmlBlocks.ReplaceAllString(text, "\n")
+	text = htmlTags.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+	text = trailingSpace.ReplaceAllString(text, "\n")
+	return blankLines.ReplaceAllString(text, "\n\n")
+}
+
+// stripEmail removes the boilerplate of responses pasted from mails: header
+// lines, quoted text, earlier messages of the thread, closings with the
+// signature and legal disclaimers. Text matching one of the patterns is
+// removed first.
+func stripEmail(text string, patterns []*regexp.Regexp) string {
+	for _, pattern := range patterns {
+		text = pattern.ReplaceAllString(text, "")
+	}
+	lines := strings.Split(text, "\n")
+	var kept []string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case len(kept) == 0 && (trimmed == "" || emailHeaders.MatchString(trimmed)):
+			continue
+		case strings.HasPrefix(trimmed, ">"):
+			continue
+		case emailCutoff.MatchString(trimmed) || emailDisclaimers.MatchString(trimmed):
+			return strings.Join(kept, "\n")
+		case len(kept) > 0 && emailClosings.MatchString(trimmed) && len(lines)-i <= maxClosingDistance:
+			return strings.Join(kept, "\n")
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,153 @@
+package excel
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/embedding"
+)
+
+// Units long responses are split into
+const (
+	SegmentParagraph = "paragraph" // Paragraphs separated by blank lines
+	SegmentSentence  = "sentence"  // Sentences
+	SegmentSemantic  = "semantic"  // Runs of consecutive sentences on the same topic
+)
+
+// minSegmentLength is the length below which a segment is merged into the
+// one before, so headings and fragments do not become responses
+const minSegmentLength = 20
+
+var (
+	// paragraphBreaks are blank lines
+	paragraphBreaks = regexp.MustCompile(`\n[ \t]*\n\s*`)
+	// sentenceEnds are the ends of sentences, followed by space and the
+	// start of the next sentence, or line breaks
+	sentenceEnds = regexp.MustCompile(`[.!?…]["'”»)]*\s+["'“«(]*[\p{Lu}\p{N}]|\n+`)
+)
+
+// Segmenter splits long responses, such as documents or letters, into
+// segments that are analyzed as responses of their own, so the theme
+// statistics count ideas instead of documents
+type Segmenter struct {
+	mode      string
+	minLength int
+	embed     func(texts []string) ([][]float32, error)
+	threshold float64
+}
+
+// NewSegmenter creates a segmenter splitting responses of at least
+// minLength characters by paragraph, sentence or semantic chunk
+func NewSegmenter(mode string, minLength int) *Segmenter {
+	return &Segmenter{mode: mode, minLength: minLength}
+}
+
+// SetEmbedder sets the embedding function of semantic segmentation. A new
+// segment starts at a sentence less similar than the threshold to the
+// sentences of the current segment.
+func (s *Segmenter) SetEmbedder(embed func(texts []string) ([][]float32, error), threshold float64) {
+	s.embed = embed
+	s.threshold = threshold
+}
+
+// Split returns the segments of a text, or the text itself if it is shorter
+// than the minimum length or has a single segment
+func (s *Segmenter) Split(text string) ([]string, error) {
+	if len(text) < s.minLength {
+		return []string{text}, nil
+	}
+	var segments []string
+	switch s.mode {
+	case SegmentParagraph:
+		segments = paragraphBreaks.Split(text, -1)
+	case SegmentSemantic:
+		var err error
+		segments, err = s.semanticChunks(splitSentences(text))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		segments = splitSentences(text)
+	}
+	return mergeShort(segments), nil
+}
+
+// splitSentences splits a text after the end of each sentence
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, match := range sentenceEnds.FindAllStringIndex(text, -1) {
+		// Keep the first letter of the next sentence in the next sentence
+		end := match[1]
+		if text[match[0]] != '\n' {
+			end = match[0] + strings.IndexFunc(text[match[0]:match[1]], isSpace)
+		}
+		sentences = append(sentences, text[start:end])
+		start = end
+	}
+	return append(sentences, text[start:])
+}
+
+// isSpace reports whether a rune is white space
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// semanticChunks groups consecutive sentences while they are similar to the
+// mean of the sentences of the current chunk
+func (s *Segmenter) semanticChunks(sentences []string) ([]string, error) {
+	if s.embed == nil || len(sentences) < 2 {
+		return sentences, nil
+	}
+	vectors, err := s.embed(sentences)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []string
+	chunk := []string{sentences[0]}
+	centroid := append([]float32(nil), vectors[0]...)
+	for i := 1; i < len(sentences); i++ {
+		if embedding.CosineSimilarity(centroid, vectors[i]) < s.threshold {
+			chunks = append(chunks, strings.Join(chunk, " "))
+			chunk = nil
+			centroid = make([]float32, len(vectors[i]))
+		}
+		chunk = append(chunk, sentences[i])
+		for j := range centroid {
+			if j < len(vectors[i]) {
+				centroid[j] += vectors[i][j]
+			}
+		}
+	}
+	return append(chunks, strings.Join(chunk, " ")), nil
+}
+
+// mergeShort trims the segments, drops empty ones and merges those shorter
+// than minSegmentLength into the segment before, or the first into the next
+func mergeShort(segments []string) []string {
+	var merged []string
+	carry := ""
+	for _, segment := range segments {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+		if carry != "" {
+			segment = carry + " " + segment
+			carry = ""
+		}
+		if len(segment) < minSegmentLength {
+			if len(merged) > 0 {
+				merged[len(merged)-1] += " " + segment
+			} else {
+				carry = segment
+			}
+			continue
+		}
+		merged = append(merged, segment)
+	}
+	if carry != "" {
+		merged = append(merged, carry)
+	}
+	return merged
+}
@@ -0,0 +1,131 @@
+package helpdesk
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// freshdeskSource fetches the description and conversations of Freshdesk
+// tickets, all tickets or those matching a filter query. Filter queries
+// return at most 300 tickets.
+type freshdeskSource struct {
+	*client
+	query string
+}
+
+// freshdeskTicket is a Freshdesk ticket with its requester
+type freshdeskTicket struct {
+	ID              int64     `json:"id"`
+	Subject         string    `json:"subject"`
+	CreatedAt       time.Time `json:"created_at"`
+	RequesterID     int64     `json:"requester_id"`
+	DescriptionText string    `json:"description_text"`
+	Requester       struct {
+		Name string `json:"name"`
+	} `json:"requester"`
+}
+
+// freshdeskConversation is a reply or note of a Freshdesk ticket
+type freshdeskConversation struct {
+	ID        int64     `json:"id"`
+	BodyText  string    `json:"body_text"`
+	Private   bool      `json:"private"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Name identifies the helpdesk in log messages
+func (s *freshdeskSource) Name() string {
+	return "Freshdesk " + s.base.Host
+}
+
+// Fetch returns the selected comments of the tickets
+func (s *freshdeskSource) Fetch() ([]Comment, error) {
+	ids, err := s.ticketIDs()
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Found tickets", "helpdesk", s.Name(), "tickets", len(ids))
+
+	var comments []Comment
+	for _, id := range ids {
+		var ticket freshdeskTicket
+		if err := s.getJSON(s.endpoint(fmt.Sprintf("api/v2/tickets/%d", id), url.Values{"include": {"requester"}}), &ticket); err != nil {
+			return nil, fmt.Errorf("failed to fetch ticket %d: %w", id, err)
+		}
+		newComment := func(commentID string, created time.Time, text string) Comment {
+			return Comment{
+				Ticket:    fmt.Sprintf("%d", ticket.ID),
+				ID:        commentID,
+				Created:   created,
+				Requester: ticket.Requester.Name,
+				Subject:   ticket.Subject,
+				Text:      text,
+			}
+		}
+		// The description is the first comment, written by the requester
+		if strings.TrimSpace(ticket.DescriptionText) != "" {
+			comments = append(comments, newComment(descriptionID, ticket.CreatedAt, ticket.DescriptionText))
+		}
+		if s.comments == CommentsFirst {
+			continue
+		}
+
+		for page := 1; ; page++ {
+			var conversations []freshdeskConversation
+			query := url.Values{"per_page": {"100"}, "page": {strconv.Itoa(page)}}
+			if err := s.getJSON(s.endpoint(fmt.Sprintf("api/v2/tickets/%d/conversations", id), query), &conversations); err != nil {
+				return nil, fmt.Errorf("failed to fetch the conversations of ticket %d: %w", id, err)
+			}
+			for _, conversation := range conversations {
+				if conversation.Private || !s.keep(false, conversation.UserID == ticket.RequesterID) || strings.TrimSpace(conversation.BodyText) == "" {
+					continue
+				}
+				comments = append(comments, newComment(fmt.Sprintf("%d", conversation.ID), conversation.CreatedAt, conversation.BodyText))
+			}
+			if len(conversations) < 100 {
+				break
+			}
+		}
+	}
+	return comments, nil
+}
+
+// ticketIDs returns the IDs of the tickets matching the query, or of all
+// tickets, oldest first
+func (s *freshdeskSource) ticketIDs() ([]int64, error) {
+	var ids []int64
+	for page := 1; !s.enough(len(ids)); page++ {
+		var tickets []freshdeskTicket
+		if s.query != "" {
+			// Filter queries are quoted and return 30 tickets per page
+			var result struct {
+				Results []freshdeskTicket `json:"results"`
+			}
+			query := url.Values{"query": {`"` + s.query + `"`}, "page": {strconv.Itoa(page)}}
+			if err := s.getJSON(s.endpoint("api/v2/search/tickets", query), &result); err != nil {
+				return nil, fmt.Errorf("failed to search tickets: %w", err)
+			}
+			tickets = result.Results
+		} else {
+			// Without updated_since only the tickets of the last 30 days are listed
+			query := url.Values{"updated_since": {"2000-01-01T00:00:00Z"}, "order_by": {"created_at"}, "order_type": {"asc"}, "per_page": {"100"}, "page": {strconv.Itoa(page)}}
+			if err := s.getJSON(s.endpoint("api/v2/tickets", query), &tickets); err != nil {
+				return nil, fmt.Errorf("failed to list tickets: %w", err)
+			}
+		}
+		for _, ticket := range tickets {
+			ids = append(ids, ticket.ID)
+		}
+		if len(tickets) == 0 || (s.query != "" && page == 10) {
+			break
+		}
+	}
+	if s.enough(len(ids)) {
+		ids = ids[:s.maxTickets]
+	}
+	return ids, nil
+}
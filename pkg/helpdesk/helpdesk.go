@@ -0,0 +1,175 @@
+package helpdesk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// Kinds of helpdesks
+const (
+	TypeZendesk   = "zendesk"
+	TypeFreshdesk = "freshdesk"
+	TypeJira      = "jira" // Jira Service Management
+)
+
+// Comments of a ticket that are imported
+const (
+	CommentsFirst     = "first"     // Only the first comment, which opened the ticket
+	CommentsRequester = "requester" // The public comments of the requester
+	CommentsAll       = "all"       // All public comments, including those of agents
+)
+
+// descriptionID is the comment ID of the description of a ticket, for
+// helpdesks that keep it apart from the comments
+const descriptionID = "description"
+
+// maxRetries limits the retries of a request the helpdesk rate limited
+const maxRetries = 5
+
+// Comment is a comment of a ticket
+type Comment struct {
+	Ticket    string // Ticket number or issue key
+	ID        string // ID of the comment within the ticket
+	Created   time.Time
+	Requester string // Name of the requester of the ticket
+	Subject   string // Subject of the ticket
+	Text      string
+}
+
+// Source fetches the comments of the tickets of a helpdesk
+type Source interface {
+	// Name identifies the helpdesk in log messages
+	Name() string
+	// Fetch returns the selected comments of the selected tickets
+	Fetch() ([]Comment, error)
+}
+
+// New creates the source of the configured kind of helpdesk
+func New(logger *logging.Logger, cfg config.HelpdeskSource) (Source, error) {
+	base, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid helpdesk url %q", cfg.URL)
+	}
+	c := &client{
+		logger:     logger,
+		base:       base,
+		username:   cfg.Username,
+		password:   cfg.APIToken,
+		comments:   cfg.Comments,
+		maxTickets: cfg.MaxTickets,
+		httpClient: &http.Client{Timeout: time.Minute},
+	}
+	switch c.comments {
+	case "":
+		c.comments = CommentsRequester
+	case CommentsFirst, CommentsRequester, CommentsAll:
+	default:
+		return nil, fmt.Errorf("invalid helpdesk comments: %s (valid options: first, requester, all)", cfg.Comments)
+	}
+	switch cfg.Type {
+	case TypeZendesk:
+		// Zendesk authenticates API tokens as "email/token"
+		c.username += "/token"
+		return &zendeskSource{client: c, query: cfg.Query}, nil
+	case TypeFreshdesk:
+		// Freshdesk takes the API key as user name with any password
+		c.username, c.password = cfg.APIToken, "X"
+		return &freshdeskSource{client: c, query: cfg.Query}, nil
+	case TypeJira:
+		return &jiraSource{client: c, jql: cfg.Query}, nil
+	default:
+		return nil, fmt.Errorf("unsupported helpdesk type %q, use zendesk, freshdesk or jira", cfg.Type)
+	}
+}
+
+// client holds the connection and the settings shared by the helpdesks
+type client struct {
+	logger     *logging.Logger
+	base       *url.URL
+	username   string
+	password   string
+	comments   string
+	maxTickets int
+	httpClient *http.Client
+}
+
+// endpoint returns the URL of an API path with the given query parameters
+func (c *client) endpoint(path string, query url.Values) string {
+	target := c.base.JoinPath(path)
+	target.RawQuery = query.Encode()
+	return target.String()
+}
+
+// getJSON fetches a URL and decodes the JSON answer into v. Rate limited
+// requests are retried after the time the helpdesk asks for.
+func (c *client) getJSON(target string, v any) error {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(c.username, c.password)
+		req.Header.Set("Accept", "application/json")
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s: %w", redact(target), err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read answer of %s: %w", redact(target), err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRetries {
+			wait := 60 * time.Second
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(seconds) * time.Second
+			}
+			c.logger.Warn("Helpdesk rate limit reached, waiting", "wait", wait, "attempt", attempt+1)
+			time.Sleep(wait)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("request to %s failed with status %s: %s", redact(target), resp.Status, strings.TrimSpace(string(body[:min(len(body), 200)])))
+		}
+		if err := json.Unmarshal(body, v); err != nil {
+			return fmt.Errorf("failed to parse answer of %s: %w", redact(target), err)
+		}
+		return nil
+	}
+}
+
+// enough returns whether the configured number of tickets was fetched
+func (c *client) enough(tickets int) bool {
+	return c.maxTickets > 0 && tickets >= c.maxTickets
+}
+
+// keep returns whether a public comment is imported
+func (c *client) keep(first, byRequester bool) bool {
+	switch c.comments {
+	case CommentsFirst:
+		return first
+	case CommentsAll:
+		return true
+	default:
+		return byRequester
+	}
+}
+
+// redact removes the query of a URL for error messages
+func redact(target string) string {
+	if parsed, err := url.Parse(target); err == nil {
+		parsed.RawQuery = ""
+		return parsed.Redacted()
+	}
+	return target
+}
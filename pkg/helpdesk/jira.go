@@ -0,0 +1,129 @@
+package helpdesk
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jiraTimeLayout is the format of the timestamps of the Jira API
+const jiraTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// jiraSource fetches the description and comments of Jira Service Management
+// requests selected by a JQL query. The reporter of a request is taken as
+// its requester.
+type jiraSource struct {
+	*client
+	jql string
+}
+
+// jiraUser is the reporter or the author of a comment
+type jiraUser struct {
+	AccountID   string `json:"accountId"`
+	DisplayName string `json:"displayName"`
+}
+
+// jiraIssue is a request with the fields the import needs
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string   `json:"summary"`
+		Created     string   `json:"created"`
+		Description string   `json:"description"`
+		Reporter    jiraUser `json:"reporter"`
+	} `json:"fields"`
+}
+
+// jiraComment is a comment of a request; internal comments are not public
+type jiraComment struct {
+	ID        string   `json:"id"`
+	Author    jiraUser `json:"author"`
+	Body      string   `json:"body"`
+	Created   string   `json:"created"`
+	JSDPublic *bool    `json:"jsdPublic"`
+}
+
+// Name identifies the helpdesk in log messages
+func (s *jiraSource) Name() string {
+	return "Jira " + s.base.Host
+}
+
+// Fetch returns the selected comments of the requests matching the query
+func (s *jiraSource) Fetch() ([]Comment, error) {
+	jql := s.jql
+	if !strings.Contains(strings.ToLower(jql), "order by") {
+		jql = strings.TrimSpace(jql + " ORDER BY created ASC")
+	}
+
+	// The version 2 API returns the texts as plain text with wiki markup
+	// instead of the document format of version 3
+	var issues []jiraIssue
+	for !s.enough(len(issues)) {
+		var page struct {
+			Issues []jiraIssue `json:"issues"`
+			Total  int         `json:"total"`
+		}
+		query := url.Values{"jql": {jql}, "fields": {"summary,created,description,reporter"}, "startAt": {strconv.Itoa(len(issues))}, "maxResults": {"100"}}
+		if err := s.getJSON(s.endpoint("rest/api/2/search", query), &page); err != nil {
+			return nil, fmt.Errorf("failed to search requests: %w", err)
+		}
+		issues = append(issues, page.Issues...)
+		if len(page.Issues) == 0 || len(issues) >= page.Total {
+			break
+		}
+	}
+	if s.enough(len(issues)) {
+		issues = issues[:s.maxTickets]
+	}
+	s.logger.Info("Found tickets", "helpdesk", s.Name(), "tickets", len(issues))
+
+	var comments []Comment
+	for _, issue := range issues {
+		newComment := func(commentID, created, text string) Comment {
+			timestamp, err := time.Parse(jiraTimeLayout, created)
+			if err != nil {
+				s.logger.Warn("Unreadable comment time", "ticket", issue.Key, "time", created)
+			}
+			return Comment{
+				Ticket:    issue.Key,
+				ID:        commentID,
+				Created:   timestamp,
+				Requester: issue.Fields.Reporter.DisplayName,
+				Subject:   issue.Fields.Summary,
+				Text:      text,
+			}
+		}
+		// The description is the first comment, written by the reporter
+		if strings.TrimSpace(issue.Fields.Description) != "" {
+			comments = append(comments, newComment(descriptionID, issue.Fields.Created, issue.Fields.Description))
+		}
+		if s.comments == CommentsFirst {
+			continue
+		}
+
+		for startAt := 0; ; {
+			var page struct {
+				Comments []jiraComment `json:"comments"`
+				Total    int           `json:"total"`
+			}
+			query := url.Values{"startAt": {strconv.Itoa(startAt)}, "maxResults": {"100"}}
+			if err := s.getJSON(s.endpoint(fmt.Sprintf("rest/api/2/issue/%s/comment", issue.Key), query), &page); err != nil {
+				return nil, fmt.Errorf("failed to fetch the comments of %s: %w", issue.Key, err)
+			}
+			for _, comment := range page.Comments {
+				public := comment.JSDPublic == nil || *comment.JSDPublic
+				if !public || !s.keep(false, comment.Author.AccountID == issue.Fields.Reporter.AccountID) || strings.TrimSpace(comment.Body) == "" {
+					continue
+				}
+				comments = append(comments, newComment(comment.ID, comment.Created, comment.Body))
+			}
+			startAt += len(page.Comments)
+			if len(page.Comments) == 0 || startAt >= page.Total {
+				break
+			}
+		}
+	}
+	return comments, nil
+}
@@ -0,0 +1,103 @@
+package helpdesk
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// zendeskSource fetches the comments of Zendesk tickets selected by a search
+// query. The Zendesk search returns at most 1000 tickets, narrow the query,
+// e.g. by created date, for more.
+type zendeskSource struct {
+	*client
+	query string
+}
+
+// zendeskTicket is a ticket in a Zendesk search result
+type zendeskTicket struct {
+	ID          int64     `json:"id"`
+	Subject     string    `json:"subject"`
+	CreatedAt   time.Time `json:"created_at"`
+	RequesterID int64     `json:"requester_id"`
+}
+
+// zendeskComment is a comment of a Zendesk ticket
+type zendeskComment struct {
+	ID        int64     `json:"id"`
+	AuthorID  int64     `json:"author_id"`
+	PlainBody string    `json:"plain_body"`
+	Public    bool      `json:"public"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Name identifies the helpdesk in log messages
+func (s *zendeskSource) Name() string {
+	return "Zendesk " + s.base.Host
+}
+
+// Fetch returns the selected comments of the tickets matching the query
+func (s *zendeskSource) Fetch() ([]Comment, error) {
+	query := "type:ticket"
+	if s.query != "" {
+		query += " " + s.query
+	}
+	var tickets []zendeskTicket
+	next := s.endpoint("api/v2/search.json", url.Values{"query": {query}, "sort_by": {"created_at"}, "sort_order": {"asc"}})
+	for next != "" && !s.enough(len(tickets)) {
+		var page struct {
+			Results  []zendeskTicket `json:"results"`
+			NextPage string          `json:"next_page"`
+		}
+		if err := s.getJSON(next, &page); err != nil {
+			return nil, fmt.Errorf("failed to search tickets: %w", err)
+		}
+		tickets = append(tickets, page.Results...)
+		next = page.NextPage
+	}
+	if s.enough(len(tickets)) {
+		tickets = tickets[:s.maxTickets]
+	}
+	s.logger.Info("Found tickets", "helpdesk", s.Name(), "tickets", len(tickets))
+
+	var comments []Comment
+	for _, ticket := range tickets {
+		users := make(map[int64]string)
+		first := true
+		next := s.endpoint(fmt.Sprintf("api/v2/tickets/%d/comments.json", ticket.ID), url.Values{"include": {"users"}})
+		for next != "" {
+			var page struct {
+				Comments []zendeskComment `json:"comments"`
+				Users    []struct {
+					ID   int64  `json:"id"`
+					Name string `json:"name"`
+				} `json:"users"`
+				NextPage string `json:"next_page"`
+			}
+			if err := s.getJSON(next, &page); err != nil {
+				return nil, fmt.Errorf("failed to fetch the comments of ticket %d: %w", ticket.ID, err)
+			}
+			for _, user := range page.Users {
+				users[user.ID] = user.Name
+			}
+			for _, comment := range page.Comments {
+				keep := comment.Public && s.keep(first, comment.AuthorID == ticket.RequesterID)
+				first = false
+				if !keep || strings.TrimSpace(comment.PlainBody) == "" {
+					continue
+				}
+				comments = append(comments, Comment{
+					Ticket:    fmt.Sprintf("%d", ticket.ID),
+					ID:        fmt.Sprintf("%d", comment.ID),
+					Created:   comment.CreatedAt,
+					Requester: users[ticket.RequesterID],
+					Subject:   ticket.Subject,
+					Text:      comment.PlainBody,
+				})
+			}
+			next = page.NextPage
+		}
+	}
+	return comments, nil
+}
@@ -0,0 +1,93 @@
+// Package input reads the survey file in the formats survey tools export,
+// as rows of cell values with the header in the first row.
+package input
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Formats of the survey file
+const (
+	FormatXLSX = "xlsx"
+	FormatCSV  = "csv"
+)
+
+// Formats lists the valid input formats
+var Formats = []string{FormatXLSX, FormatCSV}
+
+// DetectFormat returns the format of the survey file: the configured format,
+// or csv for files ending in .csv or .tsv and xlsx otherwise
+func DetectFormat(filePath, format string) string {
+	if format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv", ".tsv":
+		return FormatCSV
+	default:
+		return FormatXLSX
+	}
+}
+
+// utf8BOM is the byte order mark some tools write at the start of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ReadCSV reads the rows of a UTF-8 encoded CSV file. The delimiter is
+// detected from the header row: comma, semicolon (as written in locales
+// with a decimal comma) or tab. Quoted cells may span several lines, so a
+// row is a record of the file, which is what a spreadsheet shows as row.
+func ReadCSV(filePath string) ([][]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("CSV file %s is not UTF-8 encoded, export it as UTF-8", filePath)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1 // Survey tools leave out trailing empty cells
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV file: %w", err)
+	}
+	return rows, nil
+}
+
+// detectDelimiter returns the most frequent of the candidate delimiters
+// outside of quotes in the first row, comma if there is none
+func detectDelimiter(data []byte) rune {
+	counts := map[rune]int{}
+	quoted := false
+	for _, c := range string(data) {
+		if c == '"' {
+			quoted = !quoted
+			continue
+		}
+		if quoted {
+			continue
+		}
+		if c == '\n' {
+			break
+		}
+		if c == ',' || c == ';' || c == '\t' {
+			counts[c]++
+		}
+	}
+	delimiter := ','
+	for _, candidate := range []rune{';', '\t'} {
+		if counts[candidate] > counts[delimiter] {
+			delimiter = candidate
+		}
+	}
+	return delimiter
+}
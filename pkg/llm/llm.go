@@ -0,0 +1,66 @@
+// Package llm is the interface between the prompts of the analysis and the
+// APIs of the language model providers. A provider encodes completion
+// requests for its API and decodes the answers and token usage; sending,
+// retries, caching and cost accounting are shared.
+package llm
+
+import "net/http"
+
+// Names of the providers
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+)
+
+// Reasons an answer stopped early, in the terms of every provider
+const (
+	StopMaxTokens = "max_tokens" // Cut off at the token limit
+	StopRefusal   = "refusal"    // The model refused to answer
+)
+
+// Tool is a function the model is made to call; the answer is the JSON input
+// of the call, which follows the schema
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema map[string]interface{}
+}
+
+// Request is a completion request with a single user prompt
+type Request struct {
+	Model          string
+	System         string
+	Prompt         string
+	MaxTokens      int      // Limit of the answer, including the thinking budget
+	Temperature    *float64 // Nil leaves the temperature to the provider
+	ThinkingBudget int      // Tokens for extended thinking, 0 disables it
+	Tool           *Tool    // Tool the model must call, nil for a text answer
+	User           string   // Tag for usage attribution
+}
+
+// Response is the answer to a completion request
+type Response struct {
+	Text           string // Text of the answer, or the JSON input of the tool call
+	InputTokens    int
+	OutputTokens   int
+	StopReason     string // StopMaxTokens, StopRefusal or the provider's reason
+	ThinkingLength int    // Characters of reasoning left out of the text
+}
+
+// Provider encodes requests for the API of a language model provider
+type Provider interface {
+	// Name identifies the provider, e.g. ProviderOpenAI
+	Name() string
+	// CompletionURL returns the URL completion requests are posted to
+	CompletionURL() string
+	// ModelsURL returns the URL of the model list, a model is at ModelsURL()/<id>
+	ModelsURL() string
+	// Authorize sets the headers authenticating a request with the API key
+	Authorize(req *http.Request, apiKey string)
+	// EncodeRequest returns the body of a completion request
+	EncodeRequest(req Request) ([]byte, error)
+	// DecodeResponse decodes the body of a successful completion
+	DecodeResponse(data []byte) (Response, error)
+	// ErrorMessage extracts the message of a failed request from its body
+	ErrorMessage(data []byte) string
+}
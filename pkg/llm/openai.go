@@ -0,0 +1,220 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIBaseURL is the base URL of the OpenAI API
+const OpenAIBaseURL = "https://api.openai.com/v1"
+
+// Models used with OpenAI unless configured
+const (
+	OpenAIDefaultModel      = "gpt-4.1"
+	OpenAIVerificationModel = "gpt-4.1-mini" // Cheap model for claim verification
+)
+
+// openAIPrices are the costs per million input and output tokens of the
+// OpenAI models, longest prefix first so dated versions match their model
+var openAIPrices = []struct {
+	prefix        string
+	input, output float64
+}{
+	{"gpt-4.1-nano", 0.10, 0.40},
+	{"gpt-4.1-mini", 0.40, 1.60},
+	{"gpt-4.1", 2.00, 8.00},
+	{"gpt-4o-mini", 0.15, 0.60},
+	{"gpt-4o", 2.50, 10.00},
+	{"gpt-5-nano", 0.05, 0.40},
+	{"gpt-5-mini", 0.25, 2.00},
+	{"gpt-5", 1.25, 10.00},
+	{"o4-mini", 1.10, 4.40},
+	{"o3-mini", 1.10, 4.40},
+	{"o3", 2.00, 8.00},
+	{"o1", 15.00, 60.00},
+}
+
+// OpenAICostPerMillionTokens returns the cost per million tokens of an
+// OpenAI model, and whether the model is known
+func OpenAICostPerMillionTokens(model string) (inputCost, outputCost float64, ok bool) {
+	for _, price := range openAIPrices {
+		if strings.HasPrefix(model, price.prefix) {
+			return price.input, price.output, true
+		}
+	}
+	return 0, 0, false
+}
+
+// openAI encodes requests for the chat completions API of OpenAI and
+// compatible services
+type openAI struct {
+	baseURL string
+}
+
+// NewOpenAI creates the OpenAI provider. The base URL replaces that of the
+// OpenAI API for compatible services; empty keeps the OpenAI API.
+func NewOpenAI(baseURL string) Provider {
+	if baseURL == "" {
+		baseURL = OpenAIBaseURL
+	}
+	return &openAI{baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// openAIMessage is a message of a chat completion
+type openAIMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Refusal   string           `json:"refusal,omitempty"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+// openAIToolCall is a call of a function in an answer
+type openAIToolCall struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAIFunction is a function the model may call
+type openAIFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// openAITool is a tool of a chat completion request
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+// openAIRequest is the body of a chat completion request
+type openAIRequest struct {
+	Model               string          `json:"model"`
+	Messages            []openAIMessage `json:"messages"`
+	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
+	Temperature         *float64        `json:"temperature,omitempty"`
+	ReasoningEffort     string          `json:"reasoning_effort,omitempty"`
+	Tools               []openAITool    `json:"tools,omitempty"`
+	ToolChoice          interface{}     `json:"tool_choice,omitempty"`
+	User                string          `json:"user,omitempty"`
+}
+
+// openAIResponse is the body of a chat completion
+type openAIResponse struct {
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// Name identifies the provider
+func (p *openAI) Name() string {
+	return ProviderOpenAI
+}
+
+// CompletionURL returns the URL of the chat completions
+func (p *openAI) CompletionURL() string {
+	return p.baseURL + "/chat/completions"
+}
+
+// ModelsURL returns the URL of the model list
+func (p *openAI) ModelsURL() string {
+	return p.baseURL + "/models"
+}
+
+// Authorize sets the API key as bearer token
+func (p *openAI) Authorize(req *http.Request, apiKey string) {
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+}
+
+// ReasoningModel reports whether an OpenAI model reasons before answering,
+// which does not allow setting the temperature
+func ReasoningModel(model string) bool {
+	for _, prefix := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeRequest returns the body of a chat completion request. A thinking
+// budget asks reasoning models for high reasoning effort.
+func (p *openAI) EncodeRequest(req Request) ([]byte, error) {
+	body := openAIRequest{
+		Model:               req.Model,
+		MaxCompletionTokens: req.MaxTokens,
+		User:                req.User,
+	}
+	if req.System != "" {
+		body.Messages = append(body.Messages, openAIMessage{Role: "system", Content: req.System})
+	}
+	body.Messages = append(body.Messages, openAIMessage{Role: "user", Content: req.Prompt})
+	if ReasoningModel(req.Model) {
+		if req.ThinkingBudget > 0 {
+			body.ReasoningEffort = "high"
+		}
+	} else {
+		body.Temperature = req.Temperature
+	}
+	if req.Tool != nil {
+		body.Tools = []openAITool{{Type: "function", Function: openAIFunction{
+			Name:        req.Tool.Name,
+			Description: req.Tool.Description,
+			Parameters:  req.Tool.InputSchema,
+		}}}
+		body.ToolChoice = map[string]interface{}{"type": "function", "function": map[string]string{"name": req.Tool.Name}}
+	}
+	return json.Marshal(body)
+}
+
+// DecodeResponse decodes a chat completion; the answer of a tool call is
+// the JSON of its arguments
+func (p *openAI) DecodeResponse(data []byte) (Response, error) {
+	var body openAIResponse
+	if err := json.Unmarshal(data, &body); err != nil {
+		return Response{}, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if len(body.Choices) == 0 {
+		return Response{}, fmt.Errorf("answer has no choices")
+	}
+	choice := body.Choices[0]
+	response := Response{
+		Text:         choice.Message.Content,
+		InputTokens:  body.Usage.PromptTokens,
+		OutputTokens: body.Usage.CompletionTokens,
+		StopReason:   choice.FinishReason,
+	}
+	for _, call := range choice.Message.ToolCalls {
+		response.Text += call.Function.Arguments
+	}
+	switch {
+	case choice.Message.Refusal != "" || choice.FinishReason == "content_filter":
+		response.StopReason = StopRefusal
+	case choice.FinishReason == "length":
+		response.StopReason = StopMaxTokens
+	}
+	return response, nil
+}
+
+// ErrorMessage extracts the message of an OpenAI error
+func (p *openAI) ErrorMessage(data []byte) string {
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && body.Error.Message != "" {
+		return body.Error.Message
+	}
+	return string(data)
+}
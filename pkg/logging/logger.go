@@ -2,11 +2,24 @@ package logging
 
 import (
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
+// maxWarnings limits the warnings collected during a run, a misconfigured
+// column can otherwise produce one for every row
+const maxWarnings = 1000
+
+// Warning is a non-fatal issue logged during a run
+type Warning struct {
+	Time    time.Time         `yaml:"time"`
+	Message string            `yaml:"message"`
+	Fields  map[string]string `yaml:"fields,omitempty"`
+}
+
 // LogLevel represents the severity level of a log message
 type LogLevel int
 
@@ -21,24 +34,156 @@ const (
 	LogLevelError
 )
 
-// Logger provides logging functionality
+// level holds the name and terminal color of a log level
+type level struct {
+	name   string
+	color  string
+	stderr bool // Whether the level is written to standard error
+}
+
+var levels = map[LogLevel]level{
+	LogLevelDebug: {name: "DEBUG", color: "\033[2m"},
+	LogLevelInfo:  {name: "INFO", color: "\033[36m"},
+	LogLevelWarn:  {name: "WARN", color: "\033[33m"},
+	LogLevelError: {name: "ERROR", color: "\033[1;31m", stderr: true},
+}
+
+// output is shared by a logger and the worker loggers derived from it. Its
+// mutex serializes the writes, so lines logged by concurrent goroutines
+// never interleave, and guards the settings that can change during a run.
+type output struct {
+	mutex  sync.Mutex
+	stdout io.Writer
+	stderr io.Writer
+	runID  string
+	color  bool
+
+	warningsMutex   sync.Mutex
+	collecting      bool
+	warnings        []Warning
+	droppedWarnings int
+}
+
+// Logger provides logging functionality. It is safe for concurrent use.
 type Logger struct {
-	debugLogger *log.Logger
-	infoLogger  *log.Logger
-	warnLogger  *log.Logger
-	errorLogger *log.Logger
-	verbose     bool
+	out     *output
+	verbose bool
+	worker  string // Tag of the worker goroutine the logger belongs to
 }
 
 // NewLogger creates a new logger instance
 func NewLogger(verbose bool) *Logger {
 	return &Logger{
-		debugLogger: log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime),
-		infoLogger:  log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime),
-		warnLogger:  log.New(os.Stdout, "WARN: ", log.Ldate|log.Ltime),
-		errorLogger: log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime),
-		verbose:     verbose,
+		out:     &output{stdout: os.Stdout, stderr: os.Stderr},
+		verbose: verbose,
+	}
+}
+
+// Worker returns a logger tagging its lines with the given worker name, for
+// goroutines working in parallel. It shares the output, settings and
+// collected warnings with l.
+func (l *Logger) Worker(name string) *Logger {
+	worker := name
+	if l.worker != "" {
+		worker = l.worker + "/" + name
+	}
+	return &Logger{out: l.out, verbose: l.verbose, worker: worker}
+}
+
+// SetRunID tags every subsequent log line with the given run ID
+func (l *Logger) SetRunID(runID string) {
+	l.out.mutex.Lock()
+	defer l.out.mutex.Unlock()
+	l.out.runID = runID
+}
+
+// SetColor enables colored log level prefixes for terminal output
+func (l *Logger) SetColor(color bool) {
+	l.out.mutex.Lock()
+	defer l.out.mutex.Unlock()
+	l.out.color = color
+}
+
+// RunID returns the run ID the logger is tagged with
+func (l *Logger) RunID() string {
+	l.out.mutex.Lock()
+	defer l.out.mutex.Unlock()
+	return l.out.runID
+}
+
+// write writes a log line with a single call, holding the output mutex
+func (l *Logger) write(logLevel LogLevel, msg string) {
+	lvl := levels[logLevel]
+	var line strings.Builder
+
+	l.out.mutex.Lock()
+	defer l.out.mutex.Unlock()
+	if l.out.color {
+		line.WriteString(lvl.color + lvl.name + ":\033[0m ")
+	} else {
+		line.WriteString(lvl.name + ": ")
+	}
+	if l.out.runID != "" {
+		line.WriteString("[" + l.out.runID + "] ")
+	}
+	line.WriteString(time.Now().Format("2006/01/02 15:04:05 "))
+	if l.worker != "" {
+		line.WriteString("[" + l.worker + "] ")
+	}
+	line.WriteString(msg)
+	line.WriteString("\n")
+
+	writer := l.out.stdout
+	if lvl.stderr {
+		writer = l.out.stderr
+	}
+	io.WriteString(writer, line.String())
+}
+
+// CollectWarnings makes the logger keep every subsequent warning, so they
+// can be saved with the results of the run
+func (l *Logger) CollectWarnings() {
+	l.out.warningsMutex.Lock()
+	defer l.out.warningsMutex.Unlock()
+	l.out.collecting = true
+}
+
+// Warnings returns the collected warnings and the number of warnings left
+// out because there were too many
+func (l *Logger) Warnings() ([]Warning, int) {
+	l.out.warningsMutex.Lock()
+	defer l.out.warningsMutex.Unlock()
+	warnings := make([]Warning, len(l.out.warnings))
+	copy(warnings, l.out.warnings)
+	return warnings, l.out.droppedWarnings
+}
+
+// collectWarning keeps a warning if the logger collects them
+func (l *Logger) collectWarning(msg string, keyvals []interface{}) {
+	l.out.warningsMutex.Lock()
+	defer l.out.warningsMutex.Unlock()
+	if !l.out.collecting {
+		return
+	}
+	if len(l.out.warnings) >= maxWarnings {
+		l.out.droppedWarnings++
+		return
+	}
+	warning := Warning{Time: time.Now(), Message: msg}
+	if l.worker != "" {
+		warning.Fields = map[string]string{"worker": l.worker}
+	}
+	for i := 0; i < len(keyvals); i += 2 {
+		if warning.Fields == nil {
+			warning.Fields = make(map[string]string)
+		}
+		val := "<missing>"
+		if i+1 < len(keyvals) {
+			val = fmt.Sprintf("%v", keyvals[i+1])
+		}
+		warning.Fields[fmt.Sprintf("%v", keyvals[i])] = val
 	}
+	l.out.warnings = append(l.out.warnings, warning)
 }
 
 // formatMessage formats a log message with optional key-value pairs
@@ -67,23 +212,24 @@ func formatMessage(msg string, keyvals ...interface{}) string {
 // Debug logs a debug message
 func (l *Logger) Debug(msg string, keyvals ...interface{}) {
 	if l.verbose {
-		l.debugLogger.Println(formatMessage(msg, keyvals...))
+		l.write(LogLevelDebug, formatMessage(msg, keyvals...))
 	}
 }
 
 // Info logs an informational message
 func (l *Logger) Info(msg string, keyvals ...interface{}) {
-	l.infoLogger.Println(formatMessage(msg, keyvals...))
+	l.write(LogLevelInfo, formatMessage(msg, keyvals...))
 }
 
 // Warn logs a warning message
 func (l *Logger) Warn(msg string, keyvals ...interface{}) {
-	l.warnLogger.Println(formatMessage(msg, keyvals...))
+	l.write(LogLevelWarn, formatMessage(msg, keyvals...))
+	l.collectWarning(msg, keyvals)
 }
 
 // Error logs an error message
 func (l *Logger) Error(msg string, keyvals ...interface{}) {
-	l.errorLogger.Println(formatMessage(msg, keyvals...))
+	l.write(LogLevelError, formatMessage(msg, keyvals...))
 }
 
 // LogOperation logs the start and end of an operation with timing information
@@ -0,0 +1,123 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/xuri/excelize/v2"
+)
+
+// annotationAuthor is the author of the comments added to the input workbook
+const annotationAuthor = "response-analyzer"
+
+// AnnotateWorkbook writes a copy of the input workbook with a comment on the
+// cell of each analyzed response, holding its themes and, with match_reasons,
+// the justification of the model. Reviewers see the analysis inline while
+// reading the original answers. Comments of other authors already on a cell
+// are kept above the annotation, earlier annotations are replaced.
+func (w *Writer) AnnotateWorkbook(result *analysis.AnalysisResult, excelPath, column, path string) error {
+	w.logger.Info("Annotating a copy of the Excel file", "source", excelPath, "path", path)
+
+	f, err := excelize.OpenFile(excelPath)
+	if err != nil {
+		return fmt.Errorf("failed to open Excel file: %w", err)
+	}
+	defer f.Close()
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return fmt.Errorf("no sheets found in Excel file")
+	}
+	sheet := sheets[0] // Responses are read from the first sheet
+
+	comments, err := f.GetComments(sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read comments: %w", err)
+	}
+	existing := make(map[string]string, len(comments))
+	for _, comment := range comments {
+		if comment.Author == annotationAuthor {
+			existing[comment.Cell] = ""
+			continue
+		}
+		text := comment.Text
+		for _, run := range comment.Paragraph {
+			text += run.Text
+		}
+		existing[comment.Cell] = strings.TrimSpace(text)
+	}
+
+	// The segments of a split response share its cell
+	byCell := make(map[string][]analysis.ResponseAnalysis)
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		cell := fmt.Sprintf("%s%d", column, responseAnalysis.Response.RowIndex)
+		byCell[cell] = append(byCell[cell], responseAnalysis)
+	}
+
+	annotated := 0
+	for cell, analyses := range byCell {
+		sort.Slice(analyses, func(i, j int) bool {
+			return analyses[i].Response.Segment < analyses[j].Response.Segment
+		})
+		texts := make([]string, len(analyses))
+		for i, responseAnalysis := range analyses {
+			texts[i] = annotation(responseAnalysis, result.Themes)
+			if responseAnalysis.Response.Segment > 0 {
+				texts[i] = fmt.Sprintf("Segment %d: %s", responseAnalysis.Response.Segment, texts[i])
+			}
+		}
+		text := strings.Join(texts, "\n\n")
+		if previous, ok := existing[cell]; ok {
+			if err := f.DeleteComment(sheet, cell); err != nil {
+				return fmt.Errorf("failed to replace comment of %s: %w", cell, err)
+			}
+			if previous != "" {
+				text = previous + "\n\n" + text
+			}
+		}
+		if err := f.AddComment(sheet, excelize.Comment{
+			Author: annotationAuthor,
+			Cell:   cell,
+			Width:  300,
+			Height: 120,
+			Paragraph: []excelize.RichTextRun{
+				{Text: annotationAuthor + ":\n", Font: &excelize.Font{Bold: true}},
+				{Text: text},
+			},
+		}); err != nil {
+			return fmt.Errorf("failed to add comment to %s: %w", cell, err)
+		}
+		annotated += len(analyses)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("failed to save annotated workbook: %w", err)
+	}
+	w.logger.Info("Annotated copy of the Excel file written", "path", path, "responses", annotated)
+	return nil
+}
+
+// annotation returns the comment text of a response: its themes, a proposed
+// theme, the agreement of consensus runs and the justification
+func annotation(responseAnalysis analysis.ResponseAnalysis, themes []string) string {
+	lines := []string{"Themes: none"}
+	if current := currentThemes(responseAnalysis, themes); len(current) > 0 {
+		lines[0] = "Themes: " + strings.Join(current, "; ")
+	}
+	if responseAnalysis.ProposedTheme != "" {
+		lines = append(lines, "Proposed theme: "+responseAnalysis.ProposedTheme)
+	}
+	if responseAnalysis.Agreement > 0 && responseAnalysis.Agreement < 1 {
+		lines = append(lines, fmt.Sprintf("Agreement: %.0f%%", responseAnalysis.Agreement*100))
+	}
+	if responseAnalysis.Reason != "" {
+		lines = append(lines, "Why: "+responseAnalysis.Reason)
+	}
+	return strings.Join(lines, "\n")
+}
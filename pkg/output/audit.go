@@ -0,0 +1,125 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/excel"
+	"github.com/xuri/excelize/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadAuditLog reads the themes of the responses in an edited audit log. A
+// YAML file is read as written by SaveAuditLog; CSV and Excel files, such as
+// the one written by ExportAuditWorkbook, need an id or row column and a
+// themes column with the themes separated by semicolons, and may have a
+// locked column.
+func (w *Writer) LoadAuditLog(path string) ([]excel.Coding, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		return excel.NewExcelReader(w.logger).ReadCodings(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log file: %w", err)
+	}
+	var auditLog []ResponseAudit
+	if err := yaml.Unmarshal(data, &auditLog); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit log: %w", err)
+	}
+	codings := make([]excel.Coding, 0, len(auditLog))
+	for _, audit := range auditLog {
+		codings = append(codings, excel.Coding{ID: audit.ID, Row: audit.RowIndex, Themes: audit.Themes, Locked: &audit.Locked})
+	}
+	return codings, nil
+}
+
+// ExportAuditWorkbook writes the audit log to an Excel workbook for editing
+// the themes in a spreadsheet: a row per response with its themes separated
+// by semicolons, and a sheet listing the valid themes
+func (w *Writer) ExportAuditWorkbook(result *analysis.AnalysisResult, path string) error {
+	w.logger.Info("Exporting audit log to Excel workbook", "path", path)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	analyses := make([]analysis.ResponseAnalysis, 0, len(result.ResponseAnalyses))
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		analyses = append(analyses, responseAnalysis)
+	}
+	sort.Slice(analyses, func(i, j int) bool {
+		return analyses[i].Response.Before(analyses[j].Response)
+	})
+
+	f := excelize.NewFile()
+	defer f.Close()
+	header, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return fmt.Errorf("failed to create header style: %w", err)
+	}
+
+	if err := f.SetSheetName("Sheet1", "Audit"); err != nil {
+		return fmt.Errorf("failed to create audit sheet: %w", err)
+	}
+	rows := [][]interface{}{{"id", "row", "text", "themes", "corrected", "locked"}}
+	for _, responseAnalysis := range analyses {
+		text := responseAnalysis.Response.Text
+		if w.omitResponseText {
+			text = ""
+		}
+		corrected, locked := "", ""
+		if responseAnalysis.Corrected {
+			corrected = "yes"
+		}
+		if responseAnalysis.Locked {
+			locked = "yes"
+		}
+		rows = append(rows, []interface{}{
+			responseAnalysis.Response.ID,
+			responseAnalysis.Response.RowIndex,
+			text,
+			strings.Join(responseAnalysis.Themes, "; "),
+			corrected,
+			locked,
+		})
+	}
+	if err := setRows(f, "Audit", rows); err != nil {
+		return fmt.Errorf("failed to write audit sheet: %w", err)
+	}
+	if err := f.SetRowStyle("Audit", 1, 1, header); err != nil {
+		return fmt.Errorf("failed to style audit sheet: %w", err)
+	}
+	if err := f.SetColWidth("Audit", "C", "C", 80); err != nil {
+		return fmt.Errorf("failed to size audit sheet: %w", err)
+	}
+	if err := f.SetColWidth("Audit", "D", "D", 40); err != nil {
+		return fmt.Errorf("failed to size audit sheet: %w", err)
+	}
+
+	if _, err := f.NewSheet("Themes"); err != nil {
+		return fmt.Errorf("failed to create themes sheet: %w", err)
+	}
+	themes := [][]interface{}{{"theme"}}
+	for _, theme := range result.Themes {
+		themes = append(themes, []interface{}{theme})
+	}
+	if err := setRows(f, "Themes", themes); err != nil {
+		return fmt.Errorf("failed to write themes sheet: %w", err)
+	}
+	if err := f.SetRowStyle("Themes", 1, 1, header); err != nil {
+		return fmt.Errorf("failed to style themes sheet: %w", err)
+	}
+
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("failed to save workbook: %w", err)
+	}
+	w.logger.Info("Audit workbook written", "path", path, "responses", len(analyses))
+	return nil
+}
@@ -0,0 +1,159 @@
+package output
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"gopkg.in/yaml.v3"
+)
+
+// bundleManifestName is the name of the manifest inside a bundle
+const bundleManifestName = "manifest.yaml"
+
+// BundleFile is a file packaged into a results bundle
+type BundleFile struct {
+	Label string // Label in the run summary, e.g. Report
+	Path  string
+}
+
+// bundleManifest lists the run and the files of a bundle
+type bundleManifest struct {
+	CreatedAt time.Time            `yaml:"created_at"`
+	Generator string               `yaml:"generator,omitempty"` // Version of the analyzer
+	Run       analysis.RunMetadata `yaml:"run"`
+	Files     []bundleEntry        `yaml:"files"`
+}
+
+// bundleEntry is a file in the manifest of a bundle
+type bundleEntry struct {
+	Name   string `yaml:"name"`
+	Label  string `yaml:"label"`
+	Size   int64  `yaml:"size"`
+	SHA256 string `yaml:"sha256"`
+	Source string `yaml:"source"` // Path the file was read from
+}
+
+// WriteBundle packages the files of a run into a zip archive with a manifest
+// listing the run metadata and the size and SHA-256 hash of every file.
+// Files that do not exist are left out with a warning. It returns the number
+// of packaged files.
+func (w *Writer) WriteBundle(path string, files []BundleFile, run analysis.RunMetadata, generator string) (int, error) {
+	w.logger.Info("Writing results bundle", "path", path)
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	manifest := bundleManifest{CreatedAt: time.Now().UTC().Truncate(time.Second), Generator: generator, Run: run}
+	archive := zip.NewWriter(temp)
+	seen := map[string]bool{mustAbs(path): true} // Never package the bundle itself
+	used := make(map[string]bool)
+	for _, file := range files {
+		absolute := mustAbs(file.Path)
+		if seen[absolute] {
+			continue
+		}
+		seen[absolute] = true
+
+		name := uniqueBundleName(filepath.Base(file.Path), used)
+		entry, err := addBundleFile(archive, file.Path, name)
+		if os.IsNotExist(err) {
+			w.logger.Warn("Leaving missing file out of the bundle", "path", file.Path)
+			continue
+		} else if err != nil {
+			archive.Close()
+			temp.Close()
+			return 0, fmt.Errorf("failed to add %s to bundle: %w", file.Path, err)
+		}
+		entry.Label = file.Label
+		manifest.Files = append(manifest.Files, entry)
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err == nil {
+		var writer io.Writer
+		writer, err = archive.CreateHeader(&zip.FileHeader{Name: bundleManifestName, Method: zip.Deflate, Modified: manifest.CreatedAt})
+		if err == nil {
+			_, err = writer.Write(data)
+		}
+	}
+	if err != nil {
+		archive.Close()
+		temp.Close()
+		return 0, fmt.Errorf("failed to write bundle manifest: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		temp.Close()
+		return 0, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to replace bundle: %w", err)
+	}
+
+	w.logger.Info("Results bundle written", "path", path, "files", len(manifest.Files))
+	return len(manifest.Files), nil
+}
+
+// addBundleFile copies a file into the archive and returns its manifest entry
+func addBundleFile(archive *zip.Writer, path, name string) (bundleEntry, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return bundleEntry{}, err
+	}
+	defer source.Close()
+	info, err := source.Stat()
+	if err != nil {
+		return bundleEntry{}, err
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return bundleEntry{}, err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return bundleEntry{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(writer, hash), source)
+	if err != nil {
+		return bundleEntry{}, err
+	}
+	return bundleEntry{Name: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil)), Source: path}, nil
+}
+
+// uniqueBundleName returns the name, numbered if it is already used in the
+// bundle or is the name of the manifest
+func uniqueBundleName(name string, used map[string]bool) string {
+	unique := name
+	extension := filepath.Ext(name)
+	for i := 2; used[unique] || unique == bundleManifestName; i++ {
+		unique = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, extension), i, extension)
+	}
+	used[unique] = true
+	return unique
+}
+
+// mustAbs returns the absolute form of a path, or the path itself if it
+// cannot be determined
+func mustAbs(path string) string {
+	if absolute, err := filepath.Abs(path); err == nil {
+		return absolute
+	}
+	return path
+}
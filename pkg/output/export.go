@@ -0,0 +1,172 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+)
+
+// exportColumn is a column of the wide export with its codebook entry
+type exportColumn struct {
+	name   string
+	label  string
+	values string // Description of the values for the codebook
+	value  func(analysis.ResponseAnalysis) string
+}
+
+// ExportWide writes the responses in wide format for statistics software
+// such as SPSS, R or Stata: one row per response with a 0/1 column per theme.
+// The variable names are short and portable, their meaning is documented in
+// a codebook written next to the export, whose path is returned.
+func (w *Writer) ExportWide(result *analysis.AnalysisResult, path string) (string, error) {
+	w.logger.Info("Exporting responses in wide format", "path", path)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	columns := w.exportColumns(result)
+	analyses := make([]analysis.ResponseAnalysis, 0, len(result.ResponseAnalyses))
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		analyses = append(analyses, responseAnalysis)
+	}
+	sort.Slice(analyses, func(i, j int) bool {
+		return analyses[i].Response.Before(analyses[j].Response)
+	})
+
+	records := make([][]string, 0, len(analyses)+1)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.name
+	}
+	records = append(records, header)
+	for _, responseAnalysis := range analyses {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = column.value(responseAnalysis)
+		}
+		records = append(records, record)
+	}
+	if err := writeCSV(path, records); err != nil {
+		return "", fmt.Errorf("failed to write wide export: %w", err)
+	}
+
+	codebook := [][]string{{"variable", "label", "values"}}
+	for _, column := range columns {
+		codebook = append(codebook, []string{column.name, column.label, column.values})
+	}
+	codebookPath := CodebookPath(path)
+	if err := writeCSV(codebookPath, codebook); err != nil {
+		return "", fmt.Errorf("failed to write codebook: %w", err)
+	}
+
+	w.logger.Info("Wide export written", "path", path, "codebook", codebookPath, "responses", len(analyses))
+	return codebookPath, nil
+}
+
+// CodebookPath returns the path of the codebook written next to a wide export
+func CodebookPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "_codebook.csv"
+}
+
+// exportColumns returns the columns of the wide export. Optional columns are
+// only included if the result has data for them.
+func (w *Writer) exportColumns(result *analysis.AnalysisResult) []exportColumn {
+	columns := []exportColumn{
+		{name: "id", label: "Response ID", values: "text", value: func(a analysis.ResponseAnalysis) string { return a.Response.ID }},
+		{name: "row", label: "Row in the Excel file", values: "integer", value: func(a analysis.ResponseAnalysis) string { return strconv.Itoa(a.Response.RowIndex) }},
+	}
+
+	var dated, rated, agreement, segmented bool
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		segmented = segmented || responseAnalysis.Response.Document != ""
+		dated = dated || !responseAnalysis.Response.Timestamp.IsZero()
+		rated = rated || responseAnalysis.Response.Rating != nil
+		agreement = agreement || responseAnalysis.Agreement > 0
+	}
+	if segmented {
+		columns = append(columns, exportColumn{name: "segment", label: "Segment of the response in the row", values: "integer, empty if the response was not split", value: func(a analysis.ResponseAnalysis) string {
+			if a.Response.Segment == 0 {
+				return ""
+			}
+			return strconv.Itoa(a.Response.Segment)
+		}})
+	}
+	if dated {
+		columns = append(columns, exportColumn{name: "timestamp", label: "Submission time", values: "date and time as YYYY-MM-DD HH:MM:SS, empty if missing", value: func(a analysis.ResponseAnalysis) string {
+			if a.Response.Timestamp.IsZero() {
+				return ""
+			}
+			return a.Response.Timestamp.Format(time.DateTime)
+		}})
+	}
+	if rated {
+		columns = append(columns, exportColumn{name: "rating", label: "Rating", values: "number, empty if missing", value: func(a analysis.ResponseAnalysis) string {
+			if a.Response.Rating == nil {
+				return ""
+			}
+			return strconv.FormatFloat(*a.Response.Rating, 'f', -1, 64)
+		}})
+	}
+	if !w.omitResponseText {
+		label := "Response text"
+		if result.ColumnTitle != "" {
+			label = "Response text: " + result.ColumnTitle
+		}
+		columns = append(columns, exportColumn{name: "text", label: label, values: "text", value: func(a analysis.ResponseAnalysis) string { return a.Response.Text }})
+	}
+
+	columns = append(columns, exportColumn{name: "n_themes", label: "Number of themes assigned", values: "integer", value: func(a analysis.ResponseAnalysis) string {
+		count := 0
+		for _, theme := range a.Themes {
+			if slices.Contains(result.Themes, theme) {
+				count++
+			}
+		}
+		return strconv.Itoa(count)
+	}})
+	if agreement {
+		columns = append(columns, exportColumn{name: "confidence", label: "Share of consensus runs agreeing with the themes", values: "0 to 1", value: func(a analysis.ResponseAnalysis) string {
+			return strconv.FormatFloat(a.Agreement, 'f', 2, 64)
+		}})
+	}
+
+	// Number the theme variables, theme names are rarely valid variable names
+	width := len(strconv.Itoa(len(result.Themes)))
+	for i, theme := range result.Themes {
+		columns = append(columns, exportColumn{
+			name:   fmt.Sprintf("theme_%0*d", max(width, 2), i+1),
+			label:  theme,
+			values: "1 = theme assigned, 0 = not assigned",
+			value: func(a analysis.ResponseAnalysis) string {
+				if slices.Contains(a.Themes, theme) {
+					return "1"
+				}
+				return "0"
+			},
+		})
+	}
+	return columns
+}
+
+// writeCSV writes the records to a CSV file
+func writeCSV(path string, records [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(records); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
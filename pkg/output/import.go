@@ -0,0 +1,115 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/oetiker/response-analyzer/pkg/helpdesk"
+	"github.com/oetiker/response-analyzer/pkg/transcript"
+	"github.com/xuri/excelize/v2"
+)
+
+// HelpdeskColumns are the columns of the Excel file the helpdesk comments
+// are imported into. The text is the response column, the creation time is
+// read as the timestamp column.
+var HelpdeskColumns = []string{"Ticket", "Comment", "Created", "Requester", "Subject", "Text"}
+
+// TranscriptColumns are the columns of the Excel file the segments of
+// transcripts are imported into
+var TranscriptColumns = []string{"Transcript", "Segment", "Start", "Speaker", "Question", "Text"}
+
+// Columns of the imported text and of the creation time of helpdesk comments
+const (
+	ImportTextColumn      = "F"
+	HelpdeskCreatedColumn = "C"
+)
+
+// SaveHelpdeskComments adds the comments that are not in the Excel file yet
+// to its end, oldest first. It returns the number of added comments.
+func (w *Writer) SaveHelpdeskComments(comments []helpdesk.Comment, path string) (int, error) {
+	sorted := append([]helpdesk.Comment(nil), comments...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Created.Before(sorted[j].Created)
+	})
+	rows := make([][]any, len(sorted))
+	for i, comment := range sorted {
+		created := ""
+		if !comment.Created.IsZero() {
+			created = comment.Created.Local().Format("2006-01-02 15:04")
+		}
+		rows[i] = []any{comment.Ticket, comment.ID, created, comment.Requester, comment.Subject, comment.Text}
+	}
+	return w.saveImportedRows(HelpdeskColumns, rows, path)
+}
+
+// SaveTranscriptSegments adds the segments of a transcript that are not in
+// the Excel file yet to its end. Segments are identified by the base name of
+// the transcript file and their number. It returns the number of added
+// segments.
+func (w *Writer) SaveTranscriptSegments(transcriptPath string, segments []transcript.Segment, path string) (int, error) {
+	name := filepath.Base(transcriptPath)
+	rows := make([][]any, len(segments))
+	for i, segment := range segments {
+		rows[i] = []any{name, fmt.Sprintf("%d", segment.Number), segment.Start, segment.Speaker, segment.Question, segment.Text}
+	}
+	return w.saveImportedRows(TranscriptColumns, rows, path)
+}
+
+// saveImportedRows adds the rows whose first two cells are not in the Excel
+// file yet to its end, creating the file with the header if it does not
+// exist. Rows already in the file are kept as they are, so the rows of
+// analyzed responses do not move. It returns the number of added rows.
+func (w *Writer) saveImportedRows(header []string, rows [][]any, path string) (int, error) {
+	f, err := excelize.OpenFile(path)
+	if os.IsNotExist(err) {
+		f = excelize.NewFile()
+		if err := f.SetSheetName("Sheet1", "Imported"); err != nil {
+			return 0, fmt.Errorf("failed to create sheet: %w", err)
+		}
+		if err := f.SetSheetRow("Imported", "A1", &header); err != nil {
+			return 0, fmt.Errorf("failed to write header: %w", err)
+		}
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to open Excel file: %w", err)
+	}
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	existing, err := f.GetRows(sheet)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read rows: %w", err)
+	}
+	known := make(map[string]bool, len(existing))
+	for _, row := range existing {
+		if len(row) >= 2 {
+			known[row[0]+"/"+row[1]] = true
+		}
+	}
+
+	next := len(existing) + 1
+	for _, row := range rows {
+		key := fmt.Sprintf("%v/%v", row[0], row[1])
+		if known[key] {
+			continue
+		}
+		known[key] = true
+		cell, err := excelize.CoordinatesToCellName(1, next)
+		if err != nil {
+			return 0, err
+		}
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return 0, fmt.Errorf("failed to write row %s: %w", key, err)
+		}
+		next++
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := f.SaveAs(path); err != nil {
+		return 0, fmt.Errorf("failed to save Excel file: %w", err)
+	}
+	return next - len(existing) - 1, nil
+}
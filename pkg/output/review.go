@@ -0,0 +1,129 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/xuri/excelize/v2"
+)
+
+// reviewInstructions explain the review sample to the reviewers
+var reviewInstructions = []string{
+	"Review of the theme assignments",
+	"",
+	"Each row of the Review sheet is a response drawn at random from the themes (stratum) of the analysis.",
+	"The theme columns hold a 1 for every theme the model assigned to the response.",
+	"Check each response: enter 1 for a theme that applies and clear the cell of a theme that does not.",
+	"Do not change the id and row columns, they link your review to the analysis.",
+	"Compute the agreement of your review with the model with: response-analyzer agreement -config <config>",
+}
+
+// SaveReviewSample writes the review sample to an Excel file reviewers correct
+// the theme assignments in. The Review sheet has a row per response with a
+// column per theme holding 1 for the themes the model assigned, so the
+// reviewed file can be read as a coded file for the agreement.
+func (w *Writer) SaveReviewSample(result *analysis.AnalysisResult, sample []analysis.ReviewItem, path string) error {
+	w.logger.Info("Saving review sample", "path", path, "responses", len(sample))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	header, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return fmt.Errorf("failed to create header style: %w", err)
+	}
+	wrap, err := f.NewStyle(&excelize.Style{Alignment: &excelize.Alignment{WrapText: true, Vertical: "top"}})
+	if err != nil {
+		return fmt.Errorf("failed to create text style: %w", err)
+	}
+
+	sheet := "Review"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return fmt.Errorf("failed to create review sheet: %w", err)
+	}
+	titles := []interface{}{"id", "row", "stratum"}
+	if !w.omitResponseText {
+		titles = append(titles, "text")
+	}
+	for _, theme := range result.Themes {
+		titles = append(titles, theme)
+	}
+	rows := [][]interface{}{titles}
+	for _, item := range sample {
+		row := []interface{}{item.Analysis.Response.ID, item.Analysis.Response.RowIndex, item.Stratum}
+		if !w.omitResponseText {
+			row = append(row, item.Analysis.Response.Text)
+		}
+		for _, theme := range result.Themes {
+			if slices.Contains(item.Analysis.Themes, theme) {
+				row = append(row, 1)
+			} else {
+				row = append(row, nil)
+			}
+		}
+		rows = append(rows, row)
+	}
+	if err := setRows(f, sheet, rows); err != nil {
+		return fmt.Errorf("failed to write review sheet: %w", err)
+	}
+
+	last, err := excelize.ColumnNumberToName(len(titles))
+	if err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheet, "A1", last+"1", header); err != nil {
+		return fmt.Errorf("failed to style review sheet: %w", err)
+	}
+	themeColumn := "D"
+	if !w.omitResponseText {
+		themeColumn = "E"
+		if err := f.SetColWidth(sheet, "D", "D", 80); err != nil {
+			return fmt.Errorf("failed to style review sheet: %w", err)
+		}
+		if err := f.SetCellStyle(sheet, "D2", fmt.Sprintf("D%d", len(rows)), wrap); err != nil {
+			return fmt.Errorf("failed to style review sheet: %w", err)
+		}
+	}
+	if err := f.SetColWidth(sheet, "C", "C", 25); err != nil {
+		return fmt.Errorf("failed to style review sheet: %w", err)
+	}
+	if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return fmt.Errorf("failed to style review sheet: %w", err)
+	}
+
+	// Only allow 1 or an empty cell in the theme columns
+	if len(result.Themes) > 0 && len(sample) > 0 {
+		validation := excelize.NewDataValidation(true)
+		validation.Sqref = fmt.Sprintf("%s2:%s%d", themeColumn, last, len(rows))
+		if err := validation.SetDropList([]string{"1"}); err != nil {
+			return fmt.Errorf("failed to restrict theme columns: %w", err)
+		}
+		if err := f.AddDataValidation(sheet, validation); err != nil {
+			return fmt.Errorf("failed to restrict theme columns: %w", err)
+		}
+	}
+
+	if _, err := f.NewSheet("Instructions"); err != nil {
+		return fmt.Errorf("failed to create instructions sheet: %w", err)
+	}
+	for i, line := range reviewInstructions {
+		if err := f.SetCellValue("Instructions", fmt.Sprintf("A%d", i+1), line); err != nil {
+			return fmt.Errorf("failed to write instructions: %w", err)
+		}
+	}
+	if err := f.SetCellStyle("Instructions", "A1", "A1", header); err != nil {
+		return fmt.Errorf("failed to style instructions: %w", err)
+	}
+
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("failed to save review sample: %w", err)
+	}
+	w.logger.Info("Review sample saved", "path", path)
+	return nil
+}
@@ -0,0 +1,82 @@
+package output
+
+import (
+	"sync"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+)
+
+// StateSnapshots saves snapshots of the state to the state file on a
+// goroutine of its own, so serializing a large state does not hold up the
+// workers. A snapshot handed over while another is being written replaces
+// the one waiting, so only the latest of them is written next.
+type StateSnapshots struct {
+	writer *Writer
+	path   string
+
+	mutex     sync.Mutex
+	done      *sync.Cond
+	pending   *analysis.AnalysisResult
+	writing   bool
+	err       error // First error since the last Flush
+	coalesced int   // Snapshots replaced before they were written
+}
+
+// NewStateSnapshots creates the background writer of snapshots of the state
+// file at the given path
+func (w *Writer) NewStateSnapshots(path string) *StateSnapshots {
+	s := &StateSnapshots{writer: w, path: path}
+	s.done = sync.NewCond(&s.mutex)
+	return s
+}
+
+// Save hands a snapshot of the result over for writing and returns at once
+func (s *StateSnapshots) Save(result *analysis.AnalysisResult) {
+	snapshot := result.Snapshot()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.pending != nil {
+		s.coalesced++
+	}
+	s.pending = snapshot
+	if !s.writing {
+		s.writing = true
+		go s.write()
+	}
+}
+
+// write writes the waiting snapshots until there is none
+func (s *StateSnapshots) write() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for s.pending != nil {
+		snapshot := s.pending
+		s.pending = nil
+		s.mutex.Unlock()
+		err := s.writer.saveState(snapshot, s.path)
+		s.mutex.Lock()
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+		if err == nil {
+			s.writer.logger.Info("Saved checkpoint to state file", "responses", len(snapshot.ResponseAnalyses), "coalesced", s.coalesced, "path", s.path)
+		}
+	}
+	s.writing = false
+	s.done.Broadcast()
+}
+
+// Flush waits until the snapshots handed over are written and returns the
+// first error writing them. It must be called before the state file is
+// saved otherwise, so a snapshot does not overwrite it.
+func (s *StateSnapshots) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for s.writing {
+		s.done.Wait()
+	}
+	err := s.err
+	s.err = nil
+	return err
+}
@@ -0,0 +1,291 @@
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"gopkg.in/yaml.v3"
+)
+
+// StateProblem describes a part of a state file that could not be read or
+// is inconsistent
+type StateProblem struct {
+	Section string // Top-level section of the state file
+	Entry   string // Entry within the section, empty if the whole section is affected
+	Line    int    // Line in the state file
+	Message string
+}
+
+// String formats the problem for messages
+func (p StateProblem) String() string {
+	location := p.Section
+	if p.Entry != "" {
+		location += "." + p.Entry
+	}
+	if p.Line > 0 && !strings.HasPrefix(p.Message, "line ") {
+		return fmt.Sprintf("%s (line %d): %s", location, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", location, p.Message)
+}
+
+// ReadState reads a state file section by section. Sections and entries
+// that cannot be decoded are skipped and reported, so the readable parts of
+// a damaged state file are recovered. If the file cannot be read or is not a
+// YAML mapping at all, the previous state kept as backup is read instead;
+// an error is only returned if that fails too.
+func (w *Writer) ReadState(path string) (*analysis.AnalysisResult, []StateProblem, error) {
+	result, problems, err := readStateSections(path)
+	if err == nil {
+		return result, problems, nil
+	}
+	backupPath := StateBackupPath(path)
+	if _, statErr := os.Stat(backupPath); statErr != nil {
+		return nil, nil, err
+	}
+	result, problems, backupErr := readStateSections(backupPath)
+	if backupErr != nil {
+		return nil, nil, err
+	}
+	problem := StateProblem{Section: "state file", Message: fmt.Sprintf("%v, read the previous state from %s instead", err, backupPath)}
+	return result, append([]StateProblem{problem}, problems...), nil
+}
+
+// readStateSections reads the sections of a state file for ReadState
+func readStateSections(path string) (*analysis.AnalysisResult, []StateProblem, error) {
+	data, problems, err := readStateFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil, nil, fmt.Errorf("state file is empty")
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("state file is not a mapping (line %d)", root.Line)
+	}
+
+	var result analysis.AnalysisResult
+	known := stateSections()
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if !known[key.Value] {
+			problems = append(problems, StateProblem{Section: key.Value, Line: key.Line, Message: "unknown section, ignored"})
+			continue
+		}
+		if err := decodeSection(key, value, &result); err == nil {
+			continue
+		} else if value.Kind != yaml.MappingNode {
+			problems = append(problems, StateProblem{Section: key.Value, Line: value.Line, Message: yamlMessage(err)})
+			continue
+		}
+
+		// Keep the readable entries of a broken mapping section
+		readable := &yaml.Node{Kind: yaml.MappingNode, Tag: value.Tag}
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			entryKey, entryValue := value.Content[j], value.Content[j+1]
+			entry := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{entryKey, entryValue}}
+			if err := decodeSection(key, entry, &analysis.AnalysisResult{}); err != nil {
+				problems = append(problems, StateProblem{Section: key.Value, Entry: entryKey.Value, Line: entryKey.Line, Message: yamlMessage(err)})
+				continue
+			}
+			readable.Content = append(readable.Content, entryKey, entryValue)
+		}
+		if err := decodeSection(key, readable, &result); err != nil {
+			problems = append(problems, StateProblem{Section: key.Value, Line: value.Line, Message: yamlMessage(err)})
+		}
+	}
+
+	problems = append(problems, checkStateConsistency(&result)...)
+	return &result, problems, nil
+}
+
+// Encodings of the state file
+const (
+	StateEncodingYAML = "yaml" // Plain YAML, the default
+	StateEncodingGzip = "gzip" // Gzip compressed YAML, for large surveys
+)
+
+// gzipMagic are the first bytes of a gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// stateBackupSuffix is appended to the path of the state file for the
+// previous state, kept when the state is written
+const stateBackupSuffix = ".previous"
+
+// StateBackupPath returns the path of the previous state kept next to a
+// state file
+func StateBackupPath(path string) string {
+	return path + stateBackupSuffix
+}
+
+// readStateFile reads the YAML of a state file, decompressing it if it is
+// gzip compressed, so either encoding is read whatever is configured. The
+// readable start of a truncated gzip stream is returned with a problem.
+func readStateFile(path string) ([]byte, []StateProblem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress state file: %w", err)
+	}
+	defer reader.Close()
+	data, err = io.ReadAll(reader)
+	if errors.Is(err, io.ErrUnexpectedEOF) && len(data) > 0 {
+		return data, []StateProblem{{Section: "state file", Message: "the compressed file is truncated, only its start is read"}}, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress state file: %w", err)
+	}
+	return data, nil, nil
+}
+
+// writeStateFile writes the YAML of a state file in the configured encoding.
+// The file is written next to the state file and renamed over it, so an
+// interrupted write leaves the previous state in place, which is also kept
+// as backup.
+func (w *Writer) writeStateFile(path string, data []byte) error {
+	if w.stateEncoding == StateEncodingGzip {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(data); err != nil {
+			return fmt.Errorf("failed to compress state file: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress state file: %w", err)
+		}
+		data = compressed.Bytes()
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := keepStateBackup(path); err != nil {
+		w.logger.Warn("Failed to keep the previous state", "path", StateBackupPath(path), "error", err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// keepStateBackup keeps the current state file as the previous state before
+// it is replaced. It is linked rather than moved, so the state file exists
+// throughout, and copied where links are not supported.
+func keepStateBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return nil // Nothing to keep
+	}
+	backupPath := StateBackupPath(path)
+	if err := os.Remove(backupPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(path, backupPath); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(backupPath, data, 0644)
+}
+
+// decodeSection decodes a single top-level section into the result. The
+// result is only modified if the section decodes without errors.
+func decodeSection(key, value *yaml.Node, result *analysis.AnalysisResult) error {
+	section := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{key, value}}
+	var scratch analysis.AnalysisResult
+	if err := section.Decode(&scratch); err != nil {
+		return err
+	}
+	return section.Decode(result)
+}
+
+// yamlMessage strips the generic prefix from YAML decoding errors
+func yamlMessage(err error) string {
+	message := strings.TrimPrefix(err.Error(), "yaml: unmarshal errors:\n")
+	return strings.Join(strings.Fields(message), " ")
+}
+
+// stateSections returns the top-level sections of a state file
+func stateSections() map[string]bool {
+	sections := make(map[string]bool)
+	resultType := reflect.TypeOf(analysis.AnalysisResult{})
+	for i := 0; i < resultType.NumField(); i++ {
+		name := strings.Split(resultType.Field(i).Tag.Get("yaml"), ",")[0]
+		if name != "" && name != "-" {
+			sections[name] = true
+		}
+	}
+	return sections
+}
+
+// checkStateConsistency reports references between the sections of a
+// state file that do not match
+func checkStateConsistency(result *analysis.AnalysisResult) []StateProblem {
+	var problems []StateProblem
+
+	ids := make([]string, 0, len(result.ResponseAnalyses))
+	for id := range result.ResponseAnalyses {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if responseID := result.ResponseAnalyses[id].Response.ID; responseID != id {
+			problems = append(problems, StateProblem{Section: "response_analyses", Entry: id, Message: fmt.Sprintf("response has the ID %q", responseID)})
+		}
+	}
+
+	themes := make([]string, 0, len(result.ThemeAnalyses))
+	for theme := range result.ThemeAnalyses {
+		themes = append(themes, theme)
+	}
+	sort.Strings(themes)
+	for _, theme := range themes {
+		missing := 0
+		for _, id := range result.ThemeAnalyses[theme].Responses {
+			if _, ok := result.ResponseAnalyses[id]; !ok {
+				missing++
+			}
+		}
+		if missing > 0 {
+			problems = append(problems, StateProblem{Section: "theme_analyses", Entry: theme, Message: fmt.Sprintf("refers to %d unknown responses", missing)})
+		}
+	}
+
+	return problems
+}
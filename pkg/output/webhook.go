@@ -0,0 +1,93 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"gopkg.in/yaml.v3"
+)
+
+// WebhookUsage is the API usage of a run sent with the webhook payload
+type WebhookUsage struct {
+	Tokens int     `json:"tokens"`
+	Cost   float64 `json:"cost_usd"`
+}
+
+// webhookTheme is a theme in the summary payload of the webhook
+type webhookTheme struct {
+	Theme      string  `json:"theme"`
+	Count      int     `json:"count"`
+	Percentage float64 `json:"percentage"`
+	Summary    string  `json:"summary,omitempty"`
+}
+
+// WebhookPayload returns the JSON body posted to the webhook after a run.
+// The summary payload holds the run, the themes with their counts and
+// summaries and the global summary; the full payload holds the whole result
+// with the keys of the state file.
+func (w *Writer) WebhookPayload(result *analysis.AnalysisResult, full bool, usage WebhookUsage) ([]byte, error) {
+	run, err := yamlToJSON(result.Run)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert run metadata: %w", err)
+	}
+	payload := map[string]interface{}{
+		"event":     "analysis.completed",
+		"run":       run,
+		"usage":     usage,
+		"responses": len(result.ResponseAnalyses),
+	}
+
+	if full {
+		if w.omitResponseText {
+			result = analysis.WithoutResponseText(result)
+		}
+		converted, err := yamlToJSON(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert result: %w", err)
+		}
+		payload["result"] = converted
+	} else {
+		total := len(result.ResponseAnalyses)
+		themes := make([]webhookTheme, 0, len(result.Themes))
+		for _, theme := range result.Themes {
+			count := len(result.ThemeAnalyses[theme].Responses)
+			percentage := 0.0
+			if total > 0 {
+				percentage = float64(count) / float64(total) * 100.0
+			}
+			themes = append(themes, webhookTheme{
+				Theme:      theme,
+				Count:      count,
+				Percentage: percentage,
+				Summary:    result.ThemeSummaries[theme].Summary,
+			})
+		}
+		sort.SliceStable(themes, func(i, j int) bool {
+			return themes[i].Count > themes[j].Count
+		})
+		payload["themes"] = themes
+		payload["global_summary"] = result.GlobalSummary
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	return data, nil
+}
+
+// yamlToJSON converts a value to a generic form with the keys of its yaml
+// tags, so the JSON payload uses the same names as the state file
+func yamlToJSON(value interface{}) (interface{}, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
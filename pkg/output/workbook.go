@@ -0,0 +1,265 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/xuri/excelize/v2"
+)
+
+// ExportWorkbook writes the results to an Excel workbook with a sheet of the
+// themes, a sheet of the responses and a matrix sheet with a row per
+// response and a column per theme. A matrix cell holds the confidence of an
+// assigned theme, 1 without consensus runs, and 0 otherwise, shaded by a
+// color scale for eyeballing the density of the classification.
+func (w *Writer) ExportWorkbook(result *analysis.AnalysisResult, path string) error {
+	w.logger.Info("Exporting results to Excel workbook", "path", path)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	analyses := make([]analysis.ResponseAnalysis, 0, len(result.ResponseAnalyses))
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		analyses = append(analyses, responseAnalysis)
+	}
+	sort.Slice(analyses, func(i, j int) bool {
+		return analyses[i].Response.Before(analyses[j].Response)
+	})
+
+	f := excelize.NewFile()
+	defer f.Close()
+	header, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return fmt.Errorf("failed to create header style: %w", err)
+	}
+
+	if err := f.SetSheetName("Sheet1", "Themes"); err != nil {
+		return fmt.Errorf("failed to create themes sheet: %w", err)
+	}
+	if err := w.writeThemesSheet(f, result, header); err != nil {
+		return fmt.Errorf("failed to write themes sheet: %w", err)
+	}
+	if err := w.writeResponsesSheet(f, result, analyses, header); err != nil {
+		return fmt.Errorf("failed to write responses sheet: %w", err)
+	}
+	if err := w.writeMatrixSheet(f, result, analyses, header); err != nil {
+		return fmt.Errorf("failed to write matrix sheet: %w", err)
+	}
+
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("failed to save workbook: %w", err)
+	}
+	w.logger.Info("Excel workbook written", "path", path, "responses", len(analyses))
+	return nil
+}
+
+// writeThemesSheet writes the themes with their counts and summaries
+func (w *Writer) writeThemesSheet(f *excelize.File, result *analysis.AnalysisResult, header int) error {
+	sheet := "Themes"
+	rows := [][]interface{}{{"Theme", "Responses", "Share", "Summary"}}
+	for _, theme := range result.Themes {
+		count := len(result.ThemeAnalyses[theme].Responses)
+		share := 0.0
+		if len(result.ResponseAnalyses) > 0 {
+			share = float64(count) / float64(len(result.ResponseAnalyses))
+		}
+		rows = append(rows, []interface{}{theme, count, share, result.ThemeSummaries[theme].Summary})
+	}
+	if err := setRows(f, sheet, rows); err != nil {
+		return err
+	}
+	percent, err := f.NewStyle(&excelize.Style{NumFmt: 10})
+	if err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheet, "C2", fmt.Sprintf("C%d", len(rows)), percent); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(sheet, "A", "A", 40); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(sheet, "D", "D", 100); err != nil {
+		return err
+	}
+	return f.SetCellStyle(sheet, "A1", "D1", header)
+}
+
+// writeResponsesSheet writes the responses with their themes
+func (w *Writer) writeResponsesSheet(f *excelize.File, result *analysis.AnalysisResult, analyses []analysis.ResponseAnalysis, header int) error {
+	sheet := "Responses"
+	if _, err := f.NewSheet(sheet); err != nil {
+		return err
+	}
+	titles := []interface{}{"ID", "Row"}
+	if !w.omitResponseText {
+		titles = append(titles, responseHeading(result))
+	}
+	titles = append(titles, "Themes")
+	rows := [][]interface{}{titles}
+	for _, responseAnalysis := range analyses {
+		row := []interface{}{responseAnalysis.Response.ID, responseAnalysis.Response.RowIndex}
+		if !w.omitResponseText {
+			row = append(row, responseAnalysis.Response.Text)
+		}
+		row = append(row, strings.Join(currentThemes(responseAnalysis, result.Themes), "; "))
+		rows = append(rows, row)
+	}
+	if err := setRows(f, sheet, rows); err != nil {
+		return err
+	}
+	last, err := excelize.ColumnNumberToName(len(titles))
+	if err != nil {
+		return err
+	}
+	if !w.omitResponseText {
+		if err := f.SetColWidth(sheet, "C", "C", 80); err != nil {
+			return err
+		}
+	}
+	if err := f.SetColWidth(sheet, last, last, 40); err != nil {
+		return err
+	}
+	return f.SetCellStyle(sheet, "A1", last+"1", header)
+}
+
+// writeMatrixSheet writes the theme assignments as a matrix of responses
+// and themes, with a color scale over the values
+func (w *Writer) writeMatrixSheet(f *excelize.File, result *analysis.AnalysisResult, analyses []analysis.ResponseAnalysis, header int) error {
+	sheet := "Matrix"
+	if _, err := f.NewSheet(sheet); err != nil {
+		return err
+	}
+	titles := []interface{}{"ID", "Row"}
+	for _, theme := range result.Themes {
+		titles = append(titles, theme)
+	}
+	rows := [][]interface{}{titles}
+	for _, responseAnalysis := range analyses {
+		row := []interface{}{responseAnalysis.Response.ID, responseAnalysis.Response.RowIndex}
+		for _, theme := range result.Themes {
+			value := 0.0
+			if slices.Contains(responseAnalysis.Themes, theme) {
+				value = 1
+				if responseAnalysis.Agreement > 0 {
+					value = responseAnalysis.Agreement
+				}
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+	if err := setRows(f, sheet, rows); err != nil {
+		return err
+	}
+
+	last, err := excelize.ColumnNumberToName(len(titles))
+	if err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheet, "A1", last+"1", header); err != nil {
+		return err
+	}
+	if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, XSplit: 2, YSplit: 1, TopLeftCell: "C2", ActivePane: "bottomRight"}); err != nil {
+		return err
+	}
+	if len(result.Themes) == 0 || len(analyses) == 0 {
+		return nil
+	}
+	return f.SetConditionalFormat(sheet, fmt.Sprintf("C2:%s%d", last, len(rows)), []excelize.ConditionalFormatOptions{{
+		Type:     "2_color_scale",
+		Criteria: "=",
+		MinType:  "num",
+		MinValue: "0",
+		MinColor: "#FFFFFF",
+		MaxType:  "num",
+		MaxValue: "1",
+		MaxColor: "#2F5597",
+	}})
+}
+
+// currentThemes returns the themes of a response that are in the theme list,
+// split themes may be gone
+func currentThemes(responseAnalysis analysis.ResponseAnalysis, themes []string) []string {
+	var current []string
+	for _, theme := range responseAnalysis.Themes {
+		if slices.Contains(themes, theme) {
+			current = append(current, theme)
+		}
+	}
+	return current
+}
+
+// responseHeading returns the heading of the response texts, the title of
+// their column if it is known
+func responseHeading(result *analysis.AnalysisResult) string {
+	if result.ColumnTitle != "" {
+		return result.ColumnTitle
+	}
+	return "Response"
+}
+
+// setRows writes rows to a sheet, starting at the first cell
+func setRows(f *excelize.File, sheet string, rows [][]interface{}) error {
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScrubWorkbook rewrites the cells of all sheets of a workbook with scrub,
+// e.g. to remove the text of forgotten responses, and saves it if a cell
+// changed. It returns the number of changed cells and the text of all cells
+// after scrubbing, one per line, to check what remains.
+func (w *Writer) ScrubWorkbook(path string, scrub func(string) string) (int, string, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open workbook: %w", err)
+	}
+	defer f.Close()
+
+	var text strings.Builder
+	changed := 0
+	for _, sheet := range f.GetSheetList() {
+		rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+		}
+		for i, row := range rows {
+			for j, value := range row {
+				scrubbed := scrub(value)
+				if scrubbed != value {
+					cell, err := excelize.CoordinatesToCellName(j+1, i+1)
+					if err != nil {
+						return 0, "", err
+					}
+					if err := f.SetCellStr(sheet, cell, scrubbed); err != nil {
+						return 0, "", fmt.Errorf("failed to scrub cell %s of sheet %s: %w", cell, sheet, err)
+					}
+					changed++
+				}
+				text.WriteString(scrubbed)
+				text.WriteByte('\n')
+			}
+		}
+	}
+	if changed == 0 {
+		return 0, text.String(), nil
+	}
+	if err := f.Save(); err != nil {
+		return 0, "", fmt.Errorf("failed to save workbook: %w", err)
+	}
+	w.logger.Info("Scrubbed workbook", "path", path, "cells", changed)
+	return changed, text.String(), nil
+}
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 
 	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/claude"
+	"github.com/oetiker/response-analyzer/pkg/config"
 	"github.com/oetiker/response-analyzer/pkg/logging"
 	"github.com/oetiker/response-analyzer/pkg/template"
 	"gopkg.in/yaml.v3"
@@ -13,8 +15,10 @@ import (
 
 // Writer handles writing output files
 type Writer struct {
-	logger   *logging.Logger
-	renderer *template.Renderer
+	logger           *logging.Logger
+	renderer         *template.Renderer
+	omitResponseText bool
+	stateEncoding    string // Encoding of the state files written, yaml if empty
 }
 
 // NewWriter creates a new Writer instance
@@ -25,9 +29,40 @@ func NewWriter(logger *logging.Logger) *Writer {
 	}
 }
 
+// SetOmitResponseText sets whether verbatim responses are left out of the
+// state and audit files
+func (w *Writer) SetOmitResponseText(omit bool) {
+	w.omitResponseText = omit
+}
+
+// SetStateEncoding sets the encoding of the state files written, yaml or
+// gzip. State files are read in either encoding.
+func (w *Writer) SetStateEncoding(encoding string) {
+	w.stateEncoding = encoding
+}
+
+// SetHTMLTheme sets the theme of the HTML appendix and the stylesheet
+// offered to report templates
+func (w *Writer) SetHTMLTheme(theme string) {
+	w.renderer.SetHTMLTheme(theme)
+}
+
 // SaveState saves the analysis result to a state file
 func (w *Writer) SaveState(result *analysis.AnalysisResult, path string) error {
 	w.logger.Info("Saving state to file", "path", path)
+	if err := w.saveState(result, path); err != nil {
+		return err
+	}
+	w.logger.Info("State saved to file", "path", path)
+	return nil
+}
+
+// saveState writes the analysis result to a state file without logging, for
+// the checkpoints written after every batch
+func (w *Writer) saveState(result *analysis.AnalysisResult, path string) error {
+	if w.omitResponseText {
+		result = analysis.WithoutResponseText(result)
+	}
 
 	// Marshal result to YAML
 	data, err := yaml.Marshal(result)
@@ -36,41 +71,110 @@ func (w *Writer) SaveState(result *analysis.AnalysisResult, path string) error {
 	}
 
 	// Write to file
-	if err := os.WriteFile(path, data, 0644); err != nil {
-		return fmt.Errorf("failed to write state file: %w", err)
+	return w.writeStateFile(path, data)
+}
+
+// minAliasLength is the minimum length of response texts stored only once
+// in compact state files
+const minAliasLength = 16
+
+// SaveCompactState saves the analysis result to a state file, storing
+// repeated response texts only once as YAML anchors and aliases. It returns
+// the number of deduplicated texts.
+func (w *Writer) SaveCompactState(result *analysis.AnalysisResult, path string) (int, error) {
+	w.logger.Info("Saving compact state to file", "path", path)
+
+	if w.omitResponseText {
+		result = analysis.WithoutResponseText(result)
 	}
 
-	w.logger.Info("State saved to file", "path", path)
-	return nil
+	var node yaml.Node
+	if err := node.Encode(result); err != nil {
+		return 0, fmt.Errorf("failed to encode result: %w", err)
+	}
+	aliaser := &textAliaser{anchors: make(map[string]*yaml.Node)}
+	aliaser.alias(&node)
+	deduplicated := aliaser.count
+
+	data, err := yaml.Marshal(&node)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	if err := w.writeStateFile(path, data); err != nil {
+		return 0, err
+	}
+
+	w.logger.Info("State saved to file", "path", path, "deduplicated_texts", deduplicated)
+	return deduplicated, nil
+}
+
+// textAliaser stores repeated values of text fields only once
+type textAliaser struct {
+	anchors map[string]*yaml.Node
+	named   int // Number of anchors named so far
+	count   int // Number of replaced values
+}
+
+// alias replaces repeated values of text fields below the node by aliases of
+// their first occurrence, visiting the nodes in document order
+func (a *textAliaser) alias(node *yaml.Node) {
+	for i, child := range node.Content {
+		isText := node.Kind == yaml.MappingNode && i%2 == 1 && node.Content[i-1].Value == "text"
+		if isText && child.Kind == yaml.ScalarNode && len(child.Value) >= minAliasLength {
+			anchor, ok := a.anchors[child.Value]
+			if !ok {
+				a.anchors[child.Value] = child
+				continue
+			}
+			if anchor.Anchor == "" {
+				a.named++
+				anchor.Anchor = fmt.Sprintf("t%d", a.named)
+			}
+			node.Content[i] = &yaml.Node{Kind: yaml.AliasNode, Value: anchor.Anchor, Alias: anchor}
+			a.count++
+			continue
+		}
+		a.alias(child)
+	}
 }
 
 // LoadState loads the analysis result from a state file
 func (w *Writer) LoadState(path string) (*analysis.AnalysisResult, error) {
 	w.logger.Info("Loading state from file", "path", path)
 
-	// Read file
-	data, err := os.ReadFile(path)
+	// Read the file, recovering the readable sections of a damaged state
+	result, problems, err := w.ReadState(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read state file: %w", err)
+		return nil, err
+	}
+	for _, problem := range problems {
+		w.logger.Warn("Problem in state file", "path", path, "problem", problem.String())
 	}
 
-	// Unmarshal result
-	var result analysis.AnalysisResult
-	if err := yaml.Unmarshal(data, &result); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	// Keep a copy of the damaged file, as saving the state drops the skipped parts
+	if len(problems) > 0 {
+		backupPath := path + ".damaged"
+		if data, err := os.ReadFile(path); err == nil {
+			if err := os.WriteFile(backupPath, data, 0644); err != nil {
+				w.logger.Warn("Failed to back up damaged state file", "path", backupPath, "error", err)
+			} else {
+				w.logger.Warn("Recovered the readable parts of the state file, the original is kept", "backup", backupPath)
+			}
+		}
 	}
 
-	w.logger.Info("State loaded from file", "path", path)
-	return &result, nil
+	w.logger.Info("State loaded from file", "path", path, "problems", len(problems))
+	return result, nil
 }
 
-// SaveThemes saves the themes to a YAML file
-func (w *Writer) SaveThemes(themes []string, path string) error {
+// SaveThemes saves the themes and their descriptions to a YAML file
+func (w *Writer) SaveThemes(themes []string, descriptions map[string]claude.ThemeDescription, path string) error {
 	w.logger.Info("Saving themes to file", "path", path, "count", len(themes))
 
 	// Create themes map
-	themesMap := map[string][]string{
-		"themes": themes,
+	themesMap := config.ThemesFileContent{
+		Themes:            themes,
+		ThemeDescriptions: descriptions,
 	}
 
 	// Marshal themes to YAML
@@ -88,6 +192,30 @@ func (w *Writer) SaveThemes(themes []string, path string) error {
 	return nil
 }
 
+// SaveProposedThemes saves the themes proposed for unmatched responses to a YAML file
+func (w *Writer) SaveProposedThemes(proposals []analysis.ProposedTheme, path string) error {
+	w.logger.Info("Saving proposed themes to file", "path", path, "count", len(proposals))
+
+	// Create proposed themes map
+	proposalsMap := map[string][]analysis.ProposedTheme{
+		"proposed_themes": proposals,
+	}
+
+	// Marshal proposed themes to YAML
+	data, err := yaml.Marshal(proposalsMap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal proposed themes: %w", err)
+	}
+
+	// Write to file
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write proposed themes file: %w", err)
+	}
+
+	w.logger.Info("Proposed themes saved to file", "path", path)
+	return nil
+}
+
 // SaveSummary saves the summary to a file
 func (w *Writer) SaveSummary(summary string, path string) error {
 	w.logger.Info("Saving summary to file", "path", path)
@@ -101,25 +229,136 @@ func (w *Writer) SaveSummary(summary string, path string) error {
 	return nil
 }
 
+// SaveVerificationReport saves the claim verification of the summaries to a YAML file
+func (w *Writer) SaveVerificationReport(report *analysis.VerificationReport, path string) error {
+	w.logger.Info("Saving verification report to file", "path", path)
+
+	// Marshal report to YAML
+	data, err := yaml.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verification report: %w", err)
+	}
+
+	// Write to file
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write verification report file: %w", err)
+	}
+
+	w.logger.Info("Verification report saved to file", "path", path)
+	return nil
+}
+
+// SaveModelComparison saves the comparison of two models to a YAML file
+func (w *Writer) SaveModelComparison(comparison *analysis.ModelComparison, path string) error {
+	w.logger.Info("Saving model comparison to file", "path", path)
+
+	// Marshal comparison to YAML
+	data, err := yaml.Marshal(comparison)
+	if err != nil {
+		return fmt.Errorf("failed to marshal model comparison: %w", err)
+	}
+
+	// Write to file
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write model comparison file: %w", err)
+	}
+
+	w.logger.Info("Model comparison saved to file", "path", path)
+	return nil
+}
+
+// SaveModelMigration saves the migration report of a model upgrade to a YAML
+// file
+func (w *Writer) SaveModelMigration(migration *analysis.ModelMigration, path string) error {
+	w.logger.Info("Saving model diff to file", "path", path)
+
+	data, err := yaml.Marshal(migration)
+	if err != nil {
+		return fmt.Errorf("failed to marshal model diff: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write model diff file: %w", err)
+	}
+
+	w.logger.Info("Model diff saved to file", "path", path)
+	return nil
+}
+
+// SaveCodingAgreement saves the agreement with external coders to a YAML file
+func (w *Writer) SaveCodingAgreement(agreement *analysis.CodingAgreement, path string) error {
+	w.logger.Info("Saving coding agreement to file", "path", path)
+
+	data, err := yaml.Marshal(agreement)
+	if err != nil {
+		return fmt.Errorf("failed to marshal coding agreement: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write coding agreement file: %w", err)
+	}
+	return nil
+}
+
+// SaveGlossary saves the theme glossary of several projects to a YAML file
+func (w *Writer) SaveGlossary(glossary *analysis.Glossary, path string) error {
+	w.logger.Info("Saving glossary to file", "path", path)
+
+	data, err := yaml.Marshal(glossary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal glossary: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write glossary file: %w", err)
+	}
+	return nil
+}
+
+// SaveExperiment saves the results of an experiment to a YAML file
+func (w *Writer) SaveExperiment(experiment *analysis.Experiment, path string) error {
+	w.logger.Info("Saving experiment to file", "path", path)
+
+	data, err := yaml.Marshal(experiment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal experiment: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write experiment file: %w", err)
+	}
+	return nil
+}
+
+// ResponseAudit is the entry of a response in the audit log
+type ResponseAudit struct {
+	ID        string   `yaml:"id"`
+	Text      string   `yaml:"text,omitempty"`
+	Original  string   `yaml:"original,omitempty"` // Text as read, if preprocessing changed it
+	Themes    []string `yaml:"themes"`
+	RowIndex  int      `yaml:"row_index"`
+	Corrected bool     `yaml:"corrected,omitempty"` // Themes corrected by hand with apply-audit
+	Locked    bool     `yaml:"locked,omitempty"`    // Themes confirmed by a reviewer, kept even if the response changes
+
+	// Tasks whose prompts contained only the start of the response
+	Truncations []claude.Truncation `yaml:"truncations,omitempty"`
+}
+
 // SaveAuditLog saves the audit log to a YAML file
 func (w *Writer) SaveAuditLog(result *analysis.AnalysisResult, path string) error {
 	w.logger.Info("Saving audit log to file", "path", path)
 
 	// Create audit log
-	type ResponseAudit struct {
-		ID       string   `yaml:"id"`
-		Text     string   `yaml:"text"`
-		Themes   []string `yaml:"themes"`
-		RowIndex int      `yaml:"row_index"`
-	}
-
 	auditLog := make([]ResponseAudit, 0, len(result.ResponseAnalyses))
 	for _, responseAnalysis := range result.ResponseAnalyses {
 		audit := ResponseAudit{
-			ID:       responseAnalysis.Response.ID,
-			Text:     responseAnalysis.Response.Text,
-			Themes:   responseAnalysis.Themes,
-			RowIndex: responseAnalysis.Response.RowIndex,
+			ID:        responseAnalysis.Response.ID,
+			Themes:    responseAnalysis.Themes,
+			RowIndex:  responseAnalysis.Response.RowIndex,
+			Corrected: responseAnalysis.Corrected,
+			Locked:    responseAnalysis.Locked,
+
+			Truncations: responseAnalysis.Truncations,
+		}
+		if !w.omitResponseText {
+			audit.Text = responseAnalysis.Response.Text
+			audit.Original = responseAnalysis.Response.Original
 		}
 		auditLog = append(auditLog, audit)
 	}
@@ -139,15 +378,77 @@ func (w *Writer) SaveAuditLog(result *analysis.AnalysisResult, path string) erro
 	return nil
 }
 
+// RemoveFromAuditLog removes the given responses from an audit log file
+func (w *Writer) RemoveFromAuditLog(path string, ids []string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read audit log file: %w", err)
+	}
+
+	// Keep the entries as nodes so field order and unknown fields are preserved
+	var entries []yaml.Node
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal audit log: %w", err)
+	}
+
+	forget := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		forget[id] = true
+	}
+	kept := make([]yaml.Node, 0, len(entries))
+	for _, entry := range entries {
+		if forget[mappingValue(&entry, "id")] {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	removed := len(entries) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	data, err = yaml.Marshal(kept)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal audit log: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write audit log file: %w", err)
+	}
+
+	w.logger.Info("Removed responses from audit log", "path", path, "count", removed)
+	return removed, nil
+}
+
+// mappingValue returns the scalar value of a key in a YAML mapping node
+func mappingValue(node *yaml.Node, key string) string {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
 // SaveThemeStats saves theme statistics to a YAML file
 func (w *Writer) SaveThemeStats(result *analysis.AnalysisResult, path string) error {
 	w.logger.Info("Saving theme statistics to file", "path", path)
 
 	// Create theme stats
 	type ThemeStat struct {
-		Theme      string  `yaml:"theme"`
-		Count      int     `yaml:"count"`
-		Percentage float64 `yaml:"percentage"`
+		Theme      string                   `yaml:"theme"`
+		Count      int                      `yaml:"count"`
+		Percentage float64                  `yaml:"percentage"`
+		MeanChars  float64                  `yaml:"mean_chars,omitempty"`
+		MeanWords  float64                  `yaml:"mean_words,omitempty"`
+		Documents  int                      `yaml:"documents,omitempty"` // Rows the responses of the theme are from, if responses were segmented
+		Sentiment  *claude.SentimentBalance `yaml:"sentiment,omitempty"` // Responses of the theme by sentiment, with sentiment_enabled
+	}
+
+	// With segmented responses the counts are ideas, the documents tell how
+	// many rows mention the theme
+	segmented := false
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		segmented = segmented || responseAnalysis.Response.Document != ""
 	}
 
 	totalResponses := len(result.ResponseAnalyses)
@@ -164,6 +465,22 @@ func (w *Writer) SaveThemeStats(result *analysis.AnalysisResult, path string) er
 			Theme:      themeAnalysis.Theme,
 			Count:      count,
 			Percentage: percentage,
+			Sentiment:  themeAnalysis.Sentiment,
+		}
+		if segmented {
+			documents := make(map[int]bool)
+			for _, id := range themeAnalysis.Responses {
+				if responseAnalysis, ok := result.ResponseAnalyses[id]; ok {
+					documents[responseAnalysis.Response.RowIndex] = true
+				}
+			}
+			stat.Documents = len(documents)
+		}
+		if result.Corpus != nil {
+			if length, ok := result.Corpus.ThemeLength(themeAnalysis.Theme); ok {
+				stat.MeanChars = length.MeanChars
+				stat.MeanWords = length.MeanWords
+			}
 		}
 		themeStats = append(themeStats, stat)
 	}
@@ -183,6 +500,65 @@ func (w *Writer) SaveThemeStats(result *analysis.AnalysisResult, path string) er
 	return nil
 }
 
+// SaveCorpusStats saves the languages and lengths of the responses to a YAML
+// file
+func (w *Writer) SaveCorpusStats(stats *analysis.CorpusStats, path string) error {
+	w.logger.Info("Saving corpus statistics to file", "path", path)
+
+	data, err := yaml.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal corpus statistics: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write corpus statistics file: %w", err)
+	}
+	return nil
+}
+
+// SaveTimeSeries saves the theme frequencies per week or month to a YAML file
+func (w *Writer) SaveTimeSeries(series *analysis.TimeSeries, path string) error {
+	w.logger.Info("Saving theme time series to file", "path", path)
+
+	data, err := yaml.Marshal(series)
+	if err != nil {
+		return fmt.Errorf("failed to marshal time series: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write time series file: %w", err)
+	}
+	return nil
+}
+
+// SaveRollingWindows saves the theme frequencies of the rolling windows to a
+// YAML file
+func (w *Writer) SaveRollingWindows(windows []analysis.RollingWindow, path string) error {
+	w.logger.Info("Saving rolling windows to file", "path", path)
+
+	data, err := yaml.Marshal(windows)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rolling windows: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write rolling windows file: %w", err)
+	}
+	return nil
+}
+
+// SaveRatingStats saves the ratings per theme and the themes per rating band
+// to a YAML file
+func (w *Writer) SaveRatingStats(stats *analysis.RatingStats, path string) error {
+	w.logger.Info("Saving rating statistics to file", "path", path)
+
+	data, err := yaml.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rating statistics: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write rating statistics file: %w", err)
+	}
+	return nil
+}
+
 // GenerateReport generates a report using a template
 func (w *Writer) GenerateReport(result *analysis.AnalysisResult, templatePath, outputPath string) error {
 	w.logger.Info("Generating report", "template", templatePath, "output", outputPath)
@@ -201,3 +577,47 @@ func (w *Writer) GenerateReport(result *analysis.AnalysisResult, templatePath, o
 	w.logger.Info("Report generated", "path", outputPath)
 	return nil
 }
+
+// GenerateCombinedReport generates the report of all questions of a survey
+// with several response columns
+func (w *Writer) GenerateCombinedReport(questions []template.QuestionResult, templatePath, outputPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := w.renderer.RenderCombinedReport(templatePath, outputPath, questions); err != nil {
+		return fmt.Errorf("failed to render combined report: %w", err)
+	}
+	w.logger.Info("Combined report generated", "path", outputPath)
+	return nil
+}
+
+// GenerateHTMLReport generates the self-contained HTML report with charts of
+// the theme frequencies and co-occurrences and the summaries
+func (w *Writer) GenerateHTMLReport(result *analysis.AnalysisResult, outputPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := w.renderer.RenderHTMLReport(outputPath, result); err != nil {
+		return fmt.Errorf("failed to render HTML report: %w", err)
+	}
+	w.logger.Info("HTML report generated", "path", outputPath)
+	return nil
+}
+
+// GenerateAppendix generates the appendix listing all responses grouped by theme
+func (w *Writer) GenerateAppendix(result *analysis.AnalysisResult, format, outputPath string) error {
+	w.logger.Info("Generating appendix", "format", format, "output", outputPath)
+
+	// Create output directory if it doesn't exist
+	outputDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	if err := w.renderer.RenderAppendix(format, outputPath, result); err != nil {
+		return fmt.Errorf("failed to render appendix: %w", err)
+	}
+
+	w.logger.Info("Appendix generated", "path", outputPath)
+	return nil
+}
@@ -0,0 +1,63 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// directorySink copies files into a directory, such as a mounted share
+type directorySink struct {
+	logger *logging.Logger
+	name   string
+	dir    string
+}
+
+// newDirectorySink creates a sink for a file:// URL
+func newDirectorySink(logger *logging.Logger, name string, location *url.URL) (*directorySink, error) {
+	if location.Path == "" {
+		return nil, fmt.Errorf("file output sink needs a directory, e.g. file:///mnt/share/survey")
+	}
+	return &directorySink{logger: logger, name: name, dir: filepath.FromSlash(location.Path)}, nil
+}
+
+// Name identifies the sink in log messages
+func (s *directorySink) Name() string {
+	return s.name
+}
+
+// Put copies a file into the directory, replacing it atomically
+func (s *directorySink) Put(localPath, name string) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	source, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer source.Close()
+
+	target := filepath.Join(s.dir, name)
+	temp, err := os.CreateTemp(s.dir, "."+name+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if _, err := io.Copy(temp, source); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(temp.Name(), target); err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
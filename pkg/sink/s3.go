@@ -0,0 +1,185 @@
+package sink
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// s3Sink uploads files to an S3 bucket, or a bucket of an S3 compatible
+// service such as MinIO, with requests signed by AWS Signature Version 4
+type s3Sink struct {
+	logger          *logging.Logger
+	name            string
+	bucket          string
+	prefix          string
+	region          string
+	endpoint        *url.URL // Path-style endpoint, nil for AWS
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	httpClient      *http.Client
+}
+
+// newS3Sink creates a sink for an s3://bucket/prefix URL
+func newS3Sink(logger *logging.Logger, name string, location *url.URL, cfg config.OutputSink) (*s3Sink, error) {
+	if location.Host == "" {
+		return nil, fmt.Errorf("s3 output sink needs a bucket, e.g. s3://bucket/prefix")
+	}
+	sink := &s3Sink{
+		logger:          logger,
+		name:            name,
+		bucket:          location.Host,
+		prefix:          strings.Trim(location.Path, "/"),
+		region:          cfg.Region,
+		accessKeyID:     cfg.AccessKeyID,
+		secretAccessKey: cfg.SecretAccessKey,
+		httpClient:      &http.Client{Timeout: 5 * time.Minute},
+	}
+	if sink.region == "" {
+		sink.region = os.Getenv("AWS_REGION")
+	}
+	if sink.region == "" {
+		sink.region = "us-east-1"
+	}
+	if sink.accessKeyID == "" && sink.secretAccessKey == "" {
+		sink.accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		sink.secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		sink.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if sink.accessKeyID == "" || sink.secretAccessKey == "" {
+		return nil, fmt.Errorf("s3 output sink needs access_key_id and secret_access_key, or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if cfg.Endpoint != "" {
+		endpoint, err := url.Parse(cfg.Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+		}
+		sink.endpoint = endpoint
+	}
+	return sink, nil
+}
+
+// Name identifies the sink in log messages
+func (s *s3Sink) Name() string {
+	return s.name
+}
+
+// Put uploads a file as an object below the prefix
+func (s *s3Sink) Put(localPath, name string) error {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	key := path.Join(s.prefix, name)
+	target := s.objectURL(key)
+	req, err := http.NewRequest(http.MethodPut, target.String(), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, data, time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upload of %s failed with status %s: %s", key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// objectURL returns the URL of an object, virtual-hosted style on AWS and
+// path style on other endpoints
+func (s *s3Sink) objectURL(key string) *url.URL {
+	if s.endpoint != nil {
+		target := *s.endpoint
+		target.Path = "/" + path.Join(strings.Trim(target.Path, "/"), s.bucket, key)
+		return &target
+	}
+	return &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", s.bucket, s.region), Path: "/" + key}
+}
+
+// sign adds the AWS Signature Version 4 headers to a request
+func (s *s3Sink) sign(req *http.Request, payload []byte, now time.Time) {
+	payloadHash := sha256Hex(payload)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	// Sign the host and all x-amz and content-type headers
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKeyID, scope, signedHeaders, signature))
+}
+
+// sha256Hex returns the hex encoded SHA-256 hash of data
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with the given key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,95 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// sftpSink uploads files with the sftp command of OpenSSH, so the host keys,
+// SSH agent and ~/.ssh/config of the user apply
+type sftpSink struct {
+	logger       *logging.Logger
+	name         string
+	destination  string // [user@]host
+	port         string
+	dir          string
+	identityFile string
+}
+
+// newSFTPSink creates a sink for an sftp:// URL
+func newSFTPSink(logger *logging.Logger, name string, location *url.URL, cfg config.OutputSink) (*sftpSink, error) {
+	if location.Hostname() == "" {
+		return nil, fmt.Errorf("sftp output sink needs a host, e.g. sftp://user@host/path")
+	}
+	if _, err := exec.LookPath("sftp"); err != nil {
+		return nil, fmt.Errorf("sftp output sink needs the sftp command: %w", err)
+	}
+	if _, ok := location.User.Password(); ok {
+		return nil, fmt.Errorf("sftp output sink does not support passwords, use an SSH key")
+	}
+	destination := location.Hostname()
+	if user := location.User.Username(); user != "" {
+		destination = user + "@" + destination
+	}
+	return &sftpSink{
+		logger:       logger,
+		name:         name,
+		destination:  destination,
+		port:         location.Port(),
+		dir:          strings.TrimPrefix(location.Path, "/"),
+		identityFile: cfg.IdentityFile,
+	}, nil
+}
+
+// Name identifies the sink in log messages
+func (s *sftpSink) Name() string {
+	return s.name
+}
+
+// Put uploads a file into the directory, creating the directory first. The
+// path of the URL is relative to the login directory, like with scp.
+func (s *sftpSink) Put(localPath, name string) error {
+	args := []string{"-q", "-b", "-", "-o", "BatchMode=yes"}
+	if s.port != "" {
+		args = append(args, "-P", s.port)
+	}
+	if s.identityFile != "" {
+		args = append(args, "-i", s.identityFile)
+	}
+	args = append(args, s.destination)
+
+	// A leading dash makes sftp ignore the failure of creating an existing
+	// directory
+	var commands strings.Builder
+	dir := ""
+	for _, part := range strings.Split(s.dir, "/") {
+		if part == "" {
+			continue
+		}
+		dir = path.Join(dir, part)
+		fmt.Fprintf(&commands, "-mkdir %s\n", quoteSFTP(dir))
+	}
+	fmt.Fprintf(&commands, "put %s %s\n", quoteSFTP(localPath), quoteSFTP(path.Join(s.dir, name)))
+
+	cmd := exec.Command("sftp", args...)
+	cmd.Stdin = strings.NewReader(commands.String())
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sftp failed: %w: %s", err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// quoteSFTP quotes an argument of an sftp batch command
+func quoteSFTP(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
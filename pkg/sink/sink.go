@@ -0,0 +1,83 @@
+package sink
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// Sink is a destination the files of a run are delivered to
+type Sink interface {
+	// Name identifies the sink in log messages
+	Name() string
+	// Put uploads a local file under the given name below the location of
+	// the sink
+	Put(localPath, name string) error
+}
+
+// Artifact is a file written by a run
+type Artifact struct {
+	Label string // Label in the run summary, e.g. Report
+	Path  string
+}
+
+// New creates the sink configured by its URL scheme
+func New(logger *logging.Logger, cfg config.OutputSink) (Sink, error) {
+	location, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output sink url %q: %w", cfg.URL, err)
+	}
+	name := cfg.Name
+	if name == "" {
+		name = location.Host
+		if name == "" {
+			name = location.Scheme
+		}
+	}
+	switch location.Scheme {
+	case "s3":
+		return newS3Sink(logger, name, location, cfg)
+	case "sftp":
+		return newSFTPSink(logger, name, location, cfg)
+	case "http", "https":
+		return newWebDAVSink(logger, name, location, cfg)
+	case "file":
+		return newDirectorySink(logger, name, location)
+	default:
+		return nil, fmt.Errorf("unsupported output sink url %q, use s3://, sftp://, https:// (WebDAV) or file://", cfg.URL)
+	}
+}
+
+// Deliver uploads the artifacts to every configured sink and returns the
+// number of uploaded files. Failed uploads are logged as warnings and do not
+// stop the delivery of the other files, as the files are kept locally.
+func Deliver(logger *logging.Logger, sinks []config.OutputSink, artifacts []Artifact) int {
+	delivered := 0
+	for _, sinkConfig := range sinks {
+		destination, err := New(logger, sinkConfig)
+		if err != nil {
+			logger.Warn("Skipping output sink", "url", sinkConfig.URL, "error", err)
+			continue
+		}
+		for _, artifact := range artifacts {
+			if len(sinkConfig.Artifacts) > 0 && !slices.ContainsFunc(sinkConfig.Artifacts, func(label string) bool {
+				return strings.EqualFold(label, artifact.Label)
+			}) {
+				continue
+			}
+			name := path.Base(strings.ReplaceAll(artifact.Path, "\\", "/"))
+			if err := destination.Put(artifact.Path, name); err != nil {
+				logger.Warn("Failed to deliver file to output sink", "sink", destination.Name(), "file", artifact.Path, "error", err)
+				continue
+			}
+			logger.Info("Delivered file to output sink", "sink", destination.Name(), "file", name)
+			delivered++
+		}
+	}
+	return delivered
+}
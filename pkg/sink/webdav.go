@@ -0,0 +1,114 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// webDAVSink uploads files to a WebDAV collection, such as a Nextcloud folder
+type webDAVSink struct {
+	logger     *logging.Logger
+	name       string
+	base       *url.URL
+	username   string
+	password   string
+	httpClient *http.Client
+	created    bool // Whether the collection was created in this run
+}
+
+// newWebDAVSink creates a sink for an http:// or https:// URL
+func newWebDAVSink(logger *logging.Logger, name string, location *url.URL, cfg config.OutputSink) (*webDAVSink, error) {
+	base := *location
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+	return &webDAVSink{
+		logger:     logger,
+		name:       name,
+		base:       &base,
+		username:   cfg.Username,
+		password:   cfg.Password,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Name identifies the sink in log messages
+func (s *webDAVSink) Name() string {
+	return s.name
+}
+
+// Put uploads a file into the collection, creating the collection first
+func (s *webDAVSink) Put(localPath, name string) error {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if !s.created {
+		if err := s.createCollections(); err != nil {
+			return err
+		}
+		s.created = true
+	}
+
+	target := s.base.JoinPath(name)
+	resp, err := s.do(http.MethodPut, target.String(), data)
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("upload to %s failed with status %s", target.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// createCollections creates the collections of the path that do not exist.
+// Servers answer 405 for collections that already exist.
+func (s *webDAVSink) createCollections() error {
+	collection := *s.base
+	collection.Path = "/"
+	for _, part := range strings.Split(strings.Trim(s.base.Path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		collection.Path += part + "/"
+		resp, err := s.do("MKCOL", collection.String(), nil)
+		if err != nil {
+			return fmt.Errorf("failed to create collection: %w", err)
+		}
+		switch resp.StatusCode {
+		case http.StatusCreated, http.StatusMethodNotAllowed, http.StatusOK:
+		case http.StatusUnauthorized, http.StatusForbidden:
+			// The upper levels may not be ours, only the upload shows whether
+			// the collection is writable
+			s.logger.Debug("Not allowed to create collection", "url", collection.Redacted())
+		default:
+			return fmt.Errorf("creating collection %s failed with status %s", collection.Redacted(), resp.Status)
+		}
+	}
+	return nil
+}
+
+// do sends a request with basic authentication and discards the response body
+func (s *webDAVSink) do(method, target string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp, nil
+}
@@ -0,0 +1,71 @@
+package sink
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/config"
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+// webhookBaseDelay is the delay before the first retry of a failed webhook
+// delivery, doubled for each further retry
+const webhookBaseDelay = 2 * time.Second
+
+// PostWebhook posts a JSON payload to the webhook. Network errors, rate
+// limits and server errors are retried with exponential backoff; other
+// client errors are not, as sending the same payload again will not help.
+func PostWebhook(logger *logging.Logger, webhook config.Webhook, runID string, payload []byte) error {
+	httpClient := &http.Client{Timeout: time.Minute}
+	var lastErr error
+	for attempt := 0; attempt <= webhook.Retries; attempt++ {
+		if attempt > 0 {
+			delay := webhookBaseDelay * time.Duration(1<<(attempt-1))
+			logger.Warn("Webhook delivery failed, retrying after backoff",
+				"retry", attempt,
+				"delay", delay,
+				"error", lastErr)
+			time.Sleep(delay)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to create webhook request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if runID != "" {
+			req.Header.Set("X-Run-Id", runID)
+		}
+		if webhook.Secret != "" {
+			mac := hmac.New(sha256.New, []byte(webhook.Secret))
+			mac.Write(payload)
+			req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		for name, value := range webhook.Headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			break
+		}
+	}
+	return fmt.Errorf("failed to post to webhook: %w", lastErr)
+}
@@ -0,0 +1,212 @@
+package template
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"sort"
+	"text/template"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+)
+
+// AppendixGroup represents the responses assigned to one theme in the appendix
+type AppendixGroup struct {
+	Theme     string
+	Responses []ResponseData
+}
+
+// AppendixData represents the data available in the appendix templates
+type AppendixData struct {
+	ColumnTitle   string
+	ResponseCount int
+	Groups        []AppendixGroup
+	Unassigned    []ResponseData
+
+	Accessible bool             // Whether the HTML uses the accessible layout
+	Style      htmltemplate.CSS // Stylesheet of the HTML theme
+}
+
+// markdownAppendixTemplate is the built-in Markdown appendix template
+const markdownAppendixTemplate = `# Appendix: Categorized Responses
+## {{.ColumnTitle}}
+
+Total Responses: {{.ResponseCount}}
+{{range .Groups}}
+## {{.Theme}} ({{len .Responses}} responses)
+{{range .Responses}}
+- **Row {{.RowIndex}}** ({{.ID}}): {{.Text}}
+{{- end}}
+{{end}}
+{{- if .Unassigned}}
+## Not assigned to any theme ({{len .Unassigned}} responses)
+{{range .Unassigned}}
+- **Row {{.RowIndex}}** ({{.ID}}): {{.Text}}
+{{- end}}
+{{end}}`
+
+// htmlAppendixTemplate is the built-in HTML appendix template. The
+// accessible layout adds landmarks, a skip link, a list of the themes and
+// tables with captions and header cells.
+const htmlAppendixTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+{{- if .Accessible}}
+<meta name="viewport" content="width=device-width, initial-scale=1">
+{{- end}}
+<title>Appendix: {{.ColumnTitle}}</title>
+<style>
+{{.Style}}</style>
+</head>
+<body>
+{{- if .Accessible}}
+<a class="skip" href="#responses">Skip to the responses</a>
+<header>
+<h1>Appendix: Categorized Responses</h1>
+<p>{{.ColumnTitle}}</p>
+<p>Total Responses: {{.ResponseCount}}</p>
+</header>
+<nav aria-label="Themes">
+<h2>Themes</h2>
+<ul>
+{{range $i, $group := .Groups}}<li><a href="#theme-{{$i}}">{{$group.Theme}}</a> ({{len $group.Responses}} responses)</li>
+{{end}}{{if .Unassigned}}<li><a href="#unassigned">Not assigned to any theme</a> ({{len .Unassigned}} responses)</li>
+{{end}}</ul>
+</nav>
+<main id="responses">
+{{range $i, $group := .Groups}}
+<section aria-labelledby="theme-{{$i}}">
+<h2 id="theme-{{$i}}">{{$group.Theme}} ({{len $group.Responses}} responses)</h2>
+<table>
+<caption>Responses assigned to {{$group.Theme}}</caption>
+<thead><tr><th scope="col">Row</th><th scope="col">Response</th></tr></thead>
+<tbody>
+{{range $group.Responses}}<tr><th scope="row">Row {{.RowIndex}} ({{.ID}})</th><td>{{.Text}}</td></tr>
+{{end}}</tbody>
+</table>
+</section>
+{{end}}
+{{- if .Unassigned}}
+<section aria-labelledby="unassigned">
+<h2 id="unassigned">Not assigned to any theme ({{len .Unassigned}} responses)</h2>
+<table>
+<caption>Responses not assigned to any theme</caption>
+<thead><tr><th scope="col">Row</th><th scope="col">Response</th></tr></thead>
+<tbody>
+{{range .Unassigned}}<tr><th scope="row">Row {{.RowIndex}} ({{.ID}})</th><td>{{.Text}}</td></tr>
+{{end}}</tbody>
+</table>
+</section>
+{{end}}
+</main>
+{{- else}}
+<h1>Appendix: Categorized Responses</h1>
+<h2>{{.ColumnTitle}}</h2>
+<p>Total Responses: {{.ResponseCount}}</p>
+{{range .Groups}}
+<h2>{{.Theme}} ({{len .Responses}} responses)</h2>
+<table>
+{{range .Responses}}<tr><td class="row">Row {{.RowIndex}} ({{.ID}})</td><td>{{.Text}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Unassigned}}
+<h2>Not assigned to any theme ({{len .Unassigned}} responses)</h2>
+<table>
+{{range .Unassigned}}<tr><td class="row">Row {{.RowIndex}} ({{.ID}})</td><td>{{.Text}}</td></tr>
+{{end}}</table>
+{{end}}
+{{- end}}
+</body>
+</html>
+`
+
+// RenderAppendix renders the built-in appendix listing all responses grouped
+// by theme. Format is either "markdown" or "html".
+func (r *Renderer) RenderAppendix(format, outputPath string, result *analysis.AnalysisResult) error {
+	r.logger.Info("Rendering appendix", "format", format, "output", outputPath)
+
+	// Pick the template for the requested format
+	var execute func(io.Writer, *AppendixData) error
+	switch format {
+	case "markdown", "md":
+		tmpl, err := template.New("appendix").Parse(markdownAppendixTemplate)
+		if err != nil {
+			return fmt.Errorf("failed to parse appendix template: %w", err)
+		}
+		execute = func(w io.Writer, data *AppendixData) error { return tmpl.Execute(w, data) }
+	case "html":
+		tmpl, err := htmltemplate.New("appendix").Parse(htmlAppendixTemplate)
+		if err != nil {
+			return fmt.Errorf("failed to parse appendix template: %w", err)
+		}
+		execute = func(w io.Writer, data *AppendixData) error { return tmpl.Execute(w, data) }
+	default:
+		return fmt.Errorf("unsupported appendix format: %s (valid options: markdown, html)", format)
+	}
+
+	// Create output file
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	// Execute template
+	data := prepareAppendixData(result)
+	data.Accessible = r.htmlTheme == HTMLThemeAccessible
+	data.Style = htmltemplate.CSS(HTMLStyle(r.htmlTheme))
+	if err := execute(file, data); err != nil {
+		return fmt.Errorf("failed to execute appendix template: %w", err)
+	}
+
+	r.logger.Info("Appendix rendered", "output", outputPath)
+	return nil
+}
+
+// prepareAppendixData groups the responses by theme, in theme order and by row
+func prepareAppendixData(result *analysis.AnalysisResult) *AppendixData {
+	data := &AppendixData{
+		ColumnTitle:   result.ColumnTitle,
+		ResponseCount: len(result.ResponseAnalyses),
+	}
+	if data.ColumnTitle == "" {
+		data.ColumnTitle = "Survey Responses"
+	}
+
+	byTheme := make(map[string][]ResponseData)
+	for _, responseAnalysis := range result.ResponseAnalyses {
+		response := ResponseData{
+			ID:       responseAnalysis.Response.ID,
+			Text:     responseAnalysis.Response.Text,
+			Themes:   responseAnalysis.Themes,
+			RowIndex: responseAnalysis.Response.RowIndex,
+			Segment:  responseAnalysis.Response.Segment,
+		}
+		if len(responseAnalysis.Themes) == 0 {
+			data.Unassigned = append(data.Unassigned, response)
+			continue
+		}
+		for _, theme := range responseAnalysis.Themes {
+			byTheme[theme] = append(byTheme[theme], response)
+		}
+	}
+
+	byRow := func(responses []ResponseData) {
+		sort.Slice(responses, func(i, j int) bool {
+			if responses[i].RowIndex != responses[j].RowIndex {
+				return responses[i].RowIndex < responses[j].RowIndex
+			}
+			return responses[i].Segment < responses[j].Segment
+		})
+	}
+	for _, theme := range result.Themes {
+		responses := byTheme[theme]
+		byRow(responses)
+		data.Groups = append(data.Groups, AppendixGroup{Theme: theme, Responses: responses})
+	}
+	byRow(data.Unassigned)
+
+	return data
+}
@@ -0,0 +1,91 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+)
+
+// QuestionResult is the result of the analysis of one question of a survey
+// with several response columns
+type QuestionResult struct {
+	Column string
+	Result *analysis.AnalysisResult
+}
+
+// QuestionData represents a question in the combined report, with all data
+// of a single question report
+type QuestionData struct {
+	Column string
+	TemplateData
+}
+
+// CombinedData represents the data available in combined report templates
+type CombinedData struct {
+	Questions    []QuestionData
+	AnalysisDate time.Time
+}
+
+// combinedReportTemplate is the built-in Markdown template of the combined
+// report
+const combinedReportTemplate = `# Survey Report
+{{range .Questions}}
+## {{.ColumnTitle}} (column {{.Column}})
+
+Responses: {{.ResponseCount}}
+
+| Theme | Responses | Share |
+|-------|----------:|------:|
+{{range .ThemeStats}}| {{.Theme}} | {{.Count}} | {{printf "%.1f" .Percentage}}% |
+{{end}}
+{{- if .GlobalSummary}}
+{{stripCitations .GlobalSummary}}
+{{end}}
+{{end}}`
+
+// RenderCombinedReport renders the report of all questions of a survey with
+// several response columns, with the built-in template if templatePath is
+// empty
+func (r *Renderer) RenderCombinedReport(templatePath, outputPath string, questions []QuestionResult) error {
+	r.logger.Info("Rendering combined report", "template", templatePath, "output", outputPath, "questions", len(questions))
+
+	content := combinedReportTemplate
+	if templatePath != "" {
+		data, err := os.ReadFile(templatePath)
+		if err != nil {
+			return fmt.Errorf("failed to read template file: %w", err)
+		}
+		content = string(data)
+	}
+	tmpl, err := template.New("combined").Funcs(templateFuncs()).Parse(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	data := CombinedData{AnalysisDate: time.Now()}
+	for _, question := range questions {
+		if question.Result.ColumnTitle == "" {
+			question.Result.ColumnTitle = "Survey Responses"
+		}
+		questionData, err := r.prepareTemplateData(question.Result)
+		if err != nil {
+			return fmt.Errorf("failed to prepare template data of column %s: %w", question.Column, err)
+		}
+		data.Questions = append(data.Questions, QuestionData{Column: question.Column, TemplateData: *questionData})
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+	if err := tmpl.Execute(file, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	r.logger.Info("Combined report rendered", "output", outputPath)
+	return nil
+}
@@ -0,0 +1,335 @@
+package template
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/analysis"
+	"github.com/oetiker/response-analyzer/pkg/claude"
+)
+
+// HTMLReportData represents the data of the built-in HTML report
+type HTMLReportData struct {
+	ColumnTitle   string
+	Survey        *SurveyData
+	ResponseCount int
+	AnalysisDate  time.Time
+	GlobalSummary string
+	Bars          []HTMLBar
+	Heatmap       *HTMLHeatmap // Nil with less than two themes
+	Themes        []HTMLThemeSection
+
+	Accessible bool             // Whether the HTML uses the accessible layout
+	Style      htmltemplate.CSS // Stylesheet of the HTML theme and the charts
+}
+
+// HTMLBar is a bar of the theme frequency chart
+type HTMLBar struct {
+	Theme      string
+	Count      int
+	Percentage float64
+	Width      htmltemplate.CSS // Width of the bar relative to the most frequent theme
+}
+
+// HTMLHeatmap is the co-occurrence heatmap of the themes
+type HTMLHeatmap struct {
+	Themes []string
+	Rows   []HTMLHeatmapRow
+}
+
+// HTMLHeatmapRow is the row of a theme in the heatmap
+type HTMLHeatmapRow struct {
+	Theme string
+	Cells []HTMLHeatmapCell
+}
+
+// HTMLHeatmapCell is the number of responses with the themes of its row and
+// column, shaded by the share of the most frequent pair
+type HTMLHeatmapCell struct {
+	Theme    string // Theme of the column
+	Count    int
+	Diagonal bool // All responses of the theme rather than a pair
+	Style    htmltemplate.CSS
+}
+
+// HTMLThemeSection is the summary of a theme in the HTML report
+type HTMLThemeSection struct {
+	Theme       string
+	Count       int
+	Percentage  float64
+	Description string
+	Summary     string
+	UniqueIdeas []string
+	Sentiment   *claude.SentimentBalance
+	Positive    htmltemplate.CSS // Widths of the parts of the sentiment bar
+	Neutral     htmltemplate.CSS
+	Negative    htmltemplate.CSS
+}
+
+// htmlReportStyle styles the charts of the HTML report for both themes
+const htmlReportStyle = `.chart { width: 100%; border-collapse: collapse; margin-bottom: 2em; }
+.chart th { text-align: left; font-weight: normal; white-space: nowrap; padding-right: 1em; width: 1%; }
+.chart td { padding: 0.25em 0; }
+.track { background: #e8e8e8; }
+.bar { background: #1f5f99; height: 1.4em; min-width: 2px; }
+.value { white-space: nowrap; padding-left: 0.5em; width: 1%; }
+.heatmap { border-collapse: collapse; margin-bottom: 2em; }
+.heatmap th, .heatmap td { padding: 0.4em; text-align: center; border: 1px solid #ccc; }
+.heatmap thead th { writing-mode: vertical-rl; transform: rotate(180deg); text-align: left; font-weight: normal; }
+.heatmap tbody th { text-align: left; font-weight: normal; white-space: nowrap; }
+.heatmap td.diagonal { background: #f0f0f0; color: #555; font-style: italic; }
+.sentiment { display: flex; height: 1em; margin: 0.5em 0; }
+.positive { background: #1a7f37; }
+.neutral { background: #8c8c8c; }
+.negative { background: #b42318; }
+.meta { color: #555; }
+`
+
+// htmlReportAccessibleStyle overrides the chart colors for the accessible
+// theme, keeping the bars visible in the dark color scheme
+const htmlReportAccessibleStyle = `.track { background: #fff; border: 1px solid #000; }
+.bar { background: #000; }
+.heatmap th, .heatmap td { border-color: #000; }
+.heatmap td.diagonal { background: #fff; color: #000; }
+.meta { color: #000; }
+@media (prefers-color-scheme: dark) {
+  .track { background: #000; border-color: #fff; }
+  .bar { background: #fff; }
+  .heatmap th, .heatmap td { border-color: #fff; }
+  .heatmap td.diagonal { background: #000; color: #fff; }
+  .meta { color: #fff; }
+}
+`
+
+// htmlReportTemplate is the built-in HTML report. It is self-contained, the
+// charts are drawn with CSS and need no scripts or external files.
+const htmlReportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.ColumnTitle}}</title>
+<style>
+{{.Style}}</style>
+</head>
+<body>
+{{- if .Accessible}}
+<a class="skip" href="#report">Skip to the report</a>
+{{- end}}
+<header>
+<h1>{{if .Survey}}{{if .Survey.Title}}{{.Survey.Title}}: {{end}}{{end}}{{.ColumnTitle}}</h1>
+<p class="meta">{{.ResponseCount}} responses, analyzed {{.AnalysisDate.Format "2006-01-02"}}
+{{- if .Survey}}{{if .Survey.Period}}, {{.Survey.Period}}{{end}}{{if .Survey.ResponseRate}}, response rate {{printf "%.1f" .Survey.ResponseRate}}%{{end}}{{end}}</p>
+</header>
+{{- if .Accessible}}
+<nav aria-label="Sections">
+<ul>
+{{if .GlobalSummary}}<li><a href="#summary">Summary</a></li>
+{{end}}<li><a href="#frequency">Theme frequency</a></li>
+{{if .Heatmap}}<li><a href="#cooccurrence">Themes mentioned together</a></li>
+{{end}}<li><a href="#themes">Themes</a></li>
+</ul>
+</nav>
+{{- end}}
+<main id="report">
+{{- if .GlobalSummary}}
+<section aria-labelledby="summary">
+<h2 id="summary">Summary</h2>
+{{range paragraphs .GlobalSummary}}<p>{{.}}</p>
+{{end}}</section>
+{{- end}}
+<section aria-labelledby="frequency">
+<h2 id="frequency">Theme frequency</h2>
+<table class="chart">
+<caption>Responses per theme, with their share of all responses</caption>
+<tbody>
+{{range .Bars}}<tr><th scope="row">{{.Theme}}</th><td class="track"><div class="bar" style="width: {{.Width}}"></div></td><td class="value">{{.Count}} ({{printf "%.1f" .Percentage}}%)</td></tr>
+{{end}}</tbody>
+</table>
+</section>
+{{- if .Heatmap}}
+<section aria-labelledby="cooccurrence">
+<h2 id="cooccurrence">Themes mentioned together</h2>
+<table class="heatmap">
+<caption>Responses assigned to both themes, the diagonal counts all responses of a theme</caption>
+<thead><tr><td></td>{{range .Heatmap.Themes}}<th scope="col">{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Heatmap.Rows}}{{$row := .Theme}}<tr><th scope="row">{{.Theme}}</th>{{range .Cells}}<td{{if .Diagonal}} class="diagonal"{{else}} style="{{.Style}}"{{end}} title="{{$row}} / {{.Theme}}: {{.Count}}">{{.Count}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+</section>
+{{- end}}
+<section aria-labelledby="themes">
+<h2 id="themes">Themes</h2>
+{{range .Themes}}
+<article>
+<h3>{{.Theme}}</h3>
+<p class="meta">{{.Count}} responses ({{printf "%.1f" .Percentage}}%){{if .Description}}. {{.Description}}{{end}}</p>
+{{- if .Sentiment}}
+<div class="sentiment" role="img" aria-label="{{.Sentiment.Positive}} positive, {{.Sentiment.Neutral}} neutral, {{.Sentiment.Negative}} negative responses"><div class="positive" style="width: {{.Positive}}"></div><div class="neutral" style="width: {{.Neutral}}"></div><div class="negative" style="width: {{.Negative}}"></div></div>
+<p class="meta">{{.Sentiment.Positive}} positive, {{.Sentiment.Neutral}} neutral, {{.Sentiment.Negative}} negative</p>
+{{- end}}
+{{range paragraphs .Summary}}<p>{{.}}</p>
+{{end}}
+{{- if .UniqueIdeas}}
+<ul>
+{{range .UniqueIdeas}}<li>{{.}}</li>
+{{end}}</ul>
+{{- end}}
+</article>
+{{end}}
+</section>
+</main>
+</body>
+</html>
+`
+
+// RenderHTMLReport renders the self-contained HTML report with the theme
+// frequency chart, the co-occurrence heatmap and the summaries
+func (r *Renderer) RenderHTMLReport(outputPath string, result *analysis.AnalysisResult) error {
+	r.logger.Info("Rendering HTML report", "output", outputPath)
+
+	tmpl, err := htmltemplate.New("htmlreport").Funcs(htmltemplate.FuncMap{
+		// paragraphs splits a summary into paragraphs without citation markers
+		"paragraphs": func(text string) []string {
+			var paragraphs []string
+			for _, paragraph := range strings.Split(citationMarkerPattern.ReplaceAllString(text, ""), "\n\n") {
+				if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+					paragraphs = append(paragraphs, paragraph)
+				}
+			}
+			return paragraphs
+		},
+	}).Parse(htmlReportTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse HTML report template: %w", err)
+	}
+
+	data, err := r.prepareHTMLReportData(result)
+	if err != nil {
+		return fmt.Errorf("failed to prepare HTML report data: %w", err)
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+	if err := tmpl.Execute(file, data); err != nil {
+		return fmt.Errorf("failed to execute HTML report template: %w", err)
+	}
+
+	r.logger.Info("HTML report rendered", "output", outputPath)
+	return nil
+}
+
+// prepareHTMLReportData computes the charts of the HTML report, the themes
+// ordered by number of responses
+func (r *Renderer) prepareHTMLReportData(result *analysis.AnalysisResult) (*HTMLReportData, error) {
+	templateData, err := r.prepareTemplateData(result)
+	if err != nil {
+		return nil, err
+	}
+	data := &HTMLReportData{
+		ColumnTitle:   result.ColumnTitle,
+		Survey:        templateData.Survey,
+		ResponseCount: templateData.ResponseCount,
+		AnalysisDate:  templateData.AnalysisDate,
+		GlobalSummary: templateData.GlobalSummary,
+		Accessible:    r.htmlTheme == HTMLThemeAccessible,
+	}
+	if data.ColumnTitle == "" {
+		data.ColumnTitle = "Survey Responses"
+	}
+	if data.GlobalSummary == "" {
+		data.GlobalSummary = templateData.Summary
+	}
+	style := HTMLStyle(r.htmlTheme) + htmlReportStyle
+	if data.Accessible {
+		style += htmlReportAccessibleStyle
+	}
+	data.Style = htmltemplate.CSS(style)
+
+	maxCount := 0
+	for _, stat := range templateData.ThemeStats {
+		maxCount = max(maxCount, stat.Count)
+	}
+	var themes []string
+	for _, stat := range templateData.ThemeStats {
+		themes = append(themes, stat.Theme)
+		data.Bars = append(data.Bars, HTMLBar{
+			Theme:      stat.Theme,
+			Count:      stat.Count,
+			Percentage: stat.Percentage,
+			Width:      cssPercent(stat.Count, maxCount),
+		})
+
+		section := HTMLThemeSection{
+			Theme:       stat.Theme,
+			Count:       stat.Count,
+			Percentage:  stat.Percentage,
+			Description: result.ThemeDescriptions[stat.Theme].Description,
+		}
+		if summary, ok := result.ThemeSummaries[stat.Theme]; ok {
+			section.Summary = summary.Summary
+			section.UniqueIdeas = summary.UniqueIdeas
+			if sentiment := summary.Sentiment; sentiment != nil && sentiment.Total() > 0 {
+				section.Sentiment = sentiment
+				section.Positive = cssPercent(sentiment.Positive, sentiment.Total())
+				section.Neutral = cssPercent(sentiment.Neutral, sentiment.Total())
+				section.Negative = cssPercent(sentiment.Negative, sentiment.Total())
+			}
+		}
+		data.Themes = append(data.Themes, section)
+	}
+
+	if len(themes) >= 2 {
+		data.Heatmap = heatmap(analysis.ThemeCoOccurrence(result), themes)
+	}
+	return data, nil
+}
+
+// heatmap lays out the co-occurrence of the themes in the given order,
+// shading the pairs by their count relative to the most frequent pair
+func heatmap(cooccurrence *analysis.CoOccurrence, themes []string) *HTMLHeatmap {
+	maxPair := 0
+	for i, a := range themes {
+		for _, b := range themes[i+1:] {
+			maxPair = max(maxPair, cooccurrence.Count(a, b))
+		}
+	}
+
+	heatmap := &HTMLHeatmap{Themes: themes}
+	for _, a := range themes {
+		row := HTMLHeatmapRow{Theme: a}
+		for _, b := range themes {
+			cell := HTMLHeatmapCell{Theme: b, Count: cooccurrence.Count(a, b), Diagonal: a == b}
+			if !cell.Diagonal && maxPair > 0 {
+				shade := float64(cell.Count) / float64(maxPair)
+				// Switch to white text where the shade gets too dark to read
+				color := "#000"
+				if shade > 0.5 {
+					color = "#fff"
+				}
+				// Blend with white rather than relying on transparency, so the
+				// cells read the same on a dark page
+				blend := func(channel float64) int { return int(255 - shade*(255-channel)) }
+				cell.Style = htmltemplate.CSS(fmt.Sprintf("background: rgb(%d, %d, %d); color: %s", blend(31), blend(95), blend(153), color))
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		heatmap.Rows = append(heatmap.Rows, row)
+	}
+	return heatmap
+}
+
+// cssPercent formats the share of a count as a CSS width
+func cssPercent(count, total int) htmltemplate.CSS {
+	if total == 0 {
+		return "0%"
+	}
+	return htmltemplate.CSS(fmt.Sprintf("%.1f%%", float64(count)/float64(total)*100))
+}
@@ -3,7 +3,9 @@ package template
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"sort"
+	"strings"
 	"text/template"
 	"time"
 
@@ -14,35 +16,173 @@ import (
 
 // ThemeStat represents statistics for a theme
 type ThemeStat struct {
-	Theme      string  `yaml:"theme"`
-	Count      int     `yaml:"count"`
-	Percentage float64 `yaml:"percentage"`
+	Theme      string                   `yaml:"theme"`
+	Count      int                      `yaml:"count"`
+	Percentage float64                  `yaml:"percentage"`
+	Sentiment  *claude.SentimentBalance `yaml:"sentiment,omitempty"` // Responses of the theme by sentiment, nil without sentiment_enabled
 }
 
 // TemplateData represents the data available in templates
 type TemplateData struct {
-	Themes         []string
-	ThemeStats     []ThemeStat
-	ThemeSummaries map[string]claude.ThemeSummary
-	Summary        string
-	GlobalSummary  string
-	Responses      []ResponseData
-	ResponseCount  int
-	AnalysisDate   time.Time
-	ColumnTitle    string
+	Themes            []string
+	ThemeStats        []ThemeStat
+	ThemeSummaries    map[string]claude.ThemeSummary
+	Summary           string
+	GlobalSummary     string
+	SummaryVariants   []SummaryVariantData // Global summaries for the analyst to choose from, with summary_variant_selection all
+	Responses         []ResponseData
+	ResponseCount     int
+	AnalysisDate      time.Time
+	ColumnTitle       string
+	Survey            *SurveyData // Description of the survey, nil if none is configured
+	ProposedThemes    []analysis.ProposedTheme
+	ThemeSplits       []analysis.ThemeSplit
+	ThemeDescriptions map[string]claude.ThemeDescription
+	Citations         []CitationData
+	Outliers          []OutlierData
+	Clusters          []ClusterData
+	Topics            []TopicData
+	TimeSeries        *TimeSeriesData
+	Ratings           *RatingData
+	Sentiment         *claude.SentimentBalance // Responses by sentiment, nil without sentiment_enabled
+	Corpus            *analysis.CorpusStats    // Languages and lengths of the responses
+	Warnings          []analysis.WarningCount  // Non-fatal issues of the run by message, most frequent first
+	WarningTotal      int                      // Number of warnings, including those left out of the state
+	HTMLTheme         string                   // Theme of the HTML output, for HTML report templates
+	HTMLStyle         string                   // Stylesheet of the theme, e.g. <style>{{.HTMLStyle}}</style>
+}
+
+// SurveyData describes the survey in the template data
+type SurveyData struct {
+	Title        string
+	Period       string
+	Population   string
+	Invited      int     // Number of people asked, 0 if unknown
+	Respondents  int     // Number of answered rows, segments of a row count once
+	ResponseRate float64 // In percent, 0 if unknown
+	Methodology  string
+}
+
+// RatingData connects the themes to the numeric ratings in the template data
+type RatingData struct {
+	Rated      int
+	MeanRating float64
+	Themes     []analysis.ThemeRating // Rated themes in the order of the theme list
+	Bands      []RatingBandData
+	// Themes associated with lower and with higher ratings, strongest first
+	LowDrivers  []analysis.Driver
+	HighDrivers []analysis.Driver
+}
+
+// RatingBandData represents the themes of a band of ratings
+type RatingBandData struct {
+	Name      string
+	Min       float64
+	Max       float64
+	Responses int
+	Themes    []ThemeStat // Themes of the band by frequency, percentages of the band
+}
+
+// TimeSeriesData represents the theme frequencies per period in the template
+// data, with one row per theme and one column per period
+type TimeSeriesData struct {
+	Interval string // week or month
+	Periods  []TimePeriodData
+	Themes   []TimeSeriesTheme
+}
+
+// TimePeriodData represents a period of the time series in the template data
+type TimePeriodData struct {
+	Label     string
+	Start     time.Time
+	Responses int
+}
+
+// TimeSeriesTheme represents the frequencies of a theme per period
+type TimeSeriesTheme struct {
+	Theme       string
+	Counts      []int
+	Percentages []float64 // Share of the responses of each period, in percent
+}
+
+// TopicData represents a topic of the topic model baseline in the template data
+type TopicData struct {
+	Words         []string
+	Size          int
+	Percentage    float64
+	TopTheme      string
+	TopThemeShare float64 // Share of the topic assigned to the top theme, in percent
+}
+
+// ClusterData represents a cluster of responses in the template data
+type ClusterData struct {
+	Label         string
+	Size          int
+	Percentage    float64
+	TopTheme      string
+	TopThemeShare float64 // Share of the cluster assigned to the top theme, in percent
+	Responses     []ResponseData
+}
+
+// OutlierData represents a response far from all themes in the template data
+type OutlierData struct {
+	ID           string
+	Text         string
+	RowIndex     int
+	Themes       []string
+	NearestTheme string
+	Similarity   float64
+}
+
+// CitationData represents a citation of the global summary in the template data
+type CitationData struct {
+	Marker     string
+	Theme      string
+	ResponseID string
+	Text       string // Text of the cited response
+	RowIndex   int    // Row of the cited response
+}
+
+// SummaryVariantData represents a variant of the global summary in the
+// template data
+type SummaryVariantData struct {
+	Number  int
+	Summary string
+}
+
+// citationMarkerPattern matches citation markers such as [T2], [R15] or [R15.2]
+var citationMarkerPattern = regexp.MustCompile(`\[(T\d+|` + analysis.ResponseIDPattern + `)\]`)
+
+// templateFuncs returns the functions available in report templates
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		// citationLinks turns citation markers into footnote links for HTML reports
+		"citationLinks": func(text string) string {
+			return citationMarkerPattern.ReplaceAllString(text, `<sup><a href="#cite-$1">$1</a></sup>`)
+		},
+		// join joins a list of words
+		"join": strings.Join,
+		// stripCitations removes citation markers for plain-text reports
+		"stripCitations": func(text string) string {
+			return strings.TrimSpace(citationMarkerPattern.ReplaceAllString(text, ""))
+		},
+	}
 }
 
 // ResponseData represents a response in the template data
 type ResponseData struct {
-	ID       string
-	Text     string
-	Themes   []string
-	RowIndex int
+	ID        string
+	Text      string
+	Themes    []string
+	RowIndex  int
+	Segment   int               // Number of the segment of a split response, 0 if not split
+	Sentiment *claude.Sentiment // Sentiment of the response, nil without sentiment_enabled
 }
 
 // Renderer handles rendering templates
 type Renderer struct {
-	logger *logging.Logger
+	logger    *logging.Logger
+	htmlTheme string // Theme of the HTML output
 }
 
 // NewRenderer creates a new Renderer instance
@@ -68,7 +208,7 @@ func (r *Renderer) RenderTemplate(templatePath, outputPath string, result *analy
 	}
 
 	// Parse template
-	tmpl, err := template.New("report").Parse(string(tmplContent))
+	tmpl, err := template.New("report").Funcs(templateFuncs()).Parse(string(tmplContent))
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -112,6 +252,7 @@ func (r *Renderer) prepareTemplateData(result *analysis.AnalysisResult) (*Templa
 			Theme:      themeAnalysis.Theme,
 			Count:      count,
 			Percentage: percentage,
+			Sentiment:  themeAnalysis.Sentiment,
 		}
 		themeStats = append(themeStats, stat)
 	}
@@ -125,26 +266,194 @@ func (r *Renderer) prepareTemplateData(result *analysis.AnalysisResult) (*Templa
 	responses := make([]ResponseData, 0, len(result.ResponseAnalyses))
 	for _, responseAnalysis := range result.ResponseAnalyses {
 		response := ResponseData{
-			ID:       responseAnalysis.Response.ID,
-			Text:     responseAnalysis.Response.Text,
-			Themes:   responseAnalysis.Themes,
-			RowIndex: responseAnalysis.Response.RowIndex,
+			ID:        responseAnalysis.Response.ID,
+			Text:      responseAnalysis.Response.Text,
+			Themes:    responseAnalysis.Themes,
+			RowIndex:  responseAnalysis.Response.RowIndex,
+			Segment:   responseAnalysis.Response.Segment,
+			Sentiment: responseAnalysis.Sentiment,
 		}
 		responses = append(responses, response)
 	}
 
 	// Create template data
 	data := &TemplateData{
-		Themes:         result.Themes,
-		ThemeStats:     themeStats,
-		ThemeSummaries: result.ThemeSummaries,
-		Summary:        result.Summary,
-		GlobalSummary:  result.GlobalSummary,
-		Responses:      responses,
-		ResponseCount:  totalResponses,
-		AnalysisDate:   result.AnalysisTimestamp,
-		ColumnTitle:    result.ColumnTitle,
+		Themes:            result.Themes,
+		ThemeStats:        themeStats,
+		ThemeSummaries:    result.ThemeSummaries,
+		Summary:           result.Summary,
+		GlobalSummary:     result.GlobalSummary,
+		Responses:         responses,
+		ResponseCount:     totalResponses,
+		AnalysisDate:      result.AnalysisTimestamp,
+		ColumnTitle:       result.ColumnTitle,
+		ProposedThemes:    result.ProposedThemes,
+		ThemeSplits:       result.ThemeSplits,
+		ThemeDescriptions: result.ThemeDescriptions,
+		Sentiment:         analysis.OverallSentiment(result.ResponseAnalyses),
+	}
+
+	if survey := result.Survey; survey != nil {
+		data.Survey = &SurveyData{
+			Title:        survey.Title,
+			Period:       survey.Period,
+			Population:   survey.Population,
+			Invited:      survey.Invited,
+			ResponseRate: survey.ResponseRate,
+			Methodology:  survey.Methodology,
+		}
+		for _, responseAnalysis := range result.ResponseAnalyses {
+			if responseAnalysis.Response.Segment <= 1 {
+				data.Survey.Respondents++
+			}
+		}
+		if data.Survey.ResponseRate == 0 && survey.Invited > 0 {
+			data.Survey.ResponseRate = float64(data.Survey.Respondents) / float64(survey.Invited) * 100
+		}
+	}
+
+	for i, variant := range result.SummaryVariantsToChoose() {
+		data.SummaryVariants = append(data.SummaryVariants, SummaryVariantData{Number: i + 1, Summary: variant.Summary})
+	}
+
+	// Resolve the citations of the global summary
+	for _, citation := range result.SummaryCitations {
+		citationData := CitationData{
+			Marker:     citation.Marker,
+			Theme:      citation.Theme,
+			ResponseID: citation.ResponseID,
+		}
+		if responseAnalysis, ok := result.ResponseAnalyses[citation.ResponseID]; ok {
+			citationData.Text = responseAnalysis.Response.Text
+			citationData.RowIndex = responseAnalysis.Response.RowIndex
+		}
+		data.Citations = append(data.Citations, citationData)
+	}
+
+	// Resolve the outlier responses
+	for _, outlier := range result.Outliers {
+		responseAnalysis, ok := result.ResponseAnalyses[outlier.ResponseID]
+		if !ok {
+			continue
+		}
+		data.Outliers = append(data.Outliers, OutlierData{
+			ID:           outlier.ResponseID,
+			Text:         responseAnalysis.Response.Text,
+			RowIndex:     responseAnalysis.Response.RowIndex,
+			Themes:       responseAnalysis.Themes,
+			NearestTheme: outlier.NearestTheme,
+			Similarity:   outlier.Similarity,
+		})
+	}
+
+	// Resolve the responses of the clusters
+	for _, item := range result.Clusters {
+		clusterData := ClusterData{
+			Label:         item.Label,
+			Size:          item.Size,
+			TopTheme:      item.TopTheme,
+			TopThemeShare: item.TopThemeShare * 100.0,
+		}
+		if totalResponses > 0 {
+			clusterData.Percentage = float64(item.Size) / float64(totalResponses) * 100.0
+		}
+		for _, id := range item.Responses {
+			if responseAnalysis, ok := result.ResponseAnalyses[id]; ok {
+				clusterData.Responses = append(clusterData.Responses, ResponseData{
+					ID:       id,
+					Text:     responseAnalysis.Response.Text,
+					Themes:   responseAnalysis.Themes,
+					RowIndex: responseAnalysis.Response.RowIndex,
+					Segment:  responseAnalysis.Response.Segment,
+				})
+			}
+		}
+		data.Clusters = append(data.Clusters, clusterData)
+	}
+
+	// Add the topics of the topic model baseline
+	for _, topic := range result.Topics {
+		topicData := TopicData{
+			Words:         topic.Words,
+			Size:          topic.Size,
+			TopTheme:      topic.TopTheme,
+			TopThemeShare: topic.TopThemeShare * 100.0,
+		}
+		if totalResponses > 0 {
+			topicData.Percentage = float64(topic.Size) / float64(totalResponses) * 100.0
+		}
+		data.Topics = append(data.Topics, topicData)
+	}
+
+	// Add the theme frequencies per period, themes ordered by frequency
+	if series := result.TimeSeries; series != nil {
+		timeSeries := &TimeSeriesData{Interval: series.Interval}
+		for _, period := range series.Periods {
+			timeSeries.Periods = append(timeSeries.Periods, TimePeriodData{Label: period.Label, Start: period.Start, Responses: period.Responses})
+		}
+		for _, stat := range themeStats {
+			row := TimeSeriesTheme{Theme: stat.Theme}
+			for _, period := range series.Periods {
+				count := period.ThemeCounts[stat.Theme]
+				percentage := 0.0
+				if period.Responses > 0 {
+					percentage = float64(count) / float64(period.Responses) * 100.0
+				}
+				row.Counts = append(row.Counts, count)
+				row.Percentages = append(row.Percentages, percentage)
+			}
+			timeSeries.Themes = append(timeSeries.Themes, row)
+		}
+		data.TimeSeries = timeSeries
+	}
+
+	// Add the ratings per theme and the themes per rating band
+	if ratings := result.Ratings; ratings != nil {
+		ratingData := &RatingData{
+			Rated:       ratings.Rated,
+			MeanRating:  ratings.MeanRating,
+			LowDrivers:  ratings.LowDrivers(),
+			HighDrivers: ratings.HighDrivers(),
+		}
+		for _, theme := range ratings.Themes {
+			if theme.Rated > 0 {
+				ratingData.Themes = append(ratingData.Themes, theme)
+			}
+		}
+		for _, band := range ratings.Bands {
+			bandData := RatingBandData{Name: band.Name, Min: band.Min, Max: band.Max, Responses: band.Responses}
+			for theme, count := range band.ThemeCounts {
+				bandData.Themes = append(bandData.Themes, ThemeStat{
+					Theme:      theme,
+					Count:      count,
+					Percentage: float64(count) / float64(band.Responses) * 100.0,
+				})
+			}
+			sort.Slice(bandData.Themes, func(i, j int) bool {
+				if bandData.Themes[i].Count != bandData.Themes[j].Count {
+					return bandData.Themes[i].Count > bandData.Themes[j].Count
+				}
+				return bandData.Themes[i].Theme < bandData.Themes[j].Theme
+			})
+			ratingData.Bands = append(ratingData.Bands, bandData)
+		}
+		data.Ratings = ratingData
+	}
+
+	// Describe the responses, also for results saved before the statistics existed
+	data.Corpus = result.Corpus
+	if data.Corpus == nil {
+		data.Corpus = analysis.BuildCorpusStats(result.ResponseAnalyses, result.Themes)
+	}
+
+	// Add the non-fatal issues of the run
+	data.Warnings = result.WarningCounts()
+	data.WarningTotal = len(result.Warnings) + result.DroppedWarnings
+	data.HTMLTheme = r.htmlTheme
+	if data.HTMLTheme == "" {
+		data.HTMLTheme = HTMLThemeDefault
 	}
+	data.HTMLStyle = HTMLStyle(r.htmlTheme)
 
 	// If ColumnTitle is empty, use a default value
 	if data.ColumnTitle == "" {
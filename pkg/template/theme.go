@@ -0,0 +1,53 @@
+package template
+
+// Themes of the HTML output
+const (
+	HTMLThemeDefault    = "default"
+	HTMLThemeAccessible = "accessible" // High contrast, screen-reader-friendly layout
+)
+
+// HTMLThemes lists the valid themes of the HTML output
+var HTMLThemes = []string{HTMLThemeDefault, HTMLThemeAccessible}
+
+// htmlStyles are the stylesheets of the HTML themes
+var htmlStyles = map[string]string{
+	HTMLThemeDefault: `body { font-family: sans-serif; max-width: 60em; margin: 2em auto; }
+td.row { white-space: nowrap; vertical-align: top; color: #555; }
+td { padding: 0.2em 0.5em; }
+`,
+	// Black on white with a contrast above WCAG AAA, text that can be
+	// enlarged, visible focus and links that do not rely on color
+	HTMLThemeAccessible: `body { font-family: Verdana, Arial, sans-serif; font-size: 1.125rem; line-height: 1.6; color: #000; background: #fff; max-width: 70ch; margin: 2rem auto; padding: 0 1rem; }
+a { color: #00008b; text-decoration: underline; }
+a:focus, a:hover { outline: 3px solid #000; outline-offset: 2px; background: #ff0; color: #000; }
+.skip { position: absolute; left: -999rem; }
+.skip:focus { position: static; display: inline-block; padding: 0.5rem; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2rem; }
+caption { text-align: left; font-weight: bold; padding: 0.5rem 0; }
+th, td { border: 1px solid #000; padding: 0.5rem; text-align: left; vertical-align: top; }
+thead th { background: #000; color: #fff; }
+tbody th { font-weight: normal; white-space: nowrap; }
+@media (prefers-color-scheme: dark) {
+  body { color: #fff; background: #000; }
+  a { color: #ffff00; }
+  a:focus, a:hover { outline-color: #fff; }
+  th, td { border-color: #fff; }
+  thead th { background: #fff; color: #000; }
+}
+@media print { .skip, nav { display: none; } }
+`,
+}
+
+// HTMLStyle returns the stylesheet of an HTML theme, the default theme for
+// an empty or unknown name
+func HTMLStyle(theme string) string {
+	if style, ok := htmlStyles[theme]; ok {
+		return style
+	}
+	return htmlStyles[HTMLThemeDefault]
+}
+
+// SetHTMLTheme sets the theme of the HTML output
+func (r *Renderer) SetHTMLTheme(theme string) {
+	r.htmlTheme = theme
+}
@@ -0,0 +1,244 @@
+package topics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/oetiker/response-analyzer/pkg/embedding"
+)
+
+const (
+	// DefaultIterations is the number of multiplicative update iterations
+	DefaultIterations = 200
+	// DefaultTopWords is the number of words reported per topic
+	DefaultTopWords = 8
+	// maxVocabulary limits the number of terms considered
+	maxVocabulary = 2000
+	// seed makes the factorization deterministic
+	seed = 42
+	// epsilon avoids divisions by zero in the update rules
+	epsilon = 1e-9
+)
+
+// Topic represents a topic found by the topic model
+type Topic struct {
+	Words     []string // Most characteristic words of the topic
+	Documents []int    // Indices of the documents whose dominant topic this is
+}
+
+// Model is a classical non-negative matrix factorization (NMF) topic model
+// over TF-IDF weighted word counts. It needs no LLM and serves as a
+// conservative baseline for the themes.
+type Model struct {
+	TopicCount int
+	Iterations int
+	TopWords   int
+}
+
+// NewModel creates a topic model finding the given number of topics
+func NewModel(topicCount int) *Model {
+	return &Model{
+		TopicCount: topicCount,
+		Iterations: DefaultIterations,
+		TopWords:   DefaultTopWords,
+	}
+}
+
+// Fit finds the topics of the documents
+func (m *Model) Fit(documents []string) []Topic {
+	vocabulary, matrix := tfidf(documents)
+	if len(vocabulary) == 0 || m.TopicCount <= 0 {
+		return nil
+	}
+	k := m.TopicCount
+	if k > len(documents) {
+		k = len(documents)
+	}
+
+	w, h := factorize(matrix, len(vocabulary), k, m.Iterations)
+
+	topics := make([]Topic, k)
+	for t := range topics {
+		// Rank the terms by their weight in the topic
+		terms := make([]int, len(vocabulary))
+		for i := range terms {
+			terms[i] = i
+		}
+		sort.Slice(terms, func(i, j int) bool {
+			if h[t][terms[i]] != h[t][terms[j]] {
+				return h[t][terms[i]] > h[t][terms[j]]
+			}
+			return vocabulary[terms[i]] < vocabulary[terms[j]]
+		})
+		for _, term := range terms[:min(m.TopWords, len(terms))] {
+			if h[t][term] <= epsilon {
+				break
+			}
+			topics[t].Words = append(topics[t].Words, vocabulary[term])
+		}
+	}
+
+	// Assign each document to its dominant topic
+	for d, weights := range w {
+		if len(matrix[d]) == 0 {
+			continue
+		}
+		best, bestWeight := -1, 0.0
+		for t, weight := range weights {
+			if weight > bestWeight {
+				best, bestWeight = t, weight
+			}
+		}
+		if best >= 0 {
+			topics[best].Documents = append(topics[best].Documents, d)
+		}
+	}
+
+	return topics
+}
+
+// tfidf builds the vocabulary and the TF-IDF matrix of the documents. Terms
+// occurring in fewer than two documents or in the stop word list are ignored.
+func tfidf(documents []string) ([]string, []map[int]float64) {
+	tokenized := make([][]string, len(documents))
+	documentFrequency := make(map[string]int)
+	for d, document := range documents {
+		seen := make(map[string]bool)
+		for _, word := range embedding.Tokenize(document) {
+			if utf8.RuneCountInString(word) < 3 || stopWords[word] {
+				continue
+			}
+			tokenized[d] = append(tokenized[d], word)
+			if !seen[word] {
+				seen[word] = true
+				documentFrequency[word]++
+			}
+		}
+	}
+
+	// Keep the most frequent terms occurring in at least two documents
+	var vocabulary []string
+	for word, frequency := range documentFrequency {
+		if frequency >= 2 {
+			vocabulary = append(vocabulary, word)
+		}
+	}
+	sort.Slice(vocabulary, func(i, j int) bool {
+		if documentFrequency[vocabulary[i]] != documentFrequency[vocabulary[j]] {
+			return documentFrequency[vocabulary[i]] > documentFrequency[vocabulary[j]]
+		}
+		return vocabulary[i] < vocabulary[j]
+	})
+	if len(vocabulary) > maxVocabulary {
+		vocabulary = vocabulary[:maxVocabulary]
+	}
+	index := make(map[string]int, len(vocabulary))
+	for i, word := range vocabulary {
+		index[word] = i
+	}
+
+	matrix := make([]map[int]float64, len(documents))
+	for d, words := range tokenized {
+		matrix[d] = make(map[int]float64)
+		for _, word := range words {
+			if i, ok := index[word]; ok {
+				matrix[d][i]++
+			}
+		}
+		for i, count := range matrix[d] {
+			idf := math.Log(float64(len(documents))/float64(documentFrequency[vocabulary[i]])) + 1
+			matrix[d][i] = count * idf
+		}
+	}
+	return vocabulary, matrix
+}
+
+// factorize approximates the sparse documents x terms matrix V by W*H using
+// the multiplicative update rules of Lee and Seung
+func factorize(v []map[int]float64, terms, k, iterations int) ([][]float64, [][]float64) {
+	rng := rand.New(rand.NewSource(seed))
+	w := randomMatrix(rng, len(v), k)
+	h := randomMatrix(rng, k, terms)
+
+	for iteration := 0; iteration < iterations; iteration++ {
+		// Update H: H *= (W^T V) / (W^T W H)
+		wtv := make([][]float64, k)
+		for t := range wtv {
+			wtv[t] = make([]float64, terms)
+		}
+		for d, row := range v {
+			for i, value := range row {
+				for t := 0; t < k; t++ {
+					wtv[t][i] += w[d][t] * value
+				}
+			}
+		}
+		wtw := gram(w, k)
+		for t := 0; t < k; t++ {
+			for i := 0; i < terms; i++ {
+				denominator := 0.0
+				for s := 0; s < k; s++ {
+					denominator += wtw[t][s] * h[s][i]
+				}
+				h[t][i] *= wtv[t][i] / (denominator + epsilon)
+			}
+		}
+
+		// Update W: W *= (V H^T) / (W H H^T)
+		hht := make([][]float64, k)
+		for t := range hht {
+			hht[t] = make([]float64, k)
+			for s := 0; s < k; s++ {
+				for i := 0; i < terms; i++ {
+					hht[t][s] += h[t][i] * h[s][i]
+				}
+			}
+		}
+		for d, row := range v {
+			vht := make([]float64, k)
+			for i, value := range row {
+				for t := 0; t < k; t++ {
+					vht[t] += value * h[t][i]
+				}
+			}
+			for t := 0; t < k; t++ {
+				denominator := 0.0
+				for s := 0; s < k; s++ {
+					denominator += w[d][s] * hht[s][t]
+				}
+				w[d][t] *= vht[t] / (denominator + epsilon)
+			}
+		}
+	}
+	return w, h
+}
+
+// gram returns W^T W for a rows x k matrix W
+func gram(w [][]float64, k int) [][]float64 {
+	result := make([][]float64, k)
+	for t := range result {
+		result[t] = make([]float64, k)
+	}
+	for _, row := range w {
+		for t := 0; t < k; t++ {
+			for s := 0; s < k; s++ {
+				result[t][s] += row[t] * row[s]
+			}
+		}
+	}
+	return result
+}
+
+// randomMatrix returns a rows x columns matrix of positive random values
+func randomMatrix(rng *rand.Rand, rows, columns int) [][]float64 {
+	matrix := make([][]float64, rows)
+	for r := range matrix {
+		matrix[r] = make([]float64, columns)
+		for c := range matrix[r] {
+			matrix[r][c] = rng.Float64() + epsilon
+		}
+	}
+	return matrix
+}
@@ -0,0 +1,32 @@
+package topics
+
+// stopWords lists common English and German words that carry no topic
+var stopWords = toSet(
+	// English
+	"the", "and", "for", "are", "but", "not", "you", "all", "any", "can", "had", "her", "was", "one", "our", "out",
+	"has", "him", "his", "how", "its", "may", "new", "now", "see", "who", "did", "get", "let", "say", "she", "too",
+	"use", "that", "this", "with", "have", "from", "they", "will", "would", "there", "their", "what", "about",
+	"which", "when", "make", "like", "time", "just", "know", "take", "into", "your", "some", "could", "them",
+	"than", "then", "look", "only", "come", "over", "think", "also", "back", "after", "work", "first", "well",
+	"even", "want", "because", "these", "give", "most", "very", "more", "much", "should", "been", "were", "does",
+	"being", "other", "such", "here", "where", "while", "those", "many", "same", "each", "both", "own", "why",
+	"off", "yes", "really", "things", "thing", "lot", "don", "doesn", "didn", "isn", "aren", "wasn",
+	// German
+	"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "einen", "einem", "einer", "eines", "mit", "den",
+	"dem", "des", "sich", "auf", "für", "von", "auch", "als", "aus", "bei", "nach", "wie", "wir", "ich", "sie",
+	"aber", "oder", "noch", "nur", "mehr", "sehr", "sind", "wird", "werden", "wurde", "kann", "können", "hat",
+	"haben", "man", "zum", "zur", "über", "unter", "durch", "dass", "wenn", "was", "wer", "mir", "mich", "uns",
+	"ihr", "ihre", "ihren", "sein", "seine", "keine", "kein", "schon", "immer", "viel", "viele", "gibt", "gut",
+	"dies", "diese", "dieser", "dieses", "hier", "dort", "dann", "denn", "doch", "jedoch", "etwas", "alle",
+	"allem", "wäre", "wären", "würde", "sollte", "sollten", "muss", "müssen", "beim", "vom", "bis", "ohne",
+	"gegen", "weil", "damit", "nein", "mal", "eher", "eigentlich", "ganz", "habe", "bin", "war", "waren",
+)
+
+// toSet converts a list of words into a set
+func toSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
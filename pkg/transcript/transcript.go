@@ -0,0 +1,191 @@
+package transcript
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Units a transcript is segmented into
+const (
+	SegmentTurn     = "turn"     // One response per speaker turn
+	SegmentQuestion = "question" // One response per question of an interviewer, with all answers to it
+)
+
+var (
+	// timing is the time range of a VTT or SRT cue
+	timing = regexp.MustCompile(`^((?:\d{1,2}:)?\d{1,2}:\d{2})[.,]\d{1,3}\s+-->\s+`)
+	// voice is the speaker tag of a VTT cue, <v Name>
+	voice = regexp.MustCompile(`^<v(?:\.[^\s>]*)?\s+([^>]+)>`)
+	// tags are the other markup of VTT cues
+	tags = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	// leadingTime is a time stamp in front of a line of a plain transcript,
+	// e.g. [00:12:31] or 12:31
+	leadingTime = regexp.MustCompile(`^\[?((?:\d{1,2}:)?\d{1,2}:\d{2})\]?\s+`)
+	// label is a speaker label in front of a line, "Name:" or "[Name]"
+	label = regexp.MustCompile(`^(?:\[([^\]\d][^\]]{0,39})\]|([\p{L}][\p{L}\p{N} ._'-]{0,39}):)\s*`)
+)
+
+// Turn is what a speaker said without interruption
+type Turn struct {
+	Speaker string
+	Start   string // Time in the recording, e.g. 00:12:31, if the transcript has times
+	Text    string
+}
+
+// Segment is a part of a transcript analyzed as one response
+type Segment struct {
+	Number   int
+	Start    string
+	Speaker  string // Speakers of the segment, separated by commas
+	Question string // Last question of an interviewer before the segment
+	Text     string
+}
+
+// Parse reads the turns of a WebVTT (.vtt), SubRip (.srt) or plain text
+// transcript. Speakers are taken from VTT voice tags and from labels like
+// "Name:" or "[Name]" in front of a line; lines without a label continue the
+// turn of the previous speaker.
+func Parse(path string) ([]Turn, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer file.Close()
+
+	cues := strings.EqualFold(filepath.Ext(path), ".vtt") || strings.EqualFold(filepath.Ext(path), ".srt")
+	var turns []Turn
+	speaker, start := "", ""
+	addLine := func(line string) {
+		if match := voice.FindStringSubmatch(line); match != nil {
+			speaker = strings.TrimSpace(match[1])
+			line = line[len(match[0]):]
+		}
+		line = strings.TrimSpace(tags.ReplaceAllString(line, ""))
+		if !cues {
+			if match := leadingTime.FindStringSubmatch(line); match != nil {
+				start = match[1]
+				line = line[len(match[0]):]
+			}
+		}
+		if match := label.FindStringSubmatch(line); match != nil {
+			speaker = strings.TrimSpace(match[1] + match[2])
+			line = line[len(match[0]):]
+		}
+		if line == "" {
+			return
+		}
+		if n := len(turns); n > 0 && turns[n-1].Speaker == speaker {
+			turns[n-1].Text += " " + line
+			return
+		}
+		turns = append(turns, Turn{Speaker: speaker, Start: start, Text: line})
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	inCue := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if !cues {
+			addLine(line)
+			continue
+		}
+		// Cues are a time range followed by text lines up to a blank line;
+		// headers, cue numbers and notes outside of cues are skipped
+		switch match := timing.FindStringSubmatch(line); {
+		case match != nil:
+			start, inCue = normalizeTime(match[1]), true
+		case line == "":
+			inCue = false
+		case inCue:
+			addLine(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	return turns, nil
+}
+
+// normalizeTime gives times without hours an hour part, 01:02 becomes 00:01:02
+func normalizeTime(value string) string {
+	if strings.Count(value, ":") == 1 {
+		return "00:" + value
+	}
+	return value
+}
+
+// Segments splits the turns into the segments analyzed as responses. The
+// turns of the interviewers are not segments themselves but the question of
+// the segments after them. By question, all answers to a question form one
+// segment, which needs the interviewers.
+func Segments(turns []Turn, mode string, interviewers []string) ([]Segment, error) {
+	if mode == SegmentQuestion && len(interviewers) == 0 {
+		return nil, fmt.Errorf("segmenting by question needs the speaker labels of the interviewers")
+	}
+	isInterviewer := func(speaker string) bool {
+		for _, interviewer := range interviewers {
+			if strings.EqualFold(interviewer, speaker) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var segments []Segment
+	question := ""
+	var answers []Turn
+	flush := func() {
+		if len(answers) == 0 {
+			return
+		}
+		segment := Segment{Number: len(segments) + 1, Start: normalizeTime(answers[0].Start), Question: question}
+		var speakers, lines []string
+		for _, answer := range answers {
+			if !containsFold(speakers, answer.Speaker) && answer.Speaker != "" {
+				speakers = append(speakers, answer.Speaker)
+			}
+			lines = append(lines, answer.Speaker+": "+answer.Text)
+		}
+		segment.Speaker = strings.Join(speakers, ", ")
+		if len(speakers) <= 1 {
+			texts := make([]string, len(answers))
+			for i, answer := range answers {
+				texts[i] = answer.Text
+			}
+			segment.Text = strings.Join(texts, "\n")
+		} else {
+			segment.Text = strings.Join(lines, "\n")
+		}
+		segments = append(segments, segment)
+		answers = nil
+	}
+
+	for _, turn := range turns {
+		if isInterviewer(turn.Speaker) {
+			flush()
+			question = turn.Text
+			continue
+		}
+		answers = append(answers, turn)
+		if mode != SegmentQuestion {
+			flush()
+		}
+	}
+	flush()
+	return segments, nil
+}
+
+// containsFold returns whether the list contains the value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
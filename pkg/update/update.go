@@ -0,0 +1,295 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+const (
+	// Repository is the GitHub repository releases are published in
+	Repository = "oetiker/response-analyzer"
+	// BinaryName is the name of the binary inside the release archives
+	BinaryName = "response-analyzer"
+	// ChecksumsAsset is the release asset listing the SHA-256 checksums of
+	// the archives, in the format of sha256sum
+	ChecksumsAsset = "checksums.txt"
+)
+
+// Release describes a GitHub release
+type Release struct {
+	TagName    string  `json:"tag_name"`
+	HTMLURL    string  `json:"html_url"`
+	Prerelease bool    `json:"prerelease"`
+	Assets     []Asset `json:"assets"`
+}
+
+// Asset describes a file attached to a GitHub release
+type Asset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// Updater checks for and installs new releases
+type Updater struct {
+	logger     *logging.Logger
+	httpClient *http.Client
+	apiURL     string
+}
+
+// NewUpdater creates a new Updater instance
+func NewUpdater(logger *logging.Logger) *Updater {
+	return &Updater{
+		logger:     logger,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		apiURL:     "https://api.github.com/repos/" + Repository + "/releases/latest",
+	}
+}
+
+// LatestRelease fetches the latest release from GitHub
+func (u *Updater) LatestRelease() (*Release, error) {
+	u.logger.Debug("Checking for the latest release", "url", u.apiURL)
+
+	req, err := http.NewRequest("GET", u.apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("release request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to decode release: %w", err)
+	}
+	return &release, nil
+}
+
+// AssetName returns the name of the release archive for the current platform
+func AssetName(tag string) string {
+	archive := "tar.gz"
+	if runtime.GOOS == "windows" {
+		archive = "zip"
+	}
+	return fmt.Sprintf("%s-%s-%s-%s.%s", BinaryName, tag, runtime.GOOS, runtime.GOARCH, archive)
+}
+
+// IsNewer reports whether the version latest is newer than current. Versions
+// are compared numerically by their dot separated components, ignoring a
+// leading "v" and pre-release suffixes.
+func IsNewer(latest, current string) bool {
+	l := versionParts(latest)
+	c := versionParts(current)
+	for i := 0; i < len(l) || i < len(c); i++ {
+		var a, b int
+		if i < len(l) {
+			a = l[i]
+		}
+		if i < len(c) {
+			b = c[i]
+		}
+		if a != b {
+			return a > b
+		}
+	}
+	return false
+}
+
+// versionParts splits a version such as v1.2.3-rc1 into its numbers
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	var parts []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// DownloadBinary downloads the release archive for the current platform,
+// verifies it against the checksums published with the release and returns
+// the binary it contains. A release without checksums is not installed.
+func (u *Updater) DownloadBinary(release *Release) ([]byte, error) {
+	name := AssetName(release.TagName)
+	asset := release.asset(name)
+	if asset == nil {
+		return nil, fmt.Errorf("release %s has no archive for %s/%s (expected %s)", release.TagName, runtime.GOOS, runtime.GOARCH, name)
+	}
+	checksums := release.asset(ChecksumsAsset)
+	if checksums == nil {
+		return nil, fmt.Errorf("release %s has no %s, refusing to install an unverified binary", release.TagName, ChecksumsAsset)
+	}
+
+	u.logger.Info("Downloading release", "asset", asset.Name)
+	sums, err := u.download(checksums)
+	if err != nil {
+		return nil, err
+	}
+	data, err := u.download(asset)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(sums, asset.Name, data); err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(asset.Name, ".zip") {
+		return extractFromZip(data, BinaryName+".exe")
+	}
+	return extractFromTarGz(data, BinaryName)
+}
+
+// asset returns the asset of the release with the given name, nil if there
+// is none
+func (r *Release) asset(name string) *Asset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+// download returns the contents of a release asset
+func (u *Updater) download(asset *Asset) ([]byte, error) {
+	resp, err := u.httpClient.Get(asset.BrowserDownloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download of %s failed with status %d", asset.Name, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", asset.Name, err)
+	}
+	return data, nil
+}
+
+// verifyChecksum checks the SHA-256 checksum of a downloaded archive against
+// the line of the checksums file naming it
+func verifyChecksum(checksums []byte, name string, data []byte) error {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+			return fmt.Errorf("checksum of %s does not match %s, refusing to install it", name, ChecksumsAsset)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s lists no checksum for %s, refusing to install an unverified binary", ChecksumsAsset, name)
+}
+
+// extractFromZip returns the contents of the named file in a zip archive
+func extractFromZip(data []byte, name string) ([]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open release archive: %w", err)
+	}
+	for _, file := range reader.File {
+		if filepath.Base(file.Name) != name {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s in release archive: %w", name, err)
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("release archive does not contain %s", name)
+}
+
+// extractFromTarGz returns the contents of the named file in a tar.gz archive
+func extractFromTarGz(data []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open release archive: %w", err)
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read release archive: %w", err)
+		}
+		if header.Typeflag == tar.TypeReg && filepath.Base(header.Name) == name {
+			return io.ReadAll(reader)
+		}
+	}
+	return nil, fmt.Errorf("release archive does not contain %s", name)
+}
+
+// ReplaceExecutable replaces the running executable by the given binary. The
+// new binary is written next to the executable and renamed over it, so the
+// executable is never left half-written.
+func ReplaceExecutable(binary []byte) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+
+	newPath := executable + ".new"
+	if err := os.WriteFile(newPath, binary, 0755); err != nil {
+		return "", fmt.Errorf("failed to write new executable: %w", err)
+	}
+
+	// A running executable cannot be overwritten on Windows, but it can be renamed
+	oldPath := executable + ".old"
+	if runtime.GOOS == "windows" {
+		os.Remove(oldPath)
+		if err := os.Rename(executable, oldPath); err != nil {
+			os.Remove(newPath)
+			return "", fmt.Errorf("failed to move old executable: %w", err)
+		}
+	}
+	if err := os.Rename(newPath, executable); err != nil {
+		if runtime.GOOS == "windows" {
+			os.Rename(oldPath, executable)
+		}
+		os.Remove(newPath)
+		return "", fmt.Errorf("failed to replace executable: %w", err)
+	}
+	return executable, nil
+}
@@ -0,0 +1,47 @@
+package update
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oetiker/response-analyzer/pkg/logging"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	archive := []byte("archive")
+	const archiveSum = "0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3" // SHA-256 of archive
+	const otherSum = "d6c8e2d2a7a7e9d0d1ed0a3d6d8c3b6f5c2a1b1e2f5d39f8d2d8a2e1b0c6f1a4"
+
+	tests := []struct {
+		name      string
+		checksums string
+		asset     string
+		wantErr   string
+	}{
+		{name: "matching", checksums: otherSum + "  other.tar.gz\n" + archiveSum + "  response-analyzer-v1.0.0-linux-amd64.tar.gz\n", asset: "response-analyzer-v1.0.0-linux-amd64.tar.gz"},
+		{name: "binary mode", checksums: strings.ToUpper(archiveSum) + " *response-analyzer-v1.0.0-windows-amd64.zip\n", asset: "response-analyzer-v1.0.0-windows-amd64.zip"},
+		{name: "mismatch", checksums: otherSum + "  response-analyzer-v1.0.0-linux-amd64.tar.gz\n", asset: "response-analyzer-v1.0.0-linux-amd64.tar.gz", wantErr: "does not match"},
+		{name: "not listed", checksums: archiveSum + "  response-analyzer-v1.0.0-darwin-arm64.tar.gz\n", asset: "response-analyzer-v1.0.0-linux-amd64.tar.gz", wantErr: "lists no checksum"},
+		{name: "empty", checksums: "", asset: "response-analyzer-v1.0.0-linux-amd64.tar.gz", wantErr: "lists no checksum"},
+	}
+	for _, test := range tests {
+		err := verifyChecksum([]byte(test.checksums), test.asset, archive)
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+			t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestDownloadBinaryRequiresChecksums(t *testing.T) {
+	updater := NewUpdater(logging.NewLogger(false))
+	release := &Release{
+		TagName: "v1.0.0",
+		Assets:  []Asset{{Name: AssetName("v1.0.0"), BrowserDownloadURL: "http://127.0.0.1:0/archive"}},
+	}
+	if _, err := updater.DownloadBinary(release); err == nil || !strings.Contains(err.Error(), ChecksumsAsset) {
+		t.Errorf("got error %v, want one about the missing %s", err, ChecksumsAsset)
+	}
+}
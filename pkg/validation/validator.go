@@ -2,12 +2,19 @@ package validation
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/oetiker/response-analyzer/pkg/claude"
 	"github.com/oetiker/response-analyzer/pkg/config"
 	"github.com/oetiker/response-analyzer/pkg/excel"
+	"github.com/oetiker/response-analyzer/pkg/input"
+	"github.com/oetiker/response-analyzer/pkg/llm"
 	"github.com/oetiker/response-analyzer/pkg/logging"
+	"github.com/oetiker/response-analyzer/pkg/template"
 )
 
 // Validator handles validation of inputs
@@ -15,6 +22,23 @@ type Validator struct {
 	logger *logging.Logger
 }
 
+// maxSummaryVariants limits the global summary variants generated per run
+const maxSummaryVariants = 10
+
+// validLanguages lists the supported output languages
+var validLanguages = map[string]bool{
+	"en":    true,
+	"de":    true,
+	"de-ch": true,
+	"fr":    true,
+	"it":    true,
+}
+
+// ValidLanguage reports whether the output language is supported
+func ValidLanguage(language string) bool {
+	return validLanguages[language]
+}
+
 // NewValidator creates a new Validator instance
 func NewValidator(logger *logging.Logger) *Validator {
 	return &Validator{
@@ -32,33 +56,332 @@ func (v *Validator) ValidateConfig(cfg *config.Config) error {
 	}
 
 	// Check if response column is valid
-	if cfg.ResponseColumn == "" {
+	columns := cfg.ResponseColumns
+	switch {
+	case cfg.ResponseColumn == "" && len(columns) == 0:
 		return fmt.Errorf("response_column is required")
+	case cfg.ResponseColumn != "" && len(columns) > 0:
+		return fmt.Errorf("response_column and response_columns cannot be combined")
+	case len(columns) == 0:
+		columns = []string{cfg.ResponseColumn}
+	}
+	seen := make(map[string]bool)
+	for _, column := range cfg.ResponseColumns {
+		if seen[strings.ToUpper(column)] {
+			return fmt.Errorf("duplicate response_columns entry: %s", column)
+		}
+		seen[strings.ToUpper(column)] = true
+	}
+	if cfg.CombinedReportTemplatePath != "" {
+		if _, err := os.Stat(cfg.CombinedReportTemplatePath); os.IsNotExist(err) {
+			return fmt.Errorf("combined report template file does not exist: %s", cfg.CombinedReportTemplatePath)
+		}
 	}
 
 	// Check if Claude API key is provided
-	if cfg.ClaudeAPIKey == "" {
+	if cfg.ClaudeAPIKey == "" && len(cfg.ClaudeAPIKeys) == 0 && !cfg.MockProvider {
 		return fmt.Errorf("claude_api_key is required")
 	}
+	names := make(map[string]bool)
+	for _, key := range cfg.ClaudeAPIKeys {
+		if key.Key == "" {
+			return fmt.Errorf("claude_api_keys entry %s has no key", key.Name)
+		}
+		if names[key.Name] {
+			return fmt.Errorf("duplicate claude_api_keys name: %s", key.Name)
+		}
+		names[key.Name] = true
+	}
+	if cfg.APIKeyRotation != claude.RotateRoundRobin && cfg.APIKeyRotation != claude.RotateOnRateLimit {
+		return fmt.Errorf("invalid api_key_rotation: %s (valid options: %s, %s)", cfg.APIKeyRotation, claude.RotateRoundRobin, claude.RotateOnRateLimit)
+	}
 
 	// Validate Excel file and column
+	if cfg.InputFormat != "" && !slices.Contains(input.Formats, cfg.InputFormat) {
+		return fmt.Errorf("invalid input_format: %s (valid options: %s)", cfg.InputFormat, strings.Join(input.Formats, ", "))
+	}
 	excelReader := excel.NewExcelReader(v.logger)
-	if err := excelReader.ValidateExcelFile(cfg.ExcelFilePath, cfg.ResponseColumn); err != nil {
-		return fmt.Errorf("Excel file validation failed: %w", err)
+	excelReader.SetInputFormat(cfg.InputFormat)
+	for _, column := range columns {
+		if err := excelReader.ValidateExcelFile(cfg.ExcelFilePath, column); err != nil {
+			return fmt.Errorf("Excel file validation failed: %w", err)
+		}
+	}
+
+	// Validate preprocessing
+	if _, err := excel.NewPreprocessor(cfg.Preprocessing, cfg.SpellingCorrections, cfg.BoilerplatePatterns); err != nil {
+		return fmt.Errorf("invalid preprocessing: %w", err)
+	}
+	if len(cfg.SpellingCorrections) > 0 && !slices.Contains(cfg.Preprocessing, excel.StepSpellfix) {
+		return fmt.Errorf("spelling_corrections requires the %s preprocessing step", excel.StepSpellfix)
+	}
+	if len(cfg.BoilerplatePatterns) > 0 && !slices.Contains(cfg.Preprocessing, excel.StepStripEmail) {
+		return fmt.Errorf("boilerplate_patterns requires the %s preprocessing step", excel.StepStripEmail)
+	}
+
+	// Validate time series
+	if cfg.TimestampColumn != "" {
+		if err := excelReader.ValidateExcelFile(cfg.ExcelFilePath, cfg.TimestampColumn); err != nil {
+			return fmt.Errorf("invalid timestamp_column: %w", err)
+		}
+	}
+	if cfg.TimeSeriesInterval != "week" && cfg.TimeSeriesInterval != "month" {
+		return fmt.Errorf("invalid time_series_interval: %s (valid options: week, month)", cfg.TimeSeriesInterval)
+	}
+
+	// Validate rating correlation
+	if cfg.RatingColumn != "" {
+		if err := excelReader.ValidateExcelFile(cfg.ExcelFilePath, cfg.RatingColumn); err != nil {
+			return fmt.Errorf("invalid rating_column: %w", err)
+		}
+	}
+	switch cfg.Segmentation {
+	case "", "paragraph", "sentence", "semantic":
+	default:
+		return fmt.Errorf("invalid segmentation: %s (valid options: paragraph, sentence, semantic)", cfg.Segmentation)
+	}
+	if cfg.SegmentationMinLength < 0 {
+		return fmt.Errorf("invalid segmentation_min_length: %d (must not be negative)", cfg.SegmentationMinLength)
+	}
+	if cfg.SegmentationThreshold < 0 || cfg.SegmentationThreshold > 1 {
+		return fmt.Errorf("invalid segmentation_threshold: %v (must be between 0 and 1)", cfg.SegmentationThreshold)
+	}
+
+	if len(cfg.RollingWindows) > 0 && cfg.TimestampColumn == "" {
+		return fmt.Errorf("rolling_windows requires timestamp_column")
+	}
+	for _, days := range cfg.RollingWindows {
+		if days <= 0 {
+			return fmt.Errorf("invalid rolling_windows: %d (must be a positive number of days)", days)
+		}
+	}
+	if len(cfg.RatingBands) > 0 && cfg.RatingColumn == "" {
+		return fmt.Errorf("rating_bands requires rating_column")
+	}
+	for _, band := range cfg.RatingBands {
+		if band.Name == "" {
+			return fmt.Errorf("rating_bands entry without name")
+		}
+		if band.Min > band.Max {
+			return fmt.Errorf("invalid rating band %s: min %v is above max %v", band.Name, band.Min, band.Max)
+		}
 	}
 
 	// Validate output language
-	validLanguages := map[string]bool{
-		"en":    true,
-		"de":    true,
-		"de-ch": true,
-		"fr":    true,
-		"it":    true,
-	}
-	if !validLanguages[cfg.OutputLanguage] {
+	if !ValidLanguage(cfg.OutputLanguage) {
 		return fmt.Errorf("invalid output_language: %s (valid options: en, de, de-ch, fr, it)", cfg.OutputLanguage)
 	}
 
+	// Validate the provider
+	switch cfg.LLMProvider {
+	case "", llm.ProviderAnthropic:
+		if cfg.LLMBaseURL != "" {
+			return fmt.Errorf("llm_base_url requires llm_provider: openai")
+		}
+	case llm.ProviderOpenAI:
+		if cfg.BatchAPI {
+			return fmt.Errorf("batch_api requires llm_provider: anthropic")
+		}
+	default:
+		return fmt.Errorf("invalid llm_provider: %s (valid options: anthropic, openai)", cfg.LLMProvider)
+	}
+	if cfg.BatchPollInterval < 0 {
+		return fmt.Errorf("invalid batch_poll_interval: %d (must not be negative)", cfg.BatchPollInterval)
+	}
+	if cfg.MaxCostUSD < 0 {
+		return fmt.Errorf("invalid max_cost_usd: %g (must not be negative)", cfg.MaxCostUSD)
+	}
+
+	// Validate extended thinking
+	if cfg.ThinkingBudgetTokens != 0 {
+		if cfg.ThinkingBudgetTokens < claude.MinThinkingBudget {
+			return fmt.Errorf("invalid thinking_budget_tokens: %d (must be 0 or at least %d)", cfg.ThinkingBudgetTokens, claude.MinThinkingBudget)
+		}
+		model := cfg.ClaudeModel
+		if model == "" {
+			model = claude.DefaultModel
+		}
+		if cfg.LLMProvider == llm.ProviderOpenAI {
+			// The budget asks reasoning models for high reasoning effort
+			if !llm.ReasoningModel(model) {
+				return fmt.Errorf("thinking_budget_tokens requires a reasoning model with openai, %s is not one", model)
+			}
+		} else if !claude.SupportsThinking(model) {
+			return fmt.Errorf("thinking_budget_tokens requires a model with extended thinking, %s does not support it", model)
+		}
+		for _, stage := range cfg.ThinkingStages {
+			if !slices.Contains(claude.ThinkingStages, stage) {
+				return fmt.Errorf("invalid thinking_stages entry: %s (valid options: %s)", stage, strings.Join(claude.ThinkingStages, ", "))
+			}
+		}
+		if slices.Contains(cfg.ThinkingStages, claude.StageMatching) && cfg.StrictThemes {
+			return fmt.Errorf("strict_themes cannot be combined with thinking for the %s stage, as the model is made to call a tool", claude.StageMatching)
+		}
+		if cfg.StructuredOutput {
+			for _, stage := range []string{claude.StageMatching, claude.StageThemeSummaries} {
+				if slices.Contains(cfg.ThinkingStages, stage) {
+					return fmt.Errorf("structured_output cannot be combined with thinking for the %s stage, as the model is made to call a tool", stage)
+				}
+			}
+		}
+		if slices.Contains(cfg.ThinkingStages, claude.StageMatching) && cfg.MatchingTemperature != nil {
+			v.logger.Warn("matching_temperature is ignored when thinking is enabled for matching")
+		}
+	}
+
+	// Validate consensus voting
+	if cfg.ConsensusRuns < 1 {
+		return fmt.Errorf("invalid consensus_runs: %d (must be at least 1)", cfg.ConsensusRuns)
+	}
+	if cfg.MatchingTemperature != nil && (*cfg.MatchingTemperature < 0 || *cfg.MatchingTemperature > 1) {
+		return fmt.Errorf("invalid matching_temperature: %v (must be between 0 and 1)", *cfg.MatchingTemperature)
+	}
+
+	// Validate rate limits
+	if _, ok := claude.RateLimitTiers[cfg.RateLimitTier]; cfg.RateLimitTier != 0 && !ok {
+		return fmt.Errorf("invalid rate_limit_tier: %d (valid options: 1, 2, 3, 4)", cfg.RateLimitTier)
+	}
+	if cfg.RequestsPerMinute < 0 || cfg.InputTokensPerMinute < 0 || cfg.OutputTokensPerMinute < 0 {
+		return fmt.Errorf("invalid rate limits: requests_per_minute, input_tokens_per_minute and output_tokens_per_minute must not be negative")
+	}
+
+	// Validate model comparison
+	if len(cfg.ComparisonModels) != 0 && (len(cfg.ComparisonModels) != 2 || cfg.ComparisonModels[0] == cfg.ComparisonModels[1]) {
+		return fmt.Errorf("invalid comparison_models: %s (must name two different models)", strings.Join(cfg.ComparisonModels, ", "))
+	}
+	if cfg.ComparisonSampleSize < 1 {
+		return fmt.Errorf("invalid comparison_sample_size: %d (must be at least 1)", cfg.ComparisonSampleSize)
+	}
+
+	// Validate embedding provider
+	if cfg.EmbeddingsEnabled {
+		switch cfg.EmbeddingProvider {
+		case "local":
+		case "voyage":
+			if cfg.EmbeddingAPIKey == "" {
+				return fmt.Errorf("embedding_api_key is required for the voyage embedding provider")
+			}
+		default:
+			return fmt.Errorf("invalid embedding_provider: %s (valid options: local, voyage)", cfg.EmbeddingProvider)
+		}
+	}
+
+	// Validate pseudonymization
+	if cfg.Pseudonymize {
+		if cfg.PseudonymizationSalt == "" {
+			return fmt.Errorf("pseudonymization_salt is required when pseudonymize is enabled")
+		}
+		if len(cfg.PseudonymizationSalt) < 16 {
+			v.logger.Warn("Pseudonymization salt is short, use at least 16 random characters")
+		}
+	}
+
+	// Append mode merges the stored responses, which needs their texts
+	if cfg.AppendMode && cfg.OmitResponseText {
+		return fmt.Errorf("append_mode cannot be combined with omit_response_text, the responses are merged from the state")
+	}
+
+	// Validate outlier threshold
+	if cfg.OutlierThreshold < 0 || cfg.OutlierThreshold > 1 {
+		return fmt.Errorf("invalid outlier_threshold: %v (must be between 0 and 1)", cfg.OutlierThreshold)
+	}
+	if cfg.OutlierThreshold > 0 && !cfg.EmbeddingsEnabled {
+		return fmt.Errorf("outlier_threshold requires embeddings_enabled")
+	}
+
+	// Validate the sampling of the responses of theme summaries
+	if cfg.SummarySampleSize < 1 {
+		return fmt.Errorf("invalid summary_sample_size: %d (must be at least 1)", cfg.SummarySampleSize)
+	}
+	for theme, override := range cfg.ThemeOverrides {
+		if override.SummarySampleSize < 0 {
+			return fmt.Errorf("invalid summary_sample_size of theme %s: %d (must be at least 1)", theme, override.SummarySampleSize)
+		}
+	}
+	switch cfg.SummarySampling {
+	case "shortest", "longest", "random":
+	case "representative":
+		if !cfg.EmbeddingsEnabled {
+			return fmt.Errorf("summary_sampling representative requires embeddings_enabled")
+		}
+	case "stratified":
+		if cfg.RatingColumn == "" {
+			return fmt.Errorf("summary_sampling stratified requires rating_column")
+		}
+	default:
+		return fmt.Errorf("invalid summary_sampling: %s (valid options: shortest, longest, random, representative, stratified)", cfg.SummarySampling)
+	}
+
+	// Validate the summary audiences
+	audiences := make(map[string]bool)
+	for _, audience := range cfg.SummaryAudiences {
+		if strings.TrimSpace(audience.Name) == "" {
+			return fmt.Errorf("summary_audiences: every audience needs a name")
+		}
+		if audiences[audience.Name] {
+			return fmt.Errorf("summary_audiences: audience %s is listed twice", audience.Name)
+		}
+		audiences[audience.Name] = true
+		if strings.TrimSpace(audience.Prompt) == "" {
+			return fmt.Errorf("summary_audiences: audience %s needs a prompt", audience.Name)
+		}
+		if audience.Length < 0 {
+			return fmt.Errorf("summary_audiences: invalid length of audience %s: %d (must be 0 or greater)", audience.Name, audience.Length)
+		}
+		if audience.TemplatePath != "" {
+			if _, err := os.Stat(audience.TemplatePath); os.IsNotExist(err) {
+				return fmt.Errorf("summary_audiences: template of audience %s does not exist: %s", audience.Name, audience.TemplatePath)
+			}
+		}
+	}
+
+	// Validate the global summary variants
+	if cfg.SummaryVariants < 0 || cfg.SummaryVariants > maxSummaryVariants {
+		return fmt.Errorf("invalid summary_variants: %d (must be between 0 and %d)", cfg.SummaryVariants, maxSummaryVariants)
+	}
+	if cfg.SummaryDriftThreshold < 0 || cfg.SummaryDriftThreshold > 1 {
+		return fmt.Errorf("invalid summary_drift_threshold: %v (must be between 0 and 1)", cfg.SummaryDriftThreshold)
+	}
+	if cfg.SummaryVariantSelection != "" && cfg.SummaryVariantSelection != "judge" && cfg.SummaryVariantSelection != "all" {
+		return fmt.Errorf("invalid summary_variant_selection: %s (valid options: judge, all)", cfg.SummaryVariantSelection)
+	}
+
+	// Validate cluster count
+	if cfg.ClusterCount < 0 {
+		return fmt.Errorf("invalid cluster_count: %d (must be 0 or greater)", cfg.ClusterCount)
+	}
+	if cfg.ClusterCount > 0 && !cfg.EmbeddingsEnabled {
+		return fmt.Errorf("cluster_count requires embeddings_enabled")
+	}
+
+	// Validate topic model
+	if cfg.TopicModelTopics < 0 {
+		return fmt.Errorf("invalid topic_model_topics: %d (must be 0 or greater)", cfg.TopicModelTopics)
+	}
+
+	// Validate the tolerated share of failed responses
+	if cfg.MaxFailedResponsesPct < 0 || cfg.MaxFailedResponsesPct > 100 {
+		return fmt.Errorf("invalid max_failed_responses_pct: %v (must be between 0 and 100)", cfg.MaxFailedResponsesPct)
+	}
+
+	// Validate theme split threshold
+	if cfg.SplitThemeThreshold < 0 || cfg.SplitThemeThreshold >= 1 {
+		return fmt.Errorf("invalid split_theme_threshold: %v (must be between 0 and 1)", cfg.SplitThemeThreshold)
+	}
+
+	// Warn about overrides for themes that are not configured
+	if len(cfg.Themes) > 0 {
+		known := make(map[string]bool)
+		for _, theme := range cfg.Themes {
+			known[theme] = true
+		}
+		for theme := range cfg.ThemeOverrides {
+			if !known[theme] {
+				v.logger.Warn("Theme override does not match any configured theme", "theme", theme)
+			}
+		}
+	}
+
 	// Check if report template exists if provided
 	if cfg.ReportTemplatePath != "" {
 		if _, err := os.Stat(cfg.ReportTemplatePath); os.IsNotExist(err) {
@@ -66,6 +389,97 @@ func (v *Validator) ValidateConfig(cfg *config.Config) error {
 		}
 	}
 
+	// Validate appendix format
+	switch cfg.AppendixFormat {
+	case "", "markdown", "md", "html":
+	default:
+		return fmt.Errorf("invalid appendix_format: %s (valid options: markdown, html)", cfg.AppendixFormat)
+	}
+	if cfg.HTMLTheme != "" && !slices.Contains(template.HTMLThemes, cfg.HTMLTheme) {
+		return fmt.Errorf("invalid html_theme: %s (valid options: %s)", cfg.HTMLTheme, strings.Join(template.HTMLThemes, ", "))
+	}
+
+	// Validate the Excel workbook output
+	if cfg.ExcelOutputPath != "" && !strings.EqualFold(filepath.Ext(cfg.ExcelOutputPath), ".xlsx") {
+		return fmt.Errorf("excel_output_path must end in .xlsx: %s", cfg.ExcelOutputPath)
+	}
+	if cfg.AnnotatedExcelPath != "" {
+		if !strings.EqualFold(filepath.Ext(cfg.AnnotatedExcelPath), ".xlsx") {
+			return fmt.Errorf("annotated_excel_path must end in .xlsx: %s", cfg.AnnotatedExcelPath)
+		}
+		if input.DetectFormat(cfg.ExcelFilePath, cfg.InputFormat) != input.FormatXLSX {
+			return fmt.Errorf("annotated_excel_path requires an Excel file, %s is read as %s", cfg.ExcelFilePath, input.DetectFormat(cfg.ExcelFilePath, cfg.InputFormat))
+		}
+		annotated, _ := filepath.Abs(cfg.AnnotatedExcelPath)
+		source, _ := filepath.Abs(cfg.ExcelFilePath)
+		if annotated == source {
+			return fmt.Errorf("annotated_excel_path must not be the Excel file itself: %s", cfg.AnnotatedExcelPath)
+		}
+	}
+
+	// Validate the review sample
+	if cfg.ReviewSampleSize < 0 {
+		return fmt.Errorf("review_sample_size must not be negative: %d", cfg.ReviewSampleSize)
+	}
+	if cfg.ReviewSamplePath != "" && !strings.EqualFold(filepath.Ext(cfg.ReviewSamplePath), ".xlsx") {
+		return fmt.Errorf("review_sample_path must end in .xlsx: %s", cfg.ReviewSamplePath)
+	}
+
+	// Check the webhook
+	for i, report := range cfg.ReportOutputs {
+		if report.Path == "" {
+			return fmt.Errorf("report_outputs entry %d needs a path", i+1)
+		}
+		if report.Template == "" && cfg.ReportTemplatePath == "" {
+			return fmt.Errorf("report_outputs entry %d needs a template, or set report_template_path", i+1)
+		}
+		if report.Language != "" && !ValidLanguage(report.Language) {
+			return fmt.Errorf("invalid language of report_outputs entry %d: %s (valid options: en, de, de-ch, fr, it)", i+1, report.Language)
+		}
+	}
+
+	if survey := cfg.Survey; survey != nil {
+		if survey.Invited < 0 {
+			return fmt.Errorf("survey invited must not be negative: %d", survey.Invited)
+		}
+		if survey.ResponseRate < 0 || survey.ResponseRate > 100 {
+			return fmt.Errorf("survey response_rate must be between 0 and 100: %g", survey.ResponseRate)
+		}
+	}
+
+	if cfg.Webhook != nil {
+		location, err := url.Parse(cfg.Webhook.URL)
+		if err != nil || (location.Scheme != "http" && location.Scheme != "https") || location.Host == "" {
+			return fmt.Errorf("webhook needs an http or https url: %q", cfg.Webhook.URL)
+		}
+		if cfg.Webhook.Payload != "summary" && cfg.Webhook.Payload != "full" {
+			return fmt.Errorf("webhook payload must be summary or full: %s", cfg.Webhook.Payload)
+		}
+		if cfg.Webhook.Retries < 0 {
+			return fmt.Errorf("webhook retries must not be negative: %d", cfg.Webhook.Retries)
+		}
+	}
+
+	// Check the output sinks, credentials are only checked on delivery
+	for i, outputSink := range cfg.OutputSinks {
+		location, err := url.Parse(outputSink.URL)
+		if err != nil || outputSink.URL == "" {
+			return fmt.Errorf("output sink %d has an invalid url: %q", i+1, outputSink.URL)
+		}
+		switch location.Scheme {
+		case "s3", "sftp", "http", "https":
+			if location.Host == "" {
+				return fmt.Errorf("output sink %d needs a host or bucket: %s", i+1, outputSink.URL)
+			}
+		case "file":
+			if location.Path == "" {
+				return fmt.Errorf("output sink %d needs a directory: %s", i+1, outputSink.URL)
+			}
+		default:
+			return fmt.Errorf("output sink %d has an unsupported url scheme %q, use s3, sftp, https or file", i+1, location.Scheme)
+		}
+	}
+
 	// Check if cache directory exists or can be created
 	if cfg.CacheEnabled && cfg.CacheDir != "" {
 		if _, err := os.Stat(cfg.CacheDir); os.IsNotExist(err) {
@@ -76,6 +490,13 @@ func (v *Validator) ValidateConfig(cfg *config.Config) error {
 		}
 	}
 
+	// Validate the state encoding
+	switch cfg.StateEncoding {
+	case "", "yaml", "gzip":
+	default:
+		return fmt.Errorf("invalid state_encoding: %s (valid options: yaml, gzip)", cfg.StateEncoding)
+	}
+
 	// Create state file directory if it doesn't exist
 	if cfg.StateFilePath != "" {
 		stateDir := filepath.Dir(cfg.StateFilePath)